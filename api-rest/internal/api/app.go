@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// AppConfig holds everything NewApp needs to build the HTTP handler
+type AppConfig struct {
+	// AuthStrategy selects the authentication middleware (see AUTH_STRATEGY)
+	AuthStrategy string
+	// Auth holds the settings used by the authentication strategies
+	Auth AuthConfig
+	// Keycloak configures the /auth routes; nil uses an empty config
+	Keycloak *KeycloakConfig
+	// MaxRequestBodyBytes limits request bodies; zero or less uses DefaultMaxRequestBodyBytes
+	MaxRequestBodyBytes int64
+	// MetricsHandler serves /metrics; nil uses the Prometheus default registry
+	MetricsHandler http.Handler
+	// RateLimitRequests is the number of requests a tenant can make per RateLimitWindow; zero disables the limit
+	RateLimitRequests int
+	RateLimitWindow   time.Duration
+}
+
+// AppConfigFromEnv builds the application configuration from environment variables
+func AppConfigFromEnv() AppConfig {
+	keycloakConfig, err := NewKeycloakConfig()
+	if err != nil {
+		// Log error but continue - auth routes will use dummy config
+		keycloakConfig = &KeycloakConfig{}
+	}
+
+	// Without Keycloak the JWKS URL stays empty and every token is rejected
+	auth := AuthConfigFromEnv()
+	auth.JWKSURL = keycloakConfig.JWKSURL
+	auth.JWKS = keycloakConfig.JWKS()
+
+	rateLimitWindow := GetRateLimitWindow()
+
+	return AppConfig{
+		AuthStrategy:        GetAuthStrategy(),
+		Auth:                auth,
+		Keycloak:            keycloakConfig,
+		MaxRequestBodyBytes: GetMaxRequestBodyBytes(),
+		MetricsHandler:      promhttp.Handler(),
+		RateLimitRequests:   GetRateLimitRequests(rateLimitWindow),
+		RateLimitWindow:     rateLimitWindow,
+	}
+}
+
+// NewApp wires the middleware and routes of the API and returns the configured handler,
+// loading the Keycloak signing keys first when the strategy verifies signatures.
+// Database connections and tenant channels are still created per request by the handlers.
+func NewApp(cfg AppConfig) (http.Handler, error) {
+	if cfg.Keycloak == nil {
+		cfg.Keycloak = &KeycloakConfig{}
+	}
+	if cfg.MaxRequestBodyBytes <= 0 {
+		cfg.MaxRequestBodyBytes = DefaultMaxRequestBodyBytes
+	}
+	if cfg.MetricsHandler == nil {
+		cfg.MetricsHandler = promhttp.Handler()
+	}
+
+	r, err := newRouter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	warmUpJWKS(cfg)
+	// HEAD requests reach the GET routes, and the caller's trace continues
+	return otelhttp.NewHandler(ETagMiddleware(r), "api-rest"), nil
+}
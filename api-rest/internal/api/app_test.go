@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewApp(t *testing.T) {
+	metricsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	app, err := NewApp(AppConfig{
+		AuthStrategy:   AuthStrategyAPIKey,
+		Auth:           AuthConfig{APIKeys: []string{"test-key"}},
+		MetricsHandler: metricsHandler,
+	})
+	if err != nil {
+		t.Fatalf("NewApp returned error: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		apiKey         string
+		expectedStatus int
+	}{
+		{
+			name:           "Liveness skips authentication",
+			method:         "GET",
+			path:           LivenessPath,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Metrics uses the configured handler",
+			method:         "GET",
+			path:           MetricsPath,
+			expectedStatus: http.StatusTeapot,
+		},
+		{
+			name:           "Tenant route without key is rejected",
+			method:         "GET",
+			path:           "/api/tenant1/encounters",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Tenant route with wrong key is rejected",
+			method:         "GET",
+			path:           "/api/tenant1/encounters",
+			apiKey:         "wrong-key",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+
+			rr := httptest.NewRecorder()
+			app.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestNewAppInvalidAuthConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  AppConfig
+	}{
+		{
+			name: "Unknown strategy",
+			cfg:  AppConfig{AuthStrategy: "magic"},
+		},
+		{
+			name: "API key strategy without keys",
+			cfg:  AppConfig{AuthStrategy: AuthStrategyAPIKey},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, err := NewApp(tt.cfg)
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if app != nil {
+				t.Errorf("Expected nil handler, got %T", app)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -64,6 +65,10 @@ type HealthResponse struct {
 func (ah *AuthHandlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if isRequestBodyTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
@@ -78,7 +83,7 @@ func (ah *AuthHandlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	jsonEncode(w, resp)
 }
 
 // RefreshTokenHandler handles token refresh
@@ -93,7 +98,7 @@ func (ah *AuthHandlers) RefreshTokenHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	jsonEncode(w, resp)
 }
 
 // UserInfoHandler returns authenticated user information
@@ -141,10 +146,11 @@ func (ah *AuthHandlers) UserInfoHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	jsonEncode(w, response)
 }
 
-// HealthHandler provides a health check endpoint
+// HealthHandler provides a health check endpoint reporting the status of each dependency.
+// It returns 200 when all are healthy, 207 when only non-critical ones fail and 503 when a critical one fails.
 func (ah *AuthHandlers) HealthHandler(w http.ResponseWriter, r *http.Request) {
 	services := make(map[string]string)
 
@@ -160,16 +166,35 @@ func (ah *AuthHandlers) HealthHandler(w http.ResponseWriter, r *http.Request) {
 		Version:   "1.0.0", // Replace with actual version
 		Services:  services,
 	}
-
 	if keycloakStatus != "healthy" {
 		response.Status = "degraded"
-		w.WriteHeader(http.StatusServiceUnavailable)
-	} else {
-		w.WriteHeader(http.StatusOK)
+	}
+
+	checks := append(slices.Clone(healthChecks), fhirTenantHealthChecks()...)
+	for _, result := range runHealthChecks(r.Context(), checks) {
+		if result.err == nil {
+			services[result.name] = "healthy"
+			continue
+		}
+		services[result.name] = "unhealthy: " + result.err.Error()
+		log.Ctx(r.Context()).Warn().Err(result.err).Str("service", result.name).Msg("Health check failed")
+		if result.critical {
+			response.Status = "unhealthy"
+		} else if response.Status == "healthy" {
+			response.Status = "degraded"
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	switch response.Status {
+	case "unhealthy":
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case "degraded":
+		w.WriteHeader(http.StatusMultiStatus)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+	jsonEncode(w, response)
 }
 
 // ConfigureAuthRoutes sets up authentication-related routes
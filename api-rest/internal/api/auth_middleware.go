@@ -5,18 +5,60 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
-	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/rs/zerolog/log"
 )
 
-// AuthMiddleware validates JWT tokens and extracts tenant information
-func AuthMiddleware(next http.Handler) http.Handler {
+// The keycloak middlewares take a nil jwks with KEYCLOAK_VERIFY_SIGNATURE=false, which only checks the token timestamps.
+
+// tenantsFromClaims returns the tenants a validated token grants access to
+type tenantsFromClaims func(claims *JWTClaims) ([]string, error)
+
+// AuthMiddleware validates JWT tokens against the Keycloak keys and takes the tenant from the username (keycloak-username strategy)
+func AuthMiddleware(jwks *JWKSCache) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return jwtAuthHandler(next, jwks, tenantsFromUsername)
+	}
+}
+
+// GroupsAuthMiddleware validates JWT tokens against the Keycloak keys and takes the tenants from the user groups (keycloak-groups strategy)
+func GroupsAuthMiddleware(jwks *JWKSCache) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return jwtAuthHandler(next, jwks, tenantsFromGroups)
+	}
+}
+
+// tenantsFromUsername grants access to the tenant named after the preferred username
+func tenantsFromUsername(claims *JWTClaims) ([]string, error) {
+	if claims.PreferredUsername == "" {
+		return nil, errors.New("no preferred_username found in token")
+	}
+	return []string{claims.PreferredUsername}, nil
+}
+
+// tenantsFromGroups grants access to every tenant the user is a group member of,
+// accepting Keycloak group paths such as /tenant1
+func tenantsFromGroups(claims *JWTClaims) ([]string, error) {
+	var tenants []string
+	for _, group := range claims.Groups {
+		if tenant := strings.TrimPrefix(group, "/"); tenant != "" {
+			tenants = append(tenants, tenant)
+		}
+	}
+	if len(tenants) == 0 {
+		return nil, errors.New(ErrNoGroupsAssigned)
+	}
+	return tenants, nil
+}
+
+// jwtAuthHandler validates JWT tokens and checks the URL tenant against the tenants granted by the token
+func jwtAuthHandler(next http.Handler, jwks *JWKSCache, resolveTenants tenantsFromClaims) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip auth for health check and metrics endpoints
-		if r.URL.Path == HealthPath || r.URL.Path == MetricsPath {
+		if r.URL.Path == HealthPath || r.URL.Path == LivenessPath || r.URL.Path == MetricsPath {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -24,14 +66,14 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// Extract token from Authorization header
 		authHeader := r.Header.Get(AuthorizationHeader)
 		if authHeader == "" {
-			log.Warn().Str("path", r.URL.Path).Msg("Authorization header missing")
+			log.Ctx(r.Context()).Warn().Str("path", r.URL.Path).Msg("Authorization header missing")
 			http.Error(w, ErrAuthHeaderRequired, http.StatusUnauthorized)
 			return
 		}
 
 		// Check if it's a Bearer token
 		if !strings.HasPrefix(authHeader, BearerPrefix) {
-			log.Warn().Str("path", r.URL.Path).Msg("Invalid authorization header format")
+			log.Ctx(r.Context()).Warn().Str("path", r.URL.Path).Msg("Invalid authorization header format")
 			http.Error(w, ErrInvalidAuthHeader, http.StatusUnauthorized)
 			return
 		}
@@ -39,17 +81,26 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		tokenString := strings.TrimPrefix(authHeader, BearerPrefix)
 
 		// Parse and validate the JWT token
-		claims, err := validateJWTToken(tokenString)
+		claims, err := validateJWTToken(tokenString, jwks)
 		if err != nil {
-			log.Error().Err(err).Str("path", r.URL.Path).Msg(LogJWTValidationFailed)
+			log.Ctx(r.Context()).Error().Err(err).Str("path", r.URL.Path).Msg(LogJWTValidationFailed)
 			http.Error(w, ErrInvalidToken, http.StatusUnauthorized)
 			return
 		}
 
-		// Extract tenant ID from username (since groups aren't configured yet)
-		tenantID := claims.PreferredUsername
-		if tenantID == "" {
-			log.Warn().Msg("No preferred_username found in token")
+		// Admin endpoints are not tenant-scoped; their route requires the admin realm role instead
+		if r.URL.Path == IngestManifestsPath {
+			ctx := context.WithValue(r.Context(), UserIDKey, claims.Sub)
+			ctx = context.WithValue(ctx, UsernameKey, claims.PreferredUsername)
+			ctx = context.WithValue(ctx, JWTClaimsKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		// Extract the tenants granted by the token
+		tenants, err := resolveTenants(claims)
+		if err != nil {
+			log.Ctx(r.Context()).Warn().Err(err).Str("path", r.URL.Path).Msg(LogTenantExtractionFailed)
 			http.Error(w, ErrInvalidTenantConfig, http.StatusForbidden)
 			return
 		}
@@ -57,16 +108,16 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// Validate tenant from URL path
 		urlTenant, err := extractTenantFromURL(r.URL.Path)
 		if err != nil {
-			log.Error().Err(err).Str("path", r.URL.Path).Msg("Failed to extract tenant from URL")
+			log.Ctx(r.Context()).Error().Err(err).Str("path", r.URL.Path).Msg("Failed to extract tenant from URL")
 			http.Error(w, "Invalid URL format", http.StatusBadRequest)
 			return
 		}
 
-		// Ensure tenant in URL matches tenant in token
-		if urlTenant != tenantID {
-			log.Warn().
+		// Ensure tenant in URL matches a tenant in token
+		if !slices.Contains(tenants, urlTenant) {
+			log.Ctx(r.Context()).Warn().
 				Str("url_tenant", urlTenant).
-				Str("token_tenant", tenantID).
+				Strs("token_tenants", tenants).
 				Str("path", r.URL.Path).
 				Msg(LogTenantValidationFailed)
 			http.Error(w, ErrTenantMismatch, http.StatusForbidden)
@@ -74,7 +125,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		// Add tenant ID and user info to request context
-		ctx := context.WithValue(r.Context(), TenantIDKey, tenantID)
+		ctx := context.WithValue(r.Context(), TenantIDKey, urlTenant)
 		ctx = context.WithValue(ctx, UserIDKey, claims.Sub)
 		ctx = context.WithValue(ctx, UsernameKey, claims.PreferredUsername)
 		ctx = context.WithValue(ctx, UserGroupsKey, claims.Groups)
@@ -84,37 +135,65 @@ func AuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// validateJWTToken validates the JWT token and returns the claims
-func validateJWTToken(tokenString string) (*JWTClaims, error) {
-	// Parse the token without verification first to get the claims
-	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &JWTClaims{})
-	if err != nil {
+// validateJWTToken verifies the token signature against the Keycloak keys and returns the claims.
+// A token signed with a key that is unknown or no longer matches re-fetches the keys once, to follow key rotation.
+// Without jwks the signature is not verified, only the timestamps.
+func validateJWTToken(tokenString string, jwks *JWKSCache) (*JWTClaims, error) {
+	var claims *JWTClaims
+	var err error
+	if jwks == nil {
+		claims, err = parseUnverifiedJWTToken(tokenString)
+	} else {
+		claims, err = parseJWTToken(tokenString, jwks, false)
+		if errors.Is(err, errUnknownSigningKey) || errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			claims, err = parseJWTToken(tokenString, jwks, true)
+		}
+	}
+
+	switch {
+	case err == nil:
+		return claims, nil
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return nil, errors.New(ErrTokenExpired)
+	case errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return nil, errors.New(ErrTokenIssuedInFuture)
+	default:
 		return nil, fmt.Errorf(ErrTokenParseFailed, err)
 	}
+}
+
+// parseJWTToken parses the token, verifying an RSA signature with the key named by its kid header
+func parseJWTToken(tokenString string, jwks *JWKSCache, refresh bool) (*JWTClaims, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}), jwt.WithIssuedAt())
+	token, err := parser.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return jwks.Key(kid, refresh)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	// Extract claims
 	claims, ok := token.Claims.(*JWTClaims)
 	if !ok {
 		return nil, errors.New(ErrInvalidTokenClaims)
 	}
+	return claims, nil
+}
 
-	// Check if token is expired
-	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
-		if exp.Before(time.Now()) {
-			return nil, errors.New(ErrTokenExpired)
-		}
+// parseUnverifiedJWTToken parses the token and checks its timestamps, without verifying the signature
+func parseUnverifiedJWTToken(tokenString string) (*JWTClaims, error) {
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, &JWTClaims{})
+	if err != nil {
+		return nil, err
 	}
 
-	// Check if token is issued in the future
-	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
-		if iat.After(time.Now()) {
-			return nil, errors.New(ErrTokenIssuedInFuture)
-		}
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok {
+		return nil, errors.New(ErrInvalidTokenClaims)
+	}
+	if err := jwt.NewValidator(jwt.WithIssuedAt()).Validate(claims); err != nil {
+		return nil, err
 	}
-
-	// For production, you should verify the token signature with Keycloak's public key
-	// For now, we'll trust the token structure and timing
-
 	return claims, nil
 }
 
@@ -145,6 +224,12 @@ func GetTenantFromContext(ctx context.Context) (string, error) {
 	return tenantID, nil
 }
 
+// GetUsernameFromContext returns the authenticated username, empty when the request carries none
+func GetUsernameFromContext(ctx context.Context) string {
+	username, _ := ctx.Value(UsernameKey).(string)
+	return username
+}
+
 // GetUserFromContext extracts user information from request context
 func GetUserFromContext(ctx context.Context) (string, string, []string, error) {
 	userID, ok := ctx.Value(UserIDKey).(string)
@@ -15,6 +15,7 @@ const (
 	UsernameKey   contextKey = "username"
 	UserGroupsKey contextKey = "userGroups"
 	JWTClaimsKey  contextKey = "jwtClaims"
+	RequestIDKey  contextKey = "requestID"
 )
 
 // HTTP header constants
@@ -25,8 +26,10 @@ const (
 
 // HTTP path constants
 const (
-	HealthPath  = "/health"
-	MetricsPath = "/metrics"
+	HealthPath          = "/health"
+	LivenessPath        = "/healthz/live"
+	MetricsPath         = "/metrics"
+	IngestManifestsPath = "/api/ingest-manifests"
 )
 
 // Error message constants
@@ -47,6 +50,7 @@ const (
 	ErrTokenIssuedInFuture   = "token issued in the future"
 	ErrTokenParseFailed      = "failed to parse token: %w"
 	ErrTenantMismatch        = "tenant in URL does not match tenant in token"
+	ErrPermissionDenied      = "permission denied"
 )
 
 // Log message constants
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Authentication strategies selectable with AUTH_STRATEGY
+const (
+	AuthStrategyKeycloakGroups   = "keycloak-groups"
+	AuthStrategyKeycloakUsername = "keycloak-username"
+	AuthStrategyAPIKey           = "api-key"
+)
+
+// APIKeyHeader carries the key for the api-key strategy
+const APIKeyHeader = "X-API-Key"
+
+// ErrInvalidAPIKey is returned when the API key is missing or unknown
+const ErrInvalidAPIKey = "Invalid API key"
+
+// APIKeyUsername is stored as the username of requests authenticated with an API key
+const APIKeyUsername = "api-key"
+
+// AuthConfig holds the settings used by the authentication strategies
+type AuthConfig struct {
+	// APIKeys lists the accepted keys for the api-key strategy
+	APIKeys []string
+	// JWKSURL is the Keycloak endpoint serving the token signing keys of the keycloak strategies
+	JWKSURL string
+	// JWKSCacheTTL is how long signing keys are cached; zero or less uses DefaultJWKSCacheTTL
+	JWKSCacheTTL time.Duration
+	// JWKS is the signing key cache of the keycloak strategies; nil creates one from JWKSURL and JWKSCacheTTL
+	JWKS *JWKSCache
+	// SkipSignatureVerification only checks the token timestamps, without verifying the signature
+	SkipSignatureVerification bool
+}
+
+// jwksCache returns the signing key cache of the keycloak strategies, nil when signatures are not verified
+func (config AuthConfig) jwksCache() *JWKSCache {
+	if config.SkipSignatureVerification {
+		return nil
+	}
+	if config.JWKS != nil {
+		return config.JWKS
+	}
+	return NewJWKSCache(config.JWKSURL, config.JWKSCacheTTL)
+}
+
+// GetVerifySignature reads KEYCLOAK_VERIFY_SIGNATURE, defaulting to false so existing deployments keep working
+// until they opt in; invalid values keep the default
+func GetVerifySignature() bool {
+	if value := os.Getenv("KEYCLOAK_VERIFY_SIGNATURE"); value != "" {
+		if verify, err := strconv.ParseBool(value); err == nil {
+			return verify
+		}
+	}
+	return false
+}
+
+// GetAuthStrategy reads AUTH_STRATEGY, defaulting to keycloak-username
+func GetAuthStrategy() string {
+	if strategy := os.Getenv("AUTH_STRATEGY"); strategy != "" {
+		return strategy
+	}
+	return AuthStrategyKeycloakUsername
+}
+
+// AuthConfigFromEnv reads the authentication settings, with API keys from API_KEYS (comma-separated),
+// the signing key cache TTL from KEYCLOAK_JWKS_CACHE_TTL or JWKS_CACHE_TTL_SECONDS and signature verification
+// from KEYCLOAK_VERIFY_SIGNATURE; the JWKS URL comes from the Keycloak config
+func AuthConfigFromEnv() AuthConfig {
+	var keys []string
+	for _, key := range strings.Split(os.Getenv("API_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	verify := GetVerifySignature()
+	if !verify {
+		log.Warn().Msg("KEYCLOAK_VERIFY_SIGNATURE is not enabled: JWT signatures are not verified, any well-formed token is trusted; set KEYCLOAK_VERIFY_SIGNATURE=true to verify them against the Keycloak keys")
+	}
+	return AuthConfig{APIKeys: keys, JWKSCacheTTL: GetJWKSCacheTTL(), SkipSignatureVerification: !verify}
+}
+
+// AuthMiddlewareFactory returns the authentication middleware of a strategy
+func AuthMiddlewareFactory(strategy string, config AuthConfig) (func(http.Handler) http.Handler, error) {
+	switch strategy {
+	case AuthStrategyKeycloakGroups:
+		return GroupsAuthMiddleware(config.jwksCache()), nil
+	case AuthStrategyKeycloakUsername:
+		return AuthMiddleware(config.jwksCache()), nil
+	case AuthStrategyAPIKey:
+		if len(config.APIKeys) == 0 {
+			return nil, fmt.Errorf("auth strategy %s requires at least one key in API_KEYS", strategy)
+		}
+		return apiKeyAuthMiddleware(config.APIKeys), nil
+	default:
+		return nil, fmt.Errorf("unknown auth strategy %q, expected %s, %s or %s",
+			strategy, AuthStrategyKeycloakGroups, AuthStrategyKeycloakUsername, AuthStrategyAPIKey)
+	}
+}
+
+// apiKeyAuthMiddleware authenticates internal service-to-service calls with the X-API-Key header.
+// A valid key grants access to the tenant in the URL.
+func apiKeyAuthMiddleware(keys []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Skip auth for health check and metrics endpoints
+			if r.URL.Path == HealthPath || r.URL.Path == LivenessPath || r.URL.Path == MetricsPath {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !isValidAPIKey(r.Header.Get(APIKeyHeader), keys) {
+				log.Ctx(r.Context()).Warn().Str("path", r.URL.Path).Msg("Invalid or missing API key")
+				http.Error(w, ErrInvalidAPIKey, http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), UserIDKey, APIKeyUsername)
+			ctx = context.WithValue(ctx, UsernameKey, APIKeyUsername)
+
+			// Admin endpoints are not tenant-scoped
+			if r.URL.Path != IngestManifestsPath {
+				tenantID, err := extractTenantFromURL(r.URL.Path)
+				if err != nil {
+					log.Ctx(r.Context()).Error().Err(err).Str("path", r.URL.Path).Msg("Failed to extract tenant from URL")
+					http.Error(w, "Invalid URL format", http.StatusBadRequest)
+					return
+				}
+				ctx = context.WithValue(ctx, TenantIDKey, tenantID)
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// isValidAPIKey compares the key against the accepted keys in constant time
+func isValidAPIKey(key string, keys []string) bool {
+	if key == "" {
+		return false
+	}
+	valid := false
+	for _, accepted := range keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(accepted)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
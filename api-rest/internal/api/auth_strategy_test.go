@@ -0,0 +1,231 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthMiddlewareFactory(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		config   AuthConfig
+		wantErr  bool
+	}{
+		{name: "Keycloak groups", strategy: AuthStrategyKeycloakGroups},
+		{name: "Keycloak username", strategy: AuthStrategyKeycloakUsername},
+		{name: "API key", strategy: AuthStrategyAPIKey, config: AuthConfig{APIKeys: []string{"secret"}}},
+		{name: "API key without keys", strategy: AuthStrategyAPIKey, wantErr: true},
+		{name: "Invalid strategy", strategy: "basic-auth", wantErr: true},
+		{name: "Empty strategy", strategy: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware, err := AuthMiddlewareFactory(tt.strategy, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AuthMiddlewareFactory(%q) error = %v, wantErr %v", tt.strategy, err, tt.wantErr)
+			}
+			if !tt.wantErr && middleware == nil {
+				t.Errorf("Expected a middleware for strategy %q", tt.strategy)
+			}
+		})
+	}
+}
+
+func TestAuthStrategies(t *testing.T) {
+	keys := newTestKeySet(t)
+	config := AuthConfig{APIKeys: []string{"secret", "other-secret"}, JWKSURL: keys.server.URL}
+
+	tests := []struct {
+		name           string
+		strategy       string
+		path           string
+		claims         *JWTClaims
+		apiKey         string
+		expectedStatus int
+		expectedTenant string
+	}{
+		{
+			name:           "Username matches URL tenant",
+			strategy:       AuthStrategyKeycloakUsername,
+			path:           "/api/tenant1/patients",
+			claims:         &JWTClaims{Sub: "u1", PreferredUsername: "tenant1"},
+			expectedStatus: http.StatusOK,
+			expectedTenant: "tenant1",
+		},
+		{
+			name:           "Username does not match URL tenant",
+			strategy:       AuthStrategyKeycloakUsername,
+			path:           "/api/tenant2/patients",
+			claims:         &JWTClaims{Sub: "u1", PreferredUsername: "tenant1"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Group path matches URL tenant",
+			strategy:       AuthStrategyKeycloakGroups,
+			path:           "/api/tenant2/patients",
+			claims:         &JWTClaims{Sub: "u1", PreferredUsername: "alice", Groups: []string{"/tenant1", "/tenant2"}},
+			expectedStatus: http.StatusOK,
+			expectedTenant: "tenant2",
+		},
+		{
+			name:           "No group for URL tenant",
+			strategy:       AuthStrategyKeycloakGroups,
+			path:           "/api/tenant3/patients",
+			claims:         &JWTClaims{Sub: "u1", PreferredUsername: "alice", Groups: []string{"/tenant1"}},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "No groups assigned",
+			strategy:       AuthStrategyKeycloakGroups,
+			path:           "/api/tenant1/patients",
+			claims:         &JWTClaims{Sub: "u1", PreferredUsername: "tenant1"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Valid API key",
+			strategy:       AuthStrategyAPIKey,
+			path:           "/api/tenant1/patients",
+			apiKey:         "other-secret",
+			expectedStatus: http.StatusOK,
+			expectedTenant: "tenant1",
+		},
+		{
+			name:           "Unknown API key",
+			strategy:       AuthStrategyAPIKey,
+			path:           "/api/tenant1/patients",
+			apiKey:         "wrong",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Missing API key",
+			strategy:       AuthStrategyAPIKey,
+			path:           "/api/tenant1/patients",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "API key strategy ignores bearer tokens",
+			strategy:       AuthStrategyAPIKey,
+			path:           "/api/tenant1/patients",
+			claims:         &JWTClaims{Sub: "u1", PreferredUsername: "tenant1"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Health endpoint skips API key",
+			strategy:       AuthStrategyAPIKey,
+			path:           HealthPath,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware, err := AuthMiddlewareFactory(tt.strategy, config)
+			if err != nil {
+				t.Fatalf("AuthMiddlewareFactory(%q) error = %v", tt.strategy, err)
+			}
+
+			var gotTenant string
+			handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotTenant, _ = GetTenantFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.claims != nil {
+				req.Header.Set(AuthorizationHeader, BearerPrefix+keys.sign(t, tt.claims))
+			}
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if gotTenant != tt.expectedTenant {
+				t.Errorf("Expected tenant %q in context, got %q", tt.expectedTenant, gotTenant)
+			}
+		})
+	}
+}
+
+func TestAuthConfigFromEnv(t *testing.T) {
+	t.Setenv("API_KEYS", " key-1, ,key-2 ")
+
+	config := AuthConfigFromEnv()
+	if len(config.APIKeys) != 2 || config.APIKeys[0] != "key-1" || config.APIKeys[1] != "key-2" {
+		t.Errorf("Expected keys [key-1 key-2], got %v", config.APIKeys)
+	}
+}
+
+func TestGetVerifySignature(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{value: "", want: false},
+		{value: "true", want: true},
+		{value: "1", want: true},
+		{value: "false", want: false},
+		{value: "invalid", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("KEYCLOAK_VERIFY_SIGNATURE", tt.value)
+			if got := GetVerifySignature(); got != tt.want {
+				t.Errorf("GetVerifySignature() = %v, want %v", got, tt.want)
+			}
+			if got := AuthConfigFromEnv().SkipSignatureVerification; got != !tt.want {
+				t.Errorf("Expected SkipSignatureVerification %v, got %v", !tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareFactorySignatureVerification(t *testing.T) {
+	keys := newTestKeySet(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	forged := signTestToken(t, "key-1", otherKey, &JWTClaims{PreferredUsername: "tenant1"})
+
+	tests := []struct {
+		name           string
+		skip           bool
+		expectedStatus int
+	}{
+		{name: "KEYCLOAK_VERIFY_SIGNATURE=true", expectedStatus: http.StatusUnauthorized},
+		{name: "KEYCLOAK_VERIFY_SIGNATURE=false", skip: true, expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware, err := AuthMiddlewareFactory(AuthStrategyKeycloakUsername,
+				AuthConfig{JWKSURL: keys.server.URL, SkipSignatureVerification: tt.skip})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/tenant1/encounters", nil)
+			req.Header.Set("Authorization", "Bearer "+forged)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
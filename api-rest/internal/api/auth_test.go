@@ -1,9 +1,22 @@
 package api
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 func TestAuthMiddleware(t *testing.T) {
@@ -14,7 +27,7 @@ func TestAuthMiddleware(t *testing.T) {
 	})
 
 	// Wrap with auth middleware
-	authHandler := AuthMiddleware(handler)
+	authHandler := AuthMiddleware(newTestKeySet(t).jwks())(handler)
 
 	tests := []struct {
 		name           string
@@ -123,3 +136,509 @@ func TestExtractTenantFromURL(t *testing.T) {
 		})
 	}
 }
+
+func TestContextKeysAreTyped(t *testing.T) {
+	claims := &JWTClaims{PreferredUsername: "tenant1"}
+
+	// Values stored under plain string keys must not collide with the typed keys
+	ctx := context.WithValue(context.Background(), "jwtClaims", claims)
+	ctx = context.WithValue(ctx, "tenantID", "tenant1")
+
+	if _, ok := ctx.Value(JWTClaimsKey).(*JWTClaims); ok {
+		t.Errorf("Expected JWT claims stored under a string key not to be found with JWTClaimsKey")
+	}
+	if _, err := GetTenantFromContext(ctx); err == nil {
+		t.Errorf("Expected tenant stored under a string key not to be found with TenantIDKey")
+	}
+
+	// Values stored under the typed keys are found
+	ctx = context.WithValue(ctx, JWTClaimsKey, claims)
+	ctx = context.WithValue(ctx, TenantIDKey, "tenant1")
+
+	if got, ok := ctx.Value(JWTClaimsKey).(*JWTClaims); !ok || got != claims {
+		t.Errorf("Expected JWT claims to be found with JWTClaimsKey")
+	}
+	if tenantID, err := GetTenantFromContext(ctx); err != nil || tenantID != "tenant1" {
+		t.Errorf("Expected tenant1, got %q (err: %v)", tenantID, err)
+	}
+}
+
+func TestAuthModelConstants(t *testing.T) {
+	constants := map[string]string{
+		"TenantIDKey":               string(TenantIDKey),
+		"UserIDKey":                 string(UserIDKey),
+		"UsernameKey":               string(UsernameKey),
+		"UserGroupsKey":             string(UserGroupsKey),
+		"JWTClaimsKey":              string(JWTClaimsKey),
+		"AuthorizationHeader":       AuthorizationHeader,
+		"BearerPrefix":              BearerPrefix,
+		"HealthPath":                HealthPath,
+		"LivenessPath":              LivenessPath,
+		"MetricsPath":               MetricsPath,
+		"IngestManifestsPath":       IngestManifestsPath,
+		"ErrAuthHeaderRequired":     ErrAuthHeaderRequired,
+		"ErrInvalidAuthHeader":      ErrInvalidAuthHeader,
+		"ErrInvalidToken":           ErrInvalidToken,
+		"ErrInvalidTenantConfig":    ErrInvalidTenantConfig,
+		"ErrTenantMismatch":         ErrTenantMismatch,
+		"LogJWTValidationFailed":    LogJWTValidationFailed,
+		"LogTenantExtractionFailed": LogTenantExtractionFailed,
+		"LogTenantValidationFailed": LogTenantValidationFailed,
+	}
+
+	for name, value := range constants {
+		if value == "" {
+			t.Errorf("Expected constant %s to be non-empty", name)
+		}
+	}
+}
+
+// testKeySet serves RSA signing keys as a JWKS document, like the Keycloak certs endpoint
+type testKeySet struct {
+	mu      sync.Mutex
+	kid     string
+	keys    map[string]*rsa.PrivateKey
+	fetches int
+	server  *httptest.Server
+}
+
+// newTestKeySet starts a JWKS server with a single signing key
+func newTestKeySet(t *testing.T) *testKeySet {
+	t.Helper()
+
+	ks := &testKeySet{}
+	ks.rotate(t, "key-1")
+	ks.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ks.mu.Lock()
+		defer ks.mu.Unlock()
+		ks.fetches++
+
+		var keys []jsonWebKey
+		for kid, key := range ks.keys {
+			keys = append(keys, jsonWebKey{
+				Kid: kid,
+				Kty: "RSA",
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	t.Cleanup(ks.server.Close)
+	return ks
+}
+
+// rotate replaces the served keys with a new key, which signs the following tokens
+func (ks *testKeySet) rotate(t *testing.T, kid string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.kid = kid
+	ks.keys = map[string]*rsa.PrivateKey{kid: key}
+}
+
+// sign signs claims with the current key
+func (ks *testKeySet) sign(t *testing.T, claims *JWTClaims) string {
+	t.Helper()
+
+	ks.mu.Lock()
+	kid, key := ks.kid, ks.keys[ks.kid]
+	ks.mu.Unlock()
+	return signTestToken(t, kid, key, claims)
+}
+
+// jwks returns a key cache reading from the test server
+func (ks *testKeySet) jwks() *JWKSCache {
+	return NewJWKSCache(ks.server.URL, time.Hour)
+}
+
+// signTestToken signs claims with an RSA key, naming it with kid
+func signTestToken(t *testing.T, kid string, key *rsa.PrivateKey, claims *JWTClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestValidateJWTToken(t *testing.T) {
+	keys := newTestKeySet(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	now := time.Now()
+
+	valid := keys.sign(t, &JWTClaims{Sub: "u1", PreferredUsername: "tenant1", Exp: now.Add(time.Hour).Unix()})
+	parts := strings.Split(valid, ".")
+	forgedClaims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"u1","preferred_username":"tenant2"}`))
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &JWTClaims{PreferredUsername: "tenant1"}).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{name: "Valid token", token: valid},
+		{name: "Tampered claims", token: parts[0] + "." + forgedClaims + "." + parts[2], wantErr: "signature is invalid"},
+		{
+			name:    "Expired token",
+			token:   keys.sign(t, &JWTClaims{PreferredUsername: "tenant1", Exp: now.Add(-time.Minute).Unix()}),
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:    "Issued in the future",
+			token:   keys.sign(t, &JWTClaims{PreferredUsername: "tenant1", Iat: now.Add(time.Hour).Unix()}),
+			wantErr: ErrTokenIssuedInFuture,
+		},
+		{
+			name:    "Signed by another key with the same key ID",
+			token:   signTestToken(t, "key-1", otherKey, &JWTClaims{PreferredUsername: "tenant1"}),
+			wantErr: "signature is invalid",
+		},
+		{
+			name:    "Unknown key ID",
+			token:   signTestToken(t, "key-unknown", otherKey, &JWTClaims{PreferredUsername: "tenant1"}),
+			wantErr: "unknown signing key",
+		},
+		{name: "HMAC signed token", token: hmacToken, wantErr: "signing method HS256 is invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := validateJWTToken(tt.token, keys.jwks())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if claims.PreferredUsername != "tenant1" {
+					t.Errorf("Expected username tenant1, got %q", claims.PreferredUsername)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateJWTTokenKeyRotation(t *testing.T) {
+	origInterval := jwksMinRefreshInterval
+	jwksMinRefreshInterval = 0
+	t.Cleanup(func() { jwksMinRefreshInterval = origInterval })
+
+	keys := newTestKeySet(t)
+	jwks := keys.jwks()
+
+	if _, err := validateJWTToken(keys.sign(t, &JWTClaims{PreferredUsername: "tenant1"}), jwks); err != nil {
+		t.Fatalf("Unexpected error before rotation: %v", err)
+	}
+
+	// Keycloak rotates to a new key; the cached keys are still within their TTL
+	keys.rotate(t, "key-2")
+	if _, err := validateJWTToken(keys.sign(t, &JWTClaims{PreferredUsername: "tenant1"}), jwks); err != nil {
+		t.Fatalf("Expected the rotated key to be fetched, got %v", err)
+	}
+	if keys.fetches != 2 {
+		t.Errorf("Expected 2 JWKS fetches, got %d", keys.fetches)
+	}
+
+	// A known key is served from the cache
+	if _, err := validateJWTToken(keys.sign(t, &JWTClaims{PreferredUsername: "tenant1"}), jwks); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if keys.fetches != 2 {
+		t.Errorf("Expected the cached keys to be reused, got %d fetches", keys.fetches)
+	}
+}
+
+func TestValidateJWTTokenRefreshIsRateLimited(t *testing.T) {
+	keys := newTestKeySet(t)
+	jwks := keys.jwks()
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		token := signTestToken(t, "key-unknown", otherKey, &JWTClaims{PreferredUsername: "tenant1"})
+		if _, err := validateJWTToken(token, jwks); err == nil {
+			t.Fatal("Expected an error for an unknown key ID")
+		}
+	}
+	if keys.fetches != 1 {
+		t.Errorf("Expected a single JWKS fetch within the refresh interval, got %d", keys.fetches)
+	}
+}
+
+func TestJWKSCacheRefreshesExpiredKeysInBackground(t *testing.T) {
+	keys := newTestKeySet(t)
+	jwks := NewJWKSCache(keys.server.URL, 50*time.Millisecond)
+
+	if _, err := jwks.Key("key-1", false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Once the TTL expires the cached key keeps verifying tokens while the keys are re-fetched
+	keys.rotate(t, "key-2")
+	time.Sleep(60 * time.Millisecond)
+	if _, err := jwks.Key("key-1", false); err != nil {
+		t.Fatalf("Expected the expired key to be served during the refresh, got %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		keys.mu.Lock()
+		fetches := keys.fetches
+		keys.mu.Unlock()
+		if fetches == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a background JWKS fetch, got %d fetches", fetches)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Wait for the refreshed keys to be stored
+	for {
+		if _, err := jwks.Key("key-2", false); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the refreshed key to be cached")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestValidateJWTTokenWithoutJWKSURL(t *testing.T) {
+	keys := newTestKeySet(t)
+	token := keys.sign(t, &JWTClaims{PreferredUsername: "tenant1"})
+
+	if _, err := validateJWTToken(token, NewJWKSCache("", 0)); err == nil {
+		t.Error("Expected tokens to be rejected without a JWKS URL")
+	}
+}
+
+func TestGetJWKSCacheTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		keycloak string
+		seconds  string
+		want     time.Duration
+	}{
+		{name: "Unset", want: DefaultJWKSCacheTTL},
+		{name: "Seconds fallback", seconds: "60", want: time.Minute},
+		{name: "Zero seconds", seconds: "0", want: DefaultJWKSCacheTTL},
+		{name: "Invalid seconds", seconds: "invalid", want: DefaultJWKSCacheTTL},
+		{name: "Keycloak duration", keycloak: "10m", want: 10 * time.Minute},
+		{name: "Keycloak duration wins", keycloak: "90s", seconds: "60", want: 90 * time.Second},
+		{name: "Invalid Keycloak duration falls back", keycloak: "300", seconds: "60", want: time.Minute},
+		{name: "Negative Keycloak duration", keycloak: "-1m", want: DefaultJWKSCacheTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KEYCLOAK_JWKS_CACHE_TTL", tt.keycloak)
+			t.Setenv("JWKS_CACHE_TTL_SECONDS", tt.seconds)
+			if got := GetJWKSCacheTTL(); got != tt.want {
+				t.Errorf("GetJWKSCacheTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateJWTTokenWithoutSignatureVerification(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	claims, err := validateJWTToken(signTestToken(t, "key-unknown", otherKey, &JWTClaims{PreferredUsername: "tenant1"}), nil)
+	if err != nil {
+		t.Fatalf("Expected any signature to be accepted, got %v", err)
+	}
+	if claims.PreferredUsername != "tenant1" {
+		t.Errorf("Expected username tenant1, got %q", claims.PreferredUsername)
+	}
+
+	expired := signTestToken(t, "key-unknown", otherKey, &JWTClaims{PreferredUsername: "tenant1", Exp: time.Now().Add(-time.Minute).Unix()})
+	if _, err := validateJWTToken(expired, nil); err == nil || err.Error() != ErrTokenExpired {
+		t.Errorf("Expected %q, got %v", ErrTokenExpired, err)
+	}
+	future := signTestToken(t, "key-unknown", otherKey, &JWTClaims{PreferredUsername: "tenant1", Iat: time.Now().Add(time.Hour).Unix()})
+	if _, err := validateJWTToken(future, nil); err == nil || err.Error() != ErrTokenIssuedInFuture {
+		t.Errorf("Expected %q, got %v", ErrTokenIssuedInFuture, err)
+	}
+}
+
+func TestKeycloakConfigFetchJWKS(t *testing.T) {
+	keys := newTestKeySet(t)
+	config := &KeycloakConfig{JWKSURL: keys.server.URL}
+
+	for i := 0; i < 3; i++ {
+		fetched, err := config.FetchJWKS(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, ok := fetched["key-1"]; !ok || len(fetched) != 1 {
+			t.Fatalf("Expected the key-1 signing key, got %v", fetched)
+		}
+	}
+	if keys.fetches != 1 {
+		t.Errorf("Expected the key set to be cached after one fetch, got %d fetches", keys.fetches)
+	}
+
+	// The middleware shares the cache, so it does not fetch again
+	if _, err := config.JWKS().Key("key-1", false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if keys.fetches != 1 {
+		t.Errorf("Expected the shared cache to be used, got %d fetches", keys.fetches)
+	}
+}
+
+func TestKeycloakConfigFetchJWKSCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	config := &KeycloakConfig{JWKSURL: server.URL}
+	if _, err := config.FetchJWKS(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context deadline, got %v", err)
+	}
+}
+
+func TestAuthMiddlewareAdminPath(t *testing.T) {
+	keys := newTestKeySet(t)
+
+	// The route of the admin path requires the admin realm role, as registered in NewRouter
+	handler := AuthMiddleware(keys.jwks())(RequirePermission(PermissionAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tests := []struct {
+		name           string
+		claims         *JWTClaims
+		expectedStatus int
+	}{
+		{name: "Admin role is allowed", claims: claimsWithRoles("ops-admin", "admin"), expectedStatus: http.StatusOK},
+		{name: "Admin role without tenant is allowed", claims: claimsWithRoles("", "admin"), expectedStatus: http.StatusOK},
+		{name: "Reviewer role is forbidden", claims: claimsWithRoles("tenant1", "reviewer"), expectedStatus: http.StatusForbidden},
+		{name: "No realm role is forbidden", claims: &JWTClaims{Sub: "user-tenant1", PreferredUsername: "tenant1"}, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := keys.sign(t, tt.claims)
+
+			req := httptest.NewRequest("GET", IngestManifestsPath, nil)
+			req.Header.Set(AuthorizationHeader, BearerPrefix+token)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestJWKSCacheServesCachedKeysDuringFetch(t *testing.T) {
+	origInterval := jwksMinRefreshInterval
+	jwksMinRefreshInterval = 0
+	t.Cleanup(func() { jwksMinRefreshInterval = origInterval })
+
+	keys := newTestKeySet(t)
+	release := make(chan struct{})
+	var slow atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.Load() {
+			<-release
+		}
+		keys.server.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	jwks := NewJWKSCache(server.URL, time.Hour)
+	if _, err := jwks.Key("key-1", false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A forced refresh hangs on Keycloak while other requests are served from the cache
+	slow.Store(true)
+	go jwks.Key("key-2", true)
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := jwks.Key("key-1", false)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the cached key while a JWKS fetch is in flight")
+	}
+}
+
+func TestJWKSCacheRateLimitsFailedInitialFetch(t *testing.T) {
+	fetches := 0
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	jwks := NewJWKSCache(server.URL, time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := jwks.Key("key-1", false); err == nil {
+				t.Error("Expected an error while Keycloak is unavailable")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := jwks.Key("key-1", true); err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Errorf("Expected the last fetch error, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fetches != 1 {
+		t.Errorf("Expected a single JWKS fetch within the refresh interval, got %d", fetches)
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultMaxRequestBodyBytes is the default request body limit (1MB)
+const DefaultMaxRequestBodyBytes int64 = 1 << 20
+
+// MaxBytesMiddleware limits the size of request bodies to prevent memory exhaustion
+func MaxBytesMiddleware(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetMaxRequestBodyBytes reads MAX_REQUEST_BODY_BYTES from the environment
+func GetMaxRequestBodyBytes() int64 {
+	value := os.Getenv("MAX_REQUEST_BODY_BYTES")
+	if value == "" {
+		return DefaultMaxRequestBodyBytes
+	}
+
+	maxBytes, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || maxBytes <= 0 {
+		log.Warn().
+			Str("value", value).
+			Int64("default", DefaultMaxRequestBodyBytes).
+			Msg("Invalid MAX_REQUEST_BODY_BYTES, using default")
+		return DefaultMaxRequestBodyBytes
+	}
+
+	return maxBytes
+}
+
+// isRequestBodyTooLarge checks if a decode error was caused by the body size limit
+func isRequestBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBytesMiddleware(t *testing.T) {
+	handler := MaxBytesMiddleware(1 << 20)(http.HandlerFunc(ReviewRequestHandler))
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "Body over limit should be rejected",
+			body:           `{"entity":"` + strings.Repeat("a", 2<<20) + `","id":"123"}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "Invalid JSON under limit should be a bad request",
+			body:           `{"entity":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/tenant1/review-request", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), TenantIDKey, "tenant1"))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestGetMaxRequestBodyBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected int64
+	}{
+		{
+			name:     "Unset uses default",
+			value:    "",
+			expected: DefaultMaxRequestBodyBytes,
+		},
+		{
+			name:     "Valid value is used",
+			value:    "2048",
+			expected: 2048,
+		},
+		{
+			name:     "Invalid value uses default",
+			value:    "abc",
+			expected: DefaultMaxRequestBodyBytes,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_REQUEST_BODY_BYTES", tt.value)
+
+			if result := GetMaxRequestBodyBytes(); result != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// RequestIDHeader carries the correlation ID of a request, echoed on the response
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-provided correlation IDs, so log events can't be inflated
+const maxRequestIDLength = 128
+
+// CorrelationIDMiddleware takes the correlation ID from X-Request-ID, or generates a UUID when it is
+// absent or invalid, and sets it on the response. The request context carries the ID and a logger
+// adding it as request_id, so log.Ctx(ctx) events of the request can be correlated.
+func CorrelationIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		logger := log.Logger.With().Str("request_id", requestID).Logger()
+		ctx := context.WithValue(r.Context(), RequestIDKey, requestID)
+		next.ServeHTTP(w, r.WithContext(logger.WithContext(ctx)))
+	})
+}
+
+// isValidRequestID accepts non-empty IDs of printable ASCII characters up to maxRequestIDLength
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// GetRequestIDFromContext returns the correlation ID set by CorrelationIDMiddleware, empty when there is none
+func GetRequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(RequestIDKey).(string)
+	return requestID
+}
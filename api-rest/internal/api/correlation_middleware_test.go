@@ -0,0 +1,72 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestCorrelationIDMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	origLogger := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = origLogger })
+
+	tests := []struct {
+		name       string
+		header     string
+		expectedID string
+	}{
+		{name: "Client request ID is kept", header: "req-123", expectedID: "req-123"},
+		{name: "Missing request ID is generated"},
+		{name: "Request ID with spaces is replaced", header: "req 123"},
+		{name: "Oversized request ID is replaced", header: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+
+			var contextID string
+			handler := CorrelationIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contextID = GetRequestIDFromContext(r.Context())
+				log.Ctx(r.Context()).Info().Msg("handled")
+			}))
+
+			req := httptest.NewRequest("GET", "/api/tenant1/encounters", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			responseID := rr.Header().Get(RequestIDHeader)
+			if tt.expectedID != "" && responseID != tt.expectedID {
+				t.Errorf("Expected response request ID %q, got %q", tt.expectedID, responseID)
+			}
+			if tt.expectedID == "" {
+				if _, err := uuid.Parse(responseID); err != nil {
+					t.Errorf("Expected a generated UUID, got %q", responseID)
+				}
+			}
+			if contextID != responseID {
+				t.Errorf("Expected context request ID %q, got %q", responseID, contextID)
+			}
+
+			var event map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+				t.Fatalf("Failed to decode log event %q: %v", buf.String(), err)
+			}
+			if event["request_id"] != responseID {
+				t.Errorf("Expected log event with request_id %q, got %v", responseID, event)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	corsAllowedMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	corsAllowedHeaders = "Authorization, Content-Type, X-API-Key, If-Match, X-Request-ID"
+	corsExposedHeaders = "ETag, X-Request-ID"
+	corsMaxAge         = "600"
+)
+
+// CORSMiddleware adds CORS headers and answers preflight requests before authentication
+func CORSMiddleware(next http.Handler) http.Handler {
+	allowedOrigins := getCORSAllowedOrigins()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setCORSHeaders(w, r, allowedOrigins)
+
+		// Preflight requests carry no credentials, so they must not reach AuthMiddleware
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// preflightHandler lets OPTIONS requests match a route so that CORSMiddleware runs
+func preflightHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setCORSHeaders sets the CORS response headers for an allowed origin
+func setCORSHeaders(w http.ResponseWriter, r *http.Request, allowedOrigins []string) {
+	origin := r.Header.Get("Origin")
+	headers := w.Header()
+
+	switch {
+	case len(allowedOrigins) == 1 && allowedOrigins[0] == "*":
+		headers.Set("Access-Control-Allow-Origin", "*")
+	case origin != "" && slices.Contains(allowedOrigins, origin):
+		headers.Set("Access-Control-Allow-Origin", origin)
+		headers.Add("Vary", "Origin")
+	default:
+		return
+	}
+
+	headers.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+	headers.Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+	headers.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+	headers.Set("Access-Control-Max-Age", corsMaxAge)
+}
+
+// getCORSAllowedOrigins reads CORS_ALLOWED_ORIGINS as a comma-separated list (default "*")
+func getCORSAllowedOrigins() []string {
+	value := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if value == "" {
+		return []string{"*"}
+	}
+
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		return []string{"*"}
+	}
+	return origins
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPreflightOnProtectedRoute(t *testing.T) {
+	router, err := NewApp(AppConfigFromEnv())
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+	}{
+		{
+			name:   "Tenant list route",
+			method: "GET",
+			path:   "/api/tenant1/encounters",
+		},
+		{
+			name:   "Review request route",
+			method: "POST",
+			path:   "/api/tenant1/review-request",
+		},
+		{
+			name:   "Auth route",
+			method: "POST",
+			path:   "/auth/login",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("OPTIONS", tt.path, nil)
+			req.Header.Set("Origin", "http://localhost:3000")
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			req.Header.Set("Access-Control-Request-Headers", "authorization")
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			// No Authorization header is sent, so 204 means the auth check was not reached
+			if rr.Code != http.StatusNoContent {
+				t.Fatalf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+				t.Errorf("Expected Access-Control-Allow-Origin *, got %q", got)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Methods"); got != corsAllowedMethods {
+				t.Errorf("Expected Access-Control-Allow-Methods %q, got %q", corsAllowedMethods, got)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Headers"); got != corsAllowedHeaders {
+				t.Errorf("Expected Access-Control-Allow-Headers %q, got %q", corsAllowedHeaders, got)
+			}
+		})
+	}
+}
+
+func TestCORSMiddlewareAllowedOrigins(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com, https://admin.example.com")
+
+	handler := CORSMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name           string
+		origin         string
+		expectedOrigin string
+	}{
+		{
+			name:           "Allowed origin is echoed",
+			origin:         "https://admin.example.com",
+			expectedOrigin: "https://admin.example.com",
+		},
+		{
+			name:           "Unknown origin gets no CORS headers",
+			origin:         "https://evil.example.com",
+			expectedOrigin: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/tenant1/encounters", nil)
+			req.Header.Set("Origin", tt.origin)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tt.expectedOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.expectedOrigin, got)
+			}
+		})
+	}
+}
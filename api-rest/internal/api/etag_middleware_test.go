@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETagMiddleware(t *testing.T) {
+	var received *http.Request
+	handler := ETagMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"data":{}}`))
+	}))
+
+	t.Run("HEAD is served by the GET route without a body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodHead, "/api/tenant1/encounters/1", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if received.Method != http.MethodGet {
+			t.Errorf("Expected the route to see GET, got %s", received.Method)
+		}
+		if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"v1"` {
+			t.Errorf("Expected status 200 with the ETag, got %d %q", rr.Code, rr.Header().Get("ETag"))
+		}
+		if rr.Body.Len() != 0 {
+			t.Errorf("Expected no body, got %q", rr.Body.String())
+		}
+	})
+
+	t.Run("OPTIONS drops conditional headers", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/api/tenant1/encounters/1", nil)
+		req.Header.Set("If-None-Match", `"v1"`)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if received.Header.Get("If-None-Match") != "" {
+			t.Errorf("Expected If-None-Match to be removed from OPTIONS requests")
+		}
+	})
+
+	t.Run("GET is unchanged", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/tenant1/encounters/1", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Body.String() != `{"data":{}}` {
+			t.Errorf("Expected the body to be written, got %q", rr.Body.String())
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"stealthcompany.com/api-rest/internal/dal"
+	"stealthcompany.com/api-rest/internal/metrics"
+)
+
+// exportResourceTypes are the resource types of a tenant export, in export order
+var exportResourceTypes = []string{"Patient", "Practitioner", "Encounter"}
+
+// parseExportTypes returns the resource types selected by a _type parameter, every exported type without one
+func parseExportTypes(param string) ([]string, error) {
+	if param == "" {
+		return exportResourceTypes, nil
+	}
+
+	var types []string
+	for _, resourceType := range strings.Split(param, ",") {
+		resourceType = strings.TrimSpace(resourceType)
+		if !slices.Contains(exportResourceTypes, resourceType) {
+			return nil, fmt.Errorf("unsupported _type %q, expected one of %s", resourceType, strings.Join(exportResourceTypes, ","))
+		}
+		if !slices.Contains(types, resourceType) {
+			types = append(types, resourceType)
+		}
+	}
+	return types, nil
+}
+
+// exportBundle is the first line of an export, the Bundle whose entries follow one per line
+type exportBundle struct {
+	ResourceType string `json:"resourceType"`
+	Type         string `json:"type"`
+	Timestamp    string `json:"timestamp"`
+}
+
+// exportEntry is a Bundle entry line of an export
+type exportEntry struct {
+	FullURL  string                 `json:"fullUrl"`
+	Resource map[string]interface{} `json:"resource"`
+}
+
+// exportStream writes a tenant export as NDJSON and flushes after each line. The status and the Bundle line
+// are sent with the first entry, so a failure before any entry can still be answered with an error status.
+type exportStream struct {
+	w        http.ResponseWriter
+	tenantID string
+	started  bool
+}
+
+// Started reports whether the status and the Bundle line were sent
+func (s *exportStream) Started() bool {
+	return s.started
+}
+
+// start sends the status, the download headers and the Bundle line
+func (s *exportStream) start() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", "application/fhir+ndjson")
+	s.w.Header().Set("Content-Disposition", "attachment; filename=export.ndjson")
+	s.w.WriteHeader(http.StatusOK)
+	return s.writeLine("Bundle", exportBundle{
+		ResourceType: "Bundle",
+		Type:         "collection",
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// WriteEntry writes a resource of resourceType as the next Bundle entry
+func (s *exportStream) WriteEntry(resourceType string, row dal.QueryRow) error {
+	if err := s.start(); err != nil {
+		return err
+	}
+	return s.writeLine(resourceType, exportEntry{FullURL: resourceType + "/" + row.ID, Resource: row.Resource})
+}
+
+// writeLine marshals v, writes it as a line and counts its bytes in fhir_export_bytes_total
+func (s *exportStream) writeLine(resourceType string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	n, err := s.w.Write(append(body, '\n'))
+	metrics.RecordExportBytes(s.tenantID, resourceType, n)
+	if err != nil {
+		return err
+	}
+
+	// Writers that cannot flush still get the line, only later
+	_ = http.NewResponseController(s.w).Flush()
+	return nil
+}
+
+// ExportHandler handles GET /api/{tenant}/export
+// It streams the tenant resources as NDJSON, a Bundle of type collection followed by one entry per line,
+// reading the DAL directly so a large export does not hold a tenant channel worker.
+// ?_type=Encounter,Patient limits the export to these resource types.
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := GetTenantFromRequest(r)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().
+			Err(err).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Msg("Invalid tenant ID in request")
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	resourceTypes, err := parseExportTypes(r.URL.Query().Get("_type"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	stream := &exportStream{w: w, tenantID: tenantID}
+	for _, resourceType := range resourceTypes {
+		err := exportResources(r.Context(), tenantID, resourceType, func(row dal.QueryRow) error {
+			return stream.WriteEntry(resourceType, row)
+		})
+		if err == nil {
+			continue
+		}
+
+		if stream.Started() {
+			// The status was sent with the first entry, so the truncated body is the only failure signal
+			log.Ctx(r.Context()).Error().
+				Err(err).
+				Str("tenant", tenantID).
+				Str("resourceType", resourceType).
+				Msg("Export failed after the response was started")
+			return
+		}
+		log.Ctx(r.Context()).Error().
+			Err(err).
+			Str("tenant", tenantID).
+			Str("resourceType", resourceType).
+			Msg("Export failed")
+		if writeContextError(w, err) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, http.StatusInternalServerError, map[string]string{
+			"error":   "Failed to export tenant",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// An export without resources still has its Bundle line
+	if err := stream.start(); err != nil {
+		log.Ctx(r.Context()).Warn().
+			Err(err).
+			Str("tenant", tenantID).
+			Msg("Failed to write export")
+	}
+}
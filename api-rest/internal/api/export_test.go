@@ -0,0 +1,165 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"stealthcompany.com/api-rest/internal/dal"
+	"stealthcompany.com/api-rest/internal/metrics"
+)
+
+// fakeExport replaces exportResources with one streaming rows per resource type, recording the exported types
+func fakeExport(t *testing.T, rows map[string][]dal.QueryRow, errs map[string]error) *[]string {
+	t.Helper()
+	orig := exportResources
+	t.Cleanup(func() {
+		exportResources = orig
+	})
+
+	var exported []string
+	exportResources = func(ctx context.Context, tenantID, resourceType string, fn func(dal.QueryRow) error) error {
+		exported = append(exported, resourceType)
+		for _, row := range rows[resourceType] {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return errs[resourceType]
+	}
+	return &exported
+}
+
+// readExportLines decodes the NDJSON lines of an export response
+func readExportLines(t *testing.T, rr *httptest.ResponseRecorder) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(rr.Body)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Failed to decode export line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestExportHandler(t *testing.T) {
+	rows := map[string][]dal.QueryRow{
+		"Patient":   {{ID: "p1", Resource: map[string]interface{}{"resourceType": "Patient", "id": "p1"}}},
+		"Encounter": {{ID: "e1", Resource: map[string]interface{}{"resourceType": "Encounter", "id": "e1"}}, {ID: "e2", Resource: map[string]interface{}{"resourceType": "Encounter", "id": "e2"}}},
+	}
+
+	t.Run("Exports every resource type", func(t *testing.T) {
+		exported := fakeExport(t, rows, nil)
+		before := promtestutil.ToFloat64(metrics.FHIRExportBytesTotal.WithLabelValues("Encounter", ""))
+
+		rr := httptest.NewRecorder()
+		ExportHandler(rr, newTenantRequest("GET", "/api/tenant1/export", "tenant1", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if got := rr.Header().Get("Content-Type"); got != "application/fhir+ndjson" {
+			t.Errorf("Expected Content-Type application/fhir+ndjson, got %q", got)
+		}
+		if got := rr.Header().Get("Content-Disposition"); got != "attachment; filename=export.ndjson" {
+			t.Errorf("Unexpected Content-Disposition %q", got)
+		}
+		if !slices.Equal(*exported, exportResourceTypes) {
+			t.Errorf("Expected %v to be exported, got %v", exportResourceTypes, *exported)
+		}
+
+		lines := readExportLines(t, rr)
+		if len(lines) != 4 {
+			t.Fatalf("Expected a Bundle line and 3 entries, got %d lines", len(lines))
+		}
+		if lines[0]["resourceType"] != "Bundle" || lines[0]["type"] != "collection" {
+			t.Errorf("Expected a collection Bundle first, got %v", lines[0])
+		}
+		if lines[1]["fullUrl"] != "Patient/p1" || lines[3]["fullUrl"] != "Encounter/e2" {
+			t.Errorf("Unexpected entries %v", lines[1:])
+		}
+
+		if after := promtestutil.ToFloat64(metrics.FHIRExportBytesTotal.WithLabelValues("Encounter", "")); after <= before {
+			t.Errorf("Expected fhir_export_bytes_total to grow for Encounter, got %v then %v", before, after)
+		}
+	})
+
+	t.Run("Filters by _type", func(t *testing.T) {
+		exported := fakeExport(t, rows, nil)
+
+		rr := httptest.NewRecorder()
+		ExportHandler(rr, newTenantRequest("GET", "/api/tenant1/export?_type=Encounter,Patient", "tenant1", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if !slices.Equal(*exported, []string{"Encounter", "Patient"}) {
+			t.Errorf("Expected Encounter and Patient to be exported, got %v", *exported)
+		}
+		if lines := readExportLines(t, rr); len(lines) != 4 {
+			t.Errorf("Expected a Bundle line and 3 entries, got %d lines", len(lines))
+		}
+	})
+
+	t.Run("Empty export still has the Bundle", func(t *testing.T) {
+		fakeExport(t, nil, nil)
+
+		rr := httptest.NewRecorder()
+		ExportHandler(rr, newTenantRequest("GET", "/api/tenant1/export", "tenant1", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if lines := readExportLines(t, rr); len(lines) != 1 || lines[0]["resourceType"] != "Bundle" {
+			t.Errorf("Expected only the Bundle line, got %v", lines)
+		}
+	})
+
+	t.Run("Unsupported _type", func(t *testing.T) {
+		exported := fakeExport(t, rows, nil)
+
+		rr := httptest.NewRecorder()
+		ExportHandler(rr, newTenantRequest("GET", "/api/tenant1/export?_type=Observation", "tenant1", nil))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if len(*exported) != 0 {
+			t.Errorf("Expected nothing to be exported, got %v", *exported)
+		}
+	})
+
+	t.Run("Failure before the first entry", func(t *testing.T) {
+		fakeExport(t, nil, map[string]error{"Patient": errors.New("query failed")})
+
+		rr := httptest.NewRecorder()
+		ExportHandler(rr, newTenantRequest("GET", "/api/tenant1/export", "tenant1", nil))
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+	})
+
+	t.Run("Failure after the first entry truncates the export", func(t *testing.T) {
+		exported := fakeExport(t, rows, map[string]error{"Patient": errors.New("query failed")})
+
+		rr := httptest.NewRecorder()
+		ExportHandler(rr, newTenantRequest("GET", "/api/tenant1/export", "tenant1", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected the started status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if len(*exported) != 1 {
+			t.Errorf("Expected the export to stop at Patient, got %v", *exported)
+		}
+	})
+}
@@ -1,43 +1,87 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
+	"stealthcompany.com/api-rest/internal/dal"
+	"stealthcompany.com/api-rest/internal/metrics"
+	"stealthcompany.com/pkg/fhirutil"
 )
 
 // RootHandler returns the API information
 func RootHandler(w http.ResponseWriter, r *http.Request) {
-	log.Info().
+	log.Ctx(r.Context()).Info().
 		Str("method", r.Method).
 		Str("path", r.URL.Path).
 		Str("remote_addr", r.RemoteAddr).
 		Msg("Root request received")
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
+	writeJSON(w, http.StatusOK, map[string]string{
 		"api": "EVTeChallenge",
 	})
 }
 
+// LivenessHandler handles GET /healthz/live
+// It fails with 503 when the goroutine count exceeds LIVENESS_GOROUTINE_THRESHOLD, so leaks get the pod restarted
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	goroutines := runtime.NumGoroutine()
+	threshold := livenessGoroutineThreshold()
+
+	w.Header().Set("Content-Type", "application/json")
+	if goroutines > threshold {
+		metrics.RecordGoroutineThresholdExceeded()
+		log.Ctx(r.Context()).Warn().
+			Int("goroutines", goroutines).
+			Int("threshold", threshold).
+			Msg("Liveness probe failed: goroutine count exceeds threshold")
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":     "unhealthy",
+			"goroutines": goroutines,
+			"threshold":  threshold,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":     "ok",
+		"goroutines": goroutines,
+		"threshold":  threshold,
+	})
+}
+
+// livenessGoroutineThreshold reads LIVENESS_GOROUTINE_THRESHOLD, defaulting to 1000
+func livenessGoroutineThreshold() int {
+	if value := os.Getenv("LIVENESS_GOROUTINE_THRESHOLD"); value != "" {
+		if threshold, err := strconv.Atoi(value); err == nil && threshold > 0 {
+			return threshold
+		}
+	}
+	return 1000
+}
+
 // GetResourceByIDHandler handles GET /{resource}/{id}
 func GetResourceByIDHandler(resourceType string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenantID, err := GetTenantFromRequest(r)
 		if err != nil {
-			log.Warn().
+			log.Ctx(r.Context()).Warn().
 				Err(err).
 				Str("method", r.Method).
 				Str("path", r.URL.Path).
 				Msg("Invalid tenant ID in request")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{
+			writeJSON(w, http.StatusBadRequest, map[string]string{
 				"error": err.Error(),
 			})
 			return
@@ -46,33 +90,43 @@ func GetResourceByIDHandler(resourceType string) http.HandlerFunc {
 		vars := mux.Vars(r)
 		id := vars["id"]
 		if id == "" {
-			log.Warn().
+			log.Ctx(r.Context()).Warn().
 				Str("tenant", tenantID).
 				Str("resourceType", resourceType).
 				Msg("Missing resource ID in request")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "missing id"})
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing id"})
 			return
 		}
 
 		// Check if tenant is warmed up and send to channel
 		if channels, exists := GetTenantChannels(tenantID); exists {
 			// Get response channel from pool
-			respCh := channels.responsePool.GetChannel()
+			respCh, err := channels.responsePool.GetChannel(r.Context())
+			if err != nil {
+				writeContextError(w, err)
+				return
+			}
+			defer channels.responsePool.ReturnChannel(respCh)
 			responseKey := respCh.key
 
 			// Send request to appropriate channel
 			switch resourceType {
 			case "Encounter":
-				channels.getEncounterCh <- RequestMessage{tenantID, resourceType, id, responseKey, 0, 0}
+				channels.getEncounterCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ID: id, ResponseKey: responseKey, Ctx: r.Context()}
 			case "Patient":
-				channels.getPatientCh <- RequestMessage{tenantID, resourceType, id, responseKey, 0, 0}
+				includeSummary := r.URL.Query().Get("include_summary") == "true"
+				channels.getPatientCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ID: id, ResponseKey: responseKey, IncludeSummary: includeSummary, Ctx: r.Context()}
 			case "Practitioner":
-				channels.getPractitionerCh <- RequestMessage{tenantID, resourceType, id, responseKey, 0, 0}
+				includeStats := r.URL.Query().Get("stats") == "true"
+				channels.getPractitionerCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ID: id, ResponseKey: responseKey, IncludeStats: includeStats, Ctx: r.Context()}
+			case "Observation":
+				channels.getObservationCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ID: id, ResponseKey: responseKey, Ctx: r.Context()}
+			case "Condition":
+				channels.getConditionCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ID: id, ResponseKey: responseKey, Ctx: r.Context()}
+			case "MedicationRequest":
+				channels.getMedicationRequestCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ID: id, ResponseKey: responseKey, Ctx: r.Context()}
 			default:
-				channels.responsePool.ReturnChannel(respCh)
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(map[string]string{"error": "unsupported resource type"})
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported resource type"})
 				return
 			}
 
@@ -80,21 +134,40 @@ func GetResourceByIDHandler(resourceType string) http.HandlerFunc {
 			select {
 			case response := <-respCh.ch:
 				if response.Error != nil {
-					w.WriteHeader(http.StatusInternalServerError)
-					json.NewEncoder(w).Encode(map[string]string{"error": response.Error.Error()})
+					if writeContextError(w, response.Error) {
+						return
+					}
+					if strings.Contains(response.Error.Error(), "not found") {
+						writeOperationOutcome(w, r, http.StatusNotFound, fhirutil.IssueCodeNotFound,
+							fmt.Sprintf("%s/%s not found", resourceType, id))
+						return
+					}
+					writeJSON(w, http.StatusInternalServerError, map[string]string{"error": response.Error.Error()})
 					return
 				}
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusOK)
-				json.NewEncoder(w).Encode(response.Data)
+				// An _elements subset gets its own ETag, so it is not revalidated against the full resource
+				elements := fhirutil.ParseElements(r.URL.Query().Get("_elements"))
+				etag := dal.ElementsETag(response.ETag, elements)
+				if etag != "" {
+					w.Header().Set("ETag", etag)
+				}
+				// The client already holds this version of the resource
+				if dal.MatchesIfNoneMatch(r.Header.Get("If-None-Match"), etag) {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				if len(elements) > 0 {
+					filterResourceElements(response.Data, elements)
+				}
+				w.Header().Set("Content-Type", fhirutil.NegotiateContentType(r.Header.Get("Accept")))
+				writeJSON(w, http.StatusOK, response.Data)
 			case <-time.After(30 * time.Second):
 				http.Error(w, "Request timeout", http.StatusRequestTimeout)
 			}
 		} else {
 			// Tenant not warmed up
 			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusServiceUnavailable)
-			json.NewEncoder(w).Encode(map[string]string{
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{
 				"error":   "Tenant not warmed up",
 				"message": "Please call /warm-up-tenant first",
 			})
@@ -102,18 +175,92 @@ func GetResourceByIDHandler(resourceType string) http.HandlerFunc {
 	}
 }
 
+// GetEncounterSummaryHandler handles GET /encounters/{id}/summary, returning the encounter with its patient,
+// practitioners and review info. References that cannot be read are left out instead of failing the request.
+func GetEncounterSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := GetTenantFromRequest(r)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().
+			Err(err).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Msg("Invalid tenant ID in request")
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		log.Ctx(r.Context()).Warn().
+			Str("tenant", tenantID).
+			Msg("Missing encounter ID in summary request")
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing id"})
+		return
+	}
+
+	// Check if tenant is warmed up and send to channel
+	if channels, exists := GetTenantChannels(tenantID); exists {
+		// Get response channel from pool
+		respCh, err := channels.responsePool.GetChannel(r.Context())
+		if err != nil {
+			writeContextError(w, err)
+			return
+		}
+		defer channels.responsePool.ReturnChannel(respCh)
+		responseKey := respCh.key
+
+		channels.getEncounterCh <- RequestMessage{TenantID: tenantID, Entity: "Encounter", ID: id, ResponseKey: responseKey, IncludeSummary: true, Ctx: r.Context()}
+
+		// Wait for response from channel
+		select {
+		case response := <-respCh.ch:
+			if response.Error != nil {
+				if writeContextError(w, response.Error) {
+					return
+				}
+				if strings.Contains(response.Error.Error(), "not found") {
+					writeOperationOutcome(w, r, http.StatusNotFound, fhirutil.IssueCodeNotFound,
+						fmt.Sprintf("Encounter/%s not found", id))
+					return
+				}
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": response.Error.Error()})
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			writeJSON(w, http.StatusOK, response.Data)
+		case <-time.After(30 * time.Second):
+			http.Error(w, "Request timeout", http.StatusRequestTimeout)
+		}
+	} else {
+		// Tenant not warmed up
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error":   "Tenant not warmed up",
+			"message": "Please call /warm-up-tenant first",
+		})
+	}
+}
+
+// writeOperationOutcome writes a FHIR OperationOutcome error body,
+// as application/fhir+json when the client accepts it
+func writeOperationOutcome(w http.ResponseWriter, r *http.Request, status int, code, diagnostics string) {
+	w.Header().Set("Content-Type", fhirutil.NegotiateContentType(r.Header.Get("Accept")))
+	writeJSON(w, status, fhirutil.NewOperationOutcome(fhirutil.IssueSeverityError, code, diagnostics))
+}
+
 // ListResourcesHandler handles GET /{resource}
 func ListResourcesHandler(resourceType string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenantID, err := GetTenantFromRequest(r)
 		if err != nil {
-			log.Warn().
+			log.Ctx(r.Context()).Warn().
 				Err(err).
 				Str("method", r.Method).
 				Str("path", r.URL.Path).
 				Msg("Invalid tenant ID in request")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{
+			writeJSON(w, http.StatusBadRequest, map[string]string{
 				"error": err.Error(),
 			})
 			return
@@ -122,61 +269,143 @@ func ListResourcesHandler(resourceType string) http.HandlerFunc {
 		// Parse pagination parameters
 		countParam := r.URL.Query().Get("count")
 		pageParam := r.URL.Query().Get("page")
+		cursor := r.URL.Query().Get("cursor")
 
 		// Simple pagination validation (default values)
-		page := 1
+		// Without page, pages follow the cursor; page alone keeps the deprecated offset pagination
+		page := 0
 		count := 10
-		if pageParam != "" {
+		if pageParam != "" && cursor == "" {
+			log.Ctx(r.Context()).Warn().
+				Str("tenant", tenantID).
+				Str("path", r.URL.Path).
+				Msg("Offset pagination with page is deprecated, use cursor instead")
+			page = 1
 			if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
 				page = p
 			}
 		}
+		if _, err := dal.ParseCursor(cursor); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
 		if countParam != "" {
 			if c, err := strconv.Atoi(countParam); err == nil && c > 0 && c <= 100 {
 				count = c
 			}
 		}
 
+		// Parse encounter filters
+		var encounterFilter dal.EncounterFilter
+		if resourceType == "Encounter" {
+			encounterFilter = parseEncounterFilter(r)
+			if err := encounterFilter.Validate(); err != nil {
+				log.Ctx(r.Context()).Warn().
+					Err(err).
+					Str("tenant", tenantID).
+					Msg("Invalid encounter filter in request")
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			// The cursor only holds the document key, so sorted listings page by offset
+			if cursor != "" && len(encounterFilter.Sort) > 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cursor cannot be combined with _sort, use page"})
+				return
+			}
+		}
+
 		// Check if tenant is warmed up and send to channel
 		if channels, exists := GetTenantChannels(tenantID); exists {
-			// Get response channel from pool
-			respCh := channels.responsePool.GetChannel()
+			// Get response channel from pool; the rows of the page arrive one at a time before the response
+			respCh, err := channels.responsePool.GetChannel(r.Context())
+			if err != nil {
+				writeContextError(w, err)
+				return
+			}
+			defer channels.responsePool.ReturnChannel(respCh)
 			responseKey := respCh.key
+			rows := make(chan dal.QueryRow)
 
 			// Send request to appropriate channel
 			switch resourceType {
 			case "Encounter":
-				channels.listEncountersCh <- RequestMessage{tenantID, resourceType, "", responseKey, page, count}
+				channels.listEncountersCh <- RequestMessage{
+					TenantID:        tenantID,
+					Entity:          resourceType,
+					ResponseKey:     responseKey,
+					Page:            page,
+					Count:           count,
+					Cursor:          cursor,
+					EncounterFilter: encounterFilter,
+					Rows:            rows,
+					Ctx:             r.Context(),
+				}
 			case "Patient":
-				channels.listPatientsCh <- RequestMessage{tenantID, resourceType, "", responseKey, page, count}
+				channels.listPatientsCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ResponseKey: responseKey, Page: page, Count: count, Cursor: cursor, Rows: rows, Ctx: r.Context()}
 			case "Practitioner":
-				channels.listPractitionersCh <- RequestMessage{tenantID, resourceType, "", responseKey, page, count}
+				channels.listPractitionersCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ResponseKey: responseKey, Page: page, Count: count, Cursor: cursor, Rows: rows, Ctx: r.Context()}
+			case "Observation":
+				channels.listObservationsCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ResponseKey: responseKey, Page: page, Count: count, Cursor: cursor, Rows: rows, Ctx: r.Context()}
+			case "Condition":
+				channels.listConditionsCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ResponseKey: responseKey, Page: page, Count: count, Cursor: cursor, Rows: rows, Ctx: r.Context()}
+			case "MedicationRequest":
+				channels.listMedicationRequestsCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ResponseKey: responseKey, Page: page, Count: count, Cursor: cursor, Rows: rows, Ctx: r.Context()}
 			default:
-				channels.responsePool.ReturnChannel(respCh)
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(map[string]string{"error": "unsupported resource type"})
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported resource type"})
 				return
 			}
 
-			// Wait for response from channel
-			select {
-			case response := <-respCh.ch:
-				if response.Error != nil {
-					w.WriteHeader(http.StatusInternalServerError)
-					json.NewEncoder(w).Encode(map[string]string{"error": response.Error.Error()})
+			// Write each row as it arrives, then the pagination from the response
+			stream := &jsonListStream{w: w}
+			timeout := time.After(30 * time.Second)
+			for {
+				select {
+				case row := <-rows:
+					if err := stream.WriteElement(row); err != nil {
+						// Returning cancels the request context, which stops the listing
+						log.Ctx(r.Context()).Warn().
+							Err(err).
+							Str("tenant", tenantID).
+							Msg("Failed to stream resource, aborting list response")
+						return
+					}
+				case response := <-respCh.ch:
+					if response.Error != nil {
+						if stream.Started() {
+							// The status was sent with the first row, so the truncated body is the only failure signal
+							log.Ctx(r.Context()).Error().
+								Err(response.Error).
+								Str("tenant", tenantID).
+								Msg("Listing failed after the response was started")
+							return
+						}
+						if writeContextError(w, response.Error) {
+							return
+						}
+						writeJSON(w, http.StatusInternalServerError, map[string]string{"error": response.Error.Error()})
+						return
+					}
+					var pagination interface{}
+					if data, ok := response.Data.(map[string]interface{}); ok {
+						pagination = data["pagination"]
+					}
+					stream.Finish(pagination)
+					return
+				case <-timeout:
+					if stream.Started() {
+						log.Ctx(r.Context()).Error().
+							Str("tenant", tenantID).
+							Msg("Listing timed out after the response was started")
+						return
+					}
+					http.Error(w, "Request timeout", http.StatusRequestTimeout)
 					return
 				}
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusOK)
-				json.NewEncoder(w).Encode(response.Data)
-			case <-time.After(30 * time.Second):
-				http.Error(w, "Request timeout", http.StatusRequestTimeout)
 			}
 		} else {
 			// Tenant not warmed up
 			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusServiceUnavailable)
-			json.NewEncoder(w).Encode(map[string]string{
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{
 				"error":   "Tenant not warmed up",
 				"message": "Please call /warm-up-tenant first",
 			})
@@ -184,110 +413,834 @@ func ListResourcesHandler(resourceType string) http.HandlerFunc {
 	}
 }
 
+// parseEncounterFilter reads encounter filters from query parameters.
+// status accepts repeated parameters and comma-separated values.
+// date accepts repeated prefixed values, e.g. "date=ge2023-01-01&date=le2023-12-31" (prefix defaults to eq).
+// patient accepts a reference ("Patient/abc") or a bare patient ID.
+// _sort accepts comma-separated field:direction pairs, e.g. "date:desc,status:asc" (direction defaults to asc).
+func parseEncounterFilter(r *http.Request) dal.EncounterFilter {
+	var filter dal.EncounterFilter
+	for _, value := range r.URL.Query()["status"] {
+		for _, status := range strings.Split(value, ",") {
+			status = strings.ToLower(strings.TrimSpace(status))
+			if status != "" {
+				filter.Status = append(filter.Status, status)
+			}
+		}
+	}
+	for _, value := range r.URL.Query()["date"] {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		filter.Date = append(filter.Date, parseDateComparison(value))
+	}
+	if patient := strings.TrimSpace(r.URL.Query().Get("patient")); patient != "" {
+		filter.Patient = patient
+		if patientID, reason := fhirutil.ParseReference(patient, "Patient"); reason == fhirutil.ReferenceReasonOK {
+			filter.Patient = patientID
+		}
+	}
+	for _, value := range r.URL.Query()["_sort"] {
+		for _, pair := range strings.Split(value, ",") {
+			field, direction, _ := strings.Cut(strings.ToLower(strings.TrimSpace(pair)), ":")
+			if field == "" {
+				continue
+			}
+			if direction == "" {
+				direction = dal.SortAscending
+			}
+			filter.Sort = append(filter.Sort, dal.SortField{Field: field, Direction: direction})
+		}
+	}
+	return filter
+}
+
+// parseDateComparison splits a date search value into its prefix and date
+func parseDateComparison(value string) dal.DateComparison {
+	switch prefix := strings.ToLower(value[:min(2, len(value))]); prefix {
+	case dal.DatePrefixEqual, dal.DatePrefixGreater, dal.DatePrefixGreaterEqual, dal.DatePrefixLess, dal.DatePrefixLessEqual:
+		return dal.DateComparison{Prefix: prefix, Value: value[2:]}
+	}
+	return dal.DateComparison{Prefix: dal.DatePrefixEqual, Value: value}
+}
+
 // ReviewRequestHandler handles POST /review-request
 func ReviewRequestHandler(w http.ResponseWriter, r *http.Request) {
 	tenantID, err := GetTenantFromRequest(r)
 	if err != nil {
-		log.Warn().
+		log.Ctx(r.Context()).Warn().
 			Err(err).
 			Str("method", r.Method).
 			Str("path", r.URL.Path).
 			Msg("Invalid tenant ID in request")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
+		writeJSON(w, http.StatusBadRequest, map[string]string{
 			"error": err.Error(),
 		})
 		return
 	}
 
 	if r.Method != http.MethodPost {
-		log.Warn().
+		log.Ctx(r.Context()).Warn().
 			Str("method", r.Method).
 			Str("tenant", tenantID).
 			Msg("Method not allowed on review request endpoint")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 		return
 	}
 
 	var req ReviewRequest
 	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Error().
+		if isRequestBodyTooLarge(err) {
+			log.Ctx(r.Context()).Warn().
+				Str("tenant", tenantID).
+				Msg("Review request body too large")
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "request body too large"})
+			return
+		}
+		log.Ctx(r.Context()).Error().
 			Err(err).
 			Str("tenant", tenantID).
 			Msg("Failed to decode review request JSON")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid json"})
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
 		return
 	}
 
 	// Validate and normalize entity type
-	entity := strings.ToLower(strings.TrimSpace(req.Entity))
-	var resourceType string
-	switch entity {
-	case "encounter", "encounters":
-		resourceType = "Encounter"
-	case "patient", "patients":
-		resourceType = "Patient"
-	case "practitioner", "practitioners":
-		resourceType = "Practitioner"
-	default:
-		log.Warn().
+	resourceType, ok := reviewResourceType(req.Entity)
+	if !ok {
+		log.Ctx(r.Context()).Warn().
 			Str("entity", req.Entity).
 			Str("tenant", tenantID).
 			Msg("Invalid entity type in review request")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid entity"})
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid entity"})
 		return
 	}
 
 	if req.ID == "" {
-		log.Warn().
+		log.Ctx(r.Context()).Warn().
 			Str("tenant", tenantID).
 			Str("resourceType", resourceType).
 			Msg("Missing ID in review request")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "missing id"})
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing id"})
 		return
 	}
 
+	severity := strings.ToLower(strings.TrimSpace(req.Severity))
+	if !dal.IsValidReviewSeverity(severity) {
+		log.Ctx(r.Context()).Warn().
+			Str("severity", req.Severity).
+			Str("tenant", tenantID).
+			Msg("Invalid severity in review request")
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid severity"})
+		return
+	}
+
+	// With If-Match the review only applies to the version of the resource the reviewer read
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" {
+		if _, err := dal.ParseETag(ifMatch); err != nil {
+			log.Ctx(r.Context()).Warn().
+				Str("ifMatch", ifMatch).
+				Str("tenant", tenantID).
+				Msg("Invalid If-Match header in review request")
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid If-Match header"})
+			return
+		}
+	}
+
 	// Check if tenant is warmed up and send to channel
 	if channels, exists := GetTenantChannels(tenantID); exists {
 		// Get response channel from pool
-		respCh := channels.responsePool.GetChannel()
+		respCh, err := channels.responsePool.GetChannel(r.Context())
+		if err != nil {
+			writeContextError(w, err)
+			return
+		}
+		defer channels.responsePool.ReturnChannel(respCh)
 		responseKey := respCh.key
 
 		// Send request to review channel with concatenated entity/ID
 		entityID := resourceType + "/" + req.ID
-		channels.reviewCh <- RequestMessage{tenantID, resourceType, entityID, responseKey, 0, 0}
+		channels.reviewCh <- RequestMessage{
+			TenantID:    tenantID,
+			Entity:      resourceType,
+			ID:          entityID,
+			ResponseKey: responseKey,
+			Notes:       req.Notes,
+			Severity:    severity,
+			Reviewer:    GetUsernameFromContext(r.Context()),
+			IfMatch:     ifMatch,
+			Ctx:         r.Context(),
+		}
 
 		// Wait for response from channel
 		select {
 		case response := <-respCh.ch:
 			if response.Error != nil {
+				if writeContextError(w, response.Error) {
+					return
+				}
+				if errors.Is(response.Error, dal.ErrReviewConflict) {
+					writeJSON(w, http.StatusConflict, map[string]string{"error": dal.ErrReviewConflict.Error()})
+					return
+				}
 				if strings.Contains(response.Error.Error(), "not found") {
-					w.WriteHeader(http.StatusNotFound)
-					json.NewEncoder(w).Encode(map[string]string{"error": "resource not found"})
+					writeJSON(w, http.StatusNotFound, map[string]string{"error": "resource not found"})
 					return
 				}
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(map[string]string{"error": response.Error.Error()})
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": response.Error.Error()})
 				return
 			}
 			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(response.Data)
+			writeJSON(w, http.StatusOK, response.Data)
 		case <-time.After(30 * time.Second):
 			http.Error(w, "Request timeout", http.StatusRequestTimeout)
 		}
 	} else {
 		// Tenant not warmed up
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]string{
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{
 			"error":   "Tenant not warmed up",
 			"message": "Please call /warm-up-tenant first",
 		})
 	}
 }
+
+// reviewResourceType normalizes the entity of a review request body to its FHIR resource type
+func reviewResourceType(entity string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(entity)) {
+	case "encounter", "encounters":
+		return "Encounter", true
+	case "patient", "patients":
+		return "Patient", true
+	case "practitioner", "practitioners":
+		return "Practitioner", true
+	default:
+		return "", false
+	}
+}
+
+// maxBulkReviewItems is the largest batch accepted by BulkReviewRequestHandler
+const maxBulkReviewItems = 100
+
+// BulkReviewRequestHandler handles POST /bulk-review-request.
+// Entries are reviewed one by one over a single connection; the response is 200 when all succeed,
+// 207 Multi-Status on partial success and 422 when all fail.
+func BulkReviewRequestHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := GetTenantFromRequest(r)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().
+			Err(err).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Msg("Invalid tenant ID in request")
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var req BulkReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if isRequestBodyTooLarge(err) {
+			log.Ctx(r.Context()).Warn().
+				Str("tenant", tenantID).
+				Msg("Bulk review request body too large")
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "request body too large"})
+			return
+		}
+		log.Ctx(r.Context()).Error().
+			Err(err).
+			Str("tenant", tenantID).
+			Msg("Failed to decode bulk review request JSON")
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+
+	if len(req.Reviews) == 0 || len(req.Reviews) > maxBulkReviewItems {
+		log.Ctx(r.Context()).Warn().
+			Str("tenant", tenantID).
+			Int("reviews", len(req.Reviews)).
+			Msg("Invalid bulk review batch size")
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("reviews must contain between 1 and %d entries", maxBulkReviewItems),
+		})
+		return
+	}
+
+	// Invalid entries fail on their own instead of rejecting the batch
+	items, failed := validateBulkReviews(req.Reviews)
+	reviewer := GetUsernameFromContext(r.Context())
+	for i := range items {
+		items[i].Details.ReviewerUsername = reviewer
+	}
+
+	response := &BulkReviewResponse{Succeeded: []string{}, Failed: failed}
+	if len(items) > 0 {
+		channels, exists := GetTenantChannels(tenantID)
+		if !exists {
+			// Tenant not warmed up
+			w.Header().Set("Content-Type", "application/json")
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+				"error":   "Tenant not warmed up",
+				"message": "Please call /warm-up-tenant first",
+			})
+			return
+		}
+
+		// Get response channel from pool
+		respCh, err := channels.responsePool.GetChannel(r.Context())
+		if err != nil {
+			writeContextError(w, err)
+			return
+		}
+		defer channels.responsePool.ReturnChannel(respCh)
+		channels.bulkReviewCh <- RequestMessage{
+			TenantID:    tenantID,
+			ResponseKey: respCh.key,
+			Reviews:     items,
+			Ctx:         r.Context(),
+		}
+
+		// Wait for response from channel
+		select {
+		case channelResponse := <-respCh.ch:
+			if channelResponse.Error != nil {
+				if writeContextError(w, channelResponse.Error) {
+					return
+				}
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": channelResponse.Error.Error()})
+				return
+			}
+			processed := channelResponse.Data.(*BulkReviewResponse)
+			response.Succeeded = processed.Succeeded
+			response.Failed = append(response.Failed, processed.Failed...)
+		case <-time.After(30 * time.Second):
+			http.Error(w, "Request timeout", http.StatusRequestTimeout)
+			return
+		}
+	}
+
+	status := http.StatusOK
+	switch {
+	case len(response.Succeeded) == 0:
+		status = http.StatusUnprocessableEntity
+	case len(response.Failed) > 0:
+		status = http.StatusMultiStatus
+	}
+
+	log.Ctx(r.Context()).Info().
+		Str("tenant", tenantID).
+		Int("succeeded", len(response.Succeeded)).
+		Int("failed", len(response.Failed)).
+		Msg("Bulk review request processed")
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, status, response)
+}
+
+// validateBulkReviews validates each entry like ReviewRequestHandler, returning the valid items and the failures
+func validateBulkReviews(reviews []ReviewRequest) ([]BulkReviewItem, []BulkReviewFailure) {
+	items := make([]BulkReviewItem, 0, len(reviews))
+	failed := []BulkReviewFailure{}
+
+	for _, review := range reviews {
+		resourceType, ok := reviewResourceType(review.Entity)
+		if !ok {
+			failed = append(failed, BulkReviewFailure{Entity: review.Entity, ID: review.ID, Error: "invalid entity"})
+			continue
+		}
+		if review.ID == "" {
+			failed = append(failed, BulkReviewFailure{Entity: resourceType, Error: "missing id"})
+			continue
+		}
+		severity := strings.ToLower(strings.TrimSpace(review.Severity))
+		if !dal.IsValidReviewSeverity(severity) {
+			failed = append(failed, BulkReviewFailure{Entity: resourceType, ID: review.ID, Error: "invalid severity"})
+			continue
+		}
+
+		items = append(items, BulkReviewItem{
+			ResourceType: resourceType,
+			ID:           review.ID,
+			Details:      dal.ReviewDetails{Notes: review.Notes, Severity: severity},
+		})
+	}
+
+	return items, failed
+}
+
+// DeleteReviewRequestHandler handles DELETE /review-request
+func DeleteReviewRequestHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := GetTenantFromRequest(r)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().
+			Err(err).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Msg("Invalid tenant ID in request")
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var req ReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if isRequestBodyTooLarge(err) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "request body too large"})
+			return
+		}
+		log.Ctx(r.Context()).Error().
+			Err(err).
+			Str("tenant", tenantID).
+			Msg("Failed to decode review delete JSON")
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+
+	resourceType, ok := reviewResourceType(req.Entity)
+	if !ok {
+		log.Ctx(r.Context()).Warn().
+			Str("entity", req.Entity).
+			Str("tenant", tenantID).
+			Msg("Invalid entity type in review delete")
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid entity"})
+		return
+	}
+
+	if req.ID == "" {
+		log.Ctx(r.Context()).Warn().
+			Str("tenant", tenantID).
+			Str("resourceType", resourceType).
+			Msg("Missing ID in review delete")
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing id"})
+		return
+	}
+
+	// Check if tenant is warmed up and send to channel
+	if channels, exists := GetTenantChannels(tenantID); exists {
+		// Get response channel from pool
+		respCh, err := channels.responsePool.GetChannel(r.Context())
+		if err != nil {
+			writeContextError(w, err)
+			return
+		}
+		defer channels.responsePool.ReturnChannel(respCh)
+		responseKey := respCh.key
+
+		channels.reviewDeleteCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ID: req.ID, ResponseKey: responseKey, Ctx: r.Context()}
+
+		// Wait for response from channel
+		select {
+		case response := <-respCh.ch:
+			if response.Error != nil {
+				if writeContextError(w, response.Error) {
+					return
+				}
+				if errors.Is(response.Error, dal.ErrNotReviewed) {
+					writeJSON(w, http.StatusNotFound, map[string]string{"error": "resource not reviewed"})
+					return
+				}
+				if strings.Contains(response.Error.Error(), "not found") {
+					writeJSON(w, http.StatusNotFound, map[string]string{"error": "resource not found"})
+					return
+				}
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": response.Error.Error()})
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			writeJSON(w, http.StatusOK, response.Data)
+		case <-time.After(30 * time.Second):
+			http.Error(w, "Request timeout", http.StatusRequestTimeout)
+		}
+	} else {
+		// Tenant not warmed up
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error":   "Tenant not warmed up",
+			"message": "Please call /warm-up-tenant first",
+		})
+	}
+}
+
+// ReviewStatusHandler handles GET /{resource}/{id}/review-status
+func ReviewStatusHandler(resourceType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenantID, err := GetTenantFromRequest(r)
+		if err != nil {
+			log.Ctx(r.Context()).Warn().
+				Err(err).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Msg("Invalid tenant ID in request")
+			writeJSON(w, http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		id := mux.Vars(r)["id"]
+		if id == "" {
+			log.Ctx(r.Context()).Warn().
+				Str("tenant", tenantID).
+				Str("resourceType", resourceType).
+				Msg("Missing resource ID in review status request")
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing id"})
+			return
+		}
+
+		// Check if tenant is warmed up and send to channel
+		if channels, exists := GetTenantChannels(tenantID); exists {
+			// Get response channel from pool
+			respCh, err := channels.responsePool.GetChannel(r.Context())
+			if err != nil {
+				writeContextError(w, err)
+				return
+			}
+			defer channels.responsePool.ReturnChannel(respCh)
+			responseKey := respCh.key
+
+			channels.reviewStatusCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ID: id, ResponseKey: responseKey, Ctx: r.Context()}
+
+			// Wait for response from channel
+			select {
+			case response := <-respCh.ch:
+				if response.Error != nil {
+					if writeContextError(w, response.Error) {
+						return
+					}
+					if strings.Contains(response.Error.Error(), "not found") {
+						writeJSON(w, http.StatusNotFound, map[string]string{"error": "resource not found"})
+						return
+					}
+					writeJSON(w, http.StatusInternalServerError, map[string]string{"error": response.Error.Error()})
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				writeJSON(w, http.StatusOK, response.Data)
+			case <-time.After(30 * time.Second):
+				http.Error(w, "Request timeout", http.StatusRequestTimeout)
+			}
+		} else {
+			// Tenant not warmed up
+			w.Header().Set("Content-Type", "application/json")
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+				"error":   "Tenant not warmed up",
+				"message": "Please call /warm-up-tenant first",
+			})
+		}
+	}
+}
+
+// ReviewHistoryHandler handles GET /{resource}/{id}/review-history, returning the reviews of a resource oldest first
+func ReviewHistoryHandler(resourceType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenantID, err := GetTenantFromRequest(r)
+		if err != nil {
+			log.Ctx(r.Context()).Warn().
+				Err(err).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Msg("Invalid tenant ID in request")
+			writeJSON(w, http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		id := mux.Vars(r)["id"]
+		if id == "" {
+			log.Ctx(r.Context()).Warn().
+				Str("tenant", tenantID).
+				Str("resourceType", resourceType).
+				Msg("Missing resource ID in review history request")
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing id"})
+			return
+		}
+
+		// Check if tenant is warmed up and send to channel
+		if channels, exists := GetTenantChannels(tenantID); exists {
+			// Get response channel from pool
+			respCh, err := channels.responsePool.GetChannel(r.Context())
+			if err != nil {
+				writeContextError(w, err)
+				return
+			}
+			defer channels.responsePool.ReturnChannel(respCh)
+			responseKey := respCh.key
+
+			channels.reviewHistoryCh <- RequestMessage{TenantID: tenantID, Entity: resourceType, ID: id, ResponseKey: responseKey, Ctx: r.Context()}
+
+			// Wait for response from channel
+			select {
+			case response := <-respCh.ch:
+				if response.Error != nil {
+					if writeContextError(w, response.Error) {
+						return
+					}
+					if strings.Contains(response.Error.Error(), "not found") {
+						writeJSON(w, http.StatusNotFound, map[string]string{"error": "resource not found"})
+						return
+					}
+					writeJSON(w, http.StatusInternalServerError, map[string]string{"error": response.Error.Error()})
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				writeJSON(w, http.StatusOK, response.Data)
+			case <-time.After(30 * time.Second):
+				http.Error(w, "Request timeout", http.StatusRequestTimeout)
+			}
+		} else {
+			// Tenant not warmed up
+			w.Header().Set("Content-Type", "application/json")
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+				"error":   "Tenant not warmed up",
+				"message": "Please call /warm-up-tenant first",
+			})
+		}
+	}
+}
+
+// IngestionStatusHandler handles GET /api/{tenant}/ingestion-status
+// It reads the DAL directly, so the tenant does not need to be warm
+func IngestionStatusHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := GetTenantFromRequest(r)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().
+			Err(err).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Msg("Invalid tenant ID in request")
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	status, err := getTenantIngestionStatus(r.Context(), tenantID)
+	if err != nil {
+		log.Ctx(r.Context()).Error().
+			Err(err).
+			Str("tenant", tenantID).
+			Msg("Failed to get tenant ingestion status")
+		if writeContextError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, status)
+}
+
+// ReviewSummaryHandler handles GET /api/{tenant}/review-summary
+// It reads the DAL directly, so the dashboard KPI is not queued behind tenant channel requests
+func ReviewSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := GetTenantFromRequest(r)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().
+			Err(err).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Msg("Invalid tenant ID in request")
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	summary, err := getReviewSummary(r.Context(), tenantID)
+	if err != nil {
+		log.Ctx(r.Context()).Error().
+			Err(err).
+			Str("tenant", tenantID).
+			Msg("Failed to get review summary")
+		if writeContextError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// warmUpTimeout bounds a blocking or background tenant warm-up, which may copy the whole DefaultScope
+var warmUpTimeout = 10 * time.Minute
+
+// WarmUpTenantHandler handles POST /warm-up-tenant
+// With ?async=true the warm-up runs in the background and the response points to the ingestion status
+func WarmUpTenantHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := GetTenantFromRequest(r)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().
+			Err(err).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Msg("Invalid tenant ID in request")
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+	if async {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), warmUpTimeout)
+			defer cancel()
+			if err := recordedWarmUpTenant(ctx, tenantID); err != nil {
+				log.Ctx(r.Context()).Error().
+					Err(err).
+					Str("tenant", tenantID).
+					Msg("Background tenant warm-up failed")
+			}
+		}()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "30")
+		writeJSON(w, http.StatusAccepted, map[string]string{
+			"status":  "warming",
+			"checkAt": fmt.Sprintf("/api/%s/ingestion-status", tenantID),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), warmUpTimeout)
+	defer cancel()
+	if err := recordedWarmUpTenant(ctx, tenantID); err != nil {
+		log.Ctx(r.Context()).Error().
+			Err(err).
+			Str("tenant", tenantID).
+			Msg("Tenant warm-up failed")
+		if writeContextError(w, err) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, http.StatusInternalServerError, map[string]string{
+			"error":   "Failed to warm up tenant",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "warm",
+		"tenant": tenantID,
+	})
+}
+
+// IngestManifestsHandler handles GET /api/ingest-manifests (admin only)
+// It returns the most recent fhir-client ingestion run manifests, newest first
+func IngestManifestsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	manifests, err := listIngestManifests(r.Context(), limit)
+	if err != nil {
+		log.Ctx(r.Context()).Error().
+			Err(err).
+			Int("limit", limit).
+			Msg("Failed to list ingest manifests")
+		if writeContextError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": manifests,
+	})
+}
+
+// filterResourceElements keeps only the requested _elements of the resource returned under "data"
+func filterResourceElements(data interface{}, elements []string) {
+	body, ok := data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if doc, ok := body["data"].(map[string]interface{}); ok {
+		body["data"] = fhirutil.FilterElements(doc, elements)
+	}
+}
+
+// statusClientClosedRequest is the de-facto status of a request the client closed before the response
+const statusClientClosedRequest = 499
+
+// writeContextError writes 499 when the client cancelled the request and 504 when a deadline expired;
+// it reports whether err was a context error and a response was written
+func writeContextError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, context.Canceled):
+		writeJSON(w, statusClientClosedRequest, map[string]string{"error": "request cancelled"})
+		return true
+	case errors.Is(err, context.DeadlineExceeded):
+		writeJSON(w, http.StatusGatewayTimeout, map[string]string{"error": "request timed out"})
+		return true
+	}
+	return false
+}
+
+// DeleteTenantHandler handles DELETE /api/{tenant}
+// It offboards the tenant by removing its scope and data and its channels; deleting an already removed tenant succeeds.
+// Later requests of the tenant get 410 Gone until an admin warm-up restores it.
+func DeleteTenantHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := GetTenantFromRequest(r)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().
+			Err(err).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Msg("Invalid tenant ID in request")
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := deleteTenantScope(r.Context(), tenantID); err != nil {
+		if errors.Is(err, dal.ErrProtectedScope) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		log.Ctx(r.Context()).Error().
+			Err(err).
+			Str("tenant", tenantID).
+			Msg("Tenant deletion failed")
+		if writeContextError(w, err) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, http.StatusInternalServerError, map[string]string{
+			"error":   "Failed to delete tenant",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// The next request of the tenant is refused instead of re-creating the scope from DefaultScope,
+	// and must not reach the channels still pointing at the dropped collections
+	markTenantDeleted(tenantID)
+	InvalidateTenantScopeCheck(tenantID)
+	CleanupTenantChannels(tenantID)
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "deleted",
+		"tenant": tenantID,
+	})
+}
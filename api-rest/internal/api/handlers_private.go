@@ -5,15 +5,16 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/rs/zerolog/log"
 	"stealthcompany.com/api-rest/internal/dal"
 )
 
-// getResourceByID retrieves a single resource by ID (private function for channel processing)
-func getResourceByID(ctx context.Context, tenantID, resourceType, id string) (map[string]interface{}, error) {
+// getResourceByID retrieves a single resource by ID and its ETag (private function for channel processing)
+func getResourceByID(ctx context.Context, tenantID, resourceType, id string) (map[string]interface{}, string, error) {
 	// Get connection
-	conn, err := dal.GetConnectionWithRetry()
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get connection: %w", err)
+		return nil, "", fmt.Errorf("failed to get connection: %w", err)
 	}
 	defer dal.ReturnConnection(conn)
 
@@ -21,21 +22,70 @@ func getResourceByID(ctx context.Context, tenantID, resourceType, id string) (ma
 	resourceModel := dal.NewResourceModel(conn)
 
 	// Get the resource
-	doc, err := resourceModel.GetResource(ctx, resourceType+"/"+id)
+	resource, err := resourceModel.GetCASedResource(ctx, resourceType+"/"+id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve resource: %w", err)
+		return nil, "", fmt.Errorf("failed to retrieve resource: %w", err)
 	}
 
 	// Review fields are already embedded in the document from fhir-client ingestion
 	return map[string]interface{}{
-		"data": doc,
-	}, nil
+		"data": resource.Data,
+	}, resource.ETag(), nil
+}
+
+// getPatientSummary retrieves the linked resource counts of a patient (private function for channel processing)
+func getPatientSummary(ctx context.Context, tenantID, id string) (*dal.PatientSummary, error) {
+	// Get connection
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	patientModel := dal.NewPatientModel(dal.NewResourceModel(conn))
+	summary, err := patientModel.GetSummary(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get patient summary: %w", err)
+	}
+	return summary, nil
+}
+
+// getEncounterSummary retrieves an encounter with its patient and practitioners, reading all of them
+// over the same connection (private function for channel processing)
+func getEncounterSummary(ctx context.Context, tenantID, id string) (*dal.EncounterSummary, error) {
+	// Get connection
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	encounterModel := dal.NewEncounterModel(dal.NewResourceModel(conn))
+	summary, err := encounterModel.GetSummary(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encounter summary: %w", err)
+	}
+	return summary, nil
+}
+
+// getPractitionerActiveEncounterCount counts the in-progress encounters of a practitioner (private function for channel processing)
+func getPractitionerActiveEncounterCount(ctx context.Context, tenantID, id string) (int64, error) {
+	// Get connection
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	practitionerModel := dal.NewPractitionerModel(dal.NewResourceModel(conn))
+	return practitionerModel.CountActiveEncounters(ctx, id)
 }
 
-// listResources retrieves a list of resources (private function for channel processing)
-func listResources(ctx context.Context, tenantID, resourceType string, page, count int) (map[string]interface{}, error) {
+// streamResources sends the resources of a page to rows one at a time and returns the pagination of the page
+// (private function for channel processing)
+func streamResources(ctx context.Context, tenantID, resourceType string, page, count int, cursor string, encounterFilter dal.EncounterFilter, rows chan<- dal.QueryRow) (map[string]interface{}, error) {
 	// Get connection
-	conn, err := dal.GetConnectionWithRetry()
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get connection: %w", err)
 	}
@@ -44,40 +94,45 @@ func listResources(ctx context.Context, tenantID, resourceType string, page, cou
 	// Create resource model
 	resourceModel := dal.NewResourceModel(conn)
 
-	// Get resources using the appropriate model
-	var paginatedResponse *dal.PaginatedResponse
-	var listErr error
+	// Each row is handed over before the next one is read, so the handler writes it while the query goes on
+	var streamed int
+	var lastID string
+	send := func(row dal.QueryRow) error {
+		select {
+		case rows <- row:
+			streamed++
+			lastID = row.ID
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 
+	params := dal.PaginationParams{Page: page, Count: count, AfterCursor: cursor}
+	var streamErr error
 	switch resourceType {
 	case "Encounter":
-		encounterModel := dal.NewEncounterModel(resourceModel)
-		paginatedResponse, listErr = encounterModel.List(ctx, page, count)
-	case "Patient":
-		patientModel := dal.NewPatientModel(resourceModel)
-		paginatedResponse, listErr = patientModel.List(ctx, page, count)
-	case "Practitioner":
-		practitionerModel := dal.NewPractitionerModel(resourceModel)
-		paginatedResponse, listErr = practitionerModel.List(ctx, page, count)
+		params.OrderBy = encounterFilter.OrderBy()
+		streamErr = dal.NewEncounterModel(resourceModel).StreamWithFilter(ctx, params, encounterFilter, send)
+	case "Patient", "Practitioner", "Observation", "Condition", "MedicationRequest":
+		streamErr = resourceModel.StreamListResources(ctx, resourceType, params, send)
 	default:
 		return nil, fmt.Errorf("unsupported resource type: %s", resourceType)
 	}
-	if listErr != nil {
-		return nil, fmt.Errorf("failed to list resources: %w", listErr)
+	if streamErr != nil {
+		return nil, fmt.Errorf("failed to list resources: %w", streamErr)
 	}
 
 	// Review fields are already embedded in the documents from fhir-client ingestion
-	// No need to fetch review info separately
-
 	return map[string]interface{}{
-		"data":       paginatedResponse.Data,
-		"pagination": paginatedResponse.Pagination,
+		"pagination": dal.StreamedPagination(params, streamed, lastID),
 	}, nil
 }
 
 // processReviewRequest processes a review request (private function for channel processing)
-func processReviewRequest(ctx context.Context, tenantID, resourceType, entityID string) (map[string]interface{}, error) {
+func processReviewRequest(ctx context.Context, tenantID, resourceType, entityID string, details dal.ReviewDetails) (map[string]interface{}, error) {
 	// Get connection
-	conn, err := dal.GetConnectionWithRetry()
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get connection: %w", err)
 	}
@@ -85,7 +140,7 @@ func processReviewRequest(ctx context.Context, tenantID, resourceType, entityID
 
 	// Create resource model
 	resourceModel := dal.NewResourceModel(conn)
-	reviewModel := dal.NewReviewModel(resourceModel)
+	reviewModel := dal.NewReviewModel(resourceModel, conn.GetCluster().Transactions())
 
 	// entityID is in format "ResourceType/ID", extract just the ID part
 	resourceID := entityID
@@ -94,7 +149,7 @@ func processReviewRequest(ctx context.Context, tenantID, resourceType, entityID
 		resourceID = parts[len(parts)-1] // Get the last part (the actual ID)
 	}
 
-	err = reviewModel.CreateReviewRequest(ctx, tenantID, resourceType, resourceID)
+	err = reviewModel.CreateReviewRequest(ctx, tenantID, resourceType, resourceID, details)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create review request: %w", err)
 	}
@@ -107,10 +162,270 @@ func processReviewRequest(ctx context.Context, tenantID, resourceType, entityID
 		"reviewed": "true",
 	}
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"status":   response["status"],
 		"tenant":   response["tenant"],
 		"entity":   response["entity"],
 		"reviewed": response["reviewed"],
+	}
+	if details.Notes != "" {
+		result["notes"] = details.Notes
+	}
+	if details.Severity != "" {
+		result["severity"] = details.Severity
+	}
+
+	return result, nil
+}
+
+// processBulkReviewRequest reviews each item over a single connection, collecting per-item failures
+// (private function for channel processing). It stops with the context error once ctx is cancelled.
+func processBulkReviewRequest(ctx context.Context, tenantID string, items []BulkReviewItem) (*BulkReviewResponse, error) {
+	// Get connection
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	reviewModel := dal.NewReviewModel(dal.NewResourceModel(conn), conn.GetCluster().Transactions())
+
+	response := &BulkReviewResponse{Succeeded: []string{}, Failed: []BulkReviewFailure{}}
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		entityID := item.ResourceType + "/" + item.ID
+		if err := reviewModel.CreateReviewRequest(ctx, tenantID, item.ResourceType, item.ID, item.Details); err != nil {
+			log.Ctx(ctx).Warn().
+				Err(err).
+				Str("tenant", tenantID).
+				Str("entity", entityID).
+				Msg("Bulk review item failed")
+			response.Failed = append(response.Failed, BulkReviewFailure{Entity: item.ResourceType, ID: item.ID, Error: err.Error()})
+			continue
+		}
+		response.Succeeded = append(response.Succeeded, entityID)
+	}
+
+	return response, nil
+}
+
+// deleteReviewRequest removes the review of a resource (private function for channel processing)
+func deleteReviewRequest(ctx context.Context, tenantID, resourceType, id string) (map[string]interface{}, error) {
+	// Get connection
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	resourceModel := dal.NewResourceModel(conn)
+	reviewModel := dal.NewReviewModel(resourceModel, conn.GetCluster().Transactions())
+
+	if err := reviewModel.DeleteReviewRequest(ctx, tenantID, resourceType, id); err != nil {
+		return nil, fmt.Errorf("failed to delete review request: %w", err)
+	}
+
+	return map[string]interface{}{
+		"status": "review removed",
+		"entity": resourceType + "/" + id,
+	}, nil
+}
+
+// getReviewHistory retrieves the review audit trail of a resource (private function for channel processing)
+func getReviewHistory(ctx context.Context, tenantID, resourceType, id string) (*ReviewHistoryResponse, error) {
+	// Get connection
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	reviewModel := dal.NewReviewModel(dal.NewResourceModel(conn), conn.GetCluster().Transactions())
+
+	// GetReviewHistory fails with "resource not found" when the document does not exist
+	history, err := reviewModel.GetReviewHistory(ctx, resourceType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Debug().
+		Str("tenant", tenantID).
+		Str("resourceType", resourceType).
+		Str("id", id).
+		Int("events", len(history)).
+		Msg("Review history retrieved")
+
+	return &ReviewHistoryResponse{
+		EntityType: resourceType,
+		EntityID:   id,
+		History:    history,
 	}, nil
 }
+
+// getReviewStatus retrieves only the review fields of a resource (private function for channel processing)
+func getReviewStatus(ctx context.Context, tenantID, resourceType, id string) (*ReviewStatusResponse, error) {
+	// Get connection
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	// Create resource model
+	resourceModel := dal.NewResourceModel(conn)
+	reviewModel := dal.NewReviewModel(resourceModel, conn.GetCluster().Transactions())
+
+	exists, err := resourceModel.ResourceExists(ctx, resourceType+"/"+id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check resource: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("resource not found")
+	}
+
+	reviewInfo, err := reviewModel.GetReviewInfo(ctx, tenantID, resourceType, id)
+	if err != nil {
+		// Report the status as unavailable instead of un-reviewed
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("tenant", tenantID).
+			Str("resourceType", resourceType).
+			Str("id", id).
+			Msg("Failed to get review info")
+		return &ReviewStatusResponse{
+			ReviewError: true,
+			EntityType:  resourceType,
+			EntityID:    id,
+		}, nil
+	}
+
+	response := &ReviewStatusResponse{
+		Reviewed:   reviewInfo.Reviewed,
+		ReviewTime: reviewInfo.ReviewTime,
+		Notes:      reviewInfo.Notes,
+		Severity:   reviewInfo.Severity,
+		EntityType: resourceType,
+		EntityID:   id,
+	}
+
+	if resourceType == "Practitioner" {
+		count, err := dal.NewPractitionerModel(resourceModel).CountActiveEncounters(ctx, id)
+		if err != nil {
+			// The count only helps prioritize, so the review status is still returned
+			log.Ctx(ctx).Warn().
+				Err(err).
+				Str("tenant", tenantID).
+				Str("id", id).
+				Msg("Failed to count practitioner active encounters")
+		} else {
+			response.CurrentEncounterCount = &count
+		}
+	}
+
+	return response, nil
+}
+
+// getTenantIngestionStatus retrieves the tenant scope ingestion status directly from the DAL,
+// without going through tenant channels
+var getTenantIngestionStatus = func(ctx context.Context, tenantID string) (*dal.IngestionStatus, error) {
+	// Get connection
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	ingestionStatusModel := dal.NewIngestionStatusModel(conn)
+	return ingestionStatusModel.GetTenantScopeIngestionStatus(ctx, tenantID)
+}
+
+// getReviewSummary retrieves the per-type review counts directly from the DAL,
+// without going through tenant channels
+var getReviewSummary = func(ctx context.Context, tenantID string) (*dal.ReviewSummary, error) {
+	// Get connection
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	reviewModel := dal.NewReviewModel(dal.NewResourceModel(conn), conn.GetCluster().Transactions())
+	return reviewModel.GetReviewSummary(ctx)
+}
+
+// listIngestManifests retrieves the most recent ingestion run manifests directly from the DAL
+var listIngestManifests = func(ctx context.Context, limit int) ([]dal.IngestManifest, error) {
+	// Get connection
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	manifestModel := dal.NewManifestModel(conn)
+	return manifestModel.ListManifests(ctx, limit)
+}
+
+// deleteTenantScope removes the tenant scope and its data directly from the DAL,
+// without going through tenant channels
+var deleteTenantScope = func(ctx context.Context, tenantID string) error {
+	// Get connection
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	scopeModel := dal.NewScopeModel(conn)
+	return scopeModel.DeleteTenantScope(ctx, tenantID)
+}
+
+// restoreTenant removes the tombstone of a deleted tenant so its scope can be created again; overridable in tests
+var restoreTenant = func(ctx context.Context, tenantID string) error {
+	// Get connection
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	return dal.NewScopeModel(conn).RestoreTenant(ctx, tenantID)
+}
+
+// exportPageSize is the number of resources read per query by exportResources
+const exportPageSize = 1000
+
+// exportResources calls fn for every resource of resourceType in the tenant scope, reading the collection
+// page after page by cursor so only one row at a time is held in memory
+var exportResources = func(ctx context.Context, tenantID, resourceType string, fn func(dal.QueryRow) error) error {
+	// Get connection
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	resourceModel := dal.NewResourceModelWithTenant(conn, tenantID)
+	cursor := ""
+	for {
+		var streamed int
+		var lastID string
+		params := dal.PaginationParams{Count: exportPageSize, AfterCursor: cursor}
+		err := resourceModel.StreamListResources(ctx, resourceType, params, func(row dal.QueryRow) error {
+			streamed++
+			lastID = row.ID
+			return fn(row)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export %s resources: %w", resourceType, err)
+		}
+		// Rows that fail to decode are skipped, so only an empty page proves the collection was read to the end
+		if streamed == 0 {
+			return nil
+		}
+		cursor = dal.FormatCursor(lastID)
+	}
+}
@@ -0,0 +1,1990 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/gorilla/mux"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"stealthcompany.com/api-rest/internal/dal"
+	"stealthcompany.com/api-rest/internal/metrics"
+)
+
+// registerTestTenant registers warm tenant channels whose encounter, observation, condition, medication request and review requests are answered by respond
+func registerTestTenant(t *testing.T, tenantID string, respond func(RequestMessage) ResponseMessage) *TenantChannels {
+	t.Helper()
+
+	channels := &TenantChannels{
+		getEncounterCh:           make(chan RequestMessage),
+		listEncountersCh:         make(chan RequestMessage),
+		getObservationCh:         make(chan RequestMessage),
+		listObservationsCh:       make(chan RequestMessage),
+		getConditionCh:           make(chan RequestMessage),
+		listConditionsCh:         make(chan RequestMessage),
+		getMedicationRequestCh:   make(chan RequestMessage),
+		listMedicationRequestsCh: make(chan RequestMessage),
+		reviewCh:                 make(chan RequestMessage),
+		reviewStatusCh:           make(chan RequestMessage),
+		reviewHistoryCh:          make(chan RequestMessage),
+		reviewDeleteCh:           make(chan RequestMessage),
+		bulkReviewCh:             make(chan RequestMessage),
+		responsePool:             NewResponsePool(1),
+	}
+	tenantChannelManager.channels.Store(tenantID, channels)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case msg := <-channels.getEncounterCh:
+				channels.sendResponse(msg.ResponseKey, respond(msg))
+			case msg := <-channels.listEncountersCh:
+				channels.sendResponse(msg.ResponseKey, respond(msg))
+			case msg := <-channels.getObservationCh:
+				channels.sendResponse(msg.ResponseKey, respond(msg))
+			case msg := <-channels.listObservationsCh:
+				channels.sendResponse(msg.ResponseKey, respond(msg))
+			case msg := <-channels.getConditionCh:
+				channels.sendResponse(msg.ResponseKey, respond(msg))
+			case msg := <-channels.listConditionsCh:
+				channels.sendResponse(msg.ResponseKey, respond(msg))
+			case msg := <-channels.getMedicationRequestCh:
+				channels.sendResponse(msg.ResponseKey, respond(msg))
+			case msg := <-channels.listMedicationRequestsCh:
+				channels.sendResponse(msg.ResponseKey, respond(msg))
+			case msg := <-channels.reviewCh:
+				channels.sendResponse(msg.ResponseKey, respond(msg))
+			case msg := <-channels.reviewStatusCh:
+				channels.sendResponse(msg.ResponseKey, respond(msg))
+			case msg := <-channels.reviewHistoryCh:
+				channels.sendResponse(msg.ResponseKey, respond(msg))
+			case msg := <-channels.reviewDeleteCh:
+				channels.sendResponse(msg.ResponseKey, respond(msg))
+			case msg := <-channels.bulkReviewCh:
+				channels.sendResponse(msg.ResponseKey, respond(msg))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	t.Cleanup(func() {
+		close(done)
+		tenantChannelManager.channels.Delete(tenantID)
+	})
+
+	return channels
+}
+
+// newTenantRequest builds a request carrying the tenant in its context and the given mux vars
+func newTenantRequest(method, path, tenantID string, vars map[string]string) *http.Request {
+	return newTenantRequestWithBody(method, path, tenantID, vars, nil)
+}
+
+// newTenantRequestWithBody builds a tenant request with a body
+func newTenantRequestWithBody(method, path, tenantID string, vars map[string]string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, path, body)
+	req = req.WithContext(context.WithValue(req.Context(), TenantIDKey, tenantID))
+	return mux.SetURLVars(req, vars)
+}
+
+func TestReviewStatusHandler(t *testing.T) {
+	registerTestTenant(t, "review-status-tenant", func(msg RequestMessage) ResponseMessage {
+		switch msg.ID {
+		case "reviewed":
+			return ResponseMessage{Data: &ReviewStatusResponse{
+				Reviewed:   true,
+				ReviewTime: "2025-01-01T10:00:00Z",
+				EntityType: msg.Entity,
+				EntityID:   msg.ID,
+			}}
+		case "unreviewed":
+			return ResponseMessage{Data: &ReviewStatusResponse{
+				Reviewed:   false,
+				EntityType: msg.Entity,
+				EntityID:   msg.ID,
+			}}
+		case "unavailable":
+			return ResponseMessage{Data: &ReviewStatusResponse{
+				ReviewError: true,
+				EntityType:  msg.Entity,
+				EntityID:    msg.ID,
+			}}
+		default:
+			return ResponseMessage{Error: errors.New("resource not found")}
+		}
+	})
+
+	tests := []struct {
+		name                string
+		id                  string
+		expectedStatus      int
+		expectedReviewed    bool
+		expectedReviewError bool
+	}{
+		{
+			name:             "Reviewed resource",
+			id:               "reviewed",
+			expectedStatus:   http.StatusOK,
+			expectedReviewed: true,
+		},
+		{
+			name:             "Un-reviewed resource",
+			id:               "unreviewed",
+			expectedStatus:   http.StatusOK,
+			expectedReviewed: false,
+		},
+		{
+			name:                "Review status unavailable",
+			id:                  "unavailable",
+			expectedStatus:      http.StatusOK,
+			expectedReviewed:    false,
+			expectedReviewError: true,
+		},
+		{
+			name:           "Non-existent resource",
+			id:             "missing",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTenantRequest("GET", "/api/review-status-tenant/encounters/"+tt.id+"/review-status",
+				"review-status-tenant", map[string]string{"id": tt.id})
+
+			rr := httptest.NewRecorder()
+			ReviewStatusHandler("Encounter").ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response ReviewStatusResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Reviewed != tt.expectedReviewed {
+				t.Errorf("Expected reviewed %v, got %v", tt.expectedReviewed, response.Reviewed)
+			}
+			if response.ReviewError != tt.expectedReviewError {
+				t.Errorf("Expected reviewError %v, got %v", tt.expectedReviewError, response.ReviewError)
+			}
+			if response.EntityType != "Encounter" || response.EntityID != tt.id {
+				t.Errorf("Unexpected entity %s/%s", response.EntityType, response.EntityID)
+			}
+		})
+	}
+}
+
+func TestReviewHistoryHandler(t *testing.T) {
+	first := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	registerTestTenant(t, "review-history-tenant", func(msg RequestMessage) ResponseMessage {
+		if msg.ID == "missing" {
+			return ResponseMessage{Error: fmt.Errorf("failed to get review history for %s/missing: resource not found", msg.Entity)}
+		}
+		return ResponseMessage{Data: &ReviewHistoryResponse{
+			EntityType: msg.Entity,
+			EntityID:   msg.ID,
+			History: []dal.ReviewEvent{
+				{ReviewedAt: first, TenantID: msg.TenantID, ReviewerUsername: "alice"},
+				{ReviewedAt: first.Add(time.Hour), TenantID: msg.TenantID, ReviewerUsername: "bob"},
+			},
+		}}
+	})
+
+	req := newTenantRequest("GET", "/api/review-history-tenant/patients/1/review-history",
+		"review-history-tenant", map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+	ReviewHistoryHandler("Patient").ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var response ReviewHistoryResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.EntityType != "Patient" || response.EntityID != "1" {
+		t.Errorf("Unexpected entity %s/%s", response.EntityType, response.EntityID)
+	}
+	if len(response.History) != 2 || response.History[0].ReviewerUsername != "alice" || !response.History[0].ReviewedAt.Equal(first) {
+		t.Errorf("Expected the history in review order, got %+v", response.History)
+	}
+
+	req = newTenantRequest("GET", "/api/review-history-tenant/patients/missing/review-history",
+		"review-history-tenant", map[string]string{"id": "missing"})
+	rr = httptest.NewRecorder()
+	ReviewHistoryHandler("Patient").ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing resource, got %d", rr.Code)
+	}
+}
+
+func TestReviewRequestHandlerReviewer(t *testing.T) {
+	var received RequestMessage
+	registerTestTenant(t, "review-reviewer-tenant", func(msg RequestMessage) ResponseMessage {
+		received = msg
+		return ResponseMessage{Data: map[string]interface{}{"status": "review requested"}}
+	})
+
+	req := newTenantRequestWithBody("POST", "/api/review-reviewer-tenant/review-request",
+		"review-reviewer-tenant", nil, strings.NewReader(`{"entity":"encounter","id":"1"}`))
+	req = req.WithContext(context.WithValue(req.Context(), UsernameKey, "alice"))
+
+	rr := httptest.NewRecorder()
+	ReviewRequestHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if received.Reviewer != "alice" {
+		t.Errorf("Expected reviewer alice, got %q", received.Reviewer)
+	}
+}
+
+func TestReviewRequestHandlerSeverity(t *testing.T) {
+	var received RequestMessage
+	registerTestTenant(t, "review-severity-tenant", func(msg RequestMessage) ResponseMessage {
+		received = msg
+		return ResponseMessage{Data: map[string]interface{}{"status": "review requested"}}
+	})
+
+	tests := []struct {
+		name             string
+		body             string
+		expectedStatus   int
+		expectedSeverity string
+		expectedNotes    string
+	}{
+		{
+			name:           "No severity",
+			body:           `{"entity":"encounter","id":"1"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "Info severity with notes",
+			body:             `{"entity":"encounter","id":"1","severity":"info","notes":"Patient identity confirmed"}`,
+			expectedStatus:   http.StatusOK,
+			expectedSeverity: "info",
+			expectedNotes:    "Patient identity confirmed",
+		},
+		{
+			name:             "Warning severity",
+			body:             `{"entity":"patient","id":"1","severity":"warning"}`,
+			expectedStatus:   http.StatusOK,
+			expectedSeverity: "warning",
+		},
+		{
+			name:             "Critical severity is normalized",
+			body:             `{"entity":"practitioner","id":"1","severity":" Critical "}`,
+			expectedStatus:   http.StatusOK,
+			expectedSeverity: "critical",
+		},
+		{
+			name:           "Unknown severity",
+			body:           `{"entity":"encounter","id":"1","severity":"urgent"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = RequestMessage{}
+			req := newTenantRequestWithBody("POST", "/api/review-severity-tenant/review-request",
+				"review-severity-tenant", nil, strings.NewReader(tt.body))
+
+			rr := httptest.NewRecorder()
+			ReviewRequestHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			if received.Severity != tt.expectedSeverity {
+				t.Errorf("Expected severity %q, got %q", tt.expectedSeverity, received.Severity)
+			}
+			if received.Notes != tt.expectedNotes {
+				t.Errorf("Expected notes %q, got %q", tt.expectedNotes, received.Notes)
+			}
+		})
+	}
+}
+
+func TestBulkReviewRequestHandler(t *testing.T) {
+	var received RequestMessage
+	registerTestTenant(t, "bulk-review-tenant", func(msg RequestMessage) ResponseMessage {
+		received = msg
+		response := &BulkReviewResponse{Succeeded: []string{}, Failed: []BulkReviewFailure{}}
+		for _, item := range msg.Reviews {
+			if item.ID == "missing" {
+				response.Failed = append(response.Failed, BulkReviewFailure{Entity: item.ResourceType, ID: item.ID, Error: "resource not found"})
+				continue
+			}
+			response.Succeeded = append(response.Succeeded, item.ResourceType+"/"+item.ID)
+		}
+		return ResponseMessage{Data: response}
+	})
+
+	tooMany := make([]string, maxBulkReviewItems+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf(`{"entity":"encounter","id":"%d"}`, i)
+	}
+
+	tests := []struct {
+		name              string
+		body              string
+		expectedStatus    int
+		expectedSucceeded int
+		expectedFailed    int
+		expectedItems     int
+	}{
+		{
+			name:              "All succeed",
+			body:              `{"reviews":[{"entity":"encounter","id":"1"},{"entity":"patient","id":"2","severity":"Warning"}]}`,
+			expectedStatus:    http.StatusOK,
+			expectedSucceeded: 2,
+			expectedItems:     2,
+		},
+		{
+			name:              "Partial success",
+			body:              `{"reviews":[{"entity":"encounter","id":"1"},{"entity":"encounter","id":"missing"},{"entity":"device","id":"3"}]}`,
+			expectedStatus:    http.StatusMultiStatus,
+			expectedSucceeded: 1,
+			expectedFailed:    2,
+			expectedItems:     2,
+		},
+		{
+			name:           "All fail",
+			body:           `{"reviews":[{"entity":"encounter","id":"missing"},{"entity":"encounter","id":"1","severity":"urgent"}]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedFailed: 2,
+			expectedItems:  1,
+		},
+		{
+			name:           "All invalid",
+			body:           `{"reviews":[{"entity":"encounter"}]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedFailed: 1,
+		},
+		{
+			name:           "Empty batch",
+			body:           `{"reviews":[]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Batch too large",
+			body:           `{"reviews":[` + strings.Join(tooMany, ",") + `]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid JSON",
+			body:           `{"reviews":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = RequestMessage{}
+			req := newTenantRequestWithBody("POST", "/api/bulk-review-tenant/bulk-review-request",
+				"bulk-review-tenant", nil, strings.NewReader(tt.body))
+
+			rr := httptest.NewRecorder()
+			BulkReviewRequestHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if len(received.Reviews) != tt.expectedItems {
+				t.Errorf("Expected %d items sent to the channel, got %d", tt.expectedItems, len(received.Reviews))
+			}
+			if tt.expectedStatus == http.StatusBadRequest {
+				return
+			}
+
+			var response BulkReviewResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(response.Succeeded) != tt.expectedSucceeded {
+				t.Errorf("Expected %d succeeded, got %v", tt.expectedSucceeded, response.Succeeded)
+			}
+			if len(response.Failed) != tt.expectedFailed {
+				t.Errorf("Expected %d failed, got %v", tt.expectedFailed, response.Failed)
+			}
+		})
+	}
+}
+
+func TestDeleteReviewRequestHandler(t *testing.T) {
+	// The fake tenant keeps review state so deletes are visible to review-status requests
+	reviewed := map[string]bool{"reviewed-1": true, "unreviewed-1": false}
+	channels := &TenantChannels{
+		reviewStatusCh: make(chan RequestMessage),
+		reviewDeleteCh: make(chan RequestMessage),
+		responsePool:   NewResponsePool(1),
+	}
+	tenantChannelManager.channels.Store("review-delete-tenant", channels)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case msg := <-channels.reviewDeleteCh:
+				isReviewed, exists := reviewed[msg.ID]
+				switch {
+				case !exists:
+					channels.sendResponse(msg.ResponseKey, ResponseMessage{Error: errors.New("resource not found")})
+				case !isReviewed:
+					channels.sendResponse(msg.ResponseKey, ResponseMessage{Error: fmt.Errorf("failed to delete review request: %w", dal.ErrNotReviewed)})
+				default:
+					reviewed[msg.ID] = false
+					channels.sendResponse(msg.ResponseKey, ResponseMessage{Data: map[string]interface{}{
+						"status": "review removed",
+						"entity": msg.Entity + "/" + msg.ID,
+					}})
+				}
+			case msg := <-channels.reviewStatusCh:
+				channels.sendResponse(msg.ResponseKey, ResponseMessage{Data: &ReviewStatusResponse{
+					Reviewed:   reviewed[msg.ID],
+					EntityType: msg.Entity,
+					EntityID:   msg.ID,
+				}})
+			case <-done:
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		close(done)
+		tenantChannelManager.channels.Delete("review-delete-tenant")
+	})
+
+	deleteReview := func(body string) *httptest.ResponseRecorder {
+		req := newTenantRequestWithBody("DELETE", "/api/review-delete-tenant/review-request",
+			"review-delete-tenant", nil, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		DeleteReviewRequestHandler(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Invalid entity", body: `{"entity":"observation","id":"reviewed-1"}`, expectedStatus: http.StatusBadRequest, expectedError: "invalid entity"},
+		{name: "Missing id", body: `{"entity":"encounter"}`, expectedStatus: http.StatusBadRequest, expectedError: "missing id"},
+		{name: "Unknown resource", body: `{"entity":"encounter","id":"missing"}`, expectedStatus: http.StatusNotFound, expectedError: "resource not found"},
+		{name: "Resource not reviewed", body: `{"entity":"encounter","id":"unreviewed-1"}`, expectedStatus: http.StatusNotFound, expectedError: "resource not reviewed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := deleteReview(tt.body)
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			var body map[string]string
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if body["error"] != tt.expectedError {
+				t.Errorf("Expected error %q, got %q", tt.expectedError, body["error"])
+			}
+		})
+	}
+
+	t.Run("Reviewed resource", func(t *testing.T) {
+		rr := deleteReview(`{"entity":"Encounter","id":"reviewed-1"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		var body map[string]string
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if body["status"] != "review removed" || body["entity"] != "Encounter/reviewed-1" {
+			t.Errorf("Unexpected body: %v", body)
+		}
+
+		// The resource now reports reviewed=false
+		req := newTenantRequest("GET", "/api/review-delete-tenant/encounters/reviewed-1/review-status",
+			"review-delete-tenant", map[string]string{"id": "reviewed-1"})
+		statusRR := httptest.NewRecorder()
+		ReviewStatusHandler("Encounter")(statusRR, req)
+
+		var status ReviewStatusResponse
+		if err := json.NewDecoder(statusRR.Body).Decode(&status); err != nil {
+			t.Fatalf("Failed to decode review status: %v", err)
+		}
+		if status.Reviewed {
+			t.Error("Expected reviewed=false after deleting the review")
+		}
+
+		// Deleting again finds nothing to remove
+		if rr := deleteReview(`{"entity":"Encounter","id":"reviewed-1"}`); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d on second delete, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+func TestListResourcesHandlerStatusFilter(t *testing.T) {
+	var received RequestMessage
+	registerTestTenant(t, "status-filter-tenant", func(msg RequestMessage) ResponseMessage {
+		received = msg
+		return ResponseMessage{Data: map[string]interface{}{"data": []interface{}{}}}
+	})
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedFilter []string
+	}{
+		{
+			name:           "Absent status applies no filter",
+			query:          "",
+			expectedStatus: http.StatusOK,
+			expectedFilter: nil,
+		},
+		{
+			name:           "Single status",
+			query:          "?status=finished",
+			expectedStatus: http.StatusOK,
+			expectedFilter: []string{"finished"},
+		},
+		{
+			name:           "Multiple comma-separated statuses",
+			query:          "?status=planned,in-progress",
+			expectedStatus: http.StatusOK,
+			expectedFilter: []string{"planned", "in-progress"},
+		},
+		{
+			name:           "Multiple repeated statuses",
+			query:          "?status=finished&status=cancelled",
+			expectedStatus: http.StatusOK,
+			expectedFilter: []string{"finished", "cancelled"},
+		},
+		{
+			name:           "Invalid status",
+			query:          "?status=done",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = RequestMessage{}
+			req := newTenantRequest("GET", "/api/status-filter-tenant/encounters"+tt.query, "status-filter-tenant", nil)
+
+			rr := httptest.NewRecorder()
+			ListResourcesHandler("Encounter").ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			if !reflect.DeepEqual(received.EncounterFilter.Status, tt.expectedFilter) {
+				t.Errorf("Expected status filter %v, got %v", tt.expectedFilter, received.EncounterFilter.Status)
+			}
+		})
+	}
+}
+
+func TestListResourcesHandlerSort(t *testing.T) {
+	var received RequestMessage
+	registerTestTenant(t, "sort-tenant", func(msg RequestMessage) ResponseMessage {
+		received = msg
+		return ResponseMessage{Data: map[string]interface{}{"data": []interface{}{}}}
+	})
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedSort   []dal.SortField
+	}{
+		{
+			name:           "Absent sort keeps key order",
+			query:          "",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Ascending by default",
+			query:          "?_sort=date",
+			expectedStatus: http.StatusOK,
+			expectedSort:   []dal.SortField{{Field: "date", Direction: "asc"}},
+		},
+		{
+			name:           "Descending",
+			query:          "?_sort=date:desc",
+			expectedStatus: http.StatusOK,
+			expectedSort:   []dal.SortField{{Field: "date", Direction: "desc"}},
+		},
+		{
+			name:           "Multiple fields",
+			query:          "?_sort=date:desc,status:asc",
+			expectedStatus: http.StatusOK,
+			expectedSort:   []dal.SortField{{Field: "date", Direction: "desc"}, {Field: "status", Direction: "asc"}},
+		},
+		{
+			name:           "Unknown field",
+			query:          "?_sort=priority:desc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown direction",
+			query:          "?_sort=date:down",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = RequestMessage{}
+			req := newTenantRequest("GET", "/api/sort-tenant/encounters"+tt.query, "sort-tenant", nil)
+
+			rr := httptest.NewRecorder()
+			ListResourcesHandler("Encounter").ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			if !reflect.DeepEqual(received.EncounterFilter.Sort, tt.expectedSort) {
+				t.Errorf("Expected sort %v, got %v", tt.expectedSort, received.EncounterFilter.Sort)
+			}
+		})
+	}
+}
+
+func TestListResourcesHandlerSearch(t *testing.T) {
+	var received RequestMessage
+	registerTestTenant(t, "search-tenant", func(msg RequestMessage) ResponseMessage {
+		received = msg
+		return ResponseMessage{Data: map[string]interface{}{"data": []interface{}{}}}
+	})
+
+	tests := []struct {
+		name            string
+		query           string
+		expectedStatus  int
+		expectedDate    []dal.DateComparison
+		expectedPatient string
+	}{
+		{
+			name:           "Date range",
+			query:          "?date=ge2023-01-01&date=le2023-12-31",
+			expectedStatus: http.StatusOK,
+			expectedDate: []dal.DateComparison{
+				{Prefix: "ge", Value: "2023-01-01"},
+				{Prefix: "le", Value: "2023-12-31"},
+			},
+		},
+		{
+			name:           "Date without prefix",
+			query:          "?date=2023-06",
+			expectedStatus: http.StatusOK,
+			expectedDate:   []dal.DateComparison{{Prefix: "eq", Value: "2023-06"}},
+		},
+		{
+			name:            "Patient reference",
+			query:           "?patient=Patient/abc&status=finished",
+			expectedStatus:  http.StatusOK,
+			expectedPatient: "abc",
+		},
+		{
+			name:            "Bare patient ID",
+			query:           "?patient=abc",
+			expectedStatus:  http.StatusOK,
+			expectedPatient: "abc",
+		},
+		{
+			name:           "Invalid date",
+			query:          "?date=ge2023-13-01",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid date prefix",
+			query:          "?date=sa2023-01-01",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Reference to another resource type",
+			query:          "?patient=Practitioner/abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = RequestMessage{}
+			req := newTenantRequest("GET", "/api/search-tenant/encounters"+tt.query, "search-tenant", nil)
+
+			rr := httptest.NewRecorder()
+			ListResourcesHandler("Encounter").ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			if !reflect.DeepEqual(received.EncounterFilter.Date, tt.expectedDate) {
+				t.Errorf("Expected date filter %v, got %v", tt.expectedDate, received.EncounterFilter.Date)
+			}
+			if received.EncounterFilter.Patient != tt.expectedPatient {
+				t.Errorf("Expected patient %q, got %q", tt.expectedPatient, received.EncounterFilter.Patient)
+			}
+		})
+	}
+}
+
+func TestListResourcesHandlerPagination(t *testing.T) {
+	var received RequestMessage
+	registerTestTenant(t, "pagination-tenant", func(msg RequestMessage) ResponseMessage {
+		received = msg
+		return ResponseMessage{Data: map[string]interface{}{"data": []interface{}{}}}
+	})
+
+	cursor := dal.FormatCursor("Encounter/10")
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedPage   int
+		expectedCursor string
+	}{
+		{
+			name:           "First page uses keyset pagination",
+			query:          "",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Cursor",
+			query:          "?cursor=" + cursor,
+			expectedStatus: http.StatusOK,
+			expectedCursor: cursor,
+		},
+		{
+			name:           "Page alone falls back to offset pagination",
+			query:          "?page=3",
+			expectedStatus: http.StatusOK,
+			expectedPage:   3,
+		},
+		{
+			name:           "Cursor takes precedence over page",
+			query:          "?page=3&cursor=" + cursor,
+			expectedStatus: http.StatusOK,
+			expectedCursor: cursor,
+		},
+		{
+			name:           "Invalid cursor",
+			query:          "?cursor=not-base64!",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Cursor with sort",
+			query:          "?_sort=date&cursor=" + cursor,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = RequestMessage{}
+			req := newTenantRequest("GET", "/api/pagination-tenant/encounters"+tt.query, "pagination-tenant", nil)
+
+			rr := httptest.NewRecorder()
+			ListResourcesHandler("Encounter").ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			if received.Page != tt.expectedPage || received.Cursor != tt.expectedCursor {
+				t.Errorf("Expected page %d and cursor %q, got page %d and cursor %q",
+					tt.expectedPage, tt.expectedCursor, received.Page, received.Cursor)
+			}
+		})
+	}
+}
+
+func TestListResourcesHandlerStreamsRows(t *testing.T) {
+	registerTestTenant(t, "stream-tenant", func(msg RequestMessage) ResponseMessage {
+		for i := 1; i <= 3; i++ {
+			msg.Rows <- dal.QueryRow{ID: fmt.Sprintf("Encounter/%d", i), Resource: map[string]interface{}{"id": fmt.Sprintf("%d", i)}}
+		}
+		return ResponseMessage{Data: map[string]interface{}{
+			"pagination": dal.StreamedPagination(dal.PaginationParams{Count: msg.Count}, 3, "Encounter/3"),
+		}}
+	})
+
+	req := newTenantRequest("GET", "/api/stream-tenant/encounters?count=3", "stream-tenant", nil)
+	rr := httptest.NewRecorder()
+	ListResourcesHandler("Encounter").ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if !rr.Flushed {
+		t.Errorf("Expected rows to be flushed as they are written")
+	}
+
+	var body struct {
+		Data       []dal.QueryRow         `json:"data"`
+		Pagination map[string]interface{} `json:"pagination"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected a JSON body, got %q: %v", rr.Body.String(), err)
+	}
+	if len(body.Data) != 3 || body.Data[0].ID != "Encounter/1" || body.Data[2].ID != "Encounter/3" {
+		t.Errorf("Expected the 3 streamed rows in order, got %+v", body.Data)
+	}
+	if body.Pagination["hasNext"] != true || body.Pagination["nextCursor"] != dal.FormatCursor("Encounter/3") {
+		t.Errorf("Expected a full page with a next cursor, got %v", body.Pagination)
+	}
+}
+
+func TestListResourcesHandlerEmptyStream(t *testing.T) {
+	registerTestTenant(t, "empty-stream-tenant", func(msg RequestMessage) ResponseMessage {
+		return ResponseMessage{Data: map[string]interface{}{
+			"pagination": dal.StreamedPagination(dal.PaginationParams{Count: msg.Count}, 0, ""),
+		}}
+	})
+
+	req := newTenantRequest("GET", "/api/empty-stream-tenant/encounters", "empty-stream-tenant", nil)
+	rr := httptest.NewRecorder()
+	ListResourcesHandler("Encounter").ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if !strings.HasPrefix(rr.Body.String(), `{"data":[],"pagination":`) {
+		t.Errorf("Expected an empty data array, got %s", rr.Body.String())
+	}
+}
+
+func TestIngestionStatusHandler(t *testing.T) {
+	startedAt := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	completedAt := startedAt.Add(2 * time.Minute)
+
+	statuses := map[string]*dal.IngestionStatus{
+		"in-progress-tenant": {
+			Ready:     false,
+			StartedAt: startedAt,
+			Message:   "FHIR ingestion started",
+		},
+		"completed-tenant": {
+			Ready:          true,
+			StartedAt:      startedAt,
+			CompletedAt:    completedAt,
+			Message:        "Data copied from DefaultScope",
+			ResourceCounts: map[string]int{"Encounter": 10, "Patient": 5, "Practitioner": 5},
+		},
+		"never-started-tenant": {Ready: false},
+	}
+
+	origGetter := getTenantIngestionStatus
+	getTenantIngestionStatus = func(ctx context.Context, tenantID string) (*dal.IngestionStatus, error) {
+		status, ok := statuses[tenantID]
+		if !ok {
+			return nil, errors.New("failed to get tenant scope ingestion status")
+		}
+		return status, nil
+	}
+	t.Cleanup(func() {
+		getTenantIngestionStatus = origGetter
+	})
+
+	tests := []struct {
+		name           string
+		tenantID       string
+		expectedStatus int
+		expected       *dal.IngestionStatus
+	}{
+		{
+			name:           "In-progress ingestion",
+			tenantID:       "in-progress-tenant",
+			expectedStatus: http.StatusOK,
+			expected:       statuses["in-progress-tenant"],
+		},
+		{
+			name:           "Completed ingestion",
+			tenantID:       "completed-tenant",
+			expectedStatus: http.StatusOK,
+			expected:       statuses["completed-tenant"],
+		},
+		{
+			name:           "Never-started ingestion",
+			tenantID:       "never-started-tenant",
+			expectedStatus: http.StatusOK,
+			expected:       statuses["never-started-tenant"],
+		},
+		{
+			name:           "DAL error",
+			tenantID:       "broken-tenant",
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTenantRequest("GET", "/api/"+tt.tenantID+"/ingestion-status", tt.tenantID, nil)
+
+			rr := httptest.NewRecorder()
+			IngestionStatusHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expected == nil {
+				return
+			}
+
+			var response dal.IngestionStatus
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Ready != tt.expected.Ready || response.Message != tt.expected.Message {
+				t.Errorf("Expected ready %v message %q, got ready %v message %q",
+					tt.expected.Ready, tt.expected.Message, response.Ready, response.Message)
+			}
+			if !response.StartedAt.Equal(tt.expected.StartedAt) || !response.CompletedAt.Equal(tt.expected.CompletedAt) {
+				t.Errorf("Unexpected timestamps: started %v completed %v", response.StartedAt, response.CompletedAt)
+			}
+			if !reflect.DeepEqual(response.ResourceCounts, tt.expected.ResourceCounts) {
+				t.Errorf("Expected resource counts %v, got %v", tt.expected.ResourceCounts, response.ResourceCounts)
+			}
+			if _, warm := GetTenantChannels(tt.tenantID); warm {
+				t.Errorf("Expected ingestion status not to warm up the tenant")
+			}
+		})
+	}
+}
+
+func TestReviewSummaryHandler(t *testing.T) {
+	summary := &dal.ReviewSummary{
+		Encounter:    dal.ReviewCounts{Total: 100, Reviewed: 45, Pct: 45},
+		Patient:      dal.ReviewCounts{Total: 20, Reviewed: 5, Pct: 25},
+		Practitioner: dal.ReviewCounts{},
+	}
+
+	origGetter := getReviewSummary
+	getReviewSummary = func(ctx context.Context, tenantID string) (*dal.ReviewSummary, error) {
+		if tenantID == "broken-tenant" {
+			return nil, errors.New("query failed")
+		}
+		return summary, nil
+	}
+	t.Cleanup(func() {
+		getReviewSummary = origGetter
+	})
+
+	tests := []struct {
+		name           string
+		tenantID       string
+		expectedStatus int
+	}{
+		{name: "Summary", tenantID: "summary-tenant", expectedStatus: http.StatusOK},
+		{name: "DAL error", tenantID: "broken-tenant", expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTenantRequest("GET", "/api/"+tt.tenantID+"/review-summary", tt.tenantID, nil)
+
+			rr := httptest.NewRecorder()
+			ReviewSummaryHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response map[string]dal.ReviewCounts
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			want := map[string]dal.ReviewCounts{
+				"encounter":    summary.Encounter,
+				"patient":      summary.Patient,
+				"practitioner": summary.Practitioner,
+			}
+			if !reflect.DeepEqual(response, want) {
+				t.Errorf("Expected %v, got %v", want, response)
+			}
+		})
+	}
+}
+
+func TestIngestManifestsHandler(t *testing.T) {
+	startedAt := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	manifests := []dal.IngestManifest{
+		{
+			RunID:             "run-2",
+			StartedAt:         startedAt.Add(time.Hour),
+			CompletedAt:       startedAt.Add(time.Hour + 2*time.Minute),
+			ResourceCounts:    map[string]int{"Encounter": 10, "Patient": 5, "Practitioner": 5},
+			FHIRServerURL:     "https://hapi.fhir.org/baseR4",
+			Status:            "success",
+			FailedDocumentIDs: []string{},
+		},
+		{
+			RunID:             "run-1",
+			StartedAt:         startedAt,
+			CompletedAt:       startedAt.Add(time.Minute),
+			ResourceCounts:    map[string]int{"Encounter": 3},
+			FHIRServerURL:     "https://hapi.fhir.org/baseR4",
+			Status:            "failure",
+			Error:             "failed to ingest patients",
+			FailedDocumentIDs: []string{"Encounter/bad-1"},
+		},
+	}
+
+	var gotLimit int
+	var listErr error
+	origList := listIngestManifests
+	listIngestManifests = func(ctx context.Context, limit int) ([]dal.IngestManifest, error) {
+		gotLimit = limit
+		if listErr != nil {
+			return nil, listErr
+		}
+		return manifests, nil
+	}
+	t.Cleanup(func() {
+		listIngestManifests = origList
+	})
+
+	tests := []struct {
+		name           string
+		query          string
+		err            error
+		expectedStatus int
+		expectedLimit  int
+	}{
+		{name: "Default limit", expectedStatus: http.StatusOK, expectedLimit: 20},
+		{name: "Custom limit", query: "?limit=5", expectedStatus: http.StatusOK, expectedLimit: 5},
+		{name: "Out of range limit uses default", query: "?limit=1000", expectedStatus: http.StatusOK, expectedLimit: 20},
+		{name: "DAL error", err: errors.New("query failed"), expectedStatus: http.StatusInternalServerError, expectedLimit: 20},
+		{name: "Client cancelled", err: fmt.Errorf("query failed: %w", context.Canceled), expectedStatus: statusClientClosedRequest, expectedLimit: 20},
+		{name: "Deadline exceeded", err: fmt.Errorf("query failed: %w", context.DeadlineExceeded), expectedStatus: http.StatusGatewayTimeout, expectedLimit: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listErr = tt.err
+			req := httptest.NewRequest("GET", IngestManifestsPath+tt.query, nil)
+
+			rr := httptest.NewRecorder()
+			IngestManifestsHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if gotLimit != tt.expectedLimit {
+				t.Errorf("Expected limit %d, got %d", tt.expectedLimit, gotLimit)
+			}
+			if tt.err != nil {
+				return
+			}
+
+			var response struct {
+				Data []dal.IngestManifest `json:"data"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(response.Data) != len(manifests) {
+				t.Fatalf("Expected %d manifests, got %d", len(manifests), len(response.Data))
+			}
+			if response.Data[0].RunID != "run-2" || !reflect.DeepEqual(response.Data[0].ResourceCounts, manifests[0].ResourceCounts) {
+				t.Errorf("Unexpected first manifest: %+v", response.Data[0])
+			}
+			if !reflect.DeepEqual(response.Data[1].FailedDocumentIDs, []string{"Encounter/bad-1"}) {
+				t.Errorf("Unexpected failed document IDs: %v", response.Data[1].FailedDocumentIDs)
+			}
+		})
+	}
+}
+
+func TestReviewRequestHandlerIfMatch(t *testing.T) {
+	// The fake tenant keeps one encounter whose version changes with every applied review
+	version := gocb.Cas(1)
+	registerTestTenant(t, "if-match-tenant", func(msg RequestMessage) ResponseMessage {
+		current := dal.FormatETag(version)
+		switch {
+		case !strings.Contains(msg.ID, "/"):
+			// Get requests carry the bare ID, review requests carry "Encounter/1"
+			return ResponseMessage{Data: map[string]interface{}{"data": map[string]interface{}{"id": msg.ID}}, ETag: current}
+		case msg.IfMatch != "" && msg.IfMatch != current:
+			return ResponseMessage{Error: fmt.Errorf("failed to create review request: %w", dal.ErrReviewConflict)}
+		default:
+			version++
+			return ResponseMessage{Data: map[string]interface{}{"status": "review requested"}}
+		}
+	})
+
+	getETag := func(t *testing.T) string {
+		t.Helper()
+		req := newTenantRequest("GET", "/api/if-match-tenant/encounters/1", "if-match-tenant", map[string]string{"id": "1"})
+		rr := httptest.NewRecorder()
+		GetResourceByIDHandler("Encounter")(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		etag := rr.Header().Get("ETag")
+		if etag == "" {
+			t.Fatal("Expected an ETag header")
+		}
+		return etag
+	}
+
+	review := func(t *testing.T, ifMatch, notes string) int {
+		t.Helper()
+		req := newTenantRequestWithBody("POST", "/api/if-match-tenant/review-request", "if-match-tenant", nil,
+			strings.NewReader(`{"entity":"encounter","id":"1","notes":"`+notes+`"}`))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		ReviewRequestHandler(rr, req)
+		return rr.Code
+	}
+
+	// Two reviewers read the same version of the encounter
+	first := getETag(t)
+	second := getETag(t)
+	if first != second {
+		t.Fatalf("Expected the same ETag for the same version, got %s and %s", first, second)
+	}
+
+	if status := review(t, first, "first reviewer"); status != http.StatusOK {
+		t.Fatalf("Expected first review to succeed, got %d", status)
+	}
+	if status := review(t, second, "second reviewer"); status != http.StatusConflict {
+		t.Fatalf("Expected second review to conflict, got %d", status)
+	}
+
+	// After reading the new version the second reviewer can review again
+	if status := review(t, getETag(t), "second reviewer"); status != http.StatusOK {
+		t.Errorf("Expected review with the current ETag to succeed, got %d", status)
+	}
+
+	// Without If-Match the review is unconditional
+	if status := review(t, "", "third reviewer"); status != http.StatusOK {
+		t.Errorf("Expected review without If-Match to succeed, got %d", status)
+	}
+
+	if status := review(t, "not-an-etag", "fourth reviewer"); status != http.StatusBadRequest {
+		t.Errorf("Expected invalid If-Match to be rejected, got %d", status)
+	}
+}
+
+func TestGetResourceByIDHandlerIfNoneMatch(t *testing.T) {
+	// The fake tenant keeps one encounter whose version changes with every applied review
+	version := gocb.Cas(1)
+	registerTestTenant(t, "if-none-match-tenant", func(msg RequestMessage) ResponseMessage {
+		if strings.Contains(msg.ID, "/") {
+			version++
+			return ResponseMessage{Data: map[string]interface{}{"status": "review requested"}}
+		}
+		return ResponseMessage{Data: map[string]interface{}{"data": map[string]interface{}{"id": msg.ID}}, ETag: dal.FormatETag(version)}
+	})
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := newTenantRequest("GET", "/api/if-none-match-tenant/encounters/1", "if-none-match-tenant", map[string]string{"id": "1"})
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		GetResourceByIDHandler("Encounter")(rr, req)
+		return rr
+	}
+
+	first := get("")
+	if first.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, first.Code)
+	}
+	etag := first.Header().Get("ETag")
+
+	second := get(etag)
+	if second.Code != http.StatusNotModified {
+		t.Fatalf("Expected status %d for an unchanged resource, got %d", http.StatusNotModified, second.Code)
+	}
+	if second.Body.Len() != 0 {
+		t.Errorf("Expected no body on 304, got %q", second.Body.String())
+	}
+	if second.Header().Get("ETag") != etag {
+		t.Errorf("Expected 304 to carry ETag %s, got %s", etag, second.Header().Get("ETag"))
+	}
+
+	// A review changes the CAS of the document
+	req := newTenantRequestWithBody("POST", "/api/if-none-match-tenant/review-request", "if-none-match-tenant", nil,
+		strings.NewReader(`{"entity":"encounter","id":"1"}`))
+	rr := httptest.NewRecorder()
+	ReviewRequestHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected review to succeed, got %d", rr.Code)
+	}
+
+	third := get(etag)
+	if third.Code != http.StatusOK {
+		t.Fatalf("Expected status %d after the review, got %d", http.StatusOK, third.Code)
+	}
+	if third.Header().Get("ETag") == etag {
+		t.Errorf("Expected a new ETag after the review")
+	}
+}
+
+func TestGetResourceByIDHandlerIfNoneMatchElements(t *testing.T) {
+	registerTestTenant(t, "elements-etag-tenant", func(msg RequestMessage) ResponseMessage {
+		return ResponseMessage{Data: map[string]interface{}{"data": map[string]interface{}{
+			"resourceType": "Encounter",
+			"id":           msg.ID,
+			"status":       "finished",
+		}}, ETag: dal.FormatETag(1)}
+	})
+
+	get := func(elements, ifNoneMatch string) *httptest.ResponseRecorder {
+		path := "/api/elements-etag-tenant/encounters/1"
+		if elements != "" {
+			path += "?_elements=" + elements
+		}
+		req := newTenantRequest("GET", path, "elements-etag-tenant", map[string]string{"id": "1"})
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		GetResourceByIDHandler("Encounter")(rr, req)
+		return rr
+	}
+
+	full := get("", "").Header().Get("ETag")
+	subset := get("status,subject", "")
+	etag := subset.Header().Get("ETag")
+	if etag == "" || etag == full {
+		t.Fatalf("Expected the _elements response to have its own ETag, got %q for full ETag %q", etag, full)
+	}
+
+	// The subset is revalidated against its own ETag, in any element order
+	if rr := get("subject,status", etag); rr.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d for an unchanged subset, got %d", http.StatusNotModified, rr.Code)
+	}
+	// The full resource ETag does not validate a subset, nor the subset ETag the full resource
+	if rr := get("status,subject", full); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d for the full resource ETag, got %d", http.StatusOK, rr.Code)
+	}
+	if rr := get("", etag); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d for the subset ETag, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestGetResourceByIDHandlerNotFound(t *testing.T) {
+	registerTestTenant(t, "get-encounter-tenant", func(msg RequestMessage) ResponseMessage {
+		if msg.ID == "missing" {
+			return ResponseMessage{Error: errors.New("failed to retrieve resource: resource not found: document not found")}
+		}
+		return ResponseMessage{Data: map[string]interface{}{"data": map[string]interface{}{"id": msg.ID, "resourceType": "Encounter"}}}
+	})
+
+	tests := []struct {
+		name                string
+		id                  string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+	}{
+		{
+			name:                "Missing encounter as JSON",
+			id:                  "missing",
+			expectedStatus:      http.StatusNotFound,
+			expectedContentType: "application/json",
+		},
+		{
+			name:                "Missing encounter as FHIR JSON",
+			id:                  "missing",
+			accept:              "application/fhir+json",
+			expectedStatus:      http.StatusNotFound,
+			expectedContentType: "application/fhir+json",
+		},
+		{
+			name:                "Existing encounter as FHIR JSON",
+			id:                  "enc-1",
+			accept:              "application/fhir+json",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/fhir+json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTenantRequest("GET", "/api/get-encounter-tenant/encounters/"+tt.id, "get-encounter-tenant", map[string]string{"id": tt.id})
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rr := httptest.NewRecorder()
+			GetResourceByIDHandler("Encounter")(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.expectedContentType {
+				t.Errorf("Expected Content-Type %q, got %q", tt.expectedContentType, got)
+			}
+			if tt.expectedStatus != http.StatusNotFound {
+				return
+			}
+
+			var outcome struct {
+				ResourceType string `json:"resourceType"`
+				Issue        []struct {
+					Severity    string `json:"severity"`
+					Code        string `json:"code"`
+					Diagnostics string `json:"diagnostics"`
+				} `json:"issue"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&outcome); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if outcome.ResourceType != "OperationOutcome" || len(outcome.Issue) != 1 {
+				t.Fatalf("Unexpected OperationOutcome: %+v", outcome)
+			}
+			issue := outcome.Issue[0]
+			if issue.Severity != "error" || issue.Code != "not-found" || issue.Diagnostics != "Encounter/missing not found" {
+				t.Errorf("Unexpected issue: %+v", issue)
+			}
+		})
+	}
+}
+
+func TestGetResourceByIDHandlerContextErrors(t *testing.T) {
+	registerTestTenant(t, "context-error-tenant", func(msg RequestMessage) ResponseMessage {
+		if msg.ID == "slow" {
+			return ResponseMessage{Error: fmt.Errorf("failed to retrieve resource: %w", context.DeadlineExceeded)}
+		}
+		// The processor sees the request context, which the client has cancelled
+		if err := msg.requestContext().Err(); err != nil {
+			return ResponseMessage{Error: fmt.Errorf("failed to retrieve resource: %w", err)}
+		}
+		return ResponseMessage{Data: map[string]interface{}{"id": msg.ID}}
+	})
+
+	tests := []struct {
+		name           string
+		id             string
+		cancel         bool
+		expectedStatus int
+	}{
+		{name: "Client closed request", id: "enc-1", cancel: true, expectedStatus: statusClientClosedRequest},
+		{name: "Deadline exceeded", id: "slow", expectedStatus: http.StatusGatewayTimeout},
+		{name: "Success", id: "enc-1", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTenantRequest("GET", "/api/context-error-tenant/encounters/"+tt.id, "context-error-tenant", map[string]string{"id": tt.id})
+			if tt.cancel {
+				ctx, cancel := context.WithCancel(req.Context())
+				cancel()
+				req = req.WithContext(ctx)
+			}
+
+			rr := httptest.NewRecorder()
+			GetResourceByIDHandler("Encounter")(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestGetResourceByIDHandlerPoolAtCapacity(t *testing.T) {
+	channels := registerTestTenant(t, "busy-tenant", func(msg RequestMessage) ResponseMessage {
+		return ResponseMessage{Data: map[string]interface{}{"id": msg.ID}}
+	})
+	// Another request holds the only response channel
+	borrowed, err := channels.responsePool.GetChannel(context.Background())
+	if err != nil {
+		t.Fatalf("GetChannel() error = %v", err)
+	}
+	defer channels.responsePool.ReturnChannel(borrowed)
+
+	tests := []struct {
+		name           string
+		cancel         bool
+		expectedStatus int
+	}{
+		{name: "Client closed request", cancel: true, expectedStatus: statusClientClosedRequest},
+		{name: "Deadline exceeded", expectedStatus: http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTenantRequest("GET", "/api/busy-tenant/encounters/enc-1", "busy-tenant", map[string]string{"id": "enc-1"})
+			ctx, cancel := context.WithTimeout(req.Context(), 20*time.Millisecond)
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			rr := httptest.NewRecorder()
+			GetResourceByIDHandler("Encounter")(rr, req.WithContext(ctx))
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestGetResourceByIDHandlerElements(t *testing.T) {
+	registerTestTenant(t, "elements-tenant", func(msg RequestMessage) ResponseMessage {
+		return ResponseMessage{Data: map[string]interface{}{"data": map[string]interface{}{
+			"resourceType": "Encounter",
+			"id":           msg.ID,
+			"status":       "finished",
+			"subject":      map[string]interface{}{"reference": "Patient/p1"},
+			"period":       map[string]interface{}{"start": "2025-01-01T10:00:00Z"},
+		}}}
+	})
+
+	tests := []struct {
+		name         string
+		elements     string
+		expectedKeys []string
+	}{
+		{name: "Selected elements", elements: "status,subject", expectedKeys: []string{"id", "resourceType", "status", "subject"}},
+		{name: "Unknown element ignored", elements: "status,unknown", expectedKeys: []string{"id", "resourceType", "status"}},
+		{name: "No elements returns full resource", elements: "", expectedKeys: []string{"id", "period", "resourceType", "status", "subject"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/api/elements-tenant/encounters/enc-1"
+			if tt.elements != "" {
+				path += "?_elements=" + tt.elements
+			}
+			req := newTenantRequest("GET", path, "elements-tenant", map[string]string{"id": "enc-1"})
+
+			rr := httptest.NewRecorder()
+			GetResourceByIDHandler("Encounter")(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+			var response struct {
+				Data map[string]interface{} `json:"data"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			var keys []string
+			for key := range response.Data {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.expectedKeys) {
+				t.Errorf("Expected elements %v, got %v", tt.expectedKeys, keys)
+			}
+		})
+	}
+}
+
+func TestObservationHandlers(t *testing.T) {
+	var received RequestMessage
+	registerTestTenant(t, "observation-tenant", func(msg RequestMessage) ResponseMessage {
+		received = msg
+		if msg.ID != "" {
+			return ResponseMessage{Data: map[string]interface{}{"data": map[string]interface{}{
+				"resourceType": "Observation",
+				"id":           msg.ID,
+				"encounterId":  "enc-1",
+			}}}
+		}
+		return ResponseMessage{Data: map[string]interface{}{"data": []interface{}{}}}
+	})
+
+	t.Run("Get observation", func(t *testing.T) {
+		req := newTenantRequest("GET", "/api/observation-tenant/observations/obs-1", "observation-tenant", map[string]string{"id": "obs-1"})
+		rr := httptest.NewRecorder()
+		GetResourceByIDHandler("Observation")(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if received.Entity != "Observation" || received.ID != "obs-1" {
+			t.Errorf("Expected request for Observation/obs-1, got %s/%s", received.Entity, received.ID)
+		}
+	})
+
+	t.Run("List observations", func(t *testing.T) {
+		received = RequestMessage{}
+		req := newTenantRequest("GET", "/api/observation-tenant/observations?count=10", "observation-tenant", nil)
+		rr := httptest.NewRecorder()
+		ListResourcesHandler("Observation")(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if received.Entity != "Observation" {
+			t.Errorf("Expected list request for Observation, got %q", received.Entity)
+		}
+	})
+}
+
+func TestConditionHandlers(t *testing.T) {
+	var received RequestMessage
+	registerTestTenant(t, "condition-tenant", func(msg RequestMessage) ResponseMessage {
+		received = msg
+		if msg.ID != "" {
+			return ResponseMessage{Data: map[string]interface{}{"data": map[string]interface{}{
+				"resourceType":     "Condition",
+				"id":               msg.ID,
+				"subjectPatientId": "pat-1",
+			}}}
+		}
+		return ResponseMessage{Data: map[string]interface{}{"data": []interface{}{}}}
+	})
+
+	t.Run("Get condition", func(t *testing.T) {
+		req := newTenantRequest("GET", "/api/condition-tenant/conditions/cond-1", "condition-tenant", map[string]string{"id": "cond-1"})
+		rr := httptest.NewRecorder()
+		GetResourceByIDHandler("Condition")(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if received.Entity != "Condition" || received.ID != "cond-1" {
+			t.Errorf("Expected request for Condition/cond-1, got %s/%s", received.Entity, received.ID)
+		}
+	})
+
+	t.Run("List conditions", func(t *testing.T) {
+		received = RequestMessage{}
+		req := newTenantRequest("GET", "/api/condition-tenant/conditions?count=10", "condition-tenant", nil)
+		rr := httptest.NewRecorder()
+		ListResourcesHandler("Condition")(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if received.Entity != "Condition" {
+			t.Errorf("Expected list request for Condition, got %q", received.Entity)
+		}
+	})
+}
+
+func TestMedicationRequestHandlers(t *testing.T) {
+	var received RequestMessage
+	registerTestTenant(t, "medication-tenant", func(msg RequestMessage) ResponseMessage {
+		received = msg
+		if msg.ID != "" {
+			return ResponseMessage{Data: map[string]interface{}{"data": map[string]interface{}{
+				"resourceType":   "MedicationRequest",
+				"id":             msg.ID,
+				"medicationCode": "860975",
+			}}}
+		}
+		return ResponseMessage{Data: map[string]interface{}{"data": []interface{}{}}}
+	})
+
+	t.Run("Get medication request", func(t *testing.T) {
+		req := newTenantRequest("GET", "/api/medication-tenant/medication-requests/med-1", "medication-tenant", map[string]string{"id": "med-1"})
+		rr := httptest.NewRecorder()
+		GetResourceByIDHandler("MedicationRequest")(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if received.Entity != "MedicationRequest" || received.ID != "med-1" {
+			t.Errorf("Expected request for MedicationRequest/med-1, got %s/%s", received.Entity, received.ID)
+		}
+	})
+
+	t.Run("List medication requests", func(t *testing.T) {
+		received = RequestMessage{}
+		req := newTenantRequest("GET", "/api/medication-tenant/medication-requests?count=10", "medication-tenant", nil)
+		rr := httptest.NewRecorder()
+		ListResourcesHandler("MedicationRequest")(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if received.Entity != "MedicationRequest" {
+			t.Errorf("Expected list request for MedicationRequest, got %q", received.Entity)
+		}
+	})
+}
+
+func TestGetEncounterSummaryHandler(t *testing.T) {
+	var received RequestMessage
+	registerTestTenant(t, "summary-tenant", func(msg RequestMessage) ResponseMessage {
+		received = msg
+		if msg.ID == "missing" {
+			return ResponseMessage{Error: errors.New("failed to get encounter summary: resource not found")}
+		}
+		return ResponseMessage{Data: &dal.EncounterSummary{
+			Encounter:     map[string]interface{}{"resourceType": "Encounter", "id": msg.ID},
+			Practitioners: []map[string]interface{}{},
+		}}
+	})
+
+	t.Run("Summary", func(t *testing.T) {
+		req := newTenantRequest("GET", "/api/summary-tenant/encounters/enc-1/summary", "summary-tenant", map[string]string{"id": "enc-1"})
+		rr := httptest.NewRecorder()
+		GetEncounterSummaryHandler(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if received.Entity != "Encounter" || received.ID != "enc-1" || !received.IncludeSummary {
+			t.Errorf("Expected a summary request for Encounter/enc-1, got %+v", received)
+		}
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if body["patient"] != nil {
+			t.Errorf("Expected a null patient, got %v", body["patient"])
+		}
+		if practitioners, ok := body["practitioners"].([]interface{}); !ok || len(practitioners) != 0 {
+			t.Errorf("Expected an empty practitioners list, got %v", body["practitioners"])
+		}
+	})
+
+	t.Run("Encounter not found", func(t *testing.T) {
+		req := newTenantRequest("GET", "/api/summary-tenant/encounters/missing/summary", "summary-tenant", map[string]string{"id": "missing"})
+		rr := httptest.NewRecorder()
+		GetEncounterSummaryHandler(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+func TestLivenessHandler(t *testing.T) {
+	// Leak goroutines blocked on a channel, released when the test ends
+	release := make(chan struct{})
+	t.Cleanup(func() {
+		close(release)
+	})
+
+	threshold := runtime.NumGoroutine() + 5
+	t.Setenv("LIVENESS_GOROUTINE_THRESHOLD", strconv.Itoa(threshold))
+
+	probe := func() (int, map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		LivenessHandler(rr, httptest.NewRequest("GET", LivenessPath, nil))
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return rr.Code, body
+	}
+
+	if code, body := probe(); code != http.StatusOK || body["status"] != "ok" {
+		t.Fatalf("Expected healthy probe before the leak, got %d %v", code, body)
+	}
+
+	for i := 0; i < 10; i++ {
+		go func() {
+			<-release
+		}()
+	}
+
+	code, body := probe()
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status %d after the leak, got %d", http.StatusServiceUnavailable, code)
+	}
+	if body["status"] != "unhealthy" || body["threshold"] != float64(threshold) {
+		t.Errorf("Unexpected body: %v", body)
+	}
+	if goroutines, _ := body["goroutines"].(float64); int(goroutines) <= threshold {
+		t.Errorf("Expected goroutines above %d, got %v", threshold, body["goroutines"])
+	}
+}
+
+func TestLivenessGoroutineThreshold(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected int
+	}{
+		{name: "Default", value: "", expected: 1000},
+		{name: "Custom", value: "250", expected: 250},
+		{name: "Invalid", value: "many", expected: 1000},
+		{name: "Zero", value: "0", expected: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LIVENESS_GOROUTINE_THRESHOLD", tt.value)
+			if got := livenessGoroutineThreshold(); got != tt.expected {
+				t.Errorf("Expected threshold %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWarmUpTenantHandler(t *testing.T) {
+	origWarmUp := warmUpTenant
+	t.Cleanup(func() {
+		warmUpTenant = origWarmUp
+	})
+
+	t.Run("Sync warm-up blocks until ready", func(t *testing.T) {
+		var warmed string
+		warmUpTenant = func(ctx context.Context, tenantID string) error {
+			warmed = tenantID
+			return nil
+		}
+
+		rr := httptest.NewRecorder()
+		WarmUpTenantHandler(rr, newTenantRequest("POST", "/api/tenant1/warm-up-tenant", "tenant1", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if warmed != "tenant1" {
+			t.Errorf("Expected tenant1 to be warmed up, got %q", warmed)
+		}
+		var body map[string]string
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if body["status"] != "warm" {
+			t.Errorf("Expected status warm, got %q", body["status"])
+		}
+	})
+
+	t.Run("Sync warm-up failure", func(t *testing.T) {
+		warmUpTenant = func(ctx context.Context, tenantID string) error {
+			return errors.New("scope copy failed")
+		}
+
+		rr := httptest.NewRecorder()
+		WarmUpTenantHandler(rr, newTenantRequest("POST", "/api/tenant1/warm-up-tenant", "tenant1", nil))
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+	})
+
+	t.Run("Async warm-up returns immediately", func(t *testing.T) {
+		release := make(chan struct{})
+		warmed := make(chan string, 1)
+		warmUpTenant = func(ctx context.Context, tenantID string) error {
+			<-release
+			warmed <- tenantID
+			return nil
+		}
+
+		rr := httptest.NewRecorder()
+		WarmUpTenantHandler(rr, newTenantRequest("POST", "/api/tenant1/warm-up-tenant?async=true", "tenant1", nil))
+
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("Expected status %d, got %d", http.StatusAccepted, rr.Code)
+		}
+		if got := rr.Header().Get("Retry-After"); got != "30" {
+			t.Errorf("Expected Retry-After 30, got %q", got)
+		}
+		var body map[string]string
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if body["status"] != "warming" || body["checkAt"] != "/api/tenant1/ingestion-status" {
+			t.Errorf("Unexpected body: %v", body)
+		}
+
+		// The warm-up keeps running after the response
+		close(release)
+		select {
+		case tenantID := <-warmed:
+			if tenantID != "tenant1" {
+				t.Errorf("Expected tenant1 to be warmed up, got %q", tenantID)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Background warm-up did not run")
+		}
+	})
+
+	t.Run("Failed warm-ups are recorded by outcome", func(t *testing.T) {
+		outcomes := []struct {
+			status string
+			err    error
+		}{
+			{status: "timeout", err: fmt.Errorf("failed to ensure tenant scope: %w", context.DeadlineExceeded)},
+			{status: "error", err: errors.New("scope copy failed")},
+		}
+		for _, outcome := range outcomes {
+			warmUpTenant = func(ctx context.Context, tenantID string) error {
+				return outcome.err
+			}
+			before := promtestutil.ToFloat64(metrics.TenantWarmUpTotal.WithLabelValues(outcome.status))
+
+			WarmUpTenantHandler(httptest.NewRecorder(), newTenantRequest("POST", "/api/tenant1/warm-up-tenant", "tenant1", nil))
+
+			if got := promtestutil.ToFloat64(metrics.TenantWarmUpTotal.WithLabelValues(outcome.status)); got != before+1 {
+				t.Errorf("Expected a %s warm-up to be recorded, got %v", outcome.status, got-before)
+			}
+		}
+	})
+}
+
+func TestDeleteTenantHandler(t *testing.T) {
+	origDelete := deleteTenantScope
+	t.Cleanup(func() {
+		deleteTenantScope = origDelete
+		InvalidateTenantScopeCheck("tenant1")
+		deletedTenants.Delete("tenant1")
+	})
+
+	t.Run("Deletes the tenant scope", func(t *testing.T) {
+		var deleted string
+		deleteTenantScope = func(ctx context.Context, tenantID string) error {
+			deleted = tenantID
+			return nil
+		}
+		markTenantScopeVerified("tenant1")
+
+		rr := httptest.NewRecorder()
+		DeleteTenantHandler(rr, newTenantRequest("DELETE", "/api/tenant1", "tenant1", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if deleted != "tenant1" {
+			t.Errorf("Expected tenant1 to be deleted, got %q", deleted)
+		}
+		if isTenantScopeRecentlyVerified("tenant1") {
+			t.Errorf("Expected the scope check to be invalidated")
+		}
+		var body map[string]string
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if body["status"] != "deleted" {
+			t.Errorf("Expected status deleted, got %q", body["status"])
+		}
+	})
+
+	t.Run("Protected scope", func(t *testing.T) {
+		deleteTenantScope = func(ctx context.Context, tenantID string) error {
+			return fmt.Errorf("%w: %q", dal.ErrProtectedScope, tenantID)
+		}
+
+		rr := httptest.NewRecorder()
+		DeleteTenantHandler(rr, newTenantRequest("DELETE", "/api/_default", "_default", nil))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Deletion failure", func(t *testing.T) {
+		deleteTenantScope = func(ctx context.Context, tenantID string) error {
+			return errors.New("drop scope failed")
+		}
+
+		rr := httptest.NewRecorder()
+		DeleteTenantHandler(rr, newTenantRequest("DELETE", "/api/tenant1", "tenant1", nil))
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+	})
+}
+
+func TestDeleteTenantHandlerOffboardsTenant(t *testing.T) {
+	tenantID := "offboarded-tenant"
+	countTenantWorkers(t)
+	origDelete, origEnsure, origRestore := deleteTenantScope, ensureTenantScope, restoreTenant
+	t.Cleanup(func() {
+		deleteTenantScope, ensureTenantScope, restoreTenant = origDelete, origEnsure, origRestore
+		deletedTenants.Delete(tenantID)
+		InvalidateTenantScopeCheck(tenantID)
+		CleanupTenantChannels(tenantID)
+	})
+
+	deleteTenantScope = func(ctx context.Context, tenantID string) error { return nil }
+	var ensured int
+	ensureTenantScope = func(ctx context.Context, tenantID string) error {
+		ensured++
+		return nil
+	}
+	restoreTenant = func(ctx context.Context, tenantID string) error { return nil }
+
+	channels := AutoWarmUpTenant(tenantID)
+	markTenantScopeVerified(tenantID)
+
+	rr := httptest.NewRecorder()
+	DeleteTenantHandler(rr, newTenantRequest("DELETE", "/api/"+tenantID, tenantID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	// The channels are removed and their in-flight work cancelled
+	if _, exists := GetTenantChannels(tenantID); exists {
+		t.Error("Expected the tenant channels to be removed")
+	}
+	if channels.tenantContext().Err() == nil {
+		t.Error("Expected the tenant context to be cancelled")
+	}
+
+	// A later request is refused instead of re-creating the scope from DefaultScope
+	handler := TenantChannelMiddleware(GetResourceByIDHandler("Encounter"))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newTenantRequest("GET", "/api/"+tenantID+"/encounters/1", tenantID, map[string]string{"id": "1"}))
+	if rr.Code != http.StatusGone {
+		t.Errorf("Expected status %d, got %d", http.StatusGone, rr.Code)
+	}
+	if ensured != 0 {
+		t.Errorf("Expected the deleted tenant scope not to be ensured, got %d calls", ensured)
+	}
+	if _, exists := GetTenantChannels(tenantID); exists {
+		t.Error("Expected the deleted tenant not to be warmed up again")
+	}
+
+	// An explicit warm-up restores the tenant
+	if err := warmUpTenant(context.Background(), tenantID); err != nil {
+		t.Fatalf("warmUpTenant() error = %v", err)
+	}
+	if ensured != 1 || !IsTenantWarm(tenantID) {
+		t.Errorf("Expected the restored tenant to be ensured and warm, got %d calls", ensured)
+	}
+}
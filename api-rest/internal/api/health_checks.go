@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"stealthcompany.com/api-rest/internal/dal"
+)
+
+// healthCheckTimeout bounds every dependency check of the health endpoint
+const healthCheckTimeout = 3 * time.Second
+
+// healthCheck is a dependency check of the health endpoint.
+// A failing critical check makes the API unhealthy since it cannot serve requests without it.
+type healthCheck struct {
+	name     string
+	critical bool
+	check    func(ctx context.Context) error
+}
+
+// healthCheckResult is the outcome of one healthCheck
+type healthCheckResult struct {
+	name     string
+	critical bool
+	err      error
+}
+
+// healthChecks are the dependency checks run by HealthHandler (overridable in tests)
+var healthChecks = []healthCheck{
+	{name: "couchbase", critical: true, check: checkCouchbase},
+	{name: "fhir_server", check: checkFHIRServer},
+}
+
+// fhirTenantHealthChecks returns a fhir_server:{tenant} check for every tenant listed in FHIR_TENANT_CONFIG_FILE,
+// since those tenants are ingested from their own FHIR server; an unreadable file fails a fhir_tenant_config check
+func fhirTenantHealthChecks() []healthCheck {
+	servers, err := dal.FHIRTenantServers()
+	if err != nil {
+		return []healthCheck{{name: "fhir_tenant_config", check: func(ctx context.Context) error { return err }}}
+	}
+
+	checks := make([]healthCheck, 0, len(servers))
+	for _, tenantID := range slices.Sorted(maps.Keys(servers)) {
+		baseURL := strings.TrimSuffix(servers[tenantID], "/")
+		checks = append(checks, healthCheck{
+			name:  "fhir_server:" + tenantID,
+			check: func(ctx context.Context) error { return checkFHIRServerAt(ctx, baseURL) },
+		})
+	}
+	return checks
+}
+
+// runHealthChecks runs the checks concurrently and returns their results in order.
+// A check that does not return within healthCheckTimeout fails with the context error.
+func runHealthChecks(ctx context.Context, checks []healthCheck) []healthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	results := make([]healthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, hc := range checks {
+		wg.Add(1)
+		go func(i int, hc healthCheck) {
+			defer wg.Done()
+
+			done := make(chan error, 1)
+			go func() {
+				done <- hc.check(ctx)
+			}()
+
+			results[i] = healthCheckResult{name: hc.name, critical: hc.critical}
+			select {
+			case err := <-done:
+				results[i].err = err
+			case <-ctx.Done():
+				results[i].err = ctx.Err()
+			}
+		}(i, hc)
+	}
+	wg.Wait()
+	return results
+}
+
+// checkCouchbase pings the cluster with a pooled connection
+func checkCouchbase(ctx context.Context) error {
+	conn, err := dal.GetConnOrGenConn()
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer dal.ReturnConnection(conn)
+
+	return conn.Ping(ctx)
+}
+
+// fhirBaseURL reads FHIR_BASE_URL, the server fhir-client ingests from
+func fhirBaseURL() string {
+	if value := os.Getenv("FHIR_BASE_URL"); value != "" {
+		return strings.TrimSuffix(value, "/")
+	}
+	return "https://hapi.fhir.org/baseR4"
+}
+
+// checkFHIRServer checks the FHIR server of FHIR_BASE_URL
+func checkFHIRServer(ctx context.Context) error {
+	return checkFHIRServerAt(ctx, fhirBaseURL())
+}
+
+// checkFHIRServerAt sends a HEAD request to the capability statement of the FHIR server at baseURL.
+// Servers that do not allow HEAD (405) are still reachable.
+func checkFHIRServerAt(ctx context.Context, baseURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, baseURL+"/metadata", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode != http.StatusMethodNotAllowed {
+		return fmt.Errorf("FHIR server returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useHealthChecks replaces the dependency checks of the health endpoint for the duration of the test
+func useHealthChecks(t *testing.T, couchbaseErr, fhirErr error) {
+	original := healthChecks
+	healthChecks = []healthCheck{
+		{name: "couchbase", critical: true, check: func(ctx context.Context) error { return couchbaseErr }},
+		{name: "fhir_server", check: func(ctx context.Context) error { return fhirErr }},
+	}
+	t.Cleanup(func() {
+		healthChecks = original
+	})
+}
+
+func TestHealthHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		keycloakURL    string
+		couchbaseErr   error
+		fhirErr        error
+		expectedCode   int
+		expectedStatus string
+	}{
+		{
+			name:           "All dependencies healthy",
+			keycloakURL:    "http://keycloak:8080",
+			expectedCode:   http.StatusOK,
+			expectedStatus: "healthy",
+		},
+		{
+			name:           "FHIR server down",
+			keycloakURL:    "http://keycloak:8080",
+			fhirErr:        errors.New("connection refused"),
+			expectedCode:   http.StatusMultiStatus,
+			expectedStatus: "degraded",
+		},
+		{
+			name:           "Keycloak config missing",
+			expectedCode:   http.StatusMultiStatus,
+			expectedStatus: "degraded",
+		},
+		{
+			name:           "Couchbase down",
+			keycloakURL:    "http://keycloak:8080",
+			couchbaseErr:   errors.New("ping failed"),
+			fhirErr:        errors.New("connection refused"),
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedStatus: "unhealthy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useHealthChecks(t, tt.couchbaseErr, tt.fhirErr)
+
+			rr := httptest.NewRecorder()
+			NewAuthHandlers(&KeycloakConfig{URL: tt.keycloakURL}).HealthHandler(rr, httptest.NewRequest("GET", HealthPath, nil))
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, rr.Code)
+			}
+			var response HealthResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Status != tt.expectedStatus {
+				t.Errorf("Expected status %q, got %q", tt.expectedStatus, response.Status)
+			}
+			for _, service := range []string{"keycloak", "couchbase", "fhir_server"} {
+				if _, ok := response.Services[service]; !ok {
+					t.Errorf("Expected %s in services, got %v", service, response.Services)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckFHIRServer(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		expectError bool
+	}{
+		{name: "Capability statement available", status: http.StatusOK},
+		{name: "HEAD not allowed", status: http.StatusMethodNotAllowed},
+		{name: "Server error", status: http.StatusBadGateway, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodHead || r.URL.Path != "/fhir/metadata" {
+					t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+			t.Setenv("FHIR_BASE_URL", server.URL+"/fhir/")
+
+			err := checkFHIRServer(context.Background())
+			if tt.expectError != (err != nil) {
+				t.Errorf("Expected error %v, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestHealthHandlerFHIRTenantServers(t *testing.T) {
+	useHealthChecks(t, nil, nil)
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	content := `[{"tenantId": "acme", "baseUrl": "` + up.URL + `/"}, {"tenantId": "globex", "baseUrl": "` + down.URL + `"}]`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FHIR_TENANT_CONFIG_FILE", path)
+
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(&KeycloakConfig{URL: "http://keycloak:8080"}).HealthHandler(rr, httptest.NewRequest("GET", HealthPath, nil))
+
+	if rr.Code != http.StatusMultiStatus {
+		t.Errorf("Expected status code %d, got %d", http.StatusMultiStatus, rr.Code)
+	}
+	var response HealthResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if got := response.Services["fhir_server:acme"]; got != "healthy" {
+		t.Errorf("Expected fhir_server:acme to be healthy, got %q", got)
+	}
+	if got := response.Services["fhir_server:globex"]; !strings.HasPrefix(got, "unhealthy") {
+		t.Errorf("Expected fhir_server:globex to be unhealthy, got %q", got)
+	}
+	if got := response.Services["fhir_server"]; got != "healthy" {
+		t.Errorf("Expected the FHIR_BASE_URL server to stay a separate component, got %q", got)
+	}
+}
+
+func TestFHIRTenantHealthChecksInvalidFile(t *testing.T) {
+	t.Setenv("FHIR_TENANT_CONFIG_FILE", filepath.Join(t.TempDir(), "missing.json"))
+
+	checks := fhirTenantHealthChecks()
+	if len(checks) != 1 || checks[0].name != "fhir_tenant_config" || checks[0].critical {
+		t.Fatalf("Expected a non-critical fhir_tenant_config check, got %v", checks)
+	}
+	if err := checks[0].check(context.Background()); err == nil {
+		t.Error("Expected the unreadable tenant config file to fail the check")
+	}
+}
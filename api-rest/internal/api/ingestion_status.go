@@ -3,6 +3,8 @@ package api
 import (
 	"context"
 	"fmt"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -20,6 +22,7 @@ func WaitForFHIRIngestion(ctx context.Context) error {
 	defer dal.ReturnConnection(conn) // Return connection to pool
 
 	ingestionModel := dal.NewIngestionStatusModel(conn)
+	minCounts := getMinResourceCounts()
 
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
@@ -29,7 +32,7 @@ func WaitForFHIRIngestion(ctx context.Context) error {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-ticker.C:
-			ready, err := ingestionModel.IsDefaultScopeIngestionReady(ctx)
+			ready, err := ingestionModel.IsDefaultScopeIngestionReady(ctx, minCounts)
 			if err != nil {
 				log.Error().Err(err).Msg("Error checking ingestion status")
 				continue
@@ -44,3 +47,27 @@ func WaitForFHIRIngestion(ctx context.Context) error {
 		}
 	}
 }
+
+// getMinResourceCounts reads the minimum ingested counts required per resource type
+func getMinResourceCounts() map[string]int {
+	return map[string]int{
+		"Encounter":    getMinCountFromEnv("FHIR_MIN_ENCOUNTERS"),
+		"Patient":      getMinCountFromEnv("FHIR_MIN_PATIENTS"),
+		"Practitioner": getMinCountFromEnv("FHIR_MIN_PRACTITIONERS"),
+	}
+}
+
+// getMinCountFromEnv parses a minimum count env var, defaulting to 1
+func getMinCountFromEnv(key string) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return 1
+	}
+
+	minCount, err := strconv.Atoi(value)
+	if err != nil || minCount < 0 {
+		log.Warn().Str("key", key).Str("value", value).Msg("Invalid minimum resource count, using 1")
+		return 1
+	}
+	return minCount
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// encodeErrorBody is written when a response cannot be encoded
+const encodeErrorBody = `{"error":"failed to encode response"}` + "\n"
+
+// headerWriteTracker is implemented by response writers that know whether the status was sent
+type headerWriteTracker interface {
+	HeaderWritten() bool
+}
+
+// headerWritten reports whether the status was already sent, assuming it was when the writer cannot tell
+func headerWritten(w http.ResponseWriter) bool {
+	if tracker, ok := w.(headerWriteTracker); ok {
+		return tracker.HeaderWritten()
+	}
+	return true
+}
+
+// jsonEncode marshals v before writing it, so an encoding error never leaves a truncated body.
+// On error it answers 500 when the status has not been sent yet, otherwise it only logs.
+func jsonEncode(w http.ResponseWriter, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		if headerWritten(w) {
+			log.Error().Err(err).Msg("Failed to encode JSON response after status was sent")
+			return err
+		}
+		writeEncodeError(w, err)
+		return err
+	}
+
+	_, err = w.Write(append(body, '\n'))
+	return err
+}
+
+// writeJSON marshals v and writes it with status, answering 500 instead when v cannot be encoded
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeEncodeError(w, err)
+		return err
+	}
+
+	w.WriteHeader(status)
+	_, err = w.Write(append(body, '\n'))
+	return err
+}
+
+// writeEncodeError answers 500 for a response that could not be encoded
+func writeEncodeError(w http.ResponseWriter, err error) {
+	log.Error().Err(err).Msg("Failed to encode JSON response")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte(encodeErrorBody))
+}
+
+// jsonListStream writes a list response, {"data":[...],"pagination":{...}}, one data element at a time
+// and flushes after each element, so clients consume a page as it is read from the database
+type jsonListStream struct {
+	w        http.ResponseWriter
+	started  bool
+	elements int
+}
+
+// Started reports whether the status and the start of the body were sent
+func (s *jsonListStream) Started() bool {
+	return s.started
+}
+
+// start sends the status and opens the data array
+func (s *jsonListStream) start() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", "application/json")
+	s.w.WriteHeader(http.StatusOK)
+	_, err := s.w.Write([]byte(`{"data":[`))
+	return err
+}
+
+// WriteElement marshals v and writes it as the next element of the data array
+func (s *jsonListStream) WriteElement(v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := s.start(); err != nil {
+		return err
+	}
+	if s.elements > 0 {
+		body = append([]byte{','}, body...)
+	}
+	if _, err := s.w.Write(body); err != nil {
+		return err
+	}
+	s.elements++
+
+	// Writers that cannot flush still get the element, only later
+	_ = http.NewResponseController(s.w).Flush()
+	return nil
+}
+
+// Finish closes the data array and writes the pagination, answering 500 instead when nothing was sent
+// and the pagination cannot be encoded
+func (s *jsonListStream) Finish(pagination interface{}) error {
+	body, err := json.Marshal(pagination)
+	if err != nil {
+		if !s.started {
+			writeEncodeError(s.w, err)
+			return err
+		}
+		log.Error().Err(err).Msg("Failed to encode pagination after list was streamed")
+		return err
+	}
+	if err := s.start(); err != nil {
+		return err
+	}
+	_, err = s.w.Write(append(append([]byte(`],"pagination":`), body...), "}\n"...))
+	return err
+}
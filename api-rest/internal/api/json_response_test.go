@@ -0,0 +1,129 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stealthcompany.com/api-rest/internal/metrics"
+)
+
+func TestWriteJSON(t *testing.T) {
+	tests := []struct {
+		name           string
+		value          interface{}
+		expectedStatus int
+		expectedBody   string
+		wantErr        bool
+	}{
+		{
+			name:           "Serializable value",
+			value:          map[string]string{"status": "ok"},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"status":"ok"}` + "\n",
+		},
+		{
+			name:           "Function value",
+			value:          map[string]interface{}{"callback": func() {}},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   encodeErrorBody,
+			wantErr:        true,
+		},
+		{
+			name:           "Channel value",
+			value:          map[string]interface{}{"updates": make(chan int)},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   encodeErrorBody,
+			wantErr:        true,
+		},
+		{
+			name:           "Infinite float",
+			value:          map[string]interface{}{"ratio": math.Inf(1)},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   encodeErrorBody,
+			wantErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			err := writeJSON(rr, http.StatusCreated, tt.value)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestJSONEncode(t *testing.T) {
+	tests := []struct {
+		name           string
+		writeStatus    bool
+		value          interface{}
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Serializable value",
+			value:          map[string]string{"status": "ok"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"ok"}` + "\n",
+		},
+		{
+			name:           "Non-serializable value before status",
+			value:          map[string]interface{}{"callback": func() {}},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   encodeErrorBody,
+		},
+		{
+			name:           "Non-serializable value after status",
+			writeStatus:    true,
+			value:          map[string]interface{}{"callback": func() {}},
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// MetricsMiddleware wraps the writer with one that tracks whether the status was sent
+			handler := metrics.MetricsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.writeStatus {
+					w.WriteHeader(http.StatusAccepted)
+				}
+				jsonEncode(w, tt.value)
+			}))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestJSONEncodeUntrackedWriter(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	if err := jsonEncode(rr, make(chan int)); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	// Without a tracking writer the status is assumed sent, so nothing is written
+	if rr.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got %q", rr.Body.String())
+	}
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultJWKSCacheTTL is how long Keycloak signing keys are cached when neither KEYCLOAK_JWKS_CACHE_TTL
+// nor JWKS_CACHE_TTL_SECONDS is set
+const DefaultJWKSCacheTTL = 5 * time.Minute
+
+// jwksMinRefreshInterval limits forced refreshes and retries of failed fetches,
+// so tokens with unknown key IDs or an unreachable Keycloak can't flood it
+var jwksMinRefreshInterval = 10 * time.Second
+
+// errUnknownSigningKey is returned when no cached key matches the key ID of a token
+var errUnknownSigningKey = errors.New("unknown signing key")
+
+// GetJWKSCacheTTL reads KEYCLOAK_JWKS_CACHE_TTL as a duration such as "5m", falling back to
+// JWKS_CACHE_TTL_SECONDS and then to DefaultJWKSCacheTTL
+func GetJWKSCacheTTL() time.Duration {
+	if value := os.Getenv("KEYCLOAK_JWKS_CACHE_TTL"); value != "" {
+		if ttl, err := time.ParseDuration(value); err == nil && ttl > 0 {
+			return ttl
+		}
+	}
+	if value := os.Getenv("JWKS_CACHE_TTL_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return DefaultJWKSCacheTTL
+}
+
+// jsonWebKey is an entry of a JWKS document; only RSA signing keys are used
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKSCache fetches and caches the public keys Keycloak signs tokens with
+type JWKSCache struct {
+	url        string
+	ttl        time.Duration
+	httpClient *http.Client
+	// fetches shares one JWKS download between the requests waiting for it
+	fetches singleflight.Group
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	lastErr     error
+	refreshing  bool
+}
+
+// NewJWKSCache creates a key cache for the JWKS endpoint at url, refreshed after ttl
+func NewJWKSCache(url string, ttl time.Duration) *JWKSCache {
+	if ttl <= 0 {
+		ttl = DefaultJWKSCacheTTL
+	}
+	return &JWKSCache{
+		url:        url,
+		ttl:        ttl,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Key returns the public key with the given key ID. Keys older than the TTL keep being served while
+// they are re-fetched in the background. With refresh set, the keys are re-fetched right away (key rotation).
+// Fetches run outside the lock, at most one at a time and once per jwksMinRefreshInterval: within it,
+// a cache without keys returns the error of the last fetch.
+func (c *JWKSCache) Key(kid string, refresh bool) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	sinceAttempt := time.Since(c.attemptedAt)
+	fetch := (c.keys == nil || refresh) && sinceAttempt >= jwksMinRefreshInterval
+	if c.keys == nil && !fetch {
+		err := c.lastErr
+		c.mu.Unlock()
+		return nil, fmt.Errorf("JWKS unavailable, last fetch failed: %w", err)
+	}
+	retryAllowed := c.lastErr == nil || sinceAttempt >= jwksMinRefreshInterval
+	if !fetch && time.Since(c.fetchedAt) >= c.ttl && retryAllowed && !c.refreshing {
+		c.refreshing = true
+		go c.refreshInBackground()
+	}
+	c.mu.Unlock()
+
+	if fetch {
+		if err := c.load(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	key, ok := c.keys[kid]
+	c.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q", errUnknownSigningKey, kid)
+	}
+	return key, nil
+}
+
+// Keys returns the cached keys while they are within the TTL, and downloads them otherwise
+func (c *JWKSCache) Keys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	c.mu.Lock()
+	fresh := c.keys != nil && time.Since(c.fetchedAt) < c.ttl
+	c.mu.Unlock()
+
+	if !fresh {
+		if err := c.load(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make(map[string]*rsa.PublicKey, len(c.keys))
+	for kid, key := range c.keys {
+		keys[kid] = key
+	}
+	return keys, nil
+}
+
+// load fetches the keys, sharing the fetch already in flight, and stores them or the fetch error.
+// The shared fetch is bounded by the HTTP client timeout; ctx only stops this caller from waiting on it.
+func (c *JWKSCache) load(ctx context.Context) error {
+	result := c.fetches.DoChan("jwks", func() (interface{}, error) {
+		keys, err := c.fetch(context.WithoutCancel(ctx))
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.attemptedAt = time.Now()
+		c.lastErr = err
+		if err != nil {
+			return nil, err
+		}
+		c.keys = keys
+		c.fetchedAt = c.attemptedAt
+		return nil, nil
+	})
+
+	select {
+	case res := <-result:
+		return res.Err
+	case <-ctx.Done():
+		return fmt.Errorf("failed to fetch JWKS: %w", ctx.Err())
+	}
+}
+
+// refreshInBackground re-fetches expired keys off the request path. On failure the previous keys are
+// kept and a request after jwksMinRefreshInterval tries again.
+func (c *JWKSCache) refreshInBackground() {
+	err := c.load(context.Background())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refreshing = false
+	if err != nil {
+		log.Warn().Err(err).Str("url", c.url).Msg("Background JWKS refresh failed, keeping cached keys")
+	}
+}
+
+// fetch downloads the JWKS document and decodes its RSA signing keys
+func (c *JWKSCache) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	if c.url == "" {
+		return nil, errors.New("JWKS URL not configured, set KEYCLOAK_URL and KEYCLOAK_REALM")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(document.Keys))
+	for _, jwk := range document.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := jwk.rsaPublicKey()
+		if err != nil {
+			log.Warn().Err(err).Str("kid", jwk.Kid).Msg("Skipping invalid JWKS key")
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	log.Debug().Int("keys", len(keys)).Str("url", c.url).Msg("JWKS fetched")
+	return keys, nil
+}
+
+// rsaPublicKey decodes the base64url modulus and exponent of an RSA key
+func (jwk jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %w", err)
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() <= 1 || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
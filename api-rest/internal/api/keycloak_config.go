@@ -1,9 +1,12 @@
 package api
 
 import (
+	"context"
+	"crypto/rsa"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -19,6 +22,9 @@ type KeycloakConfig struct {
 	AdminPassword string
 	TokenEndpoint string
 	JWKSURL       string
+
+	jwksOnce sync.Once
+	jwks     *JWKSCache
 }
 
 // NewKeycloakConfig loads Keycloak configuration from environment variables
@@ -43,6 +49,20 @@ func NewKeycloakConfig() (*KeycloakConfig, error) {
 	return config, nil
 }
 
+// JWKS returns the signing key cache of the realm, shared by FetchJWKS and the keycloak-* strategies
+func (kc *KeycloakConfig) JWKS() *JWKSCache {
+	kc.jwksOnce.Do(func() {
+		kc.jwks = NewJWKSCache(kc.JWKSURL, GetJWKSCacheTTL())
+	})
+	return kc.jwks
+}
+
+// FetchJWKS returns the signing keys of the realm by key ID, downloading them when the cached ones
+// are older than KEYCLOAK_JWKS_CACHE_TTL
+func (kc *KeycloakConfig) FetchJWKS(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	return kc.JWKS().Keys(ctx)
+}
+
 // GetAdminToken fetches an admin access token from Keycloak
 func (kc *KeycloakConfig) GetAdminToken() (string, error) {
 	log.Warn().Msg("Using dummy admin token. Implement actual Keycloak admin token retrieval for production.")
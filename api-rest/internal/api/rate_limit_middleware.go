@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"stealthcompany.com/api-rest/internal/dal"
+)
+
+// Rate limit defaults, see RATE_LIMIT_REQUESTS_PER_MINUTE and RATE_LIMIT_WINDOW_SECONDS
+const (
+	DefaultRateLimitRequestsPerMinute = 600
+	DefaultRateLimitWindow            = 60 * time.Second
+)
+
+// incrementRateLimitCounter counts a request in the Couchbase counter document of its window (overridable in tests)
+var incrementRateLimitCounter = func(ctx context.Context, key string, window time.Duration) (uint64, error) {
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
+	if err != nil {
+		return 0, err
+	}
+	defer dal.ReturnConnection(conn)
+
+	return dal.NewRateLimitModel(conn).Increment(ctx, key, window)
+}
+
+// rateLimitNow returns the current time (overridable in tests)
+var rateLimitNow = time.Now
+
+// RateLimitMiddleware answers 429 once a tenant made more than limit requests in the current window.
+// Windows are aligned to multiples of window, and Retry-After tells the client when the next one starts.
+// It needs the tenant ID set by the authentication middleware; requests without one are not limited,
+// and requests are let through when the counter cannot be read so Couchbase hiccups do not reject traffic.
+func RateLimitMiddleware(limit int, window time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 || window <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenantID, err := GetTenantFromRequest(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			now := rateLimitNow()
+			windowStart := now.Truncate(window)
+			count, err := incrementRateLimitCounter(r.Context(), dal.RateLimitKey(tenantID, windowStart), window)
+			if err != nil {
+				log.Ctx(r.Context()).Warn().
+					Err(err).
+					Str("tenant", tenantID).
+					Msg("Failed to count request for rate limiting, letting it through")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if count > uint64(limit) {
+				retryAfter := int(math.Ceil(windowStart.Add(window).Sub(now).Seconds()))
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				log.Ctx(r.Context()).Warn().
+					Str("tenant", tenantID).
+					Uint64("count", count).
+					Int("limit", limit).
+					Msg("Tenant rate limit exceeded")
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate limit exceeded"})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetRateLimitWindow reads RATE_LIMIT_WINDOW_SECONDS from the environment
+func GetRateLimitWindow() time.Duration {
+	value := os.Getenv("RATE_LIMIT_WINDOW_SECONDS")
+	if value == "" {
+		return DefaultRateLimitWindow
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		log.Warn().
+			Str("value", value).
+			Dur("default", DefaultRateLimitWindow).
+			Msg("Invalid RATE_LIMIT_WINDOW_SECONDS, using default")
+		return DefaultRateLimitWindow
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetRateLimitRequests reads RATE_LIMIT_REQUESTS_PER_MINUTE and scales it to the requests allowed per window.
+// Zero disables rate limiting.
+func GetRateLimitRequests(window time.Duration) int {
+	perMinute := DefaultRateLimitRequestsPerMinute
+	if value := os.Getenv("RATE_LIMIT_REQUESTS_PER_MINUTE"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			log.Warn().
+				Str("value", value).
+				Int("default", DefaultRateLimitRequestsPerMinute).
+				Msg("Invalid RATE_LIMIT_REQUESTS_PER_MINUTE, using default")
+		} else {
+			perMinute = parsed
+		}
+	}
+	if perMinute == 0 {
+		return 0
+	}
+
+	return int(math.Max(1, math.Ceil(float64(perMinute)*window.Minutes())))
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// useRateLimitCounter replaces the Couchbase counter with an in-memory one and fixes the clock
+func useRateLimitCounter(t *testing.T, now time.Time, counterErr error) map[string]uint64 {
+	counts := make(map[string]uint64)
+	originalIncrement, originalNow := incrementRateLimitCounter, rateLimitNow
+	incrementRateLimitCounter = func(ctx context.Context, key string, window time.Duration) (uint64, error) {
+		if counterErr != nil {
+			return 0, counterErr
+		}
+		counts[key]++
+		return counts[key], nil
+	}
+	rateLimitNow = func() time.Time { return now }
+	t.Cleanup(func() {
+		incrementRateLimitCounter, rateLimitNow = originalIncrement, originalNow
+	})
+	return counts
+}
+
+// rateLimitedRequest sends a tenant request through the middleware
+func rateLimitedRequest(handler http.Handler, tenantID string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newTenantRequest("GET", "/api/"+tenantID+"/encounters", tenantID, nil))
+	return rr
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	// 45 seconds into the window, so the next one starts in 15 seconds
+	windowStart := time.Unix(1700000000, 0).Truncate(time.Minute)
+	counts := useRateLimitCounter(t, windowStart.Add(45*time.Second+200*time.Millisecond), nil)
+
+	handler := RateLimitMiddleware(2, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 2; i++ {
+		if rr := rateLimitedRequest(handler, "tenant1"); rr.Code != http.StatusOK {
+			t.Fatalf("Expected request %d within the limit to pass, got %d", i+1, rr.Code)
+		}
+	}
+
+	rr := rateLimitedRequest(handler, "tenant1")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d over the limit, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	// 14.8 seconds left in the window are rounded up
+	if retryAfter := rr.Header().Get("Retry-After"); retryAfter != "15" {
+		t.Errorf("Expected Retry-After 15, got %q", retryAfter)
+	}
+
+	// Other tenants have their own counter
+	if rr := rateLimitedRequest(handler, "tenant2"); rr.Code != http.StatusOK {
+		t.Errorf("Expected another tenant to pass, got %d", rr.Code)
+	}
+
+	key := "ratelimit/tenant1/" + strconv.FormatInt(windowStart.Unix(), 10)
+	if counts[key] != 3 {
+		t.Errorf("Expected 3 requests counted under %s, got %v", key, counts)
+	}
+}
+
+func TestRateLimitMiddlewareLetsRequestsThrough(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		counterErr error
+		tenantID   string
+	}{
+		{name: "Disabled", limit: 0, tenantID: "tenant1"},
+		{name: "Counter unavailable", limit: 1, counterErr: errors.New("couchbase down"), tenantID: "tenant1"},
+		{name: "No tenant", limit: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useRateLimitCounter(t, time.Now(), tt.counterErr)
+			handler := RateLimitMiddleware(tt.limit, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			for i := 0; i < 3; i++ {
+				rr := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", "/api/tenant1/encounters", nil)
+				if tt.tenantID != "" {
+					req = newTenantRequest("GET", "/api/tenant1/encounters", tt.tenantID, nil)
+				}
+				handler.ServeHTTP(rr, req)
+				if rr.Code != http.StatusOK {
+					t.Fatalf("Expected request %d to pass, got %d", i+1, rr.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestGetRateLimitRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		perMinute string
+		window    time.Duration
+		expected  int
+	}{
+		{name: "Default per minute", window: time.Minute, expected: DefaultRateLimitRequestsPerMinute},
+		{name: "Scaled to a shorter window", perMinute: "120", window: 10 * time.Second, expected: 20},
+		{name: "At least one request", perMinute: "1", window: time.Second, expected: 1},
+		{name: "Zero disables", perMinute: "0", window: time.Minute, expected: 0},
+		{name: "Invalid uses default", perMinute: "many", window: time.Minute, expected: DefaultRateLimitRequestsPerMinute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RATE_LIMIT_REQUESTS_PER_MINUTE", tt.perMinute)
+			if got := GetRateLimitRequests(tt.window); got != tt.expected {
+				t.Errorf("GetRateLimitRequests() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
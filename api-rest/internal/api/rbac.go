@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// Permission is a Keycloak realm role a route requires
+type Permission string
+
+// Realm roles, each granting the permissions of the roles before it
+const (
+	// PermissionReader allows the read-only (GET) routes
+	PermissionReader Permission = "reader"
+	// PermissionReviewer also allows creating and removing reviews
+	PermissionReviewer Permission = "reviewer"
+	// PermissionAdmin allows every route, including tenant warm-up
+	PermissionAdmin Permission = "admin"
+)
+
+// rolePermissions lists the permissions granted by each realm role
+var rolePermissions = map[Permission][]Permission{
+	PermissionReader:   {PermissionReader},
+	PermissionReviewer: {PermissionReader, PermissionReviewer},
+	PermissionAdmin:    {PermissionReader, PermissionReviewer, PermissionAdmin},
+}
+
+// realmRoles returns the realm roles of a token, read from realm_access.roles
+func realmRoles(claims *JWTClaims) []string {
+	roles, _ := claims.RealmAccess["roles"].([]interface{})
+
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if name, ok := role.(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// hasPermission checks if any realm role of the token grants perm
+func hasPermission(claims *JWTClaims, perm Permission) bool {
+	for _, role := range realmRoles(claims) {
+		for _, granted := range rolePermissions[Permission(role)] {
+			if granted == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RequirePermission answers 403 unless the Keycloak token of the request has a realm role granting perm.
+// Requests authenticated with an API key carry no token; the keys are trusted service credentials and
+// are allowed every route.
+func RequirePermission(perm Permission) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(JWTClaimsKey).(*JWTClaims)
+			if !ok {
+				if GetUsernameFromContext(r.Context()) == APIKeyUsername {
+					next.ServeHTTP(w, r)
+					return
+				}
+				log.Ctx(r.Context()).Warn().Str("path", r.URL.Path).Str("permission", string(perm)).Msg("Permission check without token claims")
+				http.Error(w, ErrPermissionDenied, http.StatusForbidden)
+				return
+			}
+
+			if !hasPermission(claims, perm) {
+				log.Ctx(r.Context()).Warn().
+					Str("username", claims.PreferredUsername).
+					Strs("roles", realmRoles(claims)).
+					Str("permission", string(perm)).
+					Str("method", r.Method).
+					Str("path", r.URL.Path).
+					Msg("Permission denied")
+				http.Error(w, ErrPermissionDenied, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
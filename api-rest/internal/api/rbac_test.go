@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// claimsWithRoles returns token claims with the given realm roles
+func claimsWithRoles(username string, roles ...string) *JWTClaims {
+	realmRoles := make([]interface{}, 0, len(roles))
+	for _, role := range roles {
+		realmRoles = append(realmRoles, role)
+	}
+	return &JWTClaims{
+		Sub:               "user-" + username,
+		PreferredUsername: username,
+		RealmAccess:       map[string]interface{}{"roles": realmRoles},
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name           string
+		permission     Permission
+		claims         *JWTClaims
+		username       string
+		expectedStatus int
+	}{
+		{name: "Reader reads", permission: PermissionReader, claims: claimsWithRoles("u", "reader"), expectedStatus: http.StatusOK},
+		{name: "Reader cannot review", permission: PermissionReviewer, claims: claimsWithRoles("u", "reader"), expectedStatus: http.StatusForbidden},
+		{name: "Reviewer reads", permission: PermissionReader, claims: claimsWithRoles("u", "reviewer"), expectedStatus: http.StatusOK},
+		{name: "Reviewer reviews", permission: PermissionReviewer, claims: claimsWithRoles("u", "reviewer"), expectedStatus: http.StatusOK},
+		{name: "Reviewer cannot warm up", permission: PermissionAdmin, claims: claimsWithRoles("u", "reviewer"), expectedStatus: http.StatusForbidden},
+		{name: "Admin warms up", permission: PermissionAdmin, claims: claimsWithRoles("u", "offline_access", "admin"), expectedStatus: http.StatusOK},
+		{name: "No realm roles", permission: PermissionReader, claims: &JWTClaims{PreferredUsername: "u"}, expectedStatus: http.StatusForbidden},
+		{name: "Unknown role", permission: PermissionReader, claims: claimsWithRoles("u", "uma_authorization"), expectedStatus: http.StatusForbidden},
+		{name: "API key", permission: PermissionAdmin, username: APIKeyUsername, expectedStatus: http.StatusOK},
+		{name: "No token", permission: PermissionReader, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RequirePermission(tt.permission)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", "/api/tenant1/encounters", nil)
+			ctx := req.Context()
+			if tt.claims != nil {
+				ctx = context.WithValue(ctx, JWTClaimsKey, tt.claims)
+			}
+			if tt.username != "" {
+				ctx = context.WithValue(ctx, UsernameKey, tt.username)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req.WithContext(ctx))
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestTenantRoutesPermissions(t *testing.T) {
+	keys := newTestKeySet(t)
+	registerTestTenant(t, "rbac-tenant", func(msg RequestMessage) ResponseMessage {
+		return ResponseMessage{Data: map[string]interface{}{"status": "ok"}}
+	})
+
+	r := mux.NewRouter()
+	r.Use(AuthMiddleware(keys.jwks()))
+	registerTenantRoutes(r.PathPrefix("/api/{tenant}").Subrouter())
+
+	tests := []struct {
+		name           string
+		roles          []string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Reader lists encounters", roles: []string{"reader"}, method: "GET", path: "/api/rbac-tenant/encounters", expectedStatus: http.StatusOK},
+		{
+			name:           "Reader cannot request a review",
+			roles:          []string{"reader"},
+			method:         "POST",
+			path:           "/api/rbac-tenant/review-request",
+			body:           `{"entity":"encounter","id":"1"}`,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Reviewer requests a review",
+			roles:          []string{"reviewer"},
+			method:         "POST",
+			path:           "/api/rbac-tenant/review-request",
+			body:           `{"entity":"encounter","id":"1"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{name: "Reviewer cannot warm up the tenant", roles: []string{"reviewer"}, method: "POST", path: "/api/rbac-tenant/warm-up-tenant", expectedStatus: http.StatusForbidden},
+		{name: "Reviewer cannot delete the tenant", roles: []string{"reviewer"}, method: "DELETE", path: "/api/rbac-tenant", expectedStatus: http.StatusForbidden},
+		{name: "Token without roles cannot read", method: "GET", path: "/api/rbac-tenant/encounters", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := keys.sign(t, claimsWithRoles("rbac-tenant", tt.roles...))
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(AuthorizationHeader, BearerPrefix+token)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
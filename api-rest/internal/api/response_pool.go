@@ -1,77 +1,153 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
 )
 
-// ResponseChannel represents a response channel with metadata
+// ErrResponsePoolClosed is the response of a request whose channel is taken from a closed pool
+var ErrResponsePoolClosed = errors.New("response pool closed")
+
+// responseChannelWait is how long GetChannel waits for a free channel, the time handlers wait for their response
+const responseChannelWait = 30 * time.Second
+
+// ResponseChannel is a response channel borrowed from a ResponsePool, registered under its key until returned
 type ResponseChannel struct {
-	ch    chan ResponseMessage
-	key   string
-	inUse bool
+	ch  chan ResponseMessage
+	key string
+
+	// mu is held while a response is sent, so a returned channel, possibly lent again, never gets a late response
+	mu       sync.Mutex
+	returned bool
 }
 
-// ResponsePool manages a pool of response channels
+// ResponsePool lends at most capacity response channels at a time. Requests past the capacity block until
+// a channel is returned, and returned channels are reused instead of allocating one per request.
 type ResponsePool struct {
-	responseChannels map[string]*ResponseChannel
-	responsePool     chan *ResponseChannel
+	channels  sync.Pool
+	inFlight  sync.Map // response key -> *ResponseChannel
+	slots     chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
-// NewResponsePool creates a new response channel pool
-func NewResponsePool(size int) *ResponsePool {
-	responsePool := make(chan *ResponseChannel, size)
-	responseChannels := make(map[string]*ResponseChannel)
-
-	// Pre-create response channels
-	for i := 0; i < size; i++ {
-		key := fmt.Sprintf("resp_%d", i)
-		respCh := &ResponseChannel{
-			ch:    make(chan ResponseMessage, 1),
-			key:   key,
-			inUse: false,
-		}
-		responseChannels[key] = respCh
-		responsePool <- respCh
+// NewResponsePool creates a response channel pool lending at most capacity channels at a time
+func NewResponsePool(capacity int) *ResponsePool {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	slots := make(chan struct{}, capacity)
+	for i := 0; i < capacity; i++ {
+		slots <- struct{}{}
 	}
 
 	return &ResponsePool{
-		responseChannels: responseChannels,
-		responsePool:     responsePool,
+		channels: sync.Pool{
+			New: func() interface{} {
+				return make(chan ResponseMessage, 1)
+			},
+		},
+		slots:  slots,
+		closed: make(chan struct{}),
 	}
 }
 
-// GetChannel gets a response channel from the pool
-func (rp *ResponsePool) GetChannel() *ResponseChannel {
+// GetChannel borrows a response channel under a new key, blocking while capacity channels are borrowed.
+// It gives up when ctx is done or after responseChannelWait, returning an error wrapping context.Canceled
+// or context.DeadlineExceeded. Once the pool is closed the channel already holds an ErrResponsePoolClosed response.
+func (rp *ResponsePool) GetChannel(ctx context.Context) (*ResponseChannel, error) {
+	ctx, cancel := context.WithTimeout(ctx, responseChannelWait)
+	defer cancel()
+
 	select {
-	case respCh := <-rp.responsePool:
-		respCh.inUse = true
-		return respCh
-	default:
-		// Pool exhausted, create a temporary one
-		return &ResponseChannel{
-			ch:    make(chan ResponseMessage, 1),
-			key:   "temp",
-			inUse: true,
-		}
+	case <-rp.slots:
+	case <-rp.closed:
+		// Nothing answers a closed pool, so the caller gets the error instead of waiting for its timeout
+		respCh := &ResponseChannel{ch: make(chan ResponseMessage, 1), key: uuid.NewString()}
+		respCh.ch <- ResponseMessage{Error: ErrResponsePoolClosed}
+		return respCh, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a response channel: %w", ctx.Err())
+	}
+
+	respCh := &ResponseChannel{
+		ch:  rp.channels.Get().(chan ResponseMessage),
+		key: uuid.NewString(),
 	}
+	rp.inFlight.Store(respCh.key, respCh)
+	return respCh, nil
 }
 
-// ReturnChannel returns a response channel to the pool
+// ReturnChannel unregisters a borrowed channel, drops a response left in it and puts it back in the pool.
+// A response sent to its key afterwards, e.g. after the request timed out, is dropped.
 func (rp *ResponsePool) ReturnChannel(respCh *ResponseChannel) {
-	if respCh.key == "temp" {
-		// Don't return temporary channels to pool
+	if _, borrowed := rp.inFlight.LoadAndDelete(respCh.key); !borrowed {
+		// Already returned, or not borrowed from the pool
 		return
 	}
-	respCh.inUse = false
+
+	respCh.release()
+	rp.channels.Put(respCh.ch)
+	rp.slots <- struct{}{}
+}
+
+// GetChannelByKey returns the borrowed channel registered under key
+func (rp *ResponsePool) GetChannelByKey(key string) (*ResponseChannel, bool) {
+	value, exists := rp.inFlight.Load(key)
+	if !exists {
+		return nil, false
+	}
+	return value.(*ResponseChannel), true
+}
+
+// Send delivers response to the channel borrowed under key, and reports false when it is no longer borrowed
+func (rp *ResponsePool) Send(key string, response ResponseMessage) bool {
+	respCh, exists := rp.GetChannelByKey(key)
+	if !exists {
+		return false
+	}
+
+	respCh.mu.Lock()
+	defer respCh.mu.Unlock()
+	if respCh.returned {
+		return false
+	}
 	select {
-	case rp.responsePool <- respCh:
+	case respCh.ch <- response:
+		return true
 	default:
-		// Pool is full, discard
+		// A response is already waiting, each request gets a single one
+		return false
 	}
 }
 
-// GetChannelByKey gets a specific response channel by key
-func (rp *ResponsePool) GetChannelByKey(key string) (*ResponseChannel, bool) {
-	respCh, exists := rp.responseChannels[key]
-	return respCh, exists
+// DrainAndClose unregisters the borrowed channels and drops their undelivered responses. Later
+// GetChannel calls, and those blocked waiting for capacity, get an ErrResponsePoolClosed response.
+func (rp *ResponsePool) DrainAndClose() {
+	rp.closeOnce.Do(func() {
+		close(rp.closed)
+		rp.inFlight.Range(func(key, value interface{}) bool {
+			rp.inFlight.Delete(key)
+			value.(*ResponseChannel).release()
+			return true
+		})
+	})
+}
+
+// release marks the channel returned and drops the response buffered in it, if any
+func (rc *ResponseChannel) release() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.returned = true
+	select {
+	case <-rc.ch:
+	default:
+	}
 }
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// mustGetChannel borrows a channel from pool, failing the test when it gives up
+func mustGetChannel(t testing.TB, pool *ResponsePool) *ResponseChannel {
+	t.Helper()
+
+	respCh, err := pool.GetChannel(context.Background())
+	if err != nil {
+		t.Fatalf("GetChannel() error = %v", err)
+	}
+	return respCh
+}
+
+func TestResponsePoolSendAndReturn(t *testing.T) {
+	pool := NewResponsePool(2)
+
+	respCh := mustGetChannel(t, pool)
+	if got, exists := pool.GetChannelByKey(respCh.key); !exists || got != respCh {
+		t.Fatalf("Expected the borrowed channel to be registered under its key")
+	}
+	if !pool.Send(respCh.key, ResponseMessage{ETag: "v1"}) {
+		t.Fatal("Expected the response to be delivered")
+	}
+	if response := <-respCh.ch; response.ETag != "v1" {
+		t.Errorf("Expected the sent response, got %+v", response)
+	}
+
+	pool.ReturnChannel(respCh)
+	if _, exists := pool.GetChannelByKey(respCh.key); exists {
+		t.Error("Expected the returned channel to be unregistered")
+	}
+	if pool.Send(respCh.key, ResponseMessage{ETag: "late"}) {
+		t.Error("Expected a response to a returned channel to be dropped")
+	}
+
+	// Returning twice must not free a second slot
+	pool.ReturnChannel(respCh)
+	if got := len(pool.slots); got != 2 {
+		t.Errorf("Expected 2 free slots, got %d", got)
+	}
+}
+
+func TestResponsePoolReturnDropsUnreadResponse(t *testing.T) {
+	pool := NewResponsePool(1)
+
+	// The handler timed out before reading its response
+	timedOut := mustGetChannel(t, pool)
+	pool.Send(timedOut.key, ResponseMessage{ETag: "stale"})
+	pool.ReturnChannel(timedOut)
+
+	next := mustGetChannel(t, pool)
+	defer pool.ReturnChannel(next)
+	if next.key == timedOut.key {
+		t.Fatal("Expected a new key for each borrowed channel")
+	}
+	select {
+	case response := <-next.ch:
+		t.Fatalf("Expected the reused channel to be empty, got %+v", response)
+	default:
+	}
+}
+
+func TestResponsePoolBlocksAtCapacity(t *testing.T) {
+	pool := NewResponsePool(1)
+	first := mustGetChannel(t, pool)
+
+	var acquired atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		second, err := pool.GetChannel(context.Background())
+		if err != nil {
+			t.Errorf("GetChannel() error = %v", err)
+			return
+		}
+		acquired.Store(true)
+		pool.ReturnChannel(second)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if acquired.Load() {
+		t.Fatal("Expected GetChannel to block while the pool is at capacity")
+	}
+
+	pool.ReturnChannel(first)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected GetChannel to acquire the returned channel")
+	}
+}
+
+func TestResponsePoolGetChannelCancelled(t *testing.T) {
+	pool := NewResponsePool(1)
+	first := mustGetChannel(t, pool)
+	defer pool.ReturnChannel(first)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.GetChannel(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded at capacity, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.GetChannel(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Canceled for a closed request, got %v", err)
+	}
+
+	if got := len(pool.slots); got != 0 {
+		t.Errorf("Expected abandoned waits not to take a slot, got %d free", got)
+	}
+}
+
+func TestResponsePoolConcurrentRequests(t *testing.T) {
+	pool := NewResponsePool(5)
+
+	const requests = 100
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			respCh, err := pool.GetChannel(context.Background())
+			if err != nil {
+				t.Errorf("GetChannel() error = %v", err)
+				return
+			}
+			defer pool.ReturnChannel(respCh)
+
+			go pool.Send(respCh.key, ResponseMessage{Data: i})
+			if response := <-respCh.ch; response.Data != i {
+				t.Errorf("Request %d got the response of request %v", i, response.Data)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(pool.slots); got != 5 {
+		t.Errorf("Expected every slot to be free, got %d", got)
+	}
+}
+
+func TestResponsePoolDrainAndClose(t *testing.T) {
+	pool := NewResponsePool(1)
+	borrowed := mustGetChannel(t, pool)
+	pool.Send(borrowed.key, ResponseMessage{ETag: "undelivered"})
+
+	blocked := make(chan *ResponseChannel)
+	go func() {
+		respCh, err := pool.GetChannel(context.Background())
+		if err != nil {
+			t.Errorf("GetChannel() error = %v", err)
+		}
+		blocked <- respCh
+	}()
+
+	pool.DrainAndClose()
+	pool.DrainAndClose()
+
+	select {
+	case response := <-borrowed.ch:
+		t.Errorf("Expected the undelivered response to be dropped, got %+v", response)
+	default:
+	}
+	if pool.Send(borrowed.key, ResponseMessage{}) {
+		t.Error("Expected sends to a closed pool to be dropped")
+	}
+
+	select {
+	case respCh := <-blocked:
+		if response := <-respCh.ch; !errors.Is(response.Error, ErrResponsePoolClosed) {
+			t.Errorf("Expected ErrResponsePoolClosed, got %v", response.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the blocked GetChannel to be woken by DrainAndClose")
+	}
+
+	// Returning a channel of a closed pool is a no-op
+	pool.ReturnChannel(borrowed)
+}
+
+// BenchmarkResponsePool borrows, answers and returns pooled channels, like a handler and its tenant worker;
+// compare its allocations with BenchmarkResponseChannelPerRequest
+func BenchmarkResponsePool(b *testing.B) {
+	pool := NewResponsePool(5)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			respCh := mustGetChannel(b, pool)
+			pool.Send(respCh.key, ResponseMessage{})
+			<-respCh.ch
+			pool.ReturnChannel(respCh)
+		}
+	})
+}
+
+// BenchmarkResponseChannelPerRequest allocates and registers a response channel for each request
+func BenchmarkResponseChannelPerRequest(b *testing.B) {
+	var inFlight sync.Map
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			key := uuid.NewString()
+			ch := make(chan ResponseMessage, 1)
+			inFlight.Store(key, ch)
+			if value, exists := inFlight.Load(key); exists {
+				value.(chan ResponseMessage) <- ResponseMessage{}
+			}
+			<-ch
+			inFlight.Delete(key)
+		}
+	})
+}
@@ -1,48 +1,121 @@
 package api
 
 import (
+	"context"
+	"net/http"
+	"time"
+
 	"github.com/gorilla/mux"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
 	"stealthcompany.com/api-rest/internal/metrics"
 )
 
-// SetupRoutes configures and returns the HTTP router
-func SetupRoutes() *mux.Router {
+// warmUpJWKS loads the Keycloak signing keys before serving, so the first requests don't wait on Keycloak.
+// A failure is only logged: requests fetch the keys again once jwksMinRefreshInterval has passed.
+func warmUpJWKS(cfg AppConfig) {
+	if cfg.Auth.SkipSignatureVerification ||
+		(cfg.AuthStrategy != AuthStrategyKeycloakGroups && cfg.AuthStrategy != AuthStrategyKeycloakUsername) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	keys, err := cfg.Keycloak.FetchJWKS(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load the Keycloak signing keys at startup")
+		return
+	}
+	log.Info().Int("keys", len(keys)).Msg("Keycloak signing keys loaded")
+}
+
+// newRouter registers the middleware and routes described by cfg
+func newRouter(cfg AppConfig) (*mux.Router, error) {
 	r := mux.NewRouter()
 
+	authMiddleware, err := AuthMiddlewareFactory(cfg.AuthStrategy, cfg.Auth)
+	if err != nil {
+		return nil, err
+	}
+
 	// Add middleware to all routes
+	r.Use(CorrelationIDMiddleware) // First, so every later log event carries the request ID
+	r.Use(SecurityHeadersMiddleware)
+	r.Use(CORSMiddleware) // Answers preflight requests before authentication
+	r.Use(MaxBytesMiddleware(cfg.MaxRequestBodyBytes))
 	r.Use(metrics.MetricsMiddleware)
-	r.Use(AuthMiddleware) // JWT authentication middleware
+	r.Use(authMiddleware) // Authentication middleware selected by AUTH_STRATEGY
+	// Per-tenant rate limit, after authentication which sets the tenant
+	r.Use(RateLimitMiddleware(cfg.RateLimitRequests, cfg.RateLimitWindow))
 	r.Use(TenantChannelMiddleware)
 
 	// Note: Couchbase connections are now created per-request to avoid globals
 
 	// Public routes (no authentication required)
 	r.HandleFunc("/", RootHandler).Methods("GET")
-	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	r.Handle(MetricsPath, cfg.MetricsHandler).Methods("GET")
+	r.HandleFunc(LivenessPath, LivenessHandler).Methods("GET")
 
 	// Authentication routes (no tenant required)
-	keycloakConfig, err := NewKeycloakConfig()
-	if err != nil {
-		// Log error but continue - auth routes will use dummy config
-		keycloakConfig = &KeycloakConfig{}
-	}
-	ConfigureAuthRoutes(r, keycloakConfig)
+	ConfigureAuthRoutes(r, cfg.Keycloak)
+
+	// Admin routes (registered before tenant routes so the path is not taken as a tenant)
+	r.Handle(IngestManifestsPath, RequirePermission(PermissionAdmin)(http.HandlerFunc(IngestManifestsHandler))).Methods("GET")
 
 	// Tenant-based API routes
 	apiRouter := r.PathPrefix("/api/{tenant}").Subrouter()
+	registerTenantRoutes(apiRouter)
+
+	// CORS preflight for every route (registered last so method-specific routes match first)
+	r.PathPrefix("/").HandlerFunc(preflightHandler).Methods("OPTIONS")
+
+	return r, nil
+}
+
+// registerTenantRoutes registers the tenant-scoped routes, each requiring the realm role permission
+// of its operation: reader for GET routes, reviewer for review changes and admin for warm-up
+func registerTenantRoutes(apiRouter *mux.Router) {
+	read := RequirePermission(PermissionReader)
+	review := RequirePermission(PermissionReviewer)
+	admin := RequirePermission(PermissionAdmin)
 
 	// FHIR resource endpoints for specific tenant
-	apiRouter.HandleFunc("/encounters", ListResourcesHandler("Encounter")).Methods("GET")
-	apiRouter.HandleFunc("/encounters/{id}", GetResourceByIDHandler("Encounter")).Methods("GET")
-	apiRouter.HandleFunc("/patients", ListResourcesHandler("Patient")).Methods("GET")
-	apiRouter.HandleFunc("/patients/{id}", GetResourceByIDHandler("Patient")).Methods("GET")
-	apiRouter.HandleFunc("/practitioners", ListResourcesHandler("Practitioner")).Methods("GET")
-	apiRouter.HandleFunc("/practitioners/{id}", GetResourceByIDHandler("Practitioner")).Methods("GET")
+	apiRouter.Handle("/encounters", read(ListResourcesHandler("Encounter"))).Methods("GET")
+	apiRouter.Handle("/encounters/{id}", read(GetResourceByIDHandler("Encounter"))).Methods("GET")
+	apiRouter.Handle("/encounters/{id}/summary", read(http.HandlerFunc(GetEncounterSummaryHandler))).Methods("GET")
+	apiRouter.Handle("/encounters/{id}/review-status", read(ReviewStatusHandler("Encounter"))).Methods("GET")
+	apiRouter.Handle("/encounters/{id}/review-history", read(ReviewHistoryHandler("Encounter"))).Methods("GET")
+	apiRouter.Handle("/patients", read(ListResourcesHandler("Patient"))).Methods("GET")
+	apiRouter.Handle("/patients/{id}", read(GetResourceByIDHandler("Patient"))).Methods("GET")
+	apiRouter.Handle("/patients/{id}/review-status", read(ReviewStatusHandler("Patient"))).Methods("GET")
+	apiRouter.Handle("/patients/{id}/review-history", read(ReviewHistoryHandler("Patient"))).Methods("GET")
+	apiRouter.Handle("/practitioners", read(ListResourcesHandler("Practitioner"))).Methods("GET")
+	apiRouter.Handle("/practitioners/{id}", read(GetResourceByIDHandler("Practitioner"))).Methods("GET")
+	apiRouter.Handle("/practitioners/{id}/review-status", read(ReviewStatusHandler("Practitioner"))).Methods("GET")
+	apiRouter.Handle("/practitioners/{id}/review-history", read(ReviewHistoryHandler("Practitioner"))).Methods("GET")
+	apiRouter.Handle("/observations", read(ListResourcesHandler("Observation"))).Methods("GET")
+	apiRouter.Handle("/observations/{id}", read(GetResourceByIDHandler("Observation"))).Methods("GET")
+	apiRouter.Handle("/conditions", read(ListResourcesHandler("Condition"))).Methods("GET")
+	apiRouter.Handle("/conditions/{id}", read(GetResourceByIDHandler("Condition"))).Methods("GET")
+	apiRouter.Handle("/medication-requests", read(ListResourcesHandler("MedicationRequest"))).Methods("GET")
+	apiRouter.Handle("/medication-requests/{id}", read(GetResourceByIDHandler("MedicationRequest"))).Methods("GET")
 
 	// Review request endpoint for specific tenant
-	apiRouter.HandleFunc("/review-request", ReviewRequestHandler).Methods("POST")
+	apiRouter.Handle("/review-request", review(http.HandlerFunc(ReviewRequestHandler))).Methods("POST")
+	apiRouter.Handle("/review-request", review(http.HandlerFunc(DeleteReviewRequestHandler))).Methods("DELETE")
+	apiRouter.Handle("/bulk-review-request", review(http.HandlerFunc(BulkReviewRequestHandler))).Methods("POST")
+
+	// Ingestion status endpoint for monitoring (does not require a warm tenant)
+	apiRouter.Handle("/ingestion-status", read(http.HandlerFunc(IngestionStatusHandler))).Methods("GET")
+
+	// Review statistics for dashboards, cached for REVIEW_SUMMARY_CACHE_TTL_SECONDS
+	apiRouter.Handle("/review-summary", read(http.HandlerFunc(ReviewSummaryHandler))).Methods("GET")
+
+	// Bulk export of the tenant resources as NDJSON, optionally filtered by ?_type=
+	apiRouter.Handle("/export", read(http.HandlerFunc(ExportHandler))).Methods("GET")
 
+	// Explicit tenant warm-up, blocking unless ?async=true
+	apiRouter.Handle("/warm-up-tenant", admin(http.HandlerFunc(WarmUpTenantHandler))).Methods("POST")
 
-	return r
+	// Tenant offboarding, removes the tenant scope and its data
+	apiRouter.Handle("", admin(http.HandlerFunc(DeleteTenantHandler))).Methods("DELETE")
 }
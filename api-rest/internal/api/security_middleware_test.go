@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	handler := SecurityHeadersMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name           string
+		forwardedProto string
+		expectHSTS     bool
+	}{
+		{
+			name:           "Plain HTTP should not send HSTS",
+			forwardedProto: "",
+			expectHSTS:     false,
+		},
+		{
+			name:           "HTTPS behind proxy should send HSTS",
+			forwardedProto: "https",
+			expectHSTS:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/health", nil)
+			if tt.forwardedProto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.forwardedProto)
+			}
+
+			rr := httptest.NewRecorder()
+			rr.Header().Set("Server", "Go")
+			rr.Header().Set("X-Powered-By", "Go")
+			handler.ServeHTTP(rr, req)
+
+			expected := map[string]string{
+				"X-Content-Type-Options": "nosniff",
+				"X-Frame-Options":        "DENY",
+				"Referrer-Policy":        "no-referrer",
+			}
+			for header, value := range expected {
+				if got := rr.Header().Get(header); got != value {
+					t.Errorf("Expected %s %q, got %q", header, value, got)
+				}
+			}
+
+			for _, header := range []string{"Server", "X-Powered-By"} {
+				if got := rr.Header().Get(header); got != "" {
+					t.Errorf("Expected %s to be removed, got %q", header, got)
+				}
+			}
+
+			hsts := rr.Header().Get("Strict-Transport-Security")
+			if tt.expectHSTS && hsts != "max-age=31536000" {
+				t.Errorf("Expected HSTS header, got %q", hsts)
+			}
+			if !tt.expectHSTS && hsts != "" {
+				t.Errorf("Expected no HSTS header, got %q", hsts)
+			}
+		})
+	}
+}
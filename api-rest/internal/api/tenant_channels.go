@@ -1,25 +1,47 @@
 package api
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"stealthcompany.com/api-rest/internal/dal"
+	"stealthcompany.com/api-rest/internal/metrics"
 )
 
 // TenantChannels represents the channel-based concurrency system for a tenant
 type TenantChannels struct {
-	getEncounterCh      chan RequestMessage
-	listEncountersCh    chan RequestMessage
-	getPatientCh        chan RequestMessage
-	listPatientsCh      chan RequestMessage
-	getPractitionerCh   chan RequestMessage
-	listPractitionersCh chan RequestMessage
-	reviewCh            chan RequestMessage
-	cooldownCh          chan struct{}
-	timerResetCh        chan struct{}
-	responsePool        *ResponsePool
-	pseudoClosed        bool
-	queryContext        string // Stores the query context for this tenant's scope
+	getEncounterCh           chan RequestMessage
+	listEncountersCh         chan RequestMessage
+	getPatientCh             chan RequestMessage
+	listPatientsCh           chan RequestMessage
+	getPractitionerCh        chan RequestMessage
+	listPractitionersCh      chan RequestMessage
+	getObservationCh         chan RequestMessage
+	listObservationsCh       chan RequestMessage
+	getConditionCh           chan RequestMessage
+	listConditionsCh         chan RequestMessage
+	getMedicationRequestCh   chan RequestMessage
+	listMedicationRequestsCh chan RequestMessage
+	reviewCh                 chan RequestMessage
+	reviewStatusCh           chan RequestMessage
+	reviewHistoryCh          chan RequestMessage
+	reviewDeleteCh           chan RequestMessage
+	bulkReviewCh             chan RequestMessage
+	cooldownCh               chan struct{}
+	timerResetCh             chan struct{}
+	removedCh                chan struct{} // closed by CleanupTenantChannels when the tenant is deleted
+	responsePool             *ResponsePool
+	tenantID                 string
+
+	// ctx is cancelled when a graceful shutdown times out, cancelling the in-flight work of the tenant
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu guards pseudoClosed, so a cold tenant's goroutines are restarted once
+	mu           sync.Mutex
+	pseudoClosed bool
 }
 
 // RequestMessage contains the request data and response channel key
@@ -30,82 +52,116 @@ type RequestMessage struct {
 	ResponseKey string
 	Page        int
 	Count       int
+	Cursor      string // nextCursor of the previous page for list requests
+	Notes       string // Optional reviewer notes for review requests
+	Severity    string // Optional review severity for review requests
+	Reviewer    string // Authenticated username recorded in the review history of review requests
+	// EncounterFilter holds optional filters for encounter list requests
+	EncounterFilter dal.EncounterFilter
+	// IncludeSummary requests linked resource counts for patient get requests,
+	// and the encounter with its patient and practitioners for encounter get requests
+	IncludeSummary bool
+	// IncludeStats requests the active encounter count for practitioner get requests
+	IncludeStats bool
+	// IfMatch is the validated If-Match ETag of review requests, empty for unconditional reviews
+	IfMatch string
+	// Reviews holds the validated entries of bulk review requests
+	Reviews []BulkReviewItem
+	// Rows receives the resources of list requests one at a time, so pages are streamed to the client
+	Rows chan<- dal.QueryRow
+	// Ctx is the context of the HTTP request, so a client that disconnects cancels the database work
+	Ctx context.Context
+}
+
+// requestContext returns the context of the HTTP request, or a background context when none was set
+func (m RequestMessage) requestContext() context.Context {
+	if m.Ctx == nil {
+		return context.Background()
+	}
+	return m.Ctx
 }
 
 // ResponseMessage contains the response data
 type ResponseMessage struct {
 	Data  interface{}
 	Error error
+	// ETag identifies the version of the resource returned by get requests
+	ETag string
 }
 
-// Global state management for all tenant channels
+// TenantChannelManager holds the channels of every tenant warmed up since startup, keyed by tenant ID.
+// It is read and written by concurrent requests, so the channels are kept in a sync.Map.
 type TenantChannelManager struct {
-	channels map[string]*TenantChannels
+	channels sync.Map // tenant ID -> *TenantChannels
+
+	// mu guards shuttingDown, so no request is added to inFlight once GracefulShutdown waits on it
+	mu           sync.Mutex
+	shuttingDown bool
+	inFlight     sync.WaitGroup // requests being processed through tenant channels
 }
 
-var tenantChannelManager = &TenantChannelManager{
-	channels: make(map[string]*TenantChannels),
+var tenantChannelManager = &TenantChannelManager{}
+
+// startTenantWorkers starts the message and timer goroutines of tenant channels (overridable in tests)
+var startTenantWorkers = func(tc *TenantChannels) {
+	go tc.processMessages()
+	go tc.manageTimer()
 }
 
-// AutoWarmUpTenant automatically warms up a tenant on first request
-func AutoWarmUpTenant(tenantID string) *TenantChannels {
-	// Check if channels exist
-	channels, exists := tenantChannelManager.channels[tenantID]
-
-	if exists {
-		// Check if this tenant's channels are pseudo-closed
-		if channels.pseudoClosed {
-			// Just reset the flag, don't create new channels
-			channels.pseudoClosed = false
-			log.Info().
-				Str("tenant", tenantID).
-				Msg("Tenant channels pseudo-closed, resetting flag")
-			// Restart both goroutines since they were stopped
-			go channels.processMessages()
-			go channels.manageTimer()
-			return channels
-		}
-		return channels
+// newTenantChannels creates the channels of a tenant, without starting its goroutines
+func newTenantChannels(tenantID string) *TenantChannels {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TenantChannels{
+		getEncounterCh:           make(chan RequestMessage),
+		listEncountersCh:         make(chan RequestMessage),
+		getPatientCh:             make(chan RequestMessage),
+		listPatientsCh:           make(chan RequestMessage),
+		getPractitionerCh:        make(chan RequestMessage),
+		listPractitionersCh:      make(chan RequestMessage),
+		getObservationCh:         make(chan RequestMessage),
+		listObservationsCh:       make(chan RequestMessage),
+		getConditionCh:           make(chan RequestMessage),
+		listConditionsCh:         make(chan RequestMessage),
+		getMedicationRequestCh:   make(chan RequestMessage),
+		listMedicationRequestsCh: make(chan RequestMessage),
+		reviewCh:                 make(chan RequestMessage),
+		reviewStatusCh:           make(chan RequestMessage),
+		reviewHistoryCh:          make(chan RequestMessage),
+		reviewDeleteCh:           make(chan RequestMessage),
+		bulkReviewCh:             make(chan RequestMessage),
+		cooldownCh:               make(chan struct{}),
+		timerResetCh:             make(chan struct{}),
+		removedCh:                make(chan struct{}),
+		responsePool:             NewResponsePool(5),
+		tenantID:                 tenantID,
+		ctx:                      ctx,
+		cancel:                   cancel,
 	}
+}
 
-	// Double-check in case another goroutine created them while we were waiting
-	if channels, exists := tenantChannelManager.channels[tenantID]; exists {
-		if channels.pseudoClosed {
-			channels.pseudoClosed = false
-			log.Info().
-				Str("tenant", tenantID).
-				Msg("Tenant channels pseudo-closed, resetting flag")
-			// Restart both goroutines since they were stopped
-			go channels.processMessages()
-			go channels.manageTimer()
-			return channels
-		}
-		return channels
+// tenantContext returns the context of the tenant, or a background context for channels built without one
+func (tc *TenantChannels) tenantContext() context.Context {
+	if tc.ctx == nil {
+		return context.Background()
 	}
+	return tc.ctx
+}
 
-	// Create channels for this tenant
-	channels = &TenantChannels{
-		getEncounterCh:      make(chan RequestMessage),
-		listEncountersCh:    make(chan RequestMessage),
-		getPatientCh:        make(chan RequestMessage),
-		listPatientsCh:      make(chan RequestMessage),
-		getPractitionerCh:   make(chan RequestMessage),
-		listPractitionersCh: make(chan RequestMessage),
-		reviewCh:            make(chan RequestMessage),
-		cooldownCh:          make(chan struct{}),
-		timerResetCh:        make(chan struct{}),
-		responsePool:        NewResponsePool(5),
-		pseudoClosed:        false,
-		queryContext:        "", // Will be set by ensureTenantScope
+// AutoWarmUpTenant automatically warms up a tenant on first request. Concurrent calls for a cold
+// tenant share the channels stored first, so its goroutines are started only once.
+func AutoWarmUpTenant(tenantID string) *TenantChannels {
+	if channels, exists := GetTenantChannels(tenantID); exists {
+		return channels.reactivate()
 	}
 
-	tenantChannelManager.channels[tenantID] = channels
-
-	// Start worker goroutine
-	go channels.processMessages()
+	value, loaded := tenantChannelManager.channels.LoadOrStore(tenantID, newTenantChannels(tenantID))
+	channels := value.(*TenantChannels)
+	if loaded {
+		// Another request warmed the tenant up first
+		return channels.reactivate()
+	}
 
-	// Start timer management goroutine
-	go channels.manageTimer()
+	startTenantWorkers(channels)
 
 	log.Info().
 		Str("tenant", tenantID).
@@ -114,9 +170,27 @@ func AutoWarmUpTenant(tenantID string) *TenantChannels {
 	return channels
 }
 
-// manageTimer handles the 10-minute timer with reset capability
+// reactivate restarts the goroutines of pseudo-closed channels; the channels themselves are reused
+func (tc *TenantChannels) reactivate() *TenantChannels {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if tc.pseudoClosed {
+		tc.pseudoClosed = false
+		log.Info().
+			Str("tenant", tc.tenantID).
+			Msg("Tenant channels pseudo-closed, resetting flag")
+		startTenantWorkers(tc)
+	}
+	return tc
+}
+
+// manageTimer handles the 10-minute timer with reset capability. Once the tenant is removed the timer
+// is shortened to responseChannelWait, the longest a request already holding the channels waits for them.
 func (tc *TenantChannels) manageTimer() {
-	ticker := time.NewTicker(10 * time.Minute)
+	idleTimeout := 10 * time.Minute
+	removed := tc.removedCh
+	ticker := time.NewTicker(idleTimeout)
 	defer ticker.Stop()
 
 	for {
@@ -128,15 +202,37 @@ func (tc *TenantChannels) manageTimer() {
 		case <-tc.timerResetCh:
 			// Reset timer - create new ticker
 			ticker.Stop()
-			ticker = time.NewTicker(10 * time.Minute)
+			ticker = time.NewTicker(idleTimeout)
+		case <-removed:
+			// Tenant deleted - cool down once the requests already sent to the channels are answered
+			removed = nil
+			idleTimeout = responseChannelWait
+			ticker.Stop()
+			ticker = time.NewTicker(idleTimeout)
 		}
 	}
 }
 
 // GetTenantChannels returns the channels for a tenant if they exist
 func GetTenantChannels(tenantID string) (*TenantChannels, bool) {
-	channels, exists := tenantChannelManager.channels[tenantID]
-	return channels, exists
+	value, exists := tenantChannelManager.channels.Load(tenantID)
+	if !exists {
+		return nil, false
+	}
+	return value.(*TenantChannels), true
+}
+
+// IsTenantWarm checks if a tenant has active (not pseudo-closed) channels
+func IsTenantWarm(tenantID string) bool {
+	channels, exists := GetTenantChannels(tenantID)
+	return exists && !channels.isPseudoClosed()
+}
+
+// isPseudoClosed checks if the goroutines of the channels stopped after the idle timeout
+func (tc *TenantChannels) isPseudoClosed() bool {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.pseudoClosed
 }
 
 // ResetTimer resets the 10-minute timer for a tenant
@@ -150,34 +246,117 @@ func (tc *TenantChannels) ResetTimer() {
 }
 
 // SetPseudoClosed sets the pseudo-closed flag for a specific tenant
+// The tenant query context is cleared so it is set again when the tenant warms up
 func (tc *TenantChannels) SetPseudoClosed() {
+	tc.mu.Lock()
 	tc.pseudoClosed = true
-	log.Info().Msg("Tenant channels marked as pseudo-closed")
+	tc.mu.Unlock()
+
+	ClearTenantQueryContext(tc.tenantID)
+	metrics.RecordTenantCold()
+	log.Info().Str("tenant", tc.tenantID).Msg("Tenant channels marked as pseudo-closed")
+}
+
+// acquire registers a request processed through tenant channels, or returns false once shutdown has started
+func (m *TenantChannelManager) acquire() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.shuttingDown {
+		return false
+	}
+	m.inFlight.Add(1)
+	return true
+}
+
+// release marks a request registered with acquire as done
+func (m *TenantChannelManager) release() {
+	m.inFlight.Done()
+}
+
+// GracefulShutdown stops accepting tenant requests and waits for the in-flight ones to drain.
+// When ctx expires first, the context of every tenant is cancelled and ctx.Err() is returned.
+func (m *TenantChannelManager) GracefulShutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.shuttingDown = true
+	m.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		m.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		log.Info().Msg("In-flight tenant requests drained")
+		return nil
+	case <-ctx.Done():
+		m.channels.Range(func(key, value interface{}) bool {
+			if tc := value.(*TenantChannels); tc.cancel != nil {
+				tc.cancel()
+			}
+			log.Warn().Str("tenant", key.(string)).Msg("Tenant requests cancelled after shutdown timeout")
+			return true
+		})
+		return ctx.Err()
+	}
+}
+
+// DrainTenantChannels gracefully shuts down the tenant channels, see TenantChannelManager.GracefulShutdown
+func DrainTenantChannels(ctx context.Context) error {
+	return tenantChannelManager.GracefulShutdown(ctx)
 }
 
 // CleanupAllChannels performs graceful shutdown cleanup
 func CleanupAllChannels() {
-	for tenantID, channels := range tenantChannelManager.channels {
-		channels.cleanupChannels()
+	tenantChannelManager.channels.Range(func(key, value interface{}) bool {
+		tenantID := key.(string)
+		tenantChannelManager.channels.Delete(tenantID)
+		value.(*TenantChannels).cleanupChannels()
+		ClearTenantQueryContext(tenantID)
 		log.Info().Str("tenant", tenantID).Msg("Tenant channels cleaned up")
-	}
+		return true
+	})
 
-	// Clear the map
-	tenantChannelManager.channels = make(map[string]*TenantChannels)
 	log.Info().Msg("All tenant channels cleaned up during shutdown")
 }
 
-// GetTenantQueryContext returns the query context for a tenant
-func GetTenantQueryContext(tenantID string) string {
-	if channels, exists := tenantChannelManager.channels[tenantID]; exists {
-		return channels.queryContext
+// CleanupTenantChannels removes the channels of a deleted tenant and cancels its in-flight work, so its next
+// request does not reach workers reading the dropped collections. The channels are not closed: requests that
+// already hold them are answered with a cancelled context before the workers cool down.
+func CleanupTenantChannels(tenantID string) {
+	if value, ok := tenantChannelManager.channels.LoadAndDelete(tenantID); ok {
+		tc := value.(*TenantChannels)
+		if tc.cancel != nil {
+			tc.cancel()
+		}
+		if tc.removedCh != nil {
+			close(tc.removedCh)
+		}
+		log.Info().Str("tenant", tenantID).Msg("Tenant channels removed")
+	}
+	ClearTenantQueryContext(tenantID)
+}
+
+// tenantQueryContexts holds the query context ("default:bucket.scope") of each warm tenant
+var tenantQueryContexts sync.Map
+
+// GetTenantQueryContext returns the query context for a tenant, if one was set
+func GetTenantQueryContext(tenantID string) (string, bool) {
+	value, ok := tenantQueryContexts.Load(tenantID)
+	if !ok {
+		return "", false
 	}
-	return ""
+	return value.(string), true
 }
 
 // SetTenantQueryContext sets the query context for a tenant
 func SetTenantQueryContext(tenantID, queryContext string) {
-	if channels, exists := tenantChannelManager.channels[tenantID]; exists {
-		channels.queryContext = queryContext
-	}
+	tenantQueryContexts.Store(tenantID, queryContext)
+}
+
+// ClearTenantQueryContext removes the query context of a tenant
+func ClearTenantQueryContext(tenantID string) {
+	tenantQueryContexts.Delete(tenantID)
 }
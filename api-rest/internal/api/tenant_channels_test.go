@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countTenantWorkers replaces the goroutine starter with a counter for the duration of the test
+func countTenantWorkers(t *testing.T) *int32 {
+	var started int32
+	original := startTenantWorkers
+	startTenantWorkers = func(tc *TenantChannels) {
+		atomic.AddInt32(&started, 1)
+	}
+	t.Cleanup(func() {
+		startTenantWorkers = original
+	})
+	return &started
+}
+
+func TestAutoWarmUpTenantConcurrentColdStart(t *testing.T) {
+	tenantID := "concurrent-warmup-tenant"
+	started := countTenantWorkers(t)
+	t.Cleanup(func() {
+		tenantChannelManager.channels.Delete(tenantID)
+	})
+
+	const requests = 50
+	results := make([]*TenantChannels, requests)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			results[i] = AutoWarmUpTenant(tenantID)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(started); got != 1 {
+		t.Fatalf("Expected processMessages to be started once, got %d", got)
+	}
+	for i, channels := range results {
+		if channels != results[0] {
+			t.Errorf("Request %d got different channels than the first request", i)
+		}
+	}
+}
+
+func TestAutoWarmUpTenantConcurrentReactivation(t *testing.T) {
+	tenantID := "concurrent-reactivation-tenant"
+	started := countTenantWorkers(t)
+	t.Cleanup(func() {
+		tenantChannelManager.channels.Delete(tenantID)
+	})
+
+	channels := AutoWarmUpTenant(tenantID)
+	channels.SetPseudoClosed()
+	if IsTenantWarm(tenantID) {
+		t.Fatalf("Expected pseudo-closed tenant not to be warm")
+	}
+
+	const requests = 50
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			AutoWarmUpTenant(tenantID)
+		}()
+	}
+	wg.Wait()
+
+	// One start on the cold start, one on the reactivation
+	if got := atomic.LoadInt32(started); got != 2 {
+		t.Errorf("Expected goroutines to be started twice, got %d", got)
+	}
+	if !IsTenantWarm(tenantID) {
+		t.Errorf("Expected reactivated tenant to be warm")
+	}
+}
+
+// startInFlightRequest processes a 200ms request through the channels, as a request admitted by the middleware,
+// and reports whether it completed or was cancelled
+func startInFlightRequest(t *testing.T, m *TenantChannelManager, tc *TenantChannels) <-chan error {
+	if !m.acquire() {
+		t.Fatalf("Expected request to be accepted before shutdown")
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		defer m.release()
+		tc.handleChannelMessage(RequestMessage{TenantID: tc.tenantID}, true, "test", func(msg RequestMessage) ResponseMessage {
+			select {
+			case <-time.After(200 * time.Millisecond):
+				result <- nil
+			case <-msg.Ctx.Done():
+				result <- msg.Ctx.Err()
+			}
+			return ResponseMessage{}
+		})
+	}()
+	return result
+}
+
+func TestGracefulShutdownDrainsInFlightRequests(t *testing.T) {
+	m := &TenantChannelManager{}
+	tc := newTenantChannels("drain-tenant")
+	m.channels.Store(tc.tenantID, tc)
+
+	result := startInFlightRequest(t, m, tc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := m.GracefulShutdown(ctx); err != nil {
+		t.Fatalf("GracefulShutdown() error = %v", err)
+	}
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("Expected in-flight request to complete, got %v", err)
+		}
+	default:
+		t.Errorf("Expected in-flight request to complete before shutdown returned")
+	}
+
+	if m.acquire() {
+		t.Errorf("Expected new requests to be refused after shutdown")
+	}
+}
+
+func TestGracefulShutdownCancelsAfterTimeout(t *testing.T) {
+	m := &TenantChannelManager{}
+	tc := newTenantChannels("cancel-tenant")
+	m.channels.Store(tc.tenantID, tc)
+
+	result := startInFlightRequest(t, m, tc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := m.GracefulShutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected in-flight request to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected in-flight request to be cancelled")
+	}
+}
+
+func TestTenantChannelMiddlewareRefusesRequestsDuringShutdown(t *testing.T) {
+	original := tenantChannelManager
+	tenantChannelManager = &TenantChannelManager{}
+	t.Cleanup(func() {
+		tenantChannelManager = original
+	})
+	if err := tenantChannelManager.GracefulShutdown(context.Background()); err != nil {
+		t.Fatalf("GracefulShutdown() error = %v", err)
+	}
+
+	handler := TenantChannelMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected request not to reach the handler")
+	}))
+	req := newTenantRequest("GET", "/api/shutdown-tenant/encounters", "shutdown-tenant", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
@@ -2,20 +2,25 @@ package api
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"stealthcompany.com/api-rest/internal/dal"
+	"stealthcompany.com/api-rest/internal/metrics"
 )
 
 // TenantChannelMiddleware routes requests through tenant channels if available
 func TenantChannelMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Special handling for endpoints that don't require tenant warm-up
-		if r.URL.Path == "/" || r.URL.Path == "/metrics" || r.URL.Path == "/health" {
+		if r.URL.Path == "/" || r.URL.Path == "/metrics" || r.URL.Path == "/health" || r.URL.Path == LivenessPath {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -26,6 +31,25 @@ func TenantChannelMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Admin endpoints are not tenant-scoped
+		if r.URL.Path == IngestManifestsPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Ingestion status is read directly from the DAL and must not warm up the tenant;
+		// warm-up-tenant warms the tenant itself so it can answer before the copy completes
+		if strings.HasSuffix(r.URL.Path, "/ingestion-status") || strings.HasSuffix(r.URL.Path, "/warm-up-tenant") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Deleting a tenant must not create its scope first
+		if r.Method == http.MethodDelete && isTenantRootPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		tenantID, err := GetTenantFromRequest(r)
 		if err != nil {
 			// If no tenant ID, fallback to direct processing
@@ -33,16 +57,35 @@ func TenantChannelMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Ensure tenant scope exists and is ready
-		if err := ensureTenantScope(r.Context(), tenantID); err != nil {
-			log.Error().
+		// Tenant requests are tracked so shutdown can drain them; new ones are refused once it starts
+		if !tenantChannelManager.acquire() {
+			w.Header().Set("Content-Type", "application/json")
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+				"error":   "Service shutting down",
+				"message": "Tenant requests are no longer accepted",
+			})
+			return
+		}
+		defer tenantChannelManager.release()
+
+		// Ensure tenant scope exists and is ready, skipping the check for recently verified warm tenants
+		if err := ensureTenantScopeCached(r.Context(), tenantID); err != nil {
+			if errors.Is(err, dal.ErrTenantDeleted) {
+				log.Ctx(r.Context()).Warn().Str("tenantID", tenantID).Msg("Request for a deleted tenant")
+				w.Header().Set("Content-Type", "application/json")
+				writeJSON(w, http.StatusGone, map[string]string{
+					"error":   "Tenant deleted",
+					"message": "The tenant was offboarded; an admin warm-up restores it",
+				})
+				return
+			}
+			log.Ctx(r.Context()).Error().
 				Err(err).
 				Str("tenantID", tenantID).
 				Msg("Failed to ensure tenant scope")
 
 			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
+			writeJSON(w, http.StatusInternalServerError, map[string]string{
 				"error":   "Failed to initialize tenant scope",
 				"message": "Unable to access tenant data",
 			})
@@ -51,10 +94,8 @@ func TenantChannelMiddleware(next http.Handler) http.Handler {
 
 		if channels, exists := GetTenantChannels(tenantID); exists {
 			// Check if channels are pseudo-closed
-			if channels.pseudoClosed {
-				// Channels are pseudo-closed, need to reactivate them
-				channels = AutoWarmUpTenant(tenantID)
-			}
+			// Channels pseudo-closed after the idle timeout get their goroutines restarted
+			channels = channels.reactivate()
 
 			// Reset timer for this request
 			channels.ResetTimer()
@@ -67,8 +108,7 @@ func TenantChannelMiddleware(next http.Handler) http.Handler {
 			if channels == nil {
 				// Auto-warm-up failed - return error
 				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(map[string]string{
+				writeJSON(w, http.StatusInternalServerError, map[string]string{
 					"error":   "Failed to warm up tenant",
 					"message": "Unable to initialize tenant channels",
 				})
@@ -84,10 +124,112 @@ func TenantChannelMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// ensureTenantScope ensures that a tenant scope exists and is ready for use
-func ensureTenantScope(ctx context.Context, tenantID string) error {
+// isTenantRootPath reports whether path is /api/{tenant} itself
+func isTenantRootPath(path string) bool {
+	tenant, ok := strings.CutPrefix(strings.TrimSuffix(path, "/"), "/api/")
+	return ok && tenant != "" && !strings.Contains(tenant, "/")
+}
+
+// warmUpTenant ensures the tenant scope and starts its channels; overridable in tests.
+// An explicit warm-up restores a deleted tenant, its scope is then created again from DefaultScope.
+var warmUpTenant = func(ctx context.Context, tenantID string) error {
+	if err := restoreTenant(ctx, tenantID); err != nil {
+		return fmt.Errorf("failed to restore tenant: %w", err)
+	}
+	deletedTenants.Delete(tenantID)
+	if err := ensureTenantScopeCached(ctx, tenantID); err != nil {
+		return fmt.Errorf("failed to ensure tenant scope: %w", err)
+	}
+	AutoWarmUpTenant(tenantID).ResetTimer()
+	return nil
+}
+
+// recordedWarmUpTenant runs warmUpTenant and records its duration and outcome
+func recordedWarmUpTenant(ctx context.Context, tenantID string) error {
+	start := time.Now()
+	err := warmUpTenant(ctx, tenantID)
+	metrics.RecordTenantWarmUp(warmUpStatus(err), time.Since(start))
+	return err
+}
+
+// warmUpStatus returns the status label of a warm-up outcome
+func warmUpStatus(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "error"
+	}
+}
+
+// tenantScopeChecks caches the last successful scope check time per tenant
+var tenantScopeChecks sync.Map
+
+// tenantScopeCheckTTL reads TENANT_SCOPE_CHECK_TTL_SECONDS (default 60)
+func tenantScopeCheckTTL() time.Duration {
+	if value := os.Getenv("TENANT_SCOPE_CHECK_TTL_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 60 * time.Second
+}
+
+// isTenantScopeRecentlyVerified checks if the tenant scope was verified within the TTL
+func isTenantScopeRecentlyVerified(tenantID string) bool {
+	value, ok := tenantScopeChecks.Load(tenantID)
+	if !ok {
+		return false
+	}
+	return time.Since(value.(time.Time)) < tenantScopeCheckTTL()
+}
+
+// markTenantScopeVerified records a successful scope check for a tenant
+func markTenantScopeVerified(tenantID string) {
+	tenantScopeChecks.Store(tenantID, time.Now())
+}
+
+// InvalidateTenantScopeCheck forces the next request of a tenant to verify its scope again
+func InvalidateTenantScopeCheck(tenantID string) {
+	tenantScopeChecks.Delete(tenantID)
+}
+
+// deletedTenants holds the tenants known to be deleted, refused without reading their tombstone again
+var deletedTenants sync.Map
+
+// markTenantDeleted records a deleted tenant, so ensureTenantScopeCached refuses it
+func markTenantDeleted(tenantID string) {
+	deletedTenants.Store(tenantID, struct{}{})
+}
+
+// ensureTenantScopeCached ensures the tenant scope unless it was recently verified and the tenant is warm.
+// A deleted tenant gets dal.ErrTenantDeleted instead of a new scope.
+func ensureTenantScopeCached(ctx context.Context, tenantID string) error {
+	if _, deleted := deletedTenants.Load(tenantID); deleted {
+		return fmt.Errorf("%w: %s", dal.ErrTenantDeleted, tenantID)
+	}
+	if isTenantScopeRecentlyVerified(tenantID) && IsTenantWarm(tenantID) {
+		return nil
+	}
+
+	if err := ensureTenantScope(ctx, tenantID); err != nil {
+		InvalidateTenantScopeCheck(tenantID)
+		if errors.Is(err, dal.ErrTenantDeleted) {
+			markTenantDeleted(tenantID)
+		}
+		return err
+	}
+
+	markTenantScopeVerified(tenantID)
+	return nil
+}
+
+// ensureTenantScope ensures that a tenant scope exists and is ready for use; overridable in tests
+var ensureTenantScope = func(ctx context.Context, tenantID string) error {
 	// Get the database connection
-	conn, err := dal.GetConnectionWithRetry()
+	conn, err := dal.GetConnectionWithRetry(dal.DefaultConnectionRetryAttempts, dal.DefaultConnectionRetryBaseDelay)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTenantScopeCheckCache(t *testing.T) {
+	tenantID := "scope-cache-tenant"
+	t.Cleanup(func() {
+		InvalidateTenantScopeCheck(tenantID)
+	})
+
+	if isTenantScopeRecentlyVerified(tenantID) {
+		t.Fatalf("Expected unknown tenant not to be verified")
+	}
+
+	markTenantScopeVerified(tenantID)
+	if !isTenantScopeRecentlyVerified(tenantID) {
+		t.Errorf("Expected tenant to be verified after marking")
+	}
+
+	// Scope deletion invalidates the cached check
+	InvalidateTenantScopeCheck(tenantID)
+	if isTenantScopeRecentlyVerified(tenantID) {
+		t.Errorf("Expected tenant not to be verified after invalidation")
+	}
+}
+
+func TestTenantScopeCheckCacheTTL(t *testing.T) {
+	tenantID := "scope-cache-ttl-tenant"
+	t.Cleanup(func() {
+		InvalidateTenantScopeCheck(tenantID)
+	})
+
+	t.Setenv("TENANT_SCOPE_CHECK_TTL_SECONDS", "60")
+	tenantScopeChecks.Store(tenantID, time.Now().Add(-2*time.Minute))
+	if isTenantScopeRecentlyVerified(tenantID) {
+		t.Errorf("Expected expired check not to be verified")
+	}
+
+	tenantScopeChecks.Store(tenantID, time.Now().Add(-30*time.Second))
+	if !isTenantScopeRecentlyVerified(tenantID) {
+		t.Errorf("Expected check within TTL to be verified")
+	}
+
+	t.Setenv("TENANT_SCOPE_CHECK_TTL_SECONDS", "0")
+	if isTenantScopeRecentlyVerified(tenantID) {
+		t.Errorf("Expected zero TTL to always revalidate")
+	}
+}
+
+func TestEnsureTenantScopeCachedRequiresWarmTenant(t *testing.T) {
+	tenantID := "scope-cache-cold-tenant"
+	t.Cleanup(func() {
+		InvalidateTenantScopeCheck(tenantID)
+	})
+
+	markTenantScopeVerified(tenantID)
+
+	registerTestTenant(t, tenantID, func(msg RequestMessage) ResponseMessage {
+		return ResponseMessage{}
+	})
+	if err := ensureTenantScopeCached(context.Background(), tenantID); err != nil {
+		t.Errorf("Expected cached check for warm tenant to skip Couchbase, got %v", err)
+	}
+	if IsTenantWarm("scope-cache-unknown-tenant") {
+		t.Errorf("Expected unknown tenant not to be warm")
+	}
+}
+
+func TestTenantQueryContext(t *testing.T) {
+	tenantID := "query-context-tenant"
+	t.Cleanup(func() {
+		ClearTenantQueryContext(tenantID)
+	})
+
+	if _, ok := GetTenantQueryContext(tenantID); ok {
+		t.Fatalf("Expected no query context before the scope is ensured")
+	}
+
+	SetTenantQueryContext(tenantID, "default:EvTeChallenge."+tenantID)
+	queryContext, ok := GetTenantQueryContext(tenantID)
+	if !ok || queryContext != "default:EvTeChallenge.query-context-tenant" {
+		t.Errorf("Expected query context default:EvTeChallenge.query-context-tenant, got %q (set: %v)", queryContext, ok)
+	}
+
+	// Going cold clears the query context
+	channels := &TenantChannels{tenantID: tenantID}
+	channels.SetPseudoClosed()
+	if _, ok := GetTenantQueryContext(tenantID); ok {
+		t.Errorf("Expected query context to be cleared after the tenant goes cold")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"github.com/rs/zerolog/log"
+	"stealthcompany.com/api-rest/internal/dal"
 	"stealthcompany.com/api-rest/internal/metrics"
 )
 
@@ -25,8 +27,28 @@ func (tc *TenantChannels) processMessages() {
 			tc.handleChannelMessage(msg, ok, "get_practitioner", tc.processGetPractitioner)
 		case msg, ok := <-tc.listPractitionersCh:
 			tc.handleChannelMessage(msg, ok, "list_practitioners", tc.processListPractitioners)
+		case msg, ok := <-tc.getObservationCh:
+			tc.handleChannelMessage(msg, ok, "get_observation", tc.processGetObservation)
+		case msg, ok := <-tc.listObservationsCh:
+			tc.handleChannelMessage(msg, ok, "list_observations", tc.processListObservations)
+		case msg, ok := <-tc.getConditionCh:
+			tc.handleChannelMessage(msg, ok, "get_condition", tc.processGetCondition)
+		case msg, ok := <-tc.listConditionsCh:
+			tc.handleChannelMessage(msg, ok, "list_conditions", tc.processListConditions)
+		case msg, ok := <-tc.getMedicationRequestCh:
+			tc.handleChannelMessage(msg, ok, "get_medication_request", tc.processGetMedicationRequest)
+		case msg, ok := <-tc.listMedicationRequestsCh:
+			tc.handleChannelMessage(msg, ok, "list_medication_requests", tc.processListMedicationRequests)
 		case msg, ok := <-tc.reviewCh:
 			tc.handleChannelMessage(msg, ok, "review_request", tc.processReviewRequest)
+		case msg, ok := <-tc.reviewStatusCh:
+			tc.handleChannelMessage(msg, ok, "review_status", tc.processReviewStatus)
+		case msg, ok := <-tc.reviewHistoryCh:
+			tc.handleChannelMessage(msg, ok, "review_history", tc.processReviewHistory)
+		case msg, ok := <-tc.reviewDeleteCh:
+			tc.handleChannelMessage(msg, ok, "review_delete", tc.processReviewDelete)
+		case msg, ok := <-tc.bulkReviewCh:
+			tc.handleChannelMessage(msg, ok, "bulk_review_request", tc.processBulkReviewRequest)
 		case <-tc.cooldownCh:
 			// Handle cooldown signal - stop goroutine
 			return
@@ -45,17 +67,25 @@ func (tc *TenantChannels) handleChannelMessage(
 		return
 	}
 
+	// Couchbase operations of the message are recorded for the tenant of the channel,
+	// and cancelled with the request or when a graceful shutdown times out
+	ctx, cancel := context.WithCancel(metrics.ContextWithTenant(msg.requestContext(), tc.tenantID))
+	defer cancel()
+	stop := context.AfterFunc(tc.tenantContext(), cancel)
+	defer stop()
+	msg.Ctx = ctx
+
 	start := time.Now()
 	response := processor(msg)
 	tc.sendResponse(msg.ResponseKey, response)
-	metrics.RecordChannelOperation(operation, time.Since(start))
+	metrics.RecordChannelOperation(operation, tc.tenantID, time.Since(start))
 }
 
-// sendResponse sends a response back through the response pool
+// sendResponse sends a response back through the response pool; the handler waiting for it returns the channel.
+// The response of a request whose handler already gave up is dropped.
 func (tc *TenantChannels) sendResponse(responseKey string, response ResponseMessage) {
-	if respCh, exists := tc.responsePool.GetChannelByKey(responseKey); exists {
-		respCh.ch <- response
-		tc.responsePool.ReturnChannel(respCh)
+	if !tc.responsePool.Send(responseKey, response) {
+		log.Debug().Str("tenant", tc.tenantID).Msg("Dropped response of a request no longer waiting")
 	}
 }
 
@@ -67,40 +97,104 @@ func (tc *TenantChannels) cleanupChannels() {
 	close(tc.listPatientsCh)
 	close(tc.getPractitionerCh)
 	close(tc.listPractitionersCh)
+	close(tc.getObservationCh)
+	close(tc.listObservationsCh)
+	close(tc.getConditionCh)
+	close(tc.listConditionsCh)
+	close(tc.getMedicationRequestCh)
+	close(tc.listMedicationRequestsCh)
 	close(tc.reviewCh)
+	close(tc.reviewStatusCh)
+	close(tc.reviewHistoryCh)
+	close(tc.reviewDeleteCh)
+	close(tc.bulkReviewCh)
 	close(tc.cooldownCh)
 	close(tc.timerResetCh)
+	tc.responsePool.DrainAndClose()
 }
 
 // Processing functions for each request type
 
 func (tc *TenantChannels) processGetEncounter(msg RequestMessage) ResponseMessage {
-	data, err := getResourceByID(context.Background(), msg.TenantID, msg.Entity, msg.ID)
-	return ResponseMessage{Data: data, Error: err}
+	if msg.IncludeSummary {
+		summary, err := getEncounterSummary(msg.requestContext(), msg.TenantID, msg.ID)
+		return ResponseMessage{Data: summary, Error: err}
+	}
+
+	data, etag, err := getResourceByID(msg.requestContext(), msg.TenantID, msg.Entity, msg.ID)
+	return ResponseMessage{Data: data, Error: err, ETag: etag}
 }
 
 func (tc *TenantChannels) processListEncounters(msg RequestMessage) ResponseMessage {
-	data, err := listResources(context.Background(), msg.TenantID, msg.Entity, msg.Page, msg.Count)
+	data, err := streamResources(msg.requestContext(), msg.TenantID, msg.Entity, msg.Page, msg.Count, msg.Cursor, msg.EncounterFilter, msg.Rows)
 	return ResponseMessage{Data: data, Error: err}
 }
 
 func (tc *TenantChannels) processGetPatient(msg RequestMessage) ResponseMessage {
-	data, err := getResourceByID(context.Background(), msg.TenantID, msg.Entity, msg.ID)
-	return ResponseMessage{Data: data, Error: err}
+	data, etag, err := getResourceByID(msg.requestContext(), msg.TenantID, msg.Entity, msg.ID)
+	if err != nil || !msg.IncludeSummary {
+		return ResponseMessage{Data: data, Error: err, ETag: etag}
+	}
+
+	summary, err := getPatientSummary(msg.requestContext(), msg.TenantID, msg.ID)
+	if err != nil {
+		return ResponseMessage{Error: err}
+	}
+	data["summary"] = summary
+	return ResponseMessage{Data: data, ETag: etag}
 }
 
 func (tc *TenantChannels) processListPatients(msg RequestMessage) ResponseMessage {
-	data, err := listResources(context.Background(), msg.TenantID, msg.Entity, msg.Page, msg.Count)
+	data, err := streamResources(msg.requestContext(), msg.TenantID, msg.Entity, msg.Page, msg.Count, msg.Cursor, dal.EncounterFilter{}, msg.Rows)
 	return ResponseMessage{Data: data, Error: err}
 }
 
 func (tc *TenantChannels) processGetPractitioner(msg RequestMessage) ResponseMessage {
-	data, err := getResourceByID(context.Background(), msg.TenantID, msg.Entity, msg.ID)
-	return ResponseMessage{Data: data, Error: err}
+	data, etag, err := getResourceByID(msg.requestContext(), msg.TenantID, msg.Entity, msg.ID)
+	if err != nil || !msg.IncludeStats {
+		return ResponseMessage{Data: data, Error: err, ETag: etag}
+	}
+
+	count, err := getPractitionerActiveEncounterCount(msg.requestContext(), msg.TenantID, msg.ID)
+	if err != nil {
+		return ResponseMessage{Error: err}
+	}
+	data["_currentEncounterCount"] = count
+	return ResponseMessage{Data: data, ETag: etag}
 }
 
 func (tc *TenantChannels) processListPractitioners(msg RequestMessage) ResponseMessage {
-	data, err := listResources(context.Background(), msg.TenantID, msg.Entity, msg.Page, msg.Count)
+	data, err := streamResources(msg.requestContext(), msg.TenantID, msg.Entity, msg.Page, msg.Count, msg.Cursor, dal.EncounterFilter{}, msg.Rows)
+	return ResponseMessage{Data: data, Error: err}
+}
+
+func (tc *TenantChannels) processGetObservation(msg RequestMessage) ResponseMessage {
+	data, etag, err := getResourceByID(msg.requestContext(), msg.TenantID, msg.Entity, msg.ID)
+	return ResponseMessage{Data: data, Error: err, ETag: etag}
+}
+
+func (tc *TenantChannels) processListObservations(msg RequestMessage) ResponseMessage {
+	data, err := streamResources(msg.requestContext(), msg.TenantID, msg.Entity, msg.Page, msg.Count, msg.Cursor, dal.EncounterFilter{}, msg.Rows)
+	return ResponseMessage{Data: data, Error: err}
+}
+
+func (tc *TenantChannels) processGetCondition(msg RequestMessage) ResponseMessage {
+	data, etag, err := getResourceByID(msg.requestContext(), msg.TenantID, msg.Entity, msg.ID)
+	return ResponseMessage{Data: data, Error: err, ETag: etag}
+}
+
+func (tc *TenantChannels) processListConditions(msg RequestMessage) ResponseMessage {
+	data, err := streamResources(msg.requestContext(), msg.TenantID, msg.Entity, msg.Page, msg.Count, msg.Cursor, dal.EncounterFilter{}, msg.Rows)
+	return ResponseMessage{Data: data, Error: err}
+}
+
+func (tc *TenantChannels) processGetMedicationRequest(msg RequestMessage) ResponseMessage {
+	data, etag, err := getResourceByID(msg.requestContext(), msg.TenantID, msg.Entity, msg.ID)
+	return ResponseMessage{Data: data, Error: err, ETag: etag}
+}
+
+func (tc *TenantChannels) processListMedicationRequests(msg RequestMessage) ResponseMessage {
+	data, err := streamResources(msg.requestContext(), msg.TenantID, msg.Entity, msg.Page, msg.Count, msg.Cursor, dal.EncounterFilter{}, msg.Rows)
 	return ResponseMessage{Data: data, Error: err}
 }
 
@@ -122,6 +216,31 @@ func (tc *TenantChannels) processReviewRequest(msg RequestMessage) ResponseMessa
 		}
 	}
 
-	data, err := processReviewRequest(context.Background(), msg.TenantID, resourceType, resourceID)
+	details := dal.ReviewDetails{Notes: msg.Notes, Severity: msg.Severity, ReviewerUsername: msg.Reviewer}
+	if msg.IfMatch != "" {
+		// Already validated by the handler
+		details.Cas, _ = dal.ParseETag(msg.IfMatch)
+	}
+	data, err := processReviewRequest(msg.requestContext(), msg.TenantID, resourceType, resourceID, details)
+	return ResponseMessage{Data: data, Error: err}
+}
+
+func (tc *TenantChannels) processReviewStatus(msg RequestMessage) ResponseMessage {
+	data, err := getReviewStatus(msg.requestContext(), msg.TenantID, msg.Entity, msg.ID)
+	return ResponseMessage{Data: data, Error: err}
+}
+
+func (tc *TenantChannels) processReviewHistory(msg RequestMessage) ResponseMessage {
+	data, err := getReviewHistory(msg.requestContext(), msg.TenantID, msg.Entity, msg.ID)
+	return ResponseMessage{Data: data, Error: err}
+}
+
+func (tc *TenantChannels) processReviewDelete(msg RequestMessage) ResponseMessage {
+	data, err := deleteReviewRequest(msg.requestContext(), msg.TenantID, msg.Entity, msg.ID)
+	return ResponseMessage{Data: data, Error: err}
+}
+
+func (tc *TenantChannels) processBulkReviewRequest(msg RequestMessage) ResponseMessage {
+	data, err := processBulkReviewRequest(msg.requestContext(), msg.TenantID, msg.Reviews)
 	return ResponseMessage{Data: data, Error: err}
 }
@@ -1,13 +1,29 @@
 package api
 
+import "stealthcompany.com/api-rest/internal/dal"
+
 // Request Types
 type AllGoodRequest struct {
 	Yes bool `json:"yes"`
 }
 
 type ReviewRequest struct {
-	Entity string `json:"entity"`
-	ID     string `json:"id"`
+	Entity   string `json:"entity"`
+	ID       string `json:"id"`
+	Notes    string `json:"notes,omitempty"`
+	Severity string `json:"severity,omitempty"` // "info", "warning" or "critical"
+}
+
+// BulkReviewRequest is the body of POST /bulk-review-request
+type BulkReviewRequest struct {
+	Reviews []ReviewRequest `json:"reviews"`
+}
+
+// BulkReviewItem is a validated entry of a bulk review request
+type BulkReviewItem struct {
+	ResourceType string
+	ID           string
+	Details      dal.ReviewDetails
 }
 
 // Response Types
@@ -17,6 +33,39 @@ type ResponseWithReview struct {
 	Data       map[string]interface{} `json:"data"`
 }
 
+// ReviewStatusResponse contains only the review fields of a resource
+type ReviewStatusResponse struct {
+	Reviewed    bool   `json:"reviewed"`
+	ReviewTime  string `json:"reviewTime,omitempty"`
+	Notes       string `json:"notes,omitempty"`
+	Severity    string `json:"severity,omitempty"`
+	ReviewError bool   `json:"reviewError,omitempty"` // review status could not be read
+	EntityType  string `json:"entityType"`
+	EntityID    string `json:"entityID"`
+	// CurrentEncounterCount is the practitioner's in-progress encounter count, to help prioritize reviews
+	CurrentEncounterCount *int64 `json:"currentEncounterCount,omitempty"`
+}
+
+// ReviewHistoryResponse contains the review audit trail of a resource, oldest first
+type ReviewHistoryResponse struct {
+	EntityType string            `json:"entityType"`
+	EntityID   string            `json:"entityID"`
+	History    []dal.ReviewEvent `json:"history"`
+}
+
+// BulkReviewFailure is an entry of a bulk review request that was not reviewed
+type BulkReviewFailure struct {
+	Entity string `json:"entity,omitempty"`
+	ID     string `json:"id"`
+	Error  string `json:"error"`
+}
+
+// BulkReviewResponse lists the reviewed resources ("ResourceType/id") and the failed entries of a bulk review request
+type BulkReviewResponse struct {
+	Succeeded []string            `json:"succeeded"`
+	Failed    []BulkReviewFailure `json:"failed"`
+}
+
 // Constants
 const (
 	// Tenant Management
@@ -0,0 +1,50 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ConditionModel handles condition-specific database operations
+type ConditionModel struct {
+	resourceModel *ResourceModel
+}
+
+// NewConditionModel creates a new condition model instance
+func NewConditionModel(resourceModel *ResourceModel) *ConditionModel {
+	return &ConditionModel{resourceModel: resourceModel}
+}
+
+// NewConditionModelWithTenant creates a new condition model instance for a specific tenant
+func NewConditionModelWithTenant(conn *Connection, tenantScope string) *ConditionModel {
+	resourceModel := NewResourceModelWithTenant(conn, tenantScope)
+	return &ConditionModel{resourceModel: resourceModel}
+}
+
+// GetByID retrieves an condition by ID
+func (cm *ConditionModel) GetByID(ctx context.Context, id string) (map[string]interface{}, error) {
+	log.Ctx(ctx).Debug().
+		Str("id", id).
+		Msg("Getting condition by ID")
+
+	docID := fmt.Sprintf("Condition/%s", id)
+	return cm.resourceModel.GetResource(ctx, docID)
+}
+
+// List retrieves a paginated list of conditions
+func (cm *ConditionModel) List(ctx context.Context, page, count int, cursor string) (*PaginatedResponse, error) {
+	log.Ctx(ctx).Debug().
+		Int("page", page).
+		Int("count", count).
+		Str("cursor", cursor).
+		Msg("Listing conditions")
+
+	params := PaginationParams{
+		Page:        page,
+		Count:       count,
+		AfterCursor: cursor,
+	}
+	return cm.resourceModel.ListResources(ctx, "Condition", params)
+}
@@ -1,13 +1,21 @@
 package dal
 
 import (
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"stealthcompany.com/api-rest/internal/metrics"
 )
 
 // ConnectionPool manages a pool of Couchbase connections
 type ConnectionPool struct {
 	connections chan *Connection
 	maxSize     int
+	// active counts connections checked out by GetConnOrGenConn and not yet returned
+	active atomic.Int64
 }
 
 var (
@@ -15,28 +23,61 @@ var (
 	poolOnce sync.Once
 )
 
-// GetConnOrGenConn gets a connection from the pool or creates a new one
-func GetConnOrGenConn() (*Connection, error) {
+// newConnectionPool creates a pool keeping up to maxSize idle connections
+func newConnectionPool(maxSize int) *ConnectionPool {
+	return &ConnectionPool{
+		connections: make(chan *Connection, maxSize),
+		maxSize:     maxSize,
+	}
+}
+
+// getPool returns the process-wide connection pool
+func getPool() *ConnectionPool {
 	poolOnce.Do(func() {
-		pool = &ConnectionPool{
-			connections: make(chan *Connection, 5), // Pool of 5 connections
-			maxSize:     5,
-		}
+		pool = newConnectionPool(5) // Pool of 5 connections
 	})
+	return pool
+}
+
+// stats returns the pool size, the idle connections in the pool buffer and the checked out connections
+func (p *ConnectionPool) stats() (total, idle, active int) {
+	return p.maxSize, len(p.connections), int(p.active.Load())
+}
+
+// recordStats publishes the pool gauges
+func (p *ConnectionPool) recordStats() {
+	metrics.SetCouchbasePoolConnections(p.stats())
+}
+
+// GetConnOrGenConn gets a connection from the pool or creates a new one
+func GetConnOrGenConn() (*Connection, error) {
+	p := getPool()
+
+	var conn *Connection
+	var err error
 
 	// Try to get connection from pool
 	select {
-	case conn := <-pool.connections:
+	case conn = <-p.connections:
 		// Test if connection is still alive
-		if isConnectionAlive(conn) {
-			return conn, nil
+		if !connectionAlive(conn) {
+			// Connection is dead, create a new one
+			conn, err = newConnection()
 		}
-		// Connection is dead, create a new one
-		return createNewConnection()
 	default:
-		// Pool is empty, create new connection
-		return createNewConnection()
+		// No idle connection, create new connection. The pool only counts as exhausted once maxSize
+		// connections are checked out, not while it is still filling up.
+		if p.active.Load() >= int64(p.maxSize) {
+			metrics.RecordCouchbasePoolExhausted()
+		}
+		conn, err = newConnection()
+	}
+
+	if err == nil {
+		p.active.Add(1)
 	}
+	p.recordStats()
+	return conn, err
 }
 
 // ReturnConnection returns a connection to the pool
@@ -45,32 +86,65 @@ func ReturnConnection(conn *Connection) {
 		return
 	}
 
+	p := getPool()
+	p.active.Add(-1)
+	defer p.recordStats()
+
 	// Test if connection is still alive
-	if !isConnectionAlive(conn) {
+	if !connectionAlive(conn) {
 		// Connection is dead, don't return it to pool
 		return
 	}
 
 	// Try to return to pool
 	select {
-	case pool.connections <- conn:
+	case p.connections <- conn:
 		// Successfully returned to pool
 	default:
 		// Pool is full, discard connection
 	}
 }
 
-// GetConnectionWithRetry gets a connection with retry logic
-func GetConnectionWithRetry() (*Connection, error) {
-	maxRetries := 3
-	for i := 0; i < maxRetries; i++ {
-		conn, err := GetConnOrGenConn()
+// Default retry settings for GetConnectionWithRetry
+const (
+	DefaultConnectionRetryAttempts  = 3
+	DefaultConnectionRetryBaseDelay = 100 * time.Millisecond
+)
+
+// Overridable in tests
+var (
+	acquireConnection = GetConnOrGenConn
+	connectionAlive   = isConnectionAlive
+	newConnection     = createNewConnection
+)
+
+// GetConnectionWithRetry gets a connection, retrying with exponential backoff when it cannot be acquired.
+// GetConnOrGenConn already pings pooled connections, so the acquired connection is not pinged again.
+func GetConnectionWithRetry(maxAttempts int, baseDelay time.Duration) (*Connection, error) {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultConnectionRetryAttempts
+	}
+
+	var lastErr error
+	delay := baseDelay
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		conn, err := acquireConnection()
 		if err == nil {
 			return conn, nil
 		}
-		if i == maxRetries-1 {
-			return nil, err
+		lastErr = err
+
+		if IsClusterClosedError(err) {
+			log.Warn().Err(err).Int("attempt", attempt).Msg("Couchbase cluster closed, retrying with a new connection")
+		} else {
+			log.Warn().Err(err).Int("attempt", attempt).Msg("Failed to get Couchbase connection")
+		}
+
+		if attempt < maxAttempts {
+			time.Sleep(delay)
+			delay *= 2
 		}
 	}
-	return nil, nil // This should never be reached
+
+	return nil, fmt.Errorf("failed to get connection after %d attempts: %w", maxAttempts, lastErr)
 }
@@ -0,0 +1,204 @@
+package dal
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"stealthcompany.com/api-rest/internal/metrics"
+)
+
+// useMockConnectionFactory replaces connection acquisition with a factory failing the first failures calls,
+// and fails the test when an acquired connection is pinged again
+func useMockConnectionFactory(t *testing.T, failures int, failErr error) *int {
+	t.Helper()
+
+	origAcquire, origAlive := acquireConnection, connectionAlive
+	t.Cleanup(func() {
+		acquireConnection, connectionAlive = origAcquire, origAlive
+	})
+
+	calls := 0
+	acquireConnection = func() (*Connection, error) {
+		calls++
+		if calls <= failures {
+			return nil, failErr
+		}
+		return &Connection{bucketName: "test"}, nil
+	}
+	connectionAlive = func(conn *Connection) bool {
+		t.Error("Expected the acquired connection not to be pinged again")
+		return conn != nil
+	}
+
+	return &calls
+}
+
+func TestGetConnectionWithRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int
+		failErr       error
+		maxAttempts   int
+		expectError   bool
+		expectedCalls int
+	}{
+		{
+			name:          "Succeeds on first attempt",
+			failures:      0,
+			maxAttempts:   3,
+			expectedCalls: 1,
+		},
+		{
+			name:          "Fails twice then succeeds",
+			failures:      2,
+			failErr:       errors.New("connect cluster: timeout"),
+			maxAttempts:   3,
+			expectedCalls: 3,
+		},
+		{
+			name:          "Cluster closed is retried",
+			failures:      1,
+			failErr:       errors.New("query failed: cluster closed"),
+			maxAttempts:   3,
+			expectedCalls: 2,
+		},
+		{
+			name:          "Gives up after max attempts",
+			failures:      5,
+			failErr:       errors.New("connect cluster: timeout"),
+			maxAttempts:   3,
+			expectError:   true,
+			expectedCalls: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := useMockConnectionFactory(t, tt.failures, tt.failErr)
+
+			conn, err := GetConnectionWithRetry(tt.maxAttempts, time.Millisecond)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if conn == nil {
+					t.Errorf("Expected a connection")
+				}
+			}
+			if *calls != tt.expectedCalls {
+				t.Errorf("Expected %d attempts, got %d", tt.expectedCalls, *calls)
+			}
+		})
+	}
+}
+
+// useTestPool replaces the connection pool with an empty pool of maxSize backed by fake connections
+func useTestPool(t *testing.T, maxSize int) *ConnectionPool {
+	t.Helper()
+
+	origPool := getPool()
+	origNew, origAlive := newConnection, connectionAlive
+	t.Cleanup(func() {
+		pool = origPool
+		newConnection, connectionAlive = origNew, origAlive
+	})
+
+	pool = newConnectionPool(maxSize)
+	newConnection = func() (*Connection, error) {
+		return &Connection{bucketName: "test"}, nil
+	}
+	connectionAlive = func(conn *Connection) bool {
+		return conn != nil
+	}
+	return pool
+}
+
+func TestConnectionPoolStatsUnderConcurrentLoad(t *testing.T) {
+	p := useTestPool(t, 3)
+	const workers = 20
+
+	// Check out connections concurrently and hold them
+	conns := make(chan *Connection, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := GetConnOrGenConn()
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			conns <- conn
+		}()
+	}
+	wg.Wait()
+	close(conns)
+
+	if total, idle, active := p.stats(); total != 3 || idle != 0 || active != workers {
+		t.Fatalf("Expected total=3 idle=0 active=%d while checked out, got total=%d idle=%d active=%d", workers, total, idle, active)
+	}
+
+	// Return them concurrently; the pool keeps at most maxSize idle connections
+	for conn := range conns {
+		wg.Add(1)
+		go func(conn *Connection) {
+			defer wg.Done()
+			ReturnConnection(conn)
+		}(conn)
+	}
+	wg.Wait()
+
+	if total, idle, active := p.stats(); total != 3 || idle != 3 || active != 0 {
+		t.Errorf("Expected total=3 idle=3 active=0 after return, got total=%d idle=%d active=%d", total, idle, active)
+	}
+
+	// Idle connections are reused
+	conn, err := GetConnOrGenConn()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, idle, active := p.stats(); idle != 2 || active != 1 {
+		t.Errorf("Expected idle=2 active=1 after reuse, got idle=%d active=%d", idle, active)
+	}
+	ReturnConnection(conn)
+}
+
+func TestConnectionPoolExhausted(t *testing.T) {
+	useTestPool(t, 2)
+	before := promtestutil.ToFloat64(metrics.CouchbasePoolExhaustedTotal)
+
+	// Filling the pool up to its size is not exhaustion
+	var conns []*Connection
+	for i := 0; i < 2; i++ {
+		conn, err := GetConnOrGenConn()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	if got := promtestutil.ToFloat64(metrics.CouchbasePoolExhaustedTotal) - before; got != 0 {
+		t.Errorf("Expected no exhaustion while the pool fills up, got %v", got)
+	}
+
+	conn, err := GetConnOrGenConn()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	conns = append(conns, conn)
+	if got := promtestutil.ToFloat64(metrics.CouchbasePoolExhaustedTotal) - before; got != 1 {
+		t.Errorf("Expected one exhaustion past the pool size, got %v", got)
+	}
+
+	for _, conn := range conns {
+		ReturnConnection(conn)
+	}
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/couchbase/gocb/v2"
 	"github.com/rs/zerolog/log"
+	"stealthcompany.com/api-rest/internal/metrics"
+	"stealthcompany.com/pkg/couchbaseping"
 )
 
 // Connection represents the Couchbase connection
@@ -25,15 +27,32 @@ func getEnv(key, def string) string {
 	return def
 }
 
+// connectionPingTimeout bounds the liveness ping so an unresponsive cluster can't block connection acquisition
+var connectionPingTimeout = 2 * time.Second
+
 // isConnectionAlive tests if a connection is still usable
 func isConnectionAlive(conn *Connection) bool {
 	if conn == nil || conn.cluster == nil {
 		return false
 	}
 
-	// Try a simple operation to test the connection
-	_, err := conn.cluster.Ping(&gocb.PingOptions{})
-	return err == nil
+	return couchbaseping.WithTimeout(conn.cluster, connectionPingTimeout, metrics.RecordCouchbasePing)
+}
+
+// Ping checks that the cluster answers before the deadline of ctx (or connectionPingTimeout without one)
+func (c *Connection) Ping(ctx context.Context) error {
+	if c == nil || c.cluster == nil {
+		return fmt.Errorf("couchbase ping: no cluster connection")
+	}
+
+	timeout := connectionPingTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if !couchbaseping.WithTimeout(c.cluster, timeout, metrics.RecordCouchbasePing) {
+		return fmt.Errorf("couchbase ping failed within %s", timeout)
+	}
+	return nil
 }
 
 // createNewConnection creates a fresh Couchbase connection
@@ -61,13 +80,24 @@ func getConnOrGenConn() (*Connection, error) {
 	pass := getEnv("COUCHBASE_PASSWORD", "password")
 	bucketName := getEnv("COUCHBASE_BUCKET", "EvTeChallenge")
 
+	tlsConfig, err := couchbaseTLSConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	cbURL = tlsConfig.connectionString(cbURL)
+	security, err := tlsConfig.securityConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	log.Info().
 		Str("url", cbURL).
 		Str("bucket", bucketName).
 		Msg("Creating Couchbase connection")
 
 	cluster, err := gocb.Connect(cbURL, gocb.ClusterOptions{
-		Authenticator: gocb.PasswordAuthenticator{Username: user, Password: pass},
+		Authenticator:  gocb.PasswordAuthenticator{Username: user, Password: pass},
+		SecurityConfig: security,
 	})
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to connect to Couchbase cluster")
@@ -2,19 +2,75 @@ package dal
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/couchbase/gocb/v2"
 	"github.com/rs/zerolog/log"
+	"stealthcompany.com/api-rest/internal/metrics"
+	"stealthcompany.com/api-rest/internal/tracing"
 )
 
 // executeQueryWithContext executes a N1QL query with proper tenant isolation
 // Tenant isolation is handled by explicit bucket.scope.collection paths in queries
 func executeQueryWithContext(ctx context.Context, conn *Connection, tenantScope, query string) (*gocb.QueryResult, error) {
+	return executeQueryWithParams(ctx, conn, tenantScope, query, nil)
+}
+
+// executeQueryWithParams executes a N1QL query with named parameters
+func executeQueryWithParams(ctx context.Context, conn *Connection, tenantScope, query string, params map[string]interface{}) (*gocb.QueryResult, error) {
 	// Execute the query directly - tenant isolation is handled by explicit scope/collection paths
-	return conn.GetCluster().Query(query, &gocb.QueryOptions{Context: ctx})
+	return conn.GetCluster().Query(query, &gocb.QueryOptions{Context: ctx, NamedParameters: params})
+}
+
+// documentCollection is the part of a Couchbase collection used by the models, with decoded reads
+type documentCollection interface {
+	Get(docID string, valuePtr interface{}) (gocb.Cas, error)
+	Upsert(docID string, value interface{}, opts *gocb.UpsertOptions) (*gocb.MutationResult, error)
+	MutateIn(docID string, specs []gocb.MutateInSpec, opts *gocb.MutateInOptions) (*gocb.MutateInResult, error)
+	Remove(docID string, opts *gocb.RemoveOptions) (*gocb.MutationResult, error)
+}
+
+// errDocumentDecode wraps failures to decode a document that was found
+var errDocumentDecode = errors.New("failed to decode document")
+
+// gocbCollection adapts *gocb.Collection to documentCollection
+type gocbCollection struct {
+	*gocb.Collection
+	ctx context.Context
+}
+
+// Get fetches a document, decodes it into valuePtr and returns its CAS
+func (c gocbCollection) Get(docID string, valuePtr interface{}) (gocb.Cas, error) {
+	result, err := c.Collection.Get(docID, &gocb.GetOptions{Context: c.ctx})
+	if err != nil {
+		return 0, err
+	}
+	if err := result.Content(valuePtr); err != nil {
+		return 0, fmt.Errorf("%w: %w", errDocumentDecode, err)
+	}
+	return result.Cas(), nil
+}
+
+// collectionForResource returns the collection used for reads and upserts of a resource type (overridable in tests)
+var collectionForResource = func(ctx context.Context, rm *ResourceModel, resourceType string) documentCollection {
+	return gocbCollection{Collection: rm.getCollectionForResource(resourceType), ctx: ctx}
+}
+
+// queryRows is the part of *gocb.QueryResult used to read query rows
+type queryRows interface {
+	Next() bool
+	Row(valuePtr interface{}) error
+	Err() error
+	Close() error
+}
+
+// runQuery runs a N1QL query for a model (overridable in tests)
+var runQuery = func(ctx context.Context, rm *ResourceModel, query string, params map[string]interface{}) (queryRows, error) {
+	return executeQueryWithParams(ctx, rm.conn, rm.tenantScope, query, params)
 }
 
 // ResourceModel represents the database model for FHIR resources
@@ -23,6 +79,11 @@ type ResourceModel struct {
 	tenantScope string
 }
 
+// Compile-time check that ResourceModel keeps the upsert contract shared by both services
+var _ interface {
+	UpsertResource(context.Context, string, map[string]interface{}) error
+} = (*ResourceModel)(nil)
+
 // NewResourceModel creates a new resource model
 func NewResourceModel(conn *Connection) *ResourceModel {
 	return &ResourceModel{
@@ -41,18 +102,27 @@ func NewResourceModelWithTenant(conn *Connection, tenantScope string) *ResourceM
 
 // getCollectionForResource returns the appropriate collection for a resource type
 func (rm *ResourceModel) getCollectionForResource(resourceType string) *gocb.Collection {
-	scope := rm.conn.GetBucket().Scope(rm.tenantScope)
+	return rm.conn.GetBucket().Scope(rm.tenantScope).Collection(resourceCollectionName(resourceType))
+}
 
+// resourceCollectionName returns the collection name of a resource type
+func resourceCollectionName(resourceType string) string {
 	switch resourceType {
 	case "Encounter":
-		return scope.Collection("encounters")
+		return "encounters"
 	case "Patient":
-		return scope.Collection("patients")
+		return "patients"
 	case "Practitioner":
-		return scope.Collection("practitioners")
+		return "practitioners"
+	case "Observation":
+		return "observations"
+	case "Condition":
+		return "conditions"
+	case "MedicationRequest":
+		return "medication_requests"
 	default:
 		// Fallback to default collection
-		return scope.Collection("defaulty")
+		return "defaulty"
 	}
 }
 
@@ -64,8 +134,14 @@ type QueryRow struct {
 
 // PaginationParams represents pagination parameters
 type PaginationParams struct {
+	// Page selects the deprecated offset pagination when above 0; otherwise pages follow AfterCursor
 	Page  int
 	Count int
+	// AfterCursor is the nextCursor of the previous page, empty for the first page
+	AfterCursor string
+	// OrderBy is an optional N1QL ORDER BY expression list; META(d).id is always appended so pages stay stable.
+	// Sorted listings use offset pagination, since the cursor only holds the document key.
+	OrderBy string
 }
 
 // PaginatedResponse represents a paginated response
@@ -76,16 +152,39 @@ type PaginatedResponse struct {
 
 // GetResource retrieves a FHIR resource from Couchbase
 func (rm *ResourceModel) GetResource(ctx context.Context, docID string) (map[string]interface{}, error) {
+	resource, err := rm.GetCASedResource(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	return resource.Data, nil
+}
+
+// GetCASedResource retrieves a FHIR resource with the CAS of its document
+func (rm *ResourceModel) GetCASedResource(ctx context.Context, docID string) (_ *CASedResource, err error) {
 	// Extract resource type from docID (e.g., "Encounter/123" -> "Encounter")
 	resourceType := strings.Split(docID, "/")[0]
-	collection := rm.getCollectionForResource(resourceType)
 
+	// GetResource reads through here, so both are traced as the same operation
+	ctx, span := tracing.StartDBSpan(ctx, "ResourceModel.GetResource", "get", resourceType)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	collection := collectionForResource(ctx, rm, resourceType)
+
+	var data map[string]interface{}
 	start := time.Now()
-	result, err := collection.Get(docID, &gocb.GetOptions{Context: ctx})
+	cas, err := collection.Get(docID, &data)
 	duration := time.Since(start)
+	metrics.RecordCouchbaseOperation(ctx, "get", getStatus(err), duration)
 
+	if errors.Is(err, errDocumentDecode) {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("doc_id", docID).
+			Msg("Failed to decode resource")
+		return nil, fmt.Errorf("failed to decode resource: %w", err)
+	}
 	if err != nil {
-		log.Warn().
+		log.Ctx(ctx).Warn().
 			Err(err).
 			Str("doc_id", docID).
 			Str("tenant_scope", rm.tenantScope).
@@ -94,103 +193,345 @@ func (rm *ResourceModel) GetResource(ctx context.Context, docID string) (map[str
 		return nil, fmt.Errorf("resource not found: %w", err)
 	}
 
-	var data map[string]interface{}
-	err = result.Content(&data)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Str("doc_id", docID).
-			Msg("Failed to decode resource")
-		return nil, fmt.Errorf("failed to decode resource: %w", err)
-	}
-
-	log.Debug().
+	log.Ctx(ctx).Debug().
 		Str("doc_id", docID).
 		Str("tenant_scope", rm.tenantScope).
 		Str("collection", resourceType).
 		Dur("duration", duration).
 		Msg("Successfully retrieved resource")
-	return data, nil
+	return &CASedResource{Data: data, CAS: cas}, nil
 }
 
 // ListResources retrieves a paginated list of resources
 func (rm *ResourceModel) ListResources(ctx context.Context, resourceType string, params PaginationParams) (*PaginatedResponse, error) {
-	// Validate and set defaults
+	return rm.ListResourcesWhere(ctx, resourceType, params, "", nil)
+}
+
+// ListResourcesWhere retrieves a paginated list of resources matching a N1QL WHERE condition
+func (rm *ResourceModel) ListResourcesWhere(ctx context.Context, resourceType string, params PaginationParams, where string, queryParams map[string]interface{}) (_ *PaginatedResponse, err error) {
+	ctx, span := tracing.StartDBSpan(ctx, "ResourceModel.ListResources", "list", resourceType)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	params, afterCursor := normalizePaginationParams(params)
+	if afterCursor {
+		return rm.listResourcesAfter(ctx, resourceType, params, where, queryParams)
+	}
+
+	offset := (params.Page - 1) * params.Count
+
+	log.Ctx(ctx).Debug().
+		Str("resourceType", resourceType).
+		Int("page", params.Page).
+		Int("count", params.Count).
+		Int("offset", offset).
+		Msg("Querying resources")
+
+	// Fetch one extra row to know whether a next page exists
+	query := rm.offsetListQuery(resourceType, params, where, params.Count+1)
+
+	results, err := rm.queryResourceRows(ctx, query, queryParams)
+	if err != nil {
+		return nil, err
+	}
+
+	results, hasNext := trimPeekedResults(results, params.Count)
+
+	nextCursor := ""
+	if hasNext && params.OrderBy == "" {
+		// Lets offset clients move on to cursor pagination
+		nextCursor = FormatCursor(results[len(results)-1].ID)
+	}
+
+	response := &PaginatedResponse{
+		Data: results,
+		Pagination: map[string]interface{}{
+			"page":        params.Page,
+			"count":       params.Count,
+			"offset":      offset,
+			"totalItems":  len(results),
+			"hasNext":     hasNext,
+			"hasPrevious": params.Page > 1,
+			"nextCursor":  nextCursor,
+		},
+	}
+
+	log.Ctx(ctx).Debug().
+		Str("resourceType", resourceType).
+		Int("resultCount", len(results)).
+		Msg("Resources queried successfully")
+
+	return response, nil
+}
+
+// listResourcesAfter retrieves the page of resources whose document key follows params.AfterCursor (keyset pagination),
+// which uses the primary index instead of scanning the rows of all previous pages
+func (rm *ResourceModel) listResourcesAfter(ctx context.Context, resourceType string, params PaginationParams, where string, queryParams map[string]interface{}) (*PaginatedResponse, error) {
+	afterID, err := ParseCursor(params.AfterCursor)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Debug().
+		Str("resourceType", resourceType).
+		Int("count", params.Count).
+		Str("afterId", afterID).
+		Msg("Querying resources after cursor")
+
+	// Fetch one extra row to know whether a next page exists
+	query, cursorParams := rm.cursorListQuery(resourceType, afterID, where, queryParams, params.Count+1)
+
+	results, err := rm.queryResourceRows(ctx, query, cursorParams)
+	if err != nil {
+		return nil, err
+	}
+
+	results, hasNext := trimPeekedResults(results, params.Count)
+
+	nextCursor := ""
+	if hasNext {
+		nextCursor = FormatCursor(results[len(results)-1].ID)
+	}
+
+	response := &PaginatedResponse{
+		Data: results,
+		Pagination: map[string]interface{}{
+			"count":       params.Count,
+			"totalItems":  len(results),
+			"hasNext":     hasNext,
+			"hasPrevious": afterID != "",
+			"nextCursor":  nextCursor,
+		},
+	}
+
+	log.Ctx(ctx).Debug().
+		Str("resourceType", resourceType).
+		Int("resultCount", len(results)).
+		Msg("Resources queried successfully")
+
+	return response, nil
+}
+
+// listCollectionName returns the collection queried by list requests: encounters, patients, practitioners
+func listCollectionName(resourceType string) string {
+	// Resource types with several words have snake_case collections
+	if resourceType == "MedicationRequest" {
+		return resourceCollectionName(resourceType)
+	}
+	return strings.ToLower(resourceType) + "s"
+}
+
+// normalizePaginationParams applies the default page size and reports whether the page follows
+// params.AfterCursor (keyset pagination) rather than an offset
+func normalizePaginationParams(params PaginationParams) (PaginationParams, bool) {
 	if params.Count <= 0 || params.Count > 10000 {
 		params.Count = 100
 	}
+	if params.Page <= 0 && params.OrderBy == "" {
+		return params, true
+	}
 	if params.Page <= 0 {
 		params.Page = 1
 	}
+	return params, false
+}
 
-	offset := (params.Page - 1) * params.Count
+// offsetListQuery builds the query reading up to limit resources of params.Page, sorted by params.OrderBy
+func (rm *ResourceModel) offsetListQuery(resourceType string, params PaginationParams, where string, limit int) string {
+	// Use scoped collection query instead of bucket-wide query
+	whereClause := ""
+	if where != "" {
+		whereClause = " WHERE " + where
+	}
+	orderBy := "META(d).id"
+	if params.OrderBy != "" {
+		orderBy = params.OrderBy + ", META(d).id"
+	}
+	return fmt.Sprintf("SELECT META(d).id AS id, d AS resource FROM `%s`.`%s`.`%s` AS d%s ORDER BY %s LIMIT %d OFFSET %d",
+		rm.conn.GetBucketName(), rm.tenantScope, listCollectionName(resourceType), whereClause, orderBy, limit, (params.Page-1)*params.Count)
+}
+
+// cursorListQuery builds the query reading up to limit resources whose document key follows afterID,
+// and its named parameters
+func (rm *ResourceModel) cursorListQuery(resourceType, afterID, where string, queryParams map[string]interface{}, limit int) (string, map[string]interface{}) {
+	var conditions []string
+	if where != "" {
+		conditions = append(conditions, where)
+	}
+	cursorParams := make(map[string]interface{}, len(queryParams)+1)
+	for name, value := range queryParams {
+		cursorParams[name] = value
+	}
+	if afterID != "" {
+		conditions = append(conditions, "META(d).id > $cursor")
+		cursorParams["cursor"] = afterID
+	}
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf("SELECT META(d).id AS id, d AS resource FROM `%s`.`%s`.`%s` AS d%s ORDER BY META(d).id LIMIT %d",
+		rm.conn.GetBucketName(), rm.tenantScope, listCollectionName(resourceType), whereClause, limit)
+	return query, cursorParams
+}
+
+// ErrStopStreaming is returned by a StreamListResources callback to stop reading rows without failing the listing
+var ErrStopStreaming = errors.New("stop streaming")
+
+// StreamListResources calls fn for each resource of a page as its row is read from the query result,
+// so a page is never held in memory. Unlike ListResources it doesn't read past the page,
+// so StreamedPagination only knows a next page may exist when the page is full.
+func (rm *ResourceModel) StreamListResources(ctx context.Context, resourceType string, params PaginationParams, fn func(QueryRow) error) error {
+	return rm.StreamListResourcesWhere(ctx, resourceType, params, "", nil, fn)
+}
+
+// StreamListResourcesWhere streams the page of resources matching a N1QL WHERE condition, like StreamListResources
+func (rm *ResourceModel) StreamListResourcesWhere(ctx context.Context, resourceType string, params PaginationParams, where string, queryParams map[string]interface{}, fn func(QueryRow) error) (err error) {
+	ctx, span := tracing.StartDBSpan(ctx, "ResourceModel.StreamListResources", "list", resourceType)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	params, afterCursor := normalizePaginationParams(params)
+
+	query := ""
+	if afterCursor {
+		afterID, err := ParseCursor(params.AfterCursor)
+		if err != nil {
+			return err
+		}
+		query, queryParams = rm.cursorListQuery(resourceType, afterID, where, queryParams, params.Count)
+	} else {
+		query = rm.offsetListQuery(resourceType, params, where, params.Count)
+	}
 
-	log.Debug().
+	log.Ctx(ctx).Debug().
 		Str("resourceType", resourceType).
 		Int("page", params.Page).
 		Int("count", params.Count).
-		Int("offset", offset).
-		Msg("Querying resources")
+		Msg("Streaming resources")
 
-	// Use scoped collection query instead of bucket-wide query
-	collectionName := strings.ToLower(resourceType) + "s" // encounters, patients, practitioners
-	query := fmt.Sprintf("SELECT META(d).id AS id, d AS resource FROM `%s`.`%s`.`%s` AS d ORDER BY META(d).id LIMIT %d OFFSET %d",
-		rm.conn.GetBucketName(), rm.tenantScope, collectionName, params.Count, offset)
+	return rm.streamResourceRows(ctx, query, queryParams, fn)
+}
+
+// StreamedPagination returns the pagination of a page read by StreamListResources, in the format of ListResources,
+// from the number of rows streamed and the key of the last one
+func StreamedPagination(params PaginationParams, streamed int, lastID string) map[string]interface{} {
+	params, afterCursor := normalizePaginationParams(params)
 
-	rows, err := executeQueryWithContext(ctx, rm.conn, rm.tenantScope, query)
+	// Without a peeked row, a full page may be followed by an empty one
+	hasNext := streamed == params.Count
+	nextCursor := ""
+	if hasNext && params.OrderBy == "" {
+		nextCursor = FormatCursor(lastID)
+	}
+
+	if afterCursor {
+		return map[string]interface{}{
+			"count":       params.Count,
+			"totalItems":  streamed,
+			"hasNext":     hasNext,
+			"hasPrevious": params.AfterCursor != "",
+			"nextCursor":  nextCursor,
+		}
+	}
+	return map[string]interface{}{
+		"page":        params.Page,
+		"count":       params.Count,
+		"offset":      (params.Page - 1) * params.Count,
+		"totalItems":  streamed,
+		"hasNext":     hasNext,
+		"hasPrevious": params.Page > 1,
+		"nextCursor":  nextCursor,
+	}
+}
+
+// queryResourceRows runs a list query and decodes its rows, skipping rows that fail to decode
+func (rm *ResourceModel) queryResourceRows(ctx context.Context, query string, queryParams map[string]interface{}) ([]QueryRow, error) {
+	// An empty collection must encode as "data": [] rather than null
+	results := make([]QueryRow, 0)
+	err := rm.streamResourceRows(ctx, query, queryParams, func(row QueryRow) error {
+		results = append(results, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// streamResourceRows runs a list query and calls fn for each row as it is decoded, skipping rows that fail to decode
+func (rm *ResourceModel) streamResourceRows(ctx context.Context, query string, queryParams map[string]interface{}, fn func(QueryRow) error) error {
+	start := time.Now()
+	rows, err := runQuery(ctx, rm, query, queryParams)
+	metrics.RecordCouchbaseOperation(ctx, "query", operationStatus(err), time.Since(start))
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("query", query).
 			Msg("Query failed")
-		return nil, fmt.Errorf("query failed: %w", err)
+		return fmt.Errorf("query failed: %w", err)
 	}
 	defer rows.Close()
 
-	var results []QueryRow
 	for rows.Next() {
 		var row QueryRow
 		err := rows.Row(&row)
 		if err != nil {
-			log.Warn().
+			log.Ctx(ctx).Warn().
 				Err(err).
 				Msg("Failed to decode query row")
 			continue
 		}
-		results = append(results, row)
+		if err := fn(row); err != nil {
+			if errors.Is(err, ErrStopStreaming) {
+				return nil
+			}
+			return err
+		}
 	}
+	return nil
+}
 
-	response := &PaginatedResponse{
-		Data: results,
-		Pagination: map[string]interface{}{
-			"page":       params.Page,
-			"count":      params.Count,
-			"offset":     offset,
-			"totalItems": len(results),
-			"hasNext":    len(results) == params.Count,
-		},
+// operationStatus returns the status label of a Couchbase operation result
+func operationStatus(err error) string {
+	if err != nil {
+		return "error"
 	}
+	return "success"
+}
 
-	log.Debug().
-		Str("resourceType", resourceType).
-		Int("resultCount", len(results)).
-		Msg("Resources queried successfully")
+// getStatus returns the status label of a document read, "miss" for documents that don't exist
+func getStatus(err error) string {
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return "miss"
+	}
+	return operationStatus(err)
+}
 
-	return response, nil
+// trimPeekedResults drops the extra peeked row and reports whether a next page exists
+func trimPeekedResults(results []QueryRow, count int) ([]QueryRow, bool) {
+	if len(results) > count {
+		return results[:count], true
+	}
+	return results, false
 }
 
 // UpsertResource upserts a FHIR resource to Couchbase
-func (rm *ResourceModel) UpsertResource(ctx context.Context, docID string, data map[string]interface{}) error {
+func (rm *ResourceModel) UpsertResource(ctx context.Context, docID string, data map[string]interface{}) (err error) {
 	// Extract resource type from docID (e.g., "Encounter/123" -> "Encounter")
 	resourceType := strings.Split(docID, "/")[0]
-	collection := rm.getCollectionForResource(resourceType)
+
+	ctx, span := tracing.StartDBSpan(ctx, "ResourceModel.UpsertResource", "upsert", resourceType)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	collection := collectionForResource(ctx, rm, resourceType)
 
 	start := time.Now()
-	_, err := collection.Upsert(docID, data, &gocb.UpsertOptions{Context: ctx})
+	_, err = collection.Upsert(docID, data, upsertOptionsForScope(&gocb.UpsertOptions{Context: ctx}, rm.tenantScope))
 	duration := time.Since(start)
+	metrics.RecordCouchbaseOperation(ctx, "upsert", operationStatus(err), duration)
 
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("doc_id", docID).
 			Str("tenant_scope", rm.tenantScope).
@@ -199,7 +540,14 @@ func (rm *ResourceModel) UpsertResource(ctx context.Context, docID string, data
 		return fmt.Errorf("failed to upsert resource %s: %w", docID, err)
 	}
 
-	log.Debug().
+	// A new or changed encounter may change its patient's summary counts
+	if resourceType == "Encounter" {
+		if patientID, ok := data["subjectPatientId"].(string); ok && patientID != "" {
+			InvalidatePatientSummary(rm.tenantScope, patientID)
+		}
+	}
+
+	log.Ctx(ctx).Debug().
 		Str("doc_id", docID).
 		Str("tenant_scope", rm.tenantScope).
 		Str("collection", resourceType).
@@ -212,21 +560,21 @@ func (rm *ResourceModel) UpsertResource(ctx context.Context, docID string, data
 func (rm *ResourceModel) ResourceExists(ctx context.Context, docID string) (bool, error) {
 	// Extract resource type from docID (e.g., "Encounter/123" -> "Encounter")
 	resourceType := strings.Split(docID, "/")[0]
-	collection := rm.getCollectionForResource(resourceType)
+	collection := collectionForResource(ctx, rm, resourceType)
 
+	var doc json.RawMessage
 	start := time.Now()
-	_, err := collection.Get(docID, &gocb.GetOptions{Context: ctx})
+	_, err := collection.Get(docID, &doc)
 	duration := time.Since(start)
 
 	if err != nil {
-		// Check if it's a key not found error
-		if strings.Contains(err.Error(), "key not found") {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
 			return false, nil
 		}
 		return false, fmt.Errorf("failed to check resource existence %s: %w", docID, err)
 	}
 
-	log.Debug().
+	log.Ctx(ctx).Debug().
 		Str("doc_id", docID).
 		Str("tenant_scope", rm.tenantScope).
 		Str("collection", resourceType).
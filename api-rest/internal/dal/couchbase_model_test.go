@@ -0,0 +1,524 @@
+package dal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/couchbase/gocb/v2"
+	"stealthcompany.com/pkg/testutil"
+)
+
+// useMockBucket routes collection reads, upserts, review transactions and the encounter summary cache of all
+// models to an in-memory bucket
+func useMockBucket(t *testing.T) *testutil.MockBucket {
+	t.Helper()
+
+	bucket := testutil.NewMockBucket("evtechallenge")
+	orig := collectionForResource
+	collectionForResource = func(ctx context.Context, rm *ResourceModel, resourceType string) documentCollection {
+		return bucket.Collection(rm.tenantScope, resourceCollectionName(resourceType))
+	}
+	origSummary := summaryCollection
+	summaryCollection = func(ctx context.Context, rm *ResourceModel) documentCollection {
+		return bucket.Collection(rm.tenantScope, "defaulty")
+	}
+	origTransaction := runReviewTransaction
+	runReviewTransaction = func(ctx context.Context, rm *ReviewModel, resourceType, docID string, mutate func(map[string]interface{})) error {
+		return mockTransaction(bucket.Collection(rm.resourceModel.tenantScope, resourceCollectionName(resourceType)), docID, mutate)
+	}
+	t.Cleanup(func() {
+		collectionForResource = orig
+		summaryCollection = origSummary
+		runReviewTransaction = origTransaction
+	})
+	return bucket
+}
+
+// mockTransactionMu makes the CAS check and the write of a mockTransaction commit atomic
+var mockTransactionMu sync.Mutex
+
+// mockTransaction emulates a read-modify-replace transaction over a MockCollection: like gocb it runs
+// the attempt again when the document was changed by another writer between the read and the commit
+func mockTransaction(collection *testutil.MockCollection, docID string, mutate func(map[string]interface{})) error {
+	for {
+		var doc map[string]interface{}
+		cas, err := collection.Get(docID, &doc)
+		if err != nil {
+			return err
+		}
+		mutate(doc)
+
+		mockTransactionMu.Lock()
+		var current map[string]interface{}
+		currentCas, err := collection.Get(docID, &current)
+		if err == nil && currentCas == cas {
+			err = collection.AddFixture(docID, doc)
+			mockTransactionMu.Unlock()
+			return err
+		}
+		mockTransactionMu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// useMockCluster answers model queries from an in-memory cluster
+func useMockCluster(t *testing.T, cluster *testutil.MockCluster) {
+	t.Helper()
+
+	orig := runQuery
+	runQuery = func(ctx context.Context, rm *ResourceModel, query string, params map[string]interface{}) (queryRows, error) {
+		return cluster.Query(query, &gocb.QueryOptions{Context: ctx, NamedParameters: params})
+	}
+	t.Cleanup(func() {
+		runQuery = orig
+	})
+}
+
+// testResourceModel returns a model of a tenant scope in the evtechallenge bucket
+func testResourceModel(tenantScope string) *ResourceModel {
+	return NewResourceModelWithTenant(&Connection{bucketName: "evtechallenge"}, tenantScope)
+}
+
+// queryPage simulates "LIMIT count+1 OFFSET offset" over a collection of total documents
+func queryPage(total, page, count int) []QueryRow {
+	offset := (page - 1) * count
+	var rows []QueryRow
+	for i := offset; i < total && i < offset+count+1; i++ {
+		rows = append(rows, QueryRow{ID: fmt.Sprintf("Encounter/%d", i)})
+	}
+	return rows
+}
+
+func TestTrimPeekedResults(t *testing.T) {
+	tests := []struct {
+		name            string
+		total           int
+		page            int
+		count           int
+		expectedItems   int
+		expectedHasNext bool
+	}{
+		{
+			name:            "Page size 10 with more pages",
+			total:           25,
+			page:            1,
+			count:           10,
+			expectedItems:   10,
+			expectedHasNext: true,
+		},
+		{
+			name:            "Page size 10 partial last page",
+			total:           25,
+			page:            3,
+			count:           10,
+			expectedItems:   5,
+			expectedHasNext: false,
+		},
+		{
+			name:            "Page size 100 exactly full single page",
+			total:           100,
+			page:            1,
+			count:           100,
+			expectedItems:   100,
+			expectedHasNext: false,
+		},
+		{
+			name:            "Total divisible by page size on last page",
+			total:           30,
+			page:            3,
+			count:           10,
+			expectedItems:   10,
+			expectedHasNext: false,
+		},
+		{
+			name:            "Total divisible by page size before last page",
+			total:           30,
+			page:            2,
+			count:           10,
+			expectedItems:   10,
+			expectedHasNext: true,
+		},
+		{
+			name:            "Empty collection",
+			total:           0,
+			page:            1,
+			count:           10,
+			expectedItems:   0,
+			expectedHasNext: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, hasNext := trimPeekedResults(queryPage(tt.total, tt.page, tt.count), tt.count)
+
+			if len(results) != tt.expectedItems {
+				t.Errorf("Expected %d items, got %d", tt.expectedItems, len(results))
+			}
+			if hasNext != tt.expectedHasNext {
+				t.Errorf("Expected hasNext %v, got %v", tt.expectedHasNext, hasNext)
+			}
+		})
+	}
+}
+
+func TestResourceModelListResources(t *testing.T) {
+	rows := func(n int) []interface{} {
+		var result []interface{}
+		for i := 0; i < n; i++ {
+			result = append(result, map[string]interface{}{
+				"id":       fmt.Sprintf("Patient/%d", i),
+				"resource": map[string]interface{}{"resourceType": "Patient", "id": fmt.Sprint(i)},
+			})
+		}
+		return result
+	}
+
+	tests := []struct {
+		name            string
+		rows            int
+		params          PaginationParams
+		expectedItems   int
+		expectedHasNext bool
+		expectedLimit   string
+	}{
+		{
+			name:            "Peeked row means a next page",
+			rows:            3,
+			params:          PaginationParams{Page: 1, Count: 2},
+			expectedItems:   2,
+			expectedHasNext: true,
+			expectedLimit:   "LIMIT 3 OFFSET 0",
+		},
+		{
+			name:            "Last page",
+			rows:            1,
+			params:          PaginationParams{Page: 2, Count: 2},
+			expectedItems:   1,
+			expectedHasNext: false,
+			expectedLimit:   "LIMIT 3 OFFSET 2",
+		},
+		{
+			name:            "Invalid count uses default",
+			rows:            0,
+			params:          PaginationParams{Page: 1, Count: 0},
+			expectedItems:   0,
+			expectedHasNext: false,
+			expectedLimit:   "LIMIT 101 OFFSET 0",
+		},
+		{
+			name:            "No page uses keyset pagination",
+			rows:            3,
+			params:          PaginationParams{Count: 2},
+			expectedItems:   2,
+			expectedHasNext: true,
+			expectedLimit:   "ORDER BY META(d).id LIMIT 3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &testutil.MockCluster{Result: &testutil.MockQueryResult{Rows: rows(tt.rows)}}
+			useMockCluster(t, cluster)
+
+			response, err := testResourceModel("tenant1").ListResources(context.Background(), "Patient", tt.params)
+			if err != nil {
+				t.Fatalf("ListResources() error = %v", err)
+			}
+
+			if len(response.Data) != tt.expectedItems {
+				t.Errorf("Expected %d items, got %d", tt.expectedItems, len(response.Data))
+			}
+			if response.Pagination["hasNext"] != tt.expectedHasNext {
+				t.Errorf("Expected hasNext %v, got %v", tt.expectedHasNext, response.Pagination["hasNext"])
+			}
+			if tt.expectedItems > 0 && response.Data[0].Resource["resourceType"] != "Patient" {
+				t.Errorf("Expected decoded resource, got %v", response.Data[0].Resource)
+			}
+
+			calls := cluster.QueryCalls()
+			if len(calls) != 1 {
+				t.Fatalf("Expected 1 query, got %d", len(calls))
+			}
+			if !strings.Contains(calls[0].Statement, "`evtechallenge`.`tenant1`.`patients`") {
+				t.Errorf("Expected query on the tenant patients collection, got %s", calls[0].Statement)
+			}
+			if !strings.HasSuffix(calls[0].Statement, tt.expectedLimit) {
+				t.Errorf("Expected query ending with %q, got %s", tt.expectedLimit, calls[0].Statement)
+			}
+		})
+	}
+}
+
+func TestResourceModelListResourcesAfterCursor(t *testing.T) {
+	cluster := &testutil.MockCluster{Result: &testutil.MockQueryResult{Rows: []interface{}{
+		map[string]interface{}{"id": "Patient/3", "resource": map[string]interface{}{"id": "3"}},
+		map[string]interface{}{"id": "Patient/4", "resource": map[string]interface{}{"id": "4"}},
+		map[string]interface{}{"id": "Patient/5", "resource": map[string]interface{}{"id": "5"}},
+	}}}
+	useMockCluster(t, cluster)
+
+	params := PaginationParams{Count: 2, AfterCursor: FormatCursor("Patient/2")}
+	response, err := testResourceModel("tenant1").ListResources(context.Background(), "Patient", params)
+	if err != nil {
+		t.Fatalf("ListResources() error = %v", err)
+	}
+
+	if response.Pagination["nextCursor"] != FormatCursor("Patient/4") {
+		t.Errorf("Expected nextCursor of Patient/4, got %v", response.Pagination["nextCursor"])
+	}
+	if response.Pagination["hasPrevious"] != true {
+		t.Errorf("Expected hasPrevious true, got %v", response.Pagination["hasPrevious"])
+	}
+	if _, ok := response.Pagination["page"]; ok {
+		t.Errorf("Expected no page in keyset pagination, got %v", response.Pagination)
+	}
+
+	calls := cluster.QueryCalls()
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 query, got %d", len(calls))
+	}
+	expected := "AS d WHERE META(d).id > $cursor ORDER BY META(d).id LIMIT 3"
+	if !strings.HasSuffix(calls[0].Statement, expected) {
+		t.Errorf("Expected query ending with %q, got %s", expected, calls[0].Statement)
+	}
+	if cursor := calls[0].Options.NamedParameters["cursor"]; cursor != "Patient/2" {
+		t.Errorf("Expected cursor parameter Patient/2, got %v", cursor)
+	}
+}
+
+func TestResourceModelListResourcesLastPageHasNoCursor(t *testing.T) {
+	for _, params := range []PaginationParams{{Count: 2}, {Page: 2, Count: 2}} {
+		useMockCluster(t, &testutil.MockCluster{Result: &testutil.MockQueryResult{Rows: []interface{}{
+			map[string]interface{}{"id": "Patient/1", "resource": map[string]interface{}{"id": "1"}},
+		}}})
+
+		response, err := testResourceModel("tenant1").ListResources(context.Background(), "Patient", params)
+		if err != nil {
+			t.Fatalf("ListResources() error = %v", err)
+		}
+		if response.Pagination["nextCursor"] != "" {
+			t.Errorf("Expected empty nextCursor on the last page, got %v", response.Pagination["nextCursor"])
+		}
+	}
+}
+
+func TestResourceModelListResourcesInvalidCursor(t *testing.T) {
+	cluster := &testutil.MockCluster{}
+	useMockCluster(t, cluster)
+
+	_, err := testResourceModel("tenant1").ListResources(context.Background(), "Patient", PaginationParams{AfterCursor: "not base64!"})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+	if calls := cluster.QueryCalls(); len(calls) != 0 {
+		t.Errorf("Expected no query for an invalid cursor, got %d", len(calls))
+	}
+}
+
+func TestResourceModelListResourcesEmptyCollection(t *testing.T) {
+	useMockCluster(t, &testutil.MockCluster{})
+
+	response, err := testResourceModel("tenant1").ListResources(context.Background(), "Patient", PaginationParams{Page: 1, Count: 10})
+	if err != nil {
+		t.Fatalf("ListResources() error = %v", err)
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("Failed to encode response: %v", err)
+	}
+	if !strings.Contains(string(body), `"data":[]`) {
+		t.Errorf("Expected \"data\":[] for an empty collection, got %s", body)
+	}
+}
+
+func TestResourceModelListResourcesQueryError(t *testing.T) {
+	useMockCluster(t, &testutil.MockCluster{QueryErr: gocb.ErrTimeout})
+
+	if _, err := testResourceModel("tenant1").ListResources(context.Background(), "Patient", PaginationParams{}); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+}
+
+// lazyRows produces query rows one at a time, like a N1QL result set, counting the rows read so far
+type lazyRows struct {
+	total int
+	read  int
+}
+
+func (r *lazyRows) Next() bool {
+	if r.read >= r.total {
+		return false
+	}
+	r.read++
+	return true
+}
+
+func (r *lazyRows) Row(valuePtr interface{}) error {
+	row := valuePtr.(*QueryRow)
+	row.ID = fmt.Sprintf("Encounter/%d", r.read)
+	row.Resource = map[string]interface{}{"id": fmt.Sprintf("%d", r.read)}
+	return nil
+}
+
+func (r *lazyRows) Err() error   { return nil }
+func (r *lazyRows) Close() error { return nil }
+
+// useLazyRows answers model queries with rows, recording the queries
+func useLazyRows(t *testing.T, rows *lazyRows) *[]string {
+	t.Helper()
+
+	var queries []string
+	orig := runQuery
+	runQuery = func(ctx context.Context, rm *ResourceModel, query string, params map[string]interface{}) (queryRows, error) {
+		queries = append(queries, query)
+		return rows, nil
+	}
+	t.Cleanup(func() {
+		runQuery = orig
+	})
+	return &queries
+}
+
+func TestResourceModelStreamListResourcesHoldsOneRow(t *testing.T) {
+	rows := &lazyRows{total: 5000}
+	queries := useLazyRows(t, rows)
+
+	streamed := 0
+	maxHeld := 0
+	err := testResourceModel("tenant1").StreamListResources(context.Background(), "Encounter", PaginationParams{Count: 5000}, func(row QueryRow) error {
+		// Rows read from the result set but not yet handed back, including this one
+		if held := rows.read - streamed; held > maxHeld {
+			maxHeld = held
+		}
+		streamed++
+		if row.ID != fmt.Sprintf("Encounter/%d", streamed) {
+			t.Errorf("Expected row Encounter/%d, got %s", streamed, row.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamListResources() error = %v", err)
+	}
+	if streamed != 5000 {
+		t.Errorf("Expected 5000 rows, got %d", streamed)
+	}
+	if maxHeld != 1 {
+		t.Errorf("Expected a single row held at a time, got up to %d", maxHeld)
+	}
+	if len(*queries) != 1 || !strings.Contains((*queries)[0], "LIMIT 5000") {
+		t.Errorf("Expected a single query without peeked row, got %v", *queries)
+	}
+}
+
+func TestResourceModelStreamListResourcesStop(t *testing.T) {
+	rows := &lazyRows{total: 100}
+	useLazyRows(t, rows)
+
+	streamed := 0
+	err := testResourceModel("tenant1").StreamListResources(context.Background(), "Encounter", PaginationParams{Count: 100}, func(row QueryRow) error {
+		streamed++
+		if streamed == 3 {
+			return ErrStopStreaming
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected ErrStopStreaming to end the listing without error, got %v", err)
+	}
+	if rows.read != 3 {
+		t.Errorf("Expected reading to stop after 3 rows, read %d", rows.read)
+	}
+
+	failure := errors.New("client gone")
+	err = testResourceModel("tenant1").StreamListResources(context.Background(), "Encounter", PaginationParams{Count: 100}, func(row QueryRow) error {
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected the callback error, got %v", err)
+	}
+}
+
+func TestStreamedPagination(t *testing.T) {
+	tests := []struct {
+		name            string
+		params          PaginationParams
+		streamed        int
+		expectedHasNext bool
+		expectedCursor  string
+	}{
+		{
+			name:            "Full cursor page",
+			params:          PaginationParams{Count: 10},
+			streamed:        10,
+			expectedHasNext: true,
+			expectedCursor:  FormatCursor("Encounter/10"),
+		},
+		{
+			name:     "Partial cursor page",
+			params:   PaginationParams{Count: 10},
+			streamed: 4,
+		},
+		{
+			name:            "Full offset page",
+			params:          PaginationParams{Page: 2, Count: 10},
+			streamed:        10,
+			expectedHasNext: true,
+			expectedCursor:  FormatCursor("Encounter/10"),
+		},
+		{
+			name:            "Full sorted page has no cursor",
+			params:          PaginationParams{Count: 10, OrderBy: "d.status ASC"},
+			streamed:        10,
+			expectedHasNext: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pagination := StreamedPagination(tt.params, tt.streamed, "Encounter/10")
+			if pagination["hasNext"] != tt.expectedHasNext {
+				t.Errorf("Expected hasNext %v, got %v", tt.expectedHasNext, pagination["hasNext"])
+			}
+			if pagination["nextCursor"] != tt.expectedCursor {
+				t.Errorf("Expected nextCursor %q, got %v", tt.expectedCursor, pagination["nextCursor"])
+			}
+			if pagination["totalItems"] != tt.streamed {
+				t.Errorf("Expected totalItems %d, got %v", tt.streamed, pagination["totalItems"])
+			}
+		})
+	}
+}
+
+func TestResourceModelResourceExists(t *testing.T) {
+	bucket := useMockBucket(t)
+	bucket.Collection("tenant1", "encounters").AddFixture("Encounter/1", map[string]interface{}{"id": "1"})
+	rm := testResourceModel("tenant1")
+
+	if exists, err := rm.ResourceExists(context.Background(), "Encounter/1"); err != nil || !exists {
+		t.Errorf("Expected Encounter/1 to exist, got %v, %v", exists, err)
+	}
+	// The mock wraps gocb.ErrDocumentNotFound, which must be recognised without matching its message
+	if exists, err := rm.ResourceExists(context.Background(), "Encounter/missing"); err != nil || exists {
+		t.Errorf("Expected Encounter/missing not to exist, got %v, %v", exists, err)
+	}
+
+	orig := collectionForResource
+	collectionForResource = func(ctx context.Context, rm *ResourceModel, resourceType string) documentCollection {
+		return failingGetCollection{MockCollection: testutil.NewMockCollection(), err: errors.New("key not found in proxy cache")}
+	}
+	t.Cleanup(func() {
+		collectionForResource = orig
+	})
+	if _, err := rm.ResourceExists(context.Background(), "Encounter/1"); err == nil {
+		t.Error("Expected an error that is not gocb.ErrDocumentNotFound to be returned")
+	}
+}
@@ -0,0 +1,69 @@
+package dal
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// couchbaseTLSConfig holds the TLS settings of Couchbase connections
+type couchbaseTLSConfig struct {
+	enabled    bool
+	caCertPath string
+	skipVerify bool
+}
+
+// couchbaseTLSConfigFromEnv reads COUCHBASE_TLS_ENABLED, COUCHBASE_TLS_CA_CERT_PATH and COUCHBASE_TLS_SKIP_VERIFY
+func couchbaseTLSConfigFromEnv() (couchbaseTLSConfig, error) {
+	var cfg couchbaseTLSConfig
+	var err error
+
+	cfg.enabled, err = strconv.ParseBool(getEnv("COUCHBASE_TLS_ENABLED", "false"))
+	if err != nil {
+		return couchbaseTLSConfig{}, fmt.Errorf("invalid COUCHBASE_TLS_ENABLED: %w", err)
+	}
+	cfg.skipVerify, err = strconv.ParseBool(getEnv("COUCHBASE_TLS_SKIP_VERIFY", "false"))
+	if err != nil {
+		return couchbaseTLSConfig{}, fmt.Errorf("invalid COUCHBASE_TLS_SKIP_VERIFY: %w", err)
+	}
+	cfg.caCertPath = os.Getenv("COUCHBASE_TLS_CA_CERT_PATH")
+	return cfg, nil
+}
+
+// connectionString switches a couchbase:// URL to couchbases:// when TLS is enabled
+func (cfg couchbaseTLSConfig) connectionString(url string) string {
+	if !cfg.enabled {
+		return url
+	}
+	if rest, ok := strings.CutPrefix(url, "couchbase://"); ok {
+		return "couchbases://" + rest
+	}
+	return url
+}
+
+// securityConfig returns the cluster security options; without a CA certificate the SDK default roots are used
+func (cfg couchbaseTLSConfig) securityConfig() (gocb.SecurityConfig, error) {
+	if !cfg.enabled {
+		return gocb.SecurityConfig{}, nil
+	}
+
+	security := gocb.SecurityConfig{TLSSkipVerify: cfg.skipVerify}
+	if cfg.caCertPath == "" {
+		return security, nil
+	}
+
+	pem, err := os.ReadFile(cfg.caCertPath)
+	if err != nil {
+		return gocb.SecurityConfig{}, fmt.Errorf("failed to read Couchbase CA certificate: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return gocb.SecurityConfig{}, fmt.Errorf("no certificates found in %s", cfg.caCertPath)
+	}
+	security.TLSRootCAs = roots
+	return security, nil
+}
@@ -0,0 +1,28 @@
+package dal
+
+import (
+	"encoding/base64"
+	"errors"
+)
+
+// ErrInvalidCursor is returned for cursor values that were not produced by FormatCursor
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// FormatCursor encodes the document key of the last row of a page as a URL-safe base64 cursor
+func FormatCursor(docID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(docID))
+}
+
+// ParseCursor decodes a cursor produced by FormatCursor back to the document key.
+// An empty cursor decodes to an empty key, meaning the first page.
+func ParseCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(decoded) == 0 {
+		return "", ErrInvalidCursor
+	}
+	return string(decoded), nil
+}
@@ -0,0 +1,34 @@
+package dal
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, docID := range []string{"Patient/1", "Encounter/a-b_c.d", "Practitioner/ünïcode"} {
+		cursor := FormatCursor(docID)
+		got, err := ParseCursor(cursor)
+		if err != nil {
+			t.Fatalf("ParseCursor(%s) error = %v", cursor, err)
+		}
+		if got != docID {
+			t.Errorf("ParseCursor(FormatCursor(%q)) = %q", docID, got)
+		}
+	}
+}
+
+func TestParseCursorEmpty(t *testing.T) {
+	got, err := ParseCursor("")
+	if err != nil || got != "" {
+		t.Errorf("ParseCursor(\"\") = %q, %v, expected first page", got, err)
+	}
+}
+
+func TestParseCursorInvalid(t *testing.T) {
+	for _, cursor := range []string{"not base64!", "UGF0aWVudC8x=="} {
+		if _, err := ParseCursor(cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("ParseCursor(%q): expected ErrInvalidCursor, got %v", cursor, err)
+		}
+	}
+}
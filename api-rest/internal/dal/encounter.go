@@ -4,10 +4,202 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
+// EncounterStatuses lists the valid FHIR R4 Encounter status codes
+var EncounterStatuses = []string{
+	"planned",
+	"arrived",
+	"triaged",
+	"in-progress",
+	"onleave",
+	"finished",
+	"cancelled",
+	"entered-in-error",
+	"unknown",
+}
+
+// encounterSortFields maps the _sort fields of encounter lists to their N1QL expressions
+var encounterSortFields = map[string]string{
+	"date":   "d.period.`start`",
+	"status": "d.status",
+	"id":     "d.id",
+}
+
+// Sort directions of a _sort field
+const (
+	SortAscending  = "asc"
+	SortDescending = "desc"
+)
+
+// SortField is one field:direction pair of a _sort parameter
+type SortField struct {
+	Field     string
+	Direction string
+}
+
+// Prefixes of a FHIR date search value, e.g. ge2023-01-01
+const (
+	DatePrefixEqual        = "eq"
+	DatePrefixGreater      = "gt"
+	DatePrefixGreaterEqual = "ge"
+	DatePrefixLess         = "lt"
+	DatePrefixLessEqual    = "le"
+)
+
+// dateSearchLayouts are the precisions accepted in date search values, from year to full date-time
+var dateSearchLayouts = []string{"2006", "2006-01", "2006-01-02", time.RFC3339}
+
+// DateComparison is one prefixed value of a date search parameter
+type DateComparison struct {
+	Prefix string
+	Value  string
+}
+
+// EncounterFilter holds optional filters and sort order for listing encounters
+type EncounterFilter struct {
+	Status []string
+	// Date bounds the encounter period: lower bounds apply to period.start, upper bounds to period.end
+	Date []DateComparison
+	// Patient is the bare ID of the subject patient
+	Patient string
+	Sort    []SortField
+}
+
+// Validate checks that all filter values are valid FHIR Encounter values and sort fields are allowed
+func (f EncounterFilter) Validate() error {
+	for _, status := range f.Status {
+		if !isValidEncounterStatus(status) {
+			return fmt.Errorf("invalid encounter status: %s", status)
+		}
+	}
+	for _, date := range f.Date {
+		if _, err := parseSearchDate(date.Value); err != nil {
+			return fmt.Errorf("invalid date: %s", date.Value)
+		}
+		switch date.Prefix {
+		case DatePrefixEqual, DatePrefixGreater, DatePrefixGreaterEqual, DatePrefixLess, DatePrefixLessEqual:
+		default:
+			return fmt.Errorf("invalid date prefix: %s", date.Prefix)
+		}
+	}
+	if strings.Contains(f.Patient, "/") {
+		return fmt.Errorf("invalid patient reference: %s", f.Patient)
+	}
+	for _, sort := range f.Sort {
+		if _, ok := encounterSortFields[sort.Field]; !ok {
+			return fmt.Errorf("invalid sort field: %s", sort.Field)
+		}
+		if sort.Direction != SortAscending && sort.Direction != SortDescending {
+			return fmt.Errorf("invalid sort direction: %s", sort.Direction)
+		}
+	}
+	return nil
+}
+
+// OrderBy builds the N1QL ORDER BY expressions of the sort fields, empty without sort fields.
+// Documents without a field sort last in both directions.
+func (f EncounterFilter) OrderBy() string {
+	var terms []string
+	for _, sort := range f.Sort {
+		terms = append(terms, fmt.Sprintf("%s %s NULLS LAST", encounterSortFields[sort.Field], strings.ToUpper(sort.Direction)))
+	}
+	return strings.Join(terms, ", ")
+}
+
+// whereClause builds the N1QL WHERE condition and named parameters for the filter
+func (f EncounterFilter) whereClause() (string, map[string]interface{}) {
+	var conditions []string
+	params := make(map[string]interface{})
+
+	if len(f.Status) > 0 {
+		conditions = append(conditions, "d.status IN $statusList")
+		params["statusList"] = f.Status
+	}
+
+	// Dates are compared as stored; date-only values cover their whole day, month or year
+	for i, date := range f.Date {
+		start := "d.period.`start`"
+		end := "IFMISSINGORNULL(d.period.`end`, d.period.`start`)"
+		value := fmt.Sprintf("date%d", i)
+		next := fmt.Sprintf("dateNext%d", i)
+		params[value] = date.Value
+		nextValue, hasNext := nextSearchDate(date.Value)
+		if hasNext {
+			params[next] = nextValue
+		}
+
+		switch {
+		case date.Prefix == DatePrefixGreaterEqual:
+			conditions = append(conditions, fmt.Sprintf("%s >= $%s", start, value))
+		case date.Prefix == DatePrefixGreater && hasNext:
+			conditions = append(conditions, fmt.Sprintf("%s >= $%s", start, next))
+		case date.Prefix == DatePrefixGreater:
+			conditions = append(conditions, fmt.Sprintf("%s > $%s", start, value))
+		case date.Prefix == DatePrefixLess:
+			conditions = append(conditions, fmt.Sprintf("%s < $%s", end, value))
+		case date.Prefix == DatePrefixLessEqual && hasNext:
+			conditions = append(conditions, fmt.Sprintf("%s < $%s", end, next))
+		case date.Prefix == DatePrefixLessEqual:
+			conditions = append(conditions, fmt.Sprintf("%s <= $%s", end, value))
+		case hasNext:
+			conditions = append(conditions, fmt.Sprintf("%s >= $%s AND %s < $%s", start, value, start, next))
+		default:
+			conditions = append(conditions, fmt.Sprintf("%s = $%s", start, value))
+		}
+	}
+
+	if f.Patient != "" {
+		conditions = append(conditions, "d.subjectPatientId = $patientId")
+		params["patientId"] = f.Patient
+	}
+
+	return strings.Join(conditions, " AND "), params
+}
+
+// parseSearchDate parses a date search value and returns the layout matching its precision
+func parseSearchDate(value string) (string, error) {
+	for _, layout := range dateSearchLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return layout, nil
+		}
+	}
+	return "", fmt.Errorf("invalid date: %s", value)
+}
+
+// nextSearchDate returns the first date after a date-only value at its precision, e.g. 2023-12-31 -> 2024-01-01.
+// Date-time values have no next date, they are compared exactly.
+func nextSearchDate(value string) (string, bool) {
+	layout, err := parseSearchDate(value)
+	if err != nil || layout == time.RFC3339 {
+		return "", false
+	}
+	date, _ := time.Parse(layout, value)
+	switch layout {
+	case "2006":
+		date = date.AddDate(1, 0, 0)
+	case "2006-01":
+		date = date.AddDate(0, 1, 0)
+	default:
+		date = date.AddDate(0, 0, 1)
+	}
+	return date.Format(layout), true
+}
+
+// isValidEncounterStatus checks if status is a FHIR Encounter status code
+func isValidEncounterStatus(status string) bool {
+	for _, valid := range EncounterStatuses {
+		if status == valid {
+			return true
+		}
+	}
+	return false
+}
+
 // EncounterModel handles encounter-specific database operations
 type EncounterModel struct {
 	resourceModel *ResourceModel
@@ -26,7 +218,7 @@ func NewEncounterModelWithTenant(conn *Connection, tenantScope string) *Encounte
 
 // GetByID retrieves an encounter by ID
 func (em *EncounterModel) GetByID(ctx context.Context, id string) (map[string]interface{}, error) {
-	log.Debug().
+	log.Ctx(ctx).Debug().
 		Str("id", id).
 		Msg("Getting encounter by ID")
 
@@ -35,19 +227,58 @@ func (em *EncounterModel) GetByID(ctx context.Context, id string) (map[string]in
 }
 
 // List retrieves a paginated list of encounters
-func (em *EncounterModel) List(ctx context.Context, page, count int) (*PaginatedResponse, error) {
-	log.Debug().
+func (em *EncounterModel) List(ctx context.Context, page, count int, cursor string) (*PaginatedResponse, error) {
+	log.Ctx(ctx).Debug().
 		Int("page", page).
 		Int("count", count).
+		Str("cursor", cursor).
 		Msg("Listing encounters")
 
 	params := PaginationParams{
-		Page:  page,
-		Count: count,
+		Page:        page,
+		Count:       count,
+		AfterCursor: cursor,
 	}
 	return em.resourceModel.ListResources(ctx, "Encounter", params)
 }
 
+// ListWithFilter retrieves a paginated list of encounters matching the filter
+func (em *EncounterModel) ListWithFilter(ctx context.Context, page, count int, cursor string, filter EncounterFilter) (*PaginatedResponse, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Debug().
+		Int("page", page).
+		Int("count", count).
+		Str("cursor", cursor).
+		Strs("status", filter.Status).
+		Int("dateFilters", len(filter.Date)).
+		Str("patient", filter.Patient).
+		Str("orderBy", filter.OrderBy()).
+		Msg("Listing encounters with filter")
+
+	params := PaginationParams{
+		Page:        page,
+		Count:       count,
+		AfterCursor: cursor,
+		OrderBy:     filter.OrderBy(),
+	}
+	where, queryParams := filter.whereClause()
+	return em.resourceModel.ListResourcesWhere(ctx, "Encounter", params, where, queryParams)
+}
+
+// StreamWithFilter streams the page of encounters matching a filter to fn, see ResourceModel.StreamListResources
+func (em *EncounterModel) StreamWithFilter(ctx context.Context, params PaginationParams, filter EncounterFilter, fn func(QueryRow) error) error {
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	params.OrderBy = filter.OrderBy()
+	where, queryParams := filter.whereClause()
+	return em.resourceModel.StreamListResourcesWhere(ctx, "Encounter", params, where, queryParams, fn)
+}
+
 // ValidatePaginationParams validates and normalizes pagination parameters
 func (em *EncounterModel) ValidatePaginationParams(pageStr, countStr string) (int, int, error) {
 	page := 1
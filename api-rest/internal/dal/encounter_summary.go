@@ -0,0 +1,164 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+	"stealthcompany.com/api-rest/internal/metrics"
+)
+
+// EncounterSummaryKeyPrefix is the document key prefix of the cached encounter summaries
+const EncounterSummaryKeyPrefix = "summary/"
+
+// encounterSummaryTTL is how long a cached encounter summary is served before it is built again
+const encounterSummaryTTL = 60 * time.Second
+
+// encounterSummaryFetchLimit is the most practitioners of an encounter fetched at once
+const encounterSummaryFetchLimit = 8
+
+// EncounterSummary is an encounter with its patient and practitioners embedded, for a single-call review.
+// Patient is null and Practitioners leaves out the references that could not be read.
+type EncounterSummary struct {
+	Encounter     map[string]interface{}   `json:"encounter"`
+	Patient       map[string]interface{}   `json:"patient"`
+	Practitioners []map[string]interface{} `json:"practitioners"`
+	ReviewInfo    ReviewInfo               `json:"reviewInfo"`
+}
+
+// EncounterSummaryKey returns the document key of the cached summary of an encounter
+func EncounterSummaryKey(encounterID string) string {
+	return EncounterSummaryKeyPrefix + encounterID
+}
+
+// summaryCollection returns the collection caching encounter summaries: the default collection of the bucket,
+// or the defaulty collection of a tenant scope (overridable in tests)
+var summaryCollection = func(ctx context.Context, rm *ResourceModel) documentCollection {
+	if rm.tenantScope == "_default" {
+		return gocbCollection{Collection: rm.conn.GetDefaultCollection(), ctx: ctx}
+	}
+	return gocbCollection{Collection: rm.conn.GetCollection(rm.tenantScope, "defaulty"), ctx: ctx}
+}
+
+// invalidateEncounterSummary drops the cached summary of an encounter after its review changed, so the next
+// GetSummary reads the new review info instead of serving the cached one until it expires.
+// Failures are logged, the summary then expires on its own.
+func invalidateEncounterSummary(ctx context.Context, rm *ResourceModel, resourceType, docID string) {
+	if resourceType != "Encounter" {
+		return
+	}
+	key := EncounterSummaryKey(strings.TrimPrefix(docID, "Encounter/"))
+
+	start := time.Now()
+	_, err := summaryCollection(ctx, rm).Remove(key, &gocb.RemoveOptions{Context: ctx})
+	metrics.RecordCouchbaseOperation(ctx, "remove", getStatus(err), time.Since(start))
+	if err != nil && !errors.Is(err, gocb.ErrDocumentNotFound) {
+		log.Ctx(ctx).Warn().
+			Err(err).
+			Str("key", key).
+			Msg("Failed to drop cached encounter summary")
+	}
+}
+
+// GetSummary returns the encounter with its patient, practitioners and review info, cached for 60 seconds
+// under summary/{encounterID}. Patient and practitioners that cannot be read are logged and left out;
+// only a missing or unreadable encounter fails the summary.
+func (em *EncounterModel) GetSummary(ctx context.Context, id string) (*EncounterSummary, error) {
+	key := EncounterSummaryKey(id)
+	collection := summaryCollection(ctx, em.resourceModel)
+
+	var cached EncounterSummary
+	start := time.Now()
+	_, err := collection.Get(key, &cached)
+	metrics.RecordCouchbaseOperation(ctx, "get", getStatus(err), time.Since(start))
+	if err == nil {
+		log.Ctx(ctx).Debug().
+			Str("id", id).
+			Str("tenant_scope", em.resourceModel.tenantScope).
+			Msg("Encounter summary served from cache")
+		return &cached, nil
+	}
+	if !errors.Is(err, gocb.ErrDocumentNotFound) {
+		log.Ctx(ctx).Warn().
+			Err(err).
+			Str("key", key).
+			Msg("Failed to read cached encounter summary, building it again")
+	}
+
+	encounter, err := em.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &EncounterSummary{
+		Encounter:     encounter,
+		Practitioners: em.summaryPractitioners(ctx, id, encounter),
+		ReviewInfo:    reviewInfoFromDocument(encounter),
+	}
+	if patientID, ok := encounter["subjectPatientId"].(string); ok && patientID != "" {
+		patient, err := NewPatientModel(em.resourceModel).GetByID(ctx, strings.TrimPrefix(patientID, "Patient/"))
+		if err != nil {
+			log.Ctx(ctx).Warn().
+				Err(err).
+				Str("id", id).
+				Str("patient_id", patientID).
+				Msg("Failed to fetch encounter patient for summary")
+		} else {
+			summary.Patient = patient
+		}
+	}
+
+	start = time.Now()
+	_, err = collection.Upsert(key, summary, &gocb.UpsertOptions{Context: ctx, Expiry: encounterSummaryTTL})
+	metrics.RecordCouchbaseOperation(ctx, "upsert", operationStatus(err), time.Since(start))
+	if err != nil {
+		log.Ctx(ctx).Warn().
+			Err(err).
+			Str("key", key).
+			Msg("Failed to cache encounter summary")
+	}
+	return summary, nil
+}
+
+// summaryPractitioners fetches the practitioners listed in practitionerIds concurrently, in their listed order
+func (em *EncounterModel) summaryPractitioners(ctx context.Context, id string, encounter map[string]interface{}) []map[string]interface{} {
+	ids, _ := encounter["practitionerIds"].([]interface{})
+	fetched := make([]map[string]interface{}, len(ids))
+
+	practitionerModel := NewPractitionerModel(em.resourceModel)
+	var group errgroup.Group
+	group.SetLimit(encounterSummaryFetchLimit)
+	for i, value := range ids {
+		practitionerID, ok := value.(string)
+		if !ok || practitionerID == "" {
+			continue
+		}
+		group.Go(func() error {
+			practitioner, err := practitionerModel.GetByID(ctx, strings.TrimPrefix(practitionerID, "Practitioner/"))
+			if err != nil {
+				// A missing practitioner leaves the summary without it instead of failing it
+				log.Ctx(ctx).Warn().
+					Err(err).
+					Str("id", id).
+					Str("practitioner_id", practitionerID).
+					Msg("Failed to fetch encounter practitioner for summary")
+				return nil
+			}
+			fetched[i] = practitioner
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	practitioners := make([]map[string]interface{}, 0, len(fetched))
+	for _, practitioner := range fetched {
+		if practitioner != nil {
+			practitioners = append(practitioners, practitioner)
+		}
+	}
+	return practitioners
+}
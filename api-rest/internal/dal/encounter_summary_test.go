@@ -0,0 +1,132 @@
+package dal
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEncounterGetSummary(t *testing.T) {
+	bucket := useMockBucket(t)
+
+	encounters := bucket.Collection("tenant1", "encounters")
+	encounters.AddFixture("Encounter/1", map[string]interface{}{
+		"resourceType":     "Encounter",
+		"id":               "1",
+		"subjectPatientId": "pat-1",
+		"practitionerIds":  []string{"pr-1", "pr-missing", "pr-2"},
+		"reviewed":         true,
+		"reviewTime":       "2025-01-01T00:00:00Z",
+	})
+	bucket.Collection("tenant1", "patients").AddFixture("Patient/pat-1", map[string]interface{}{"id": "pat-1"})
+	practitioners := bucket.Collection("tenant1", "practitioners")
+	practitioners.AddFixture("Practitioner/pr-1", map[string]interface{}{"id": "pr-1"})
+	practitioners.AddFixture("Practitioner/pr-2", map[string]interface{}{"id": "pr-2"})
+
+	em := NewEncounterModel(testResourceModel("tenant1"))
+	summary, err := em.GetSummary(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("GetSummary() error = %v", err)
+	}
+
+	if summary.Encounter["id"] != "1" {
+		t.Errorf("Expected the encounter, got %v", summary.Encounter)
+	}
+	if summary.Patient["id"] != "pat-1" {
+		t.Errorf("Expected the encounter patient, got %v", summary.Patient)
+	}
+	if len(summary.Practitioners) != 2 || summary.Practitioners[0]["id"] != "pr-1" || summary.Practitioners[1]["id"] != "pr-2" {
+		t.Errorf("Expected the practitioners found, in listed order, got %v", summary.Practitioners)
+	}
+	if !summary.ReviewInfo.Reviewed || summary.ReviewInfo.ReviewTime != "2025-01-01T00:00:00Z" {
+		t.Errorf("Expected the review info of the encounter, got %+v", summary.ReviewInfo)
+	}
+
+	calls := bucket.Collection("tenant1", "defaulty").UpsertCalls()
+	if len(calls) != 1 || calls[0].ID != "summary/1" || calls[0].Options.Expiry != 60*time.Second {
+		t.Fatalf("Expected the summary to be cached under summary/1 for 60s, got %+v", calls)
+	}
+
+	// A cached summary is served without reading the encounter again
+	encounters.AddFixture("Encounter/1", map[string]interface{}{"resourceType": "Encounter", "id": "1", "status": "changed"})
+	cached, err := em.GetSummary(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("GetSummary() error = %v", err)
+	}
+	if cached.Encounter["status"] != nil || len(cached.Practitioners) != 2 {
+		t.Errorf("Expected the cached summary, got %+v", cached)
+	}
+}
+
+func TestEncounterGetSummaryMissingReferences(t *testing.T) {
+	bucket := useMockBucket(t)
+
+	bucket.Collection("tenant1", "encounters").AddFixture("Encounter/2", map[string]interface{}{
+		"resourceType":     "Encounter",
+		"id":               "2",
+		"subjectPatientId": "pat-missing",
+		"practitionerIds":  []string{"pr-missing"},
+	})
+
+	summary, err := NewEncounterModel(testResourceModel("tenant1")).GetSummary(context.Background(), "2")
+	if err != nil {
+		t.Fatalf("Expected missing references not to fail the summary, got %v", err)
+	}
+	if summary.Patient != nil {
+		t.Errorf("Expected no patient, got %v", summary.Patient)
+	}
+	if summary.Practitioners == nil || len(summary.Practitioners) != 0 {
+		t.Errorf("Expected an empty practitioners list, got %v", summary.Practitioners)
+	}
+}
+
+func TestEncounterGetSummaryNotFound(t *testing.T) {
+	bucket := useMockBucket(t)
+
+	if _, err := NewEncounterModel(testResourceModel("tenant1")).GetSummary(context.Background(), "missing"); err == nil {
+		t.Fatal("Expected an error for a missing encounter")
+	}
+	if calls := bucket.Collection("tenant1", "defaulty").UpsertCalls(); len(calls) != 0 {
+		t.Errorf("Expected nothing to be cached, got %+v", calls)
+	}
+}
+
+func TestReviewDropsCachedEncounterSummary(t *testing.T) {
+	bucket := useMockBucket(t)
+
+	bucket.Collection("tenant1", "encounters").AddFixture("Encounter/1", map[string]interface{}{"resourceType": "Encounter", "id": "1"})
+	bucket.Collection("tenant1", "practitioners").AddFixture("Practitioner/1", map[string]interface{}{"resourceType": "Practitioner", "id": "1"})
+	summaries := bucket.Collection("tenant1", "defaulty")
+
+	em := NewEncounterModel(testResourceModel("tenant1"))
+	summary, err := em.GetSummary(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("GetSummary() error = %v", err)
+	}
+	if summary.ReviewInfo.Reviewed {
+		t.Fatal("Expected an unreviewed encounter")
+	}
+
+	rm := NewReviewModel(testResourceModel("tenant1"), nil)
+	if err := rm.CreateReviewRequest(context.Background(), "tenant1", "Practitioner", "1", ReviewDetails{}); err != nil {
+		t.Fatalf("CreateReviewRequest() error = %v", err)
+	}
+	if calls := summaries.RemoveCalls(); len(calls) != 0 {
+		t.Errorf("Expected a practitioner review to keep the cached summaries, got removals %v", calls)
+	}
+
+	if err := rm.CreateReviewRequest(context.Background(), "tenant1", "Encounter", "1", ReviewDetails{}); err != nil {
+		t.Fatalf("CreateReviewRequest() error = %v", err)
+	}
+	if calls := summaries.RemoveCalls(); len(calls) != 1 || calls[0] != EncounterSummaryKey("1") {
+		t.Errorf("Expected the cached summary to be removed, got removals %v", calls)
+	}
+
+	summary, err = em.GetSummary(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("GetSummary() error = %v", err)
+	}
+	if !summary.ReviewInfo.Reviewed {
+		t.Error("Expected the summary to show the new review")
+	}
+}
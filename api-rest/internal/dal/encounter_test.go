@@ -0,0 +1,236 @@
+package dal
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"stealthcompany.com/pkg/testutil"
+)
+
+func TestEncounterFilterWhereClause(t *testing.T) {
+	tests := []struct {
+		name          string
+		filter        EncounterFilter
+		expectedWhere string
+		expectError   bool
+	}{
+		{
+			name:          "No filter",
+			filter:        EncounterFilter{},
+			expectedWhere: "",
+		},
+		{
+			name:          "Status filter",
+			filter:        EncounterFilter{Status: []string{"finished", "cancelled"}},
+			expectedWhere: "d.status IN $statusList",
+		},
+		{
+			name:        "Invalid status",
+			filter:      EncounterFilter{Status: []string{"finished", "done"}},
+			expectError: true,
+		},
+		{
+			name: "Date range",
+			filter: EncounterFilter{Date: []DateComparison{
+				{Prefix: DatePrefixGreaterEqual, Value: "2023-01-01"},
+				{Prefix: DatePrefixLessEqual, Value: "2023-12-31"},
+			}},
+			expectedWhere: "d.period.`start` >= $date0 AND IFMISSINGORNULL(d.period.`end`, d.period.`start`) < $dateNext1",
+		},
+		{
+			name:          "Date equal to a month",
+			filter:        EncounterFilter{Date: []DateComparison{{Prefix: DatePrefixEqual, Value: "2023-06"}}},
+			expectedWhere: "d.period.`start` >= $date0 AND d.period.`start` < $dateNext0",
+		},
+		{
+			name:          "Date after a date-time",
+			filter:        EncounterFilter{Date: []DateComparison{{Prefix: DatePrefixGreater, Value: "2023-06-01T10:00:00Z"}}},
+			expectedWhere: "d.period.`start` > $date0",
+		},
+		{
+			name:          "Status and patient",
+			filter:        EncounterFilter{Status: []string{"finished"}, Patient: "abc"},
+			expectedWhere: "d.status IN $statusList AND d.subjectPatientId = $patientId",
+		},
+		{
+			name:        "Invalid date",
+			filter:      EncounterFilter{Date: []DateComparison{{Prefix: DatePrefixGreaterEqual, Value: "01/01/2023"}}},
+			expectError: true,
+		},
+		{
+			name:        "Invalid date prefix",
+			filter:      EncounterFilter{Date: []DateComparison{{Prefix: "sa", Value: "2023-01-01"}}},
+			expectError: true,
+		},
+		{
+			name:        "Patient reference instead of ID",
+			filter:      EncounterFilter{Patient: "Practitioner/abc"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Validate()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			where, params := tt.filter.whereClause()
+			if where != tt.expectedWhere {
+				t.Errorf("Expected where %q, got %q", tt.expectedWhere, where)
+			}
+			if len(tt.filter.Status) > 0 && params["statusList"] == nil {
+				t.Errorf("Expected statusList parameter")
+			}
+		})
+	}
+}
+
+func TestEncounterFilterOrderBy(t *testing.T) {
+	tests := []struct {
+		name            string
+		sort            []SortField
+		expectedOrderBy string
+		expectError     bool
+	}{
+		{
+			name:            "No sort",
+			expectedOrderBy: "",
+		},
+		{
+			name:            "Date ascending",
+			sort:            []SortField{{Field: "date", Direction: SortAscending}},
+			expectedOrderBy: "d.period.`start` ASC NULLS LAST",
+		},
+		{
+			name:            "Date descending",
+			sort:            []SortField{{Field: "date", Direction: SortDescending}},
+			expectedOrderBy: "d.period.`start` DESC NULLS LAST",
+		},
+		{
+			name:            "Multiple fields",
+			sort:            []SortField{{Field: "date", Direction: SortDescending}, {Field: "status", Direction: SortAscending}},
+			expectedOrderBy: "d.period.`start` DESC NULLS LAST, d.status ASC NULLS LAST",
+		},
+		{
+			name:        "Unknown field",
+			sort:        []SortField{{Field: "priority", Direction: SortAscending}},
+			expectError: true,
+		},
+		{
+			name:        "Unknown direction",
+			sort:        []SortField{{Field: "id", Direction: "sideways"}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := EncounterFilter{Sort: tt.sort}
+			err := filter.Validate()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if orderBy := filter.OrderBy(); orderBy != tt.expectedOrderBy {
+				t.Errorf("Expected order by %q, got %q", tt.expectedOrderBy, orderBy)
+			}
+		})
+	}
+}
+
+func TestEncounterModelListWithFilterSort(t *testing.T) {
+	cluster := &testutil.MockCluster{}
+	useMockCluster(t, cluster)
+
+	em := NewEncounterModel(testResourceModel("tenant1"))
+	filter := EncounterFilter{Sort: []SortField{{Field: "date", Direction: SortDescending}}}
+	if _, err := em.ListWithFilter(context.Background(), 1, 10, "", filter); err != nil {
+		t.Fatalf("ListWithFilter() error = %v", err)
+	}
+
+	calls := cluster.QueryCalls()
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 query, got %d", len(calls))
+	}
+	// Encounters without period.start sort last, and the document key keeps pages stable
+	expected := "ORDER BY d.period.`start` DESC NULLS LAST, META(d).id LIMIT 11 OFFSET 0"
+	if !strings.HasSuffix(calls[0].Statement, expected) {
+		t.Errorf("Expected query ending with %q, got %s", expected, calls[0].Statement)
+	}
+}
+
+func TestEncounterModelUpsert(t *testing.T) {
+	t.Setenv("TENANT_DATA_TTL_DAYS", "7")
+	bucket := useMockBucket(t)
+	patientSummaries.set(patientSummaryKey("tenant1", "Patient/1"), PatientSummary{EncounterCount: 1}, time.Now().Add(time.Minute))
+	t.Cleanup(func() {
+		InvalidateTenantPatientSummaries("tenant1")
+	})
+
+	em := NewEncounterModel(testResourceModel("tenant1"))
+	encounter := map[string]interface{}{
+		"resourceType":     "Encounter",
+		"id":               "1",
+		"status":           "finished",
+		"subjectPatientId": "Patient/1",
+	}
+	if err := em.resourceModel.UpsertResource(context.Background(), "Encounter/1", encounter); err != nil {
+		t.Fatalf("UpsertResource() error = %v", err)
+	}
+
+	calls := bucket.Collection("tenant1", "encounters").UpsertCalls()
+	if len(calls) != 1 || calls[0].ID != "Encounter/1" {
+		t.Fatalf("Expected one upsert into the tenant encounters collection, got %+v", calls)
+	}
+	if calls[0].Options.Expiry != 7*24*time.Hour {
+		t.Errorf("Expected tenant upsert to expire after 168h, got %v", calls[0].Options.Expiry)
+	}
+
+	got, err := em.GetByID(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got["status"] != "finished" {
+		t.Errorf("Expected upserted encounter to be readable, got %v", got)
+	}
+
+	if _, ok := patientSummaries.get(patientSummaryKey("tenant1", "Patient/1"), time.Now()); ok {
+		t.Error("Expected the patient summary to be invalidated")
+	}
+}
+
+func TestNextSearchDate(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		hasNext  bool
+	}{
+		{value: "2023", expected: "2024", hasNext: true},
+		{value: "2023-12", expected: "2024-01", hasNext: true},
+		{value: "2023-12-31", expected: "2024-01-01", hasNext: true},
+		{value: "2023-12-31T10:00:00Z", hasNext: false},
+	}
+
+	for _, tt := range tests {
+		next, ok := nextSearchDate(tt.value)
+		if ok != tt.hasNext || next != tt.expected {
+			t.Errorf("nextSearchDate(%q) = %q, %v; want %q, %v", tt.value, next, ok, tt.expected, tt.hasNext)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package dal
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// ErrInvalidETag is returned for If-Match values that were not produced by FormatETag
+var ErrInvalidETag = errors.New("invalid ETag")
+
+// CASedResource is a resource document with the CAS it was read at
+type CASedResource struct {
+	Data map[string]interface{}
+	CAS  gocb.Cas
+}
+
+// ETag returns the ETag of the document version
+func (r *CASedResource) ETag() string {
+	return FormatETag(r.CAS)
+}
+
+// FormatETag encodes a document CAS as a quoted base64 ETag
+func FormatETag(cas gocb.Cas) string {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(cas))
+	return `"` + base64.StdEncoding.EncodeToString(buf) + `"`
+}
+
+// ElementsETag returns the weak ETag of the _elements subset of a document version: the CAS ETag
+// suffixed with a hash of the sorted, deduplicated element list, so each subset revalidates on its own.
+// It is weak so ParseETag rejects it as an If-Match value.
+func ElementsETag(etag string, elements []string) string {
+	if etag == "" || len(elements) == 0 {
+		return etag
+	}
+	normalized := slices.Compact(slices.Sorted(slices.Values(elements)))
+	sum := sha256.Sum256([]byte(strings.Join(normalized, ",")))
+	return `W/"` + strings.Trim(etag, `"`) + "." + base64.RawURLEncoding.EncodeToString(sum[:6]) + `"`
+}
+
+// ParseETag decodes an ETag produced by FormatETag back to the document CAS.
+// Weak ETags are rejected since If-Match requires a strong comparison.
+func ParseETag(etag string) (gocb.Cas, error) {
+	value := strings.TrimSpace(etag)
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return 0, ErrInvalidETag
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+	if err != nil || len(decoded) != 8 {
+		return 0, ErrInvalidETag
+	}
+
+	cas := gocb.Cas(binary.BigEndian.Uint64(decoded))
+	if cas == 0 {
+		return 0, ErrInvalidETag
+	}
+	return cas, nil
+}
+
+// MatchesIfNoneMatch checks if an If-None-Match header lists the ETag or is "*".
+// If-None-Match uses the weak comparison, so W/ prefixes are ignored.
+func MatchesIfNoneMatch(ifNoneMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
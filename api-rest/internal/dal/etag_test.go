@@ -0,0 +1,95 @@
+package dal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+func TestETagRoundTrip(t *testing.T) {
+	for _, cas := range []gocb.Cas{1, 1700000000000000000, ^gocb.Cas(0)} {
+		etag := FormatETag(cas)
+		got, err := ParseETag(etag)
+		if err != nil {
+			t.Fatalf("ParseETag(%s) error = %v", etag, err)
+		}
+		if got != cas {
+			t.Errorf("ParseETag(FormatETag(%d)) = %d", cas, got)
+		}
+	}
+}
+
+func TestParseETagInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		etag string
+	}{
+		{name: "Empty", etag: ""},
+		{name: "Unquoted", etag: "AAAAAAAAAAE="},
+		{name: "Weak", etag: `W/"AAAAAAAAAAE="`},
+		{name: "Not base64", etag: `"not-base64!"`},
+		{name: "Wrong length", etag: `"AAE="`},
+		{name: "Zero CAS", etag: `"AAAAAAAAAAA="`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseETag(tt.etag); !errors.Is(err, ErrInvalidETag) {
+				t.Errorf("Expected ErrInvalidETag, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMatchesIfNoneMatch(t *testing.T) {
+	etag := FormatETag(1)
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		expected    bool
+	}{
+		{name: "Absent", ifNoneMatch: "", expected: false},
+		{name: "Same ETag", ifNoneMatch: etag, expected: true},
+		{name: "Weak ETag", ifNoneMatch: "W/" + etag, expected: true},
+		{name: "Listed ETag", ifNoneMatch: FormatETag(2) + ", " + etag, expected: true},
+		{name: "Any", ifNoneMatch: "*", expected: true},
+		{name: "Other ETag", ifNoneMatch: FormatETag(2), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchesIfNoneMatch(tt.ifNoneMatch, etag); got != tt.expected {
+				t.Errorf("MatchesIfNoneMatch(%q) = %v, want %v", tt.ifNoneMatch, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestElementsETag(t *testing.T) {
+	etag := FormatETag(1)
+	subset := ElementsETag(etag, []string{"status", "subject"})
+
+	if !strings.HasPrefix(subset, "W/") {
+		t.Errorf("Expected a weak ETag, got %s", subset)
+	}
+	if subset == "W/"+etag || MatchesIfNoneMatch(etag, subset) {
+		t.Errorf("Expected the subset ETag %s to differ from the full ETag %s", subset, etag)
+	}
+	if got := ElementsETag(etag, []string{"subject", "status", "status"}); got != subset {
+		t.Errorf("Expected the element order and duplicates to be ignored, got %s and %s", got, subset)
+	}
+	if got := ElementsETag(etag, []string{"status"}); got == subset {
+		t.Errorf("Expected other elements to produce another ETag, got %s", got)
+	}
+	if got := ElementsETag(FormatETag(2), []string{"status", "subject"}); got == subset {
+		t.Errorf("Expected another version to produce another ETag, got %s", got)
+	}
+	if got := ElementsETag(etag, nil); got != etag {
+		t.Errorf("Expected no elements to keep the ETag, got %s", got)
+	}
+	if _, err := ParseETag(subset); !errors.Is(err, ErrInvalidETag) {
+		t.Errorf("Expected the subset ETag to be rejected as If-Match, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package dal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// fhirTenantConfigured checks if a tenant is listed in FHIR_TENANT_CONFIG_FILE, the file fhir-client ingests
+// tenants from their own FHIR server with. Such a tenant scope is filled by fhir-client, not copied from DefaultScope.
+// Overridable in tests.
+var fhirTenantConfigured = func(tenantScope string) (bool, error) {
+	servers, err := FHIRTenantServers()
+	if err != nil {
+		return false, err
+	}
+	_, configured := servers[tenantScope]
+	return configured, nil
+}
+
+// FHIRTenantServers returns the FHIR server base URL of every tenant listed in FHIR_TENANT_CONFIG_FILE,
+// or nil when the variable is unset
+func FHIRTenantServers() (map[string]string, error) {
+	path := os.Getenv("FHIR_TENANT_CONFIG_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return loadFHIRTenantServers(path)
+}
+
+// loadFHIRTenantServers reads the tenant IDs and base URLs of a fhir-client tenant config file,
+// a JSON array of tenant configs
+func loadFHIRTenantServers(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FHIR tenant config file: %w", err)
+	}
+
+	var configs []struct {
+		TenantID string `json:"tenantId"`
+		BaseURL  string `json:"baseUrl"`
+	}
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse FHIR tenant config file %s: %w", path, err)
+	}
+
+	servers := make(map[string]string, len(configs))
+	for _, config := range configs {
+		servers[config.TenantID] = config.BaseURL
+	}
+	return servers, nil
+}
@@ -0,0 +1,58 @@
+package dal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFHIRTenantConfigured(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	content := `[{"tenantId": "acme", "baseUrl": "https://fhir.acme.example/R4", "timeout": "45s"}, {"tenantId": "globex", "baseUrl": "https://fhir.globex.example"}]`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FHIR_TENANT_CONFIG_FILE", path)
+
+	tests := []struct {
+		tenant string
+		want   bool
+	}{
+		{"acme", true},
+		{"globex", true},
+		{"tenant1", false},
+	}
+	for _, tt := range tests {
+		got, err := fhirTenantConfigured(tt.tenant)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("fhirTenantConfigured(%q) = %v, want %v", tt.tenant, got, tt.want)
+		}
+	}
+}
+
+func TestFHIRTenantConfiguredUnset(t *testing.T) {
+	t.Setenv("FHIR_TENANT_CONFIG_FILE", "")
+
+	got, err := fhirTenantConfigured("acme")
+	if err != nil || got {
+		t.Errorf("Expected no configured tenant without a file, got %v, %v", got, err)
+	}
+}
+
+func TestFHIRTenantConfiguredInvalidFile(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	invalid := filepath.Join(t.TempDir(), "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"tenantId": "acme"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{missing, invalid} {
+		t.Setenv("FHIR_TENANT_CONFIG_FILE", path)
+		if _, err := fhirTenantConfigured("acme"); err == nil {
+			t.Errorf("Expected an error for %s", path)
+		}
+	}
+}
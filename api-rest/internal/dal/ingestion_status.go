@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/couchbase/gocb/v2"
@@ -18,10 +17,21 @@ type IngestionStatusModel struct {
 
 // IngestionStatus represents the ingestion status document
 type IngestionStatus struct {
-	Ready       bool      `json:"ready"`
-	StartedAt   time.Time `json:"startedAt"`
-	CompletedAt time.Time `json:"completedAt,omitempty"`
-	Message     string    `json:"message"`
+	Ready          bool           `json:"ready"`
+	StartedAt      time.Time      `json:"startedAt"`
+	CompletedAt    time.Time      `json:"completedAt,omitempty"`
+	Message        string         `json:"message"`
+	ResourceCounts map[string]int `json:"resourceCounts,omitempty"`
+}
+
+// HasMinimumResourceCounts checks that every resource type reached its minimum ingested count
+func (s *IngestionStatus) HasMinimumResourceCounts(minCounts map[string]int) bool {
+	for resourceType, minCount := range minCounts {
+		if s.ResourceCounts[resourceType] < minCount {
+			return false
+		}
+	}
+	return true
 }
 
 // TemplateIngestionStatusKey is the document key for system-wide FHIR ingestion status
@@ -37,64 +47,63 @@ func NewIngestionStatusModel(conn *Connection) *IngestionStatusModel {
 	}
 }
 
-// GetDefaultScopeIngestionStatus retrieves ingestion status from default scope (for API startup monitoring)
-func (ism *IngestionStatusModel) GetDefaultScopeIngestionStatus(ctx context.Context) (*IngestionStatus, error) {
-	collection := ism.conn.GetBucket().DefaultCollection()
+// ingestionStatusCollection returns the collection holding the ingestion status of a scope:
+// the default collection of the bucket, or the defaulty collection of a tenant scope (overridable in tests)
+var ingestionStatusCollection = func(ctx context.Context, ism *IngestionStatusModel, tenantScope string) documentCollection {
+	if tenantScope == "_default" {
+		return gocbCollection{Collection: ism.conn.GetBucket().DefaultCollection(), ctx: ctx}
+	}
+	return gocbCollection{Collection: ism.conn.GetBucket().Scope(tenantScope).Collection("defaulty"), ctx: ctx}
+}
 
-	result, err := collection.Get(TemplateIngestionStatusKey, &gocb.GetOptions{})
-	if err != nil {
-		// Check if document doesn't exist (multiple ways to check this)
-		if errors.Is(err, gocb.ErrDocumentNotFound) ||
-			strings.Contains(err.Error(), "document not found") ||
-			strings.Contains(err.Error(), "Not Found") ||
-			strings.Contains(err.Error(), "KEY_ENOENT") {
+// getIngestionStatus reads the ingestion status document under key; a missing document is a status not ready yet
+func (ism *IngestionStatusModel) getIngestionStatus(ctx context.Context, tenantScope, key string) (*IngestionStatus, error) {
+	var status IngestionStatus
+	if _, err := ingestionStatusCollection(ctx, ism, tenantScope).Get(key, &status); err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
 			// Ingestion status document doesn't exist yet
 			return &IngestionStatus{Ready: false}, nil
 		}
-		return nil, fmt.Errorf("failed to get default scope ingestion status: %w", err)
+		return nil, err
 	}
+	return &status, nil
+}
 
-	var status IngestionStatus
-	if err := result.Content(&status); err != nil {
-		return nil, fmt.Errorf("failed to parse default scope ingestion status: %w", err)
+// GetDefaultScopeIngestionStatus retrieves ingestion status from default scope (for API startup monitoring)
+func (ism *IngestionStatusModel) GetDefaultScopeIngestionStatus(ctx context.Context) (*IngestionStatus, error) {
+	status, err := ism.getIngestionStatus(ctx, "_default", TemplateIngestionStatusKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get default scope ingestion status: %w", err)
 	}
-
-	return &status, nil
+	return status, nil
 }
 
 // IsDefaultScopeIngestionReady checks if FHIR ingestion is complete in default scope (for API startup)
-func (ism *IngestionStatusModel) IsDefaultScopeIngestionReady(ctx context.Context) (bool, error) {
+// and that each resource type reached its minimum ingested count
+func (ism *IngestionStatusModel) IsDefaultScopeIngestionReady(ctx context.Context, minCounts map[string]int) (bool, error) {
 	status, err := ism.GetDefaultScopeIngestionStatus(ctx)
 	if err != nil {
 		return false, err
 	}
 
+	if status.Ready && !status.HasMinimumResourceCounts(minCounts) {
+		log.Ctx(ctx).Warn().
+			Interface("resource_counts", status.ResourceCounts).
+			Interface("min_counts", minCounts).
+			Msg("FHIR ingestion completed but resource counts are below the minimum")
+		return false, nil
+	}
+
 	return status.Ready, nil
 }
 
 // GetTenantScopeIngestionStatus retrieves ingestion status from tenant scope
 func (ism *IngestionStatusModel) GetTenantScopeIngestionStatus(ctx context.Context, tenantScope string) (*IngestionStatus, error) {
-	collection := ism.conn.GetBucket().Scope(tenantScope).Collection("defaulty")
-
-	result, err := collection.Get(TenantIngestionStatusKey, &gocb.GetOptions{})
+	status, err := ism.getIngestionStatus(ctx, tenantScope, TenantIngestionStatusKey)
 	if err != nil {
-		// Check if document doesn't exist (multiple ways to check this)
-		if errors.Is(err, gocb.ErrDocumentNotFound) ||
-			strings.Contains(err.Error(), "document not found") ||
-			strings.Contains(err.Error(), "Not Found") ||
-			strings.Contains(err.Error(), "KEY_ENOENT") {
-			// Ingestion status document doesn't exist yet
-			return &IngestionStatus{Ready: false}, nil
-		}
 		return nil, fmt.Errorf("failed to get tenant scope ingestion status for %s: %w", tenantScope, err)
 	}
-
-	var status IngestionStatus
-	if err := result.Content(&status); err != nil {
-		return nil, fmt.Errorf("failed to parse tenant scope ingestion status for %s: %w", tenantScope, err)
-	}
-
-	return &status, nil
+	return status, nil
 }
 
 // IsTenantScopeIngestionReady checks if FHIR ingestion is complete for a specific tenant scope
@@ -116,7 +125,7 @@ func (ism *IngestionStatusModel) SetTenantScopeIngestionStatus(ctx context.Conte
 		return fmt.Errorf("failed to set tenant scope ingestion status for %s: %w", tenantScope, err)
 	}
 
-	log.Debug().Str("tenant", tenantScope).Bool("ready", status.Ready).Msg("Tenant scope ingestion status updated")
+	log.Ctx(ctx).Debug().Str("tenant", tenantScope).Bool("ready", status.Ready).Msg("Tenant scope ingestion status updated")
 	return nil
 }
 
@@ -131,6 +140,20 @@ func (ism *IngestionStatusModel) MarkTenantScopeIngestionStarted(ctx context.Con
 	return ism.SetTenantScopeIngestionStatus(ctx, tenantScope, status)
 }
 
+// collectionInitFailedMessage prefixes the status message of a tenant scope whose collections could not be created
+const collectionInitFailedMessage = "Collection initialization failed: "
+
+// MarkTenantScopeCollectionInitFailed records that the collections of a tenant scope could not be created,
+// so the scope is never reported ready
+func (ism *IngestionStatusModel) MarkTenantScopeCollectionInitFailed(ctx context.Context, tenantScope string, cause error) error {
+	status := &IngestionStatus{
+		Ready:   false,
+		Message: collectionInitFailedMessage + cause.Error(),
+	}
+
+	return ism.SetTenantScopeIngestionStatus(ctx, tenantScope, status)
+}
+
 // MarkTenantScopeIngestionCompleted marks ingestion as completed for a specific tenant scope
 func (ism *IngestionStatusModel) MarkTenantScopeIngestionCompleted(ctx context.Context, tenantScope string, message string) error {
 	status := &IngestionStatus{
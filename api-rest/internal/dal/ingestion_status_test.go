@@ -0,0 +1,139 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/couchbase/gocb/v2"
+	"stealthcompany.com/pkg/testutil"
+)
+
+func TestHasMinimumResourceCounts(t *testing.T) {
+	minCounts := map[string]int{
+		"Encounter":    1,
+		"Patient":      1,
+		"Practitioner": 1,
+	}
+
+	tests := []struct {
+		name     string
+		counts   map[string]int
+		expected bool
+	}{
+		{
+			name:     "All counts present",
+			counts:   map[string]int{"Encounter": 500, "Patient": 120, "Practitioner": 30},
+			expected: true,
+		},
+		{
+			name:     "One count is zero",
+			counts:   map[string]int{"Encounter": 500, "Patient": 0, "Practitioner": 30},
+			expected: false,
+		},
+		{
+			name:     "One count is missing",
+			counts:   map[string]int{"Encounter": 500, "Patient": 120},
+			expected: false,
+		},
+		{
+			name:     "Status without counts",
+			counts:   nil,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &IngestionStatus{Ready: true, ResourceCounts: tt.counts}
+			if result := status.HasMinimumResourceCounts(minCounts); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+
+	t.Run("Zero minimums accept missing counts", func(t *testing.T) {
+		status := &IngestionStatus{Ready: true}
+		if !status.HasMinimumResourceCounts(map[string]int{"Encounter": 0}) {
+			t.Errorf("Expected zero minimum to be satisfied")
+		}
+	})
+}
+
+// failingGetCollection is a mock collection whose reads fail with err
+type failingGetCollection struct {
+	*testutil.MockCollection
+	err error
+}
+
+func (c failingGetCollection) Get(docID string, valuePtr interface{}) (gocb.Cas, error) {
+	return 0, c.err
+}
+
+// useIngestionStatusCollection routes the ingestion status reads of every scope to collection
+func useIngestionStatusCollection(t *testing.T, collection documentCollection) {
+	t.Helper()
+
+	orig := ingestionStatusCollection
+	ingestionStatusCollection = func(ctx context.Context, ism *IngestionStatusModel, tenantScope string) documentCollection {
+		return collection
+	}
+	t.Cleanup(func() {
+		ingestionStatusCollection = orig
+	})
+}
+
+func TestGetIngestionStatusDocumentNotFound(t *testing.T) {
+	ism := NewIngestionStatusModel(&Connection{})
+
+	t.Run("Missing document is not ready", func(t *testing.T) {
+		useIngestionStatusCollection(t, testutil.NewMockCollection())
+
+		status, err := ism.GetDefaultScopeIngestionStatus(context.Background())
+		if err != nil || status.Ready {
+			t.Errorf("Expected a default scope status not ready yet, got %+v, %v", status, err)
+		}
+		status, err = ism.GetTenantScopeIngestionStatus(context.Background(), "tenant1")
+		if err != nil || status.Ready {
+			t.Errorf("Expected a tenant scope status not ready yet, got %+v, %v", status, err)
+		}
+	})
+
+	t.Run("Wrapped ErrDocumentNotFound is not ready", func(t *testing.T) {
+		useIngestionStatusCollection(t, failingGetCollection{
+			MockCollection: testutil.NewMockCollection(),
+			err:            fmt.Errorf("get %s: %w", TenantIngestionStatusKey, gocb.ErrDocumentNotFound),
+		})
+
+		ready, err := ism.IsTenantScopeIngestionReady(context.Background(), "tenant1")
+		if err != nil || ready {
+			t.Errorf("Expected tenant scope not ready without error, got %v, %v", ready, err)
+		}
+	})
+
+	t.Run("Other errors mentioning not found are returned", func(t *testing.T) {
+		useIngestionStatusCollection(t, failingGetCollection{
+			MockCollection: testutil.NewMockCollection(),
+			err:            errors.New("proxy returned 404 Not Found"),
+		})
+
+		if _, err := ism.GetDefaultScopeIngestionStatus(context.Background()); err == nil {
+			t.Error("Expected the read error of the default scope status to be returned")
+		}
+		if _, err := ism.IsTenantScopeIngestionReady(context.Background(), "tenant1"); err == nil {
+			t.Error("Expected the read error of the tenant scope status to be returned")
+		}
+	})
+
+	t.Run("Stored status is read", func(t *testing.T) {
+		collection := testutil.NewMockCollection()
+		collection.AddFixture(TenantIngestionStatusKey, IngestionStatus{Ready: true, Message: "done"})
+		useIngestionStatusCollection(t, collection)
+
+		ready, err := ism.IsTenantScopeIngestionReady(context.Background(), "tenant1")
+		if err != nil || !ready {
+			t.Errorf("Expected tenant scope ready, got %v, %v", ready, err)
+		}
+	})
+}
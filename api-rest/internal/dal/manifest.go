@@ -0,0 +1,70 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// IngestManifestKeyPrefix is the document key prefix for ingestion run manifests written by fhir-client
+const IngestManifestKeyPrefix = "_system/ingest_manifest/"
+
+// IngestManifest is the audit record of a single fhir-client ingestion run
+type IngestManifest struct {
+	RunID                           string            `json:"runId"`
+	StartedAt                       time.Time         `json:"startedAt"`
+	CompletedAt                     time.Time         `json:"completedAt"`
+	ResourceCounts                  map[string]int    `json:"resourceCounts"`
+	FHIRServerURL                   string            `json:"fhirServerUrl"`
+	Filters                         map[string]string `json:"filters,omitempty"`
+	Status                          string            `json:"status"`
+	Error                           string            `json:"error,omitempty"`
+	FailedDocumentIDs               []string          `json:"failedDocumentIds"`
+	EncountersWithMissingReferences int               `json:"encountersWithMissingReferences"`
+}
+
+// ManifestModel represents the database model for ingestion run manifests
+type ManifestModel struct {
+	conn *Connection
+}
+
+// NewManifestModel creates a new manifest model
+func NewManifestModel(conn *Connection) *ManifestModel {
+	return &ManifestModel{
+		conn: conn,
+	}
+}
+
+// ListManifests returns the most recent ingestion run manifests, newest first
+func (mm *ManifestModel) ListManifests(ctx context.Context, limit int) ([]IngestManifest, error) {
+	query := fmt.Sprintf("SELECT RAW m FROM `%s`.`_default`.`_default` AS m WHERE META(m).id LIKE $prefix AND m.startedAt IS NOT MISSING ORDER BY m.startedAt DESC LIMIT $limit",
+		mm.conn.GetBucketName())
+	params := map[string]interface{}{
+		"prefix": IngestManifestKeyPrefix + "%",
+		"limit":  limit,
+	}
+
+	rows, err := mm.conn.GetCluster().Query(query, &gocb.QueryOptions{Context: ctx, NamedParameters: params})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ingest manifests: %w", err)
+	}
+	defer rows.Close()
+
+	manifests := []IngestManifest{}
+	for rows.Next() {
+		var manifest IngestManifest
+		if err := rows.Row(&manifest); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("Failed to decode ingest manifest")
+			continue
+		}
+		manifests = append(manifests, manifest)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ingest manifests: %w", err)
+	}
+
+	return manifests, nil
+}
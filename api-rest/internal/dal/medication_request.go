@@ -0,0 +1,50 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MedicationRequestModel handles medication request-specific database operations
+type MedicationRequestModel struct {
+	resourceModel *ResourceModel
+}
+
+// NewMedicationRequestModel creates a new medication request model instance
+func NewMedicationRequestModel(resourceModel *ResourceModel) *MedicationRequestModel {
+	return &MedicationRequestModel{resourceModel: resourceModel}
+}
+
+// NewMedicationRequestModelWithTenant creates a new medication request model instance for a specific tenant
+func NewMedicationRequestModelWithTenant(conn *Connection, tenantScope string) *MedicationRequestModel {
+	resourceModel := NewResourceModelWithTenant(conn, tenantScope)
+	return &MedicationRequestModel{resourceModel: resourceModel}
+}
+
+// GetByID retrieves a medication request by ID
+func (mm *MedicationRequestModel) GetByID(ctx context.Context, id string) (map[string]interface{}, error) {
+	log.Ctx(ctx).Debug().
+		Str("id", id).
+		Msg("Getting medication request by ID")
+
+	docID := fmt.Sprintf("MedicationRequest/%s", id)
+	return mm.resourceModel.GetResource(ctx, docID)
+}
+
+// List retrieves a paginated list of medication requests
+func (mm *MedicationRequestModel) List(ctx context.Context, page, count int, cursor string) (*PaginatedResponse, error) {
+	log.Ctx(ctx).Debug().
+		Int("page", page).
+		Int("count", count).
+		Str("cursor", cursor).
+		Msg("Listing medication requests")
+
+	params := PaginationParams{
+		Page:        page,
+		Count:       count,
+		AfterCursor: cursor,
+	}
+	return mm.resourceModel.ListResources(ctx, "MedicationRequest", params)
+}
@@ -0,0 +1,50 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ObservationModel handles observation-specific database operations
+type ObservationModel struct {
+	resourceModel *ResourceModel
+}
+
+// NewObservationModel creates a new observation model instance
+func NewObservationModel(resourceModel *ResourceModel) *ObservationModel {
+	return &ObservationModel{resourceModel: resourceModel}
+}
+
+// NewObservationModelWithTenant creates a new observation model instance for a specific tenant
+func NewObservationModelWithTenant(conn *Connection, tenantScope string) *ObservationModel {
+	resourceModel := NewResourceModelWithTenant(conn, tenantScope)
+	return &ObservationModel{resourceModel: resourceModel}
+}
+
+// GetByID retrieves an observation by ID
+func (om *ObservationModel) GetByID(ctx context.Context, id string) (map[string]interface{}, error) {
+	log.Ctx(ctx).Debug().
+		Str("id", id).
+		Msg("Getting observation by ID")
+
+	docID := fmt.Sprintf("Observation/%s", id)
+	return om.resourceModel.GetResource(ctx, docID)
+}
+
+// List retrieves a paginated list of observations
+func (om *ObservationModel) List(ctx context.Context, page, count int, cursor string) (*PaginatedResponse, error) {
+	log.Ctx(ctx).Debug().
+		Int("page", page).
+		Int("count", count).
+		Str("cursor", cursor).
+		Msg("Listing observations")
+
+	params := PaginationParams{
+		Page:        page,
+		Count:       count,
+		AfterCursor: cursor,
+	}
+	return om.resourceModel.ListResources(ctx, "Observation", params)
+}
@@ -26,7 +26,7 @@ func NewPatientModelWithTenant(conn *Connection, tenantScope string) *PatientMod
 
 // GetByID retrieves a patient by ID
 func (pm *PatientModel) GetByID(ctx context.Context, id string) (map[string]interface{}, error) {
-	log.Debug().
+	log.Ctx(ctx).Debug().
 		Str("id", id).
 		Msg("Getting patient by ID")
 
@@ -35,15 +35,17 @@ func (pm *PatientModel) GetByID(ctx context.Context, id string) (map[string]inte
 }
 
 // List retrieves a paginated list of patients
-func (pm *PatientModel) List(ctx context.Context, page, count int) (*PaginatedResponse, error) {
-	log.Debug().
+func (pm *PatientModel) List(ctx context.Context, page, count int, cursor string) (*PaginatedResponse, error) {
+	log.Ctx(ctx).Debug().
 		Int("page", page).
 		Int("count", count).
+		Str("cursor", cursor).
 		Msg("Listing patients")
 
 	params := PaginationParams{
-		Page:  page,
-		Count: count,
+		Page:        page,
+		Count:       count,
+		AfterCursor: cursor,
 	}
 	return pm.resourceModel.ListResources(ctx, "Patient", params)
 }
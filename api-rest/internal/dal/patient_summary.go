@@ -0,0 +1,144 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// PatientSummary holds the counts of resources linked to a patient
+type PatientSummary struct {
+	EncounterCount int `json:"encounterCount"`
+}
+
+// patientSummaryEntry is a cached patient summary with its expiry time
+type patientSummaryEntry struct {
+	summary   PatientSummary
+	expiresAt time.Time
+}
+
+// patientSummaryCache caches patient summaries by tenant scope and patient ID
+type patientSummaryCache struct {
+	mu      sync.Mutex
+	entries map[string]patientSummaryEntry
+}
+
+// patientSummaries is the process-wide patient summary cache
+var patientSummaries = &patientSummaryCache{entries: make(map[string]patientSummaryEntry)}
+
+// patientSummaryKey builds the cache key for a patient in a tenant scope
+func patientSummaryKey(tenantScope, patientID string) string {
+	return tenantScope + "/" + patientID
+}
+
+// get returns the cached summary if it has not expired at now
+func (c *patientSummaryCache) get(key string, now time.Time) (PatientSummary, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return PatientSummary{}, false
+	}
+	if !now.Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return PatientSummary{}, false
+	}
+	return entry.summary, true
+}
+
+// set stores a summary until expiresAt
+func (c *patientSummaryCache) set(key string, summary PatientSummary, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = patientSummaryEntry{summary: summary, expiresAt: expiresAt}
+}
+
+// invalidate drops the cached summary for key
+func (c *patientSummaryCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// invalidatePrefix drops every cached summary whose key starts with prefix
+func (c *patientSummaryCache) invalidatePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// InvalidatePatientSummary drops the cached summary of a patient in a tenant scope
+func InvalidatePatientSummary(tenantScope, patientID string) {
+	patientSummaries.invalidate(patientSummaryKey(tenantScope, patientID))
+}
+
+// InvalidateTenantPatientSummaries drops all cached patient summaries of a tenant scope
+func InvalidateTenantPatientSummaries(tenantScope string) {
+	patientSummaries.invalidatePrefix(tenantScope + "/")
+}
+
+// summaryCacheTTL returns how long patient summaries are cached,
+// configurable via SUMMARY_CACHE_TTL_SECONDS (default 5 minutes)
+func summaryCacheTTL() time.Duration {
+	if value := os.Getenv("SUMMARY_CACHE_TTL_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 5 * time.Minute
+}
+
+// countLinkedResources counts the documents of a collection linked to a patient by subjectPatientId
+var countLinkedResources = func(ctx context.Context, rm *ResourceModel, collectionName, patientID string) (int, error) {
+	query := fmt.Sprintf("SELECT RAW COUNT(*) FROM `%s`.`%s`.`%s` AS d WHERE d.subjectPatientId = $patientId",
+		rm.conn.GetBucketName(), rm.tenantScope, collectionName)
+
+	result, err := executeQueryWithParams(ctx, rm.conn, rm.tenantScope, query, map[string]interface{}{"patientId": patientID})
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	if err := result.One(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetSummary returns the counts of resources linked to a patient, cached for SUMMARY_CACHE_TTL_SECONDS
+func (pm *PatientModel) GetSummary(ctx context.Context, patientID string) (*PatientSummary, error) {
+	key := patientSummaryKey(pm.resourceModel.tenantScope, patientID)
+	if summary, ok := patientSummaries.get(key, time.Now()); ok {
+		log.Ctx(ctx).Debug().
+			Str("id", patientID).
+			Str("tenant_scope", pm.resourceModel.tenantScope).
+			Msg("Patient summary served from cache")
+		return &summary, nil
+	}
+
+	encounterCount, err := countLinkedResources(ctx, pm.resourceModel, "encounters", patientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count encounters for patient %s: %w", patientID, err)
+	}
+
+	summary := PatientSummary{EncounterCount: encounterCount}
+	patientSummaries.set(key, summary, time.Now().Add(summaryCacheTTL()))
+
+	log.Ctx(ctx).Debug().
+		Str("id", patientID).
+		Str("tenant_scope", pm.resourceModel.tenantScope).
+		Int("encounterCount", encounterCount).
+		Msg("Patient summary computed")
+	return &summary, nil
+}
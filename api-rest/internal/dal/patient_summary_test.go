@@ -0,0 +1,140 @@
+package dal
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// useCountLinkedResources replaces the COUNT query with counts from a map and resets the cache
+func useCountLinkedResources(t *testing.T, counts map[string]int) *int {
+	t.Helper()
+
+	orig := countLinkedResources
+	queries := 0
+	countLinkedResources = func(ctx context.Context, rm *ResourceModel, collectionName, patientID string) (int, error) {
+		queries++
+		return counts[rm.tenantScope+"/"+collectionName+"/"+patientID], nil
+	}
+	patientSummaries = &patientSummaryCache{entries: make(map[string]patientSummaryEntry)}
+	t.Cleanup(func() {
+		countLinkedResources = orig
+		patientSummaries = &patientSummaryCache{entries: make(map[string]patientSummaryEntry)}
+	})
+	return &queries
+}
+
+func TestPatientModelGetSummary(t *testing.T) {
+	counts := map[string]int{
+		"tenant1/encounters/p1": 3,
+		"tenant1/encounters/p2": 0,
+		"tenant2/encounters/p1": 7,
+	}
+	useCountLinkedResources(t, counts)
+
+	tests := []struct {
+		name        string
+		tenantScope string
+		patientID   string
+		want        int
+	}{
+		{name: "patient with encounters", tenantScope: "tenant1", patientID: "p1", want: 3},
+		{name: "patient without encounters", tenantScope: "tenant1", patientID: "p2", want: 0},
+		{name: "same patient in another tenant", tenantScope: "tenant2", patientID: "p1", want: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := &PatientModel{resourceModel: &ResourceModel{tenantScope: tt.tenantScope}}
+			summary, err := pm.GetSummary(context.Background(), tt.patientID)
+			if err != nil {
+				t.Fatalf("GetSummary() error = %v", err)
+			}
+			if summary.EncounterCount != tt.want {
+				t.Errorf("EncounterCount = %d, want %d", summary.EncounterCount, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatientModelGetSummaryCache(t *testing.T) {
+	counts := map[string]int{"tenant1/encounters/p1": 3}
+	queries := useCountLinkedResources(t, counts)
+	pm := &PatientModel{resourceModel: &ResourceModel{tenantScope: "tenant1"}}
+	ctx := context.Background()
+
+	if _, err := pm.GetSummary(ctx, "p1"); err != nil {
+		t.Fatalf("GetSummary() error = %v", err)
+	}
+
+	// A new encounter is not visible until the cached summary is invalidated
+	counts["tenant1/encounters/p1"] = 4
+	summary, _ := pm.GetSummary(ctx, "p1")
+	if summary.EncounterCount != 3 || *queries != 1 {
+		t.Fatalf("cached EncounterCount = %d after %d queries, want 3 after 1", summary.EncounterCount, *queries)
+	}
+
+	InvalidatePatientSummary("tenant1", "p1")
+	summary, _ = pm.GetSummary(ctx, "p1")
+	if summary.EncounterCount != 4 {
+		t.Errorf("EncounterCount after invalidation = %d, want 4", summary.EncounterCount)
+	}
+
+	counts["tenant1/encounters/p1"] = 5
+	InvalidateTenantPatientSummaries("tenant1")
+	summary, _ = pm.GetSummary(ctx, "p1")
+	if summary.EncounterCount != 5 {
+		t.Errorf("EncounterCount after tenant invalidation = %d, want 5", summary.EncounterCount)
+	}
+}
+
+func TestPatientSummaryCacheExpiry(t *testing.T) {
+	cache := &patientSummaryCache{entries: make(map[string]patientSummaryEntry)}
+	now := time.Now()
+	cache.set("tenant1/p1", PatientSummary{EncounterCount: 2}, now.Add(time.Minute))
+
+	if _, ok := cache.get("tenant1/p1", now.Add(30*time.Second)); !ok {
+		t.Error("get() before expiry = miss, want hit")
+	}
+	if _, ok := cache.get("tenant1/p1", now.Add(time.Minute)); ok {
+		t.Error("get() at expiry = hit, want miss")
+	}
+}
+
+func TestInvalidateTenantPatientSummariesKeepsOtherTenants(t *testing.T) {
+	useCountLinkedResources(t, nil)
+	expiresAt := time.Now().Add(time.Minute)
+	patientSummaries.set(patientSummaryKey("tenant1", "p1"), PatientSummary{EncounterCount: 1}, expiresAt)
+	patientSummaries.set(patientSummaryKey("tenant10", "p1"), PatientSummary{EncounterCount: 2}, expiresAt)
+
+	InvalidateTenantPatientSummaries("tenant1")
+
+	if _, ok := patientSummaries.get(patientSummaryKey("tenant1", "p1"), time.Now()); ok {
+		t.Error("tenant1 summary still cached after invalidation")
+	}
+	if _, ok := patientSummaries.get(patientSummaryKey("tenant10", "p1"), time.Now()); !ok {
+		t.Error("tenant10 summary dropped by tenant1 invalidation")
+	}
+}
+
+func TestSummaryCacheTTL(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "default", value: "", want: 5 * time.Minute},
+		{name: "custom", value: "30", want: 30 * time.Second},
+		{name: "invalid", value: "abc", want: 5 * time.Minute},
+		{name: "negative", value: "-1", want: 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SUMMARY_CACHE_TTL_SECONDS", tt.value)
+			if got := summaryCacheTTL(); got != tt.want {
+				t.Errorf("summaryCacheTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
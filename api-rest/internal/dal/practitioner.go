@@ -26,7 +26,7 @@ func NewPractitionerModelWithTenant(conn *Connection, tenantScope string) *Pract
 
 // GetByID retrieves a practitioner by ID
 func (prm *PractitionerModel) GetByID(ctx context.Context, id string) (map[string]interface{}, error) {
-	log.Debug().
+	log.Ctx(ctx).Debug().
 		Str("id", id).
 		Msg("Getting practitioner by ID")
 
@@ -34,16 +34,58 @@ func (prm *PractitionerModel) GetByID(ctx context.Context, id string) (map[strin
 	return prm.resourceModel.GetResource(ctx, docID)
 }
 
+// activeEncounterStatus is the Encounter status counted as a practitioner's current workload
+const activeEncounterStatus = "in-progress"
+
+// countWhere runs a COUNT(*) N1QL query over a collection of the model's scope
+var countWhere = func(ctx context.Context, rm *ResourceModel, collectionName, where string, params map[string]interface{}) (int64, error) {
+	query := fmt.Sprintf("SELECT RAW COUNT(*) FROM `%s`.`%s`.`%s` AS d WHERE %s",
+		rm.conn.GetBucketName(), rm.tenantScope, collectionName, where)
+
+	result, err := executeQueryWithParams(ctx, rm.conn, rm.tenantScope, query, params)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err := result.One(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountActiveEncounters counts the in-progress encounters that list the practitioner
+func (prm *PractitionerModel) CountActiveEncounters(ctx context.Context, practitionerID string) (int64, error) {
+	where := "d.status = $status AND ANY p IN d.practitionerIds SATISFIES p = $practitionerId END"
+	params := map[string]interface{}{
+		"status":         activeEncounterStatus,
+		"practitionerId": practitionerID,
+	}
+
+	count, err := countWhere(ctx, prm.resourceModel, "encounters", where, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count active encounters for practitioner %s: %w", practitionerID, err)
+	}
+
+	log.Ctx(ctx).Debug().
+		Str("id", practitionerID).
+		Int64("activeEncounters", count).
+		Msg("Counted practitioner active encounters")
+	return count, nil
+}
+
 // List retrieves a paginated list of practitioners
-func (prm *PractitionerModel) List(ctx context.Context, page, count int) (*PaginatedResponse, error) {
-	log.Debug().
+func (prm *PractitionerModel) List(ctx context.Context, page, count int, cursor string) (*PaginatedResponse, error) {
+	log.Ctx(ctx).Debug().
 		Int("page", page).
 		Int("count", count).
+		Str("cursor", cursor).
 		Msg("Listing practitioners")
 
 	params := PaginationParams{
-		Page:  page,
-		Count: count,
+		Page:        page,
+		Count:       count,
+		AfterCursor: cursor,
 	}
 	return prm.resourceModel.ListResources(ctx, "Practitioner", params)
 }
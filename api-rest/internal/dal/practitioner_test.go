@@ -0,0 +1,67 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// useEncounterCounts answers COUNT queries by evaluating the active encounter filter over encounters
+func useEncounterCounts(t *testing.T, encounters []map[string]interface{}) {
+	t.Helper()
+
+	orig := countWhere
+	countWhere = func(ctx context.Context, rm *ResourceModel, collectionName, where string, params map[string]interface{}) (int64, error) {
+		if collectionName != "encounters" || !strings.Contains(where, "d.status = $status") || !strings.Contains(where, "d.practitionerIds") {
+			return 0, errors.New("unexpected count query")
+		}
+
+		var count int64
+		for _, encounter := range encounters {
+			ids, _ := encounter["practitionerIds"].([]string)
+			if encounter["status"] == params["status"] && slices.Contains(ids, params["practitionerId"].(string)) {
+				count++
+			}
+		}
+		return count, nil
+	}
+	t.Cleanup(func() {
+		countWhere = orig
+	})
+}
+
+func TestPractitionerModelCountActiveEncounters(t *testing.T) {
+	useEncounterCounts(t, []map[string]interface{}{
+		{"id": "e1", "status": "in-progress", "practitionerIds": []string{"busy", "single"}},
+		{"id": "e2", "status": "in-progress", "practitionerIds": []string{"busy"}},
+		{"id": "e3", "status": "in-progress", "practitionerIds": []string{"busy", "other"}},
+		{"id": "e4", "status": "finished", "practitionerIds": []string{"single", "idle"}},
+		{"id": "e5", "status": "planned", "practitionerIds": []string{"idle"}},
+	})
+
+	tests := []struct {
+		name           string
+		practitionerID string
+		expected       int64
+	}{
+		{name: "No active encounters", practitionerID: "idle", expected: 0},
+		{name: "One active encounter", practitionerID: "single", expected: 1},
+		{name: "Multiple active encounters", practitionerID: "busy", expected: 3},
+		{name: "Unknown practitioner", practitionerID: "missing", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prm := &PractitionerModel{resourceModel: &ResourceModel{tenantScope: "tenant1"}}
+			count, err := prm.CountActiveEncounters(context.Background(), tt.practitionerID)
+			if err != nil {
+				t.Fatalf("CountActiveEncounters() error = %v", err)
+			}
+			if count != tt.expected {
+				t.Errorf("CountActiveEncounters(%q) = %d, want %d", tt.practitionerID, count, tt.expected)
+			}
+		})
+	}
+}
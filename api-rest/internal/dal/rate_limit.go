@@ -0,0 +1,48 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"stealthcompany.com/api-rest/internal/metrics"
+)
+
+// RateLimitKeyPrefix is the document key prefix of the per-tenant request counters
+const RateLimitKeyPrefix = "ratelimit/"
+
+// RateLimitKey returns the counter document key of a tenant for the window starting at windowStart
+func RateLimitKey(tenantID string, windowStart time.Time) string {
+	return fmt.Sprintf("%s%s/%d", RateLimitKeyPrefix, tenantID, windowStart.Unix())
+}
+
+// RateLimitModel counts tenant requests per time window in the default collection,
+// so every api-rest instance shares the same counters
+type RateLimitModel struct {
+	conn *Connection
+}
+
+// NewRateLimitModel creates a new rate limit model
+func NewRateLimitModel(conn *Connection) *RateLimitModel {
+	return &RateLimitModel{
+		conn: conn,
+	}
+}
+
+// Increment adds one request to the counter document and returns the new count.
+// The document is created on the first request of the window and expires with it.
+func (rl *RateLimitModel) Increment(ctx context.Context, key string, window time.Duration) (uint64, error) {
+	start := time.Now()
+	result, err := rl.conn.GetDefaultCollection().Binary().Increment(key, &gocb.IncrementOptions{
+		Initial: 1,
+		Delta:   1,
+		Expiry:  window,
+		Context: ctx,
+	})
+	metrics.RecordCouchbaseOperation(ctx, "increment", operationStatus(err), time.Since(start))
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment rate limit counter: %w", err)
+	}
+	return result.Content(), nil
+}
@@ -2,39 +2,92 @@ package dal
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/couchbase/gocb/v2"
 	"github.com/rs/zerolog/log"
 )
 
+// Allowed review severities
+const (
+	ReviewSeverityInfo     = "info"
+	ReviewSeverityWarning  = "warning"
+	ReviewSeverityCritical = "critical"
+)
+
+// ReviewActionDeleted is the audit action recorded when a review is removed
+const ReviewActionDeleted = "review_deleted"
+
+// ErrNotReviewed is returned when removing the review of a resource that is not reviewed
+var ErrNotReviewed = errors.New("resource not reviewed")
+
+// ErrReviewConflict is returned when the resource changed after the CAS given with the review was read
+var ErrReviewConflict = errors.New("resource was modified since it was read")
+
 // ReviewInfo contains review status and metadata embedded in resource documents
 type ReviewInfo struct {
 	Reviewed   bool   `json:"reviewed"`
 	ReviewTime string `json:"reviewTime,omitempty"`
+	Notes      string `json:"reviewNotes,omitempty"`
+	Severity   string `json:"reviewSeverity,omitempty"`
+}
+
+// ReviewDetails contains the optional reviewer input attached to a review request
+type ReviewDetails struct {
+	Notes    string
+	Severity string
+	// ReviewerUsername is the authenticated user recorded in the review history
+	ReviewerUsername string
+	// Cas, when set, applies the review only if the document CAS still matches
+	Cas gocb.Cas
+}
+
+// ReviewEvent is an entry of the reviewHistory array embedded in resource documents,
+// appended by every review so earlier reviews are kept
+type ReviewEvent struct {
+	ReviewedAt       time.Time `json:"reviewedAt"`
+	TenantID         string    `json:"tenantId"`
+	ReviewerUsername string    `json:"reviewerUsername,omitempty"`
+}
+
+// IsValidReviewSeverity checks if severity is one of the allowed values or empty
+func IsValidReviewSeverity(severity string) bool {
+	switch severity {
+	case "", ReviewSeverityInfo, ReviewSeverityWarning, ReviewSeverityCritical:
+		return true
+	default:
+		return false
+	}
 }
 
 // ReviewModel handles review-specific database operations using embedded fields
 type ReviewModel struct {
 	resourceModel *ResourceModel
+	// transactions applies reviews given without a CAS
+	transactions *gocb.Transactions
 }
 
-// NewReviewModel creates a new review model instance
-func NewReviewModel(resourceModel *ResourceModel) *ReviewModel {
-	return &ReviewModel{resourceModel: resourceModel}
+// NewReviewModel creates a new review model instance applying reviews through transactions
+func NewReviewModel(resourceModel *ResourceModel, transactions *gocb.Transactions) *ReviewModel {
+	return &ReviewModel{resourceModel: resourceModel, transactions: transactions}
 }
 
 // NewReviewModelWithTenant creates a new review model instance for a specific tenant
 func NewReviewModelWithTenant(conn *Connection, tenantScope string) *ReviewModel {
 	resourceModel := NewResourceModelWithTenant(conn, tenantScope)
-	return &ReviewModel{resourceModel: resourceModel}
+	return &ReviewModel{resourceModel: resourceModel, transactions: conn.GetCluster().Transactions()}
 }
 
-// GetReviewInfo checks if a resource is reviewed and returns review metadata from embedded fields
-func (rm *ReviewModel) GetReviewInfo(ctx context.Context, tenantID, resourceType, resourceID string) ReviewInfo {
+// GetReviewInfo checks if a resource is reviewed and returns review metadata from embedded fields.
+// An error means the review status is unknown, not that the resource is un-reviewed.
+func (rm *ReviewModel) GetReviewInfo(ctx context.Context, tenantID, resourceType, resourceID string) (ReviewInfo, error) {
 	docID := fmt.Sprintf("%s/%s", resourceType, resourceID)
 
-	log.Debug().
+	log.Ctx(ctx).Debug().
 		Str("tenantID", tenantID).
 		Str("resourceType", resourceType).
 		Str("resourceID", resourceID).
@@ -44,44 +97,76 @@ func (rm *ReviewModel) GetReviewInfo(ctx context.Context, tenantID, resourceType
 	// Get the resource document
 	resourceData, err := rm.resourceModel.GetResource(ctx, docID)
 	if err != nil {
-		log.Debug().
-			Err(err).
-			Str("docID", docID).
-			Msg("Resource not found")
-		return ReviewInfo{Reviewed: false}
+		return ReviewInfo{}, fmt.Errorf("failed to get review info for %s: %w", docID, err)
 	}
 
-	// Check for embedded review fields
+	reviewInfo := reviewInfoFromDocument(resourceData)
+
+	log.Ctx(ctx).Debug().
+		Str("docID", docID).
+		Bool("reviewed", reviewInfo.Reviewed).
+		Str("reviewTime", reviewInfo.ReviewTime).
+		Msg("Review info read from embedded fields")
+
+	return reviewInfo, nil
+}
+
+// reviewInfoFromDocument reads the embedded review fields of a resource document
+func reviewInfoFromDocument(resourceData map[string]interface{}) ReviewInfo {
 	reviewed, ok := resourceData["reviewed"].(bool)
 	if !ok {
-		log.Debug().
-			Str("docID", docID).
-			Msg("No review field found, resource not reviewed")
 		return ReviewInfo{Reviewed: false}
 	}
 
-	reviewTime := ""
+	reviewInfo := ReviewInfo{Reviewed: reviewed}
 	if rt, ok := resourceData["reviewTime"].(string); ok {
-		reviewTime = rt
+		reviewInfo.ReviewTime = rt
+	}
+	if notes, ok := resourceData["reviewNotes"].(string); ok {
+		reviewInfo.Notes = notes
+	}
+	if severity, ok := resourceData["reviewSeverity"].(string); ok {
+		reviewInfo.Severity = severity
 	}
 
-	log.Debug().
-		Str("docID", docID).
-		Bool("reviewed", reviewed).
-		Str("reviewTime", reviewTime).
-		Msg("Review info found in embedded fields")
+	return reviewInfo
+}
+
+// reviewEntrySpecs builds the sub-document mutations that embed a review into a resource document.
+// Notes and severity left over from a previous review are removed.
+func reviewEntrySpecs(resourceData map[string]interface{}, details ReviewDetails, reviewTime time.Time) []gocb.MutateInSpec {
+	specs := []gocb.MutateInSpec{
+		gocb.UpsertSpec("reviewed", true, nil),
+		gocb.UpsertSpec("reviewTime", reviewTime.UTC().Format(time.RFC3339), nil),
+	}
 
-	return ReviewInfo{
-		Reviewed:   reviewed,
-		ReviewTime: reviewTime,
+	optional := []struct {
+		field string
+		value string
+	}{
+		{field: "reviewNotes", value: details.Notes},
+		{field: "reviewSeverity", value: details.Severity},
 	}
+	for _, f := range optional {
+		if f.value != "" {
+			specs = append(specs, gocb.UpsertSpec(f.field, f.value, nil))
+		} else if _, ok := resourceData[f.field]; ok {
+			// Removing a missing path fails the whole mutation, so only remove fields the document has
+			specs = append(specs, gocb.RemoveSpec(f.field, nil))
+		}
+	}
+	return specs
 }
 
-// CreateReviewRequest creates or updates a review for a resource by embedding review fields
-func (rm *ReviewModel) CreateReviewRequest(ctx context.Context, tenantID, resourceType, resourceID string) error {
+// CreateReviewRequest creates or updates a review for a resource by embedding review fields and
+// appending a ReviewEvent to its review history.
+// The document is read and replaced in a transaction, so a concurrent update is never overwritten.
+// When details.Cas is set the review is applied with a CAS-checked MutateIn instead and
+// ErrReviewConflict is returned if the document changed since that CAS was read.
+func (rm *ReviewModel) CreateReviewRequest(ctx context.Context, tenantID, resourceType, resourceID string, details ReviewDetails) error {
 	docID := fmt.Sprintf("%s/%s", resourceType, resourceID)
 
-	log.Debug().
+	log.Ctx(ctx).Debug().
 		Str("tenantID", tenantID).
 		Str("resourceType", resourceType).
 		Str("resourceID", resourceID).
@@ -91,47 +176,296 @@ func (rm *ReviewModel) CreateReviewRequest(ctx context.Context, tenantID, resour
 	// Verify the resource exists
 	exists, err := rm.resourceModel.ResourceExists(ctx, docID)
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("docID", docID).
 			Msg("Failed to check resource existence")
 		return fmt.Errorf("failed to verify resource: %w", err)
 	}
 	if !exists {
-		log.Warn().
+		log.Ctx(ctx).Warn().
 			Str("docID", docID).
 			Msg("Resource not found")
 		return fmt.Errorf("resource not found")
 	}
 
+	// Transactions cannot check the CAS read by the reviewer
+	if details.Cas == 0 {
+		return rm.applyReviewInTransaction(ctx, tenantID, docID, resourceType, details)
+	}
+
 	// Get the current resource document
 	resourceData, err := rm.resourceModel.GetResource(ctx, docID)
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("docID", docID).
 			Msg("Failed to get resource for review update")
 		return fmt.Errorf("failed to get resource: %w", err)
 	}
 
-	// Add embedded review fields
+	return rm.applyReview(ctx, tenantID, docID, resourceType, resourceData, details)
+}
+
+// reviewTransactionRetries is the number of times a review transaction failing on a conflict is retried
+const reviewTransactionRetries = 3
+
+// reviewTransactionRetryBaseDelay is the first backoff delay between review transaction retries (overridable in tests)
+var reviewTransactionRetryBaseDelay = 50 * time.Millisecond
+
+// isTransactionConflict checks if a transaction failed because of a concurrent transaction or write
+func isTransactionConflict(err error) bool {
+	return errors.Is(err, gocb.ErrWriteWriteConflict) ||
+		errors.Is(err, gocb.ErrDocAlreadyInTransaction) ||
+		errors.Is(err, gocb.ErrConcurrentOperationsDetectedOnSameDocument) ||
+		errors.Is(err, gocb.ErrAttemptExpired)
+}
+
+// runReviewTransaction reads a resource document, lets mutate change its content and replaces it,
+// all in one transaction (overridable in tests). The transaction ends with ctx's deadline.
+// Unlike MutateIn it cannot preserve the document expiry, the collection max TTL applies again.
+var runReviewTransaction = func(ctx context.Context, rm *ReviewModel, resourceType, docID string, mutate func(map[string]interface{})) error {
+	collection := rm.resourceModel.getCollectionForResource(resourceType)
+
+	opts := &gocb.TransactionOptions{}
+	if deadline, ok := ctx.Deadline(); ok {
+		opts.Timeout = time.Until(deadline)
+	}
+
+	_, err := rm.transactions.Run(func(attempt *gocb.TransactionAttemptContext) error {
+		doc, err := attempt.Get(collection, docID)
+		if err != nil {
+			return err
+		}
+
+		var content map[string]interface{}
+		if err := doc.Content(&content); err != nil {
+			return fmt.Errorf("%w: %w", errDocumentDecode, err)
+		}
+		mutate(content)
+
+		_, err = attempt.Replace(doc, content)
+		return err
+	}, opts)
+	return err
+}
+
+// embedReview sets the review fields of a resource document and appends event to its review history,
+// the in-memory counterpart of reviewEntrySpecs and reviewHistorySpec
+func embedReview(resourceData map[string]interface{}, details ReviewDetails, event ReviewEvent) {
 	resourceData["reviewed"] = true
-	resourceData["reviewTime"] = time.Now().UTC().Format(time.RFC3339)
+	resourceData["reviewTime"] = event.ReviewedAt.Format(time.RFC3339)
+
+	// Notes and severity left over from a previous review are removed
+	optional := map[string]string{
+		"reviewNotes":    details.Notes,
+		"reviewSeverity": details.Severity,
+	}
+	for field, value := range optional {
+		if value != "" {
+			resourceData[field] = value
+		} else {
+			delete(resourceData, field)
+		}
+	}
+
+	history, _ := resourceData["reviewHistory"].([]interface{})
+	resourceData["reviewHistory"] = append(history, event)
+}
+
+// applyReviewInTransaction embeds the review in a transaction, retrying up to reviewTransactionRetries times
+// with exponential backoff when the transaction fails on a conflict
+func (rm *ReviewModel) applyReviewInTransaction(ctx context.Context, tenantID, docID, resourceType string, details ReviewDetails) error {
+	delay := reviewTransactionRetryBaseDelay
+	for attempt := 0; ; attempt++ {
+		// Each attempt records its own review time, the one of the transaction that commits is kept
+		event := ReviewEvent{
+			ReviewedAt:       time.Now().UTC(),
+			TenantID:         tenantID,
+			ReviewerUsername: details.ReviewerUsername,
+		}
+		err := runReviewTransaction(ctx, rm, resourceType, docID, func(resourceData map[string]interface{}) {
+			embedReview(resourceData, details, event)
+		})
+		if err == nil {
+			break
+		}
+		if !isTransactionConflict(err) || attempt >= reviewTransactionRetries {
+			log.Ctx(ctx).Error().
+				Err(err).
+				Str("docID", docID).
+				Int("attempts", attempt+1).
+				Msg("Failed to update resource with review fields")
+			return fmt.Errorf("failed to update resource with review: %w", err)
+		}
+
+		log.Ctx(ctx).Warn().
+			Err(err).
+			Str("docID", docID).
+			Int("retry", attempt+1).
+			Int("max_retries", reviewTransactionRetries).
+			Dur("backoff", delay).
+			Msg("Review transaction conflicted, retrying")
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("review retry cancelled: %w", ctx.Err())
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+
+	InvalidateReviewSummary(rm.resourceModel.tenantScope)
+	invalidateEncounterSummary(ctx, rm.resourceModel, resourceType, docID)
 
-	// Update the resource document with embedded review fields
-	err = rm.resourceModel.UpsertResource(ctx, docID, resourceData)
+	log.Ctx(ctx).Info().
+		Str("tenantID", tenantID).
+		Str("docID", docID).
+		Msg("Review request created successfully in a transaction")
+
+	return nil
+}
+
+// reviewHistorySpec appends a review event to the reviewHistory array. gocb has no spec creating a
+// missing array on its own, CreatePath makes the append create it on the first review.
+func reviewHistorySpec(event ReviewEvent) gocb.MutateInSpec {
+	return gocb.ArrayAppendSpec("reviewHistory", event, &gocb.ArrayAppendSpecOptions{CreatePath: true})
+}
+
+// applyReview embeds the review fields and appends the review to the history in a single MutateIn,
+// checked against details.Cas when it is set
+func (rm *ReviewModel) applyReview(ctx context.Context, tenantID, docID, resourceType string, resourceData map[string]interface{}, details ReviewDetails) error {
+	reviewTime := time.Now()
+	specs := append(reviewEntrySpecs(resourceData, details, reviewTime), reviewHistorySpec(ReviewEvent{
+		ReviewedAt:       reviewTime.UTC(),
+		TenantID:         tenantID,
+		ReviewerUsername: details.ReviewerUsername,
+	}))
+
+	collection := collectionForResource(ctx, rm.resourceModel, resourceType)
+	_, err := collection.MutateIn(docID, specs, &gocb.MutateInOptions{
+		Context:        ctx,
+		Cas:            details.Cas,
+		PreserveExpiry: true,
+	})
+	if errors.Is(err, gocb.ErrCasMismatch) {
+		log.Ctx(ctx).Warn().
+			Str("tenantID", tenantID).
+			Str("docID", docID).
+			Msg("Review rejected, resource changed since it was read")
+		return fmt.Errorf("%w: %s", ErrReviewConflict, docID)
+	}
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("docID", docID).
 			Msg("Failed to update resource with review fields")
 		return fmt.Errorf("failed to update resource with review: %w", err)
 	}
 
-	log.Info().
+	InvalidateReviewSummary(rm.resourceModel.tenantScope)
+	invalidateEncounterSummary(ctx, rm.resourceModel, resourceType, docID)
+
+	log.Ctx(ctx).Info().
 		Str("tenantID", tenantID).
 		Str("docID", docID).
+		Bool("casChecked", details.Cas != 0).
 		Msg("Review request created successfully with embedded fields")
 
 	return nil
 }
+
+// GetReviewHistory returns the reviews of a resource, oldest first. Resources reviewed before the
+// history was recorded, or never reviewed, have an empty history.
+func (rm *ReviewModel) GetReviewHistory(ctx context.Context, resourceType, resourceID string) ([]ReviewEvent, error) {
+	docID := fmt.Sprintf("%s/%s", resourceType, resourceID)
+
+	resourceData, err := rm.resourceModel.GetResource(ctx, docID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review history for %s: %w", docID, err)
+	}
+
+	history := []ReviewEvent{}
+	if raw, ok := resourceData["reviewHistory"]; ok {
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode review history of %s: %w", docID, err)
+		}
+		if err := json.Unmarshal(data, &history); err != nil {
+			return nil, fmt.Errorf("failed to decode review history of %s: %w", docID, err)
+		}
+	}
+
+	// Appends keep the array ordered, sorting also covers events written with clock skew between API instances
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].ReviewedAt.Before(history[j].ReviewedAt)
+	})
+
+	return history, nil
+}
+
+// reviewAuditEntry builds an entry of the audit array embedded in resource documents
+func reviewAuditEntry(action string, at time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"action": action,
+		"time":   at.UTC().Format(time.RFC3339),
+	}
+}
+
+// reviewRemovalSpecs builds the sub-document mutations that undo the review of a resource document
+func reviewRemovalSpecs(resourceData map[string]interface{}, removedAt time.Time) []gocb.MutateInSpec {
+	specs := []gocb.MutateInSpec{
+		gocb.UpsertSpec("reviewed", false, nil),
+	}
+	// Removing a missing path fails the whole mutation, so only remove fields the document has
+	for _, field := range []string{"reviewTime", "reviewNotes", "reviewSeverity"} {
+		if _, ok := resourceData[field]; ok {
+			specs = append(specs, gocb.RemoveSpec(field, nil))
+		}
+	}
+	specs = append(specs, gocb.ArrayAppendSpec("audit", reviewAuditEntry(ReviewActionDeleted, removedAt),
+		&gocb.ArrayAppendSpecOptions{CreatePath: true}))
+	return specs
+}
+
+// DeleteReviewRequest removes the review of a resource and records it in the document audit trail.
+// Returns ErrNotReviewed when the resource is not currently reviewed.
+func (rm *ReviewModel) DeleteReviewRequest(ctx context.Context, tenantID, resourceType, resourceID string) error {
+	docID := fmt.Sprintf("%s/%s", resourceType, resourceID)
+
+	log.Ctx(ctx).Debug().
+		Str("tenantID", tenantID).
+		Str("resourceType", resourceType).
+		Str("resourceID", resourceID).
+		Str("docID", docID).
+		Msg("Deleting review from embedded fields")
+
+	// GetResource fails with "resource not found" when the document does not exist
+	resourceData, err := rm.resourceModel.GetResource(ctx, docID)
+	if err != nil {
+		return fmt.Errorf("failed to get resource: %w", err)
+	}
+	if !reviewInfoFromDocument(resourceData).Reviewed {
+		return ErrNotReviewed
+	}
+
+	collection := rm.resourceModel.getCollectionForResource(resourceType)
+	_, err = collection.MutateIn(docID, reviewRemovalSpecs(resourceData, time.Now()), &gocb.MutateInOptions{Context: ctx})
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("docID", docID).
+			Msg("Failed to remove review fields")
+		return fmt.Errorf("failed to remove review from %s: %w", docID, err)
+	}
+
+	InvalidateReviewSummary(rm.resourceModel.tenantScope)
+	invalidateEncounterSummary(ctx, rm.resourceModel, resourceType, docID)
+
+	log.Ctx(ctx).Info().
+		Str("tenantID", tenantID).
+		Str("docID", docID).
+		Msg("Review removed from embedded fields")
+
+	return nil
+}
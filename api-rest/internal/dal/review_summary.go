@@ -0,0 +1,167 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"stealthcompany.com/api-rest/internal/metrics"
+)
+
+// ReviewCounts holds how many resources of a type exist and how many are reviewed
+type ReviewCounts struct {
+	Total    int     `json:"total"`
+	Reviewed int     `json:"reviewed"`
+	Pct      float64 `json:"pct"`
+}
+
+// ReviewSummary holds the review counts of each resource type
+type ReviewSummary struct {
+	Encounter    ReviewCounts `json:"encounter"`
+	Patient      ReviewCounts `json:"patient"`
+	Practitioner ReviewCounts `json:"practitioner"`
+}
+
+// reviewSummaryEntry is a cached review summary with its expiry time
+type reviewSummaryEntry struct {
+	summary   ReviewSummary
+	expiresAt time.Time
+}
+
+// reviewSummaryCache caches review summaries by tenant scope
+type reviewSummaryCache struct {
+	mu      sync.Mutex
+	entries map[string]reviewSummaryEntry
+}
+
+// reviewSummaries is the process-wide review summary cache
+var reviewSummaries = &reviewSummaryCache{entries: make(map[string]reviewSummaryEntry)}
+
+// get returns the cached summary if it has not expired at now
+func (c *reviewSummaryCache) get(tenantScope string, now time.Time) (ReviewSummary, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[tenantScope]
+	if !ok {
+		return ReviewSummary{}, false
+	}
+	if !now.Before(entry.expiresAt) {
+		delete(c.entries, tenantScope)
+		return ReviewSummary{}, false
+	}
+	return entry.summary, true
+}
+
+// set stores a summary until expiresAt
+func (c *reviewSummaryCache) set(tenantScope string, summary ReviewSummary, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[tenantScope] = reviewSummaryEntry{summary: summary, expiresAt: expiresAt}
+}
+
+// invalidate drops the cached summary of a tenant scope
+func (c *reviewSummaryCache) invalidate(tenantScope string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, tenantScope)
+}
+
+// InvalidateReviewSummary drops the cached review summary of a tenant scope
+func InvalidateReviewSummary(tenantScope string) {
+	reviewSummaries.invalidate(tenantScope)
+}
+
+// reviewSummaryCacheTTL returns how long review summaries are cached,
+// configurable via REVIEW_SUMMARY_CACHE_TTL_SECONDS (default 30 seconds)
+func reviewSummaryCacheTTL() time.Duration {
+	if value := os.Getenv("REVIEW_SUMMARY_CACHE_TTL_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 30 * time.Second
+}
+
+// reviewGroupRow is a row of the review breakdown query
+type reviewGroupRow struct {
+	Reviewed bool `json:"reviewed"`
+	Count    int  `json:"count"`
+}
+
+// countReviewStatus counts the documents of a collection grouped by their reviewed flag
+var countReviewStatus = func(ctx context.Context, rm *ResourceModel, collectionName string) (ReviewCounts, error) {
+	query := fmt.Sprintf("SELECT IFMISSINGORNULL(d.reviewed, false) AS reviewed, COUNT(*) AS count "+
+		"FROM `%s`.`%s`.`%s` AS d GROUP BY IFMISSINGORNULL(d.reviewed, false)",
+		rm.conn.GetBucketName(), rm.tenantScope, collectionName)
+
+	rows, err := runQuery(ctx, rm, query, nil)
+	if err != nil {
+		return ReviewCounts{}, err
+	}
+	defer rows.Close()
+
+	var counts ReviewCounts
+	for rows.Next() {
+		var row reviewGroupRow
+		if err := rows.Row(&row); err != nil {
+			return ReviewCounts{}, fmt.Errorf("failed to decode review count row: %w", err)
+		}
+		counts.Total += row.Count
+		if row.Reviewed {
+			counts.Reviewed += row.Count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return ReviewCounts{}, err
+	}
+	return counts, nil
+}
+
+// reviewedPct returns the reviewed share of total as a percentage rounded to one decimal
+func reviewedPct(reviewed, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(reviewed)*1000/float64(total)) / 10
+}
+
+// GetReviewSummary returns the review counts of each resource type, cached for REVIEW_SUMMARY_CACHE_TTL_SECONDS
+func (rm *ReviewModel) GetReviewSummary(ctx context.Context) (*ReviewSummary, error) {
+	tenantScope := rm.resourceModel.tenantScope
+	if summary, ok := reviewSummaries.get(tenantScope, time.Now()); ok {
+		metrics.RecordReviewSummaryCacheHit()
+		log.Ctx(ctx).Debug().Str("tenant_scope", tenantScope).Msg("Review summary served from cache")
+		return &summary, nil
+	}
+
+	var summary ReviewSummary
+	for _, target := range []struct {
+		collectionName string
+		counts         *ReviewCounts
+	}{
+		{"encounters", &summary.Encounter},
+		{"patients", &summary.Patient},
+		{"practitioners", &summary.Practitioner},
+	} {
+		counts, err := countReviewStatus(ctx, rm.resourceModel, target.collectionName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count reviewed %s: %w", target.collectionName, err)
+		}
+		counts.Pct = reviewedPct(counts.Reviewed, counts.Total)
+		*target.counts = counts
+	}
+
+	reviewSummaries.set(tenantScope, summary, time.Now().Add(reviewSummaryCacheTTL()))
+
+	log.Ctx(ctx).Debug().
+		Str("tenant_scope", tenantScope).
+		Interface("summary", summary).
+		Msg("Review summary computed")
+	return &summary, nil
+}
@@ -0,0 +1,170 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"stealthcompany.com/pkg/testutil"
+)
+
+// useReviewDocuments answers the review breakdown query by grouping in-memory documents per collection
+// and resets the review summary cache; it returns the number of queries run
+func useReviewDocuments(tb testing.TB, reviewed map[string][]bool) *int {
+	tb.Helper()
+
+	orig := runQuery
+	queries := 0
+	runQuery = func(ctx context.Context, rm *ResourceModel, query string, params map[string]interface{}) (queryRows, error) {
+		queries++
+		for collectionName, docs := range reviewed {
+			if !strings.Contains(query, "`"+collectionName+"`") {
+				continue
+			}
+			groups := map[bool]int{}
+			for _, r := range docs {
+				groups[r]++
+			}
+			var rows []interface{}
+			for r, count := range groups {
+				rows = append(rows, reviewGroupRow{Reviewed: r, Count: count})
+			}
+			return &testutil.MockQueryResult{Rows: rows}, nil
+		}
+		return &testutil.MockQueryResult{}, nil
+	}
+	reviewSummaries = &reviewSummaryCache{entries: make(map[string]reviewSummaryEntry)}
+	tb.Cleanup(func() {
+		runQuery = orig
+		reviewSummaries = &reviewSummaryCache{entries: make(map[string]reviewSummaryEntry)}
+	})
+	return &queries
+}
+
+// reviewedDocs returns total review flags of which the first reviewed are true
+func reviewedDocs(total, reviewed int) []bool {
+	docs := make([]bool, total)
+	for i := 0; i < reviewed; i++ {
+		docs[i] = true
+	}
+	return docs
+}
+
+func TestReviewModelGetReviewSummary(t *testing.T) {
+	queries := useReviewDocuments(t, map[string][]bool{
+		"encounters": reviewedDocs(100, 45),
+		"patients":   reviewedDocs(3, 1),
+	})
+
+	rm := NewReviewModel(testResourceModel("tenant1"), nil)
+	summary, err := rm.GetReviewSummary(context.Background())
+	if err != nil {
+		t.Fatalf("GetReviewSummary() error = %v", err)
+	}
+
+	want := ReviewSummary{
+		Encounter:    ReviewCounts{Total: 100, Reviewed: 45, Pct: 45},
+		Patient:      ReviewCounts{Total: 3, Reviewed: 1, Pct: 33.3},
+		Practitioner: ReviewCounts{},
+	}
+	if *summary != want {
+		t.Errorf("GetReviewSummary() = %+v, want %+v", *summary, want)
+	}
+	if *queries != 3 {
+		t.Errorf("Expected one query per collection, got %d", *queries)
+	}
+}
+
+func TestReviewModelGetReviewSummaryCache(t *testing.T) {
+	queries := useReviewDocuments(t, map[string][]bool{"encounters": reviewedDocs(10, 2)})
+	rm := NewReviewModel(testResourceModel("tenant1"), nil)
+
+	for i := 0; i < 2; i++ {
+		if _, err := rm.GetReviewSummary(context.Background()); err != nil {
+			t.Fatalf("GetReviewSummary() error = %v", err)
+		}
+	}
+	if *queries != 3 {
+		t.Errorf("Expected the second summary from the cache, got %d queries", *queries)
+	}
+
+	InvalidateReviewSummary("tenant1")
+	if _, err := rm.GetReviewSummary(context.Background()); err != nil {
+		t.Fatalf("GetReviewSummary() error = %v", err)
+	}
+	if *queries != 6 {
+		t.Errorf("Expected the summary recomputed after invalidation, got %d queries", *queries)
+	}
+}
+
+func TestReviewModelCreateReviewRequestInvalidatesSummary(t *testing.T) {
+	useReviewDocuments(t, map[string][]bool{})
+	bucket := useMockBucket(t)
+	if err := bucket.Collection("tenant1", "encounters").AddFixture("Encounter/1", map[string]interface{}{"id": "1"}); err != nil {
+		t.Fatalf("AddFixture() error = %v", err)
+	}
+	reviewSummaries.set("tenant1", ReviewSummary{}, time.Now().Add(time.Minute))
+
+	rm := NewReviewModel(testResourceModel("tenant1"), nil)
+	if err := rm.CreateReviewRequest(context.Background(), "tenant1", "Encounter", "1", ReviewDetails{}); err != nil {
+		t.Fatalf("CreateReviewRequest() error = %v", err)
+	}
+	if _, ok := reviewSummaries.get("tenant1", time.Now()); ok {
+		t.Error("Expected the review summary cache to be invalidated by a new review")
+	}
+}
+
+func TestReviewedPct(t *testing.T) {
+	tests := []struct {
+		reviewed int
+		total    int
+		want     float64
+	}{
+		{reviewed: 45, total: 100, want: 45},
+		{reviewed: 1, total: 3, want: 33.3},
+		{reviewed: 2, total: 3, want: 66.7},
+		{reviewed: 0, total: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d of %d", tt.reviewed, tt.total), func(t *testing.T) {
+			if got := reviewedPct(tt.reviewed, tt.total); got != tt.want {
+				t.Errorf("reviewedPct() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// BenchmarkReviewModelGetReviewSummary measures uncached summaries over 1000 documents per collection
+// and fails when the p95 latency exceeds the 100ms dashboard SLA
+func BenchmarkReviewModelGetReviewSummary(b *testing.B) {
+	useReviewDocuments(b, map[string][]bool{
+		"encounters":    reviewedDocs(1000, 450),
+		"patients":      reviewedDocs(1000, 200),
+		"practitioners": reviewedDocs(1000, 700),
+	})
+	rm := NewReviewModel(testResourceModel("tenant1"), nil)
+	ctx := context.Background()
+
+	durations := make([]time.Duration, 0, b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		InvalidateReviewSummary("tenant1")
+		start := time.Now()
+		if _, err := rm.GetReviewSummary(ctx); err != nil {
+			b.Fatalf("GetReviewSummary() error = %v", err)
+		}
+		durations = append(durations, time.Since(start))
+	}
+	b.StopTimer()
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	p95 := durations[(len(durations)*95)/100]
+	b.ReportMetric(float64(p95.Microseconds()), "p95-µs")
+	if p95 > 100*time.Millisecond {
+		b.Fatalf("p95 latency %v exceeds 100ms", p95)
+	}
+}
@@ -0,0 +1,496 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/couchbase/gocb/v2"
+
+	"stealthcompany.com/pkg/testutil"
+)
+
+// specField reads an unexported field of a gocb.MutateInSpec
+func specField(spec *gocb.MutateInSpec, name string) reflect.Value {
+	field := reflect.ValueOf(spec).Elem().FieldByName(name)
+	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+}
+
+// specOp returns the sub-document operation of a spec
+func specOp(spec gocb.MutateInSpec) uint64 {
+	return specField(&spec, "op").Uint()
+}
+
+// applyingCollection is a MockCollection whose MutateIn also applies top-level upsert, remove
+// and array append specs to the stored document, so reviews can be read back
+type applyingCollection struct {
+	*testutil.MockCollection
+}
+
+func (c applyingCollection) MutateIn(id string, specs []gocb.MutateInSpec, opts *gocb.MutateInOptions) (*gocb.MutateInResult, error) {
+	result, err := c.MockCollection.MutateIn(id, specs, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	if _, err := c.Get(id, &doc); err != nil {
+		return nil, err
+	}
+
+	upsertOp := specOp(gocb.UpsertSpec("path", nil, nil))
+	removeOp := specOp(gocb.RemoveSpec("path", nil))
+	appendOp := specOp(gocb.ArrayAppendSpec("path", nil, nil))
+	for i := range specs {
+		path := specField(&specs[i], "path").String()
+		value := specField(&specs[i], "value").Interface()
+		switch specOp(specs[i]) {
+		case upsertOp:
+			doc[path] = value
+		case removeOp:
+			delete(doc, path)
+		case appendOp:
+			array, _ := doc[path].([]interface{})
+			doc[path] = append(array, value)
+		}
+	}
+
+	if err := c.AddFixture(id, doc); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// useApplyingMockBucket is useMockBucket with collections applying their sub-document mutations
+func useApplyingMockBucket(t *testing.T) *testutil.MockBucket {
+	t.Helper()
+
+	bucket := useMockBucket(t)
+	orig := collectionForResource
+	collectionForResource = func(ctx context.Context, rm *ResourceModel, resourceType string) documentCollection {
+		return applyingCollection{bucket.Collection(rm.tenantScope, resourceCollectionName(resourceType))}
+	}
+	t.Cleanup(func() {
+		collectionForResource = orig
+	})
+	return bucket
+}
+
+func TestIsValidReviewSeverity(t *testing.T) {
+	tests := []struct {
+		severity string
+		expected bool
+	}{
+		{"", true},
+		{ReviewSeverityInfo, true},
+		{ReviewSeverityWarning, true},
+		{ReviewSeverityCritical, true},
+		{"urgent", false},
+		{"INFO", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.severity, func(t *testing.T) {
+			if result := IsValidReviewSeverity(tt.severity); result != tt.expected {
+				t.Errorf("Expected %v for %q, got %v", tt.expected, tt.severity, result)
+			}
+		})
+	}
+}
+
+func TestReviewEntryPersistence(t *testing.T) {
+	tests := []struct {
+		name    string
+		details ReviewDetails
+	}{
+		{
+			name:    "Review without notes or severity",
+			details: ReviewDetails{},
+		},
+		{
+			name:    "Info review with notes",
+			details: ReviewDetails{Notes: "Patient identity confirmed", Severity: ReviewSeverityInfo},
+		},
+		{
+			name:    "Warning review with notes",
+			details: ReviewDetails{Notes: "Encounter dates corrected", Severity: ReviewSeverityWarning},
+		},
+		{
+			name:    "Critical review without notes",
+			details: ReviewDetails{Severity: ReviewSeverityCritical},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := useApplyingMockBucket(t)
+			encounters := bucket.Collection("tenant1", "encounters")
+
+			// Document previously reviewed with other notes
+			if err := encounters.AddFixture("Encounter/123", map[string]interface{}{
+				"id":             "123",
+				"resourceType":   "Encounter",
+				"reviewed":       true,
+				"reviewNotes":    "old notes",
+				"reviewSeverity": ReviewSeverityCritical,
+			}); err != nil {
+				t.Fatalf("AddFixture() error = %v", err)
+			}
+
+			resourceModel := testResourceModel("tenant1")
+			if err := NewReviewModel(resourceModel, nil).CreateReviewRequest(context.Background(), "tenant1", "Encounter", "123", tt.details); err != nil {
+				t.Fatalf("CreateReviewRequest() error = %v", err)
+			}
+
+			doc, err := resourceModel.GetResource(context.Background(), "Encounter/123")
+			if err != nil {
+				t.Fatalf("GetResource() error = %v", err)
+			}
+			info := reviewInfoFromDocument(doc)
+
+			if !info.Reviewed {
+				t.Errorf("Expected resource to be reviewed")
+			}
+			if _, err := time.Parse(time.RFC3339, info.ReviewTime); err != nil {
+				t.Errorf("Expected an RFC3339 review time, got %q", info.ReviewTime)
+			}
+			if info.Notes != tt.details.Notes {
+				t.Errorf("Expected notes %q, got %q", tt.details.Notes, info.Notes)
+			}
+			if info.Severity != tt.details.Severity {
+				t.Errorf("Expected severity %q, got %q", tt.details.Severity, info.Severity)
+			}
+		})
+	}
+}
+
+func TestReviewAuditEntry(t *testing.T) {
+	at := time.Date(2025, 2, 3, 9, 15, 0, 0, time.FixedZone("BRT", -3*60*60))
+
+	entry := reviewAuditEntry(ReviewActionDeleted, at)
+	if entry["action"] != "review_deleted" {
+		t.Errorf("Expected action review_deleted, got %v", entry["action"])
+	}
+	if entry["time"] != "2025-02-03T12:15:00Z" {
+		t.Errorf("Expected time 2025-02-03T12:15:00Z, got %v", entry["time"])
+	}
+}
+
+func TestReviewRemovalSpecs(t *testing.T) {
+	removedAt := time.Date(2025, 2, 3, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		doc           map[string]interface{}
+		expectedSpecs int
+	}{
+		{
+			name:          "Review without notes or severity",
+			doc:           map[string]interface{}{"reviewed": true, "reviewTime": "2025-01-15T10:30:00Z"},
+			expectedSpecs: 3, // reviewed, reviewTime, audit
+		},
+		{
+			name: "Review with notes and severity",
+			doc: map[string]interface{}{
+				"reviewed":       true,
+				"reviewTime":     "2025-01-15T10:30:00Z",
+				"reviewNotes":    "Encounter dates corrected",
+				"reviewSeverity": ReviewSeverityWarning,
+			},
+			expectedSpecs: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if specs := reviewRemovalSpecs(tt.doc, removedAt); len(specs) != tt.expectedSpecs {
+				t.Errorf("Expected %d mutations, got %d", tt.expectedSpecs, len(specs))
+			}
+		})
+	}
+}
+
+func TestReviewEntrySpecs(t *testing.T) {
+	reviewTime := time.Date(2025, 2, 3, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		doc           map[string]interface{}
+		details       ReviewDetails
+		expectedSpecs int
+	}{
+		{
+			name:          "First review without details",
+			doc:           map[string]interface{}{"reviewed": false},
+			expectedSpecs: 2, // reviewed, reviewTime
+		},
+		{
+			name:          "Review with notes and severity",
+			doc:           map[string]interface{}{"reviewed": false},
+			details:       ReviewDetails{Notes: "Checked", Severity: ReviewSeverityInfo},
+			expectedSpecs: 4,
+		},
+		{
+			name:          "Re-review drops previous notes and severity",
+			doc:           map[string]interface{}{"reviewed": true, "reviewNotes": "old", "reviewSeverity": ReviewSeverityCritical},
+			expectedSpecs: 4, // reviewed, reviewTime, remove notes, remove severity
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if specs := reviewEntrySpecs(tt.doc, tt.details, reviewTime); len(specs) != tt.expectedSpecs {
+				t.Errorf("Expected %d mutations, got %d", tt.expectedSpecs, len(specs))
+			}
+		})
+	}
+}
+
+func TestReviewModelCreateReviewRequest(t *testing.T) {
+	bucket := useMockBucket(t)
+	encounters := bucket.Collection("tenant1", "encounters")
+	if err := encounters.AddFixture("Encounter/1", map[string]interface{}{
+		"resourceType":   "Encounter",
+		"id":             "1",
+		"reviewed":       true,
+		"reviewNotes":    "old notes",
+		"reviewSeverity": ReviewSeverityCritical,
+	}); err != nil {
+		t.Fatalf("AddFixture() error = %v", err)
+	}
+
+	rm := NewReviewModel(testResourceModel("tenant1"), nil)
+	err := rm.CreateReviewRequest(context.Background(), "tenant1", "Encounter", "1", ReviewDetails{Severity: ReviewSeverityInfo})
+	if err != nil {
+		t.Fatalf("CreateReviewRequest() error = %v", err)
+	}
+
+	var doc map[string]interface{}
+	if _, err := encounters.Get("Encounter/1", &doc); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if doc["reviewed"] != true || doc["reviewSeverity"] != ReviewSeverityInfo {
+		t.Errorf("Expected an info review, got %v", doc)
+	}
+	if _, ok := doc["reviewNotes"]; ok {
+		t.Errorf("Expected the old notes to be removed, got %v", doc["reviewNotes"])
+	}
+	if history, _ := doc["reviewHistory"].([]interface{}); len(history) != 1 {
+		t.Errorf("Expected one review event, got %v", doc["reviewHistory"])
+	}
+	if len(encounters.MutateInCalls()) != 0 || len(encounters.UpsertCalls()) != 0 {
+		t.Error("Expected reviews without a CAS to be applied in a transaction")
+	}
+}
+
+func TestReviewModelCreateReviewRequestConcurrent(t *testing.T) {
+	bucket := useMockBucket(t)
+	encounters := bucket.Collection("tenant1", "encounters")
+	if err := encounters.AddFixture("Encounter/1", map[string]interface{}{"resourceType": "Encounter", "id": "1"}); err != nil {
+		t.Fatalf("AddFixture() error = %v", err)
+	}
+
+	rm := NewReviewModel(testResourceModel("tenant1"), nil)
+
+	const reviews = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, reviews)
+	for i := 0; i < reviews; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			details := ReviewDetails{ReviewerUsername: fmt.Sprintf("reviewer-%d", i)}
+			errs <- rm.CreateReviewRequest(context.Background(), "tenant1", "Encounter", "1", details)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("CreateReviewRequest() error = %v", err)
+		}
+	}
+
+	history, err := rm.GetReviewHistory(context.Background(), "Encounter", "1")
+	if err != nil {
+		t.Fatalf("GetReviewHistory() error = %v", err)
+	}
+	if len(history) != reviews {
+		t.Errorf("Expected %d review events, got %d", reviews, len(history))
+	}
+}
+
+func TestReviewModelCreateReviewRequestRetriesConflicts(t *testing.T) {
+	useMockBucket(t)
+	origDelay := reviewTransactionRetryBaseDelay
+	reviewTransactionRetryBaseDelay = time.Millisecond
+	t.Cleanup(func() {
+		reviewTransactionRetryBaseDelay = origDelay
+	})
+
+	tests := []struct {
+		name        string
+		failures    int
+		failWith    error
+		expectErr   bool
+		expectCalls int
+	}{
+		{name: "Conflicts within the retries", failures: reviewTransactionRetries, failWith: gocb.ErrWriteWriteConflict, expectCalls: reviewTransactionRetries + 1},
+		{name: "Conflicts past the retries", failures: reviewTransactionRetries + 1, failWith: gocb.ErrWriteWriteConflict, expectErr: true, expectCalls: reviewTransactionRetries + 1},
+		{name: "Other errors are not retried", failures: 1, failWith: gocb.ErrTimeout, expectErr: true, expectCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := testutil.NewMockBucket("evtechallenge")
+			encounters := bucket.Collection("tenant1", "encounters")
+			if err := encounters.AddFixture("Encounter/1", map[string]interface{}{"resourceType": "Encounter", "id": "1"}); err != nil {
+				t.Fatalf("AddFixture() error = %v", err)
+			}
+			collectionForResource = func(ctx context.Context, rm *ResourceModel, resourceType string) documentCollection {
+				return encounters
+			}
+
+			calls := 0
+			runReviewTransaction = func(ctx context.Context, rm *ReviewModel, resourceType, docID string, mutate func(map[string]interface{})) error {
+				calls++
+				if calls <= tt.failures {
+					return fmt.Errorf("transaction failed | %w", tt.failWith)
+				}
+				return mockTransaction(encounters, docID, mutate)
+			}
+
+			err := NewReviewModel(testResourceModel("tenant1"), nil).CreateReviewRequest(context.Background(), "tenant1", "Encounter", "1", ReviewDetails{})
+			if (err != nil) != tt.expectErr {
+				t.Errorf("CreateReviewRequest() error = %v, expected error %v", err, tt.expectErr)
+			}
+			if calls != tt.expectCalls {
+				t.Errorf("Expected %d transaction attempts, got %d", tt.expectCalls, calls)
+			}
+		})
+	}
+}
+
+func TestReviewModelGetReviewHistory(t *testing.T) {
+	bucket := useApplyingMockBucket(t)
+	if err := bucket.Collection("tenant1", "encounters").AddFixture("Encounter/1", map[string]interface{}{"resourceType": "Encounter", "id": "1"}); err != nil {
+		t.Fatalf("AddFixture() error = %v", err)
+	}
+
+	rm := NewReviewModel(testResourceModel("tenant1"), nil)
+
+	history, err := rm.GetReviewHistory(context.Background(), "Encounter", "1")
+	if err != nil {
+		t.Fatalf("GetReviewHistory() error = %v", err)
+	}
+	if history == nil || len(history) != 0 {
+		t.Fatalf("Expected an empty history before the first review, got %v", history)
+	}
+
+	reviewers := []string{"alice", "bob", "alice"}
+	for _, reviewer := range reviewers {
+		details := ReviewDetails{ReviewerUsername: reviewer}
+		if err := rm.CreateReviewRequest(context.Background(), "tenant1", "Encounter", "1", details); err != nil {
+			t.Fatalf("CreateReviewRequest(%s) error = %v", reviewer, err)
+		}
+	}
+
+	history, err = rm.GetReviewHistory(context.Background(), "Encounter", "1")
+	if err != nil {
+		t.Fatalf("GetReviewHistory() error = %v", err)
+	}
+	if len(history) != len(reviewers) {
+		t.Fatalf("Expected %d review events, got %d", len(reviewers), len(history))
+	}
+	for i, event := range history {
+		if event.ReviewerUsername != reviewers[i] || event.TenantID != "tenant1" {
+			t.Errorf("Event %d = %+v, expected reviewer %s of tenant1", i, event, reviewers[i])
+		}
+		if i > 0 && event.ReviewedAt.Before(history[i-1].ReviewedAt) {
+			t.Errorf("Event %d reviewed at %v before event %d at %v", i, event.ReviewedAt, i-1, history[i-1].ReviewedAt)
+		}
+	}
+}
+
+func TestReviewModelGetReviewHistorySorted(t *testing.T) {
+	bucket := useMockBucket(t)
+	if err := bucket.Collection("tenant1", "patients").AddFixture("Patient/1", map[string]interface{}{
+		"resourceType": "Patient",
+		"id":           "1",
+		"reviewHistory": []interface{}{
+			map[string]interface{}{"reviewedAt": "2025-01-02T10:00:00Z", "tenantId": "tenant1", "reviewerUsername": "bob"},
+			map[string]interface{}{"reviewedAt": "2025-01-01T10:00:00Z", "tenantId": "tenant1", "reviewerUsername": "alice"},
+		},
+	}); err != nil {
+		t.Fatalf("AddFixture() error = %v", err)
+	}
+
+	history, err := NewReviewModel(testResourceModel("tenant1"), nil).GetReviewHistory(context.Background(), "Patient", "1")
+	if err != nil {
+		t.Fatalf("GetReviewHistory() error = %v", err)
+	}
+	if len(history) != 2 || history[0].ReviewerUsername != "alice" || history[1].ReviewerUsername != "bob" {
+		t.Errorf("Expected the history sorted by review time, got %+v", history)
+	}
+
+	if _, err := NewReviewModel(testResourceModel("tenant1"), nil).GetReviewHistory(context.Background(), "Patient", "missing"); err == nil {
+		t.Error("Expected error for a missing resource, got nil")
+	}
+}
+
+func TestReviewModelCreateReviewRequestNotFound(t *testing.T) {
+	bucket := useMockBucket(t)
+
+	rm := NewReviewModel(testResourceModel("tenant1"), nil)
+	err := rm.CreateReviewRequest(context.Background(), "tenant1", "Patient", "missing", ReviewDetails{})
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	if calls := bucket.Collection("tenant1", "patients").MutateInCalls(); len(calls) != 0 {
+		t.Errorf("Expected no mutation, got %+v", calls)
+	}
+}
+
+func TestReviewModelCreateReviewRequestConcurrentWithCas(t *testing.T) {
+	bucket := useMockBucket(t)
+	encounters := bucket.Collection("tenant1", "encounters")
+	if err := encounters.AddFixture("Encounter/1", map[string]interface{}{"resourceType": "Encounter", "id": "1"}); err != nil {
+		t.Fatalf("AddFixture() error = %v", err)
+	}
+
+	resourceModel := testResourceModel("tenant1")
+	rm := NewReviewModel(resourceModel, nil)
+
+	// Both reviewers read the resource before either submits a review
+	resource, err := resourceModel.GetCASedResource(context.Background(), "Encounter/1")
+	if err != nil {
+		t.Fatalf("GetCASedResource() error = %v", err)
+	}
+	cas := resource.CAS
+
+	first := rm.CreateReviewRequest(context.Background(), "tenant1", "Encounter", "1", ReviewDetails{Notes: "first", Cas: cas})
+	if first != nil {
+		t.Fatalf("First review error = %v", first)
+	}
+
+	second := rm.CreateReviewRequest(context.Background(), "tenant1", "Encounter", "1", ReviewDetails{Notes: "second", Cas: cas})
+	if !errors.Is(second, ErrReviewConflict) {
+		t.Fatalf("Expected ErrReviewConflict for the second review, got %v", second)
+	}
+
+	calls := encounters.MutateInCalls()
+	if len(calls) != 1 {
+		t.Fatalf("Expected one applied review, got %d", len(calls))
+	}
+	if calls[0].Options.Cas != cas || !calls[0].Options.PreserveExpiry {
+		t.Errorf("Expected CAS-checked mutation preserving expiry, got %+v", calls[0].Options)
+	}
+	if len(encounters.UpsertCalls()) != 0 {
+		t.Error("Expected reviews with a CAS not to replace the whole document")
+	}
+}
@@ -0,0 +1,140 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrProtectedScope is returned when deleting a scope that does not belong to a tenant
+var ErrProtectedScope = errors.New("scope is not a tenant scope")
+
+// tenantScopeCleaner is the part of the cluster and bucket used to delete a tenant scope
+type tenantScopeCleaner interface {
+	// scopeCollections returns the collections of the scope, and false when the scope does not exist
+	scopeCollections(ctx context.Context, scopeName string) ([]string, bool, error)
+	// query runs a N1QL statement
+	query(ctx context.Context, statement string) error
+	// removeIngestionStatus removes the tenant ingestion status document, succeeding when it is already gone
+	removeIngestionStatus(ctx context.Context, scopeName string) error
+	// markTenantDeleted records the tenant tombstone checked by EnsureTenantScope
+	markTenantDeleted(ctx context.Context, scopeName string) error
+}
+
+// DeleteTenantScope removes a tenant scope and its data for tenant offboarding:
+// 0. Record the tenant tombstone, so the scope is not created again while or after it is dropped
+// 1. Check which of the scope and its collections still exist
+// 2. Remove the ingestion status document, so the tenant stops being served as ready
+// 3. Delete the documents of each collection with DELETE FROM
+// 4. Drop each collection
+// 5. Drop the scope
+// Only the artefacts still present are removed, so re-running it after a partial failure finishes the cleanup.
+func (sm *ScopeModel) DeleteTenantScope(ctx context.Context, tenantScope string) error {
+	return deleteTenantScope(ctx, couchbaseScopeCleaner{conn: sm.conn}, sm.conn.GetBucketName(), tenantScope)
+}
+
+// deleteTenantScope runs the steps of DeleteTenantScope through cleaner
+func deleteTenantScope(ctx context.Context, cleaner tenantScopeCleaner, bucketName, tenantScope string) error {
+	// The default and system scopes hold the shared data, never delete them
+	if tenantScope == "" || strings.HasPrefix(tenantScope, "_") {
+		return fmt.Errorf("%w: %q", ErrProtectedScope, tenantScope)
+	}
+
+	// Step 0: Record the tombstone first, a request arriving mid-deletion must not re-create the scope
+	if err := cleaner.markTenantDeleted(ctx, tenantScope); err != nil {
+		return fmt.Errorf("failed to record tenant deletion: %w", err)
+	}
+
+	// Step 1: Check what is left of the scope
+	collections, exists, err := cleaner.scopeCollections(ctx, tenantScope)
+	if err != nil {
+		return fmt.Errorf("failed to check if scope exists: %w", err)
+	}
+	if !exists {
+		log.Ctx(ctx).Info().Str("tenant", tenantScope).Msg("Tenant scope does not exist, nothing to delete")
+		return nil
+	}
+	log.Ctx(ctx).Info().Str("tenant", tenantScope).Strs("collections", collections).Msg("Deleting tenant scope")
+
+	// Step 2: Remove the ingestion status document
+	if slices.Contains(collections, "defaulty") {
+		if err := cleaner.removeIngestionStatus(ctx, tenantScope); err != nil {
+			return fmt.Errorf("failed to remove ingestion status: %w", err)
+		}
+		log.Ctx(ctx).Info().Str("tenant", tenantScope).Msg("Tenant ingestion status removed")
+	}
+
+	// Step 3: Delete the documents in the query service, without reading them first
+	for _, collectionName := range collections {
+		deleteQuery := fmt.Sprintf("DELETE FROM `%s`.`%s`.`%s`", bucketName, tenantScope, collectionName)
+		if err := cleaner.query(ctx, deleteQuery); err != nil {
+			// Dropping the collection removes its documents anyway, e.g. when no index can serve the DELETE
+			log.Ctx(ctx).Warn().Err(err).Str("tenant", tenantScope).Str("collection", collectionName).Msg("Failed to delete collection documents, dropping the collection")
+			continue
+		}
+		log.Ctx(ctx).Info().Str("tenant", tenantScope).Str("collection", collectionName).Msg("Collection documents deleted")
+	}
+
+	// Step 4: Drop the collections
+	for _, collectionName := range collections {
+		dropCollectionQuery := fmt.Sprintf("DROP COLLECTION `%s`.`%s`.`%s`", bucketName, tenantScope, collectionName)
+		if err := cleaner.query(ctx, dropCollectionQuery); err != nil && !errors.Is(err, gocb.ErrCollectionNotFound) {
+			return fmt.Errorf("failed to drop collection %s in scope %s: %w", collectionName, tenantScope, err)
+		}
+		log.Ctx(ctx).Info().Str("tenant", tenantScope).Str("collection", collectionName).Msg("Collection dropped")
+	}
+
+	// Step 5: Drop the scope
+	dropScopeQuery := fmt.Sprintf("DROP SCOPE `%s`.`%s`", bucketName, tenantScope)
+	if err := cleaner.query(ctx, dropScopeQuery); err != nil && !errors.Is(err, gocb.ErrScopeNotFound) {
+		return fmt.Errorf("failed to drop scope %s: %w", tenantScope, err)
+	}
+	InvalidateTenantPatientSummaries(tenantScope)
+	InvalidateReviewSummary(tenantScope)
+
+	log.Ctx(ctx).Info().Str("tenant", tenantScope).Msg("Tenant scope deleted")
+	return nil
+}
+
+// couchbaseScopeCleaner deletes tenant scopes through a Couchbase connection
+type couchbaseScopeCleaner struct {
+	conn *Connection
+}
+
+func (c couchbaseScopeCleaner) scopeCollections(ctx context.Context, scopeName string) ([]string, bool, error) {
+	scopes, err := c.conn.GetBucket().CollectionsV2().GetAllScopes(&gocb.GetAllScopesOptions{Context: ctx})
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, scope := range scopes {
+		if scope.Name != scopeName {
+			continue
+		}
+		collections := make([]string, 0, len(scope.Collections))
+		for _, collection := range scope.Collections {
+			collections = append(collections, collection.Name)
+		}
+		return collections, true, nil
+	}
+	return nil, false, nil
+}
+
+func (c couchbaseScopeCleaner) query(ctx context.Context, statement string) error {
+	_, err := c.conn.GetCluster().Query(statement, &gocb.QueryOptions{Context: ctx})
+	return err
+}
+
+func (c couchbaseScopeCleaner) removeIngestionStatus(ctx context.Context, scopeName string) error {
+	collection := c.conn.GetBucket().Scope(scopeName).Collection("defaulty")
+	_, err := collection.Remove(TenantIngestionStatusKey, &gocb.RemoveOptions{Context: ctx})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeScopeCleaner keeps the collections of one scope and applies the DROP statements it runs
+type fakeScopeCleaner struct {
+	exists         bool
+	collections    []string
+	statusRemoved  bool
+	tombstoned     bool
+	statements     []string
+	failDropOf     string
+	failDropRemain int
+}
+
+func (f *fakeScopeCleaner) scopeCollections(ctx context.Context, scopeName string) ([]string, bool, error) {
+	return slices.Clone(f.collections), f.exists, nil
+}
+
+func (f *fakeScopeCleaner) query(ctx context.Context, statement string) error {
+	f.statements = append(f.statements, statement)
+	switch {
+	case strings.HasPrefix(statement, "DROP COLLECTION"):
+		name := strings.Trim(statement[strings.LastIndex(statement, ".")+1:], "`")
+		if name == f.failDropOf && f.failDropRemain > 0 {
+			f.failDropRemain--
+			return errors.New("query service unavailable")
+		}
+		f.collections = slices.DeleteFunc(f.collections, func(c string) bool { return c == name })
+	case strings.HasPrefix(statement, "DROP SCOPE"):
+		f.exists = false
+	}
+	return nil
+}
+
+func (f *fakeScopeCleaner) removeIngestionStatus(ctx context.Context, scopeName string) error {
+	f.statusRemoved = true
+	return nil
+}
+
+func (f *fakeScopeCleaner) markTenantDeleted(ctx context.Context, scopeName string) error {
+	f.tombstoned = true
+	return nil
+}
+
+func TestDeleteTenantScope(t *testing.T) {
+	cleaner := &fakeScopeCleaner{exists: true, collections: []string{"defaulty", "encounters"}}
+
+	if err := deleteTenantScope(context.Background(), cleaner, "bucket", "tenant1"); err != nil {
+		t.Fatalf("deleteTenantScope() error = %v", err)
+	}
+
+	expected := []string{
+		"DELETE FROM `bucket`.`tenant1`.`defaulty`",
+		"DELETE FROM `bucket`.`tenant1`.`encounters`",
+		"DROP COLLECTION `bucket`.`tenant1`.`defaulty`",
+		"DROP COLLECTION `bucket`.`tenant1`.`encounters`",
+		"DROP SCOPE `bucket`.`tenant1`",
+	}
+	if !slices.Equal(cleaner.statements, expected) {
+		t.Errorf("Expected statements %v, got %v", expected, cleaner.statements)
+	}
+	if !cleaner.statusRemoved {
+		t.Errorf("Expected the ingestion status to be removed")
+	}
+	if !cleaner.tombstoned {
+		t.Errorf("Expected the tenant tombstone to be recorded")
+	}
+}
+
+func TestDeleteTenantScopeResumesAfterPartialFailure(t *testing.T) {
+	cleaner := &fakeScopeCleaner{
+		exists:         true,
+		collections:    []string{"defaulty", "encounters", "patients"},
+		failDropOf:     "patients",
+		failDropRemain: 1,
+	}
+
+	if err := deleteTenantScope(context.Background(), cleaner, "bucket", "tenant1"); err == nil {
+		t.Fatal("Expected the failed DROP COLLECTION to be returned")
+	}
+	if !cleaner.exists || !slices.Equal(cleaner.collections, []string{"patients"}) {
+		t.Fatalf("Expected only the patients collection to be left, got exists %v collections %v", cleaner.exists, cleaner.collections)
+	}
+
+	cleaner.statements = nil
+	if err := deleteTenantScope(context.Background(), cleaner, "bucket", "tenant1"); err != nil {
+		t.Fatalf("deleteTenantScope() retry error = %v", err)
+	}
+	expected := []string{
+		"DELETE FROM `bucket`.`tenant1`.`patients`",
+		"DROP COLLECTION `bucket`.`tenant1`.`patients`",
+		"DROP SCOPE `bucket`.`tenant1`",
+	}
+	if !slices.Equal(cleaner.statements, expected) {
+		t.Errorf("Expected the retry to clean up the remaining artefacts %v, got %v", expected, cleaner.statements)
+	}
+}
+
+func TestDeleteTenantScopeMissingScope(t *testing.T) {
+	cleaner := &fakeScopeCleaner{}
+
+	if err := deleteTenantScope(context.Background(), cleaner, "bucket", "tenant1"); err != nil {
+		t.Fatalf("deleteTenantScope() error = %v", err)
+	}
+	if len(cleaner.statements) != 0 || cleaner.statusRemoved {
+		t.Errorf("Expected nothing to be deleted, got statements %v", cleaner.statements)
+	}
+	// The tenant is still offboarded, so it is not created again by its next request
+	if !cleaner.tombstoned {
+		t.Errorf("Expected the tenant tombstone to be recorded")
+	}
+}
+
+func TestDeleteTenantScopeRefusesSharedScopes(t *testing.T) {
+	for _, scope := range []string{"", "_default", "_system"} {
+		cleaner := &fakeScopeCleaner{exists: true, collections: []string{"encounters"}}
+		if err := deleteTenantScope(context.Background(), cleaner, "bucket", scope); !errors.Is(err, ErrProtectedScope) {
+			t.Errorf("Expected ErrProtectedScope for %q, got %v", scope, err)
+		}
+		if len(cleaner.statements) != 0 || cleaner.tombstoned {
+			t.Errorf("Expected no statements nor tombstone for %q, got %v", scope, cleaner.statements)
+		}
+	}
+}
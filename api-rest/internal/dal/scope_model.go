@@ -2,12 +2,16 @@ package dal
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/couchbase/gocb/v2"
 	"github.com/rs/zerolog/log"
+	"stealthcompany.com/api-rest/internal/metrics"
 )
 
 // ScopeModel represents the database model for scope management
@@ -30,8 +34,9 @@ func NewScopeModel(conn *Connection) *ScopeModel {
 // 4. Copy all data from DefaultScope collections to tenant scope collections
 // 5. Set ingestion status to true when complete
 // 6. Wait for ingestion status if it's false (with 5-minute timeout)
+// A tenant of FHIR_TENANT_CONFIG_FILE only gets its scope and collections, fhir-client ingests it and sets its status.
 func (sm *ScopeModel) EnsureTenantScope(ctx context.Context, tenantScope string) error {
-	log.Info().Str("tenant", tenantScope).Msg("Ensuring tenant scope exists")
+	log.Ctx(ctx).Info().Str("tenant", tenantScope).Msg("Ensuring tenant scope exists")
 
 	// Step 1: Check if scope exists
 	scopeExists, err := sm.scopeExists(ctx, tenantScope)
@@ -39,36 +44,24 @@ func (sm *ScopeModel) EnsureTenantScope(ctx context.Context, tenantScope string)
 		return fmt.Errorf("failed to check if scope exists: %w", err)
 	}
 
+	ism := NewIngestionStatusModel(sm.conn)
 	if !scopeExists {
-		log.Info().Str("tenant", tenantScope).Msg("Scope does not exist, creating and copying data")
-
-		// Step 2: Create scope and collections
-		if err := sm.createScopeAndCollections(ctx, tenantScope); err != nil {
-			return fmt.Errorf("failed to create scope and collections: %w", err)
-		}
-
-		// Step 3: Set ingestion status to false and start copying
-		ism := NewIngestionStatusModel(sm.conn)
-		if err := ism.MarkTenantScopeIngestionStarted(ctx, tenantScope); err != nil {
-			return fmt.Errorf("failed to mark ingestion as started: %w", err)
+		// A deleted tenant is not created again until it is restored
+		deleted, err := sm.IsTenantDeleted(ctx, tenantScope)
+		if err != nil {
+			return fmt.Errorf("failed to check if tenant was deleted: %w", err)
 		}
-
-		// Step 4: Copy data from DefaultScope to tenant scope
-		if err := sm.copyDataFromDefaultScope(ctx, tenantScope); err != nil {
-			return fmt.Errorf("failed to copy data from default scope: %w", err)
+		if deleted {
+			return fmt.Errorf("%w: %s", ErrTenantDeleted, tenantScope)
 		}
-
-		// Step 5: Mark ingestion as completed
-		if err := ism.MarkTenantScopeIngestionCompleted(ctx, tenantScope, "Data copied from DefaultScope"); err != nil {
-			return fmt.Errorf("failed to mark ingestion as completed: %w", err)
+		if err := initTenantScope(ctx, tenantScope, sm, ism); err != nil {
+			return err
 		}
-
-		log.Info().Str("tenant", tenantScope).Msg("Tenant scope created and data copied successfully")
+	} else {
+		log.Ctx(ctx).Debug().Str("tenant", tenantScope).Msg("Scope already exists")
 	}
-	log.Debug().Str("tenant", tenantScope).Msg("Scope already exists")
 
 	// Step 6: Wait for ingestion status if it's false (with 5-minute timeout)
-	ism := NewIngestionStatusModel(sm.conn)
 	ready, err := sm.waitForIngestionReady(ctx, tenantScope, ism)
 	if err != nil {
 		return fmt.Errorf("failed to wait for ingestion ready: %w", err)
@@ -78,7 +71,76 @@ func (sm *ScopeModel) EnsureTenantScope(ctx context.Context, tenantScope string)
 		return fmt.Errorf("tenant scope %s ingestion not ready after timeout", tenantScope)
 	}
 
-	log.Info().Str("tenant", tenantScope).Msg("Tenant scope is ready for use")
+	log.Ctx(ctx).Info().Str("tenant", tenantScope).Msg("Tenant scope is ready for use")
+	return nil
+}
+
+// tenantScopeBuilder creates the collections of a tenant scope and copies DefaultScope into it
+type tenantScopeBuilder interface {
+	checkCopySize(ctx context.Context) error
+	createScopeAndCollections(ctx context.Context, scopeName string) error
+	copyDataFromDefaultScope(ctx context.Context, tenantScope string) error
+}
+
+// tenantIngestionStatusMarker records the ingestion status of a tenant scope
+type tenantIngestionStatusMarker interface {
+	MarkTenantScopeIngestionStarted(ctx context.Context, tenantScope string) error
+	MarkTenantScopeCollectionInitFailed(ctx context.Context, tenantScope string, cause error) error
+	MarkTenantScopeIngestionCompleted(ctx context.Context, tenantScope string, message string) error
+}
+
+// initTenantScope creates a missing tenant scope and copies DefaultScope into it (steps 2 to 5 of EnsureTenantScope).
+// A tenant of FHIR_TENANT_CONFIG_FILE gets no copy and no status: fhir-client owns its status,
+// so marking it ready here would make fhir-client skip the tenant as already ingested.
+func initTenantScope(ctx context.Context, tenantScope string, builder tenantScopeBuilder, ism tenantIngestionStatusMarker) error {
+	fhirTenant, err := fhirTenantConfigured(tenantScope)
+	if err != nil {
+		return err
+	}
+
+	if fhirTenant {
+		log.Ctx(ctx).Info().Str("tenant", tenantScope).Msg("Scope does not exist, creating it for fhir-client ingestion")
+	} else {
+		log.Ctx(ctx).Info().Str("tenant", tenantScope).Msg("Scope does not exist, creating and copying data")
+
+		// Refuse oversized copies before creating anything, so a later call can retry from scratch
+		if err := builder.checkCopySize(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Step 2: Create scope and collections
+	if err := builder.createScopeAndCollections(ctx, tenantScope); err != nil {
+		// The scope may exist already, so record the failure for later calls instead of leaving it to look ready
+		if markErr := ism.MarkTenantScopeCollectionInitFailed(ctx, tenantScope, err); markErr != nil {
+			log.Ctx(ctx).Warn().Err(markErr).Str("tenant", tenantScope).Msg("Failed to record collection initialization failure")
+		}
+		return fmt.Errorf("failed to create scope and collections: %w", err)
+	}
+
+	if fhirTenant {
+		log.Ctx(ctx).Info().Str("tenant", tenantScope).Msg("Tenant scope created, waiting for fhir-client to ingest it")
+		return nil
+	}
+
+	// Step 3: Set ingestion status to false and start copying
+	if err := ism.MarkTenantScopeIngestionStarted(ctx, tenantScope); err != nil {
+		return fmt.Errorf("failed to mark ingestion as started: %w", err)
+	}
+
+	// Step 4: Copy data from DefaultScope to tenant scope
+	if err := builder.copyDataFromDefaultScope(ctx, tenantScope); err != nil {
+		return fmt.Errorf("failed to copy data from default scope: %w", err)
+	}
+	InvalidateTenantPatientSummaries(tenantScope)
+	InvalidateReviewSummary(tenantScope)
+
+	// Step 5: Mark ingestion as completed
+	if err := ism.MarkTenantScopeIngestionCompleted(ctx, tenantScope, "Data copied from DefaultScope"); err != nil {
+		return fmt.Errorf("failed to mark ingestion as completed: %w", err)
+	}
+
+	log.Ctx(ctx).Info().Str("tenant", tenantScope).Msg("Tenant scope created and data copied successfully")
 	return nil
 }
 
@@ -115,40 +177,47 @@ func (sm *ScopeModel) createScopeAndCollections(ctx context.Context, scopeName s
 		if !sm.isScopeExistsError(err) {
 			return fmt.Errorf("failed to create scope %s: %w", scopeName, err)
 		}
-		log.Debug().Str("scope", scopeName).Msg("Scope already exists")
+		log.Ctx(ctx).Debug().Str("scope", scopeName).Msg("Scope already exists")
 	}
 
 	// Create collections using full bucket.scope.collection syntax
-	collections := []string{"defaulty", "encounters", "patients", "practitioners"}
+	// With TENANT_DATA_TTL_DAYS the resource collections are created through the collections manager to set their max TTL
+	tenantDataTTL := TenantDataTTL()
+	collections := []string{"defaulty", "encounters", "patients", "practitioners", "observations", "conditions", "medication_requests"}
 	for _, collectionName := range collections {
 		createCollectionQuery := fmt.Sprintf("CREATE COLLECTION `%s`.`%s`.`%s`", bucketName, scopeName, collectionName)
-		_, err := sm.conn.GetCluster().Query(createCollectionQuery, &gocb.QueryOptions{Context: ctx})
+		var err error
+		if tenantDataTTL > 0 && collectionName != "defaulty" {
+			err = createCollectionWithTTL(sm.conn.GetBucket().CollectionsV2(), scopeName, collectionName, tenantDataTTL)
+		} else {
+			_, err = sm.conn.GetCluster().Query(createCollectionQuery, &gocb.QueryOptions{Context: ctx})
+		}
 		if err != nil {
 			// Log the actual error to see what's happening
-			log.Warn().Err(err).Str("scope", scopeName).Str("collection", collectionName).Str("query", createCollectionQuery).Msg("Collection creation error")
+			log.Ctx(ctx).Warn().Err(err).Str("scope", scopeName).Str("collection", collectionName).Str("query", createCollectionQuery).Msg("Collection creation error")
 
 			// If collection already exists, that's okay
 			if !sm.isCollectionExistsError(err) {
 				return fmt.Errorf("failed to create collection %s in scope %s: %w", collectionName, scopeName, err)
 			}
-			log.Debug().Str("scope", scopeName).Str("collection", collectionName).Msg("Collection already exists")
+			log.Ctx(ctx).Debug().Str("scope", scopeName).Str("collection", collectionName).Msg("Collection already exists")
 		} else {
-			log.Info().Str("scope", scopeName).Str("collection", collectionName).Msg("Collection created successfully")
+			log.Ctx(ctx).Info().Str("scope", scopeName).Str("collection", collectionName).Msg("Collection created successfully")
 		}
 	}
 
 	// Create collection-specific indexes
 	if err := sm.createCollectionIndexes(ctx, bucketName, scopeName); err != nil {
-		log.Warn().Err(err).Str("scope", scopeName).Msg("Failed to create collection indexes, continuing")
+		log.Ctx(ctx).Warn().Err(err).Str("scope", scopeName).Msg("Failed to create collection indexes, continuing")
 	}
 
-	log.Info().Str("scope", scopeName).Msg("Scope and collections created successfully")
+	log.Ctx(ctx).Info().Str("scope", scopeName).Msg("Scope and collections created successfully")
 	return nil
 }
 
 // createCollectionIndexes creates collection-specific indexes for the tenant scope
 func (sm *ScopeModel) createCollectionIndexes(ctx context.Context, bucketName, scopeName string) error {
-	log.Info().Str("scope", scopeName).Msg("Creating collection-specific indexes")
+	log.Ctx(ctx).Info().Str("scope", scopeName).Msg("Creating collection-specific indexes")
 
 	// Define indexes for each collection
 	indexes := []struct {
@@ -161,12 +230,30 @@ func (sm *ScopeModel) createCollectionIndexes(ctx context.Context, bucketName, s
 		{"encounters", "idx_encounters_id", "id"},
 		{"encounters", "idx_encounters_resourceType", "resourceType"},
 		{"encounters", "idx_encounters_reviewed", "reviewed"},
+		{"encounters", "idx_encounters_status", "status"},
+		{"encounters", "idx_encounters_period_start", "period.`start`"},
+		{"encounters", "idx_encounters_status_date", "status, period.`start`"},
+		{"encounters", "idx_encounters_subjectPatientId", "subjectPatientId"},
 		{"patients", "idx_patients_id", "id"},
 		{"patients", "idx_patients_resourceType", "resourceType"},
 		{"patients", "idx_patients_reviewed", "reviewed"},
 		{"practitioners", "idx_practitioners_id", "id"},
 		{"practitioners", "idx_practitioners_resourceType", "resourceType"},
 		{"practitioners", "idx_practitioners_reviewed", "reviewed"},
+		{"observations", "idx_observations_id", "id"},
+		{"observations", "idx_observations_resourceType", "resourceType"},
+		{"observations", "idx_observations_subjectPatientId", "subjectPatientId"},
+		{"observations", "idx_observations_encounterId", "encounterId"},
+		{"observations", "idx_observations_effectiveDateTime", "effectiveDateTime"},
+		{"conditions", "idx_conditions_id", "id"},
+		{"conditions", "idx_conditions_resourceType", "resourceType"},
+		{"conditions", "idx_conditions_subjectPatientId", "subjectPatientId"},
+		{"conditions", "idx_conditions_encounterId", "encounterId"},
+		{"medication_requests", "idx_medication_requests_id", "id"},
+		{"medication_requests", "idx_medication_requests_resourceType", "resourceType"},
+		{"medication_requests", "idx_medication_requests_subjectPatientId", "subjectPatientId"},
+		{"medication_requests", "idx_medication_requests_encounterId", "encounterId"},
+		{"medication_requests", "idx_medication_requests_status", "status"},
 	}
 
 	for _, idx := range indexes {
@@ -175,14 +262,14 @@ func (sm *ScopeModel) createCollectionIndexes(ctx context.Context, bucketName, s
 
 		_, err := sm.conn.GetCluster().Query(createIndexQuery, &gocb.QueryOptions{Context: ctx})
 		if err != nil {
-			log.Warn().
+			log.Ctx(ctx).Warn().
 				Err(err).
 				Str("scope", scopeName).
 				Str("collection", idx.collection).
 				Str("index", idx.indexName).
 				Msg("Failed to create index (may already exist)")
 		} else {
-			log.Debug().
+			log.Ctx(ctx).Debug().
 				Str("scope", scopeName).
 				Str("collection", idx.collection).
 				Str("index", idx.indexName).
@@ -190,66 +277,252 @@ func (sm *ScopeModel) createCollectionIndexes(ctx context.Context, bucketName, s
 		}
 	}
 
-	log.Info().Str("scope", scopeName).Msg("Collection indexes creation completed")
+	log.Ctx(ctx).Info().Str("scope", scopeName).Msg("Collection indexes creation completed")
+	return nil
+}
+
+// Chunking used while copying DefaultScope data into a tenant scope
+var (
+	scopeCopyChunkSize           = 1000
+	scopeCopyProgressLogInterval = 5000
+)
+
+// defaultMaxSafeCopySize is the DefaultScope encounter count above which copies need ALLOW_LARGE_COPY=true
+const defaultMaxSafeCopySize = 10000
+
+// ErrLargeCopyNotAllowed is returned when DefaultScope is too large to copy without ALLOW_LARGE_COPY=true
+var ErrLargeCopyNotAllowed = errors.New("default scope too large to copy")
+
+// scopeCopyQueryTimeout returns the per-chunk copy query timeout from COUCHBASE_SCOPE_COPY_QUERY_TIMEOUT_SECONDS,
+// or 0 to keep the cluster default
+func scopeCopyQueryTimeout() time.Duration {
+	if value := os.Getenv("COUCHBASE_SCOPE_COPY_QUERY_TIMEOUT_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
+
+// maxSafeCopySize returns the largest encounter count copied without ALLOW_LARGE_COPY,
+// configurable via MAX_SAFE_COPY_SIZE (default 10000)
+func maxSafeCopySize() int {
+	if value := os.Getenv("MAX_SAFE_COPY_SIZE"); value != "" {
+		if size, err := strconv.Atoi(value); err == nil && size > 0 {
+			return size
+		}
+	}
+	return defaultMaxSafeCopySize
+}
+
+// validateCopySize fails when encounterCount exceeds maxSize and large copies are not allowed
+func validateCopySize(encounterCount, maxSize int, allowLarge bool) error {
+	if encounterCount > maxSize && !allowLarge {
+		return fmt.Errorf("%w: %d encounters exceed MAX_SAFE_COPY_SIZE %d, set ALLOW_LARGE_COPY=true to proceed",
+			ErrLargeCopyNotAllowed, encounterCount, maxSize)
+	}
 	return nil
 }
 
-// copyDataFromDefaultScope copies all data from DefaultScope collections to tenant scope collections
+// checkCopySize counts DefaultScope encounters and applies validateCopySize
+func (sm *ScopeModel) checkCopySize(ctx context.Context) error {
+	count, err := sm.countDocuments(ctx, sm.conn.GetBucketName(), "_default", "encounters")
+	if err != nil {
+		return fmt.Errorf("failed to count default scope encounters: %w", err)
+	}
+
+	maxSize := maxSafeCopySize()
+	allowLarge, _ := strconv.ParseBool(os.Getenv("ALLOW_LARGE_COPY"))
+	if err := validateCopySize(count, maxSize, allowLarge); err != nil {
+		return err
+	}
+	if count > maxSize {
+		log.Ctx(ctx).Warn().Int("encounters", count).Msg("Copying a large default scope, allowed by ALLOW_LARGE_COPY")
+	}
+	return nil
+}
+
+// copyDataFromDefaultScope copies all data from DefaultScope collections to tenant scope collections,
+// in chunks so large collections don't exceed the query service memory limits
 func (sm *ScopeModel) copyDataFromDefaultScope(ctx context.Context, tenantScope string) error {
 	bucketName := sm.conn.GetBucketName()
-	collections := []string{"encounters", "patients", "practitioners"}
+	collections := []string{"encounters", "patients", "practitioners", "observations", "conditions", "medication_requests"}
+	copyTimeout := scopeCopyQueryTimeout()
 
 	for _, collectionName := range collections {
-		log.Info().Str("scope", tenantScope).Str("collection", collectionName).Msg("Copying data from DefaultScope")
+		log.Ctx(ctx).Info().Str("scope", tenantScope).Str("collection", collectionName).Msg("Copying data from DefaultScope")
 
-		// Copy all documents from DefaultScope collection to tenant scope collection
-		copyQuery := fmt.Sprintf("INSERT INTO `%s`.`%s`.`%s` (KEY k, VALUE v) SELECT META(d).id as k, d as v FROM `%s`.`_default`.`%s` AS d",
+		total, err := sm.countDocuments(ctx, bucketName, "_default", collectionName)
+		if err != nil {
+			return fmt.Errorf("failed to count documents for collection %s: %w", collectionName, err)
+		}
+
+		// Copy one chunk of documents from DefaultScope collection to tenant scope collection
+		copyQuery := fmt.Sprintf("INSERT INTO `%s`.`%s`.`%s` (KEY k, VALUE v) SELECT META(d).id as k, d as v FROM `%s`.`_default`.`%s` AS d ORDER BY META(d).id LIMIT $limit OFFSET $offset",
 			bucketName, tenantScope, collectionName,
 			bucketName, collectionName)
 
-		_, err := sm.conn.GetCluster().Query(copyQuery, &gocb.QueryOptions{Context: ctx})
-		if err != nil {
+		copyChunk := func(offset, limit int) error {
+			params := map[string]interface{}{"limit": limit, "offset": offset}
+			_, err := sm.conn.GetCluster().Query(copyQuery, &gocb.QueryOptions{Context: ctx, NamedParameters: params, Timeout: copyTimeout})
+			return err
+		}
+		onProgress := func(copied int) {
+			metrics.SetTenantScopeCopyProgress(tenantScope, collectionName, copyProgressPercent(copied, total))
+			if copied%scopeCopyProgressLogInterval == 0 || copied == total {
+				log.Ctx(ctx).Info().
+					Str("scope", tenantScope).
+					Str("collection", collectionName).
+					Int("copied", copied).
+					Int("total", total).
+					Msg("Tenant scope copy progress")
+			}
+		}
+
+		if err := copyInChunks(ctx, total, scopeCopyChunkSize, copyChunk, onProgress); err != nil {
 			return fmt.Errorf("failed to copy data for collection %s: %w", collectionName, err)
 		}
 
-		log.Info().Str("scope", tenantScope).Str("collection", collectionName).Msg("Data copied successfully")
+		log.Ctx(ctx).Info().Str("scope", tenantScope).Str("collection", collectionName).Int("documents", total).Msg("Data copied successfully")
+	}
+
+	return nil
+}
+
+// countDocuments counts the documents of a collection
+func (sm *ScopeModel) countDocuments(ctx context.Context, bucketName, scopeName, collectionName string) (int, error) {
+	query := fmt.Sprintf("SELECT RAW COUNT(*) FROM `%s`.`%s`.`%s`", bucketName, scopeName, collectionName)
+	result, err := sm.conn.GetCluster().Query(query, &gocb.QueryOptions{Context: ctx})
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	if err := result.One(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// copyInChunks calls copyChunk for consecutive chunks until total documents are copied
+func copyInChunks(ctx context.Context, total, chunkSize int, copyChunk func(offset, limit int) error, onProgress func(copied int)) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+
+	for offset := 0; offset < total; offset += chunkSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := copyChunk(offset, chunkSize); err != nil {
+			return fmt.Errorf("chunk at offset %d: %w", offset, err)
+		}
+
+		onProgress(min(offset+chunkSize, total))
 	}
 
 	return nil
 }
 
+// copyProgressPercent returns the copied share of total as a percentage
+func copyProgressPercent(copied, total int) float64 {
+	if total <= 0 {
+		return 100
+	}
+	return float64(copied) * 100 / float64(total)
+}
+
+// Polling intervals used while waiting for tenant scope ingestion
+var (
+	ingestionWaitTimeout         = 5 * time.Minute
+	ingestionPollInterval        = 1 * time.Second
+	ingestionProgressLogInterval = 30 * time.Second
+)
+
+// tenantIngestionStatusGetter reads the ingestion status of a tenant scope
+type tenantIngestionStatusGetter interface {
+	GetTenantScopeIngestionStatus(ctx context.Context, tenantScope string) (*IngestionStatus, error)
+}
+
 // waitForIngestionReady waits for ingestion to be ready with a 5-minute timeout
-func (sm *ScopeModel) waitForIngestionReady(ctx context.Context, tenantScope string, ism *IngestionStatusModel) (bool, error) {
-	timeout := 5 * time.Minute
-	ticker := time.NewTicker(1 * time.Second)
+func (sm *ScopeModel) waitForIngestionReady(ctx context.Context, tenantScope string, ism tenantIngestionStatusGetter) (bool, error) {
+	start := time.Now()
+	ticker := time.NewTicker(ingestionPollInterval)
 	defer ticker.Stop()
 
-	timeoutTimer := time.NewTimer(timeout)
+	progressTicker := time.NewTicker(ingestionProgressLogInterval)
+	defer progressTicker.Stop()
+
+	timeoutTimer := time.NewTimer(ingestionWaitTimeout)
 	defer timeoutTimer.Stop()
 
+	lastMessage := ""
 	for {
 		select {
 		case <-timeoutTimer.C:
+			elapsed := time.Since(start)
+			metrics.RecordTenantScopeCopyWait(elapsed)
+			log.Ctx(ctx).Warn().
+				Str("tenant", tenantScope).
+				Dur("elapsed", elapsed).
+				Str("last_status_message", lastMessage).
+				Msg("Timeout waiting for tenant scope ingestion")
 			return false, fmt.Errorf("timeout waiting for ingestion to be ready")
+		case <-progressTicker.C:
+			log.Ctx(ctx).Info().
+				Str("tenant", tenantScope).
+				Msgf("Still waiting for tenant scope ingestion, elapsed: %s", time.Since(start).Round(time.Second))
 		case <-ticker.C:
-			ready, err := ism.IsTenantScopeIngestionReady(ctx, tenantScope)
+			status, err := ism.GetTenantScopeIngestionStatus(ctx, tenantScope)
 			if err != nil {
 				return false, fmt.Errorf("failed to check ingestion status: %w", err)
 			}
-			if ready {
+			lastMessage = status.Message
+			if !status.Ready && strings.HasPrefix(status.Message, collectionInitFailedMessage) {
+				log.Ctx(ctx).Error().
+					Str("tenant", tenantScope).
+					Str("status_message", status.Message).
+					Msg("Tenant scope collections failed to initialize")
+				return false, fmt.Errorf("tenant scope %s: %s", tenantScope, status.Message)
+			}
+			if status.Ready {
+				elapsed := time.Since(start)
+				metrics.RecordTenantScopeCopyWait(elapsed)
+				log.Ctx(ctx).Info().
+					Str("tenant", tenantScope).
+					Dur("elapsed", elapsed).
+					Msg("Tenant scope ingestion ready")
 				return true, nil
 			}
 		}
 	}
 }
 
+// alreadyExistsMessages are the lowercase fragments of the Couchbase errors for scopes and collections that already exist,
+// e.g. "Scope with this name already exists" from the management API or "Duplicate" from N1QL
+var alreadyExistsMessages = []string{"already exists", "duplicate"}
+
+// hasAlreadyExistsMessage checks, ignoring case, if the error message says the scope or collection already exists
+func hasAlreadyExistsMessage(err error) bool {
+	errStr := strings.ToLower(err.Error())
+	for _, message := range alreadyExistsMessages {
+		if strings.Contains(errStr, message) {
+			return true
+		}
+	}
+	return false
+}
+
 // isScopeExistsError checks if the error indicates the scope already exists
 func (sm *ScopeModel) isScopeExistsError(err error) bool {
 	if err == nil {
 		return false
 	}
-	errStr := err.Error()
-	return contains(errStr, "already exists") || contains(errStr, "duplicate")
+	if errors.Is(err, gocb.ErrScopeExists) {
+		return true
+	}
+	return hasAlreadyExistsMessage(err)
 }
 
 // isCollectionExistsError checks if the error indicates the collection already exists
@@ -257,23 +530,8 @@ func (sm *ScopeModel) isCollectionExistsError(err error) bool {
 	if err == nil {
 		return false
 	}
-	errStr := err.Error()
-	return contains(errStr, "already exists") || contains(errStr, "duplicate")
-}
-
-// contains checks if a string contains a substring
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || (len(s) > len(substr) &&
-		(s[:len(substr)] == substr || s[len(s)-len(substr):] == substr ||
-			indexOf(s, substr) >= 0)))
-}
-
-// indexOf finds the index of a substring in a string
-func indexOf(s, substr string) int {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return i
-		}
+	if errors.Is(err, gocb.ErrCollectionExists) {
+		return true
 	}
-	return -1
+	return hasAlreadyExistsMessage(err)
 }
@@ -0,0 +1,507 @@
+package dal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// mockIngestionStatusGetter becomes ready after a fixed number of polls, or always returns status when set
+type mockIngestionStatusGetter struct {
+	readyAfter int
+	calls      int
+	status     *IngestionStatus
+}
+
+func (m *mockIngestionStatusGetter) GetTenantScopeIngestionStatus(ctx context.Context, tenantScope string) (*IngestionStatus, error) {
+	m.calls++
+	if m.status != nil {
+		return m.status, nil
+	}
+	if m.readyAfter > 0 && m.calls >= m.readyAfter {
+		return &IngestionStatus{Ready: true, Message: "Data copied from DefaultScope"}, nil
+	}
+	return &IngestionStatus{Ready: false, Message: "FHIR ingestion started"}, nil
+}
+
+// useTestWaitIntervals shortens the wait intervals and captures log output
+func useTestWaitIntervals(t *testing.T, timeout time.Duration) *bytes.Buffer {
+	t.Helper()
+
+	origTimeout, origPoll, origProgress := ingestionWaitTimeout, ingestionPollInterval, ingestionProgressLogInterval
+	origLogger, origContextLogger := log.Logger, zerolog.DefaultContextLogger
+
+	var buf bytes.Buffer
+	ingestionWaitTimeout = timeout
+	ingestionPollInterval = 20 * time.Millisecond
+	ingestionProgressLogInterval = 15 * time.Millisecond
+	log.Logger = zerolog.New(&buf)
+	// Contexts without a logger log to the global one, as set up by zerolog_config
+	zerolog.DefaultContextLogger = &log.Logger
+
+	t.Cleanup(func() {
+		ingestionWaitTimeout, ingestionPollInterval, ingestionProgressLogInterval = origTimeout, origPoll, origProgress
+		log.Logger, zerolog.DefaultContextLogger = origLogger, origContextLogger
+	})
+
+	return &buf
+}
+
+func TestWaitForIngestionReadyLogsProgress(t *testing.T) {
+	buf := useTestWaitIntervals(t, time.Second)
+	getter := &mockIngestionStatusGetter{readyAfter: 3}
+
+	ready, err := (&ScopeModel{}).waitForIngestionReady(context.Background(), "tenant1", getter)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !ready {
+		t.Fatalf("Expected ingestion to be ready")
+	}
+	if getter.calls != 3 {
+		t.Errorf("Expected 3 status checks, got %d", getter.calls)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "Still waiting for tenant scope ingestion, elapsed:") {
+		t.Errorf("Expected progress log, got: %s", output)
+	}
+	if !strings.Contains(output, "Tenant scope ingestion ready") {
+		t.Errorf("Expected ready log with elapsed time, got: %s", output)
+	}
+}
+
+func TestWaitForIngestionReadyTimeoutLogsLastStatus(t *testing.T) {
+	buf := useTestWaitIntervals(t, 70*time.Millisecond)
+	getter := &mockIngestionStatusGetter{}
+
+	ready, err := (&ScopeModel{}).waitForIngestionReady(context.Background(), "tenant1", getter)
+	if err == nil {
+		t.Fatalf("Expected timeout error")
+	}
+	if ready {
+		t.Errorf("Expected ingestion not to be ready")
+	}
+
+	if !strings.Contains(buf.String(), "FHIR ingestion started") {
+		t.Errorf("Expected last status message in timeout log, got: %s", buf.String())
+	}
+}
+
+func TestWaitForIngestionReadyCollectionInitFailed(t *testing.T) {
+	useTestWaitIntervals(t, time.Second)
+	getter := &mockIngestionStatusGetter{status: &IngestionStatus{
+		Ready:   false,
+		Message: collectionInitFailedMessage + "failed to create collection patients",
+	}}
+
+	start := time.Now()
+	ready, err := (&ScopeModel{}).waitForIngestionReady(context.Background(), "tenant1", getter)
+	if err == nil {
+		t.Fatal("Expected an error for a scope whose collections failed to initialize")
+	}
+	if ready {
+		t.Error("Expected ingestion not to be ready")
+	}
+	if !strings.Contains(err.Error(), "failed to create collection patients") {
+		t.Errorf("Expected the collection failure in the error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Expected the wait to stop on the first poll, took %v", elapsed)
+	}
+	if getter.calls != 1 {
+		t.Errorf("Expected 1 status check, got %d", getter.calls)
+	}
+}
+
+func TestIsExistsErrorShortMessages(t *testing.T) {
+	sm := &ScopeModel{}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "Nil error",
+			err:      nil,
+			expected: false,
+		},
+		{
+			name:     "Message shorter than pattern does not panic",
+			err:      errors.New("abc"),
+			expected: false,
+		},
+		{
+			name:     "Already exists in the middle of the message",
+			err:      errors.New("scope tenant1 already exists in bucket"),
+			expected: true,
+		},
+		{
+			name:     "Duplicate at the end of the message",
+			err:      errors.New("collection duplicate"),
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := sm.isScopeExistsError(tt.err); result != tt.expected {
+				t.Errorf("isScopeExistsError: expected %v, got %v", tt.expected, result)
+			}
+			if result := sm.isCollectionExistsError(tt.err); result != tt.expected {
+				t.Errorf("isCollectionExistsError: expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestIsExistsErrorCouchbaseMessages(t *testing.T) {
+	sm := &ScopeModel{}
+
+	tests := []struct {
+		name               string
+		err                error
+		expectedScope      bool
+		expectedCollection bool
+	}{
+		{
+			name:               "Management API scope message",
+			err:                errors.New(`scope exists | {"error":"Scope with this name already exists"}`),
+			expectedScope:      true,
+			expectedCollection: true,
+		},
+		{
+			name:               "Management API collection message",
+			err:                errors.New(`collection exists | {"error":"Collection with this name already exists"}`),
+			expectedScope:      true,
+			expectedCollection: true,
+		},
+		{
+			name:               "Capitalized message",
+			err:                errors.New("Scope Already Exists"),
+			expectedScope:      true,
+			expectedCollection: true,
+		},
+		{
+			name:               "N1QL duplicate name",
+			err:                errors.New("CREATE COLLECTION: Duplicate collection name encounters"),
+			expectedScope:      true,
+			expectedCollection: true,
+		},
+		{
+			name:               "Wrapped gocb scope exists",
+			err:                fmt.Errorf("failed to create scope: %w", gocb.ErrScopeExists),
+			expectedScope:      true,
+			expectedCollection: false,
+		},
+		{
+			name:               "Wrapped gocb collection exists",
+			err:                fmt.Errorf("failed to create collection: %w", gocb.ErrCollectionExists),
+			expectedScope:      false,
+			expectedCollection: true,
+		},
+		{
+			name:               "Other Couchbase error",
+			err:                fmt.Errorf("failed to create scope: %w", gocb.ErrAuthenticationFailure),
+			expectedScope:      false,
+			expectedCollection: false,
+		},
+		{
+			name:               "Bucket not found",
+			err:                errors.New("bucket not found | {\"error\":\"Requested resource not found\"}"),
+			expectedScope:      false,
+			expectedCollection: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := sm.isScopeExistsError(tt.err); result != tt.expectedScope {
+				t.Errorf("isScopeExistsError: expected %v, got %v", tt.expectedScope, result)
+			}
+			if result := sm.isCollectionExistsError(tt.err); result != tt.expectedCollection {
+				t.Errorf("isCollectionExistsError: expected %v, got %v", tt.expectedCollection, result)
+			}
+		})
+	}
+}
+
+func TestCopyInChunks(t *testing.T) {
+	tests := []struct {
+		name             string
+		total            int
+		chunkSize        int
+		expectedOffsets  []int
+		expectedProgress []int
+	}{
+		{
+			name:             "Empty collection",
+			total:            0,
+			chunkSize:        1000,
+			expectedOffsets:  nil,
+			expectedProgress: nil,
+		},
+		{
+			name:             "Partial last chunk",
+			total:            2500,
+			chunkSize:        1000,
+			expectedOffsets:  []int{0, 1000, 2000},
+			expectedProgress: []int{1000, 2000, 2500},
+		},
+		{
+			name:             "Exact multiple of chunk size",
+			total:            2000,
+			chunkSize:        1000,
+			expectedOffsets:  []int{0, 1000},
+			expectedProgress: []int{1000, 2000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var offsets, progress []int
+			err := copyInChunks(context.Background(), tt.total, tt.chunkSize,
+				func(offset, limit int) error {
+					if limit != tt.chunkSize {
+						t.Errorf("Expected limit %d, got %d", tt.chunkSize, limit)
+					}
+					offsets = append(offsets, offset)
+					return nil
+				},
+				func(copied int) {
+					progress = append(progress, copied)
+				})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(offsets, tt.expectedOffsets) {
+				t.Errorf("Expected offsets %v, got %v", tt.expectedOffsets, offsets)
+			}
+			if !reflect.DeepEqual(progress, tt.expectedProgress) {
+				t.Errorf("Expected progress %v, got %v", tt.expectedProgress, progress)
+			}
+		})
+	}
+}
+
+func TestCopyInChunksStopsOnError(t *testing.T) {
+	calls := 0
+	err := copyInChunks(context.Background(), 5000, 1000,
+		func(offset, limit int) error {
+			calls++
+			if offset == 2000 {
+				return errors.New("query memory limit exceeded")
+			}
+			return nil
+		},
+		func(copied int) {})
+
+	if err == nil {
+		t.Fatalf("Expected error from failing chunk")
+	}
+	if calls != 3 {
+		t.Errorf("Expected copy to stop after 3 chunks, got %d", calls)
+	}
+}
+
+func TestCopyProgressPercent(t *testing.T) {
+	if got := copyProgressPercent(2500, 10000); got != 25 {
+		t.Errorf("Expected 25%%, got %v", got)
+	}
+	if got := copyProgressPercent(0, 0); got != 100 {
+		t.Errorf("Expected empty collection to be 100%%, got %v", got)
+	}
+}
+
+func TestValidateCopySize(t *testing.T) {
+	tests := []struct {
+		name       string
+		count      int
+		allowLarge bool
+		wantErr    bool
+	}{
+		{name: "Below limit", count: 5000},
+		{name: "At limit", count: defaultMaxSafeCopySize},
+		{name: "Above limit", count: defaultMaxSafeCopySize + 1, wantErr: true},
+		{name: "Above limit with ALLOW_LARGE_COPY", count: defaultMaxSafeCopySize + 1, allowLarge: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCopySize(tt.count, defaultMaxSafeCopySize, tt.allowLarge)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateCopySize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrLargeCopyNotAllowed) {
+				t.Errorf("Expected ErrLargeCopyNotAllowed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestScopeCopySettingsFromEnv(t *testing.T) {
+	t.Setenv("COUCHBASE_SCOPE_COPY_QUERY_TIMEOUT_SECONDS", "")
+	t.Setenv("MAX_SAFE_COPY_SIZE", "")
+	if got := scopeCopyQueryTimeout(); got != 0 {
+		t.Errorf("Expected no copy timeout by default, got %v", got)
+	}
+	if got := maxSafeCopySize(); got != defaultMaxSafeCopySize {
+		t.Errorf("Expected max safe copy size %d by default, got %d", defaultMaxSafeCopySize, got)
+	}
+
+	t.Setenv("COUCHBASE_SCOPE_COPY_QUERY_TIMEOUT_SECONDS", "120")
+	t.Setenv("MAX_SAFE_COPY_SIZE", "2500")
+	if got := scopeCopyQueryTimeout(); got != 2*time.Minute {
+		t.Errorf("Expected copy timeout 2m, got %v", got)
+	}
+	if got := maxSafeCopySize(); got != 2500 {
+		t.Errorf("Expected max safe copy size 2500, got %d", got)
+	}
+}
+
+// recordingScopeInit records the order of the scope creation and status steps of initTenantScope
+type recordingScopeInit struct {
+	steps     []string
+	createErr error
+}
+
+func (r *recordingScopeInit) checkCopySize(ctx context.Context) error {
+	r.steps = append(r.steps, "checkCopySize")
+	return nil
+}
+
+func (r *recordingScopeInit) createScopeAndCollections(ctx context.Context, scopeName string) error {
+	r.steps = append(r.steps, "createScopeAndCollections")
+	return r.createErr
+}
+
+func (r *recordingScopeInit) copyDataFromDefaultScope(ctx context.Context, tenantScope string) error {
+	r.steps = append(r.steps, "copyDataFromDefaultScope")
+	return nil
+}
+
+func (r *recordingScopeInit) MarkTenantScopeIngestionStarted(ctx context.Context, tenantScope string) error {
+	r.steps = append(r.steps, "markStarted")
+	return nil
+}
+
+func (r *recordingScopeInit) MarkTenantScopeCollectionInitFailed(ctx context.Context, tenantScope string, cause error) error {
+	r.steps = append(r.steps, "markCollectionInitFailed")
+	return nil
+}
+
+func (r *recordingScopeInit) MarkTenantScopeIngestionCompleted(ctx context.Context, tenantScope string, message string) error {
+	r.steps = append(r.steps, "markCompleted")
+	return nil
+}
+
+// useFHIRTenants makes fhirTenantConfigured report the given tenants as ingested by fhir-client
+func useFHIRTenants(t *testing.T, tenants ...string) {
+	t.Helper()
+	orig := fhirTenantConfigured
+	fhirTenantConfigured = func(tenantScope string) (bool, error) {
+		for _, tenant := range tenants {
+			if tenant == tenantScope {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	t.Cleanup(func() { fhirTenantConfigured = orig })
+}
+
+func TestInitTenantScopeOrdering(t *testing.T) {
+	useFHIRTenants(t, "acme")
+
+	tests := []struct {
+		name      string
+		tenant    string
+		createErr error
+		wantErr   bool
+		wantSteps []string
+	}{
+		{
+			name:   "default tenant is copied then marked ready",
+			tenant: "tenant1",
+			wantSteps: []string{
+				"checkCopySize", "createScopeAndCollections", "markStarted", "copyDataFromDefaultScope", "markCompleted",
+			},
+		},
+		{
+			name:      "configured tenant is left to fhir-client",
+			tenant:    "acme",
+			wantSteps: []string{"createScopeAndCollections"},
+		},
+		{
+			name:      "configured tenant records collection failures",
+			tenant:    "acme",
+			createErr: errors.New("failed to create collection patients"),
+			wantErr:   true,
+			wantSteps: []string{"createScopeAndCollections", "markCollectionInitFailed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingScopeInit{createErr: tt.createErr}
+
+			err := initTenantScope(context.Background(), tt.tenant, rec, rec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(rec.steps, tt.wantSteps) {
+				t.Errorf("Expected steps %v, got %v", tt.wantSteps, rec.steps)
+			}
+		})
+	}
+}
+
+func TestInitTenantScopeConfiguredTenantThenFHIRClient(t *testing.T) {
+	useFHIRTenants(t, "acme")
+	useTestWaitIntervals(t, time.Second)
+
+	// api-rest gets the first request of a configured tenant before fhir-client ran
+	rec := &recordingScopeInit{}
+	if err := initTenantScope(context.Background(), "acme", rec, rec); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Nothing marked the scope ready, so fhir-client still ingests it and api-rest waits on its status
+	getter := &mockIngestionStatusGetter{readyAfter: 2}
+	ready, err := (&ScopeModel{}).waitForIngestionReady(context.Background(), "acme", getter)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !ready {
+		t.Fatal("Expected the scope to be ready once fhir-client marked it")
+	}
+	for _, step := range rec.steps {
+		if step == "markStarted" || step == "markCompleted" {
+			t.Errorf("Expected no ingestion status written for a configured tenant, got %v", rec.steps)
+		}
+	}
+}
+
+func TestInitTenantScopeTenantConfigError(t *testing.T) {
+	orig := fhirTenantConfigured
+	fhirTenantConfigured = func(string) (bool, error) { return false, errors.New("failed to read FHIR tenant config file") }
+	t.Cleanup(func() { fhirTenantConfigured = orig })
+
+	rec := &recordingScopeInit{}
+	if err := initTenantScope(context.Background(), "acme", rec, rec); err == nil {
+		t.Fatal("Expected the tenant config error")
+	}
+	if len(rec.steps) != 0 {
+		t.Errorf("Expected nothing created without the tenant config, got %v", rec.steps)
+	}
+}
@@ -0,0 +1,55 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// ErrTenantDeleted is returned by EnsureTenantScope for a tenant removed with DeleteTenantScope,
+// so a request of the deleted tenant does not create its scope again with DefaultScope data
+var ErrTenantDeleted = errors.New("tenant was deleted")
+
+// tenantTombstoneKey is the key of the document recording a deleted tenant, kept in the defaulty collection
+// of DefaultScope since the tenant scope itself is dropped
+func tenantTombstoneKey(tenantScope string) string {
+	return "tenant_tombstone/" + tenantScope
+}
+
+// tombstoneCollection returns the collection holding the tenant tombstones
+func tombstoneCollection(conn *Connection) *gocb.Collection {
+	return conn.GetBucket().Scope("_default").Collection("defaulty")
+}
+
+// IsTenantDeleted checks if a tenant was deleted and not restored since
+func (sm *ScopeModel) IsTenantDeleted(ctx context.Context, tenantScope string) (bool, error) {
+	_, err := tombstoneCollection(sm.conn).Get(tenantTombstoneKey(tenantScope), &gocb.GetOptions{Context: ctx})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RestoreTenant removes the tombstone of a deleted tenant so its scope can be created again,
+// succeeding when the tenant was never deleted
+func (sm *ScopeModel) RestoreTenant(ctx context.Context, tenantScope string) error {
+	_, err := tombstoneCollection(sm.conn).Remove(tenantTombstoneKey(tenantScope), &gocb.RemoveOptions{Context: ctx})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (c couchbaseScopeCleaner) markTenantDeleted(ctx context.Context, scopeName string) error {
+	tombstone := map[string]interface{}{
+		"tenant":    scopeName,
+		"deletedAt": time.Now().UTC().Format(time.RFC3339),
+	}
+	_, err := tombstoneCollection(c.conn).Upsert(tenantTombstoneKey(scopeName), tombstone, &gocb.UpsertOptions{Context: ctx})
+	return err
+}
@@ -0,0 +1,53 @@
+package dal
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// TenantDataTTL returns how long tenant scope documents are kept, from TENANT_DATA_TTL_DAYS.
+// Zero (the default) means documents never expire.
+func TenantDataTTL() time.Duration {
+	if value := os.Getenv("TENANT_DATA_TTL_DAYS"); value != "" {
+		if days, err := strconv.Atoi(value); err == nil && days > 0 {
+			return time.Duration(days) * 24 * time.Hour
+		}
+	}
+	return 0
+}
+
+// WarnIfTenantDataTTL logs a startup warning when tenant scope documents expire
+func WarnIfTenantDataTTL() {
+	if ttl := TenantDataTTL(); ttl > 0 {
+		log.Warn().
+			Dur("ttl", ttl).
+			Msg("TENANT_DATA_TTL_DAYS is set: tenant scope documents, including reviews, expire and are removed by Couchbase")
+	}
+}
+
+// collectionCreator is the part of gocb.CollectionManagerV2 used to create collections with settings
+type collectionCreator interface {
+	CreateCollection(scopeName string, collectionName string, settings *gocb.CreateCollectionSettings, opts *gocb.CreateCollectionOptions) error
+}
+
+// createCollectionWithTTL creates a collection whose documents expire after maxTTL
+func createCollectionWithTTL(creator collectionCreator, scopeName, collectionName string, maxTTL time.Duration) error {
+	err := creator.CreateCollection(scopeName, collectionName, &gocb.CreateCollectionSettings{MaxExpiry: maxTTL}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create collection %s in scope %s with max TTL %s: %w", collectionName, scopeName, maxTTL, err)
+	}
+	return nil
+}
+
+// upsertOptionsForScope sets the tenant data expiry on upserts outside the default scope
+func upsertOptionsForScope(opts *gocb.UpsertOptions, tenantScope string) *gocb.UpsertOptions {
+	if tenantScope != "_default" {
+		opts.Expiry = TenantDataTTL()
+	}
+	return opts
+}
@@ -0,0 +1,68 @@
+package dal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// recordingCollectionCreator keeps the settings of the collections it is asked to create
+type recordingCollectionCreator struct {
+	settings map[string]*gocb.CreateCollectionSettings
+}
+
+func (r *recordingCollectionCreator) CreateCollection(scopeName string, collectionName string, settings *gocb.CreateCollectionSettings, opts *gocb.CreateCollectionOptions) error {
+	r.settings[scopeName+"."+collectionName] = settings
+	return nil
+}
+
+func TestTenantDataTTL(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: 0},
+		{value: "0", expected: 0},
+		{value: "30", expected: 30 * 24 * time.Hour},
+		{value: "-1", expected: 0},
+		{value: "month", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("TENANT_DATA_TTL_DAYS", tt.value)
+			if got := TenantDataTTL(); got != tt.expected {
+				t.Errorf("Expected TTL %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCreateCollectionWithTTL(t *testing.T) {
+	t.Setenv("TENANT_DATA_TTL_DAYS", "7")
+	creator := &recordingCollectionCreator{settings: map[string]*gocb.CreateCollectionSettings{}}
+
+	if err := createCollectionWithTTL(creator, "tenant1", "encounters", TenantDataTTL()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	settings, ok := creator.settings["tenant1.encounters"]
+	if !ok {
+		t.Fatal("Expected tenant1.encounters to be created")
+	}
+	if settings.MaxExpiry != 7*24*time.Hour {
+		t.Errorf("Expected max TTL 168h, got %v", settings.MaxExpiry)
+	}
+}
+
+func TestUpsertOptionsForScope(t *testing.T) {
+	t.Setenv("TENANT_DATA_TTL_DAYS", "7")
+
+	if opts := upsertOptionsForScope(&gocb.UpsertOptions{}, "tenant1"); opts.Expiry != 7*24*time.Hour {
+		t.Errorf("Expected tenant upserts to expire after 168h, got %v", opts.Expiry)
+	}
+	if opts := upsertOptionsForScope(&gocb.UpsertOptions{}, "_default"); opts.Expiry != 0 {
+		t.Errorf("Expected default scope upserts not to expire, got %v", opts.Expiry)
+	}
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"runtime"
 	"strconv"
 	"time"
@@ -16,7 +17,7 @@ var (
 			Name: "http_requests_total",
 			Help: "Total number of HTTP requests",
 		},
-		[]string{"method", "endpoint", "status"},
+		[]string{"method", "endpoint", "status", "tenant"}, // tenant is empty unless METRICS_INCLUDE_TENANT_LABEL=true
 	)
 
 	// HTTP request duration histogram
@@ -26,7 +27,7 @@ var (
 			Help:    "Duration of HTTP requests in seconds",
 			Buckets: prometheus.DefBuckets,
 		},
-		[]string{"method", "endpoint", "status"},
+		[]string{"method", "endpoint", "status", "tenant"}, // tenant is empty unless METRICS_INCLUDE_TENANT_LABEL=true
 	)
 
 	// Active HTTP connections gauge
@@ -77,16 +78,147 @@ var (
 			Help:    "Duration of channel operations in seconds",
 			Buckets: prometheus.DefBuckets,
 		},
-		[]string{"operation"},
+		[]string{"operation", "tenant"}, // tenant is empty unless METRICS_INCLUDE_TENANT_LABEL=true
+	)
+
+	// Tenant scope copy wait histogram
+	TenantScopeCopyWaitDuration = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "tenant_scope_copy_wait_seconds",
+			Help:    "Time spent waiting for tenant scope ingestion to be ready in seconds",
+			Buckets: []float64{1, 5, 15, 30, 60, 120, 180, 240, 300},
+		},
+	)
+
+	// TenantWarmUpDuration tracks how long tenant warm-ups take, successful or not
+	TenantWarmUpDuration = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "tenant_warmup_duration_seconds",
+			Help:    "Duration of tenant warm-ups in seconds",
+			Buckets: []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60},
+		},
+	)
+
+	// TenantWarmUpTotal tracks tenant warm-ups by outcome
+	TenantWarmUpTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tenant_warmup_total",
+			Help: "Total number of tenant warm-ups",
+		},
+		[]string{"status"}, // "success", "timeout", "error"
+	)
+
+	// TenantColdTotal tracks tenants whose goroutines stopped after the idle timeout
+	TenantColdTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tenant_cold_total",
+			Help: "Total number of tenants gone cold after the idle timeout",
+		},
+	)
+
+	// TenantScopeCopyProgress tracks the progress of copying DefaultScope data into a tenant scope
+	TenantScopeCopyProgress = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tenant_scope_copy_progress_percent",
+			Help: "Progress of copying DefaultScope data into a tenant scope collection in percent",
+		},
+		[]string{"tenant", "collection"},
+	)
+
+	// CouchbaseOperationsTotal tracks Couchbase document reads, writes and queries
+	CouchbaseOperationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "couchbase_operations_total",
+			Help: "Total number of Couchbase operations",
+		},
+		[]string{"operation", "status", "tenant"}, // tenant is empty unless METRICS_INCLUDE_TENANT_LABEL=true
+	)
+
+	// CouchbaseOperationDuration tracks Couchbase operation duration
+	CouchbaseOperationDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "couchbase_operation_duration_seconds",
+			Help:    "Duration of Couchbase operations in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"operation", "tenant"}, // tenant is empty unless METRICS_INCLUDE_TENANT_LABEL=true
+	)
+
+	// CouchbasePingDuration tracks connection liveness ping duration
+	CouchbasePingDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "couchbase_ping_duration_seconds",
+			Help:    "Duration of Couchbase connection liveness pings in seconds",
+			Buckets: []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5},
+		},
+		[]string{"status"}, // "success", "error", "timeout"
+	)
+
+	// CouchbasePoolConnectionsTotal tracks the maximum size of the connection pool
+	CouchbasePoolConnectionsTotal = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "couchbase_pool_connections_total",
+			Help: "Maximum number of idle connections kept in the Couchbase connection pool",
+		},
+	)
+
+	// CouchbasePoolIdleConnections tracks connections waiting in the pool
+	CouchbasePoolIdleConnections = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "couchbase_pool_idle_connections",
+			Help: "Number of Couchbase connections currently idle in the pool",
+		},
+	)
+
+	// CouchbasePoolActiveConnections tracks connections checked out of the pool
+	CouchbasePoolActiveConnections = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "couchbase_pool_active_connections",
+			Help: "Number of Couchbase connections currently checked out",
+		},
+	)
+
+	// CouchbasePoolExhaustedTotal tracks acquisitions made while every connection of the pool was checked out
+	CouchbasePoolExhaustedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "couchbase_pool_exhausted_total",
+			Help: "Total number of connection acquisitions made while every connection of the pool was checked out",
+		},
+	)
+
+	// GoroutineThresholdExceededTotal tracks liveness probes failed by too many goroutines
+	GoroutineThresholdExceededTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "go_goroutines_threshold_exceeded_total",
+			Help: "Total number of liveness probes where the goroutine count exceeded the threshold",
+		},
+	)
+
+	// ReviewSummaryCacheHitTotal tracks review summaries served from the cache
+	ReviewSummaryCacheHitTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "review_summary_cache_hit_total",
+			Help: "Total number of review summaries served from the cache",
+		},
+	)
+
+	// FHIRExportBytesTotal tracks the size of tenant exports
+	FHIRExportBytesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fhir_export_bytes_total",
+			Help: "Total number of bytes written by tenant FHIR exports",
+		},
+		[]string{"resource_type", "tenant"}, // tenant is empty unless METRICS_INCLUDE_TENANT_LABEL=true
 	)
 )
 
 // RecordHTTPRequest records metrics for an HTTP request
-func RecordHTTPRequest(method, endpoint string, statusCode int, duration time.Duration) {
+func RecordHTTPRequest(method, endpoint, tenantID string, statusCode int, duration time.Duration) {
 	status := strconv.Itoa(statusCode)
 
-	HTTPRequestsTotal.WithLabelValues(method, endpoint, status).Inc()
-	HTTPRequestDuration.WithLabelValues(method, endpoint, status).Observe(duration.Seconds())
+	tenant := tenantLabel(tenantID)
+	HTTPRequestsTotal.WithLabelValues(method, endpoint, status, tenant).Inc()
+	HTTPRequestDuration.WithLabelValues(method, endpoint, status, tenant).Observe(duration.Seconds())
 }
 
 // RecordAllGoodRequest records business logic metrics
@@ -115,8 +247,46 @@ func UpdateSystemMetrics(serviceName string) {
 }
 
 // RecordChannelOperation records metrics for channel operations
-func RecordChannelOperation(operation string, duration time.Duration) {
-	ChannelOperationDuration.WithLabelValues(operation).Observe(duration.Seconds())
+func RecordChannelOperation(operation, tenantID string, duration time.Duration) {
+	ChannelOperationDuration.WithLabelValues(operation, tenantLabel(tenantID)).Observe(duration.Seconds())
+}
+
+// RecordTenantScopeCopyWait records how long a request waited for tenant scope ingestion
+func RecordTenantScopeCopyWait(duration time.Duration) {
+	TenantScopeCopyWaitDuration.Observe(duration.Seconds())
+}
+
+// RecordTenantWarmUp records the duration and outcome of a tenant warm-up
+func RecordTenantWarmUp(status string, duration time.Duration) {
+	TenantWarmUpDuration.Observe(duration.Seconds())
+	TenantWarmUpTotal.WithLabelValues(status).Inc()
+}
+
+// RecordTenantCold records a tenant gone cold after the idle timeout
+func RecordTenantCold() {
+	TenantColdTotal.Inc()
+}
+
+// SetTenantScopeCopyProgress sets the copy progress of a tenant scope collection
+func SetTenantScopeCopyProgress(tenant, collection string, percent float64) {
+	TenantScopeCopyProgress.WithLabelValues(tenant, collection).Set(percent)
+}
+
+// RecordCouchbaseOperation records a Couchbase operation of the tenant set on ctx by ContextWithTenant
+func RecordCouchbaseOperation(ctx context.Context, operation, status string, duration time.Duration) {
+	tenant := tenantLabel(TenantFromContext(ctx))
+	CouchbaseOperationsTotal.WithLabelValues(operation, status, tenant).Inc()
+	CouchbaseOperationDuration.WithLabelValues(operation, tenant).Observe(duration.Seconds())
+}
+
+// RecordCouchbasePing records the duration and outcome of a connection liveness ping
+func RecordCouchbasePing(status string, duration time.Duration) {
+	CouchbasePingDuration.WithLabelValues(status).Observe(duration.Seconds())
+}
+
+// RecordGoroutineThresholdExceeded records a liveness probe failed by the goroutine threshold
+func RecordGoroutineThresholdExceeded() {
+	GoroutineThresholdExceededTotal.Inc()
 }
 
 // StartSystemMetricsCollection starts a goroutine to collect system metrics
@@ -130,3 +300,25 @@ func StartSystemMetricsCollection(serviceName string) {
 		}
 	}()
 }
+
+// SetCouchbasePoolConnections records the pool size and its idle and checked out connections
+func SetCouchbasePoolConnections(total, idle, active int) {
+	CouchbasePoolConnectionsTotal.Set(float64(total))
+	CouchbasePoolIdleConnections.Set(float64(idle))
+	CouchbasePoolActiveConnections.Set(float64(active))
+}
+
+// RecordCouchbasePoolExhausted records an acquisition made while every connection of the pool was checked out
+func RecordCouchbasePoolExhausted() {
+	CouchbasePoolExhaustedTotal.Inc()
+}
+
+// RecordReviewSummaryCacheHit records a review summary served from the cache
+func RecordReviewSummaryCacheHit() {
+	ReviewSummaryCacheHitTotal.Inc()
+}
+
+// RecordExportBytes records bytes of resourceType written by a tenant export
+func RecordExportBytes(tenantID, resourceType string, bytes int) {
+	FHIRExportBytesTotal.WithLabelValues(resourceType, tenantLabel(tenantID)).Add(float64(bytes))
+}
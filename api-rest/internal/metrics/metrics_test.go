@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecordTenantWarmUp(t *testing.T) {
+	TenantWarmUpTotal.Reset()
+	t.Cleanup(TenantWarmUpTotal.Reset)
+
+	RecordTenantWarmUp("success", 300*time.Millisecond)
+	RecordTenantWarmUp("success", 2*time.Second)
+	RecordTenantWarmUp("timeout", 60*time.Second)
+	RecordTenantWarmUp("error", 50*time.Millisecond)
+
+	expected := `
+# HELP tenant_warmup_total Total number of tenant warm-ups
+# TYPE tenant_warmup_total counter
+tenant_warmup_total{status="error"} 1
+tenant_warmup_total{status="success"} 2
+tenant_warmup_total{status="timeout"} 1
+`
+	if err := promtestutil.CollectAndCompare(TenantWarmUpTotal, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecordTenantCold(t *testing.T) {
+	before := promtestutil.ToFloat64(TenantColdTotal)
+
+	RecordTenantCold()
+	RecordTenantCold()
+
+	if got := promtestutil.ToFloat64(TenantColdTotal); got != before+2 {
+		t.Errorf("Expected tenant_cold_total to grow by 2, got %v", got-before)
+	}
+}
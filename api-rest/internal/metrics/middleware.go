@@ -3,6 +3,8 @@ package metrics
 import (
 	"net/http"
 	"time" // Update with your actual module name
+
+	"github.com/gorilla/mux"
 )
 
 // responseWriter wraps http.ResponseWriter to capture status code
@@ -28,6 +30,16 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return rw.ResponseWriter.Write(b)
 }
 
+// HeaderWritten reports whether the status code has been sent
+func (rw *responseWriter) HeaderWritten() bool {
+	return rw.written
+}
+
+// Unwrap returns the wrapped writer, so http.ResponseController can flush streamed responses
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // MetricsMiddleware records HTTP metrics for all requests
 func MetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -49,6 +61,6 @@ func MetricsMiddleware(next http.Handler) http.Handler {
 
 		// Record metrics
 		duration := time.Since(start)
-		RecordHTTPRequest(r.Method, r.URL.Path, rw.statusCode, duration)
+		RecordHTTPRequest(r.Method, r.URL.Path, mux.Vars(r)["tenant"], rw.statusCode, duration)
 	})
 }
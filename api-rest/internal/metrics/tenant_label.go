@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"context"
+
+	"stealthcompany.com/pkg/tenantlabel"
+)
+
+// tenantContextKey is the context key of the tenant ID attributed to Couchbase operations
+type tenantContextKey struct{}
+
+// ContextWithTenant returns a copy of ctx whose Couchbase operations are recorded for tenantID
+func ContextWithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant ID set by ContextWithTenant, or "" when there is none
+func TenantFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenantID
+}
+
+// tenantLabels is the process-wide set of tenant label values
+var tenantLabels = tenantlabel.NewSet()
+
+// tenantLabel returns the bounded tenant label of a tenant ID, empty for public endpoints or when
+// METRICS_INCLUDE_TENANT_LABEL is off
+func tenantLabel(id string) string {
+	return tenantLabels.Label(id)
+}
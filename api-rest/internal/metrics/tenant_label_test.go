@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"stealthcompany.com/pkg/tenantlabel"
+)
+
+// resetTenantLabels empties the tenant label set for the duration of a test
+func resetTenantLabels(t *testing.T) {
+	t.Helper()
+	tenantLabels = tenantlabel.NewSet()
+	t.Cleanup(func() {
+		tenantLabels = tenantlabel.NewSet()
+	})
+}
+
+func TestRecordChannelOperationTenantCardinalityBounded(t *testing.T) {
+	resetTenantLabels(t)
+	t.Setenv("METRICS_INCLUDE_TENANT_LABEL", "true")
+	ChannelOperationDuration.Reset()
+	t.Cleanup(ChannelOperationDuration.Reset)
+
+	for i := 0; i < tenantlabel.MaxLabels*3; i++ {
+		RecordChannelOperation("get_encounter", fmt.Sprintf("tenant-%d", i), time.Millisecond)
+	}
+
+	// One series per allowed tenant plus the shared overflow series
+	if got := promtestutil.CollectAndCount(ChannelOperationDuration); got > tenantlabel.MaxLabels+1 {
+		t.Errorf("Expected at most %d series, got %d", tenantlabel.MaxLabels+1, got)
+	}
+	if got := tenantLabel("tenant-new"); got != tenantlabel.Overflow {
+		t.Errorf("Expected overflow label %q once the cap is reached, got %q", tenantlabel.Overflow, got)
+	}
+	if got := tenantLabel("tenant-0"); got != tenantlabel.Sanitize("tenant-0") {
+		t.Errorf("Expected an already labelled tenant to keep its label, got %q", got)
+	}
+}
+
+func TestRecordHTTPRequestTenantLabelDisabled(t *testing.T) {
+	resetTenantLabels(t)
+	t.Setenv("METRICS_INCLUDE_TENANT_LABEL", "false")
+	HTTPRequestDuration.Reset()
+	t.Cleanup(HTTPRequestDuration.Reset)
+
+	for i := 0; i < 10; i++ {
+		RecordHTTPRequest("GET", "/api/encounters", fmt.Sprintf("tenant-%d", i), 200, time.Millisecond)
+	}
+
+	if got := promtestutil.CollectAndCount(HTTPRequestDuration); got != 1 {
+		t.Errorf("Expected a single series with tenant labels disabled, got %d", got)
+	}
+}
+
+func TestRecordHTTPRequestWithoutTenant(t *testing.T) {
+	resetTenantLabels(t)
+	t.Setenv("METRICS_INCLUDE_TENANT_LABEL", "true")
+	HTTPRequestsTotal.Reset()
+	t.Cleanup(HTTPRequestsTotal.Reset)
+
+	// Public endpoints have no tenant in the path
+	RecordHTTPRequest("GET", "/health", "", 200, time.Millisecond)
+
+	if got := promtestutil.ToFloat64(HTTPRequestsTotal.WithLabelValues("GET", "/health", "200", "")); got != 1 {
+		t.Errorf("Expected 1 request with an empty tenant label, got %v", got)
+	}
+}
+
+func TestRecordCouchbaseOperationTenantFromContext(t *testing.T) {
+	resetTenantLabels(t)
+	t.Setenv("METRICS_INCLUDE_TENANT_LABEL", "true")
+	CouchbaseOperationsTotal.Reset()
+	t.Cleanup(CouchbaseOperationsTotal.Reset)
+
+	tenantID := "3f2504e0-4f89-11d3-9a0c-0305e82c3301"
+	RecordCouchbaseOperation(ContextWithTenant(context.Background(), tenantID), "get", "success", time.Millisecond)
+	RecordCouchbaseOperation(context.Background(), "get", "success", time.Millisecond)
+
+	if got := promtestutil.ToFloat64(CouchbaseOperationsTotal.WithLabelValues("get", "success", "3f2504e0")); got != 1 {
+		t.Errorf("Expected 1 operation labelled with the context tenant, got %v", got)
+	}
+	if got := promtestutil.ToFloat64(CouchbaseOperationsTotal.WithLabelValues("get", "success", "")); got != 1 {
+		t.Errorf("Expected 1 operation with an empty tenant label, got %v", got)
+	}
+}
@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans replaces the tracer with one recording its ended spans for the duration of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	original := tracer
+	tracer = provider.Tracer("test")
+	t.Cleanup(func() {
+		tracer = original
+	})
+	return recorder
+}
+
+func TestInitWithoutEndpoint(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	before := otel.GetTracerProvider()
+
+	shutdown, err := Init(context.Background())
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown() error = %v", err)
+	}
+	if otel.GetTracerProvider() != before {
+		t.Errorf("Expected the global tracer provider to be left unchanged")
+	}
+}
+
+func TestStartDBSpan(t *testing.T) {
+	recorder := recordSpans(t)
+
+	_, span := StartDBSpan(context.Background(), "ResourceModel.GetResource", "get", "Encounter")
+	EndSpan(span, errors.New("resource not found"))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name() != "ResourceModel.GetResource" {
+		t.Errorf("Expected span name ResourceModel.GetResource, got %q", spans[0].Name())
+	}
+
+	expected := map[attribute.Key]string{
+		"db.system":          "couchbase",
+		"db.operation":       "get",
+		"fhir.resource_type": "Encounter",
+	}
+	attributes := make(map[attribute.Key]string)
+	for _, kv := range spans[0].Attributes() {
+		attributes[kv.Key] = kv.Value.AsString()
+	}
+	for key, want := range expected {
+		if attributes[key] != want {
+			t.Errorf("Expected %s %q, got %q", key, want, attributes[key])
+		}
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("Expected error status, got %v", spans[0].Status().Code)
+	}
+}
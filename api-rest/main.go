@@ -13,6 +13,7 @@ import (
 	"stealthcompany.com/api-rest/internal/api"
 	"stealthcompany.com/api-rest/internal/dal"
 	"stealthcompany.com/api-rest/internal/metrics"
+	"stealthcompany.com/api-rest/internal/tracing"
 	"stealthcompany.com/pkg/zerolog_config"
 )
 
@@ -39,6 +40,13 @@ func main() {
 	zerolog_config.StartupWithEnv(elasticsearchURL, "logs", apiLogLevel)
 
 	log.Info().Msg("Starting evtechallenge-api service")
+	dal.WarnIfTenantDataTTL()
+
+	// Export traces when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize tracing")
+	}
 
 	// Start system metrics collection
 	metrics.StartSystemMetricsCollection("api-rest")
@@ -53,13 +61,16 @@ func main() {
 		log.Fatal().Err(err).Msg("Failed to wait for FHIR ingestion")
 	}
 
-	// Setup routes
-	router := api.SetupRoutes()
+	// Build the HTTP handler from the environment, the same path the tests use
+	handler, err := api.NewApp(api.AppConfigFromEnv())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid authentication configuration")
+	}
 
 	// Create HTTP server
 	server := &http.Server{
 		Addr:    ":" + apiPort,
-		Handler: router,
+		Handler: handler,
 	}
 
 	// Setup graceful shutdown
@@ -85,6 +96,15 @@ func main() {
 	<-sigChan
 	log.Info().Msg("Received shutdown signal, shutting down gracefully...")
 
+	// Drain in-flight tenant requests before shutting the server down, cancelling them on timeout
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer drainCancel()
+
+	log.Info().Msg("Draining in-flight tenant requests...")
+	if err := api.DrainTenantChannels(drainCtx); err != nil {
+		log.Warn().Err(err).Msg("Tenant requests did not drain in time and were cancelled")
+	}
+
 	// Shutdown server with timeout
 	shutdownTimeout := 30 * time.Second
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
@@ -108,6 +128,11 @@ func main() {
 		log.Warn().Err(err).Msg("Failed to get connection for cleanup")
 	}
 
+	// Flush the spans still buffered by the exporter
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Warn().Err(err).Msg("Failed to flush traces")
+	}
+
 	log.Info().Msg("API service shutdown complete")
 }
 
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"stealthcompany.com/fhir-client/internal/dal"
+)
+
+// dalStore adapts the fhir-client DAL models to the seeder
+type dalStore struct {
+	*dal.EncounterModel
+	*dal.PatientModel
+	*dal.PractitionerModel
+	ingestionStatus *dal.IngestionStatusModel
+}
+
+// MarkSeeded records the seeded counts and marks the default scope ingestion as complete
+func (s *dalStore) MarkSeeded(ctx context.Context, counts map[string]int) error {
+	for resourceType, count := range counts {
+		if err := s.ingestionStatus.SetResourceCount(ctx, resourceType, count); err != nil {
+			return err
+		}
+	}
+	return s.ingestionStatus.SetIngestionStatus(ctx, true, "Seeded from local test data")
+}
+
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func main() {
+	force := flag.Bool("force", false, "seed even if encounters already exist")
+	flag.Parse()
+
+	ctx := context.Background()
+	dataDir := getEnv("SEED_DATA_DIR", "./testdata")
+
+	resources, err := loadResources(dataDir)
+	if err != nil {
+		log.Fatal().Err(err).Str("dir", dataDir).Msg("Failed to load seed data")
+	}
+
+	conn, err := dal.GetConnOrGenConn()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to Couchbase")
+	}
+	defer dal.CloseAllConnections()
+	defer dal.ReturnConnection(conn)
+
+	resourceModel := dal.NewResourceModel(conn)
+	store := &dalStore{
+		EncounterModel:    dal.NewEncounterModel(resourceModel),
+		PatientModel:      dal.NewPatientModel(resourceModel),
+		PractitionerModel: dal.NewPractitionerModel(resourceModel),
+		ingestionStatus:   dal.NewIngestionStatusModel(conn),
+	}
+
+	counts, err := seed(ctx, store, resources, *force)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to seed data")
+	}
+	if counts == nil {
+		return
+	}
+
+	log.Info().
+		Int("encounters", counts["Encounter"]).
+		Int("patients", counts["Patient"]).
+		Int("practitioners", counts["Practitioner"]).
+		Msg("Seed completed successfully")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// resourceStore is the subset of the DAL models used by the seeder
+type resourceStore interface {
+	CountEncounters(ctx context.Context) (int64, error)
+	UpsertEncounter(ctx context.Context, encounterID string, data map[string]interface{}) error
+	UpsertPatient(ctx context.Context, patientID string, data map[string]interface{}) error
+	UpsertPractitioner(ctx context.Context, practitionerID string, data map[string]interface{}) error
+	MarkSeeded(ctx context.Context, counts map[string]int) error
+}
+
+// seedOrder upserts referenced resources before the encounters pointing to them
+var seedOrder = []string{"Practitioner", "Patient", "Encounter"}
+
+// bundle is the subset of a FHIR Bundle read from the seed files
+type bundle struct {
+	ResourceType string `json:"resourceType"`
+	Entry        []struct {
+		Resource map[string]interface{} `json:"resource"`
+	} `json:"entry"`
+}
+
+// loadResources reads every *.json FHIR Bundle in dir and groups the resources by type
+func loadResources(dir string) (map[string][]map[string]interface{}, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list seed files: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no seed files found in %s", dir)
+	}
+	sort.Strings(files)
+
+	resources := make(map[string][]map[string]interface{})
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		var b bundle
+		if err := json.Unmarshal(content, &b); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		if b.ResourceType != "Bundle" {
+			return nil, fmt.Errorf("%s is not a FHIR Bundle", file)
+		}
+
+		for _, entry := range b.Entry {
+			resourceType, _ := entry.Resource["resourceType"].(string)
+			resources[resourceType] = append(resources[resourceType], entry.Resource)
+		}
+	}
+
+	return resources, nil
+}
+
+// seed upserts the resources unless encounters already exist and force is false.
+// Upserts are keyed by resource ID, so seeding twice leaves the same documents.
+func seed(ctx context.Context, store resourceStore, resources map[string][]map[string]interface{}, force bool) (map[string]int, error) {
+	if !force {
+		count, err := store.CountEncounters(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count encounters: %w", err)
+		}
+		if count > 0 {
+			log.Info().Int64("encounters", count).Msg("Data already exists, skipping seed (use --force to override)")
+			return nil, nil
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, resourceType := range seedOrder {
+		for _, data := range resources[resourceType] {
+			id, _ := data["id"].(string)
+			if strings.TrimSpace(id) == "" {
+				log.Warn().Str("resource_type", resourceType).Msg("Skipping seed resource without id")
+				continue
+			}
+
+			var err error
+			switch resourceType {
+			case "Practitioner":
+				err = store.UpsertPractitioner(ctx, id, data)
+			case "Patient":
+				err = store.UpsertPatient(ctx, id, data)
+			case "Encounter":
+				err = store.UpsertEncounter(ctx, id, data)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to seed %s/%s: %w", resourceType, id, err)
+			}
+			counts[resourceType]++
+		}
+	}
+
+	if err := store.MarkSeeded(ctx, counts); err != nil {
+		return nil, fmt.Errorf("failed to mark ingestion status: %w", err)
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// memoryStore keeps seeded documents in memory, keyed like the Couchbase document IDs
+type memoryStore struct {
+	docs        map[string]map[string]interface{}
+	seededCount int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{docs: make(map[string]map[string]interface{})}
+}
+
+func (m *memoryStore) CountEncounters(ctx context.Context) (int64, error) {
+	var count int64
+	for _, doc := range m.docs {
+		if doc["resourceType"] == "Encounter" {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *memoryStore) UpsertEncounter(ctx context.Context, encounterID string, data map[string]interface{}) error {
+	m.docs["Encounter/"+encounterID] = data
+	return nil
+}
+
+func (m *memoryStore) UpsertPatient(ctx context.Context, patientID string, data map[string]interface{}) error {
+	m.docs["Patient/"+patientID] = data
+	return nil
+}
+
+func (m *memoryStore) UpsertPractitioner(ctx context.Context, practitionerID string, data map[string]interface{}) error {
+	m.docs["Practitioner/"+practitionerID] = data
+	return nil
+}
+
+func (m *memoryStore) MarkSeeded(ctx context.Context, counts map[string]int) error {
+	m.seededCount++
+	return nil
+}
+
+func TestLoadResourcesFromTestdata(t *testing.T) {
+	resources, err := loadResources("testdata")
+	if err != nil {
+		t.Fatalf("Failed to load testdata: %v", err)
+	}
+
+	expected := map[string]int{"Patient": 5, "Encounter": 10, "Practitioner": 5}
+	for resourceType, count := range expected {
+		if len(resources[resourceType]) != count {
+			t.Errorf("Expected %d %s resources, got %d", count, resourceType, len(resources[resourceType]))
+		}
+	}
+}
+
+func TestSeedIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	resources, err := loadResources("testdata")
+	if err != nil {
+		t.Fatalf("Failed to load testdata: %v", err)
+	}
+
+	store := newMemoryStore()
+
+	counts, err := seed(ctx, store, resources, false)
+	if err != nil {
+		t.Fatalf("First seed failed: %v", err)
+	}
+	if counts["Encounter"] != 10 || counts["Patient"] != 5 || counts["Practitioner"] != 5 {
+		t.Errorf("Unexpected seeded counts: %v", counts)
+	}
+	if len(store.docs) != 20 {
+		t.Fatalf("Expected 20 documents after first seed, got %d", len(store.docs))
+	}
+
+	// Second run without --force is skipped
+	counts, err = seed(ctx, store, resources, false)
+	if err != nil {
+		t.Fatalf("Second seed failed: %v", err)
+	}
+	if counts != nil {
+		t.Errorf("Expected second seed to be skipped, got %v", counts)
+	}
+	if store.seededCount != 1 {
+		t.Errorf("Expected ingestion status to be marked once, got %d", store.seededCount)
+	}
+
+	// Forced run upserts the same documents again
+	if _, err := seed(ctx, store, resources, true); err != nil {
+		t.Fatalf("Forced seed failed: %v", err)
+	}
+	if len(store.docs) != 20 {
+		t.Errorf("Expected 20 documents after forced seed, got %d", len(store.docs))
+	}
+}
+
+func TestLoadResourcesMissingDir(t *testing.T) {
+	if _, err := loadResources(t.TempDir()); err == nil {
+		t.Errorf("Expected error for directory without seed files")
+	}
+}
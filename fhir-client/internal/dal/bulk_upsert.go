@@ -0,0 +1,329 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/rs/zerolog/log"
+	"stealthcompany.com/fhir-client/internal/metrics"
+)
+
+// BulkError is the failure of one document of a BulkUpsert
+type BulkError struct {
+	DocID string
+	Err   error
+}
+
+// Error implements the error interface
+func (e BulkError) Error() string {
+	return fmt.Sprintf("%s: %v", e.DocID, e.Err)
+}
+
+// Unwrap returns the error of the document
+func (e BulkError) Unwrap() error {
+	return e.Err
+}
+
+// bulkExecutor is the part of gocb.Collection used by bulk operations
+type bulkExecutor interface {
+	Do(ops []gocb.BulkOp, opts *gocb.BulkOpOptions) error
+}
+
+// BulkUpsert upserts many FHIR resources with one bulk operation per collection, the collections running concurrently.
+// Review state, deduplication and the size limit are handled like UpsertResource, and encounters get their
+// denormalized fields in the same write. Failed documents are returned in failed; err is set when a whole
+// collection could not be written.
+func (rm *ResourceModel) BulkUpsert(ctx context.Context, docs map[string]interface{}) (succeeded int, failed []BulkError, err error) {
+	if len(docs) == 0 {
+		return 0, nil, nil
+	}
+
+	// Create collections and indexes on first upsert, unless Client.Init already did
+	if err := rm.EnsureCollectionsAndIndexes(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to create collections and indexes, continuing with bulk upsert")
+	}
+
+	batches, failed := partitionByCollection(docs)
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+	for collectionName, batch := range batches {
+		collection := rm.conn.bucket.Scope(rm.tenantScope).Collection(collectionName)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			batchSucceeded, batchFailed, batchErr := bulkUpsertCollection(ctx, rm.tenantID(), collection, batch)
+
+			mu.Lock()
+			defer mu.Unlock()
+			succeeded += batchSucceeded
+			failed = append(failed, batchFailed...)
+			if batchErr != nil {
+				errs = append(errs, fmt.Errorf("bulk upsert to %s failed: %w", collectionName, batchErr))
+			}
+		}()
+	}
+	wg.Wait()
+
+	log.Debug().
+		Int("documents", len(docs)).
+		Int("succeeded", succeeded).
+		Int("failed", len(failed)).
+		Msg("Bulk upsert completed")
+	return succeeded, failed, errors.Join(errs...)
+}
+
+// partitionByCollection groups the documents by collection name. Documents of an unknown resource type,
+// or that are not JSON objects, are returned as failures.
+func partitionByCollection(docs map[string]interface{}) (map[string]map[string]map[string]interface{}, []BulkError) {
+	batches := make(map[string]map[string]map[string]interface{})
+	var failed []BulkError
+
+	for docID, doc := range docs {
+		data, ok := doc.(map[string]interface{})
+		if !ok {
+			failed = append(failed, BulkError{DocID: docID, Err: fmt.Errorf("unsupported document type %T", doc)})
+			continue
+		}
+
+		collectionName, err := collectionNameForResource(docID)
+		if err != nil {
+			failed = append(failed, BulkError{DocID: docID, Err: err})
+			continue
+		}
+
+		if batches[collectionName] == nil {
+			batches[collectionName] = make(map[string]map[string]interface{})
+		}
+		batches[collectionName][docID] = data
+	}
+
+	return batches, failed
+}
+
+// bulkUpsertCollection reads the stored review fields of a batch with one bulk get, then writes it with one bulk upsert.
+// Documents whose stored fields can't be read are not written and are returned in failed.
+func bulkUpsertCollection(ctx context.Context, tenantID string, collection bulkExecutor, docs map[string]map[string]interface{}) (succeeded int, failed []BulkError, err error) {
+	docIDs := make([]string, 0, len(docs))
+	for docID := range docs {
+		docIDs = append(docIDs, docID)
+	}
+	sort.Strings(docIDs)
+
+	existing, failed, err := getExistingFieldsBulk(ctx, collection, docIDs)
+	if err != nil {
+		metrics.RecordCouchbaseOperations(tenantID, "upsert", "error", len(docIDs))
+		return 0, nil, err
+	}
+	unreadable := make(map[string]bool, len(failed))
+	for _, f := range failed {
+		unreadable[f.DocID] = true
+	}
+	metrics.RecordCouchbaseOperations(tenantID, "upsert", "error", len(failed))
+
+	deduplicate := isDeduplicateEnabled()
+	ops := make([]gocb.BulkOp, 0, len(docIDs))
+	for _, docID := range docIDs {
+		if unreadable[docID] {
+			continue
+		}
+		data := docs[docID]
+
+		// Hash the content before the stored fields are added
+		var hash string
+		if deduplicate {
+			hash, err = contentHash(data)
+			if err != nil {
+				log.Warn().Err(err).Str("doc_id", docID).Msg("Failed to hash resource content, upserting without deduplication")
+			}
+		}
+
+		if hash != "" {
+			if existing[docID][contentHashPath] == hash {
+				metrics.RecordDedupSkip(strings.Split(docID, "/")[0])
+				succeeded++
+				continue
+			}
+			setContentHash(data, hash)
+		}
+		applyReviewFields(data, existing[docID])
+
+		// Encounters are written with their denormalized fields instead of merging them afterwards
+		if strings.HasPrefix(docID, "Encounter/") {
+			for field, value := range denormalizedFields(docID, data) {
+				data[field] = value
+			}
+		}
+
+		if err := checkResourceSize(docID, data); err != nil {
+			failed = append(failed, BulkError{DocID: docID, Err: err})
+			continue
+		}
+
+		ops = append(ops, &gocb.UpsertOp{ID: docID, Value: data})
+	}
+
+	if len(ops) == 0 {
+		return succeeded, failed, nil
+	}
+
+	start := time.Now()
+	err = retryBulkUpsert(ctx, collection, ops, getMaxRetry())
+	metrics.RecordCouchbaseOperationDuration(tenantID, "bulk_upsert", time.Since(start))
+	if err != nil {
+		metrics.RecordCouchbaseOperations(tenantID, "upsert", "error", len(ops))
+		return succeeded, failed, err
+	}
+
+	upserted := 0
+	for _, op := range ops {
+		upsertOp := op.(*gocb.UpsertOp)
+		if upsertOp.Err != nil {
+			failed = append(failed, BulkError{DocID: upsertOp.ID, Err: fmt.Errorf("failed to upsert resource %s: %w", upsertOp.ID, upsertOp.Err)})
+			continue
+		}
+		upserted++
+	}
+
+	metrics.RecordCouchbaseOperations(tenantID, "upsert", "success", upserted)
+	metrics.RecordCouchbaseOperations(tenantID, "upsert", "error", len(ops)-upserted)
+	return succeeded + upserted, failed, nil
+}
+
+// retryBulkUpsert runs the bulk upserts, then retries the documents that failed with a transient error up to
+// maxRetries times with exponential backoff, like retryUpsert. Documents still failing keep their error in the op.
+func retryBulkUpsert(ctx context.Context, collection bulkExecutor, ops []gocb.BulkOp, maxRetries int) error {
+	pending := ops
+	delay := upsertRetryBaseDelay
+	for attempt := 0; ; attempt++ {
+		if err := collection.Do(pending, &gocb.BulkOpOptions{Context: ctx}); err != nil {
+			return err
+		}
+
+		var transient []gocb.BulkOp
+		for _, op := range pending {
+			if isTransientError(op.(*gocb.UpsertOp).Err) {
+				transient = append(transient, op)
+			}
+		}
+		if len(transient) == 0 || attempt >= maxRetries {
+			return nil
+		}
+
+		metrics.RecordCouchbaseUpsertRetry(attempt + 1)
+		log.Warn().
+			Int("documents", len(transient)).
+			Int("retry", attempt+1).
+			Int("max_retries", maxRetries).
+			Dur("backoff", delay).
+			Msg("Transient Couchbase errors on bulk upsert, retrying")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay *= 2
+
+		for _, op := range transient {
+			op.(*gocb.UpsertOp).Err = nil
+		}
+		pending = transient
+	}
+}
+
+// getExistingFieldsBulk reads the review fields and content hash of already stored documents with one bulk get.
+// A document that can't be read or decoded is returned in failed, so its recorded review is never overwritten,
+// as in UpsertResource; a missing document starts as not reviewed.
+func getExistingFieldsBulk(ctx context.Context, collection bulkExecutor, docIDs []string) (existing map[string]map[string]interface{}, failed []BulkError, err error) {
+	ops := make([]gocb.BulkOp, len(docIDs))
+	for i, docID := range docIDs {
+		ops[i] = &gocb.GetOp{ID: docID}
+	}
+
+	if err := collection.Do(ops, &gocb.BulkOpOptions{Context: ctx}); err != nil {
+		return nil, nil, fmt.Errorf("failed to read existing documents: %w", err)
+	}
+
+	existing = make(map[string]map[string]interface{}, len(docIDs))
+	for _, op := range ops {
+		getOp := op.(*gocb.GetOp)
+		if errors.Is(getOp.Err, gocb.ErrDocumentNotFound) {
+			continue
+		}
+		if getOp.Err != nil {
+			failed = append(failed, BulkError{DocID: getOp.ID, Err: fmt.Errorf("failed to read existing review fields: %w", getOp.Err)})
+			continue
+		}
+
+		var doc map[string]interface{}
+		if err := getOp.Result.Content(&doc); err != nil {
+			failed = append(failed, BulkError{DocID: getOp.ID, Err: fmt.Errorf("failed to decode existing document: %w", err)})
+			continue
+		}
+		existing[getOp.ID] = existingFieldsFromDocument(doc)
+	}
+
+	return existing, failed, nil
+}
+
+// existingFieldsFromDocument picks the fields getExistingFields reads by sub-document lookup from a full document
+func existingFieldsFromDocument(doc map[string]interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+
+	for _, field := range reviewStateFields {
+		if value, ok := doc[field]; ok && value != nil {
+			fields[field] = value
+		}
+	}
+	if meta, ok := doc["_meta"].(map[string]interface{}); ok {
+		if hash, ok := meta["contentHash"].(string); ok {
+			fields[contentHashPath] = hash
+		}
+	}
+
+	return fields
+}
+
+// bulkUpserter is ResourceModel.BulkUpsert, replaced in tests
+type bulkUpserter func(ctx context.Context, docs map[string]interface{}) (succeeded int, failed []BulkError, err error)
+
+// bulkUpsertResources prepares the resources of one type with prepare, the validation and denormalization of its
+// Upsert method, then writes them with a single BulkUpsert. It returns the failures by resource ID; when the whole
+// bulk write fails every prepared resource fails with its error.
+func bulkUpsertResources(ctx context.Context, upsert bulkUpserter, resourceType string, resources map[string]map[string]interface{},
+	prepare func(id string, data map[string]interface{}) error) map[string]error {
+	failures := make(map[string]error)
+	docs := make(map[string]interface{}, len(resources))
+	for id, data := range resources {
+		if err := prepare(id, data); err != nil {
+			failures[id] = err
+			continue
+		}
+		docs[resourceType+"/"+id] = data
+	}
+
+	_, failed, err := upsert(ctx, docs)
+	for _, f := range failed {
+		failures[strings.TrimPrefix(f.DocID, resourceType+"/")] = f
+	}
+	if err != nil {
+		for docID := range docs {
+			id := strings.TrimPrefix(docID, resourceType+"/")
+			if _, ok := failures[id]; !ok {
+				failures[id] = err
+			}
+		}
+	}
+	return failures
+}
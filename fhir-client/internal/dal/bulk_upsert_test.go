@@ -0,0 +1,245 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// fakeBulkCollection serves bulk gets as missing documents, or as getErrs, and fails the upserts of failIDs
+type fakeBulkCollection struct {
+	failIDs  map[string]error
+	getErrs  map[string]error
+	upserted map[string]interface{}
+	attempts map[string]int
+	doErr    error
+}
+
+func (f *fakeBulkCollection) Do(ops []gocb.BulkOp, opts *gocb.BulkOpOptions) error {
+	if f.doErr != nil {
+		return f.doErr
+	}
+	for _, op := range ops {
+		switch op := op.(type) {
+		case *gocb.GetOp:
+			op.Err = gocb.ErrDocumentNotFound
+			if err, ok := f.getErrs[op.ID]; ok {
+				op.Err = err
+			}
+		case *gocb.UpsertOp:
+			if f.attempts == nil {
+				f.attempts = make(map[string]int)
+			}
+			f.attempts[op.ID]++
+			if err, ok := f.failIDs[op.ID]; ok {
+				op.Err = err
+				continue
+			}
+			if f.upserted == nil {
+				f.upserted = make(map[string]interface{})
+			}
+			f.upserted[op.ID] = op.Value
+		}
+	}
+	return nil
+}
+
+func TestPartitionByCollection(t *testing.T) {
+	docs := map[string]interface{}{
+		"Encounter/1":    map[string]interface{}{"id": "1"},
+		"Encounter/2":    map[string]interface{}{"id": "2"},
+		"Patient/1":      map[string]interface{}{"id": "1"},
+		"Practitioner/1": map[string]interface{}{"id": "1"},
+		"Medication/1":   map[string]interface{}{"id": "1"},
+		"Patient/2":      "not an object",
+	}
+
+	batches, failed := partitionByCollection(docs)
+
+	if len(batches["encounters"]) != 2 || len(batches["patients"]) != 1 || len(batches["practitioners"]) != 1 {
+		t.Errorf("Unexpected batches: %v", batches)
+	}
+	if len(failed) != 2 {
+		t.Fatalf("Expected 2 failed documents, got %v", failed)
+	}
+	for _, f := range failed {
+		if f.DocID != "Medication/1" && f.DocID != "Patient/2" {
+			t.Errorf("Unexpected failed document %s", f.DocID)
+		}
+	}
+}
+
+func TestBulkUpsertCollection(t *testing.T) {
+	t.Setenv("FHIR_MAX_RESOURCE_SIZE_BYTES", "1024")
+
+	upsertErr := errors.New("temporary failure")
+	collection := &fakeBulkCollection{failIDs: map[string]error{"Encounter/2": upsertErr}}
+	docs := map[string]map[string]interface{}{
+		"Encounter/1": {
+			"id":          "1",
+			"subject":     map[string]interface{}{"reference": "Patient/1"},
+			"participant": []interface{}{map[string]interface{}{"individual": map[string]interface{}{"reference": "Practitioner/1"}}},
+		},
+		"Encounter/2": {"id": "2"},
+		"Encounter/3": {"id": "3", "text": strings.Repeat("a", 2048)},
+	}
+
+	succeeded, failed, err := bulkUpsertCollection(context.Background(), "", collection, docs)
+	if err != nil {
+		t.Fatalf("bulkUpsertCollection() error = %v", err)
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected 1 succeeded, got %d", succeeded)
+	}
+	if len(failed) != 2 {
+		t.Fatalf("Expected 2 failed documents, got %v", failed)
+	}
+
+	failedErrs := make(map[string]error)
+	for _, f := range failed {
+		failedErrs[f.DocID] = f.Err
+	}
+	if !errors.Is(failedErrs["Encounter/2"], upsertErr) {
+		t.Errorf("Expected upsert error for Encounter/2, got %v", failedErrs["Encounter/2"])
+	}
+	if !errors.Is(failedErrs["Encounter/3"], ErrResourceTooLarge) {
+		t.Errorf("Expected ErrResourceTooLarge for Encounter/3, got %v", failedErrs["Encounter/3"])
+	}
+
+	stored, _ := collection.upserted["Encounter/1"].(map[string]interface{})
+	if stored["reviewed"] != false {
+		t.Errorf("Expected new resource to start as not reviewed, got %v", stored["reviewed"])
+	}
+	if stored["docId"] != "Encounter/1" || stored["subjectPatientId"] != "Patient/1" {
+		t.Errorf("Expected denormalized fields, got %v", stored)
+	}
+	if ids, _ := stored["practitionerIds"].([]string); len(ids) != 1 || ids[0] != "Practitioner/1" {
+		t.Errorf("Expected practitionerIds [Practitioner/1], got %v", stored["practitionerIds"])
+	}
+}
+
+func TestBulkUpsertCollectionRetriesTransientErrors(t *testing.T) {
+	t.Setenv("COUCHBASE_MAX_RETRY", "2")
+	origDelay := upsertRetryBaseDelay
+	upsertRetryBaseDelay = time.Millisecond
+	t.Cleanup(func() {
+		upsertRetryBaseDelay = origDelay
+	})
+
+	collection := &fakeBulkCollection{failIDs: map[string]error{
+		"Patient/2": gocb.ErrTemporaryFailure,
+		"Patient/3": errors.New("permanent failure"),
+	}}
+
+	succeeded, failed, err := bulkUpsertCollection(context.Background(), "", collection, map[string]map[string]interface{}{
+		"Patient/1": {"id": "1"},
+		"Patient/2": {"id": "2"},
+		"Patient/3": {"id": "3"},
+	})
+	if err != nil {
+		t.Fatalf("bulkUpsertCollection() error = %v", err)
+	}
+	if succeeded != 1 || len(failed) != 2 {
+		t.Errorf("Expected 1 succeeded and 2 failed, got %d and %v", succeeded, failed)
+	}
+	if got := collection.attempts; got["Patient/1"] != 1 || got["Patient/2"] != 3 || got["Patient/3"] != 1 {
+		t.Errorf("Expected only the transient failure to be retried twice, got attempts %v", got)
+	}
+}
+
+func TestBulkUpsertCollectionUnreadableDocument(t *testing.T) {
+	collection := &fakeBulkCollection{getErrs: map[string]error{"Patient/2": gocb.ErrTimeout}}
+
+	succeeded, failed, err := bulkUpsertCollection(context.Background(), "", collection, map[string]map[string]interface{}{
+		"Patient/1": {"id": "1"},
+		"Patient/2": {"id": "2"},
+	})
+	if err != nil {
+		t.Fatalf("bulkUpsertCollection() error = %v", err)
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected 1 succeeded, got %d", succeeded)
+	}
+	if len(failed) != 1 || failed[0].DocID != "Patient/2" || !errors.Is(failed[0].Err, gocb.ErrTimeout) {
+		t.Fatalf("Expected Patient/2 to fail with its read error, got %v", failed)
+	}
+	if _, ok := collection.upserted["Patient/2"]; ok {
+		t.Error("Expected a document whose review fields can't be read not to be overwritten")
+	}
+}
+
+func TestBulkUpsertCollectionDoError(t *testing.T) {
+	doErr := errors.New("bulk operation failed")
+	collection := &fakeBulkCollection{doErr: doErr}
+
+	succeeded, _, err := bulkUpsertCollection(context.Background(), "", collection, map[string]map[string]interface{}{
+		"Patient/1": {"id": "1"},
+	})
+	if !errors.Is(err, doErr) {
+		t.Errorf("Expected bulk operation error, got %v", err)
+	}
+	if succeeded != 0 {
+		t.Errorf("Expected 0 succeeded, got %d", succeeded)
+	}
+}
+
+func TestExistingFieldsFromDocument(t *testing.T) {
+	fields := existingFieldsFromDocument(map[string]interface{}{
+		"id":         "1",
+		"reviewed":   true,
+		"reviewTime": "2025-01-01T10:00:00Z",
+		"_meta":      map[string]interface{}{"contentHash": "abc"},
+	})
+
+	if fields["reviewed"] != true || fields["reviewTime"] != "2025-01-01T10:00:00Z" || fields[contentHashPath] != "abc" {
+		t.Errorf("Unexpected existing fields: %v", fields)
+	}
+	if _, ok := fields["id"]; ok {
+		t.Errorf("Expected only review fields and content hash, got %v", fields)
+	}
+}
+
+func TestBulkUpsertResources(t *testing.T) {
+	invalidErr := errors.New("invalid resource")
+	upsertErr := errors.New("temporary failure")
+	prepare := func(id string, data map[string]interface{}) error {
+		if id == "invalid" {
+			return invalidErr
+		}
+		data["docId"] = "Patient/" + id
+		return nil
+	}
+
+	var written map[string]interface{}
+	upsert := func(ctx context.Context, docs map[string]interface{}) (int, []BulkError, error) {
+		written = docs
+		return len(docs) - 1, []BulkError{{DocID: "Patient/2", Err: upsertErr}}, nil
+	}
+
+	failures := bulkUpsertResources(context.Background(), upsert, "Patient", map[string]map[string]interface{}{
+		"1":       {"id": "1"},
+		"2":       {"id": "2"},
+		"invalid": {"id": "invalid"},
+	}, prepare)
+
+	if len(written) != 2 || written["Patient/1"] == nil || written["Patient/2"] == nil {
+		t.Errorf("Expected the prepared resources to be written by document ID, got %v", written)
+	}
+	if len(failures) != 2 || !errors.Is(failures["invalid"], invalidErr) || !errors.Is(failures["2"], upsertErr) {
+		t.Errorf("Expected the preparation and upsert failures by resource ID, got %v", failures)
+	}
+
+	// A failed bulk write fails every prepared resource
+	bulkErr := errors.New("bulk upsert to patients failed")
+	failures = bulkUpsertResources(context.Background(), func(ctx context.Context, docs map[string]interface{}) (int, []BulkError, error) {
+		return 0, nil, bulkErr
+	}, "Patient", map[string]map[string]interface{}{"1": {"id": "1"}, "invalid": {"id": "invalid"}}, prepare)
+
+	if !errors.Is(failures["1"], bulkErr) || !errors.Is(failures["invalid"], invalidErr) {
+		t.Errorf("Expected the bulk error for the prepared resource, got %v", failures)
+	}
+}
@@ -0,0 +1,78 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// CheckpointKeyPrefix is the document key prefix of the per-resource-type ingestion checkpoints
+const CheckpointKeyPrefix = "checkpoint/"
+
+// IngestionCheckpoint records when a resource type was last fully synced from the FHIR server
+type IngestionCheckpoint struct {
+	ResourceType string    `json:"resourceType"`
+	LastSyncedAt time.Time `json:"lastSyncedAt"`
+}
+
+// IngestionCheckpointModel represents the database model for ingestion checkpoints
+type IngestionCheckpointModel struct {
+	conn        *Connection
+	tenantScope string
+}
+
+// NewIngestionCheckpointModel creates a new ingestion checkpoint model
+func NewIngestionCheckpointModel(conn *Connection) *IngestionCheckpointModel {
+	return &IngestionCheckpointModel{
+		conn:        conn,
+		tenantScope: DefaultScope,
+	}
+}
+
+// NewIngestionCheckpointModelWithTenant creates a new ingestion checkpoint model for the checkpoints of a tenant scope
+func NewIngestionCheckpointModelWithTenant(conn *Connection, tenantScope string) *IngestionCheckpointModel {
+	return &IngestionCheckpointModel{
+		conn:        conn,
+		tenantScope: tenantScope,
+	}
+}
+
+// GetCheckpoint returns the last sync time of a resource type, or the zero time when it was never synced
+func (icm *IngestionCheckpointModel) GetCheckpoint(ctx context.Context, resourceType string) (time.Time, error) {
+	collection := icm.conn.stateCollection(icm.tenantScope)
+
+	result, err := collection.Get(CheckpointKeyPrefix+resourceType, &gocb.GetOptions{Context: ctx})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get %s checkpoint: %w", resourceType, err)
+	}
+
+	var checkpoint IngestionCheckpoint
+	if err := result.Content(&checkpoint); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse %s checkpoint: %w", resourceType, err)
+	}
+	return checkpoint.LastSyncedAt, nil
+}
+
+// SetCheckpoint stores the last sync time of a resource type under checkpoint/{resourceType}
+func (icm *IngestionCheckpointModel) SetCheckpoint(ctx context.Context, resourceType string, lastSyncedAt time.Time) error {
+	collection := icm.conn.stateCollection(icm.tenantScope)
+	checkpoint := IngestionCheckpoint{ResourceType: resourceType, LastSyncedAt: lastSyncedAt.UTC()}
+
+	_, err := collection.Upsert(CheckpointKeyPrefix+resourceType, checkpoint, &gocb.UpsertOptions{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("failed to set %s checkpoint: %w", resourceType, err)
+	}
+
+	log.Info().
+		Str("resource_type", resourceType).
+		Time("last_synced_at", checkpoint.LastSyncedAt).
+		Msg("Ingestion checkpoint advanced")
+	return nil
+}
@@ -0,0 +1,79 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+
+	"stealthcompany.com/fhir-client/internal/metrics"
+	"stealthcompany.com/pkg/fhirutil"
+)
+
+// ConditionModel handles condition-specific database operations
+type ConditionModel struct {
+	resourceModel *ResourceModel
+}
+
+// NewConditionModel creates a new condition model
+func NewConditionModel(resourceModel *ResourceModel) *ConditionModel {
+	return &ConditionModel{
+		resourceModel: resourceModel,
+	}
+}
+
+// UpsertCondition upserts a condition resource
+func (cm *ConditionModel) UpsertCondition(ctx context.Context, conditionID string, data map[string]interface{}) error {
+	if err := prepareCondition(conditionID, data); err != nil {
+		return err
+	}
+	return cm.resourceModel.UpsertResource(ctx, fmt.Sprintf("Condition/%s", conditionID), data)
+}
+
+// BulkUpsertConditions upserts conditions by ID with a single bulk write and returns the failures by condition ID
+func (cm *ConditionModel) BulkUpsertConditions(ctx context.Context, conditions map[string]map[string]interface{}) map[string]error {
+	return bulkUpsertResources(ctx, cm.resourceModel.BulkUpsert, "Condition", conditions, prepareCondition)
+}
+
+// prepareCondition validates a condition and adds its query fields
+func prepareCondition(conditionID string, data map[string]interface{}) error {
+	if err := validateResource("Condition", conditionID, data, isStrictValidation()); err != nil {
+		return err
+	}
+	denormalizeCondition(fmt.Sprintf("Condition/%s", conditionID), data)
+	return nil
+}
+
+// GetCondition retrieves a condition by ID
+func (cm *ConditionModel) GetCondition(ctx context.Context, conditionID string) (map[string]interface{}, error) {
+	docID := fmt.Sprintf("Condition/%s", conditionID)
+	return cm.resourceModel.GetResource(ctx, docID)
+}
+
+// ConditionExists checks if a condition exists
+func (cm *ConditionModel) ConditionExists(ctx context.Context, conditionID string) (bool, error) {
+	docID := fmt.Sprintf("Condition/%s", conditionID)
+	return cm.resourceModel.ResourceExists(ctx, docID)
+}
+
+// CountConditions counts all conditions
+func (cm *ConditionModel) CountConditions(ctx context.Context) (int64, error) {
+	return cm.resourceModel.CountResourcesByType(ctx, "Condition")
+}
+
+// denormalizeCondition adds the fields conditions are queried by to the stored document
+func denormalizeCondition(docID string, data map[string]interface{}) {
+	data["docId"] = docID
+	data["resourceType"] = "Condition"
+
+	// Extract and add patient and encounter references (bare IDs)
+	if patientRef := fhirutil.ExtractPatientRef(data, metrics.RecordReferenceParse); patientRef != "" {
+		data["subjectPatientId"] = patientRef
+	}
+	if encounterRef := fhirutil.ExtractEncounterRef(data, metrics.RecordReferenceParse); encounterRef != "" {
+		data["encounterId"] = encounterRef
+	}
+
+	// Like observations, the code CodeableConcept keeps its shape and its first coding is stored flat as conditionCode
+	if codes := codingCodes(data); len(codes) > 0 {
+		data["conditionCode"] = codes[0]
+	}
+}
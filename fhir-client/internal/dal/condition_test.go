@@ -0,0 +1,52 @@
+package dal
+
+import "testing"
+
+func TestDenormalizeCondition(t *testing.T) {
+	data := map[string]interface{}{
+		"resourceType": "Condition",
+		"id":           "c-1",
+		"subject":      map[string]interface{}{"reference": "Patient/p-1"},
+		"encounter":    map[string]interface{}{"reference": "Encounter/e-1"},
+		"code": map[string]interface{}{
+			"coding": []interface{}{
+				map[string]interface{}{"system": "http://snomed.info/sct", "code": "44054006"},
+				map[string]interface{}{"system": "http://hl7.org/fhir/sid/icd-10", "code": "E11.9"},
+			},
+		},
+	}
+
+	denormalizeCondition("Condition/c-1", data)
+
+	expected := map[string]string{
+		"docId":            "Condition/c-1",
+		"resourceType":     "Condition",
+		"subjectPatientId": "p-1",
+		"encounterId":      "e-1",
+		"conditionCode":    "44054006",
+	}
+	for field, want := range expected {
+		if got, _ := data[field].(string); got != want {
+			t.Errorf("Expected %s %q, got %q", field, want, got)
+		}
+	}
+	if _, ok := data["code"].(map[string]interface{}); !ok {
+		t.Errorf("Expected code to keep its CodeableConcept shape, got %v", data["code"])
+	}
+}
+
+func TestDenormalizeConditionWithoutReferences(t *testing.T) {
+	data := map[string]interface{}{
+		"resourceType": "Condition",
+		"id":           "c-2",
+		"subject":      map[string]interface{}{"reference": "Group/g-1"},
+	}
+
+	denormalizeCondition("Condition/c-2", data)
+
+	for _, field := range []string{"subjectPatientId", "encounterId", "conditionCode"} {
+		if value, ok := data[field]; ok {
+			t.Errorf("Expected no %s, got %v", field, value)
+		}
+	}
+}
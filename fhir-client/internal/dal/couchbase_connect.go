@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/couchbase/gocb/v2"
 	"github.com/rs/zerolog/log"
+	"stealthcompany.com/fhir-client/internal/metrics"
+	"stealthcompany.com/pkg/couchbaseping"
 )
 
 // Connection represents the Couchbase connection
@@ -18,10 +21,75 @@ type Connection struct {
 	bucketName string
 }
 
+// ConnectionPoolConfig sizes the connection pool and sets how long idle connections are kept open
+type ConnectionPoolConfig struct {
+	// MinIdle is how many idle connections are reopened when eviction leaves fewer in the pool
+	MinIdle int
+	// MaxSize caps the idle connections kept in the pool; connections returned to a full pool are closed
+	MaxSize int
+	// IdleTimeout closes connections left unused in the pool for longer
+	IdleTimeout time.Duration
+	// HealthCheckInterval is how often idle connections are pinged
+	HealthCheckInterval time.Duration
+}
+
+// Default connection pool settings
+const (
+	DefaultPoolMinIdle             = 0
+	DefaultPoolMaxSize             = 5
+	DefaultPoolIdleTimeout         = 5 * time.Minute
+	DefaultPoolHealthCheckInterval = 30 * time.Second
+	// MinPoolInterval is the shortest idle timeout and health check interval; shorter values are raised to it
+	// so the maintenance tickers neither spin nor panic on a zero tick
+	MinPoolInterval = time.Second
+)
+
+// ConnectionPoolConfigFromEnv reads COUCHBASE_POOL_MIN_IDLE, COUCHBASE_POOL_MAX_SIZE, COUCHBASE_POOL_IDLE_TIMEOUT
+// and COUCHBASE_POOL_HEALTH_CHECK_INTERVAL, using the default of each missing or invalid value.
+// Durations below MinPoolInterval are raised to it.
+func ConnectionPoolConfigFromEnv() ConnectionPoolConfig {
+	cfg := ConnectionPoolConfig{
+		MinIdle:             DefaultPoolMinIdle,
+		MaxSize:             DefaultPoolMaxSize,
+		IdleTimeout:         DefaultPoolIdleTimeout,
+		HealthCheckInterval: DefaultPoolHealthCheckInterval,
+	}
+
+	if minIdle, err := strconv.Atoi(os.Getenv("COUCHBASE_POOL_MIN_IDLE")); err == nil && minIdle >= 0 {
+		cfg.MinIdle = minIdle
+	}
+	if maxSize, err := strconv.Atoi(os.Getenv("COUCHBASE_POOL_MAX_SIZE")); err == nil && maxSize > 0 {
+		cfg.MaxSize = maxSize
+	}
+	if idleTimeout, err := time.ParseDuration(os.Getenv("COUCHBASE_POOL_IDLE_TIMEOUT")); err == nil && idleTimeout > 0 {
+		cfg.IdleTimeout = max(idleTimeout, MinPoolInterval)
+	}
+	if interval, err := time.ParseDuration(os.Getenv("COUCHBASE_POOL_HEALTH_CHECK_INTERVAL")); err == nil && interval > 0 {
+		cfg.HealthCheckInterval = max(interval, MinPoolInterval)
+	}
+
+	// Connections reopened for MinIdle must fit in the pool
+	if cfg.MinIdle > cfg.MaxSize {
+		cfg.MinIdle = cfg.MaxSize
+	}
+	return cfg
+}
+
+// idleConnection is a connection waiting in the pool
+type idleConnection struct {
+	conn *Connection
+	// returnedAt is when the connection was last returned to the pool
+	returnedAt time.Time
+	// checkedAt is when the connection was last found alive
+	checkedAt time.Time
+}
+
 // ConnectionPool manages a pool of Couchbase connections
 type ConnectionPool struct {
-	connections chan *Connection
-	maxSize     int
+	connections chan *idleConnection
+	config      ConnectionPoolConfig
+	stop        chan struct{}
+	stopOnce    sync.Once
 }
 
 var (
@@ -29,61 +97,158 @@ var (
 	poolOnce sync.Once
 )
 
-// GetConnOrGenConn gets a connection from the pool or creates a new one
-func GetConnOrGenConn() (*Connection, error) {
+// Overridable in tests
+var (
+	connectionAlive = isConnectionAlive
+	newConnection   = createNewConnection
+)
+
+// newConnectionPool creates a pool keeping up to cfg.MaxSize idle connections
+func newConnectionPool(cfg ConnectionPoolConfig) *ConnectionPool {
+	return &ConnectionPool{
+		connections: make(chan *idleConnection, cfg.MaxSize),
+		config:      cfg,
+		stop:        make(chan struct{}),
+	}
+}
+
+// getPool returns the process-wide connection pool, starting its maintenance on first use
+func getPool() *ConnectionPool {
 	poolOnce.Do(func() {
-		pool = &ConnectionPool{
-			connections: make(chan *Connection, 5), // Pool of 5 connections
-			maxSize:     5,
-		}
+		pool = newConnectionPool(ConnectionPoolConfigFromEnv())
+		go pool.maintain()
 	})
+	return pool
+}
+
+// GetConnOrGenConn gets a connection from the pool or creates a new one
+func GetConnOrGenConn() (*Connection, error) {
+	p := getPool()
 
 	// Try to get connection from pool
 	select {
-	case conn := <-pool.connections:
-		// Test if connection is still alive
-		if isConnectionAlive(conn) {
-			return conn, nil
+	case idle := <-p.connections:
+		// Connections found alive within the health check interval are handed out without a ping
+		if time.Since(idle.checkedAt) < p.config.HealthCheckInterval || connectionAlive(idle.conn) {
+			return idle.conn, nil
 		}
 		// Connection is dead, create a new one
-		return createNewConnection()
+		idle.conn.Close()
+		return newConnection()
 	default:
 		// Pool is empty, create new connection
-		return createNewConnection()
+		return newConnection()
 	}
 }
 
-// ReturnConnection returns a connection to the pool
+// ReturnConnection returns a connection to the pool, where it is closed after the idle timeout
 func ReturnConnection(conn *Connection) {
 	if conn == nil {
 		return
 	}
 
 	// Test if connection is still alive
-	if !isConnectionAlive(conn) {
+	if !connectionAlive(conn) {
 		// Connection is dead, don't return it to pool
+		conn.Close()
+		return
+	}
+
+	now := time.Now()
+	getPool().put(&idleConnection{conn: conn, returnedAt: now, checkedAt: now})
+}
+
+// put adds an idle connection to the pool, closing it when the pool is full or stopped
+func (p *ConnectionPool) put(idle *idleConnection) {
+	select {
+	case <-p.stop:
+		idle.conn.Close()
 		return
+	default:
 	}
 
 	// Try to return to pool
 	select {
-	case pool.connections <- conn:
+	case p.connections <- idle:
 		// Successfully returned to pool
 	default:
 		// Pool is full, close the connection
-		conn.Close()
+		idle.conn.Close()
+	}
+}
+
+// maintain evicts idle connections until the pool is stopped. Eviction runs every half IdleTimeout,
+// so a connection is closed within twice IdleTimeout of its return.
+func (p *ConnectionPool) maintain() {
+	evictTicker := time.NewTicker(p.config.IdleTimeout / 2)
+	defer evictTicker.Stop()
+	healthTicker := time.NewTicker(p.config.HealthCheckInterval)
+	defer healthTicker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-evictTicker.C:
+			p.sweep(false)
+		case <-healthTicker.C:
+			p.sweep(true)
+		}
+	}
+}
+
+// sweep closes the idle connections past IdleTimeout, and the ones failing a ping when checkHealth is set,
+// then reopens connections until MinIdle are idle
+func (p *ConnectionPool) sweep(checkHealth bool) {
+	now := time.Now()
+
+	// Only the connections idle when the sweep starts are examined, the others are being used or returned
+	for i := len(p.connections); i > 0; i-- {
+		var idle *idleConnection
+		select {
+		case idle = <-p.connections:
+		default:
+		}
+		if idle == nil {
+			break
+		}
+
+		if now.Sub(idle.returnedAt) > p.config.IdleTimeout {
+			log.Debug().Dur("idle_timeout", p.config.IdleTimeout).Msg("Closing idle Couchbase connection")
+			idle.conn.Close()
+			continue
+		}
+		if checkHealth {
+			if !connectionAlive(idle.conn) {
+				log.Warn().Msg("Closing idle Couchbase connection that failed its health check")
+				idle.conn.Close()
+				continue
+			}
+			idle.checkedAt = now
+		}
+		p.put(idle)
+	}
+
+	for len(p.connections) < p.config.MinIdle {
+		conn, err := newConnection()
+		if err != nil {
+			log.Warn().Err(err).Int("min_idle", p.config.MinIdle).Msg("Failed to reopen idle Couchbase connection")
+			return
+		}
+		p.put(&idleConnection{conn: conn, returnedAt: now, checkedAt: now})
 	}
 }
 
+// connectionPingTimeout bounds the liveness ping so an unresponsive cluster can't block connection acquisition
+var connectionPingTimeout = 2 * time.Second
+
 // isConnectionAlive tests if a connection is still usable
 func isConnectionAlive(conn *Connection) bool {
 	if conn == nil || conn.cluster == nil {
 		return false
 	}
 
-	// Try a simple operation to test the connection
-	_, err := conn.cluster.Ping(&gocb.PingOptions{})
-	return err == nil
+	return couchbaseping.WithTimeout(conn.cluster, connectionPingTimeout, metrics.RecordCouchbasePing)
 }
 
 // createNewConnection creates a fresh Couchbase connection
@@ -91,24 +256,31 @@ func createNewConnection() (*Connection, error) {
 	return getConnOrGenConn()
 }
 
-// CloseAllConnections closes all connections in the pool (for graceful shutdown)
+// CloseAllConnections stops the pool maintenance and closes all connections in the pool (for graceful shutdown)
 func CloseAllConnections() {
 	if pool == nil {
 		return
 	}
 
 	log.Info().Msg("Closing all connections in pool...")
+	pool.close()
+	log.Info().Msg("All connections closed")
+}
+
+// close stops the maintenance goroutine and closes the idle connections;
+// connections returned afterwards are closed instead of pooled
+func (p *ConnectionPool) close() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
 
 	// Close all connections in the pool
 	for {
 		select {
-		case conn := <-pool.connections:
-			if conn != nil {
-				conn.Close()
-			}
+		case idle := <-p.connections:
+			idle.conn.Close()
 		default:
 			// Pool is empty
-			log.Info().Msg("All connections closed")
 			return
 		}
 	}
@@ -124,6 +296,16 @@ func getConnOrGenConn() (*Connection, error) {
 	password := getEnvOrDefault("COUCHBASE_PASSWORD", "password")
 	bucketName := getEnvOrDefault("COUCHBASE_BUCKET", "EvTeChallenge")
 
+	tlsConfig, err := couchbaseTLSConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	couchbaseURL = tlsConfig.connectionString(couchbaseURL)
+	security, err := tlsConfig.securityConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	cluster, err := gocb.Connect(couchbaseURL, gocb.ClusterOptions{
 		Authenticator: gocb.PasswordAuthenticator{
 			Username: username,
@@ -135,6 +317,7 @@ func getConnOrGenConn() (*Connection, error) {
 			QueryTimeout:      30 * time.Second,
 			ManagementTimeout: 30 * time.Second,
 		},
+		SecurityConfig: security,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Couchbase: %w", err)
@@ -0,0 +1,148 @@
+package dal
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConnectionPoolConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected ConnectionPoolConfig
+	}{
+		{
+			name: "Defaults",
+			env:  map[string]string{},
+			expected: ConnectionPoolConfig{
+				MinIdle:             DefaultPoolMinIdle,
+				MaxSize:             DefaultPoolMaxSize,
+				IdleTimeout:         DefaultPoolIdleTimeout,
+				HealthCheckInterval: DefaultPoolHealthCheckInterval,
+			},
+		},
+		{
+			name: "Configured",
+			env: map[string]string{
+				"COUCHBASE_POOL_MIN_IDLE":              "2",
+				"COUCHBASE_POOL_MAX_SIZE":              "10",
+				"COUCHBASE_POOL_IDLE_TIMEOUT":          "1m",
+				"COUCHBASE_POOL_HEALTH_CHECK_INTERVAL": "10s",
+			},
+			expected: ConnectionPoolConfig{MinIdle: 2, MaxSize: 10, IdleTimeout: time.Minute, HealthCheckInterval: 10 * time.Second},
+		},
+		{
+			name: "Invalid values use defaults",
+			env: map[string]string{
+				"COUCHBASE_POOL_MIN_IDLE":              "-1",
+				"COUCHBASE_POOL_MAX_SIZE":              "0",
+				"COUCHBASE_POOL_IDLE_TIMEOUT":          "soon",
+				"COUCHBASE_POOL_HEALTH_CHECK_INTERVAL": "-5s",
+			},
+			expected: ConnectionPoolConfig{
+				MinIdle:             DefaultPoolMinIdle,
+				MaxSize:             DefaultPoolMaxSize,
+				IdleTimeout:         DefaultPoolIdleTimeout,
+				HealthCheckInterval: DefaultPoolHealthCheckInterval,
+			},
+		},
+		{
+			name: "Durations raised to the minimum",
+			env: map[string]string{
+				"COUCHBASE_POOL_IDLE_TIMEOUT":          "1ns",
+				"COUCHBASE_POOL_HEALTH_CHECK_INTERVAL": "10ms",
+			},
+			expected: ConnectionPoolConfig{
+				MinIdle:             DefaultPoolMinIdle,
+				MaxSize:             DefaultPoolMaxSize,
+				IdleTimeout:         MinPoolInterval,
+				HealthCheckInterval: MinPoolInterval,
+			},
+		},
+		{
+			name:     "Min idle capped to max size",
+			env:      map[string]string{"COUCHBASE_POOL_MIN_IDLE": "8", "COUCHBASE_POOL_MAX_SIZE": "3"},
+			expected: ConnectionPoolConfig{MinIdle: 3, MaxSize: 3, IdleTimeout: DefaultPoolIdleTimeout, HealthCheckInterval: DefaultPoolHealthCheckInterval},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"COUCHBASE_POOL_MIN_IDLE", "COUCHBASE_POOL_MAX_SIZE", "COUCHBASE_POOL_IDLE_TIMEOUT", "COUCHBASE_POOL_HEALTH_CHECK_INTERVAL"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			if cfg := ConnectionPoolConfigFromEnv(); cfg != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, cfg)
+			}
+		})
+	}
+}
+
+// stubConnections replaces the liveness check and the connection factory for the duration of the test,
+// counting the connections created
+func stubConnections(t *testing.T) *int32 {
+	var created int32
+	originalAlive, originalNew := connectionAlive, newConnection
+	connectionAlive = func(conn *Connection) bool { return true }
+	newConnection = func() (*Connection, error) {
+		atomic.AddInt32(&created, 1)
+		return &Connection{}, nil
+	}
+	t.Cleanup(func() {
+		connectionAlive, newConnection = originalAlive, originalNew
+	})
+	return &created
+}
+
+func TestConnectionPoolEvictsIdleConnections(t *testing.T) {
+	stubConnections(t)
+	idleTimeout := 100 * time.Millisecond
+	p := newConnectionPool(ConnectionPoolConfig{MaxSize: 5, IdleTimeout: idleTimeout, HealthCheckInterval: time.Hour})
+	go p.maintain()
+	t.Cleanup(p.close)
+
+	lastUse := time.Now()
+	p.put(&idleConnection{conn: &Connection{}, returnedAt: lastUse, checkedAt: lastUse})
+
+	for len(p.connections) > 0 {
+		if time.Since(lastUse) > 2*idleTimeout {
+			t.Fatalf("Expected idle connection to be evicted within %v of its last use", 2*idleTimeout)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if elapsed := time.Since(lastUse); elapsed < idleTimeout {
+		t.Errorf("Expected idle connection to be kept for %v, evicted after %v", idleTimeout, elapsed)
+	}
+}
+
+func TestConnectionPoolKeepsMinIdle(t *testing.T) {
+	created := stubConnections(t)
+	p := newConnectionPool(ConnectionPoolConfig{MinIdle: 2, MaxSize: 5, IdleTimeout: time.Minute, HealthCheckInterval: time.Hour})
+	t.Cleanup(p.close)
+
+	stale := time.Now().Add(-2 * time.Minute)
+	p.put(&idleConnection{conn: &Connection{}, returnedAt: stale, checkedAt: stale})
+	p.sweep(false)
+
+	if got := len(p.connections); got != 2 {
+		t.Errorf("Expected 2 idle connections, got %d", got)
+	}
+	if got := atomic.LoadInt32(created); got != 2 {
+		t.Errorf("Expected the evicted connection to be replaced and 1 more opened, got %d created", got)
+	}
+}
+
+func TestConnectionPoolClosedPoolDropsReturnedConnections(t *testing.T) {
+	stubConnections(t)
+	p := newConnectionPool(ConnectionPoolConfig{MaxSize: 5, IdleTimeout: time.Minute, HealthCheckInterval: time.Hour})
+	p.close()
+
+	now := time.Now()
+	p.put(&idleConnection{conn: &Connection{}, returnedAt: now, checkedAt: now})
+
+	if got := len(p.connections); got != 0 {
+		t.Errorf("Expected a closed pool to keep no connections, got %d", got)
+	}
+}
@@ -2,7 +2,9 @@ package dal
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -10,6 +12,7 @@ import (
 	"github.com/couchbase/gocb/v2"
 	"github.com/rs/zerolog/log"
 	"stealthcompany.com/fhir-client/internal/metrics"
+	"stealthcompany.com/fhir-client/internal/tracing"
 )
 
 var (
@@ -19,18 +22,47 @@ var (
 
 // ResourceModel represents the database model for FHIR resources
 type ResourceModel struct {
-	conn *Connection
+	conn        *Connection
+	tenantScope string
 }
 
+// Compile-time check that ResourceModel keeps the upsert contract shared by both services
+var _ interface {
+	UpsertResource(context.Context, string, map[string]interface{}) error
+} = (*ResourceModel)(nil)
+
 // NewResourceModel creates a new resource model
 func NewResourceModel(conn *Connection) *ResourceModel {
 	return &ResourceModel{
-		conn: conn,
+		conn:        conn,
+		tenantScope: DefaultScope,
+	}
+}
+
+// NewResourceModelWithTenant creates a new resource model writing to the collections of a tenant scope
+func NewResourceModelWithTenant(conn *Connection, tenantScope string) *ResourceModel {
+	return &ResourceModel{
+		conn:        conn,
+		tenantScope: tenantScope,
+	}
+}
+
+// tenantID returns the tenant of the scope the model writes to, empty for the default scope
+func (rm *ResourceModel) tenantID() string {
+	if rm.tenantScope == DefaultScope {
+		return ""
 	}
+	return rm.tenantScope
 }
 
-// createCollectionsAndIndexesIfNeeded creates collections and indexes if they don't exist
-func (rm *ResourceModel) createCollectionsAndIndexesIfNeeded(ctx context.Context) error {
+// schemaQuerier is the part of gocb.Cluster used to create collections and indexes
+type schemaQuerier interface {
+	Query(statement string, opts *gocb.QueryOptions) (*gocb.QueryResult, error)
+}
+
+// EnsureCollectionsAndIndexes creates the collections and indexes of FHIR resources once per process.
+// It stops at the first statement after ctx is cancelled and leaves the work to be retried.
+func (rm *ResourceModel) EnsureCollectionsAndIndexes(ctx context.Context) error {
 	indexMutex.Lock()
 	defer indexMutex.Unlock()
 
@@ -40,74 +72,136 @@ func (rm *ResourceModel) createCollectionsAndIndexesIfNeeded(ctx context.Context
 
 	log.Info().Msg("Creating collections and indexes for FHIR resources...")
 
-	// Create collections using N1QL with full bucket.scope.collection syntax
-	bucketName := rm.conn.GetBucketName()
-	collections := []string{
+	if err := runSchemaStatements(ctx, rm.conn.GetCluster(), schemaStatements(rm.conn.GetBucketName())); err != nil {
+		return err
+	}
+
+	indexesCreated = true
+	log.Info().Msg("Collections and indexes creation completed")
+	return nil
+}
+
+// schemaStatements lists the N1QL statements creating the collections, then the indexes, of a bucket
+func schemaStatements(bucketName string) []string {
+	return []string{
+		// Collections using full bucket.scope.collection syntax
 		fmt.Sprintf("CREATE COLLECTION `%s`.`_default`.`encounters`", bucketName),
 		fmt.Sprintf("CREATE COLLECTION `%s`.`_default`.`patients`", bucketName),
 		fmt.Sprintf("CREATE COLLECTION `%s`.`_default`.`practitioners`", bucketName),
-	}
-
-	for _, collectionQuery := range collections {
-		_, err := rm.conn.GetCluster().Query(collectionQuery, &gocb.QueryOptions{Context: ctx})
-		if err != nil {
-			log.Warn().Err(err).Str("query", collectionQuery).Msg("Failed to create collection (may already exist)")
-		} else {
-			log.Debug().Str("query", collectionQuery).Msg("Collection created successfully")
-		}
-	}
+		fmt.Sprintf("CREATE COLLECTION `%s`.`_default`.`observations`", bucketName),
+		fmt.Sprintf("CREATE COLLECTION `%s`.`_default`.`conditions`", bucketName),
+		fmt.Sprintf("CREATE COLLECTION `%s`.`_default`.`medication_requests`", bucketName),
 
-	// Create indexes using N1QL queries for each collection
-	indexes := []string{
 		// Indexes for encounters collection
 		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_encounters_id ON `%s`.`_default`.`encounters`(id)", bucketName),
 		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_encounters_subjectPatientId ON `%s`.`_default`.`encounters`(subjectPatientId)", bucketName),
 		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_encounters_practitionerIds ON `%s`.`_default`.`encounters`(practitionerIds)", bucketName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_encounters_status ON `%s`.`_default`.`encounters`(status)", bucketName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_encounters_period_start ON `%s`.`_default`.`encounters`(period.`start`)", bucketName),
 
 		// Indexes for patients collection
 		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_patients_id ON `%s`.`_default`.`patients`(id)", bucketName),
 
 		// Indexes for practitioners collection
 		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_practitioners_id ON `%s`.`_default`.`practitioners`(id)", bucketName),
+
+		// Indexes for observations collection
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_observations_id ON `%s`.`_default`.`observations`(id)", bucketName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_observations_subjectPatientId ON `%s`.`_default`.`observations`(subjectPatientId)", bucketName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_observations_encounterId ON `%s`.`_default`.`observations`(encounterId)", bucketName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_observations_observationCode ON `%s`.`_default`.`observations`(observationCode)", bucketName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_observations_effectiveDateTime ON `%s`.`_default`.`observations`(effectiveDateTime)", bucketName),
+
+		// Indexes for conditions collection
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_conditions_id ON `%s`.`_default`.`conditions`(id)", bucketName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_conditions_subjectPatientId ON `%s`.`_default`.`conditions`(subjectPatientId)", bucketName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_conditions_encounterId ON `%s`.`_default`.`conditions`(encounterId)", bucketName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_conditions_conditionCode ON `%s`.`_default`.`conditions`(conditionCode)", bucketName),
+
+		// Indexes for medication_requests collection
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_medication_requests_id ON `%s`.`_default`.`medication_requests`(id)", bucketName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_medication_requests_subjectPatientId ON `%s`.`_default`.`medication_requests`(subjectPatientId)", bucketName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_medication_requests_encounterId ON `%s`.`_default`.`medication_requests`(encounterId)", bucketName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_medication_requests_medicationCode ON `%s`.`_default`.`medication_requests`(medicationCode)", bucketName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_medication_requests_status ON `%s`.`_default`.`medication_requests`(status)", bucketName),
+
+		// Index for ingestion run manifests in the default collection
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_ingest_manifests_startedAt ON `%s`.`_default`.`_default`(startedAt) WHERE META().id LIKE \"%s%%\"", bucketName, IngestManifestKeyPrefix),
 	}
+}
+
+// runSchemaStatements runs each statement, logging failures (the collection or index may already exist).
+// It returns the context error as soon as ctx is cancelled.
+func runSchemaStatements(ctx context.Context, querier schemaQuerier, statements []string) error {
+	for _, statement := range statements {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("collection and index creation cancelled: %w", err)
+		}
 
-	for _, indexQuery := range indexes {
-		_, err := rm.conn.GetCluster().Query(indexQuery, &gocb.QueryOptions{Context: ctx})
+		_, err := querier.Query(statement, &gocb.QueryOptions{Context: ctx})
 		if err != nil {
-			log.Warn().Err(err).Str("query", indexQuery).Msg("Failed to create index (may already exist)")
+			log.Warn().Err(err).Str("query", statement).Msg("Failed to create collection or index (may already exist)")
 		} else {
-			log.Debug().Str("query", indexQuery).Msg("Index created successfully")
+			log.Debug().Str("query", statement).Msg("Collection or index created successfully")
 		}
 	}
-
-	indexesCreated = true
-	log.Info().Msg("Collections and indexes creation completed")
 	return nil
 }
 
 // UpsertResource upserts a FHIR resource to Couchbase
-func (rm *ResourceModel) UpsertResource(ctx context.Context, docID string, data map[string]interface{}) error {
-	// Create collections and indexes on first upsert
-	if err := rm.createCollectionsAndIndexesIfNeeded(ctx); err != nil {
+func (rm *ResourceModel) UpsertResource(ctx context.Context, docID string, data map[string]interface{}) (err error) {
+	ctx, span := tracing.StartDBSpan(ctx, "ResourceModel.UpsertResource", "upsert", strings.Split(docID, "/")[0])
+	defer func() { tracing.EndSpan(span, err) }()
+
+	// Create collections and indexes on first upsert, unless Client.Init already did
+	if err := rm.EnsureCollectionsAndIndexes(ctx); err != nil {
 		log.Warn().Err(err).Msg("Failed to create collections and indexes, continuing with upsert")
 	}
 
-	// Add reviewed field to all resources during FHIR ingestion
-	data["reviewed"] = false
-
 	// Get the appropriate collection based on resource type
 	collection, err := rm.getCollectionForResource(docID)
 	if err != nil {
 		return fmt.Errorf("failed to get collection for resource %s: %w", docID, err)
 	}
 
+	// Hash the content before the stored fields are added
+	var hash string
+	if isDeduplicateEnabled() {
+		hash, err = contentHash(data)
+		if err != nil {
+			log.Warn().Err(err).Str("doc_id", docID).Msg("Failed to hash resource content, upserting without deduplication")
+		}
+	}
+
+	// Keep review state from a previous ingestion, a new resource starts as not reviewed.
+	// A failed read fails the upsert, so a recorded review is never overwritten.
+	existing, err := rm.getExistingFields(ctx, collection, docID)
+	if err != nil {
+		return err
+	}
+
+	if hash != "" {
+		if existing[contentHashPath] == hash {
+			metrics.RecordDedupSkip(strings.Split(docID, "/")[0])
+			log.Debug().Str("doc_id", docID).Msg("Resource content unchanged, skipping upsert")
+			return nil
+		}
+		setContentHash(data, hash)
+	}
+	applyReviewFields(data, existing)
+
+	// Oversized documents fail in Couchbase KV, so reject them before the upsert
+	if err := checkResourceSize(docID, data); err != nil {
+		return err
+	}
+
 	start := time.Now()
-	_, err = collection.Upsert(docID, data, nil)
+	err = retryUpsert(ctx, collection, docID, data, getMaxRetry())
 	duration := time.Since(start)
 
 	if err != nil {
-		metrics.RecordCouchbaseOperation("upsert", "error")
-		metrics.RecordCouchbaseOperationDuration("upsert", duration)
+		metrics.RecordCouchbaseOperation(rm.tenantID(), "upsert", "error")
+		metrics.RecordCouchbaseOperationDuration(rm.tenantID(), "upsert", duration)
 		return fmt.Errorf("failed to upsert resource %s: %w", docID, err)
 	}
 
@@ -116,35 +210,159 @@ func (rm *ResourceModel) UpsertResource(ctx context.Context, docID string, data
 		log.Warn().Err(err).Str("doc_id", docID).Msg("Failed to merge denormalized fields")
 	}
 
-	metrics.RecordCouchbaseOperation("upsert", "success")
-	metrics.RecordCouchbaseOperationDuration("upsert", duration)
+	metrics.RecordCouchbaseOperation(rm.tenantID(), "upsert", "success")
+	metrics.RecordCouchbaseOperationDuration(rm.tenantID(), "upsert", duration)
 
 	log.Debug().Str("doc_id", docID).Msg("Successfully upserted resource")
 	return nil
 }
 
+// documentGetter is the part of gocb.Collection used to read whole documents
+type documentGetter interface {
+	Get(id string, opts *gocb.GetOptions) (*gocb.GetResult, error)
+}
+
+// documentUpserter is the part of gocb.Collection used by retryUpsert
+type documentUpserter interface {
+	Upsert(id string, val interface{}, opts *gocb.UpsertOptions) (*gocb.MutationResult, error)
+}
+
+// upsertRetryBaseDelay is the first backoff delay between upsert retries (overridable in tests)
+var upsertRetryBaseDelay = 100 * time.Millisecond
+
+// getMaxRetry reads COUCHBASE_MAX_RETRY (default 3)
+func getMaxRetry() int {
+	maxRetry, err := strconv.Atoi(getEnvOrDefault("COUCHBASE_MAX_RETRY", "3"))
+	if err != nil || maxRetry < 0 {
+		return 3
+	}
+	return maxRetry
+}
+
+// isTransientError checks if a Couchbase error is worth retrying
+func isTransientError(err error) bool {
+	return errors.Is(err, gocb.ErrTimeout) ||
+		errors.Is(err, gocb.ErrTemporaryFailure) ||
+		errors.Is(err, gocb.ErrOverload)
+}
+
+// retryUpsert upserts a document, retrying transient errors up to maxRetries times with exponential backoff
+func retryUpsert(ctx context.Context, collection documentUpserter, docID string, data map[string]interface{}, maxRetries int) error {
+	delay := upsertRetryBaseDelay
+	for attempt := 0; ; attempt++ {
+		_, err := collection.Upsert(docID, data, nil)
+		if err == nil || !isTransientError(err) || attempt >= maxRetries {
+			return err
+		}
+
+		metrics.RecordCouchbaseUpsertRetry(attempt + 1)
+		log.Warn().
+			Err(err).
+			Str("doc_id", docID).
+			Int("retry", attempt+1).
+			Int("max_retries", maxRetries).
+			Dur("backoff", delay).
+			Msg("Transient Couchbase error on upsert, retrying")
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("upsert retry cancelled: %w", ctx.Err())
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// documentLookup is the part of gocb.Collection used to read stored fields by sub-document lookup
+type documentLookup interface {
+	LookupIn(id string, specs []gocb.LookupInSpec, opts *gocb.LookupInOptions) (*gocb.LookupInResult, error)
+}
+
+// reviewStateFields are the fields api-rest writes into resource documents when reviewing them.
+// They are owned by the reviews, so re-ingesting a resource carries them over from the stored document.
+var reviewStateFields = []string{"reviewed", "reviewTime", "reviewNotes", "reviewSeverity", "reviewHistory", "audit"}
+
+// getExistingFields reads the review state fields and content hash of an already stored resource.
+// It returns nil when the document does not exist yet, and an error when it could not be read.
+func (rm *ResourceModel) getExistingFields(ctx context.Context, collection documentLookup, docID string) (map[string]interface{}, error) {
+	specs := make([]gocb.LookupInSpec, 0, len(reviewStateFields)+1)
+	for _, field := range reviewStateFields {
+		specs = append(specs, gocb.GetSpec(field, nil))
+	}
+	specs = append(specs, gocb.GetSpec(contentHashPath, nil))
+
+	result, err := collection.LookupIn(docID, specs, &gocb.LookupInOptions{Context: ctx})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lookup review fields for %s: %w", docID, err)
+	}
+
+	fields := make(map[string]interface{})
+
+	// A field missing from the document fails its own spec only
+	for i, field := range reviewStateFields {
+		var value interface{}
+		if err := result.ContentAt(uint(i), &value); err == nil && value != nil {
+			fields[field] = value
+		}
+	}
+
+	var hash string
+	if err := result.ContentAt(uint(len(reviewStateFields)), &hash); err == nil {
+		fields[contentHashPath] = hash
+	}
+
+	return fields, nil
+}
+
+// applyReviewFields copies the existing review state fields into data, defaulting to not reviewed.
+// Review fields of the fetched payload are dropped, the stored review state is the only source.
+func applyReviewFields(data map[string]interface{}, existing map[string]interface{}) {
+	for _, field := range reviewStateFields {
+		delete(data, field)
+	}
+	data["reviewed"] = false
+
+	if reviewed, ok := existing["reviewed"].(bool); ok {
+		data["reviewed"] = reviewed
+	}
+	if reviewTime, ok := existing["reviewTime"].(string); ok && reviewTime != "" {
+		data["reviewTime"] = reviewTime
+	}
+	for _, field := range reviewStateFields[2:] {
+		if value, ok := existing[field]; ok && value != nil {
+			data[field] = value
+		}
+	}
+}
+
 // GetResource retrieves a FHIR resource from Couchbase
-func (rm *ResourceModel) GetResource(ctx context.Context, docID string) (map[string]interface{}, error) {
+func (rm *ResourceModel) GetResource(ctx context.Context, docID string) (_ map[string]interface{}, err error) {
+	_, span := tracing.StartDBSpan(ctx, "ResourceModel.GetResource", "get", strings.Split(docID, "/")[0])
+	defer func() { tracing.EndSpan(span, err) }()
+
 	start := time.Now()
 	result, err := rm.conn.bucket.DefaultCollection().Get(docID, nil)
 	duration := time.Since(start)
 
 	if err != nil {
-		metrics.RecordCouchbaseOperation("get", "error")
-		metrics.RecordCouchbaseOperationDuration("get", duration)
+		metrics.RecordCouchbaseOperation(rm.tenantID(), "get", "error")
+		metrics.RecordCouchbaseOperationDuration(rm.tenantID(), "get", duration)
 		return nil, fmt.Errorf("failed to get resource %s: %w", docID, err)
 	}
 
 	var data map[string]interface{}
 	err = result.Content(&data)
 	if err != nil {
-		metrics.RecordCouchbaseOperation("get", "error")
-		metrics.RecordCouchbaseOperationDuration("get", duration)
+		metrics.RecordCouchbaseOperation(rm.tenantID(), "get", "error")
+		metrics.RecordCouchbaseOperationDuration(rm.tenantID(), "get", duration)
 		return nil, fmt.Errorf("failed to decode resource %s: %w", docID, err)
 	}
 
-	metrics.RecordCouchbaseOperation("get", "success")
-	metrics.RecordCouchbaseOperationDuration("get", duration)
+	metrics.RecordCouchbaseOperation(rm.tenantID(), "get", "success")
+	metrics.RecordCouchbaseOperationDuration(rm.tenantID(), "get", duration)
 
 	log.Debug().Str("doc_id", docID).Msg("Successfully retrieved resource")
 	return data, nil
@@ -152,24 +370,28 @@ func (rm *ResourceModel) GetResource(ctx context.Context, docID string) (map[str
 
 // ResourceExists checks if a resource exists in Couchbase
 func (rm *ResourceModel) ResourceExists(ctx context.Context, docID string) (bool, error) {
+	return documentExists(rm.tenantID(), rm.conn.bucket.DefaultCollection(), docID)
+}
+
+// documentExists checks if a document exists, recording the read as a hit, a miss or an error
+func documentExists(tenantID string, collection documentGetter, docID string) (bool, error) {
 	start := time.Now()
-	_, err := rm.conn.bucket.DefaultCollection().Get(docID, nil)
+	_, err := collection.Get(docID, nil)
 	duration := time.Since(start)
 
 	if err != nil {
-		// Check if it's a key not found error
-		if err.Error() == "key not found" || err.Error() == "document not found" {
-			metrics.RecordCouchbaseOperation("get", "miss")
-			metrics.RecordCouchbaseOperationDuration("get", duration)
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			metrics.RecordCouchbaseOperation(tenantID, "get", "miss")
+			metrics.RecordCouchbaseOperationDuration(tenantID, "get", duration)
 			return false, nil
 		}
-		metrics.RecordCouchbaseOperation("get", "error")
-		metrics.RecordCouchbaseOperationDuration("get", duration)
+		metrics.RecordCouchbaseOperation(tenantID, "get", "error")
+		metrics.RecordCouchbaseOperationDuration(tenantID, "get", duration)
 		return false, fmt.Errorf("failed to check resource existence %s: %w", docID, err)
 	}
 
-	metrics.RecordCouchbaseOperation("get", "success")
-	metrics.RecordCouchbaseOperationDuration("get", duration)
+	metrics.RecordCouchbaseOperation(tenantID, "get", "success")
+	metrics.RecordCouchbaseOperationDuration(tenantID, "get", duration)
 	return true, nil
 }
 
@@ -209,7 +431,8 @@ func (rm *ResourceModel) GetAllResourcesByType(ctx context.Context, resourceType
 	}
 	defer rows.Close()
 
-	var resources []ResourceRow
+	// An empty collection returns an empty slice rather than nil
+	resources := make([]ResourceRow, 0)
 	for rows.Next() {
 		var row ResourceRow
 		err = rows.Row(&row)
@@ -232,27 +455,35 @@ type ResourceRow struct {
 
 // getCollectionForResource returns the appropriate collection based on resource type
 func (rm *ResourceModel) getCollectionForResource(docID string) (*gocb.Collection, error) {
-	// Extract resource type from document ID (format: "ResourceType/ID")
-	var collectionName string
-	if len(docID) > 0 {
-		for i, char := range docID {
-			if char == '/' {
-				collectionName = docID[:i]
-				break
-			}
-		}
+	collectionName, err := collectionNameForResource(docID)
+	if err != nil {
+		return nil, err
+	}
+	return rm.conn.bucket.Scope(rm.tenantScope).Collection(collectionName), nil
+}
+
+// collectionNameForResource maps the resource type of a document ID ("ResourceType/ID") to its collection name
+func collectionNameForResource(docID string) (string, error) {
+	resourceType, _, found := strings.Cut(docID, "/")
+	if !found {
+		resourceType = ""
 	}
 
-	// Map resource types to collection names
-	switch collectionName {
+	switch resourceType {
 	case "Encounter":
-		return rm.conn.bucket.Scope("_default").Collection("encounters"), nil
+		return "encounters", nil
 	case "Patient":
-		return rm.conn.bucket.Scope("_default").Collection("patients"), nil
+		return "patients", nil
 	case "Practitioner":
-		return rm.conn.bucket.Scope("_default").Collection("practitioners"), nil
+		return "practitioners", nil
+	case "Observation":
+		return "observations", nil
+	case "Condition":
+		return "conditions", nil
+	case "MedicationRequest":
+		return "medication_requests", nil
 	default:
-		return nil, fmt.Errorf("unknown resource type: %s", collectionName)
+		return "", fmt.Errorf("unknown resource type: %s", resourceType)
 	}
 }
 
@@ -279,36 +510,7 @@ func (rm *ResourceModel) mergeDenormalizedFields(collection *gocb.Collection, do
 			return fmt.Errorf("failed to parse document content: %w", err)
 		}
 
-		// Prepare merge data
-		mergeData := map[string]interface{}{
-			"docId": docID,
-		}
-
-		// Extract and add subjectPatientId (keep full reference format)
-		if subject, ok := data["subject"].(map[string]interface{}); ok {
-			if reference, ok := subject["reference"].(string); ok {
-				if strings.HasPrefix(reference, "Patient/") {
-					mergeData["subjectPatientId"] = reference
-				}
-			}
-		}
-
-		// Extract and add practitionerIds array (keep full reference format)
-		var practitionerIDs []string
-		if participants, ok := data["participant"].([]interface{}); ok {
-			for _, participant := range participants {
-				if p, ok := participant.(map[string]interface{}); ok {
-					if individual, ok := p["individual"].(map[string]interface{}); ok {
-						if reference, ok := individual["reference"].(string); ok {
-							if strings.HasPrefix(reference, "Practitioner/") {
-								practitionerIDs = append(practitionerIDs, reference)
-							}
-						}
-					}
-				}
-			}
-		}
-		mergeData["practitionerIds"] = practitionerIDs
+		mergeData := denormalizedFields(docID, data)
 
 		// Merge the denormalized fields
 		_, err = collection.MutateIn(docID, []gocb.MutateInSpec{
@@ -324,3 +526,39 @@ func (rm *ResourceModel) mergeDenormalizedFields(collection *gocb.Collection, do
 
 	return nil
 }
+
+// denormalizedFields extracts the docId, subjectPatientId and practitionerIds query fields of an encounter.
+// References keep their full "ResourceType/id" format.
+func denormalizedFields(docID string, data map[string]interface{}) map[string]interface{} {
+	fields := map[string]interface{}{
+		"docId": docID,
+	}
+
+	// Extract and add subjectPatientId
+	if subject, ok := data["subject"].(map[string]interface{}); ok {
+		if reference, ok := subject["reference"].(string); ok {
+			if strings.HasPrefix(reference, "Patient/") {
+				fields["subjectPatientId"] = reference
+			}
+		}
+	}
+
+	// Extract and add practitionerIds array
+	var practitionerIDs []string
+	if participants, ok := data["participant"].([]interface{}); ok {
+		for _, participant := range participants {
+			if p, ok := participant.(map[string]interface{}); ok {
+				if individual, ok := p["individual"].(map[string]interface{}); ok {
+					if reference, ok := individual["reference"].(string); ok {
+						if strings.HasPrefix(reference, "Practitioner/") {
+							practitionerIDs = append(practitionerIDs, reference)
+						}
+					}
+				}
+			}
+		}
+	}
+	fields["practitionerIds"] = practitionerIDs
+
+	return fields
+}
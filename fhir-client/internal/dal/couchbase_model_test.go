@@ -0,0 +1,299 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+func TestApplyReviewFields(t *testing.T) {
+	tests := []struct {
+		name               string
+		existing           map[string]interface{}
+		expectedReviewed   bool
+		expectedReviewTime string
+	}{
+		{
+			name:               "New resource starts as not reviewed",
+			existing:           nil,
+			expectedReviewed:   false,
+			expectedReviewTime: "",
+		},
+		{
+			name: "Re-ingested resource keeps reviewed state",
+			existing: map[string]interface{}{
+				"reviewed":   true,
+				"reviewTime": "2025-01-01T10:00:00Z",
+			},
+			expectedReviewed:   true,
+			expectedReviewTime: "2025-01-01T10:00:00Z",
+		},
+		{
+			name:               "Existing resource without review fields stays not reviewed",
+			existing:           map[string]interface{}{},
+			expectedReviewed:   false,
+			expectedReviewTime: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Fresh FHIR payload as fetched from the API
+			data := map[string]interface{}{
+				"id":           "123",
+				"resourceType": "Encounter",
+			}
+
+			applyReviewFields(data, tt.existing)
+
+			if reviewed, _ := data["reviewed"].(bool); reviewed != tt.expectedReviewed {
+				t.Errorf("Expected reviewed %v, got %v", tt.expectedReviewed, reviewed)
+			}
+			if reviewTime, _ := data["reviewTime"].(string); reviewTime != tt.expectedReviewTime {
+				t.Errorf("Expected reviewTime %q, got %q", tt.expectedReviewTime, reviewTime)
+			}
+		})
+	}
+}
+
+func TestReingestKeepsReviewState(t *testing.T) {
+	history := []interface{}{
+		map[string]interface{}{"reviewer": "alice", "time": "2025-01-01T10:00:00Z", "severity": "warning"},
+		map[string]interface{}{"reviewer": "bob", "time": "2025-01-02T10:00:00Z"},
+	}
+	audit := []interface{}{map[string]interface{}{"action": "deleted", "time": "2025-01-01T12:00:00Z"}}
+	stored := map[string]interface{}{
+		"id":             "123",
+		"resourceType":   "Encounter",
+		"status":         "planned",
+		"reviewed":       true,
+		"reviewTime":     "2025-01-02T10:00:00Z",
+		"reviewNotes":    "checked twice",
+		"reviewSeverity": "warning",
+		"reviewHistory":  history,
+		"audit":          audit,
+	}
+
+	// Fresh FHIR payload of the same resource, as fetched from the API on the next ingestion
+	data := map[string]interface{}{"id": "123", "resourceType": "Encounter", "status": "finished"}
+	applyReviewFields(data, existingFieldsFromDocument(stored))
+
+	if data["status"] != "finished" {
+		t.Errorf("Expected the fetched content to be written, got status %v", data["status"])
+	}
+	if data["reviewed"] != true || data["reviewTime"] != "2025-01-02T10:00:00Z" {
+		t.Errorf("Expected the review to be kept, got %v at %v", data["reviewed"], data["reviewTime"])
+	}
+	if data["reviewNotes"] != "checked twice" || data["reviewSeverity"] != "warning" {
+		t.Errorf("Expected the review notes and severity to be kept, got %v and %v", data["reviewNotes"], data["reviewSeverity"])
+	}
+	if got, _ := data["reviewHistory"].([]interface{}); len(got) != 2 {
+		t.Errorf("Expected the review history to survive re-ingestion, got %v", data["reviewHistory"])
+	}
+	if got, _ := data["audit"].([]interface{}); len(got) != 1 {
+		t.Errorf("Expected the review audit trail to survive re-ingestion, got %v", data["audit"])
+	}
+}
+
+func TestApplyReviewFieldsDropsPayloadReviewFields(t *testing.T) {
+	// Review fields only come from the stored document, never from the FHIR server
+	data := map[string]interface{}{"id": "1", "reviewed": true, "reviewNotes": "injected", "reviewHistory": []interface{}{"x"}}
+	applyReviewFields(data, nil)
+
+	if data["reviewed"] != false {
+		t.Errorf("Expected a new resource not to be reviewed, got %v", data["reviewed"])
+	}
+	for _, field := range []string{"reviewNotes", "reviewHistory"} {
+		if _, ok := data[field]; ok {
+			t.Errorf("Expected %s of the payload to be dropped, got %v", field, data[field])
+		}
+	}
+}
+
+// failingLookup fails every sub-document lookup with err
+type failingLookup struct {
+	err error
+}
+
+func (f *failingLookup) LookupIn(id string, specs []gocb.LookupInSpec, opts *gocb.LookupInOptions) (*gocb.LookupInResult, error) {
+	return nil, f.err
+}
+
+func TestGetExistingFieldsReadErrors(t *testing.T) {
+	rm := &ResourceModel{}
+
+	existing, err := rm.getExistingFields(context.Background(), &failingLookup{err: gocb.ErrDocumentNotFound}, "Encounter/1")
+	if err != nil || existing != nil {
+		t.Errorf("Expected a new resource without stored fields, got %v, %v", existing, err)
+	}
+
+	// Any other failure must not be mistaken for a new resource, which would reset its review
+	for _, readErr := range []error{gocb.ErrTimeout, gocb.ErrOverload} {
+		if _, err := rm.getExistingFields(context.Background(), &failingLookup{err: readErr}, "Encounter/1"); !errors.Is(err, readErr) {
+			t.Errorf("Expected %v to be returned, got %v", readErr, err)
+		}
+	}
+}
+
+// flakyUpserter fails the first failures upserts with err
+type flakyUpserter struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyUpserter) Upsert(id string, val interface{}, opts *gocb.UpsertOptions) (*gocb.MutationResult, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, f.err
+	}
+	return &gocb.MutationResult{}, nil
+}
+
+func TestRetryUpsert(t *testing.T) {
+	origDelay := upsertRetryBaseDelay
+	upsertRetryBaseDelay = time.Millisecond
+	t.Cleanup(func() {
+		upsertRetryBaseDelay = origDelay
+	})
+
+	tests := []struct {
+		name          string
+		failures      int
+		err           error
+		maxRetries    int
+		expectedErr   bool
+		expectedCalls int
+	}{
+		{
+			name:          "Transient timeout then success",
+			failures:      1,
+			err:           gocb.ErrTimeout,
+			maxRetries:    3,
+			expectedErr:   false,
+			expectedCalls: 2,
+		},
+		{
+			name:          "Temporary failure then success",
+			failures:      2,
+			err:           gocb.ErrTemporaryFailure,
+			maxRetries:    3,
+			expectedErr:   false,
+			expectedCalls: 3,
+		},
+		{
+			name:          "Transient errors exhaust retries",
+			failures:      5,
+			err:           gocb.ErrOverload,
+			maxRetries:    3,
+			expectedErr:   true,
+			expectedCalls: 4,
+		},
+		{
+			name:          "Non-transient error is not retried",
+			failures:      1,
+			err:           gocb.ErrCollectionNotFound,
+			maxRetries:    3,
+			expectedErr:   true,
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upserter := &flakyUpserter{failures: tt.failures, err: tt.err}
+			err := retryUpsert(context.Background(), upserter, "Encounter/1", map[string]interface{}{}, tt.maxRetries)
+
+			if (err != nil) != tt.expectedErr {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if upserter.calls != tt.expectedCalls {
+				t.Errorf("Expected %d upsert calls, got %d", tt.expectedCalls, upserter.calls)
+			}
+		})
+	}
+}
+
+func TestGetMaxRetry(t *testing.T) {
+	t.Setenv("COUCHBASE_MAX_RETRY", "")
+	if got := getMaxRetry(); got != 3 {
+		t.Errorf("Expected default max retry 3, got %d", got)
+	}
+
+	t.Setenv("COUCHBASE_MAX_RETRY", "5")
+	if got := getMaxRetry(); got != 5 {
+		t.Errorf("Expected max retry 5, got %d", got)
+	}
+}
+
+// cancellingQuerier records statements and cancels the context after cancelAfter queries
+type cancellingQuerier struct {
+	cancel      context.CancelFunc
+	cancelAfter int
+	statements  []string
+}
+
+func (q *cancellingQuerier) Query(statement string, opts *gocb.QueryOptions) (*gocb.QueryResult, error) {
+	q.statements = append(q.statements, statement)
+	if len(q.statements) == q.cancelAfter {
+		q.cancel()
+	}
+	return nil, nil
+}
+
+func TestRunSchemaStatements(t *testing.T) {
+	statements := schemaStatements("EvTeChallenge")
+
+	t.Run("Runs every statement", func(t *testing.T) {
+		querier := &cancellingQuerier{cancel: func() {}}
+		if err := runSchemaStatements(context.Background(), querier, statements); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(querier.statements) != len(statements) {
+			t.Errorf("Expected %d statements, got %d", len(statements), len(querier.statements))
+		}
+	})
+
+	t.Run("Stops when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		querier := &cancellingQuerier{cancel: cancel, cancelAfter: 2}
+
+		err := runSchemaStatements(ctx, querier, statements)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+		if len(querier.statements) != 2 {
+			t.Errorf("Expected creation to stop after 2 statements, got %d", len(querier.statements))
+		}
+	})
+
+	t.Run("Cancelled before start", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		querier := &cancellingQuerier{cancel: cancel}
+
+		if err := runSchemaStatements(ctx, querier, statements); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+		if len(querier.statements) != 0 {
+			t.Errorf("Expected no statements, got %d", len(querier.statements))
+		}
+	})
+}
+
+func TestDocumentExistsDocumentNotFound(t *testing.T) {
+	exists, err := documentExists("", &mockGetter{err: fmt.Errorf("get Encounter/1: %w", gocb.ErrDocumentNotFound)}, "Encounter/1")
+	if err != nil || exists {
+		t.Errorf("Expected a missing document, got %v, %v", exists, err)
+	}
+
+	// Only gocb.ErrDocumentNotFound means missing, not an error message that looks like it
+	if _, err := documentExists("", &mockGetter{err: errors.New("key not found")}, "Encounter/1"); err == nil {
+		t.Error("Expected an error that is not gocb.ErrDocumentNotFound to be returned")
+	}
+}
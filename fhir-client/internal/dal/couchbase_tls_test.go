@@ -0,0 +1,78 @@
+package dal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCouchbaseTLSConnectionString(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled string
+		url     string
+		want    string
+	}{
+		{name: "TLS disabled by default", url: "couchbase://evt-db", want: "couchbase://evt-db"},
+		{name: "TLS disabled", enabled: "false", url: "couchbase://evt-db", want: "couchbase://evt-db"},
+		{name: "TLS enabled", enabled: "true", url: "couchbase://evt-db", want: "couchbases://evt-db"},
+		{name: "TLS URL kept", enabled: "true", url: "couchbases://evt-db", want: "couchbases://evt-db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("COUCHBASE_TLS_ENABLED", tt.enabled)
+			cfg, err := couchbaseTLSConfigFromEnv()
+			if err != nil {
+				t.Fatalf("couchbaseTLSConfigFromEnv() error = %v", err)
+			}
+			if got := cfg.connectionString(tt.url); got != tt.want {
+				t.Errorf("connectionString(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCouchbaseTLSConfigFromEnvInvalid(t *testing.T) {
+	t.Setenv("COUCHBASE_TLS_ENABLED", "yes please")
+	if _, err := couchbaseTLSConfigFromEnv(); err == nil {
+		t.Error("Expected an error for an invalid COUCHBASE_TLS_ENABLED")
+	}
+}
+
+func TestCouchbaseTLSSecurityConfig(t *testing.T) {
+	t.Run("skip verify", func(t *testing.T) {
+		security, err := couchbaseTLSConfig{enabled: true, skipVerify: true}.securityConfig()
+		if err != nil {
+			t.Fatalf("securityConfig() error = %v", err)
+		}
+		if !security.TLSSkipVerify {
+			t.Error("Expected TLSSkipVerify to be set")
+		}
+	})
+
+	t.Run("missing CA certificate", func(t *testing.T) {
+		cfg := couchbaseTLSConfig{enabled: true, caCertPath: filepath.Join(t.TempDir(), "missing.pem")}
+		if _, err := cfg.securityConfig(); err == nil {
+			t.Error("Expected an error for a missing CA certificate")
+		}
+	})
+
+	t.Run("invalid CA certificate", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ca.pem")
+		if err := os.WriteFile(path, []byte("not a certificate"), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		cfg := couchbaseTLSConfig{enabled: true, caCertPath: path}
+		if _, err := cfg.securityConfig(); err == nil {
+			t.Error("Expected an error for a file without certificates")
+		}
+	})
+
+	t.Run("TLS disabled ignores CA certificate", func(t *testing.T) {
+		cfg := couchbaseTLSConfig{caCertPath: "/does/not/exist.pem"}
+		if _, err := cfg.securityConfig(); err != nil {
+			t.Errorf("securityConfig() error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package dal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// contentHashPath is the sub-document path of the stored content hash
+const contentHashPath = "_meta.contentHash"
+
+// contentHashExcludedFields are set by this service, not by the FHIR server, so they do not count as content
+var contentHashExcludedFields = map[string]bool{
+	"_meta":            true,
+	"reviewed":         true,
+	"reviewTime":       true,
+	"docId":            true,
+	"subjectPatientId": true,
+	"practitionerIds":  true,
+}
+
+// isDeduplicateEnabled reads FHIR_DEDUPLICATE (default false)
+func isDeduplicateEnabled() bool {
+	enabled, _ := strconv.ParseBool(getEnvOrDefault("FHIR_DEDUPLICATE", "false"))
+	return enabled
+}
+
+// contentHash returns the SHA-256 of the resource JSON without the fields stored by this service.
+// Map keys are marshaled in sorted order, so equal content gives the same hash.
+func contentHash(data map[string]interface{}) (string, error) {
+	content := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if !contentHashExcludedFields[key] {
+			content[key] = value
+		}
+	}
+
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode resource content: %w", err)
+	}
+
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// setContentHash stores the hash under _meta.contentHash
+func setContentHash(data map[string]interface{}, hash string) {
+	data["_meta"] = map[string]interface{}{"contentHash": hash}
+}
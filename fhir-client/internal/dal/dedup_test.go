@@ -0,0 +1,144 @@
+package dal
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestContentHash(t *testing.T) {
+	base := map[string]interface{}{
+		"resourceType": "Encounter",
+		"id":           "1",
+		"status":       "finished",
+		"subject":      map[string]interface{}{"reference": "Patient/1"},
+	}
+	baseHash, err := contentHash(base)
+	if err != nil {
+		t.Fatalf("contentHash() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		data     map[string]interface{}
+		wantSame bool
+	}{
+		{
+			name: "stored fields are ignored",
+			data: map[string]interface{}{
+				"resourceType":     "Encounter",
+				"id":               "1",
+				"status":           "finished",
+				"subject":          map[string]interface{}{"reference": "Patient/1"},
+				"reviewed":         true,
+				"reviewTime":       "2024-01-01T00:00:00Z",
+				"docId":            "Encounter/1",
+				"subjectPatientId": "Patient/1",
+				"practitionerIds":  []string{"Practitioner/1"},
+				"_meta":            map[string]interface{}{"contentHash": "old"},
+			},
+			wantSame: true,
+		},
+		{
+			name: "changed field changes the hash",
+			data: map[string]interface{}{
+				"resourceType": "Encounter",
+				"id":           "1",
+				"status":       "in-progress",
+				"subject":      map[string]interface{}{"reference": "Patient/1"},
+			},
+		},
+		{
+			name: "changed nested field changes the hash",
+			data: map[string]interface{}{
+				"resourceType": "Encounter",
+				"id":           "1",
+				"status":       "finished",
+				"subject":      map[string]interface{}{"reference": "Patient/2"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := contentHash(tt.data)
+			if err != nil {
+				t.Fatalf("contentHash() error = %v", err)
+			}
+			if (hash == baseHash) != tt.wantSame {
+				t.Errorf("contentHash() = %s, base %s, wantSame %v", hash, baseHash, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestContentHashDoesNotModifyData(t *testing.T) {
+	data := map[string]interface{}{"resourceType": "Patient", "id": "1", "reviewed": true}
+	if _, err := contentHash(data); err != nil {
+		t.Fatalf("contentHash() error = %v", err)
+	}
+	if len(data) != 3 || data["reviewed"] != true {
+		t.Errorf("contentHash() modified data: %v", data)
+	}
+}
+
+func TestIsDeduplicateEnabled(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{value: "", want: false},
+		{value: "true", want: true},
+		{value: "false", want: false},
+		{value: "invalid", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("FHIR_DEDUPLICATE", tt.value)
+			if got := isDeduplicateEnabled(); got != tt.want {
+				t.Errorf("isDeduplicateEnabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// benchmarkIngestion replays ingestion rounds where one resource in ten changes between rounds
+// and reports the number of writes per round, with and without deduplication.
+func benchmarkIngestion(b *testing.B, dedup bool) {
+	const resources = 1000
+	stored := make(map[string]string, resources)
+	writes := 0
+
+	for round := 0; round < b.N; round++ {
+		for i := 0; i < resources; i++ {
+			docID := fmt.Sprintf("Encounter/%d", i)
+			status := "finished"
+			if i%10 == 0 && round%2 == 1 {
+				status = "in-progress"
+			}
+			data := map[string]interface{}{"resourceType": "Encounter", "id": fmt.Sprint(i), "status": status}
+
+			if dedup {
+				hash, err := contentHash(data)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if stored[docID] == hash {
+					continue
+				}
+				stored[docID] = hash
+			}
+			writes++
+		}
+	}
+
+	b.ReportMetric(float64(writes)/float64(b.N), "writes/round")
+}
+
+func BenchmarkIngestionWithoutDedup(b *testing.B) {
+	benchmarkIngestion(b, false)
+}
+
+func BenchmarkIngestionWithDedup(b *testing.B) {
+	benchmarkIngestion(b, true)
+}
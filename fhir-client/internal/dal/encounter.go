@@ -3,7 +3,9 @@ package dal
 import (
 	"context"
 	"fmt"
-	"strings"
+
+	"stealthcompany.com/fhir-client/internal/metrics"
+	"stealthcompany.com/pkg/fhirutil"
 )
 
 // EncounterModel handles encounter-specific database operations
@@ -20,25 +22,41 @@ func NewEncounterModel(resourceModel *ResourceModel) *EncounterModel {
 
 // UpsertEncounter upserts an encounter resource
 func (em *EncounterModel) UpsertEncounter(ctx context.Context, encounterID string, data map[string]interface{}) error {
+	if err := prepareEncounter(encounterID, data); err != nil {
+		return err
+	}
+	return em.resourceModel.UpsertResource(ctx, fmt.Sprintf("Encounter/%s", encounterID), data)
+}
+
+// BulkUpsertEncounters upserts encounters by ID with a single bulk write and returns the failures by encounter ID
+func (em *EncounterModel) BulkUpsertEncounters(ctx context.Context, encounters map[string]map[string]interface{}) map[string]error {
+	return bulkUpsertResources(ctx, em.resourceModel.BulkUpsert, "Encounter", encounters, prepareEncounter)
+}
+
+// prepareEncounter validates an encounter and adds its query fields
+func prepareEncounter(encounterID string, data map[string]interface{}) error {
+	if err := validateResource("Encounter", encounterID, data, isStrictValidation()); err != nil {
+		return err
+	}
+
 	docID := fmt.Sprintf("Encounter/%s", encounterID)
 
 	// Denormalize fields for better querying
 	data["docId"] = docID
 	data["resourceType"] = "Encounter"
 
-	// Extract and add patient reference
-	patientRefs := em.extractPatientReferences(data)
-	if len(patientRefs) > 0 {
-		data["subjectPatientId"] = patientRefs[0]
+	// Extract and add patient reference (bare ID)
+	if patientRef := fhirutil.ExtractPatientRef(data, metrics.RecordReferenceParse); patientRef != "" {
+		data["subjectPatientId"] = patientRef
 	}
 
-	// Extract and add practitioner references
-	practitionerRefs := em.extractPractitionerReferences(data)
+	// Extract and add practitioner references (bare IDs)
+	practitionerRefs := fhirutil.ExtractPractitionerRefs(data, metrics.RecordReferenceParse)
 	if len(practitionerRefs) > 0 {
 		data["practitionerIds"] = practitionerRefs
 	}
 
-	return em.resourceModel.UpsertResource(ctx, docID, data)
+	return nil
 }
 
 // GetEncounter retrieves an encounter by ID
@@ -62,77 +80,3 @@ func (em *EncounterModel) CountEncounters(ctx context.Context) (int64, error) {
 func (em *EncounterModel) GetAllEncounters(ctx context.Context) ([]ResourceRow, error) {
 	return em.resourceModel.GetAllResourcesByType(ctx, "Encounter")
 }
-
-// extractPatientReferences extracts patient references from an encounter resource
-func (em *EncounterModel) extractPatientReferences(resource map[string]interface{}) []string {
-	var refs []string
-
-	subject, ok := resource["subject"].(map[string]interface{})
-	if !ok {
-		return refs
-	}
-
-	reference, ok := subject["reference"].(string)
-	if !ok {
-		return refs
-	}
-
-	if ref := em.extractReferenceID(reference, "Patient"); ref != "" {
-		refs = append(refs, ref)
-	}
-
-	return refs
-}
-
-// extractPractitionerReferences extracts practitioner references from an encounter resource
-func (em *EncounterModel) extractPractitionerReferences(resource map[string]interface{}) []string {
-	var refs []string
-
-	participants, ok := resource["participant"].([]interface{})
-	if !ok {
-		return refs
-	}
-
-	for _, participant := range participants {
-		p, ok := participant.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		individual, ok := p["individual"].(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		reference, ok := individual["reference"].(string)
-		if !ok {
-			continue
-		}
-
-		if ref := em.extractReferenceID(reference, "Practitioner"); ref != "" {
-			refs = append(refs, ref)
-		}
-	}
-
-	return refs
-}
-
-// extractReferenceID extracts the ID from a FHIR reference
-func (em *EncounterModel) extractReferenceID(reference, resourceType string) string {
-	if strings.Contains(reference, "/") {
-		parts := strings.Split(reference, "/")
-		if len(parts) == 2 {
-			refType := parts[0]
-			refID := parts[1]
-
-			if refType == resourceType {
-				return refID
-			}
-		}
-	} else if strings.HasPrefix(reference, "urn:uuid:") {
-		// Inline bundle reference (not resolvable via FHIR API): skip external sync
-		return ""
-	}
-
-	return ""
-}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/couchbase/gocb/v2"
@@ -13,10 +12,12 @@ import (
 
 // IngestionStatus represents the ingestion status document
 type IngestionStatus struct {
-	Ready       bool      `json:"ready"`
-	StartedAt   time.Time `json:"startedAt"`
-	CompletedAt time.Time `json:"completedAt,omitempty"`
-	Message     string    `json:"message"`
+	Ready          bool              `json:"ready"`
+	StartedAt      time.Time         `json:"startedAt"`
+	CompletedAt    time.Time         `json:"completedAt,omitempty"`
+	Message        string            `json:"message"`
+	ResourceCounts map[string]int    `json:"resourceCounts,omitempty"`
+	Filters        map[string]string `json:"filters,omitempty"`
 }
 
 // IngestionStatusKey is the document key for ingestion status
@@ -24,27 +25,45 @@ const IngestionStatusKey = "template/ingestion_status"
 
 // IngestionStatusModel represents the database model for ingestion status
 type IngestionStatusModel struct {
-	conn *Connection
+	conn        *Connection
+	tenantScope string
 }
 
 // NewIngestionStatusModel creates a new ingestion status model
 func NewIngestionStatusModel(conn *Connection) *IngestionStatusModel {
 	return &IngestionStatusModel{
-		conn: conn,
+		conn:        conn,
+		tenantScope: DefaultScope,
 	}
 }
 
+// NewIngestionStatusModelWithTenant creates a new ingestion status model for the status of a tenant scope,
+// stored under tenant/ingestion_status in its defaulty collection where api-rest waits for it
+func NewIngestionStatusModelWithTenant(conn *Connection, tenantScope string) *IngestionStatusModel {
+	return &IngestionStatusModel{
+		conn:        conn,
+		tenantScope: tenantScope,
+	}
+}
+
+// statusKey returns the document key of the ingestion status of the scope
+func (ism *IngestionStatusModel) statusKey() string {
+	if ism.tenantScope == "" || ism.tenantScope == DefaultScope {
+		return IngestionStatusKey
+	}
+	return TenantIngestionStatusKey
+}
+
 // GetIngestionStatus retrieves the current ingestion status
 func (ism *IngestionStatusModel) GetIngestionStatus(ctx context.Context) (*IngestionStatus, error) {
-	collection := ism.conn.GetBucket().DefaultCollection()
+	return readIngestionStatus(ism.conn.stateCollection(ism.tenantScope), ism.statusKey())
+}
 
-	result, err := collection.Get(IngestionStatusKey, &gocb.GetOptions{})
+// readIngestionStatus reads the ingestion status document under key; a missing document is a status not ready yet
+func readIngestionStatus(collection documentGetter, key string) (*IngestionStatus, error) {
+	result, err := collection.Get(key, &gocb.GetOptions{})
 	if err != nil {
-		// Check if document doesn't exist (multiple ways to check this)
-		if errors.Is(err, gocb.ErrDocumentNotFound) ||
-			strings.Contains(err.Error(), "document not found") ||
-			strings.Contains(err.Error(), "Not Found") ||
-			strings.Contains(err.Error(), "KEY_ENOENT") {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
 			// No status document exists yet
 			return &IngestionStatus{Ready: false}, nil
 		}
@@ -61,29 +80,66 @@ func (ism *IngestionStatusModel) GetIngestionStatus(ctx context.Context) (*Inges
 
 // SetIngestionStatus sets the ingestion status
 func (ism *IngestionStatusModel) SetIngestionStatus(ctx context.Context, ready bool, message string) error {
-	collection := ism.conn.GetBucket().DefaultCollection()
-
-	status := IngestionStatus{
-		Ready:     ready,
-		StartedAt: time.Now().UTC(),
-		Message:   message,
-	}
+	collection := ism.conn.stateCollection(ism.tenantScope)
 
 	if ready {
-		status.CompletedAt = time.Now().UTC()
+		// Only touch completion fields so startedAt and resource counts are kept
+		_, err := collection.MutateIn(ism.statusKey(), []gocb.MutateInSpec{
+			gocb.UpsertSpec("ready", true, nil),
+			gocb.UpsertSpec("completedAt", time.Now().UTC(), nil),
+			gocb.UpsertSpec("message", message, nil),
+		}, &gocb.MutateInOptions{Context: ctx, StoreSemantic: gocb.StoreSemanticsUpsert})
+		if err != nil {
+			return fmt.Errorf("failed to set ingestion status: %w", err)
+		}
+
+		log.Info().Msg("✅ FHIR ingestion completed successfully")
+		return nil
 	}
 
-	_, err := collection.Upsert(IngestionStatusKey, status, &gocb.UpsertOptions{})
+	// Keep completedAt and resource counts of the previous run: completedAt tells a re-run from a first run,
+	// and the counts are what api-rest checks against its minimums. Filters are set again by the new run.
+	_, err := collection.MutateIn(ism.statusKey(), []gocb.MutateInSpec{
+		gocb.UpsertSpec("ready", false, nil),
+		gocb.UpsertSpec("startedAt", time.Now().UTC(), nil),
+		gocb.UpsertSpec("message", message, nil),
+		gocb.UpsertSpec("filters", map[string]string{}, nil),
+	}, &gocb.MutateInOptions{Context: ctx, StoreSemantic: gocb.StoreSemanticsUpsert})
 	if err != nil {
 		return fmt.Errorf("failed to set ingestion status: %w", err)
 	}
 
-	if ready {
-		log.Info().Msg("✅ FHIR ingestion completed successfully")
-	} else {
-		log.Info().Msg("📝 Ingestion status set to 'not ready'")
+	log.Info().Msg("📝 Ingestion status set to 'not ready'")
+	return nil
+}
+
+// SetResourceCount records how many resources of a type were ingested
+func (ism *IngestionStatusModel) SetResourceCount(ctx context.Context, resourceType string, count int) error {
+	collection := ism.conn.stateCollection(ism.tenantScope)
+
+	_, err := collection.MutateIn(ism.statusKey(), []gocb.MutateInSpec{
+		gocb.UpsertSpec("resourceCounts."+resourceType, count, &gocb.UpsertSpecOptions{CreatePath: true}),
+	}, &gocb.MutateInOptions{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("failed to set %s resource count: %w", resourceType, err)
+	}
+
+	log.Debug().Str("resource_type", resourceType).Int("count", count).Msg("Ingestion resource count updated")
+	return nil
+}
+
+// SetFilters records the filters applied to the ingested resources
+func (ism *IngestionStatusModel) SetFilters(ctx context.Context, filters map[string]string) error {
+	collection := ism.conn.stateCollection(ism.tenantScope)
+
+	_, err := collection.MutateIn(ism.statusKey(), []gocb.MutateInSpec{
+		gocb.UpsertSpec("filters", filters, nil),
+	}, &gocb.MutateInOptions{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("failed to set ingestion filters: %w", err)
 	}
 
+	log.Debug().Interface("filters", filters).Msg("Ingestion filters updated")
 	return nil
 }
 
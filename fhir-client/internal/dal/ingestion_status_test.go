@@ -0,0 +1,46 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// mockGetter fails every read with err
+type mockGetter struct {
+	err error
+}
+
+func (m *mockGetter) Get(id string, opts *gocb.GetOptions) (*gocb.GetResult, error) {
+	return nil, m.err
+}
+
+func TestReadIngestionStatusDocumentNotFound(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{name: "Missing document", err: gocb.ErrDocumentNotFound},
+		{name: "Wrapped missing document", err: fmt.Errorf("get %s: %w", IngestionStatusKey, gocb.ErrDocumentNotFound)},
+		{name: "Other error mentioning not found", err: errors.New("proxy returned 404 Not Found"), wantErr: true},
+		{name: "Timeout", err: gocb.ErrTimeout, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := readIngestionStatus(&mockGetter{err: tt.err}, IngestionStatusKey)
+			if tt.wantErr {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("Expected the read error to be returned, got %v", err)
+				}
+				return
+			}
+			if err != nil || status == nil || status.Ready {
+				t.Errorf("Expected a status not ready yet, got %+v, %v", status, err)
+			}
+		})
+	}
+}
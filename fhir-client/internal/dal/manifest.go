@@ -0,0 +1,65 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// IngestManifestKeyPrefix is the document key prefix for ingestion run manifests
+const IngestManifestKeyPrefix = "_system/ingest_manifest/"
+
+// Ingestion run outcomes stored in the manifest status
+const (
+	ManifestStatusSuccess = "success"
+	ManifestStatusFailure = "failure"
+)
+
+// IngestManifest is the audit record of a single ingestion run
+type IngestManifest struct {
+	RunID                           string            `json:"runId"`
+	Tenant                          string            `json:"tenant,omitempty"`
+	StartedAt                       time.Time         `json:"startedAt"`
+	CompletedAt                     time.Time         `json:"completedAt"`
+	ResourceCounts                  map[string]int    `json:"resourceCounts"`
+	FHIRServerURL                   string            `json:"fhirServerUrl"`
+	Filters                         map[string]string `json:"filters,omitempty"`
+	Status                          string            `json:"status"`
+	Error                           string            `json:"error,omitempty"`
+	FailedDocumentIDs               []string          `json:"failedDocumentIds"`
+	EncountersWithMissingReferences int               `json:"encountersWithMissingReferences"`
+}
+
+// ManifestModel represents the database model for ingestion run manifests
+type ManifestModel struct {
+	conn *Connection
+}
+
+// NewManifestModel creates a new manifest model
+func NewManifestModel(conn *Connection) *ManifestModel {
+	return &ManifestModel{
+		conn: conn,
+	}
+}
+
+// WriteManifest stores the manifest under _system/ingest_manifest/{runID}
+func (mm *ManifestModel) WriteManifest(ctx context.Context, manifest *IngestManifest) error {
+	collection := mm.conn.GetBucket().DefaultCollection()
+	docID := IngestManifestKeyPrefix + manifest.RunID
+
+	_, err := collection.Upsert(docID, manifest, &gocb.UpsertOptions{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("failed to write ingest manifest %s: %w", docID, err)
+	}
+
+	log.Info().
+		Str("doc_id", docID).
+		Str("status", manifest.Status).
+		Interface("resource_counts", manifest.ResourceCounts).
+		Int("failed_documents", len(manifest.FailedDocumentIDs)).
+		Msg("Ingest manifest written")
+	return nil
+}
@@ -0,0 +1,81 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+
+	"stealthcompany.com/fhir-client/internal/metrics"
+	"stealthcompany.com/pkg/fhirutil"
+)
+
+// MedicationRequestModel handles medication request-specific database operations
+type MedicationRequestModel struct {
+	resourceModel *ResourceModel
+}
+
+// NewMedicationRequestModel creates a new medication request model
+func NewMedicationRequestModel(resourceModel *ResourceModel) *MedicationRequestModel {
+	return &MedicationRequestModel{
+		resourceModel: resourceModel,
+	}
+}
+
+// UpsertMedicationRequest upserts a medication request resource
+func (mm *MedicationRequestModel) UpsertMedicationRequest(ctx context.Context, medicationRequestID string, data map[string]interface{}) error {
+	if err := prepareMedicationRequest(medicationRequestID, data); err != nil {
+		return err
+	}
+	return mm.resourceModel.UpsertResource(ctx, fmt.Sprintf("MedicationRequest/%s", medicationRequestID), data)
+}
+
+// BulkUpsertMedicationRequests upserts medication requests by ID with a single bulk write and returns the
+// failures by medication request ID
+func (mm *MedicationRequestModel) BulkUpsertMedicationRequests(ctx context.Context, medicationRequests map[string]map[string]interface{}) map[string]error {
+	return bulkUpsertResources(ctx, mm.resourceModel.BulkUpsert, "MedicationRequest", medicationRequests, prepareMedicationRequest)
+}
+
+// prepareMedicationRequest validates a medication request and adds its query fields
+func prepareMedicationRequest(medicationRequestID string, data map[string]interface{}) error {
+	if err := validateResource("MedicationRequest", medicationRequestID, data, isStrictValidation()); err != nil {
+		return err
+	}
+	denormalizeMedicationRequest(fmt.Sprintf("MedicationRequest/%s", medicationRequestID), data)
+	return nil
+}
+
+// GetMedicationRequest retrieves a medication request by ID
+func (mm *MedicationRequestModel) GetMedicationRequest(ctx context.Context, medicationRequestID string) (map[string]interface{}, error) {
+	docID := fmt.Sprintf("MedicationRequest/%s", medicationRequestID)
+	return mm.resourceModel.GetResource(ctx, docID)
+}
+
+// MedicationRequestExists checks if a medication request exists
+func (mm *MedicationRequestModel) MedicationRequestExists(ctx context.Context, medicationRequestID string) (bool, error) {
+	docID := fmt.Sprintf("MedicationRequest/%s", medicationRequestID)
+	return mm.resourceModel.ResourceExists(ctx, docID)
+}
+
+// CountMedicationRequests counts all medication requests
+func (mm *MedicationRequestModel) CountMedicationRequests(ctx context.Context) (int64, error) {
+	return mm.resourceModel.CountResourcesByType(ctx, "MedicationRequest")
+}
+
+// denormalizeMedicationRequest adds the fields medication requests are queried by to the stored document;
+// status is already a top-level field of the resource and is indexed as is
+func denormalizeMedicationRequest(docID string, data map[string]interface{}) {
+	data["docId"] = docID
+	data["resourceType"] = "MedicationRequest"
+
+	// Extract and add patient and encounter references (bare IDs)
+	if patientRef := fhirutil.ExtractPatientRef(data, metrics.RecordReferenceParse); patientRef != "" {
+		data["subjectPatientId"] = patientRef
+	}
+	if encounterRef := fhirutil.ExtractEncounterRef(data, metrics.RecordReferenceParse); encounterRef != "" {
+		data["encounterId"] = encounterRef
+	}
+
+	// medicationCodeableConcept keeps its shape and its first coding is stored flat as medicationCode
+	if codes := codeableConceptCodes(data, "medicationCodeableConcept"); len(codes) > 0 {
+		data["medicationCode"] = codes[0]
+	}
+}
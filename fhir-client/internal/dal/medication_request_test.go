@@ -0,0 +1,55 @@
+package dal
+
+import "testing"
+
+func TestDenormalizeMedicationRequest(t *testing.T) {
+	data := map[string]interface{}{
+		"resourceType": "MedicationRequest",
+		"id":           "m-1",
+		"status":       "active",
+		"subject":      map[string]interface{}{"reference": "Patient/p-1"},
+		"encounter":    map[string]interface{}{"reference": "Encounter/e-1"},
+		"medicationCodeableConcept": map[string]interface{}{
+			"coding": []interface{}{
+				map[string]interface{}{"system": "http://www.nlm.nih.gov/research/umls/rxnorm", "code": "860975"},
+				map[string]interface{}{"system": "http://snomed.info/sct", "code": "325072002"},
+			},
+		},
+	}
+
+	denormalizeMedicationRequest("MedicationRequest/m-1", data)
+
+	expected := map[string]string{
+		"docId":            "MedicationRequest/m-1",
+		"resourceType":     "MedicationRequest",
+		"subjectPatientId": "p-1",
+		"encounterId":      "e-1",
+		"medicationCode":   "860975",
+		"status":           "active",
+	}
+	for field, want := range expected {
+		if got, _ := data[field].(string); got != want {
+			t.Errorf("Expected %s %q, got %q", field, want, got)
+		}
+	}
+	if _, ok := data["medicationCodeableConcept"].(map[string]interface{}); !ok {
+		t.Errorf("Expected medicationCodeableConcept to keep its shape, got %v", data["medicationCodeableConcept"])
+	}
+}
+
+func TestDenormalizeMedicationRequestWithoutReferences(t *testing.T) {
+	data := map[string]interface{}{
+		"resourceType":        "MedicationRequest",
+		"id":                  "m-2",
+		"subject":             map[string]interface{}{"reference": "Group/g-1"},
+		"medicationReference": map[string]interface{}{"reference": "Medication/med-1"},
+	}
+
+	denormalizeMedicationRequest("MedicationRequest/m-2", data)
+
+	for _, field := range []string{"subjectPatientId", "encounterId", "medicationCode"} {
+		if value, ok := data[field]; ok {
+			t.Errorf("Expected no %s, got %v", field, value)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// MissingReferencesKey is the document key of the set of encounters whose references could not be synced
+const MissingReferencesKey = "template/encounters_with_missing_references"
+
+// MissingReferencesModel represents the database model for encounters with missing references
+type MissingReferencesModel struct {
+	conn        *Connection
+	tenantScope string
+}
+
+// NewMissingReferencesModel creates a new missing references model
+func NewMissingReferencesModel(conn *Connection) *MissingReferencesModel {
+	return &MissingReferencesModel{
+		conn:        conn,
+		tenantScope: DefaultScope,
+	}
+}
+
+// NewMissingReferencesModelWithTenant creates a new missing references model for the encounters of a tenant scope
+func NewMissingReferencesModelWithTenant(conn *Connection, tenantScope string) *MissingReferencesModel {
+	return &MissingReferencesModel{
+		conn:        conn,
+		tenantScope: tenantScope,
+	}
+}
+
+// AddEncounter adds an encounter to the set, creating the document when it does not exist yet
+func (mrm *MissingReferencesModel) AddEncounter(ctx context.Context, encounterID string) error {
+	collection := mrm.conn.stateCollection(mrm.tenantScope)
+
+	_, err := collection.MutateIn(MissingReferencesKey, []gocb.MutateInSpec{
+		gocb.ArrayAddUniqueSpec("encounterIds", encounterID, &gocb.ArrayAddUniqueSpecOptions{CreatePath: true}),
+	}, &gocb.MutateInOptions{Context: ctx, StoreSemantic: gocb.StoreSemanticsUpsert})
+	if errors.Is(err, gocb.ErrPathExists) {
+		// Already in the set
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add encounter %s to missing references: %w", encounterID, err)
+	}
+
+	log.Debug().Str("encounter_id", encounterID).Msg("Encounter recorded with missing references")
+	return nil
+}
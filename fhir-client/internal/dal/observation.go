@@ -0,0 +1,172 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"stealthcompany.com/fhir-client/internal/metrics"
+	"stealthcompany.com/pkg/fhirutil"
+)
+
+// ErrObservationCodeNotAllowed is returned by UpsertObservation for observations whose code is not in FHIR_OBSERVATION_CODES
+var ErrObservationCodeNotAllowed = errors.New("observation code not allowed")
+
+// ObservationCodesFromEnv reads FHIR_OBSERVATION_CODES, the comma-separated LOINC/SNOMED codes of the
+// observations to ingest (e.g. "85354-9,29463-7"). An empty list means every observation is ingested.
+func ObservationCodesFromEnv() []string {
+	var codes []string
+	for _, code := range strings.Split(getEnvOrDefault("FHIR_OBSERVATION_CODES", ""), ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// ObservationModel handles observation-specific database operations
+type ObservationModel struct {
+	resourceModel *ResourceModel
+}
+
+// NewObservationModel creates a new observation model
+func NewObservationModel(resourceModel *ResourceModel) *ObservationModel {
+	return &ObservationModel{
+		resourceModel: resourceModel,
+	}
+}
+
+// UpsertObservation upserts an observation resource, unless FHIR_OBSERVATION_CODES is set and none of its codes is listed
+func (om *ObservationModel) UpsertObservation(ctx context.Context, observationID string, data map[string]interface{}) error {
+	if err := prepareObservation(observationID, data); err != nil {
+		return err
+	}
+	return om.resourceModel.UpsertResource(ctx, fmt.Sprintf("Observation/%s", observationID), data)
+}
+
+// BulkUpsertObservations upserts observations by ID with a single bulk write and returns the failures by
+// observation ID, ErrObservationCodeNotAllowed for those filtered out by FHIR_OBSERVATION_CODES
+func (om *ObservationModel) BulkUpsertObservations(ctx context.Context, observations map[string]map[string]interface{}) map[string]error {
+	return bulkUpsertResources(ctx, om.resourceModel.BulkUpsert, "Observation", observations, prepareObservation)
+}
+
+// prepareObservation validates an observation, checks its code against FHIR_OBSERVATION_CODES and adds its query fields
+func prepareObservation(observationID string, data map[string]interface{}) error {
+	if err := validateResource("Observation", observationID, data, isStrictValidation()); err != nil {
+		return err
+	}
+
+	// The search already filters by code, this also covers servers ignoring the code parameter
+	if allowed := ObservationCodesFromEnv(); len(allowed) > 0 && !observationCodeAllowed(data, allowed) {
+		return fmt.Errorf("%w: Observation/%s", ErrObservationCodeNotAllowed, observationID)
+	}
+
+	docID := fmt.Sprintf("Observation/%s", observationID)
+
+	// Denormalize fields for better querying
+	data["docId"] = docID
+	data["resourceType"] = "Observation"
+
+	// Extract and add patient and encounter references (bare IDs)
+	if patientRef := fhirutil.ExtractPatientRef(data, metrics.RecordReferenceParse); patientRef != "" {
+		data["subjectPatientId"] = patientRef
+	}
+	if encounterRef := fhirutil.ExtractEncounterRef(data, metrics.RecordReferenceParse); encounterRef != "" {
+		data["encounterId"] = encounterRef
+	}
+
+	// The FHIR code field is a CodeableConcept, so its first coding is stored flat as observationCode
+	if codes := codingCodes(data); len(codes) > 0 {
+		data["observationCode"] = codes[0]
+	}
+
+	if effective := observationEffectiveDateTime(data); effective != "" {
+		data["effectiveDateTime"] = effective
+	}
+
+	return nil
+}
+
+// GetObservation retrieves an observation by ID
+func (om *ObservationModel) GetObservation(ctx context.Context, observationID string) (map[string]interface{}, error) {
+	docID := fmt.Sprintf("Observation/%s", observationID)
+	return om.resourceModel.GetResource(ctx, docID)
+}
+
+// ObservationExists checks if an observation exists
+func (om *ObservationModel) ObservationExists(ctx context.Context, observationID string) (bool, error) {
+	docID := fmt.Sprintf("Observation/%s", observationID)
+	return om.resourceModel.ResourceExists(ctx, docID)
+}
+
+// CountObservations counts all observations
+func (om *ObservationModel) CountObservations(ctx context.Context) (int64, error) {
+	return om.resourceModel.CountResourcesByType(ctx, "Observation")
+}
+
+// GetAllObservations retrieves all observations
+func (om *ObservationModel) GetAllObservations(ctx context.Context) ([]ResourceRow, error) {
+	return om.resourceModel.GetAllResourcesByType(ctx, "Observation")
+}
+
+// codingCodes returns the codes of the codings of a resource's code, e.g. an observation or a condition (LOINC, SNOMED, ...)
+func codingCodes(data map[string]interface{}) []string {
+	return codeableConceptCodes(data, "code")
+}
+
+// codeableConceptCodes returns the codes of the codings of a CodeableConcept field of a resource
+func codeableConceptCodes(data map[string]interface{}, field string) []string {
+	var codes []string
+
+	code, ok := data[field].(map[string]interface{})
+	if !ok {
+		return codes
+	}
+
+	codings, ok := code["coding"].([]interface{})
+	if !ok {
+		return codes
+	}
+
+	for _, coding := range codings {
+		c, ok := coding.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if value, ok := c["code"].(string); ok && value != "" {
+			codes = append(codes, value)
+		}
+	}
+
+	return codes
+}
+
+// observationCodeAllowed checks if any coding of an observation's code is in the allowed list
+func observationCodeAllowed(data map[string]interface{}, allowed []string) bool {
+	for _, code := range codingCodes(data) {
+		for _, allowedCode := range allowed {
+			if code == allowedCode {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// observationEffectiveDateTime returns when an observation took effect, from effectiveDateTime,
+// the start of effectivePeriod or effectiveInstant, so observations can be sorted by a single field
+func observationEffectiveDateTime(data map[string]interface{}) string {
+	if effective, ok := data["effectiveDateTime"].(string); ok && effective != "" {
+		return effective
+	}
+	if period, ok := data["effectivePeriod"].(map[string]interface{}); ok {
+		if start, ok := period["start"].(string); ok && start != "" {
+			return start
+		}
+	}
+	if instant, ok := data["effectiveInstant"].(string); ok {
+		return instant
+	}
+	return ""
+}
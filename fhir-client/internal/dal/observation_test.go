@@ -0,0 +1,97 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func newTestObservation(codes ...string) map[string]interface{} {
+	codings := make([]interface{}, 0, len(codes))
+	for _, code := range codes {
+		codings = append(codings, map[string]interface{}{"system": "http://loinc.org", "code": code})
+	}
+	return map[string]interface{}{
+		"resourceType": "Observation",
+		"id":           "1",
+		"status":       "final",
+		"code":         map[string]interface{}{"coding": codings},
+	}
+}
+
+func TestObservationCodesFromEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{name: "unset", value: "", want: nil},
+		{name: "single code", value: "85354-9", want: []string{"85354-9"}},
+		{name: "spaces and empty entries", value: " 85354-9, ,29463-7 ", want: []string{"85354-9", "29463-7"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FHIR_OBSERVATION_CODES", tt.value)
+			if got := ObservationCodesFromEnv(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ObservationCodesFromEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObservationCodeAllowed(t *testing.T) {
+	allowed := []string{"85354-9", "29463-7"}
+
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		want bool
+	}{
+		{name: "allowed code", data: newTestObservation("29463-7"), want: true},
+		{name: "allowed secondary coding", data: newTestObservation("8867-4", "85354-9"), want: true},
+		{name: "code not allowed", data: newTestObservation("8867-4"), want: false},
+		{name: "no code", data: map[string]interface{}{"resourceType": "Observation"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := observationCodeAllowed(tt.data, allowed); got != tt.want {
+				t.Errorf("observationCodeAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpsertObservationSkipsCodeNotAllowed(t *testing.T) {
+	t.Setenv("FHIR_OBSERVATION_CODES", "85354-9,29463-7")
+
+	// The observation is rejected before any database access
+	model := NewObservationModel(nil)
+	err := model.UpsertObservation(context.Background(), "1", newTestObservation("8867-4"))
+	if !errors.Is(err, ErrObservationCodeNotAllowed) {
+		t.Errorf("Expected ErrObservationCodeNotAllowed, got %v", err)
+	}
+}
+
+func TestObservationEffectiveDateTime(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		want string
+	}{
+		{name: "dateTime", data: map[string]interface{}{"effectiveDateTime": "2024-03-01T10:00:00Z"}, want: "2024-03-01T10:00:00Z"},
+		{name: "period start", data: map[string]interface{}{"effectivePeriod": map[string]interface{}{"start": "2024-03-01"}}, want: "2024-03-01"},
+		{name: "instant", data: map[string]interface{}{"effectiveInstant": "2024-03-01T10:00:00.000Z"}, want: "2024-03-01T10:00:00.000Z"},
+		{name: "none", data: map[string]interface{}{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := observationEffectiveDateTime(tt.data); got != tt.want {
+				t.Errorf("observationEffectiveDateTime() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
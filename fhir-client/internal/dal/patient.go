@@ -19,13 +19,29 @@ func NewPatientModel(resourceModel *ResourceModel) *PatientModel {
 
 // UpsertPatient upserts a patient resource
 func (pm *PatientModel) UpsertPatient(ctx context.Context, patientID string, data map[string]interface{}) error {
+	if err := preparePatient(patientID, data); err != nil {
+		return err
+	}
+	return pm.resourceModel.UpsertResource(ctx, fmt.Sprintf("Patient/%s", patientID), data)
+}
+
+// BulkUpsertPatients upserts patients by ID with a single bulk write and returns the failures by patient ID
+func (pm *PatientModel) BulkUpsertPatients(ctx context.Context, patients map[string]map[string]interface{}) map[string]error {
+	return bulkUpsertResources(ctx, pm.resourceModel.BulkUpsert, "Patient", patients, preparePatient)
+}
+
+// preparePatient validates a patient and adds its query fields
+func preparePatient(patientID string, data map[string]interface{}) error {
+	if err := validateResource("Patient", patientID, data, isStrictValidation()); err != nil {
+		return err
+	}
+
 	docID := fmt.Sprintf("Patient/%s", patientID)
 
 	// Denormalize fields for better querying
 	data["docId"] = docID
 	data["resourceType"] = "Patient"
-
-	return pm.resourceModel.UpsertResource(ctx, docID, data)
+	return nil
 }
 
 // GetPatient retrieves a patient by ID
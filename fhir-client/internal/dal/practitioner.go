@@ -19,13 +19,29 @@ func NewPractitionerModel(resourceModel *ResourceModel) *PractitionerModel {
 
 // UpsertPractitioner upserts a practitioner resource
 func (pm *PractitionerModel) UpsertPractitioner(ctx context.Context, practitionerID string, data map[string]interface{}) error {
+	if err := preparePractitioner(practitionerID, data); err != nil {
+		return err
+	}
+	return pm.resourceModel.UpsertResource(ctx, fmt.Sprintf("Practitioner/%s", practitionerID), data)
+}
+
+// BulkUpsertPractitioners upserts practitioners by ID with a single bulk write and returns the failures by practitioner ID
+func (pm *PractitionerModel) BulkUpsertPractitioners(ctx context.Context, practitioners map[string]map[string]interface{}) map[string]error {
+	return bulkUpsertResources(ctx, pm.resourceModel.BulkUpsert, "Practitioner", practitioners, preparePractitioner)
+}
+
+// preparePractitioner validates a practitioner and adds its query fields
+func preparePractitioner(practitionerID string, data map[string]interface{}) error {
+	if err := validateResource("Practitioner", practitionerID, data, isStrictValidation()); err != nil {
+		return err
+	}
+
 	docID := fmt.Sprintf("Practitioner/%s", practitionerID)
 
 	// Denormalize fields for better querying
 	data["docId"] = docID
 	data["resourceType"] = "Practitioner"
-
-	return pm.resourceModel.UpsertResource(ctx, docID, data)
+	return nil
 }
 
 // GetPractitioner retrieves a practitioner by ID
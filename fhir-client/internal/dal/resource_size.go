@@ -0,0 +1,51 @@
+package dal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"stealthcompany.com/fhir-client/internal/metrics"
+)
+
+// defaultMaxResourceSizeBytes is the default FHIR_MAX_RESOURCE_SIZE_BYTES (5MB)
+const defaultMaxResourceSizeBytes = 5 << 20
+
+// ErrResourceTooLarge is returned when a resource exceeds FHIR_MAX_RESOURCE_SIZE_BYTES
+var ErrResourceTooLarge = errors.New("resource exceeds maximum size")
+
+// getMaxResourceSizeBytes reads FHIR_MAX_RESOURCE_SIZE_BYTES (default 5MB)
+func getMaxResourceSizeBytes() int {
+	maxSize, err := strconv.Atoi(getEnvOrDefault("FHIR_MAX_RESOURCE_SIZE_BYTES", strconv.Itoa(defaultMaxResourceSizeBytes)))
+	if err != nil || maxSize <= 0 {
+		return defaultMaxResourceSizeBytes
+	}
+	return maxSize
+}
+
+// checkResourceSize records the JSON size of a resource and rejects it when above the maximum
+func checkResourceSize(docID string, data map[string]interface{}) error {
+	resourceType := strings.Split(docID, "/")[0]
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode resource %s: %w", docID, err)
+	}
+
+	size := len(encoded)
+	metrics.RecordResourceSize(resourceType, size)
+
+	if maxSize := getMaxResourceSizeBytes(); size > maxSize {
+		metrics.RecordResourceSizeExceeded(resourceType)
+		log.Warn().
+			Str("doc_id", docID).
+			Int("size_bytes", size).
+			Int("max_size_bytes", maxSize).
+			Msg("Resource too large, not stored")
+		return fmt.Errorf("%w: %s is %d bytes, maximum %d", ErrResourceTooLarge, docID, size, maxSize)
+	}
+	return nil
+}
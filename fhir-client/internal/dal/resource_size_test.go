@@ -0,0 +1,60 @@
+package dal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckResourceSize(t *testing.T) {
+	t.Setenv("FHIR_MAX_RESOURCE_SIZE_BYTES", "1024")
+
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "small resource",
+			data: map[string]interface{}{"resourceType": "Encounter", "id": "1"},
+		},
+		{
+			name:    "resource above maximum",
+			data:    map[string]interface{}{"resourceType": "Patient", "id": "1", "photo": strings.Repeat("a", 2048)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkResourceSize(tt.data["resourceType"].(string)+"/1", tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkResourceSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrResourceTooLarge) {
+				t.Errorf("Expected ErrResourceTooLarge, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGetMaxResourceSizeBytes(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{value: "", want: 5 << 20},
+		{value: "1048576", want: 1 << 20},
+		{value: "0", want: 5 << 20},
+		{value: "big", want: 5 << 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("FHIR_MAX_RESOURCE_SIZE_BYTES", tt.value)
+			if got := getMaxResourceSizeBytes(); got != tt.want {
+				t.Errorf("getMaxResourceSizeBytes() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
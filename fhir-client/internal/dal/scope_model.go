@@ -0,0 +1,121 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultScope is the scope ingested from FHIR_BASE_URL, which api-rest copies into the scope of a new tenant
+const DefaultScope = "_default"
+
+// TenantIngestionStatusKey is the document key of the ingestion status of a tenant scope, read by api-rest
+const TenantIngestionStatusKey = "tenant/ingestion_status"
+
+// tenantStateCollection is the collection of a tenant scope holding its ingestion state documents
+const tenantStateCollection = "defaulty"
+
+// tenantCollections are the collections of a tenant scope, as api-rest creates them
+var tenantCollections = []string{tenantStateCollection, "encounters", "patients", "practitioners", "observations", "conditions", "medication_requests"}
+
+// tenantIndexes are the indexes of a tenant scope. They follow api-rest ScopeModel.createCollectionIndexes,
+// since api-rest only indexes the tenant scopes it creates itself.
+var tenantIndexes = []struct {
+	collection string
+	indexName  string
+	fields     string
+}{
+	{"defaulty", "idx_defaulty_id", "id"},
+	{"defaulty", "idx_defaulty_ready", "ready"},
+	{"encounters", "idx_encounters_id", "id"},
+	{"encounters", "idx_encounters_resourceType", "resourceType"},
+	{"encounters", "idx_encounters_reviewed", "reviewed"},
+	{"encounters", "idx_encounters_status", "status"},
+	{"encounters", "idx_encounters_period_start", "period.`start`"},
+	{"encounters", "idx_encounters_status_date", "status, period.`start`"},
+	{"encounters", "idx_encounters_subjectPatientId", "subjectPatientId"},
+	{"encounters", "idx_encounters_practitionerIds", "practitionerIds"},
+	{"patients", "idx_patients_id", "id"},
+	{"patients", "idx_patients_resourceType", "resourceType"},
+	{"patients", "idx_patients_reviewed", "reviewed"},
+	{"practitioners", "idx_practitioners_id", "id"},
+	{"practitioners", "idx_practitioners_resourceType", "resourceType"},
+	{"practitioners", "idx_practitioners_reviewed", "reviewed"},
+	{"observations", "idx_observations_id", "id"},
+	{"observations", "idx_observations_resourceType", "resourceType"},
+	{"observations", "idx_observations_subjectPatientId", "subjectPatientId"},
+	{"observations", "idx_observations_encounterId", "encounterId"},
+	{"observations", "idx_observations_observationCode", "observationCode"},
+	{"observations", "idx_observations_effectiveDateTime", "effectiveDateTime"},
+	{"conditions", "idx_conditions_id", "id"},
+	{"conditions", "idx_conditions_resourceType", "resourceType"},
+	{"conditions", "idx_conditions_subjectPatientId", "subjectPatientId"},
+	{"conditions", "idx_conditions_encounterId", "encounterId"},
+	{"conditions", "idx_conditions_conditionCode", "conditionCode"},
+	{"medication_requests", "idx_medication_requests_id", "id"},
+	{"medication_requests", "idx_medication_requests_resourceType", "resourceType"},
+	{"medication_requests", "idx_medication_requests_subjectPatientId", "subjectPatientId"},
+	{"medication_requests", "idx_medication_requests_encounterId", "encounterId"},
+	{"medication_requests", "idx_medication_requests_medicationCode", "medicationCode"},
+	{"medication_requests", "idx_medication_requests_status", "status"},
+}
+
+// ScopeModel represents the database model for the tenant scopes ingested from their own FHIR server
+type ScopeModel struct {
+	conn *Connection
+}
+
+// NewScopeModel creates a new scope model
+func NewScopeModel(conn *Connection) *ScopeModel {
+	return &ScopeModel{
+		conn: conn,
+	}
+}
+
+// EnsureTenantScope creates a tenant scope with its collections and indexes, so the tenant is ingested straight
+// into it. Existing scopes, collections and indexes are kept, and api-rest finds the scope already there.
+func (sm *ScopeModel) EnsureTenantScope(ctx context.Context, tenantScope string) error {
+	if err := ValidateTenantScope(tenantScope); err != nil {
+		return err
+	}
+
+	log.Info().Str("tenant", tenantScope).Msg("Ensuring tenant scope exists")
+	if err := runSchemaStatements(ctx, sm.conn.GetCluster(), tenantSchemaStatements(sm.conn.GetBucketName(), tenantScope)); err != nil {
+		return fmt.Errorf("failed to create tenant scope %s: %w", tenantScope, err)
+	}
+	return nil
+}
+
+// ValidateTenantScope checks that a tenant ID can be used as its scope name; the default and system
+// scopes, whose names start with "_", hold the shared data
+func ValidateTenantScope(tenantScope string) error {
+	if tenantScope == "" || strings.HasPrefix(tenantScope, "_") {
+		return fmt.Errorf("invalid tenant scope %q", tenantScope)
+	}
+	return nil
+}
+
+// tenantSchemaStatements lists the N1QL statements creating a tenant scope, its collections, then its indexes
+func tenantSchemaStatements(bucketName, tenantScope string) []string {
+	statements := []string{fmt.Sprintf("CREATE SCOPE `%s`.`%s`", bucketName, tenantScope)}
+	for _, collectionName := range tenantCollections {
+		statements = append(statements, fmt.Sprintf("CREATE COLLECTION `%s`.`%s`.`%s`", bucketName, tenantScope, collectionName))
+	}
+	for _, idx := range tenantIndexes {
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS `%s` ON `%s`.`%s`.`%s`(%s)",
+			idx.indexName, bucketName, tenantScope, idx.collection, idx.fields))
+	}
+	return statements
+}
+
+// stateCollection returns the collection holding the ingestion state documents of a scope:
+// the default collection of the bucket, or the defaulty collection of a tenant scope
+func (c *Connection) stateCollection(tenantScope string) *gocb.Collection {
+	if tenantScope == "" || tenantScope == DefaultScope {
+		return c.GetBucket().DefaultCollection()
+	}
+	return c.GetBucket().Scope(tenantScope).Collection(tenantStateCollection)
+}
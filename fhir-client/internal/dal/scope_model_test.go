@@ -0,0 +1,41 @@
+package dal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTenantScope(t *testing.T) {
+	for _, tenantScope := range []string{"acme", "tenant_1"} {
+		if err := ValidateTenantScope(tenantScope); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", tenantScope, err)
+		}
+	}
+	for _, tenantScope := range []string{"", DefaultScope, "_system"} {
+		if err := ValidateTenantScope(tenantScope); err == nil {
+			t.Errorf("Expected %q to be rejected", tenantScope)
+		}
+	}
+}
+
+func TestTenantSchemaStatements(t *testing.T) {
+	statements := tenantSchemaStatements("EvTeChallenge", "acme")
+
+	if want := len(tenantCollections) + len(tenantIndexes) + 1; len(statements) != want {
+		t.Fatalf("Expected %d statements, got %d", want, len(statements))
+	}
+	if statements[0] != "CREATE SCOPE `EvTeChallenge`.`acme`" {
+		t.Errorf("Expected the scope to be created first, got %s", statements[0])
+	}
+	for i, collectionName := range tenantCollections {
+		want := "CREATE COLLECTION `EvTeChallenge`.`acme`.`" + collectionName + "`"
+		if statements[i+1] != want {
+			t.Errorf("Expected %s, got %s", want, statements[i+1])
+		}
+	}
+	for _, statement := range statements[len(tenantCollections)+1:] {
+		if !strings.HasPrefix(statement, "CREATE INDEX IF NOT EXISTS") || !strings.Contains(statement, "`EvTeChallenge`.`acme`.") {
+			t.Errorf("Expected an index of the tenant scope, got %s", statement)
+		}
+	}
+}
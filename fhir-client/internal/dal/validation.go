@@ -0,0 +1,39 @@
+package dal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"stealthcompany.com/pkg/fhirvalidator"
+)
+
+// isStrictValidation checks if FHIR_STRICT_VALIDATION is enabled
+func isStrictValidation() bool {
+	return strings.EqualFold(getEnvOrDefault("FHIR_STRICT_VALIDATION", "false"), "true")
+}
+
+// validateResource validates a resource before upsert, failing only in strict mode
+func validateResource(resourceType, resourceID string, data map[string]interface{}, strict bool) error {
+	validationErrs := fhirvalidator.ValidateResource(resourceType, data)
+	if len(validationErrs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(validationErrs))
+	for _, validationErr := range validationErrs {
+		messages = append(messages, validationErr.Error())
+	}
+	details := strings.Join(messages, "; ")
+
+	if strict {
+		return fmt.Errorf("%w %s/%s: %s", fhirvalidator.ErrInvalidResource, resourceType, resourceID, details)
+	}
+
+	log.Warn().
+		Str("validation_errors", details).
+		Str("resource_type", resourceType).
+		Str("resource_id", resourceID).
+		Msg("FHIR resource failed validation, upserting anyway")
+	return nil
+}
@@ -0,0 +1,66 @@
+package dal
+
+import (
+	"errors"
+	"testing"
+
+	"stealthcompany.com/pkg/fhirvalidator"
+)
+
+func TestValidateResourceStrictMode(t *testing.T) {
+	valid := map[string]interface{}{"resourceType": "Encounter", "id": "1", "status": "finished"}
+	invalid := map[string]interface{}{"resourceType": "Encounter", "id": "1"}
+
+	tests := []struct {
+		name        string
+		data        map[string]interface{}
+		strict      bool
+		expectedErr bool
+	}{
+		{
+			name:        "Valid resource in strict mode",
+			data:        valid,
+			strict:      true,
+			expectedErr: false,
+		},
+		{
+			name:        "Invalid resource only warns by default",
+			data:        invalid,
+			strict:      false,
+			expectedErr: false,
+		},
+		{
+			name:        "Invalid resource fails in strict mode",
+			data:        invalid,
+			strict:      true,
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResource("Encounter", "1", tt.data, tt.strict)
+			if tt.expectedErr {
+				if !errors.Is(err, fhirvalidator.ErrInvalidResource) {
+					t.Errorf("Expected ErrInvalidResource, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsStrictValidation(t *testing.T) {
+	t.Setenv("FHIR_STRICT_VALIDATION", "")
+	if isStrictValidation() {
+		t.Errorf("Expected strict validation to be disabled by default")
+	}
+
+	t.Setenv("FHIR_STRICT_VALIDATION", "true")
+	if !isStrictValidation() {
+		t.Errorf("Expected strict validation to be enabled")
+	}
+}
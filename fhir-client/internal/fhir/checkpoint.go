@@ -0,0 +1,118 @@
+package fhir
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// checkpointStore is the part of dal.IngestionCheckpointModel used for incremental ingestion
+type checkpointStore interface {
+	GetCheckpoint(ctx context.Context, resourceType string) (time.Time, error)
+	SetCheckpoint(ctx context.Context, resourceType string, lastSyncedAt time.Time) error
+}
+
+// incrementalSearchURL appends a _lastUpdated filter from the checkpoint of the resource type.
+// Without a checkpoint, or when it can't be read, the full search runs.
+// Checkpoints exist once a run completed, so only runs after it (once the status is reset to not ready,
+// or after a failed run) are incremental.
+func (c *Client) incrementalSearchURL(ctx context.Context, resourceType, searchURL string) string {
+	if c.checkpoints == nil {
+		return searchURL
+	}
+
+	lastSyncedAt, err := c.checkpoints.GetCheckpoint(ctx, resourceType)
+	if err != nil {
+		log.Warn().Err(err).Str("resource_type", resourceType).Msg("Failed to read ingestion checkpoint, fetching all resources")
+		return searchURL
+	}
+	if lastSyncedAt.IsZero() {
+		return searchURL
+	}
+
+	log.Info().
+		Str("resource_type", resourceType).
+		Time("last_synced_at", lastSyncedAt).
+		Msg("Fetching resources updated since the last ingestion")
+	c.recordIncremental(resourceType)
+	return searchURL + "&_lastUpdated=" + url.QueryEscape("gt"+lastSyncedAt.UTC().Format(time.RFC3339))
+}
+
+// advanceCheckpoints moves the checkpoint of every searched resource type to the start of the run.
+// Types with documents that failed to ingest keep their checkpoint so the next run fetches them again.
+func (c *Client) advanceCheckpoints(ctx context.Context) {
+	if c.checkpoints == nil || c.run == nil {
+		return
+	}
+
+	for _, resourceType := range c.run.checkpointTypes() {
+		if err := c.checkpoints.SetCheckpoint(ctx, resourceType, c.run.startedAt); err != nil {
+			log.Warn().Err(err).Str("resource_type", resourceType).Msg("Failed to advance ingestion checkpoint")
+		}
+	}
+}
+
+// recordSearched marks a resource type as fetched by search in the current run, if any
+func (c *Client) recordSearched(resourceType string) {
+	if c.run != nil {
+		c.run.recordSearched(resourceType)
+	}
+}
+
+// recordSearched marks a resource type as fetched by search
+func (r *ingestRun) recordSearched(resourceType string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.searched[resourceType] = struct{}{}
+}
+
+// recordIncremental marks a resource type as fetched since its checkpoint in the current run, if any
+func (c *Client) recordIncremental(resourceType string) {
+	if c.run != nil {
+		c.run.recordIncremental(resourceType)
+	}
+}
+
+// isIncremental checks if a resource type was fetched since its checkpoint in the current run
+func (c *Client) isIncremental(resourceType string) bool {
+	return c.run != nil && c.run.isIncremental(resourceType)
+}
+
+// recordIncremental marks a resource type as fetched since its checkpoint
+func (r *ingestRun) recordIncremental(resourceType string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.incremental[resourceType] = struct{}{}
+}
+
+// isIncremental checks if a resource type was fetched since its checkpoint
+func (r *ingestRun) isIncremental(resourceType string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.incremental[resourceType]
+	return ok
+}
+
+// checkpointTypes returns the searched resource types without failed documents
+func (r *ingestRun) checkpointTypes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failed := make(map[string]bool)
+	for _, docID := range r.failedDocIDs {
+		if resourceType, _, ok := strings.Cut(docID, "/"); ok {
+			failed[resourceType] = true
+		}
+	}
+
+	var types []string
+	for resourceType := range r.searched {
+		if !failed[resourceType] {
+			types = append(types, resourceType)
+		}
+	}
+	return types
+}
@@ -0,0 +1,140 @@
+package fhir
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+// memoryCheckpointStore keeps checkpoints in memory
+type memoryCheckpointStore struct {
+	checkpoints map[string]time.Time
+	err         error
+}
+
+func (m *memoryCheckpointStore) GetCheckpoint(ctx context.Context, resourceType string) (time.Time, error) {
+	return m.checkpoints[resourceType], m.err
+}
+
+func (m *memoryCheckpointStore) SetCheckpoint(ctx context.Context, resourceType string, lastSyncedAt time.Time) error {
+	m.checkpoints[resourceType] = lastSyncedAt
+	return nil
+}
+
+func TestIncrementalSearchURL(t *testing.T) {
+	lastSync := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		store checkpointStore
+		want  string
+	}{
+		{name: "no checkpoint store", want: "http://fhir/Encounter?_count=500"},
+		{
+			name:  "no checkpoint",
+			store: &memoryCheckpointStore{checkpoints: map[string]time.Time{}},
+			want:  "http://fhir/Encounter?_count=500",
+		},
+		{
+			name:  "checkpoint",
+			store: &memoryCheckpointStore{checkpoints: map[string]time.Time{"Encounter": lastSync}},
+			want:  "http://fhir/Encounter?_count=500&_lastUpdated=gt2024-03-01T12%3A30%3A00Z",
+		},
+		{
+			name:  "unreadable checkpoint",
+			store: &memoryCheckpointStore{err: errors.New("timeout")},
+			want:  "http://fhir/Encounter?_count=500",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{}
+			if tt.store != nil {
+				client.checkpoints = tt.store
+			}
+			got := client.incrementalSearchURL(context.Background(), "Encounter", "http://fhir/Encounter?_count=500")
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFetchSearchPageUsesCheckpoint(t *testing.T) {
+	var lastUpdated string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastUpdated = r.URL.Query().Get("_lastUpdated")
+		w.Header().Set("Content-Type", "application/fhir+json")
+		w.Write([]byte(`{"resourceType":"Bundle","entry":[]}`))
+	}))
+	defer server.Close()
+
+	store := &memoryCheckpointStore{checkpoints: map[string]time.Time{
+		"Patient": time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+	}}
+	client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL, checkpoints: store, run: newIngestRun()}
+
+	if _, err := client.fetchSearchPage(context.Background(), "Patient", client.searchURL("Patient")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lastUpdated != "gt2024-03-01T12:30:00Z" {
+		t.Errorf("Expected _lastUpdated=gt2024-03-01T12:30:00Z, got %q", lastUpdated)
+	}
+
+	if _, err := client.fetchSearchPage(context.Background(), "Encounter", client.searchURL("Encounter")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lastUpdated != "" {
+		t.Errorf("Expected a full fetch without a checkpoint, got _lastUpdated=%q", lastUpdated)
+	}
+}
+
+func TestAdvanceCheckpoints(t *testing.T) {
+	store := &memoryCheckpointStore{checkpoints: map[string]time.Time{}}
+	client := &Client{checkpoints: store, run: newIngestRun()}
+
+	// Encounter and Patient were searched, but a patient failed; Practitioner was never searched
+	client.recordSearched("Encounter")
+	client.recordSearched("Patient")
+	client.recordIngestFailure("Patient/p1")
+
+	client.advanceCheckpoints(context.Background())
+
+	var advanced []string
+	for resourceType, lastSyncedAt := range store.checkpoints {
+		advanced = append(advanced, resourceType)
+		if !lastSyncedAt.Equal(client.run.startedAt) {
+			t.Errorf("Expected %s checkpoint at the run start %v, got %v", resourceType, client.run.startedAt, lastSyncedAt)
+		}
+	}
+	sort.Strings(advanced)
+	if len(advanced) != 1 || advanced[0] != "Encounter" {
+		t.Errorf("Expected only the Encounter checkpoint to advance, got %v", advanced)
+	}
+}
+
+func TestTruncatedSearchKeepsCheckpoint(t *testing.T) {
+	server, _ := pagedBundleServer(t, 5)
+	previous := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	store := &memoryCheckpointStore{checkpoints: map[string]time.Time{"Encounter": previous}}
+	client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL, maxPages: 2, checkpoints: store, run: newIngestRun()}
+
+	resources, err := client.fetchSearchPage(context.Background(), "Encounter", server.URL+"/Encounter?page=0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(resources) != 2 {
+		t.Fatalf("Expected the 2 resources of the fetched pages, got %d", len(resources))
+	}
+
+	client.advanceCheckpoints(context.Background())
+
+	if got := store.checkpoints["Encounter"]; !got.Equal(previous) {
+		t.Errorf("Expected the Encounter checkpoint to stay at %v after a truncated search, got %v", previous, got)
+	}
+}
@@ -1,30 +1,62 @@
 package fhir
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"stealthcompany.com/fhir-client/internal/dal"
 )
 
 // Client represents the FHIR client for data ingestion
 type Client struct {
-	httpClient        *http.Client
-	dal               *dal.Connection
-	encounterModel    *dal.EncounterModel
-	patientModel      *dal.PatientModel
-	practitionerModel *dal.PractitionerModel
-	fhirBaseURL       string
-	timeout           time.Duration
+	httpClient             *http.Client
+	dal                    *dal.Connection
+	resourceModel          *dal.ResourceModel
+	encounterModel         *dal.EncounterModel
+	patientModel           patientStore
+	practitionerModel      practitionerStore
+	observationModel       observationStore
+	conditionModel         conditionStore
+	medicationRequestModel medicationRequestStore
+	fhirBaseURL            string
+	timeout                time.Duration
+	encounterFilter        EncounterFilter
+	observationCodes       []string
+	pageSizes              PageSizes
+	maxPages               int
+	retryPolicy            RetryPolicy
+	includePatient         bool
+	parallelIngestion      bool
+	manifestWriter         manifestWriter
+	run                    *ingestRun
+	practitionersSource    string
+	encounterPractitioners *practitionerRefSet
+	missingReferences      missingReferenceStore
+	checkpoints            checkpointStore
+	ingestionStatus        ingestionStatusStore
+	// skipSyncIfFreshIngestion skips syncExistingData when no ingestion ever completed,
+	// since ingestEncounters already syncs the references of every new encounter
+	skipSyncIfFreshIngestion bool
+	// tenantID is the tenant scope ingested into, empty for the default scope
+	tenantID          string
+	tenants           []TenantFHIRConfig
+	tenantConcurrency int
 }
 
-// NewClient creates a new FHIR client
-func NewClient() (*Client, error) {
+// NewClient creates a new FHIR client; ctx is the service startup context
+func NewClient(ctx context.Context) (*Client, error) {
 	var err error
 
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("startup cancelled: %w", err)
+	}
+
 	// Get configuration from environment
 	fhirBaseURL := getEnvOrDefault("FHIR_BASE_URL", "https://hapi.fhir.org/baseR4")
 	timeoutStr := getEnvOrDefault("FHIR_TIMEOUT", "30s")
@@ -33,9 +65,50 @@ func NewClient() (*Client, error) {
 		timeout = 30 * time.Second
 	}
 
-	// Create HTTP client
+	encounterFilter, err := encounterFilterFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("invalid encounter filter: %w", err)
+	}
+
+	pageSizes, err := pageSizesFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("invalid page size: %w", err)
+	}
+
+	observationCodes := dal.ObservationCodesFromEnv()
+
+	maxPages, err := maxPagesFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	retryPolicy, err := retryPolicyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	includePatient, _ := strconv.ParseBool(getEnvOrDefault("FHIR_ENCOUNTER_INCLUDE_PATIENT", "false"))
+	parallelIngestion, _ := strconv.ParseBool(getEnvOrDefault("FHIR_PARALLEL_INGESTION", "false"))
+
+	practitionersSource, err := practitionersSourceFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	tenants, err := tenantConfigsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	tenantConcurrency, err := tenantIngestionConcurrencyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create HTTP client, propagating the trace context to the FHIR server
 	httpClient := &http.Client{
-		Timeout: timeout,
+		Timeout:   timeout,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
 	}
 
 	// Connect to Couchbase via DAL
@@ -49,22 +122,63 @@ func NewClient() (*Client, error) {
 	encounterModel := dal.NewEncounterModel(resourceModel)
 	patientModel := dal.NewPatientModel(resourceModel)
 	practitionerModel := dal.NewPractitionerModel(resourceModel)
+	observationModel := dal.NewObservationModel(resourceModel)
+	conditionModel := dal.NewConditionModel(resourceModel)
+	medicationRequestModel := dal.NewMedicationRequestModel(resourceModel)
 
 	log.Info().
 		Str("fhir_base_url", fhirBaseURL).
+		Interface("encounter_filter", encounterFilter.Map()).
+		Strs("observation_codes", observationCodes).
+		Interface("page_sizes", pageSizes).
+		Int("max_pages", maxPages).
+		Interface("retry_policy", retryPolicy).
+		Bool("include_patient", includePatient).
+		Bool("parallel_ingestion", parallelIngestion).
+		Str("practitioners_source", practitionersSource).
+		Int("tenants", len(tenants)).
+		Int("tenant_ingestion_concurrency", tenantConcurrency).
 		Msg("FHIR client initialized successfully")
 
 	return &Client{
-		httpClient:        httpClient,
-		dal:               dalConn,
-		encounterModel:    encounterModel,
-		patientModel:      patientModel,
-		practitionerModel: practitionerModel,
-		fhirBaseURL:       fhirBaseURL,
-		timeout:           timeout,
+		httpClient:             httpClient,
+		dal:                    dalConn,
+		resourceModel:          resourceModel,
+		encounterModel:         encounterModel,
+		patientModel:           patientModel,
+		practitionerModel:      practitionerModel,
+		observationModel:       observationModel,
+		conditionModel:         conditionModel,
+		medicationRequestModel: medicationRequestModel,
+		fhirBaseURL:            fhirBaseURL,
+		timeout:                timeout,
+		encounterFilter:        encounterFilter,
+		observationCodes:       observationCodes,
+		pageSizes:              pageSizes,
+		maxPages:               maxPages,
+		retryPolicy:            retryPolicy,
+		includePatient:         includePatient,
+		parallelIngestion:      parallelIngestion,
+		manifestWriter:         dal.NewManifestModel(dalConn),
+		practitionersSource:    practitionersSource,
+		encounterPractitioners: &practitionerRefSet{},
+		missingReferences:      dal.NewMissingReferencesModel(dalConn),
+		checkpoints:            dal.NewIngestionCheckpointModel(dalConn),
+		ingestionStatus:        dal.NewIngestionStatusModel(dalConn),
+		tenants:                tenants,
+		tenantConcurrency:      tenantConcurrency,
 	}, nil
 }
 
+// Init creates the Couchbase collections and indexes before ingestion starts,
+// so they are not created lazily by the first upsert and can be cancelled on shutdown
+func (c *Client) Init(ctx context.Context) error {
+	if err := c.resourceModel.EnsureCollectionsAndIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create collections and indexes: %w", err)
+	}
+	return nil
+}
+
 // Close closes the FHIR client and returns connection to pool
 func (c *Client) Close() error {
 	if c.dal != nil {
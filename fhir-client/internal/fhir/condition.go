@@ -0,0 +1,8 @@
+package fhir
+
+import "context"
+
+// conditionStore is the part of dal.ConditionModel used to ingest conditions
+type conditionStore interface {
+	BulkUpsertConditions(ctx context.Context, conditions map[string]map[string]interface{}) map[string]error
+}
@@ -0,0 +1,120 @@
+package fhir
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// encounterStatuses lists the valid FHIR R4 Encounter status codes
+var encounterStatuses = []string{
+	"planned",
+	"arrived",
+	"triaged",
+	"in-progress",
+	"onleave",
+	"finished",
+	"cancelled",
+	"entered-in-error",
+	"unknown",
+}
+
+// encounterFilterDateLayout is the FHIR date format accepted for the date range filter
+const encounterFilterDateLayout = "2006-01-02"
+
+// EncounterFilter restricts the encounters fetched from the FHIR API
+type EncounterFilter struct {
+	Status   string
+	DateFrom string
+	DateTo   string
+}
+
+// encounterFilterFromEnv reads and validates FHIR_ENCOUNTER_STATUS_FILTER,
+// FHIR_ENCOUNTER_DATE_FROM and FHIR_ENCOUNTER_DATE_TO
+func encounterFilterFromEnv() (EncounterFilter, error) {
+	filter := EncounterFilter{
+		Status:   getEnvOrDefault("FHIR_ENCOUNTER_STATUS_FILTER", ""),
+		DateFrom: getEnvOrDefault("FHIR_ENCOUNTER_DATE_FROM", ""),
+		DateTo:   getEnvOrDefault("FHIR_ENCOUNTER_DATE_TO", ""),
+	}
+	if err := filter.Validate(); err != nil {
+		return EncounterFilter{}, err
+	}
+	return filter, nil
+}
+
+// Validate checks that the status is a comma-separated list of Encounter status codes
+// and that the dates are YYYY-MM-DD with DateFrom not after DateTo
+func (f EncounterFilter) Validate() error {
+	if f.Status != "" {
+		for _, status := range strings.Split(f.Status, ",") {
+			if !slices.Contains(encounterStatuses, status) {
+				return fmt.Errorf("invalid FHIR_ENCOUNTER_STATUS_FILTER status: %q", status)
+			}
+		}
+	}
+
+	var from, to time.Time
+	var err error
+	if f.DateFrom != "" {
+		if from, err = time.Parse(encounterFilterDateLayout, f.DateFrom); err != nil {
+			return fmt.Errorf("invalid FHIR_ENCOUNTER_DATE_FROM %q, expected YYYY-MM-DD", f.DateFrom)
+		}
+	}
+	if f.DateTo != "" {
+		if to, err = time.Parse(encounterFilterDateLayout, f.DateTo); err != nil {
+			return fmt.Errorf("invalid FHIR_ENCOUNTER_DATE_TO %q, expected YYYY-MM-DD", f.DateTo)
+		}
+	}
+	if f.DateFrom != "" && f.DateTo != "" && from.After(to) {
+		return fmt.Errorf("FHIR_ENCOUNTER_DATE_FROM %s is after FHIR_ENCOUNTER_DATE_TO %s", f.DateFrom, f.DateTo)
+	}
+
+	return nil
+}
+
+// IsActive reports whether any filter is set
+func (f EncounterFilter) IsActive() bool {
+	return f.Status != "" || f.DateFrom != "" || f.DateTo != ""
+}
+
+// queryParams returns the FHIR search parameters for the filter
+func (f EncounterFilter) queryParams() url.Values {
+	params := url.Values{}
+	if f.Status != "" {
+		params.Set("status", f.Status)
+	}
+	if f.DateFrom != "" {
+		params.Add("date", "ge"+f.DateFrom)
+	}
+	if f.DateTo != "" {
+		params.Add("date", "le"+f.DateTo)
+	}
+	return params
+}
+
+// Map returns the active filters keyed by name, as stored in the ingestion status
+func (f EncounterFilter) Map() map[string]string {
+	filters := make(map[string]string)
+	if f.Status != "" {
+		filters["status"] = f.Status
+	}
+	if f.DateFrom != "" {
+		filters["dateFrom"] = f.DateFrom
+	}
+	if f.DateTo != "" {
+		filters["dateTo"] = f.DateTo
+	}
+	return filters
+}
+
+// encounterSearchURL builds the Encounter search URL with the configured filters
+func (c *Client) encounterSearchURL() string {
+	searchURL := c.searchURL("Encounter")
+	if params := c.encounterFilter.queryParams(); len(params) > 0 {
+		searchURL += "&" + params.Encode()
+	}
+	return searchURL
+}
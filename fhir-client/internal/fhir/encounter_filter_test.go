@@ -0,0 +1,107 @@
+package fhir
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestEncounterFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  EncounterFilter
+		wantErr bool
+	}{
+		{name: "empty filter", filter: EncounterFilter{}},
+		{name: "single status", filter: EncounterFilter{Status: "finished"}},
+		{name: "multiple statuses", filter: EncounterFilter{Status: "finished,in-progress"}},
+		{name: "unknown status", filter: EncounterFilter{Status: "done"}, wantErr: true},
+		{name: "date range", filter: EncounterFilter{DateFrom: "2024-01-01", DateTo: "2024-12-31"}},
+		{name: "open ended range", filter: EncounterFilter{DateFrom: "2024-01-01"}},
+		{name: "invalid date from", filter: EncounterFilter{DateFrom: "01/01/2024"}, wantErr: true},
+		{name: "invalid date to", filter: EncounterFilter{DateTo: "2024-13-01"}, wantErr: true},
+		{name: "reversed range", filter: EncounterFilter{DateFrom: "2024-12-31", DateTo: "2024-01-01"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncounterFilterFromEnv(t *testing.T) {
+	t.Setenv("FHIR_ENCOUNTER_STATUS_FILTER", "finished")
+	t.Setenv("FHIR_ENCOUNTER_DATE_FROM", "2024-01-01")
+	t.Setenv("FHIR_ENCOUNTER_DATE_TO", "")
+
+	filter, err := encounterFilterFromEnv()
+	if err != nil {
+		t.Fatalf("encounterFilterFromEnv() error = %v", err)
+	}
+	want := map[string]string{"status": "finished", "dateFrom": "2024-01-01"}
+	if got := filter.Map(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Map() = %v, want %v", got, want)
+	}
+
+	t.Setenv("FHIR_ENCOUNTER_STATUS_FILTER", "done")
+	if _, err := encounterFilterFromEnv(); err == nil {
+		t.Error("encounterFilterFromEnv() with invalid status returned no error")
+	}
+}
+
+func TestEncounterSearchURLFilterParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter EncounterFilter
+		want   url.Values
+	}{
+		{
+			name:   "no filter",
+			filter: EncounterFilter{},
+			want:   url.Values{"_count": {"500"}},
+		},
+		{
+			name:   "status filter",
+			filter: EncounterFilter{Status: "finished"},
+			want:   url.Values{"_count": {"500"}, "status": {"finished"}},
+		},
+		{
+			name:   "date range filter",
+			filter: EncounterFilter{DateFrom: "2024-01-01", DateTo: "2024-12-31"},
+			want:   url.Values{"_count": {"500"}, "date": {"ge2024-01-01", "le2024-12-31"}},
+		},
+		{
+			name:   "status and date filter",
+			filter: EncounterFilter{Status: "finished", DateTo: "2024-12-31"},
+			want:   url.Values{"_count": {"500"}, "status": {"finished"}, "date": {"le2024-12-31"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.URL.Query()
+				w.Header().Set("Content-Type", "application/fhir+json")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"resourceType":"Bundle","entry":[]}`))
+			}))
+			defer server.Close()
+
+			client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL, encounterFilter: tt.filter}
+			if _, _, err := client.fetchFHIRBundle(context.Background(), "Encounter", client.encounterSearchURL()); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Request query = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,20 +3,83 @@ package fhir
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/rs/zerolog/log"
 	"stealthcompany.com/fhir-client/internal/metrics"
+	"stealthcompany.com/fhir-client/internal/tracing"
 )
 
-// fetchFHIRBundle fetches a FHIR bundle from the given URL
-func (c *Client) fetchFHIRBundle(ctx context.Context, url string) ([]FHIRResource, error) {
+// ErrUnexpectedContentType is returned when the FHIR server responds with a non-JSON body
+var ErrUnexpectedContentType = errors.New("unexpected content type in FHIR response")
+
+// validateFHIRContentType checks that a FHIR response carries a JSON body
+func validateFHIRContentType(resp *http.Response) error {
+	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
+	if strings.HasPrefix(contentType, "application/fhir+json") || strings.HasPrefix(contentType, "application/json") {
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrUnexpectedContentType, resp.Header.Get("Content-Type"))
+}
+
+// fetchFHIRBundle fetches a FHIR search bundle from the given URL and follows its next links,
+// stopping after maxPages pages; truncated reports that pages were left unfetched
+func (c *Client) fetchFHIRBundle(ctx context.Context, resourceType, url string) (_ []FHIRResource, truncated bool, err error) {
+	ctx, span := tracing.StartFetchSpan(ctx, "Client.fetchFHIRBundle", resourceType)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	maxPages := c.maxPages
+	if maxPages <= 0 {
+		maxPages = defaultFHIRMaxPages
+	}
+
+	var resources []FHIRResource
+	pages := 0
+	for url != "" {
+		if pages == maxPages {
+			log.Warn().
+				Str("resource_type", resourceType).
+				Int("max_pages", maxPages).
+				Int("fetched", len(resources)).
+				Msg("FHIR search has more pages than FHIR_MAX_PAGES, stopping")
+			truncated = true
+			break
+		}
+
+		var page []FHIRResource
+		var next string
+		err = c.retryWithBackoff(ctx, resourceType+" bundle", func() error {
+			var err error
+			page, next, err = c.fetchBundlePage(ctx, resourceType, url)
+			return err
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("page %d: %w", pages+1, err)
+		}
+		resources = append(resources, page...)
+		pages++
+		url = next
+	}
+
+	log.Debug().
+		Str("resource_type", resourceType).
+		Int("pages", pages).
+		Int("resources", len(resources)).
+		Msg("Fetched FHIR search bundle")
+	return resources, truncated, nil
+}
+
+// fetchBundlePage fetches a single bundle page and returns its resources and the URL of the next page
+func (c *Client) fetchBundlePage(ctx context.Context, resourceType, url string) ([]FHIRResource, string, error) {
 	var err error
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	fetchStart := time.Now()
@@ -24,25 +87,31 @@ func (c *Client) fetchFHIRBundle(ctx context.Context, url string) ([]FHIRResourc
 	fetchDuration := time.Since(fetchStart)
 
 	if err != nil {
-		metrics.RecordHTTPFetch("bundle_fetch", "error")
+		metrics.RecordHTTPFetch("bundle_fetch", resourceType, "error")
 		metrics.RecordHTTPFetchDuration("bundle_fetch", fetchDuration)
-		return nil, fmt.Errorf("failed to fetch FHIR bundle: %w", err)
+		return nil, "", &retryableError{err: fmt.Errorf("failed to fetch FHIR bundle: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		metrics.RecordHTTPFetch("bundle_fetch", "error")
+		metrics.RecordHTTPFetch("bundle_fetch", resourceType, "error")
+		metrics.RecordHTTPFetchDuration("bundle_fetch", fetchDuration)
+		return nil, "", statusError(resp, fmt.Errorf("FHIR API returned status %d", resp.StatusCode))
+	}
+
+	if err := validateFHIRContentType(resp); err != nil {
+		metrics.RecordHTTPFetch("bundle_fetch", resourceType, "error")
 		metrics.RecordHTTPFetchDuration("bundle_fetch", fetchDuration)
-		return nil, fmt.Errorf("FHIR API returned status %d", resp.StatusCode)
+		return nil, "", err
 	}
 
-	metrics.RecordHTTPFetch("bundle_fetch", "success")
+	metrics.RecordHTTPFetch("bundle_fetch", resourceType, "success")
 	metrics.RecordHTTPFetchDuration("bundle_fetch", fetchDuration)
 
 	var bundle FHIRBundle
 	err = json.NewDecoder(resp.Body).Decode(&bundle)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode FHIR bundle: %w", err)
+		return nil, "", fmt.Errorf("failed to decode FHIR bundle: %w", err)
 	}
 
 	var resources []FHIRResource
@@ -64,11 +133,22 @@ func (c *Client) fetchFHIRBundle(ctx context.Context, url string) ([]FHIRResourc
 		}
 	}
 
-	return resources, nil
+	return resources, bundle.nextLink(), nil
 }
 
-// fetchPatientFromAPI fetches a single patient from FHIR API
+// fetchPatientFromAPI fetches a single patient from FHIR API, retrying with the client retry policy
 func (c *Client) fetchPatientFromAPI(ctx context.Context, patientID string) (map[string]interface{}, error) {
+	var patientData map[string]interface{}
+	err := c.retryWithBackoff(ctx, "Patient/"+patientID, func() error {
+		var err error
+		patientData, err = c.fetchPatientAttempt(ctx, patientID)
+		return err
+	})
+	return patientData, err
+}
+
+// fetchPatientAttempt makes a single request for a patient
+func (c *Client) fetchPatientAttempt(ctx context.Context, patientID string) (map[string]interface{}, error) {
 	url := fmt.Sprintf("%s/Patient/%s", c.fhirBaseURL, patientID)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -81,23 +161,31 @@ func (c *Client) fetchPatientFromAPI(ctx context.Context, patientID string) (map
 
 	if err != nil {
 		metrics.RecordFHIRAPICall("Patient", "error")
-		metrics.RecordHTTPFetch("resource_fetch", "error")
+		metrics.RecordHTTPFetch("resource_fetch", "Patient", "error")
 		metrics.RecordHTTPFetchDuration("resource_fetch", fetchDuration)
 		metrics.RecordFHIRAPICallDuration("Patient", "individual", fetchDuration)
-		return nil, fmt.Errorf("failed to fetch patient: %w", err)
+		return nil, &retryableError{err: fmt.Errorf("failed to fetch patient: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		metrics.RecordFHIRAPICall("Patient", "error")
-		metrics.RecordHTTPFetch("resource_fetch", "error")
+		metrics.RecordHTTPFetch("resource_fetch", "Patient", "error")
+		metrics.RecordHTTPFetchDuration("resource_fetch", fetchDuration)
+		metrics.RecordFHIRAPICallDuration("Patient", "individual", fetchDuration)
+		return nil, statusError(resp, fmt.Errorf("FHIR API returned status %d for patient", resp.StatusCode))
+	}
+
+	if err := validateFHIRContentType(resp); err != nil {
+		metrics.RecordFHIRAPICall("Patient", "error")
+		metrics.RecordHTTPFetch("resource_fetch", "Patient", "error")
 		metrics.RecordHTTPFetchDuration("resource_fetch", fetchDuration)
 		metrics.RecordFHIRAPICallDuration("Patient", "individual", fetchDuration)
-		return nil, fmt.Errorf("FHIR API returned status %d for patient", resp.StatusCode)
+		return nil, fmt.Errorf("invalid patient response: %w", err)
 	}
 
 	metrics.RecordFHIRAPICall("Patient", "success")
-	metrics.RecordHTTPFetch("resource_fetch", "success")
+	metrics.RecordHTTPFetch("resource_fetch", "Patient", "success")
 	metrics.RecordHTTPFetchDuration("resource_fetch", fetchDuration)
 	metrics.RecordFHIRAPICallDuration("Patient", "individual", fetchDuration)
 
@@ -110,8 +198,19 @@ func (c *Client) fetchPatientFromAPI(ctx context.Context, patientID string) (map
 	return patientData, nil
 }
 
-// fetchPractitionerFromAPI fetches a single practitioner from FHIR API
+// fetchPractitionerFromAPI fetches a single practitioner from FHIR API, retrying with the client retry policy
 func (c *Client) fetchPractitionerFromAPI(ctx context.Context, practitionerID string) (map[string]interface{}, error) {
+	var practitionerData map[string]interface{}
+	err := c.retryWithBackoff(ctx, "Practitioner/"+practitionerID, func() error {
+		var err error
+		practitionerData, err = c.fetchPractitionerAttempt(ctx, practitionerID)
+		return err
+	})
+	return practitionerData, err
+}
+
+// fetchPractitionerAttempt makes a single request for a practitioner
+func (c *Client) fetchPractitionerAttempt(ctx context.Context, practitionerID string) (map[string]interface{}, error) {
 	url := fmt.Sprintf("%s/Practitioner/%s", c.fhirBaseURL, practitionerID)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -124,23 +223,31 @@ func (c *Client) fetchPractitionerFromAPI(ctx context.Context, practitionerID st
 
 	if err != nil {
 		metrics.RecordFHIRAPICall("Practitioner", "error")
-		metrics.RecordHTTPFetch("resource_fetch", "error")
+		metrics.RecordHTTPFetch("resource_fetch", "Practitioner", "error")
 		metrics.RecordHTTPFetchDuration("resource_fetch", fetchDuration)
 		metrics.RecordFHIRAPICallDuration("Practitioner", "individual", fetchDuration)
-		return nil, fmt.Errorf("failed to fetch practitioner: %w", err)
+		return nil, &retryableError{err: fmt.Errorf("failed to fetch practitioner: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		metrics.RecordFHIRAPICall("Practitioner", "error")
-		metrics.RecordHTTPFetch("resource_fetch", "error")
+		metrics.RecordHTTPFetch("resource_fetch", "Practitioner", "error")
+		metrics.RecordHTTPFetchDuration("resource_fetch", fetchDuration)
+		metrics.RecordFHIRAPICallDuration("Practitioner", "individual", fetchDuration)
+		return nil, statusError(resp, fmt.Errorf("FHIR API returned status %d for practitioner", resp.StatusCode))
+	}
+
+	if err := validateFHIRContentType(resp); err != nil {
+		metrics.RecordFHIRAPICall("Practitioner", "error")
+		metrics.RecordHTTPFetch("resource_fetch", "Practitioner", "error")
 		metrics.RecordHTTPFetchDuration("resource_fetch", fetchDuration)
 		metrics.RecordFHIRAPICallDuration("Practitioner", "individual", fetchDuration)
-		return nil, fmt.Errorf("FHIR API returned status %d for practitioner", resp.StatusCode)
+		return nil, fmt.Errorf("invalid practitioner response: %w", err)
 	}
 
 	metrics.RecordFHIRAPICall("Practitioner", "success")
-	metrics.RecordHTTPFetch("resource_fetch", "success")
+	metrics.RecordHTTPFetch("resource_fetch", "Practitioner", "success")
 	metrics.RecordHTTPFetchDuration("resource_fetch", fetchDuration)
 	metrics.RecordFHIRAPICallDuration("Practitioner", "individual", fetchDuration)
 
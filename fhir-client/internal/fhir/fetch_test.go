@@ -0,0 +1,154 @@
+package fhir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"stealthcompany.com/fhir-client/internal/metrics"
+)
+
+func TestFetchFHIRBundleContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expectedErr error
+		expectedLen int
+	}{
+		{
+			name:        "HTML error page is rejected",
+			contentType: "text/html; charset=utf-8",
+			body:        "<html><body>Not a FHIR server</body></html>",
+			expectedErr: ErrUnexpectedContentType,
+		},
+		{
+			name:        "FHIR JSON is accepted",
+			contentType: "application/fhir+json;charset=UTF-8",
+			body:        `{"resourceType":"Bundle","entry":[{"resource":{"resourceType":"Encounter","id":"1"}}]}`,
+			expectedLen: 1,
+		},
+		{
+			name:        "Plain JSON is accepted",
+			contentType: "application/json",
+			body:        `{"resourceType":"Bundle","entry":[]}`,
+			expectedLen: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL}
+			resources, _, err := client.fetchFHIRBundle(context.Background(), "Encounter", server.URL+"/Encounter")
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("Expected error %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(resources) != tt.expectedLen {
+				t.Errorf("Expected %d resources, got %d", tt.expectedLen, len(resources))
+			}
+		})
+	}
+}
+
+// pagedBundleServer serves pages of one encounter each, linking every page to the next one
+func pagedBundleServer(t *testing.T, pages int) (*httptest.Server, *int) {
+	t.Helper()
+
+	requests := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		next := ""
+		if page+1 < pages {
+			next = fmt.Sprintf(`{"relation":"next","url":"%s/Encounter?page=%d"},`, server.URL, page+1)
+		}
+		w.Header().Set("Content-Type", "application/fhir+json")
+		fmt.Fprintf(w, `{"resourceType":"Bundle","link":[%s{"relation":"self","url":"%s"}],`+
+			`"entry":[{"resource":{"resourceType":"Encounter","id":"e%d"}}]}`, next, r.URL, page)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestFetchFHIRBundleFollowsNextLinks(t *testing.T) {
+	server, requests := pagedBundleServer(t, 3)
+	client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL}
+	pagesBefore := promtestutil.ToFloat64(metrics.HTTPFetchTotal.WithLabelValues("bundle_fetch", "Encounter", "success"))
+
+	resources, truncated, err := client.fetchFHIRBundle(context.Background(), "Encounter", server.URL+"/Encounter?page=0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if truncated {
+		t.Error("Expected a complete search not to be truncated")
+	}
+	if len(resources) != 3 || *requests != 3 {
+		t.Fatalf("Expected 3 resources from 3 pages, got %d resources from %d requests", len(resources), *requests)
+	}
+	for i, resource := range resources {
+		if want := fmt.Sprintf("e%d", i); resource.ID != want {
+			t.Errorf("Expected resource %d to be %s, got %s", i, want, resource.ID)
+		}
+	}
+
+	pagesAfter := promtestutil.ToFloat64(metrics.HTTPFetchTotal.WithLabelValues("bundle_fetch", "Encounter", "success"))
+	if got := pagesAfter - pagesBefore; got != 3 {
+		t.Errorf("Expected 3 page fetches recorded for Encounter, got %v", got)
+	}
+}
+
+func TestFetchFHIRBundleMaxPages(t *testing.T) {
+	server, requests := pagedBundleServer(t, 10)
+	client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL, maxPages: 2}
+
+	resources, truncated, err := client.fetchFHIRBundle(context.Background(), "Encounter", server.URL+"/Encounter?page=0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !truncated {
+		t.Error("Expected the search stopped by maxPages to be truncated")
+	}
+	if len(resources) != 2 || *requests != 2 {
+		t.Errorf("Expected traversal to stop after 2 pages, got %d resources from %d requests", len(resources), *requests)
+	}
+}
+
+func TestFetchFHIRBundlePageError(t *testing.T) {
+	requests := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/fhir+json")
+		fmt.Fprintf(w, `{"resourceType":"Bundle","link":[{"relation":"next","url":"%s/Encounter?page=1"}],"entry":[]}`, server.URL)
+	}))
+	defer server.Close()
+
+	client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL}
+	if _, _, err := client.fetchFHIRBundle(context.Background(), "Encounter", server.URL+"/Encounter"); err == nil {
+		t.Error("Expected an error when a later page fails")
+	}
+}
@@ -2,16 +2,22 @@ package fhir
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/rs/zerolog/log"
+	"stealthcompany.com/fhir-client/internal/dal"
 	"stealthcompany.com/fhir-client/internal/metrics"
+	"stealthcompany.com/pkg/fhirutil"
+	"stealthcompany.com/pkg/fhirvalidator"
 )
 
 // IngestData performs the complete FHIR data ingestion process
-func (c *Client) IngestData(ctx context.Context) error {
-	var err error
-
+// and writes an audit manifest of the run once it has started
+func (c *Client) IngestData(ctx context.Context) (err error) {
 	log.Info().Msg("Starting FHIR data ingestion process")
 
 	// Step 0: Check and set ingestion status
@@ -25,28 +31,30 @@ func (c *Client) IngestData(ctx context.Context) error {
 		return fmt.Errorf("failed to check ingestion status (2): %w", err)
 	}
 
-	// Step 1: Check if database is empty and sync existing data
-	err = c.syncExistingData(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to sync existing data: %w", err)
-	}
+	// Write the manifest even when ingestion fails or is cancelled
+	c.run = newIngestRun()
+	defer func() {
+		c.writeManifest(context.WithoutCancel(ctx), err)
+	}()
 
-	// Step 2: Fetch and ingest new encounters
-	err = c.ingestEncounters(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to ingest encounters: %w", err)
+	// Record the active encounter filters so partial datasets are explained
+	if c.encounterFilter.IsActive() {
+		err = c.SetIngestionFilters(ctx, c.encounterFilter.Map())
+		if err != nil {
+			return fmt.Errorf("failed to record ingestion filters: %w", err)
+		}
 	}
 
-	// Step 3: Fetch and ingest new practitioners
-	err = c.ingestPractitioners(ctx)
+	// Step 1: Check if database is empty and sync existing data
+	err = c.syncExistingData(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to ingest practitioners: %w", err)
+		return fmt.Errorf("failed to sync existing data: %w", err)
 	}
 
-	// Step 4: Fetch and ingest new patients
-	err = c.ingestPatients(ctx)
+	// Steps 2-4: Fetch and ingest new encounters, practitioners and patients
+	err = c.ingestResources(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to ingest patients: %w", err)
+		return err
 	}
 
 	// Step 5: Mark ingestion as complete
@@ -55,97 +63,185 @@ func (c *Client) IngestData(ctx context.Context) error {
 		return fmt.Errorf("failed to set ingestion complete: %w", err)
 	}
 
+	// Step 6: Only a complete run moves the checkpoints, so the next run fetches just the updates
+	c.advanceCheckpoints(ctx)
+
 	log.Info().Msg("FHIR data ingestion completed successfully")
 	return nil
 }
 
-// ingestEncounters fetches and ingests new encounters from FHIR API
-func (c *Client) ingestEncounters(ctx context.Context) error {
-	var err error
-
-	log.Info().Msg("Fetching encounters from FHIR API")
-
-	url := fmt.Sprintf("%s/Encounter?_count=500", c.fhirBaseURL)
-	encounters, err := c.fetchFHIRBundle(ctx, url)
-	if err != nil {
-		return fmt.Errorf("failed to fetch encounters: %w", err)
+// ingestResources ingests encounters, practitioners and patients, one after the other
+// or concurrently with FHIR_PARALLEL_INGESTION
+func (c *Client) ingestResources(ctx context.Context) error {
+	steps := c.ingestSteps()
+	if !c.parallelIngestion {
+		for _, step := range steps {
+			if err := step(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
-	log.Info().Int("total_encounters", len(encounters)).Msg("Fetched encounters from FHIR API")
+	// Every step shares ctx, so cancelling it aborts all of them; all failures are returned
+	var wg sync.WaitGroup
+	errs := make([]error, len(steps))
+	for i, step := range steps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = step(ctx)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
 
-	var ingested, skipped int
-	for _, encounter := range encounters {
-		err = c.ingestEncounter(ctx, encounter)
+// ingestSteps returns the ingestion steps; with FHIR_PRACTITIONERS_SOURCE=encounters the practitioners
+// depend on the ingested encounters, so both run in a single step
+func (c *Client) ingestSteps() []func(context.Context) error {
+	encounters := func(ctx context.Context) error {
+		if err := c.ingestEncounters(ctx); err != nil {
+			return fmt.Errorf("failed to ingest encounters: %w", err)
+		}
+		return nil
+	}
+	practitioners := func(ctx context.Context) error {
+		var err error
+		if c.practitionersSource == PractitionersSourceEncounters {
+			err = c.ingestEncounterPractitioners(ctx)
+		} else {
+			err = c.ingestPractitioners(ctx)
+		}
 		if err != nil {
-			log.Warn().Err(err).Str("encounter_id", encounter.ID).Msg("Failed to ingest encounter")
-			skipped++
-			continue
+			return fmt.Errorf("failed to ingest practitioners: %w", err)
 		}
-		ingested++
+		return nil
+	}
+	patients := func(ctx context.Context) error {
+		if err := c.ingestPatients(ctx); err != nil {
+			return fmt.Errorf("failed to ingest patients: %w", err)
+		}
+		return nil
+	}
+	observations := func(ctx context.Context) error {
+		if err := c.ingestObservations(ctx); err != nil {
+			return fmt.Errorf("failed to ingest observations: %w", err)
+		}
+		return nil
+	}
+	conditions := func(ctx context.Context) error {
+		if err := c.ingestConditions(ctx); err != nil {
+			return fmt.Errorf("failed to ingest conditions: %w", err)
+		}
+		return nil
+	}
+	medicationRequests := func(ctx context.Context) error {
+		if err := c.ingestMedicationRequests(ctx); err != nil {
+			return fmt.Errorf("failed to ingest medication requests: %w", err)
+		}
+		return nil
 	}
 
-	log.Info().
-		Int("ingested", ingested).
-		Int("skipped", skipped).
-		Msg("Completed ingesting encounters")
+	if c.practitionersSource == PractitionersSourceEncounters {
+		return []func(context.Context) error{
+			func(ctx context.Context) error {
+				if err := encounters(ctx); err != nil {
+					return err
+				}
+				return practitioners(ctx)
+			},
+			patients,
+			observations,
+			conditions,
+			medicationRequests,
+		}
+	}
+	return []func(context.Context) error{encounters, practitioners, patients, observations, conditions, medicationRequests}
+}
 
-	metrics.RecordFHIRIngestion("encounters", ingested, skipped)
-	return nil
+// bulkUpsertFunc stores fetched resources by ID, returning the failures by resource ID.
+// The ingest functions wrap the store call in a closure, so a store is only used once its page is fetched.
+type bulkUpsertFunc func(ctx context.Context, resources map[string]map[string]interface{}) map[string]error
+
+// ingestEncounters fetches and ingests new encounters from FHIR API, syncing the resources they reference
+func (c *Client) ingestEncounters(ctx context.Context) error {
+	return c.ingestResourceType(ctx, "Encounter", c.encounterSearchURL(), func(ctx context.Context, encounters map[string]map[string]interface{}) map[string]error {
+		return c.encounterModel.BulkUpsertEncounters(ctx, encounters)
+	}, c.syncEncounterReferences)
 }
 
 // ingestPractitioners fetches and ingests new practitioners from FHIR API
 func (c *Client) ingestPractitioners(ctx context.Context) error {
-	var err error
-
-	log.Info().Msg("Fetching practitioners from FHIR API")
-
-	url := fmt.Sprintf("%s/Practitioner?_count=500", c.fhirBaseURL)
-	practitioners, err := c.fetchFHIRBundle(ctx, url)
-	if err != nil {
-		return fmt.Errorf("failed to fetch practitioners: %w", err)
-	}
+	return c.ingestResourceType(ctx, "Practitioner", c.searchURL("Practitioner"), func(ctx context.Context, practitioners map[string]map[string]interface{}) map[string]error {
+		return c.practitionerModel.BulkUpsertPractitioners(ctx, practitioners)
+	}, nil)
+}
 
-	log.Info().Int("total_practitioners", len(practitioners)).Msg("Fetched practitioners from FHIR API")
+// ingestPatients fetches and ingests new patients from FHIR API
+func (c *Client) ingestPatients(ctx context.Context) error {
+	return c.ingestResourceType(ctx, "Patient", c.searchURL("Patient"), func(ctx context.Context, patients map[string]map[string]interface{}) map[string]error {
+		return c.patientModel.BulkUpsertPatients(ctx, patients)
+	}, nil)
+}
 
-	var ingested, skipped int
-	for _, practitioner := range practitioners {
-		err = c.ingestPractitioner(ctx, practitioner)
-		if err != nil {
-			log.Debug().Err(err).Str("practitioner_id", practitioner.ID).Msg("Failed to ingest practitioner")
-			skipped++
-			continue
-		}
-		ingested++
-	}
+// ingestObservations fetches and ingests new observations from FHIR API
+func (c *Client) ingestObservations(ctx context.Context) error {
+	return c.ingestResourceType(ctx, "Observation", c.observationSearchURL(), func(ctx context.Context, observations map[string]map[string]interface{}) map[string]error {
+		return c.observationModel.BulkUpsertObservations(ctx, observations)
+	}, nil)
+}
 
-	log.Info().
-		Int("ingested", ingested).
-		Int("skipped", skipped).
-		Msg("Completed ingesting practitioners")
+// ingestConditions fetches and ingests new conditions from FHIR API
+func (c *Client) ingestConditions(ctx context.Context) error {
+	return c.ingestResourceType(ctx, "Condition", c.searchURL("Condition"), func(ctx context.Context, conditions map[string]map[string]interface{}) map[string]error {
+		return c.conditionModel.BulkUpsertConditions(ctx, conditions)
+	}, nil)
+}
 
-	metrics.RecordFHIRIngestion("practitioners", ingested, skipped)
-	return nil
+// ingestMedicationRequests fetches and ingests new medication requests from FHIR API
+func (c *Client) ingestMedicationRequests(ctx context.Context) error {
+	return c.ingestResourceType(ctx, "MedicationRequest", c.searchURL("MedicationRequest"), func(ctx context.Context, medicationRequests map[string]map[string]interface{}) map[string]error {
+		return c.medicationRequestModel.BulkUpsertMedicationRequests(ctx, medicationRequests)
+	}, nil)
 }
 
-// ingestPatients fetches and ingests new patients from FHIR API
-func (c *Client) ingestPatients(ctx context.Context) error {
-	var err error
+// ingestResourceType fetches a search page of resourceType from url and stores it with bulkUpsert,
+// running afterUpsert, when set, on every stored resource. Failed resources are skipped and recorded,
+// except invalid ones which stop the ingestion, and the ingested count is saved in the ingestion status.
+func (c *Client) ingestResourceType(ctx context.Context, resourceType, url string, bulkUpsert bulkUpsertFunc,
+	afterUpsert func(context.Context, FHIRResource) error) error {
+	label := resourceLabel(resourceType)
+	plural := strings.ReplaceAll(label, " ", "_") + "s"
 
-	log.Info().Msg("Fetching patients from FHIR API")
+	log.Info().Msgf("Fetching %ss from FHIR API", label)
 
-	url := fmt.Sprintf("%s/Patient?_count=500", c.fhirBaseURL)
-	patients, err := c.fetchFHIRBundle(ctx, url)
+	resources, err := c.fetchSearchPage(ctx, resourceType, url)
 	if err != nil {
-		return fmt.Errorf("failed to fetch patients: %w", err)
+		return fmt.Errorf("failed to fetch %ss: %w", label, err)
 	}
 
-	log.Info().Int("total_patients", len(patients)).Msg("Fetched patients from FHIR API")
+	log.Info().Int("total_"+plural, len(resources)).Msgf("Fetched %ss from FHIR API", label)
 
-	var ingested, skipped int
-	for _, patient := range patients {
-		err = c.ingestPatient(ctx, patient)
+	var ingested, skipped, filtered int
+	failures := bulkUpsert(ctx, resourcesByID(resources))
+	for _, resource := range resources {
+		err = failures[resource.ID]
+		if err == nil && afterUpsert != nil {
+			err = afterUpsert(ctx, resource)
+		}
+		if errors.Is(err, dal.ErrObservationCodeNotAllowed) {
+			// Not a failure: the observation is outside FHIR_OBSERVATION_CODES
+			filtered++
+			continue
+		}
+		if errors.Is(err, fhirvalidator.ErrInvalidResource) {
+			// Strict validation: stop ingestion instead of skipping the resource
+			return fmt.Errorf("failed to validate %s %s: %w", label, resource.ID, err)
+		}
 		if err != nil {
-			log.Debug().Err(err).Str("patient_id", patient.ID).Msg("Failed to ingest patient")
+			log.Warn().Err(err).Str("resource_id", resourceType+"/"+resource.ID).Msgf("Failed to ingest %s", label)
+			c.recordIngestFailure(resourceType + "/" + resource.ID)
 			skipped++
 			continue
 		}
@@ -155,36 +251,77 @@ func (c *Client) ingestPatients(ctx context.Context) error {
 	log.Info().
 		Int("ingested", ingested).
 		Int("skipped", skipped).
-		Msg("Completed ingesting patients")
+		Int("filtered", filtered).
+		Msgf("Completed ingesting %ss", label)
+
+	metrics.RecordFHIRIngestion(c.tenantID, plural, ingested, skipped)
 
-	metrics.RecordFHIRIngestion("patients", ingested, skipped)
+	err = c.SetIngestedResourceCount(ctx, resourceType, ingested)
+	if err != nil {
+		return fmt.Errorf("failed to record %s count: %w", label, err)
+	}
 	return nil
 }
 
-// ingestEncounter ingests a single encounter resource
-func (c *Client) ingestEncounter(ctx context.Context, resource FHIRResource) error {
-	err := c.encounterModel.UpsertEncounter(ctx, resource.ID, resource.Data)
-	if err != nil {
-		return fmt.Errorf("failed to upsert encounter: %w", err)
+// resourceLabel turns a resource type into lowercase words for logs and errors, e.g. "medication request"
+func resourceLabel(resourceType string) string {
+	var label strings.Builder
+	for i, r := range resourceType {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				label.WriteByte(' ')
+			}
+			r = unicode.ToLower(r)
+		}
+		label.WriteRune(r)
 	}
+	return label.String()
+}
+
+// resourcesByID maps the data of fetched resources by resource ID for a bulk upsert
+func resourcesByID(resources []FHIRResource) map[string]map[string]interface{} {
+	byID := make(map[string]map[string]interface{}, len(resources))
+	for _, resource := range resources {
+		byID[resource.ID] = resource.Data
+	}
+	return byID
+}
+
+// syncEncounterReferences syncs the patient and practitioners referenced by an upserted encounter
+func (c *Client) syncEncounterReferences(ctx context.Context, resource FHIRResource) error {
+	var err error
 
 	// Extract and sync related resources
-	patientRefs := c.extractPatientReferences(resource.Data)
-	practitionerRefs := c.extractPractitionerReferences(resource.Data)
+	patientRef := fhirutil.ExtractPatientRef(resource.Data)
+	practitionerRefs := fhirutil.ExtractPractitionerRefs(resource.Data)
 
-	// Sync patient references
-	for _, patientRef := range patientRefs {
+	// With FHIR_ENCOUNTER_INCLUDE_PATIENT the patient must be stored before the encounter counts as ingested
+	if patientRef != "" && c.includePatient {
+		if err := c.includeEncounterPatient(ctx, patientRef); err != nil {
+			return fmt.Errorf("failed to include patient %s: %w", patientRef, err)
+		}
+	} else if patientRef != "" {
 		err = c.syncPatient(ctx, patientRef)
 		if err != nil {
 			log.Debug().Err(err).Str("patient_ref", patientRef).Msg("Failed to sync patient")
+			// An encounter has a single patient, so its patient link is now broken
+			c.recordReferenceSyncError("Patient", err)
+			c.recordMissingReferences(ctx, resource.ID)
 		}
 	}
 
+	// With FHIR_PRACTITIONERS_SOURCE=encounters the practitioners are fetched once all encounters are in
+	if c.practitionersSource == PractitionersSourceEncounters {
+		c.collectPractitionerRefs(practitionerRefs)
+		return nil
+	}
+
 	// Sync practitioner references
 	for _, practitionerRef := range practitionerRefs {
 		err = c.syncPractitioner(ctx, practitionerRef)
 		if err != nil {
 			log.Debug().Err(err).Str("practitioner_ref", practitionerRef).Msg("Failed to sync practitioner")
+			c.recordReferenceSyncError("Practitioner", err)
 		}
 	}
 
@@ -199,12 +336,3 @@ func (c *Client) ingestPractitioner(ctx context.Context, resource FHIRResource)
 	}
 	return nil
 }
-
-// ingestPatient ingests a single patient resource
-func (c *Client) ingestPatient(ctx context.Context, resource FHIRResource) error {
-	err := c.patientModel.UpsertPatient(ctx, resource.ID, resource.Data)
-	if err != nil {
-		return fmt.Errorf("failed to upsert patient: %w", err)
-	}
-	return nil
-}
@@ -9,15 +9,20 @@ import (
 	"stealthcompany.com/fhir-client/internal/dal"
 )
 
+// ingestionStatusStore is the part of dal.IngestionStatusModel used to track the ingestion status of a scope
+type ingestionStatusStore interface {
+	GetIngestionStatus(ctx context.Context) (*dal.IngestionStatus, error)
+	SetIngestionStatus(ctx context.Context, ready bool, message string) error
+	SetResourceCount(ctx context.Context, resourceType string, count int) error
+	SetFilters(ctx context.Context, filters map[string]string) error
+}
+
 // CheckAndSetIngestionStatus checks if ingestion is already complete and sets initial status
 func (c *Client) CheckAndSetIngestionStatus(ctx context.Context) error {
 	log.Info().Msg("Checking ingestion status...")
 
-	// Create ingestion status model
-	ism := dal.NewIngestionStatusModel(c.dal)
-
 	// Check if ingestion status document exists
-	status, err := ism.GetIngestionStatus(ctx)
+	status, err := c.ingestionStatus.GetIngestionStatus(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to check ingestion status: %w", err)
 	}
@@ -29,13 +34,33 @@ func (c *Client) CheckAndSetIngestionStatus(ctx context.Context) error {
 		return fmt.Errorf("ingestion already completed at %s", status.CompletedAt.Format(time.RFC3339)) // Success - ingestion is already done!
 	}
 
+	// Existing data is only re-synced as a consistency check once an ingestion has completed before;
+	// marking a run started keeps completedAt, so a reset or failed re-run still syncs
+	c.skipSyncIfFreshIngestion = status.CompletedAt.IsZero()
+
 	// No status document exists or ingestion was incomplete, start fresh
 	log.Info().Msg("No ingestion status found or previous ingestion was incomplete, starting fresh ingestion")
-	return ism.SetIngestionStatus(ctx, false, "FHIR ingestion started")
+	return c.ingestionStatus.SetIngestionStatus(ctx, false, "FHIR ingestion started")
 }
 
 // SetIngestionComplete marks the ingestion as complete
 func (c *Client) SetIngestionComplete(ctx context.Context) error {
-	ism := dal.NewIngestionStatusModel(c.dal)
-	return ism.SetIngestionStatus(ctx, true, "FHIR ingestion completed successfully")
+	return c.ingestionStatus.SetIngestionStatus(ctx, true, "FHIR ingestion completed successfully")
+}
+
+// SetIngestedResourceCount records the ingested count of a resource type in the ingestion status.
+// An incremental fetch only ingests the updates since the checkpoint, so it keeps the count of the last full ingestion
+// that api-rest checks against FHIR_MIN_*; the run manifest still records the updates.
+func (c *Client) SetIngestedResourceCount(ctx context.Context, resourceType string, count int) error {
+	c.recordIngestedCount(resourceType, count)
+	if c.isIncremental(resourceType) {
+		log.Debug().Str("resource_type", resourceType).Int("count", count).Msg("Incremental fetch, keeping the ingested resource count")
+		return nil
+	}
+	return c.ingestionStatus.SetResourceCount(ctx, resourceType, count)
+}
+
+// SetIngestionFilters records the active ingestion filters in the ingestion status
+func (c *Client) SetIngestionFilters(ctx context.Context, filters map[string]string) error {
+	return c.ingestionStatus.SetFilters(ctx, filters)
 }
@@ -0,0 +1,116 @@
+package fhir
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"stealthcompany.com/fhir-client/internal/dal"
+)
+
+// memoryIngestionStatusStore keeps the ingestion status in memory, updating the same fields as dal.IngestionStatusModel
+type memoryIngestionStatusStore struct {
+	status dal.IngestionStatus
+}
+
+func (m *memoryIngestionStatusStore) GetIngestionStatus(ctx context.Context) (*dal.IngestionStatus, error) {
+	status := m.status
+	return &status, nil
+}
+
+func (m *memoryIngestionStatusStore) SetIngestionStatus(ctx context.Context, ready bool, message string) error {
+	m.status.Ready = ready
+	m.status.Message = message
+	if ready {
+		m.status.CompletedAt = time.Now().UTC()
+	} else {
+		m.status.StartedAt = time.Now().UTC()
+		m.status.Filters = map[string]string{}
+	}
+	return nil
+}
+
+func (m *memoryIngestionStatusStore) SetResourceCount(ctx context.Context, resourceType string, count int) error {
+	if m.status.ResourceCounts == nil {
+		m.status.ResourceCounts = make(map[string]int)
+	}
+	m.status.ResourceCounts[resourceType] = count
+	return nil
+}
+
+func (m *memoryIngestionStatusStore) SetFilters(ctx context.Context, filters map[string]string) error {
+	m.status.Filters = filters
+	return nil
+}
+
+func TestCheckAndSetIngestionStatusSyncsAfterCompletedRun(t *testing.T) {
+	store := &memoryIngestionStatusStore{}
+	client := &Client{ingestionStatus: store}
+	ctx := context.Background()
+
+	// First run: nothing was ever ingested, so the existing data sync is skipped
+	if err := client.CheckAndSetIngestionStatus(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !client.skipSyncIfFreshIngestion {
+		t.Error("Expected the first run to skip the existing data sync")
+	}
+	if err := client.SetIngestionComplete(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A completed ingestion is not run again
+	if err := client.CheckAndSetIngestionStatus(ctx); err == nil || !strings.HasPrefix(err.Error(), "ingestion already completed at") {
+		t.Fatalf("Expected the already completed error, got %v", err)
+	}
+
+	// A run that started after a completed one, then failed, leaves the status not ready
+	if err := client.ingestionStatus.SetIngestionStatus(ctx, false, "FHIR ingestion started"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client.skipSyncIfFreshIngestion = true
+
+	if err := client.CheckAndSetIngestionStatus(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if client.skipSyncIfFreshIngestion {
+		t.Error("Expected a run after a completed ingestion to sync existing data")
+	}
+	if store.status.Ready {
+		t.Error("Expected the status to be marked not ready while the run is in progress")
+	}
+}
+
+func TestIncrementalRunKeepsResourceCounts(t *testing.T) {
+	store := &memoryIngestionStatusStore{status: dal.IngestionStatus{
+		CompletedAt:    time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		ResourceCounts: map[string]int{"Encounter": 120, "Patient": 80, "Practitioner": 15},
+	}}
+	checkpoints := &memoryCheckpointStore{checkpoints: map[string]time.Time{
+		"Encounter": time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}}
+	client := &Client{ingestionStatus: store, checkpoints: checkpoints, run: newIngestRun()}
+	ctx := context.Background()
+
+	// Encounter has a checkpoint and is fetched incrementally, Patient runs the full search
+	client.incrementalSearchURL(ctx, "Encounter", "http://fhir/Encounter?_count=500")
+	client.incrementalSearchURL(ctx, "Patient", "http://fhir/Patient?_count=500")
+
+	if err := client.SetIngestedResourceCount(ctx, "Encounter", 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := client.SetIngestedResourceCount(ctx, "Patient", 95); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := store.status.ResourceCounts["Encounter"]; got != 120 {
+		t.Errorf("Expected the incremental run to keep the Encounter count 120, got %d", got)
+	}
+	if got := store.status.ResourceCounts["Patient"]; got != 95 {
+		t.Errorf("Expected the full search to set the Patient count 95, got %d", got)
+	}
+	if got := client.run.counts["Encounter"]; got != 0 {
+		t.Errorf("Expected the run to record the 0 updated encounters, got %d", got)
+	}
+}
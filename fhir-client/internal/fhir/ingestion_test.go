@@ -0,0 +1,164 @@
+package fhir
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"stealthcompany.com/pkg/fhirvalidator"
+)
+
+func TestIngestResourcesParallelCancellation(t *testing.T) {
+	var mu sync.Mutex
+	searched := map[string]bool{}
+	allStarted := make(chan struct{})
+
+	// Every search blocks until its request is cancelled
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		searched[r.URL.Path] = true
+		if len(searched) == 6 {
+			close(allStarted)
+		}
+		mu.Unlock()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL, parallelIngestion: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- client.ingestResources(ctx) }()
+
+	select {
+	case <-allStarted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the six searches to run concurrently")
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected a cancellation error, got %v", err)
+		}
+		// One failure per resource type is combined into the returned error
+		var joined interface{ Unwrap() []error }
+		if !errors.As(err, &joined) || len(joined.Unwrap()) != 6 {
+			t.Errorf("Expected the six ingestion errors, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected cancellation to abort all ingestion goroutines")
+	}
+}
+
+func TestIngestStepsEncounterPractitioners(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   int
+	}{
+		{name: "search", source: PractitionersSourceSearch, want: 6},
+		{name: "encounters", source: PractitionersSourceEncounters, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{practitionersSource: tt.source}
+			if got := len(client.ingestSteps()); got != tt.want {
+				t.Errorf("Expected %d steps, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIngestPatientsBulkUpsert(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/fhir+json")
+		w.Write([]byte(`{"resourceType":"Bundle","entry":[` +
+			`{"resource":{"resourceType":"Patient","id":"p1"}},` +
+			`{"resource":{"resourceType":"Patient","id":"p2"}},` +
+			`{"resource":{"resourceType":"Patient","id":"p3"}}]}`))
+	}))
+	defer server.Close()
+
+	patients := &memoryPatientStore{
+		patients: map[string]map[string]interface{}{},
+		failures: map[string]error{"p2": errors.New("temporary failure")},
+	}
+	status := &memoryIngestionStatusStore{}
+	client := &Client{
+		httpClient:      server.Client(),
+		fhirBaseURL:     server.URL,
+		patientModel:    patients,
+		checkpoints:     &memoryCheckpointStore{checkpoints: map[string]time.Time{}},
+		ingestionStatus: status,
+		run:             newIngestRun(),
+	}
+
+	if err := client.ingestPatients(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if patients.bulkCalls != 1 {
+		t.Errorf("Expected the page to be written with one bulk upsert, got %d", patients.bulkCalls)
+	}
+	if len(patients.patients) != 2 {
+		t.Errorf("Expected p1 and p3 to be stored, got %v", patients.patients)
+	}
+	if got := status.status.ResourceCounts["Patient"]; got != 2 {
+		t.Errorf("Expected 2 ingested patients, got %d", got)
+	}
+	if failed := client.run.failedDocIDs; len(failed) != 1 || failed[0] != "Patient/p2" {
+		t.Errorf("Expected Patient/p2 to be recorded as failed, got %v", failed)
+	}
+}
+
+func TestIngestResourceTypeStopsOnInvalidResource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/fhir+json")
+		w.Write([]byte(`{"resourceType":"Bundle","entry":[{"resource":{"resourceType":"MedicationRequest","id":"m1"}}]}`))
+	}))
+	defer server.Close()
+
+	status := &memoryIngestionStatusStore{}
+	client := &Client{
+		httpClient:      server.Client(),
+		fhirBaseURL:     server.URL,
+		checkpoints:     &memoryCheckpointStore{checkpoints: map[string]time.Time{}},
+		ingestionStatus: status,
+		run:             newIngestRun(),
+	}
+	bulkUpsert := func(ctx context.Context, resources map[string]map[string]interface{}) map[string]error {
+		return map[string]error{"m1": fhirvalidator.ErrInvalidResource}
+	}
+
+	err := client.ingestResourceType(context.Background(), "MedicationRequest", client.searchURL("MedicationRequest"), bulkUpsert, nil)
+	if !errors.Is(err, fhirvalidator.ErrInvalidResource) {
+		t.Fatalf("Expected ErrInvalidResource, got %v", err)
+	}
+	if want := "failed to validate medication request m1"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected error to contain %q, got %v", want, err)
+	}
+	if status.status.ResourceCounts != nil {
+		t.Errorf("Expected no ingested count to be recorded, got %v", status.status.ResourceCounts)
+	}
+}
+
+func TestResourceLabel(t *testing.T) {
+	for resourceType, want := range map[string]string{
+		"Encounter":         "encounter",
+		"MedicationRequest": "medication request",
+	} {
+		if got := resourceLabel(resourceType); got != want {
+			t.Errorf("resourceLabel(%q) = %q, want %q", resourceType, got, want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package fhir
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"stealthcompany.com/fhir-client/internal/dal"
+)
+
+// manifestWriter stores the audit manifest of an ingestion run
+type manifestWriter interface {
+	WriteManifest(ctx context.Context, manifest *dal.IngestManifest) error
+}
+
+// ingestRun collects what happened during one ingestion run
+type ingestRun struct {
+	mu                sync.Mutex
+	id                string
+	startedAt         time.Time
+	counts            map[string]int
+	failedDocIDs      []string
+	missingReferences map[string]struct{}
+	searched          map[string]struct{}
+	incremental       map[string]struct{}
+}
+
+// newIngestRun starts a new ingestion run with a random UUID
+func newIngestRun() *ingestRun {
+	return &ingestRun{
+		id:                uuid.NewString(),
+		startedAt:         time.Now().UTC(),
+		counts:            make(map[string]int),
+		missingReferences: make(map[string]struct{}),
+		searched:          make(map[string]struct{}),
+		incremental:       make(map[string]struct{}),
+	}
+}
+
+// recordCount sets the ingested count of a resource type
+func (r *ingestRun) recordCount(resourceType string, count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[resourceType] = count
+}
+
+// recordFailure adds a document that could not be ingested
+func (r *ingestRun) recordFailure(docID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failedDocIDs = append(r.failedDocIDs, docID)
+}
+
+// recordMissingReferences adds an encounter whose patient could not be synced
+func (r *ingestRun) recordMissingReferences(encounterID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.missingReferences[encounterID] = struct{}{}
+}
+
+// manifest builds the audit manifest of the run, failed when ingestErr is not nil
+func (r *ingestRun) manifest(fhirServerURL string, filters map[string]string, ingestErr error, completedAt time.Time) *dal.IngestManifest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int, len(r.counts))
+	for resourceType, count := range r.counts {
+		counts[resourceType] = count
+	}
+
+	manifest := &dal.IngestManifest{
+		RunID:                           r.id,
+		StartedAt:                       r.startedAt,
+		CompletedAt:                     completedAt,
+		ResourceCounts:                  counts,
+		FHIRServerURL:                   fhirServerURL,
+		Filters:                         filters,
+		Status:                          dal.ManifestStatusSuccess,
+		FailedDocumentIDs:               append([]string{}, r.failedDocIDs...),
+		EncountersWithMissingReferences: len(r.missingReferences),
+	}
+	if ingestErr != nil {
+		manifest.Status = dal.ManifestStatusFailure
+		manifest.Error = ingestErr.Error()
+	}
+	return manifest
+}
+
+// recordIngestedCount records the ingested count of a resource type in the current run, if any
+func (c *Client) recordIngestedCount(resourceType string, count int) {
+	if c.run != nil {
+		c.run.recordCount(resourceType, count)
+	}
+}
+
+// recordIngestFailure records a document that failed to ingest in the current run, if any
+func (c *Client) recordIngestFailure(docID string) {
+	if c.run != nil {
+		c.run.recordFailure(docID)
+	}
+}
+
+// writeManifest writes the manifest of the current run; failures are logged and do not fail ingestion
+func (c *Client) writeManifest(ctx context.Context, ingestErr error) {
+	if c.run == nil || c.manifestWriter == nil {
+		return
+	}
+
+	manifest := c.run.manifest(c.fhirBaseURL, c.encounterFilter.Map(), ingestErr, time.Now().UTC())
+	manifest.Tenant = c.tenantID
+	if err := c.manifestWriter.WriteManifest(ctx, manifest); err != nil {
+		log.Error().
+			Err(err).
+			Str("run_id", manifest.RunID).
+			Msg("Failed to write ingest manifest")
+	}
+}
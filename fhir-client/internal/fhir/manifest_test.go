@@ -0,0 +1,95 @@
+package fhir
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"stealthcompany.com/fhir-client/internal/dal"
+)
+
+// mockManifestWriter keeps the manifests it is asked to write
+type mockManifestWriter struct {
+	manifests []*dal.IngestManifest
+	err       error
+}
+
+func (m *mockManifestWriter) WriteManifest(ctx context.Context, manifest *dal.IngestManifest) error {
+	m.manifests = append(m.manifests, manifest)
+	return m.err
+}
+
+func TestWriteManifest(t *testing.T) {
+	tests := []struct {
+		name       string
+		ingestErr  error
+		wantStatus string
+		wantError  string
+	}{
+		{name: "successful ingestion", wantStatus: dal.ManifestStatusSuccess},
+		{name: "failed ingestion", ingestErr: errors.New("failed to ingest patients"), wantStatus: dal.ManifestStatusFailure, wantError: "failed to ingest patients"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &mockManifestWriter{}
+			client := &Client{
+				fhirBaseURL:     "https://hapi.fhir.org/baseR4",
+				encounterFilter: EncounterFilter{Status: "finished"},
+				manifestWriter:  writer,
+				run:             newIngestRun(),
+			}
+
+			client.recordIngestedCount("Encounter", 10)
+			client.recordIngestedCount("Practitioner", 4)
+			client.recordIngestedCount("Patient", 7)
+			client.recordIngestFailure("Encounter/bad-1")
+			client.writeManifest(context.Background(), tt.ingestErr)
+
+			if len(writer.manifests) != 1 {
+				t.Fatalf("Expected 1 manifest written, got %d", len(writer.manifests))
+			}
+			manifest := writer.manifests[0]
+
+			wantCounts := map[string]int{"Encounter": 10, "Practitioner": 4, "Patient": 7}
+			if !reflect.DeepEqual(manifest.ResourceCounts, wantCounts) {
+				t.Errorf("ResourceCounts = %v, want %v", manifest.ResourceCounts, wantCounts)
+			}
+			if manifest.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", manifest.Status, tt.wantStatus)
+			}
+			if manifest.Error != tt.wantError {
+				t.Errorf("Error = %q, want %q", manifest.Error, tt.wantError)
+			}
+			if manifest.RunID == "" || manifest.RunID != client.run.id {
+				t.Errorf("RunID = %q, want run ID %q", manifest.RunID, client.run.id)
+			}
+			if manifest.FHIRServerURL != "https://hapi.fhir.org/baseR4" {
+				t.Errorf("FHIRServerURL = %q", manifest.FHIRServerURL)
+			}
+			if !reflect.DeepEqual(manifest.Filters, map[string]string{"status": "finished"}) {
+				t.Errorf("Filters = %v", manifest.Filters)
+			}
+			if !reflect.DeepEqual(manifest.FailedDocumentIDs, []string{"Encounter/bad-1"}) {
+				t.Errorf("FailedDocumentIDs = %v", manifest.FailedDocumentIDs)
+			}
+			if manifest.CompletedAt.Before(manifest.StartedAt) {
+				t.Errorf("CompletedAt %v before StartedAt %v", manifest.CompletedAt, manifest.StartedAt)
+			}
+		})
+	}
+}
+
+func TestWriteManifestWithoutRun(t *testing.T) {
+	writer := &mockManifestWriter{}
+	client := &Client{manifestWriter: writer}
+
+	// Counts recorded outside a run are ignored and no manifest is written
+	client.recordIngestedCount("Encounter", 1)
+	client.writeManifest(context.Background(), nil)
+
+	if len(writer.manifests) != 0 {
+		t.Errorf("Expected no manifest without a run, got %d", len(writer.manifests))
+	}
+}
@@ -0,0 +1,8 @@
+package fhir
+
+import "context"
+
+// medicationRequestStore is the part of dal.MedicationRequestModel used to ingest medication requests
+type medicationRequestStore interface {
+	BulkUpsertMedicationRequests(ctx context.Context, medicationRequests map[string]map[string]interface{}) map[string]error
+}
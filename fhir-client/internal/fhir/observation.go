@@ -0,0 +1,22 @@
+package fhir
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// observationStore is the part of dal.ObservationModel used to ingest observations
+type observationStore interface {
+	BulkUpsertObservations(ctx context.Context, observations map[string]map[string]interface{}) map[string]error
+}
+
+// observationSearchURL builds the observation search URL, limited to the FHIR_OBSERVATION_CODES codes when set
+func (c *Client) observationSearchURL() string {
+	searchURL := c.searchURL("Observation")
+	if len(c.observationCodes) > 0 {
+		params := url.Values{"code": {strings.Join(c.observationCodes, ",")}}
+		searchURL += "&" + params.Encode()
+	}
+	return searchURL
+}
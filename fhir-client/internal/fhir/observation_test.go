@@ -0,0 +1,55 @@
+package fhir
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestObservationSearchURLCodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		codes []string
+		want  url.Values
+	}{
+		{
+			name:  "no codes",
+			codes: nil,
+			want:  url.Values{"_count": {"500"}},
+		},
+		{
+			name:  "code filter",
+			codes: []string{"85354-9", "29463-7"},
+			want:  url.Values{"_count": {"500"}, "code": {"85354-9,29463-7"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			var got url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				got = r.URL.Query()
+				w.Header().Set("Content-Type", "application/fhir+json")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"resourceType":"Bundle","entry":[]}`))
+			}))
+			defer server.Close()
+
+			client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL, observationCodes: tt.codes}
+			if _, err := client.fetchSearchPage(context.Background(), "Observation", client.observationSearchURL()); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if gotPath != "/Observation" {
+				t.Errorf("Request path = %q, want /Observation", gotPath)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package fhir
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Bounds and default of the _count requested in FHIR searches
+const (
+	defaultFHIRPageSize = 500
+	minFHIRPageSize     = 1
+	maxFHIRPageSize     = 10000
+	defaultFHIRMaxPages = 100
+)
+
+// PageSizes holds the _count requested for each resource type search
+type PageSizes struct {
+	Encounter         int
+	Patient           int
+	Practitioner      int
+	Observation       int
+	Condition         int
+	MedicationRequest int
+}
+
+// pageSizesFromEnv reads and validates FHIR_ENCOUNTER_PAGE_SIZE, FHIR_PATIENT_PAGE_SIZE,
+// FHIR_PRACTITIONER_PAGE_SIZE, FHIR_OBSERVATION_PAGE_SIZE, FHIR_CONDITION_PAGE_SIZE and
+// FHIR_MEDICATION_REQUEST_PAGE_SIZE (default 500 each)
+func pageSizesFromEnv() (PageSizes, error) {
+	var sizes PageSizes
+	for _, setting := range []struct {
+		key  string
+		dest *int
+	}{
+		{"FHIR_ENCOUNTER_PAGE_SIZE", &sizes.Encounter},
+		{"FHIR_PATIENT_PAGE_SIZE", &sizes.Patient},
+		{"FHIR_PRACTITIONER_PAGE_SIZE", &sizes.Practitioner},
+		{"FHIR_OBSERVATION_PAGE_SIZE", &sizes.Observation},
+		{"FHIR_CONDITION_PAGE_SIZE", &sizes.Condition},
+		{"FHIR_MEDICATION_REQUEST_PAGE_SIZE", &sizes.MedicationRequest},
+	} {
+		value := getEnvOrDefault(setting.key, strconv.Itoa(defaultFHIRPageSize))
+		size, err := strconv.Atoi(value)
+		if err != nil || size < minFHIRPageSize || size > maxFHIRPageSize {
+			return PageSizes{}, fmt.Errorf("invalid %s %q: must be between %d and %d",
+				setting.key, value, minFHIRPageSize, maxFHIRPageSize)
+		}
+		*setting.dest = size
+	}
+	return sizes, nil
+}
+
+// forResource returns the page size of a resource type, falling back to the default when unset
+func (p PageSizes) forResource(resourceType string) int {
+	var size int
+	switch resourceType {
+	case "Encounter":
+		size = p.Encounter
+	case "Patient":
+		size = p.Patient
+	case "Practitioner":
+		size = p.Practitioner
+	case "Observation":
+		size = p.Observation
+	case "Condition":
+		size = p.Condition
+	case "MedicationRequest":
+		size = p.MedicationRequest
+	}
+	if size == 0 {
+		return defaultFHIRPageSize
+	}
+	return size
+}
+
+// maxPagesFromEnv reads FHIR_MAX_PAGES, the most search pages followed per resource type (default 100)
+func maxPagesFromEnv() (int, error) {
+	value := getEnvOrDefault("FHIR_MAX_PAGES", strconv.Itoa(defaultFHIRMaxPages))
+	maxPages, err := strconv.Atoi(value)
+	if err != nil || maxPages < 1 {
+		return 0, fmt.Errorf("invalid FHIR_MAX_PAGES %q: must be at least 1", value)
+	}
+	return maxPages, nil
+}
+
+// searchURL builds the search URL of a resource type with its configured page size
+func (c *Client) searchURL(resourceType string) string {
+	return fmt.Sprintf("%s/%s?_count=%d", c.fhirBaseURL, resourceType, c.pageSizes.forResource(resourceType))
+}
+
+// fetchSearchPage fetches a search bundle, limited to resources updated since the checkpoint of the resource type.
+// A search stopped by FHIR_MAX_PAGES keeps the checkpoint, so the next run fetches the pages left behind.
+// It warns when the server returned fewer entries than requested, which may mean it caps the page size below the configured one
+func (c *Client) fetchSearchPage(ctx context.Context, resourceType, url string) ([]FHIRResource, error) {
+	resources, truncated, err := c.fetchFHIRBundle(ctx, resourceType, c.incrementalSearchURL(ctx, resourceType, url))
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		log.Warn().Str("resource_type", resourceType).Msg("FHIR search truncated, keeping the ingestion checkpoint")
+	} else {
+		c.recordSearched(resourceType)
+	}
+
+	if requested := c.pageSizes.forResource(resourceType); len(resources) < requested {
+		log.Warn().
+			Str("resource_type", resourceType).
+			Int("requested", requested).
+			Int("returned", len(resources)).
+			Msg("FHIR bundle has fewer entries than requested, the server may cap the page size")
+	}
+	return resources, nil
+}
@@ -0,0 +1,120 @@
+package fhir
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPageSizesFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    PageSizes
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{},
+			want: PageSizes{Encounter: 500, Patient: 500, Practitioner: 500, Observation: 500, Condition: 500, MedicationRequest: 500},
+		},
+		{
+			name: "custom sizes",
+			env:  map[string]string{"FHIR_ENCOUNTER_PAGE_SIZE": "100", "FHIR_PATIENT_PAGE_SIZE": "1", "FHIR_PRACTITIONER_PAGE_SIZE": "10000", "FHIR_OBSERVATION_PAGE_SIZE": "200", "FHIR_CONDITION_PAGE_SIZE": "50", "FHIR_MEDICATION_REQUEST_PAGE_SIZE": "75"},
+			want: PageSizes{Encounter: 100, Patient: 1, Practitioner: 10000, Observation: 200, Condition: 50, MedicationRequest: 75},
+		},
+		{name: "zero", env: map[string]string{"FHIR_ENCOUNTER_PAGE_SIZE": "0"}, wantErr: true},
+		{name: "above maximum", env: map[string]string{"FHIR_PATIENT_PAGE_SIZE": "10001"}, wantErr: true},
+		{name: "not a number", env: map[string]string{"FHIR_PRACTITIONER_PAGE_SIZE": "all"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"FHIR_ENCOUNTER_PAGE_SIZE", "FHIR_PATIENT_PAGE_SIZE", "FHIR_PRACTITIONER_PAGE_SIZE", "FHIR_OBSERVATION_PAGE_SIZE", "FHIR_CONDITION_PAGE_SIZE", "FHIR_MEDICATION_REQUEST_PAGE_SIZE"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			got, err := pageSizesFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pageSizesFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("pageSizesFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchURLPageSize(t *testing.T) {
+	tests := []struct {
+		resourceType string
+		pageSizes    PageSizes
+		wantCount    string
+	}{
+		{resourceType: "Encounter", pageSizes: PageSizes{Encounter: 100}, wantCount: "100"},
+		{resourceType: "Patient", pageSizes: PageSizes{Patient: 250}, wantCount: "250"},
+		{resourceType: "Practitioner", pageSizes: PageSizes{Practitioner: 50}, wantCount: "50"},
+		{resourceType: "Observation", pageSizes: PageSizes{Observation: 20}, wantCount: "20"},
+		{resourceType: "Condition", pageSizes: PageSizes{Condition: 30}, wantCount: "30"},
+		{resourceType: "MedicationRequest", pageSizes: PageSizes{MedicationRequest: 40}, wantCount: "40"},
+		{resourceType: "Patient", pageSizes: PageSizes{}, wantCount: "500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resourceType+"_"+tt.wantCount, func(t *testing.T) {
+			var gotCount, gotPath string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				gotCount = r.URL.Query().Get("_count")
+				w.Header().Set("Content-Type", "application/fhir+json")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"resourceType":"Bundle","entry":[]}`))
+			}))
+			defer server.Close()
+
+			client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL, pageSizes: tt.pageSizes}
+			if _, err := client.fetchSearchPage(context.Background(), tt.resourceType, client.searchURL(tt.resourceType)); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if gotPath != "/"+tt.resourceType {
+				t.Errorf("Request path = %q, want /%s", gotPath, tt.resourceType)
+			}
+			if gotCount != tt.wantCount {
+				t.Errorf("Request _count = %q, want %q", gotCount, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestMaxPagesFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: defaultFHIRMaxPages},
+		{value: "5", want: 5},
+		{value: "0", wantErr: true},
+		{value: "many", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("FHIR_MAX_PAGES", tt.value)
+			got, err := maxPagesFromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error for %q", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
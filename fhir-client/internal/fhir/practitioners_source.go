@@ -0,0 +1,140 @@
+package fhir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+	"stealthcompany.com/fhir-client/internal/metrics"
+	"stealthcompany.com/pkg/fhirvalidator"
+)
+
+// Values of FHIR_PRACTITIONERS_SOURCE
+const (
+	// PractitionersSourceSearch ingests every practitioner returned by the Practitioner search
+	PractitionersSourceSearch = "search"
+	// PractitionersSourceEncounters ingests only the practitioners referenced by ingested encounters
+	PractitionersSourceEncounters = "encounters"
+)
+
+// practitionerStore is the part of dal.PractitionerModel used to ingest and sync practitioners
+type practitionerStore interface {
+	PractitionerExists(ctx context.Context, practitionerID string) (bool, error)
+	UpsertPractitioner(ctx context.Context, practitionerID string, data map[string]interface{}) error
+	BulkUpsertPractitioners(ctx context.Context, practitioners map[string]map[string]interface{}) map[string]error
+}
+
+// practitionersSourceFromEnv reads and validates FHIR_PRACTITIONERS_SOURCE (default "search")
+func practitionersSourceFromEnv() (string, error) {
+	value := getEnvOrDefault("FHIR_PRACTITIONERS_SOURCE", PractitionersSourceSearch)
+	switch value {
+	case PractitionersSourceSearch, PractitionersSourceEncounters:
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid FHIR_PRACTITIONERS_SOURCE %q: must be %q or %q",
+		value, PractitionersSourceSearch, PractitionersSourceEncounters)
+}
+
+// practitionerRefSet collects the practitioner references of ingested encounters,
+// keeping each practitioner once no matter how many encounters reference it
+type practitionerRefSet struct {
+	refs  sync.Map
+	total atomic.Int64
+}
+
+// add records a reference and reports whether it was seen for the first time
+func (s *practitionerRefSet) add(ref string) bool {
+	s.total.Add(1)
+	_, loaded := s.refs.LoadOrStore(ref, struct{}{})
+	return !loaded
+}
+
+// ids returns the distinct practitioner references collected so far
+func (s *practitionerRefSet) ids() []string {
+	var ids []string
+	s.refs.Range(func(key, _ any) bool {
+		ids = append(ids, key.(string))
+		return true
+	})
+	return ids
+}
+
+// dedupRatio returns the distinct references over the total references, or 1 when there are none
+func (s *practitionerRefSet) dedupRatio() float64 {
+	total := s.total.Load()
+	if total == 0 {
+		return 1
+	}
+	return float64(len(s.ids())) / float64(total)
+}
+
+// collectPractitionerRefs records the practitioners referenced by an encounter for later ingestion
+func (c *Client) collectPractitionerRefs(practitionerRefs []string) {
+	for _, practitionerRef := range practitionerRefs {
+		c.encounterPractitioners.add(practitionerRef)
+	}
+}
+
+// ingestEncounterPractitioners fetches and ingests the practitioners referenced by ingested encounters
+func (c *Client) ingestEncounterPractitioners(ctx context.Context) error {
+	ids := c.encounterPractitioners.ids()
+	ratio := c.encounterPractitioners.dedupRatio()
+	metrics.SetPractitionerDedupRatio(ratio)
+
+	log.Info().
+		Int("total_practitioners", len(ids)).
+		Int64("total_references", c.encounterPractitioners.total.Load()).
+		Float64("dedup_ratio", ratio).
+		Msg("Fetching practitioners referenced by encounters")
+
+	ingested, skipped, err := c.fetchEncounterPractitioners(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	log.Info().
+		Int("ingested", ingested).
+		Int("skipped", skipped).
+		Msg("Completed ingesting practitioners")
+
+	metrics.RecordFHIRIngestion(c.tenantID, "practitioners", ingested, skipped)
+
+	// Practitioners of incrementally fetched encounters are only the updated ones too
+	if c.isIncremental("Encounter") {
+		c.recordIncremental("Practitioner")
+	}
+	err = c.SetIngestedResourceCount(ctx, "Practitioner", ingested)
+	if err != nil {
+		return fmt.Errorf("failed to record practitioner count: %w", err)
+	}
+	return nil
+}
+
+// fetchEncounterPractitioners fetches each practitioner individually and upserts it
+func (c *Client) fetchEncounterPractitioners(ctx context.Context, ids []string) (ingested, skipped int, err error) {
+	for _, practitionerID := range ids {
+		if err := ctx.Err(); err != nil {
+			return ingested, skipped, fmt.Errorf("practitioner ingestion cancelled: %w", err)
+		}
+
+		data, err := c.fetchPractitionerFromAPI(ctx, practitionerID)
+		if err == nil {
+			err = c.ingestPractitioner(ctx, FHIRResource{ID: practitionerID, ResourceType: "Practitioner", Data: data})
+		}
+		if errors.Is(err, fhirvalidator.ErrInvalidResource) {
+			// Strict validation: stop ingestion instead of skipping the resource
+			return ingested, skipped, fmt.Errorf("failed to validate practitioner %s: %w", practitionerID, err)
+		}
+		if err != nil {
+			log.Debug().Err(err).Str("practitioner_id", practitionerID).Msg("Failed to ingest practitioner")
+			c.recordIngestFailure("Practitioner/" + practitionerID)
+			skipped++
+			continue
+		}
+		ingested++
+	}
+	return ingested, skipped, nil
+}
@@ -0,0 +1,135 @@
+package fhir
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryPractitionerStore keeps upserted practitioners in memory
+type memoryPractitionerStore struct {
+	practitioners map[string]map[string]interface{}
+}
+
+func (m *memoryPractitionerStore) PractitionerExists(ctx context.Context, practitionerID string) (bool, error) {
+	_, ok := m.practitioners[practitionerID]
+	return ok, nil
+}
+
+func (m *memoryPractitionerStore) UpsertPractitioner(ctx context.Context, practitionerID string, data map[string]interface{}) error {
+	m.practitioners[practitionerID] = data
+	return nil
+}
+
+func (m *memoryPractitionerStore) BulkUpsertPractitioners(ctx context.Context, practitioners map[string]map[string]interface{}) map[string]error {
+	for practitionerID, data := range practitioners {
+		m.practitioners[practitionerID] = data
+	}
+	return nil
+}
+
+func TestPractitionersSourceFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", value: "", want: PractitionersSourceSearch},
+		{name: "search", value: "search", want: PractitionersSourceSearch},
+		{name: "encounters", value: "encounters", want: PractitionersSourceEncounters},
+		{name: "invalid", value: "patients", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FHIR_PRACTITIONERS_SOURCE", tt.value)
+			got, err := practitionersSourceFromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error for %q", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFetchEncounterPractitionersDeduplicates(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+
+		id := strings.TrimPrefix(r.URL.Path, "/Practitioner/")
+		if id == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/fhir+json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"resourceType":"Practitioner","id":"` + id + `"}`))
+	}))
+	defer server.Close()
+
+	store := &memoryPractitionerStore{practitioners: map[string]map[string]interface{}{}}
+	client := &Client{
+		httpClient:             server.Client(),
+		fhirBaseURL:            server.URL,
+		practitionerModel:      store,
+		practitionersSource:    PractitionersSourceEncounters,
+		encounterPractitioners: &practitionerRefSet{},
+	}
+
+	// Three encounters share practitioners; only three distinct practitioners exist
+	client.collectPractitionerRefs([]string{"pr1", "pr2"})
+	client.collectPractitionerRefs([]string{"pr1", "missing"})
+	client.collectPractitionerRefs([]string{"pr2", "pr1"})
+
+	ingested, skipped, err := client.fetchEncounterPractitioners(context.Background(), client.encounterPractitioners.ids())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ingested != 2 || skipped != 1 {
+		t.Errorf("Expected 2 ingested and 1 skipped, got %d and %d", ingested, skipped)
+	}
+
+	for path, count := range requests {
+		if count != 1 {
+			t.Errorf("Expected one request for %s, got %d", path, count)
+		}
+	}
+	if len(requests) != 3 {
+		t.Errorf("Expected requests for 3 practitioners, got %v", requests)
+	}
+	for _, id := range []string{"pr1", "pr2"} {
+		if _, ok := store.practitioners[id]; !ok {
+			t.Errorf("Expected practitioner %s to be stored", id)
+		}
+	}
+
+	if got, want := client.encounterPractitioners.dedupRatio(), 3.0/6.0; got != want {
+		t.Errorf("Expected dedup ratio %v, got %v", want, got)
+	}
+}
+
+func TestPractitionerRefSetEmpty(t *testing.T) {
+	var set practitionerRefSet
+	if got := set.dedupRatio(); got != 1 {
+		t.Errorf("Expected dedup ratio 1 with no references, got %v", got)
+	}
+	if ids := set.ids(); len(ids) != 0 {
+		t.Errorf("Expected no ids, got %v", ids)
+	}
+}
@@ -0,0 +1,159 @@
+package fhir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Defaults of the retry policy of FHIR HTTP fetches
+const (
+	defaultRetryMaxAttempts = 3
+	defaultRetryBaseDelay   = 500 * time.Millisecond
+	defaultRetryMaxDelay    = 30 * time.Second
+	defaultRetryMultiplier  = 2.0
+)
+
+// RetryPolicy configures the retries of FHIR HTTP fetches. MaxAttempts counts the first try,
+// so 1 disables retries; the zero value also makes a single attempt.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Multiplier  float64
+}
+
+// retryPolicyFromEnv reads and validates FHIR_RETRY_MAX_ATTEMPTS (default 3), FHIR_RETRY_BASE_DELAY (default 500ms),
+// FHIR_RETRY_MAX_DELAY (default 30s) and FHIR_RETRY_MULTIPLIER (default 2)
+func retryPolicyFromEnv() (RetryPolicy, error) {
+	value := getEnvOrDefault("FHIR_RETRY_MAX_ATTEMPTS", strconv.Itoa(defaultRetryMaxAttempts))
+	maxAttempts, err := strconv.Atoi(value)
+	if err != nil || maxAttempts < 1 {
+		return RetryPolicy{}, fmt.Errorf("invalid FHIR_RETRY_MAX_ATTEMPTS %q: must be at least 1", value)
+	}
+
+	value = getEnvOrDefault("FHIR_RETRY_BASE_DELAY", defaultRetryBaseDelay.String())
+	baseDelay, err := time.ParseDuration(value)
+	if err != nil || baseDelay <= 0 {
+		return RetryPolicy{}, fmt.Errorf("invalid FHIR_RETRY_BASE_DELAY %q: must be a positive duration", value)
+	}
+
+	value = getEnvOrDefault("FHIR_RETRY_MAX_DELAY", defaultRetryMaxDelay.String())
+	maxDelay, err := time.ParseDuration(value)
+	if err != nil || maxDelay < baseDelay {
+		return RetryPolicy{}, fmt.Errorf("invalid FHIR_RETRY_MAX_DELAY %q: must be a duration of at least FHIR_RETRY_BASE_DELAY", value)
+	}
+
+	value = getEnvOrDefault("FHIR_RETRY_MULTIPLIER", strconv.FormatFloat(defaultRetryMultiplier, 'f', -1, 64))
+	multiplier, err := strconv.ParseFloat(value, 64)
+	if err != nil || multiplier < 1 {
+		return RetryPolicy{}, fmt.Errorf("invalid FHIR_RETRY_MULTIPLIER %q: must be at least 1", value)
+	}
+
+	return RetryPolicy{
+		MaxAttempts: maxAttempts,
+		BaseDelay:   baseDelay,
+		MaxDelay:    maxDelay,
+		Multiplier:  multiplier,
+	}, nil
+}
+
+// backoff returns the capped exponential delay before retry number attempt (starting at 1)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := float64(p.BaseDelay) * math.Pow(p.Multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// retryableError marks a fetch failure worth retrying: a network error, or a 429 or 503 response
+// with the delay of its Retry-After header, if any
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// isRetryableStatus checks if a FHIR response status is worth retrying
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date, returning 0 when absent or invalid
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// statusError returns the error of a non-200 FHIR response, marked retryable for 429 and 503
+func statusError(resp *http.Response, err error) error {
+	if !isRetryableStatus(resp.StatusCode) {
+		return err
+	}
+	return &retryableError{err: err, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+}
+
+// retryWithBackoff calls fn until it succeeds, returns an error not marked as retryableError, or the policy
+// runs out of attempts. Retries wait the server Retry-After delay when given, otherwise a full jitter delay
+// (rand.Float64() * backoff). It gives up early when ctx is done or its deadline falls before the next attempt.
+func (c *Client) retryWithBackoff(ctx context.Context, operation string, fn func() error) error {
+	policy := c.retryPolicy
+	for attempt := 1; ; attempt++ {
+		err := fn()
+
+		var retryable *retryableError
+		if err == nil || !errors.As(err, &retryable) || attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		delay := retryable.retryAfter
+		if delay == 0 {
+			delay = time.Duration(rand.Float64() * float64(policy.backoff(attempt)))
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("retry budget exhausted before attempt %d: %w", attempt+1, err)
+		}
+
+		log.Debug().
+			Err(err).
+			Str("operation", operation).
+			Int("attempt", attempt).
+			Int("max_attempts", policy.MaxAttempts).
+			Dur("delay", delay).
+			Msg("FHIR fetch failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("fetch retry cancelled: %w", ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package fhir
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyServer serves failStatus to the first failures requests, then a FHIR body
+func newFlakyServer(t *testing.T, failures int32, failStatus int, retryAfter, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(failStatus)
+			return
+		}
+		w.Header().Set("Content-Type", "application/fhir+json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func testRetryPolicy(maxAttempts int) RetryPolicy {
+	return RetryPolicy{MaxAttempts: maxAttempts, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Multiplier: 2}
+}
+
+func TestFetchFHIRBundleRetries(t *testing.T) {
+	const bundle = `{"resourceType":"Bundle","entry":[{"resource":{"resourceType":"Encounter","id":"1"}}]}`
+
+	tests := []struct {
+		name             string
+		failures         int32
+		failStatus       int
+		retryAfter       string
+		maxAttempts      int
+		expectErr        bool
+		expectedRequests int32
+	}{
+		{name: "503 then success", failures: 2, failStatus: http.StatusServiceUnavailable, maxAttempts: 3, expectedRequests: 3},
+		{name: "429 with Retry-After", failures: 1, failStatus: http.StatusTooManyRequests, retryAfter: "0", maxAttempts: 3, expectedRequests: 2},
+		{name: "attempts exhausted", failures: 5, failStatus: http.StatusServiceUnavailable, maxAttempts: 3, expectErr: true, expectedRequests: 3},
+		{name: "404 not retried", failures: 1, failStatus: http.StatusNotFound, maxAttempts: 3, expectErr: true, expectedRequests: 1},
+		{name: "zero policy makes a single attempt", failures: 1, failStatus: http.StatusServiceUnavailable, maxAttempts: 0, expectErr: true, expectedRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newFlakyServer(t, tt.failures, tt.failStatus, tt.retryAfter, bundle)
+
+			client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL, retryPolicy: testRetryPolicy(tt.maxAttempts)}
+			resources, _, err := client.fetchFHIRBundle(context.Background(), "Encounter", server.URL+"/Encounter")
+
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("fetchFHIRBundle() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !tt.expectErr && len(resources) != 1 {
+				t.Errorf("Expected 1 resource, got %d", len(resources))
+			}
+			if got := requests.Load(); got != tt.expectedRequests {
+				t.Errorf("Expected %d requests, got %d", tt.expectedRequests, got)
+			}
+		})
+	}
+}
+
+func TestFetchResourceFromAPIRetries(t *testing.T) {
+	server, requests := newFlakyServer(t, 2, http.StatusServiceUnavailable, "", `{"resourceType":"Patient","id":"p1"}`)
+
+	client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL, retryPolicy: testRetryPolicy(3)}
+	patient, err := client.fetchPatientFromAPI(context.Background(), "p1")
+	if err != nil {
+		t.Fatalf("fetchPatientFromAPI() error = %v", err)
+	}
+	if patient["id"] != "p1" {
+		t.Errorf("Expected patient p1, got %v", patient)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("Expected 3 requests, got %d", got)
+	}
+
+	server, requests = newFlakyServer(t, 1, http.StatusTooManyRequests, "", `{"resourceType":"Practitioner","id":"pr1"}`)
+	client = &Client{httpClient: server.Client(), fhirBaseURL: server.URL, retryPolicy: testRetryPolicy(3)}
+	if _, err := client.fetchPractitionerFromAPI(context.Background(), "pr1"); err != nil {
+		t.Fatalf("fetchPractitionerFromAPI() error = %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("Expected 2 requests, got %d", got)
+	}
+}
+
+func TestRetryWithBackoffRespectsDeadline(t *testing.T) {
+	// Retry-After is longer than the context deadline, so no retry is attempted
+	server, requests := newFlakyServer(t, 5, http.StatusServiceUnavailable, "60", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL, retryPolicy: testRetryPolicy(5)}
+	start := time.Now()
+	_, err := client.fetchPatientFromAPI(ctx, "p1")
+
+	var retryable *retryableError
+	if !errors.As(err, &retryable) {
+		t.Fatalf("Expected the last fetch error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected to give up before the deadline, took %v", elapsed)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Expected 1 request, got %d", got)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2}
+
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		5: time.Second,
+	} {
+		if got := policy.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "5", want: 5 * time.Second},
+		{value: "-1", want: 0},
+		{value: now.Add(30 * time.Second).Format(http.TimeFormat), want: 30 * time.Second},
+		{value: "soon", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    RetryPolicy
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{},
+			want: RetryPolicy{MaxAttempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second, Multiplier: 2},
+		},
+		{
+			name: "custom policy",
+			env:  map[string]string{"FHIR_RETRY_MAX_ATTEMPTS": "5", "FHIR_RETRY_BASE_DELAY": "1s", "FHIR_RETRY_MAX_DELAY": "10s", "FHIR_RETRY_MULTIPLIER": "1.5"},
+			want: RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second, Multiplier: 1.5},
+		},
+		{name: "zero attempts", env: map[string]string{"FHIR_RETRY_MAX_ATTEMPTS": "0"}, wantErr: true},
+		{name: "max delay below base delay", env: map[string]string{"FHIR_RETRY_BASE_DELAY": "2s", "FHIR_RETRY_MAX_DELAY": "1s"}, wantErr: true},
+		{name: "multiplier below 1", env: map[string]string{"FHIR_RETRY_MULTIPLIER": "0.5"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"FHIR_RETRY_MAX_ATTEMPTS", "FHIR_RETRY_BASE_DELAY", "FHIR_RETRY_MAX_DELAY", "FHIR_RETRY_MULTIPLIER"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			got, err := retryPolicyFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("retryPolicyFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("retryPolicyFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,13 +2,63 @@ package fhir
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/rs/zerolog/log"
+	"stealthcompany.com/fhir-client/internal/metrics"
+	"stealthcompany.com/pkg/fhirutil"
 )
 
+// patientStore is the part of dal.PatientModel used to ingest and sync patients
+type patientStore interface {
+	PatientExists(ctx context.Context, patientID string) (bool, error)
+	UpsertPatient(ctx context.Context, patientID string, data map[string]interface{}) error
+	BulkUpsertPatients(ctx context.Context, patients map[string]map[string]interface{}) map[string]error
+}
+
+// Reasons a reference sync fails, recorded in fhir_reference_sync_error_total
+const (
+	referenceLookupFailed = "lookup_failed"
+	referenceFetchFailed  = "fetch_failed"
+	referenceUpsertFailed = "upsert_failed"
+)
+
+// referenceSyncError is returned by syncPatient and syncPractitioner with the step that failed
+type referenceSyncError struct {
+	reason string
+	err    error
+}
+
+func (e *referenceSyncError) Error() string {
+	return e.err.Error()
+}
+
+func (e *referenceSyncError) Unwrap() error {
+	return e.err
+}
+
+// referenceSyncErrorReason returns the step a reference sync failed at, or "unknown"
+func referenceSyncErrorReason(err error) string {
+	var syncErr *referenceSyncError
+	if errors.As(err, &syncErr) {
+		return syncErr.reason
+	}
+	return "unknown"
+}
+
+// missingReferenceStore is the part of dal.MissingReferencesModel used to record encounters with broken links
+type missingReferenceStore interface {
+	AddEncounter(ctx context.Context, encounterID string) error
+}
+
 // syncExistingData checks existing data and syncs with FHIR API
 func (c *Client) syncExistingData(ctx context.Context) error {
+	if c.skipSyncIfFreshIngestion {
+		log.Info().Msg("No ingestion completed before, skipping sync of existing data")
+		return nil
+	}
+
 	log.Info().Msg("Checking existing data and syncing with FHIR API")
 
 	// Check if encounters collection is empty
@@ -62,14 +112,16 @@ func (c *Client) syncExistingEncounters(ctx context.Context) error {
 // syncEncounter syncs a single encounter with FHIR API
 func (c *Client) syncEncounter(ctx context.Context, id string, resource map[string]interface{}) error {
 	// Extract patient and practitioner references
-	patientRefs := c.extractPatientReferences(resource)
-	practitionerRefs := c.extractPractitionerReferences(resource)
+	patientRef := fhirutil.ExtractPatientRef(resource)
+	practitionerRefs := fhirutil.ExtractPractitionerRefs(resource)
 
-	// Sync patient references
-	for _, patientRef := range patientRefs {
+	// Sync patient reference
+	if patientRef != "" {
 		err := c.syncPatient(ctx, patientRef)
 		if err != nil {
 			log.Debug().Err(err).Str("patient_ref", patientRef).Msg("Failed to sync patient")
+			c.recordReferenceSyncError("Patient", err)
+			c.recordMissingReferences(ctx, id)
 		}
 	}
 
@@ -78,6 +130,7 @@ func (c *Client) syncEncounter(ctx context.Context, id string, resource map[stri
 		err := c.syncPractitioner(ctx, practitionerRef)
 		if err != nil {
 			log.Debug().Err(err).Str("practitioner_ref", practitionerRef).Msg("Failed to sync practitioner")
+			c.recordReferenceSyncError("Practitioner", err)
 		}
 	}
 
@@ -89,7 +142,7 @@ func (c *Client) syncPatient(ctx context.Context, patientRef string) error {
 	// Check if patient already exists in Couchbase
 	exists, err := c.patientModel.PatientExists(ctx, patientRef)
 	if err != nil {
-		return fmt.Errorf("failed to check patient existence: %w", err)
+		return &referenceSyncError{reason: referenceLookupFailed, err: fmt.Errorf("failed to check patient existence: %w", err)}
 	}
 
 	if exists {
@@ -100,25 +153,44 @@ func (c *Client) syncPatient(ctx context.Context, patientRef string) error {
 	// Patient doesn't exist, fetch from FHIR API
 	patientData, err := c.fetchPatientFromAPI(ctx, patientRef)
 	if err != nil {
-		return fmt.Errorf("failed to fetch patient from API: %w", err)
+		return &referenceSyncError{reason: referenceFetchFailed, err: fmt.Errorf("failed to fetch patient from API: %w", err)}
 	}
 
 	// Upsert the patient
 	err = c.patientModel.UpsertPatient(ctx, patientRef, patientData)
 	if err != nil {
-		return fmt.Errorf("failed to upsert patient: %w", err)
+		return &referenceSyncError{reason: referenceUpsertFailed, err: fmt.Errorf("failed to upsert patient: %w", err)}
 	}
 
 	log.Debug().Str("patient_id", patientRef).Msg("Successfully synced patient")
 	return nil
 }
 
+// includeEncounterPatient fetches the full patient of an encounter and upserts it right away,
+// refreshing it even when it already exists
+func (c *Client) includeEncounterPatient(ctx context.Context, patientRef string) error {
+	patientData, err := c.fetchPatientFromAPI(ctx, patientRef)
+	if err != nil {
+		metrics.RecordPatientInlineFetch("error")
+		return fmt.Errorf("failed to fetch patient from API: %w", err)
+	}
+
+	if err := c.patientModel.UpsertPatient(ctx, patientRef, patientData); err != nil {
+		metrics.RecordPatientInlineFetch("error")
+		return fmt.Errorf("failed to upsert patient: %w", err)
+	}
+
+	metrics.RecordPatientInlineFetch("success")
+	log.Debug().Str("patient_id", patientRef).Msg("Included encounter patient")
+	return nil
+}
+
 // syncPractitioner syncs a practitioner reference with FHIR API
 func (c *Client) syncPractitioner(ctx context.Context, practitionerRef string) error {
 	// Check if practitioner already exists in Couchbase
 	exists, err := c.practitionerModel.PractitionerExists(ctx, practitionerRef)
 	if err != nil {
-		return fmt.Errorf("failed to check practitioner existence: %w", err)
+		return &referenceSyncError{reason: referenceLookupFailed, err: fmt.Errorf("failed to check practitioner existence: %w", err)}
 	}
 
 	if exists {
@@ -129,15 +201,34 @@ func (c *Client) syncPractitioner(ctx context.Context, practitionerRef string) e
 	// Practitioner doesn't exist, fetch from FHIR API
 	practitionerData, err := c.fetchPractitionerFromAPI(ctx, practitionerRef)
 	if err != nil {
-		return fmt.Errorf("failed to fetch practitioner from API: %w", err)
+		return &referenceSyncError{reason: referenceFetchFailed, err: fmt.Errorf("failed to fetch practitioner from API: %w", err)}
 	}
 
 	// Upsert the practitioner
 	err = c.practitionerModel.UpsertPractitioner(ctx, practitionerRef, practitionerData)
 	if err != nil {
-		return fmt.Errorf("failed to upsert practitioner: %w", err)
+		return &referenceSyncError{reason: referenceUpsertFailed, err: fmt.Errorf("failed to upsert practitioner: %w", err)}
 	}
 
 	log.Debug().Str("practitioner_id", practitionerRef).Msg("Successfully synced practitioner")
 	return nil
 }
+
+// recordReferenceSyncError counts a patient or practitioner reference that failed to sync
+func (c *Client) recordReferenceSyncError(referenceType string, err error) {
+	metrics.RecordReferenceSyncError(referenceType, referenceSyncErrorReason(err))
+}
+
+// recordMissingReferences records an encounter whose patient could not be synced,
+// in the current run and in the encounters_with_missing_references set
+func (c *Client) recordMissingReferences(ctx context.Context, encounterID string) {
+	if c.run != nil {
+		c.run.recordMissingReferences(encounterID)
+	}
+	if c.missingReferences == nil {
+		return
+	}
+	if err := c.missingReferences.AddEncounter(ctx, encounterID); err != nil {
+		log.Warn().Err(err).Str("encounter_id", encounterID).Msg("Failed to record encounter with missing references")
+	}
+}
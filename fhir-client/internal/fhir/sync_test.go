@@ -0,0 +1,169 @@
+package fhir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"stealthcompany.com/fhir-client/internal/metrics"
+)
+
+// memoryPatientStore keeps upserted patients in memory; bulk upserts fail the patients listed in failures
+type memoryPatientStore struct {
+	patients  map[string]map[string]interface{}
+	failures  map[string]error
+	bulkCalls int
+}
+
+func (m *memoryPatientStore) PatientExists(ctx context.Context, patientID string) (bool, error) {
+	_, ok := m.patients[patientID]
+	return ok, nil
+}
+
+func (m *memoryPatientStore) UpsertPatient(ctx context.Context, patientID string, data map[string]interface{}) error {
+	m.patients[patientID] = data
+	return nil
+}
+
+func (m *memoryPatientStore) BulkUpsertPatients(ctx context.Context, patients map[string]map[string]interface{}) map[string]error {
+	m.bulkCalls++
+	failures := make(map[string]error)
+	for patientID, data := range patients {
+		if err, ok := m.failures[patientID]; ok {
+			failures[patientID] = err
+			continue
+		}
+		m.patients[patientID] = data
+	}
+	return failures
+}
+
+func TestIncludeEncounterPatient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/Patient/p1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/fhir+json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"resourceType":"Patient","id":"p1","name":[{"family":"Silva"}]}`))
+	}))
+	defer server.Close()
+
+	// p1 is stored with stale data and must be refreshed from the API
+	store := &memoryPatientStore{patients: map[string]map[string]interface{}{
+		"p1": {"resourceType": "Patient", "id": "p1"},
+	}}
+	client := &Client{httpClient: server.Client(), fhirBaseURL: server.URL, patientModel: store, includePatient: true}
+
+	if err := client.includeEncounterPatient(context.Background(), "p1"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	patient, ok := store.patients["p1"]
+	if !ok {
+		t.Fatal("Expected patient p1 to be stored")
+	}
+	if _, ok := patient["name"]; !ok {
+		t.Errorf("Expected the full patient from the API, got %v", patient)
+	}
+
+	if err := client.includeEncounterPatient(context.Background(), "missing"); err == nil {
+		t.Error("Expected an error for a patient the API does not have")
+	}
+	if _, ok := store.patients["missing"]; ok {
+		t.Error("Expected no patient stored when the fetch fails")
+	}
+}
+
+// memoryMissingReferences keeps the encounters recorded with missing references
+type memoryMissingReferences struct {
+	encounterIDs []string
+}
+
+func (m *memoryMissingReferences) AddEncounter(ctx context.Context, encounterID string) error {
+	m.encounterIDs = append(m.encounterIDs, encounterID)
+	return nil
+}
+
+func TestSyncEncounterReferenceErrors(t *testing.T) {
+	// The FHIR API knows no patient or practitioner, so every fetch fails
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	missing := &memoryMissingReferences{}
+	client := &Client{
+		httpClient:        server.Client(),
+		fhirBaseURL:       server.URL,
+		patientModel:      &memoryPatientStore{patients: map[string]map[string]interface{}{}},
+		practitionerModel: &memoryPractitionerStore{practitioners: map[string]map[string]interface{}{}},
+		missingReferences: missing,
+		run:               newIngestRun(),
+	}
+
+	patientErrors := metrics.FHIRReferenceSyncErrorTotal.WithLabelValues("Patient", referenceFetchFailed)
+	practitionerErrors := metrics.FHIRReferenceSyncErrorTotal.WithLabelValues("Practitioner", referenceFetchFailed)
+	patientBefore := promtestutil.ToFloat64(patientErrors)
+	practitionerBefore := promtestutil.ToFloat64(practitionerErrors)
+
+	encounter := map[string]interface{}{
+		"resourceType": "Encounter",
+		"id":           "enc-1",
+		"subject":      map[string]interface{}{"reference": "Patient/p1"},
+		"participant": []interface{}{
+			map[string]interface{}{"individual": map[string]interface{}{"reference": "Practitioner/pr1"}},
+			map[string]interface{}{"individual": map[string]interface{}{"reference": "Practitioner/pr2"}},
+		},
+	}
+	if err := client.syncEncounter(context.Background(), "enc-1", encounter); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := promtestutil.ToFloat64(patientErrors) - patientBefore; got != 1 {
+		t.Errorf("Expected 1 patient sync error, got %v", got)
+	}
+	if got := promtestutil.ToFloat64(practitionerErrors) - practitionerBefore; got != 2 {
+		t.Errorf("Expected 2 practitioner sync errors, got %v", got)
+	}
+	if len(missing.encounterIDs) != 1 || missing.encounterIDs[0] != "enc-1" {
+		t.Errorf("Expected enc-1 recorded with missing references, got %v", missing.encounterIDs)
+	}
+	if got := client.run.manifest(server.URL, nil, nil, time.Now()).EncountersWithMissingReferences; got != 1 {
+		t.Errorf("Expected 1 encounter with missing references in the manifest, got %d", got)
+	}
+}
+
+func TestReferenceSyncErrorReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "lookup", err: &referenceSyncError{reason: referenceLookupFailed, err: errors.New("timeout")}, want: referenceLookupFailed},
+		{name: "wrapped upsert", err: fmt.Errorf("sync: %w", &referenceSyncError{reason: referenceUpsertFailed, err: errors.New("cas")}), want: referenceUpsertFailed},
+		{name: "other error", err: errors.New("boom"), want: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := referenceSyncErrorReason(tt.err); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSyncExistingDataSkippedOnFreshIngestion(t *testing.T) {
+	// No encounter model is set: a first run must return before touching existing encounters
+	client := &Client{skipSyncIfFreshIngestion: true}
+
+	if err := client.syncExistingData(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
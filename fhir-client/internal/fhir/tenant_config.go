@@ -0,0 +1,233 @@
+package fhir
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"stealthcompany.com/fhir-client/internal/dal"
+)
+
+// defaultTenantIngestionConcurrency is the default of FHIR_TENANT_INGESTION_CONCURRENCY
+const defaultTenantIngestionConcurrency = 2
+
+// TenantFHIRConfig is the FHIR server a tenant is ingested from, into its own scope
+type TenantFHIRConfig struct {
+	TenantID string
+	BaseURL  string
+	// Timeout of each FHIR request, FHIR_TIMEOUT when zero
+	Timeout time.Duration
+	// PageSize is the _count of every search, the FHIR_*_PAGE_SIZE settings when zero
+	PageSize int
+}
+
+// UnmarshalJSON reads a tenant config whose timeout is a duration string such as "30s"
+func (tc *TenantFHIRConfig) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		TenantID string `json:"tenantId"`
+		BaseURL  string `json:"baseUrl"`
+		Timeout  string `json:"timeout"`
+		PageSize int    `json:"pageSize"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*tc = TenantFHIRConfig{TenantID: raw.TenantID, BaseURL: raw.BaseURL, PageSize: raw.PageSize}
+	if raw.Timeout != "" {
+		timeout, err := time.ParseDuration(raw.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q of tenant %q: %w", raw.Timeout, raw.TenantID, err)
+		}
+		tc.Timeout = timeout
+	}
+	return nil
+}
+
+// validate checks the tenant ID, the base URL, the timeout and the page size of a tenant config
+func (tc TenantFHIRConfig) validate() error {
+	if err := dal.ValidateTenantScope(tc.TenantID); err != nil {
+		return err
+	}
+	baseURL, err := url.Parse(tc.BaseURL)
+	if err != nil || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.Host == "" {
+		return fmt.Errorf("invalid baseUrl %q of tenant %q: must be an http or https URL", tc.BaseURL, tc.TenantID)
+	}
+	if tc.Timeout < 0 {
+		return fmt.Errorf("invalid timeout %s of tenant %q: must not be negative", tc.Timeout, tc.TenantID)
+	}
+	if tc.PageSize != 0 && (tc.PageSize < minFHIRPageSize || tc.PageSize > maxFHIRPageSize) {
+		return fmt.Errorf("invalid pageSize %d of tenant %q: must be between %d and %d",
+			tc.PageSize, tc.TenantID, minFHIRPageSize, maxFHIRPageSize)
+	}
+	return nil
+}
+
+// loadTenantConfigs reads the JSON array of tenant configs of a file
+func loadTenantConfigs(path string) ([]TenantFHIRConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant config file: %w", err)
+	}
+
+	var configs []TenantFHIRConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant config file %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		if err := config.validate(); err != nil {
+			return nil, err
+		}
+		if seen[config.TenantID] {
+			return nil, fmt.Errorf("duplicate tenant %q in tenant config file", config.TenantID)
+		}
+		seen[config.TenantID] = true
+	}
+	return configs, nil
+}
+
+// tenantConfigsFromEnv reads the tenant configs of FHIR_TENANT_CONFIG_FILE, none when it is unset
+func tenantConfigsFromEnv() ([]TenantFHIRConfig, error) {
+	path := os.Getenv("FHIR_TENANT_CONFIG_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return loadTenantConfigs(path)
+}
+
+// tenantIngestionConcurrencyFromEnv reads FHIR_TENANT_INGESTION_CONCURRENCY, the most ingestions running at once
+func tenantIngestionConcurrencyFromEnv() (int, error) {
+	value := getEnvOrDefault("FHIR_TENANT_INGESTION_CONCURRENCY", strconv.Itoa(defaultTenantIngestionConcurrency))
+	concurrency, err := strconv.Atoi(value)
+	if err != nil || concurrency < 1 {
+		return 0, fmt.Errorf("invalid FHIR_TENANT_INGESTION_CONCURRENCY %q: must be at least 1", value)
+	}
+	return concurrency, nil
+}
+
+// forTenant returns a client ingesting a tenant from its own FHIR server into the tenant scope.
+// It shares the Couchbase connection and the settings the config does not override, but none of the run state.
+func (c *Client) forTenant(config TenantFHIRConfig) *Client {
+	tenant := &Client{
+		httpClient:          c.httpClient,
+		dal:                 c.dal,
+		tenantID:            config.TenantID,
+		fhirBaseURL:         config.BaseURL,
+		timeout:             c.timeout,
+		encounterFilter:     c.encounterFilter,
+		observationCodes:    c.observationCodes,
+		pageSizes:           c.pageSizes,
+		maxPages:            c.maxPages,
+		retryPolicy:         c.retryPolicy,
+		includePatient:      c.includePatient,
+		parallelIngestion:   c.parallelIngestion,
+		manifestWriter:      c.manifestWriter,
+		practitionersSource: c.practitionersSource,
+	}
+
+	if config.Timeout > 0 {
+		tenant.timeout = config.Timeout
+		tenant.httpClient = &http.Client{
+			Timeout:   config.Timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		}
+	}
+	if config.PageSize > 0 {
+		tenant.pageSizes = PageSizes{
+			Encounter:         config.PageSize,
+			Patient:           config.PageSize,
+			Practitioner:      config.PageSize,
+			Observation:       config.PageSize,
+			Condition:         config.PageSize,
+			MedicationRequest: config.PageSize,
+		}
+	}
+
+	if c.dal != nil {
+		resourceModel := dal.NewResourceModelWithTenant(c.dal, config.TenantID)
+		tenant.resourceModel = resourceModel
+		tenant.encounterModel = dal.NewEncounterModel(resourceModel)
+		tenant.patientModel = dal.NewPatientModel(resourceModel)
+		tenant.practitionerModel = dal.NewPractitionerModel(resourceModel)
+		tenant.observationModel = dal.NewObservationModel(resourceModel)
+		tenant.conditionModel = dal.NewConditionModel(resourceModel)
+		tenant.medicationRequestModel = dal.NewMedicationRequestModel(resourceModel)
+		tenant.missingReferences = dal.NewMissingReferencesModelWithTenant(c.dal, config.TenantID)
+		tenant.checkpoints = dal.NewIngestionCheckpointModelWithTenant(c.dal, config.TenantID)
+		tenant.ingestionStatus = dal.NewIngestionStatusModelWithTenant(c.dal, config.TenantID)
+	}
+	tenant.encounterPractitioners = &practitionerRefSet{}
+	return tenant
+}
+
+// ensureTenantScope creates the scope of a tenant client before its ingestion; overridden in tests
+var ensureTenantScope = func(ctx context.Context, c *Client) error {
+	return dal.NewScopeModel(c.dal).EnsureTenantScope(ctx, c.tenantID)
+}
+
+// IngestAllTenants runs IngestData for the default tenant, from FHIR_BASE_URL into the default scope,
+// and for each tenant of FHIR_TENANT_CONFIG_FILE into its own scope
+func (c *Client) IngestAllTenants(ctx context.Context) error {
+	clients := []*Client{c}
+	for _, config := range c.tenants {
+		clients = append(clients, c.forTenant(config))
+	}
+	return ingestConcurrently(ctx, clients, c.tenantConcurrency)
+}
+
+// ingestConcurrently runs the ingestion of each client, at most concurrency at once,
+// and returns the failures of all of them
+func ingestConcurrently(ctx context.Context, clients []*Client, concurrency int) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(clients))
+	for i, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			errs[i] = client.ingestTenant(ctx)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// ingestTenant runs IngestData, creating the tenant scope first for a tenant with its own FHIR server
+func (c *Client) ingestTenant(ctx context.Context) error {
+	if c.tenantID == "" {
+		return c.IngestData(ctx)
+	}
+
+	log.Info().Str("tenant", c.tenantID).Str("fhir_base_url", c.fhirBaseURL).Msg("Starting tenant FHIR ingestion")
+	if err := ensureTenantScope(ctx, c); err != nil {
+		return fmt.Errorf("tenant %s: %w", c.tenantID, err)
+	}
+	if err := c.IngestData(ctx); err != nil {
+		return fmt.Errorf("tenant %s: %w", c.tenantID, err)
+	}
+	log.Info().Str("tenant", c.tenantID).Msg("Tenant FHIR ingestion completed")
+	return nil
+}
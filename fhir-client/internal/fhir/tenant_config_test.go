@@ -0,0 +1,213 @@
+package fhir
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeTenantConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write tenant config file: %v", err)
+	}
+	return path
+}
+
+func TestTenantConfigsFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []TenantFHIRConfig
+		wantErr string
+	}{
+		{
+			name: "valid tenants",
+			content: `[
+				{"tenantId": "acme", "baseUrl": "https://fhir.acme.example/R4", "timeout": "45s", "pageSize": 100},
+				{"tenantId": "globex", "baseUrl": "http://fhir.globex.example"}
+			]`,
+			want: []TenantFHIRConfig{
+				{TenantID: "acme", BaseURL: "https://fhir.acme.example/R4", Timeout: 45 * time.Second, PageSize: 100},
+				{TenantID: "globex", BaseURL: "http://fhir.globex.example"},
+			},
+		},
+		{name: "empty list", content: `[]`, want: []TenantFHIRConfig{}},
+		{name: "not json", content: `tenants`, wantErr: "failed to parse"},
+		{name: "invalid timeout", content: `[{"tenantId": "acme", "baseUrl": "https://fhir.acme.example", "timeout": "soon"}]`, wantErr: "invalid timeout"},
+		{name: "negative timeout", content: `[{"tenantId": "acme", "baseUrl": "https://fhir.acme.example", "timeout": "-1s"}]`, wantErr: "must not be negative"},
+		{name: "missing tenant", content: `[{"baseUrl": "https://fhir.acme.example"}]`, wantErr: "invalid tenant scope"},
+		{name: "default scope", content: `[{"tenantId": "_default", "baseUrl": "https://fhir.acme.example"}]`, wantErr: "invalid tenant scope"},
+		{name: "invalid base url", content: `[{"tenantId": "acme", "baseUrl": "fhir.acme.example"}]`, wantErr: "invalid baseUrl"},
+		{name: "page size above maximum", content: `[{"tenantId": "acme", "baseUrl": "https://fhir.acme.example", "pageSize": 10001}]`, wantErr: "invalid pageSize"},
+		{
+			name:    "duplicate tenant",
+			content: `[{"tenantId": "acme", "baseUrl": "https://a.example"}, {"tenantId": "acme", "baseUrl": "https://b.example"}]`,
+			wantErr: "duplicate tenant",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FHIR_TENANT_CONFIG_FILE", writeTenantConfigFile(t, tt.content))
+
+			got, err := tenantConfigsFromEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("tenantConfigsFromEnv() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tenantConfigsFromEnv() unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("tenantConfigsFromEnv() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("tenant %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTenantConfigsFromEnvUnset(t *testing.T) {
+	t.Setenv("FHIR_TENANT_CONFIG_FILE", "")
+
+	got, err := tenantConfigsFromEnv()
+	if err != nil || got != nil {
+		t.Errorf("tenantConfigsFromEnv() = %+v, %v, want no tenants", got, err)
+	}
+
+	t.Setenv("FHIR_TENANT_CONFIG_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := tenantConfigsFromEnv(); err == nil {
+		t.Error("Expected an error for a missing tenant config file")
+	}
+}
+
+func TestTenantIngestionConcurrencyFromEnv(t *testing.T) {
+	t.Setenv("FHIR_TENANT_INGESTION_CONCURRENCY", "")
+	if got, err := tenantIngestionConcurrencyFromEnv(); err != nil || got != defaultTenantIngestionConcurrency {
+		t.Errorf("tenantIngestionConcurrencyFromEnv() = %d, %v, want the default", got, err)
+	}
+
+	t.Setenv("FHIR_TENANT_INGESTION_CONCURRENCY", "0")
+	if _, err := tenantIngestionConcurrencyFromEnv(); err == nil {
+		t.Error("Expected an error for a concurrency below 1")
+	}
+}
+
+func TestForTenant(t *testing.T) {
+	base := &Client{
+		fhirBaseURL: "https://default.example",
+		timeout:     30 * time.Second,
+		pageSizes:   PageSizes{Encounter: 500, Patient: 500, Practitioner: 500, Observation: 500, Condition: 500, MedicationRequest: 500},
+		maxPages:    3,
+	}
+
+	tenant := base.forTenant(TenantFHIRConfig{TenantID: "acme", BaseURL: "https://fhir.acme.example", Timeout: 5 * time.Second, PageSize: 50})
+	if tenant.tenantID != "acme" || tenant.fhirBaseURL != "https://fhir.acme.example" {
+		t.Errorf("Expected the tenant ID and base URL of the config, got %q and %q", tenant.tenantID, tenant.fhirBaseURL)
+	}
+	if tenant.timeout != 5*time.Second || tenant.httpClient == nil || tenant.httpClient.Timeout != 5*time.Second {
+		t.Errorf("Expected the tenant timeout, got %s", tenant.timeout)
+	}
+	if want := (PageSizes{Encounter: 50, Patient: 50, Practitioner: 50, Observation: 50, Condition: 50, MedicationRequest: 50}); tenant.pageSizes != want {
+		t.Errorf("Expected the tenant page size for every type, got %+v", tenant.pageSizes)
+	}
+	if tenant.maxPages != 3 {
+		t.Errorf("Expected the settings of the default client to be kept, got max pages %d", tenant.maxPages)
+	}
+
+	defaults := base.forTenant(TenantFHIRConfig{TenantID: "globex", BaseURL: "https://fhir.globex.example"})
+	if defaults.timeout != base.timeout || defaults.pageSizes != base.pageSizes {
+		t.Errorf("Expected the timeout and page sizes of the default client, got %s and %+v", defaults.timeout, defaults.pageSizes)
+	}
+	if base.tenantID != "" || base.fhirBaseURL != "https://default.example" {
+		t.Error("Expected the default client to be left unchanged")
+	}
+}
+
+func TestIngestConcurrently(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	var mu sync.Mutex
+	var ensured []string
+	release := make(chan struct{})
+
+	restore := ensureTenantScope
+	defer func() { ensureTenantScope = restore }()
+	ensureTenantScope = func(ctx context.Context, c *Client) error {
+		current := running.Add(1)
+		defer running.Add(-1)
+		for {
+			previous := maxRunning.Load()
+			if current <= previous || maxRunning.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+
+		mu.Lock()
+		ensured = append(ensured, c.tenantID)
+		mu.Unlock()
+		<-release
+
+		// Failing here stops each tenant before IngestData, which needs Couchbase
+		return errors.New("scope creation failed")
+	}
+
+	base := &Client{}
+	var clients []*Client
+	for _, tenantID := range []string{"acme", "globex", "initech"} {
+		clients = append(clients, base.forTenant(TenantFHIRConfig{TenantID: tenantID, BaseURL: "https://fhir." + tenantID + ".example"}))
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- ingestConcurrently(context.Background(), clients, 2)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if got := running.Load(); got != 2 {
+		t.Errorf("Expected 2 tenant ingestions running at once, got %d", got)
+	}
+	close(release)
+
+	err := <-done
+	if got := maxRunning.Load(); got != 2 {
+		t.Errorf("Expected at most 2 tenant ingestions at once, got %d", got)
+	}
+	if len(ensured) != 3 {
+		t.Errorf("Expected the scope of every tenant to be ensured, got %v", ensured)
+	}
+	for _, tenantID := range []string{"acme", "globex", "initech"} {
+		if err == nil || !strings.Contains(err.Error(), "tenant "+tenantID+": scope creation failed") {
+			t.Errorf("Expected the failure of tenant %s to be returned, got %v", tenantID, err)
+		}
+	}
+}
+
+func TestIngestConcurrentlyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	restore := ensureTenantScope
+	defer func() { ensureTenantScope = restore }()
+	ensureTenantScope = func(ctx context.Context, c *Client) error {
+		return ctx.Err()
+	}
+
+	base := &Client{}
+	clients := []*Client{base.forTenant(TenantFHIRConfig{TenantID: "acme", BaseURL: "https://fhir.acme.example"})}
+	if err := ingestConcurrently(ctx, clients, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -2,10 +2,12 @@ package metrics
 
 import (
 	"runtime"
+	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"stealthcompany.com/pkg/tenantlabel"
 )
 
 var (
@@ -15,7 +17,7 @@ var (
 			Name: "fhir_ingestion_total",
 			Help: "Total number of FHIR resources ingested",
 		},
-		[]string{"resource_type", "status"}, // "success", "skipped"
+		[]string{"resource_type", "status", "tenant"}, // "success", "skipped"; tenant is empty unless METRICS_INCLUDE_TENANT_LABEL=true
 	)
 
 	// FHIRIngestionDuration tracks ingestion duration
@@ -53,7 +55,7 @@ var (
 			Name: "http_fetch_total",
 			Help: "Total number of HTTP fetch operations",
 		},
-		[]string{"operation", "status"}, // "bundle_fetch", "resource_fetch", "success", "error"
+		[]string{"operation", "resource_type", "status"}, // "bundle_fetch", "resource_fetch"; "success", "error"
 	)
 
 	// HTTPFetchDuration tracks HTTP fetch duration
@@ -72,7 +74,7 @@ var (
 			Name: "couchbase_operations_total",
 			Help: "Total number of Couchbase operations",
 		},
-		[]string{"operation", "status"}, // "upsert", "get", "query", "success", "error"
+		[]string{"operation", "status", "tenant"}, // "upsert", "get", "query", "success", "error"; tenant is empty unless METRICS_INCLUDE_TENANT_LABEL=true
 	)
 
 	// CouchbaseOperationDuration tracks Couchbase operation duration
@@ -82,7 +84,89 @@ var (
 			Help:    "Duration of Couchbase operations in seconds",
 			Buckets: prometheus.DefBuckets,
 		},
-		[]string{"operation"},
+		[]string{"operation", "tenant"}, // tenant is empty unless METRICS_INCLUDE_TENANT_LABEL=true
+	)
+
+	// CouchbaseUpsertRetryTotal tracks upsert retries after transient errors
+	CouchbaseUpsertRetryTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "couchbase_upsert_retry_total",
+			Help: "Total number of Couchbase upsert retries after transient errors",
+		},
+		[]string{"attempt"},
+	)
+
+	// CouchbasePingDuration tracks connection liveness ping duration
+	CouchbasePingDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "couchbase_ping_duration_seconds",
+			Help:    "Duration of Couchbase connection liveness pings in seconds",
+			Buckets: []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5},
+		},
+		[]string{"status"}, // "success", "error", "timeout"
+	)
+
+	// FHIRReferenceParseTotal tracks FHIR reference parsing outcomes
+	FHIRReferenceParseTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fhir_reference_parse_total",
+			Help: "Total number of FHIR references parsed",
+		},
+		[]string{"resource_type", "reason"}, // "ok", "type_mismatch", "urn_uuid", "urn_oid", "invalid"
+	)
+
+	// FHIRResourceSizeBytes tracks the JSON size of FHIR resources before storage
+	FHIRResourceSizeBytes = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "fhir_resource_size_bytes",
+			Help:    "JSON size of FHIR resources upserted to Couchbase in bytes",
+			Buckets: []float64{1 << 10, 10 << 10, 100 << 10, 1 << 20, 10 << 20}, // 1KB, 10KB, 100KB, 1MB, 10MB
+		},
+		[]string{"resource_type"},
+	)
+
+	// FHIRResourceSizeExceededTotal tracks resources rejected for exceeding FHIR_MAX_RESOURCE_SIZE_BYTES
+	FHIRResourceSizeExceededTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fhir_resource_size_exceeded_total",
+			Help: "Total number of FHIR resources rejected for exceeding the maximum size",
+		},
+		[]string{"resource_type"},
+	)
+
+	// FHIRPatientInlineFetchTotal tracks patients fetched inline during encounter ingestion
+	FHIRPatientInlineFetchTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fhir_patient_inline_fetch_total",
+			Help: "Total number of patients fetched inline during encounter ingestion",
+		},
+		[]string{"status"}, // "success", "error"
+	)
+
+	// FHIRDedupSkipTotal tracks upserts skipped because the resource content hash was unchanged
+	FHIRDedupSkipTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fhir_dedup_skip_total",
+			Help: "Total number of FHIR resource upserts skipped because the content was unchanged",
+		},
+		[]string{"resource_type"},
+	)
+
+	// FHIRReferenceSyncErrorTotal tracks patient and practitioner references of encounters that failed to sync
+	FHIRReferenceSyncErrorTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fhir_reference_sync_error_total",
+			Help: "Total number of encounter references that failed to sync",
+		},
+		[]string{"reference_type", "error_reason"}, // "Patient", "Practitioner"; "lookup_failed", "fetch_failed", "upsert_failed"
+	)
+
+	// FHIRPractitionerDedupRatio tracks distinct over total practitioner references of ingested encounters
+	FHIRPractitionerDedupRatio = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "fhir_practitioner_dedup_ratio",
+			Help: "Distinct practitioner references divided by total practitioner references of ingested encounters",
+		},
 	)
 
 	GoMemstatsAllocBytes = promauto.NewGaugeVec(
@@ -110,10 +194,14 @@ var (
 	)
 )
 
-// RecordFHIRIngestion records metrics for FHIR resource ingestion
-func RecordFHIRIngestion(resourceType string, ingested, skipped int) {
-	FHIRIngestionTotal.WithLabelValues(resourceType, "success").Add(float64(ingested))
-	FHIRIngestionTotal.WithLabelValues(resourceType, "skipped").Add(float64(skipped))
+// tenantLabels is the process-wide set of tenant label values
+var tenantLabels = tenantlabel.NewSet()
+
+// RecordFHIRIngestion records metrics for FHIR resource ingestion; tenantID is empty for the default scope
+func RecordFHIRIngestion(tenantID, resourceType string, ingested, skipped int) {
+	tenant := tenantLabels.Label(tenantID)
+	FHIRIngestionTotal.WithLabelValues(resourceType, "success", tenant).Add(float64(ingested))
+	FHIRIngestionTotal.WithLabelValues(resourceType, "skipped", tenant).Add(float64(skipped))
 }
 
 // RecordFHIRAPICall records metrics for FHIR API calls
@@ -126,9 +214,9 @@ func RecordFHIRAPICallDuration(resourceType, operation string, duration time.Dur
 	FHIRAPICallDuration.WithLabelValues(resourceType, operation).Observe(duration.Seconds())
 }
 
-// RecordHTTPFetch records HTTP fetch operations
-func RecordHTTPFetch(operation, status string) {
-	HTTPFetchTotal.WithLabelValues(operation, status).Inc()
+// RecordHTTPFetch records HTTP fetch operations; every bundle page counts as one fetch
+func RecordHTTPFetch(operation, resourceType, status string) {
+	HTTPFetchTotal.WithLabelValues(operation, resourceType, status).Inc()
 }
 
 // RecordHTTPFetchDuration records HTTP fetch duration
@@ -136,14 +224,64 @@ func RecordHTTPFetchDuration(operation string, duration time.Duration) {
 	HTTPFetchDuration.WithLabelValues(operation).Observe(duration.Seconds())
 }
 
-// RecordCouchbaseOperation records metrics for Couchbase operations
-func RecordCouchbaseOperation(operation, status string) {
-	CouchbaseOperationsTotal.WithLabelValues(operation, status).Inc()
+// RecordCouchbaseOperation records metrics for Couchbase operations; tenantID is empty for the default scope
+func RecordCouchbaseOperation(tenantID, operation, status string) {
+	CouchbaseOperationsTotal.WithLabelValues(operation, status, tenantLabels.Label(tenantID)).Inc()
+}
+
+// RecordCouchbaseOperations records count Couchbase operations at once, as done by bulk operations
+func RecordCouchbaseOperations(tenantID, operation, status string, count int) {
+	CouchbaseOperationsTotal.WithLabelValues(operation, status, tenantLabels.Label(tenantID)).Add(float64(count))
 }
 
 // RecordCouchbaseOperationDuration records Couchbase operation duration
-func RecordCouchbaseOperationDuration(operation string, duration time.Duration) {
-	CouchbaseOperationDuration.WithLabelValues(operation).Observe(duration.Seconds())
+func RecordCouchbaseOperationDuration(tenantID, operation string, duration time.Duration) {
+	CouchbaseOperationDuration.WithLabelValues(operation, tenantLabels.Label(tenantID)).Observe(duration.Seconds())
+}
+
+// RecordCouchbaseUpsertRetry records an upsert retry by its attempt number
+func RecordCouchbaseUpsertRetry(attempt int) {
+	CouchbaseUpsertRetryTotal.WithLabelValues(strconv.Itoa(attempt)).Inc()
+}
+
+// RecordCouchbasePing records the duration and outcome of a connection liveness ping
+func RecordCouchbasePing(status string, duration time.Duration) {
+	CouchbasePingDuration.WithLabelValues(status).Observe(duration.Seconds())
+}
+
+// RecordReferenceParse records the outcome of parsing a FHIR reference
+func RecordReferenceParse(resourceType, reason string) {
+	FHIRReferenceParseTotal.WithLabelValues(resourceType, reason).Inc()
+}
+
+// RecordResourceSize records the JSON size of a FHIR resource
+func RecordResourceSize(resourceType string, sizeBytes int) {
+	FHIRResourceSizeBytes.WithLabelValues(resourceType).Observe(float64(sizeBytes))
+}
+
+// RecordResourceSizeExceeded records a FHIR resource rejected for its size
+func RecordResourceSizeExceeded(resourceType string) {
+	FHIRResourceSizeExceededTotal.WithLabelValues(resourceType).Inc()
+}
+
+// RecordPatientInlineFetch records a patient fetched inline during encounter ingestion
+func RecordPatientInlineFetch(status string) {
+	FHIRPatientInlineFetchTotal.WithLabelValues(status).Inc()
+}
+
+// RecordDedupSkip records an upsert skipped because the resource content was unchanged
+func RecordDedupSkip(resourceType string) {
+	FHIRDedupSkipTotal.WithLabelValues(resourceType).Inc()
+}
+
+// RecordReferenceSyncError records an encounter reference that failed to sync
+func RecordReferenceSyncError(referenceType, reason string) {
+	FHIRReferenceSyncErrorTotal.WithLabelValues(referenceType, reason).Inc()
+}
+
+// SetPractitionerDedupRatio records the deduplication ratio of encounter practitioner references
+func SetPractitionerDedupRatio(ratio float64) {
+	FHIRPractitionerDedupRatio.Set(ratio)
 }
 
 // UpdateSystemMetrics updates Go runtime metrics with service label
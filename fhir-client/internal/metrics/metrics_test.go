@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"stealthcompany.com/pkg/tenantlabel"
+)
+
+// resetTenantLabels empties the tenant label set for the duration of a test
+func resetTenantLabels(t *testing.T) {
+	t.Helper()
+	tenantLabels = tenantlabel.NewSet()
+	t.Cleanup(func() {
+		tenantLabels = tenantlabel.NewSet()
+	})
+}
+
+func TestRecordFHIRIngestionTenantLabel(t *testing.T) {
+	resetTenantLabels(t)
+	t.Setenv("METRICS_INCLUDE_TENANT_LABEL", "true")
+	t.Setenv("METRICS_TENANT_ALLOWLIST", "tenant-a")
+	FHIRIngestionTotal.Reset()
+	t.Cleanup(FHIRIngestionTotal.Reset)
+
+	RecordFHIRIngestion("", "patients", 1, 0)
+	RecordFHIRIngestion("tenant-a", "patients", 2, 0)
+	RecordFHIRIngestion("tenant-b", "patients", 3, 0)
+
+	tests := []struct {
+		tenant string
+		want   float64
+	}{
+		{tenant: "", want: 1},
+		{tenant: tenantlabel.Sanitize("tenant-a"), want: 2},
+		{tenant: tenantlabel.Overflow, want: 3},
+	}
+	for _, tt := range tests {
+		if got := promtestutil.ToFloat64(FHIRIngestionTotal.WithLabelValues("patients", "success", tt.tenant)); got != tt.want {
+			t.Errorf("fhir_ingestion_total{tenant=%q} = %v, want %v", tt.tenant, got, tt.want)
+		}
+	}
+}
+
+func TestRecordCouchbaseOperationTenantLabelDisabled(t *testing.T) {
+	resetTenantLabels(t)
+	t.Setenv("METRICS_INCLUDE_TENANT_LABEL", "false")
+	CouchbaseOperationsTotal.Reset()
+	t.Cleanup(CouchbaseOperationsTotal.Reset)
+	CouchbaseOperationDuration.Reset()
+	t.Cleanup(CouchbaseOperationDuration.Reset)
+
+	for _, tenant := range []string{"", "tenant-a", "tenant-b"} {
+		RecordCouchbaseOperation(tenant, "get", "success")
+		RecordCouchbaseOperationDuration(tenant, "get", time.Millisecond)
+	}
+
+	if got := promtestutil.ToFloat64(CouchbaseOperationsTotal.WithLabelValues("get", "success", "")); got != 3 {
+		t.Errorf("Expected every operation in the series without tenant, got %v", got)
+	}
+	if got := promtestutil.CollectAndCount(CouchbaseOperationDuration); got != 1 {
+		t.Errorf("Expected a single duration series with tenant labels disabled, got %d", got)
+	}
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName is reported as service.name on every exported span
+const serviceName = "fhir-client"
+
+// tracer starts the spans of the ingestion; it delegates to the provider set by Init, a no-op until then
+var tracer = otel.Tracer("stealthcompany.com/fhir-client")
+
+// Init exports spans over OTLP/HTTP to OTEL_EXPORTER_OTLP_ENDPOINT and propagates W3C trace context.
+// Without the endpoint nothing is configured and the returned shutdown function does nothing.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// The exporter reads the endpoint and the other OTEL_EXPORTER_OTLP_* variables itself
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	resource, err := sdkresource.Merge(
+		sdkresource.Default(),
+		sdkresource.NewSchemaless(attribute.String("service.name", serviceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// StartDBSpan starts a span for a Couchbase operation on resources of resourceType
+func StartDBSpan(ctx context.Context, name, operation, resourceType string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("db.system", "couchbase"),
+		attribute.String("db.operation", operation),
+		attribute.String("fhir.resource_type", resourceType),
+	))
+}
+
+// StartFetchSpan starts a span for fetching a FHIR search bundle of resourceType
+func StartFetchSpan(ctx context.Context, name, resourceType string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("fhir.resource_type", resourceType),
+	))
+}
+
+// EndSpan marks the span as failed when err is not nil and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInitWithoutEndpoint(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	before := otel.GetTracerProvider()
+
+	shutdown, err := Init(context.Background())
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown() error = %v", err)
+	}
+	if otel.GetTracerProvider() != before {
+		t.Errorf("Expected the global tracer provider to be left unchanged")
+	}
+}
+
+func TestStartFetchSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	original := tracer
+	tracer = provider.Tracer("test")
+	t.Cleanup(func() {
+		tracer = original
+	})
+
+	_, span := StartFetchSpan(context.Background(), "Client.fetchFHIRBundle", "Patient")
+	EndSpan(span, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	var resourceType string
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == "fhir.resource_type" {
+			resourceType = kv.Value.AsString()
+		}
+	}
+	if resourceType != "Patient" {
+		t.Errorf("Expected fhir.resource_type Patient, got %q", resourceType)
+	}
+	if spans[0].Status().Code != codes.Unset {
+		t.Errorf("Expected unset status, got %v", spans[0].Status().Code)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"stealthcompany.com/fhir-client/internal/dal"
 	"stealthcompany.com/fhir-client/internal/fhir"
 	"stealthcompany.com/fhir-client/internal/metrics"
+	"stealthcompany.com/fhir-client/internal/tracing"
 	"stealthcompany.com/pkg/zerolog_config"
 )
 
@@ -40,6 +41,17 @@ func main() {
 
 	log.Info().Msg("Starting evtechallenge-fhir service")
 
+	// Export traces when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize tracing")
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Warn().Err(err).Msg("Failed to flush traces")
+		}
+	}()
+
 	// Start system metrics collection
 	metrics.StartSystemMetricsCollection("fhir-client")
 
@@ -64,12 +76,6 @@ func main() {
 		}
 	}()
 
-	// Create FHIR client
-	fhirClient, err := fhir.NewClient()
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to create FHIR client")
-	}
-
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -84,8 +90,20 @@ func main() {
 		cancel()
 	}()
 
-	// Run FHIR data ingestion
-	err = fhirClient.IngestData(ctx)
+	// Create FHIR client
+	fhirClient, err := fhir.NewClient(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create FHIR client")
+	}
+
+	// Create collections and indexes before ingestion starts
+	err = fhirClient.Init(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize FHIR client")
+	}
+
+	// Run FHIR data ingestion, for the default tenant and each tenant with its own FHIR server
+	err = fhirClient.IngestAllTenants(ctx)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to ingest FHIR data")
 	}
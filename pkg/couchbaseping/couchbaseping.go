@@ -0,0 +1,48 @@
+// Package couchbaseping checks Couchbase liveness with a hard timeout, shared by api-rest and fhir-client.
+package couchbaseping
+
+import (
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// Ping results passed to the recorder
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+	StatusTimeout = "timeout"
+)
+
+// Pinger is the part of gocb.Cluster used for liveness checks
+type Pinger interface {
+	Ping(opts *gocb.PingOptions) (*gocb.PingResult, error)
+}
+
+// WithTimeout pings the cluster and reports it dead if the ping fails or exceeds the timeout.
+// record receives the result and duration of the ping, e.g. the RecordCouchbasePing metric of a service.
+func WithTimeout(cluster Pinger, timeout time.Duration, record func(status string, duration time.Duration)) bool {
+	start := time.Now()
+
+	// The SDK timeout is also enforced here in case the ping ignores it
+	done := make(chan error, 1)
+	go func() {
+		_, err := cluster.Ping(&gocb.PingOptions{Timeout: timeout})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			record(StatusError, time.Since(start))
+			return false
+		}
+		record(StatusSuccess, time.Since(start))
+		return true
+	case <-time.After(timeout):
+		record(StatusTimeout, time.Since(start))
+		log.Warn().Dur("timeout", timeout).Msg("Couchbase ping timed out, treating connection as dead")
+		return false
+	}
+}
@@ -0,0 +1,71 @@
+package couchbaseping
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// mockPinger answers Ping after a delay
+type mockPinger struct {
+	delay time.Duration
+	err   error
+}
+
+func (m *mockPinger) Ping(opts *gocb.PingOptions) (*gocb.PingResult, error) {
+	time.Sleep(m.delay)
+	return &gocb.PingResult{}, m.err
+}
+
+func TestWithTimeout(t *testing.T) {
+	tests := []struct {
+		name           string
+		pinger         *mockPinger
+		expected       bool
+		expectedStatus string
+	}{
+		{
+			name:           "Responsive cluster",
+			pinger:         &mockPinger{},
+			expected:       true,
+			expectedStatus: StatusSuccess,
+		},
+		{
+			name:           "Ping error",
+			pinger:         &mockPinger{err: errors.New("unambiguous timeout")},
+			expected:       false,
+			expectedStatus: StatusError,
+		},
+		{
+			name:           "Unresponsive cluster",
+			pinger:         &mockPinger{delay: 5 * time.Second},
+			expected:       false,
+			expectedStatus: StatusTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var statuses []string
+			record := func(status string, duration time.Duration) {
+				statuses = append(statuses, status)
+			}
+
+			start := time.Now()
+			alive := WithTimeout(tt.pinger, 2*time.Second, record)
+			elapsed := time.Since(start)
+
+			if alive != tt.expected {
+				t.Errorf("Expected alive %v, got %v", tt.expected, alive)
+			}
+			if len(statuses) != 1 || statuses[0] != tt.expectedStatus {
+				t.Errorf("Expected a single %q ping recorded, got %v", tt.expectedStatus, statuses)
+			}
+			if elapsed > 3*time.Second {
+				t.Errorf("Expected liveness check to return within 3s, took %v", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package fhirutil
+
+import "strings"
+
+// mandatoryElements are always returned by FilterElements, as the FHIR spec requires
+var mandatoryElements = []string{"resourceType", "id"}
+
+// ParseElements splits a comma-separated _elements parameter, dropping blanks
+func ParseElements(param string) []string {
+	var elements []string
+	for _, element := range strings.Split(param, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+// FilterElements returns a copy of doc with only the requested top-level elements plus resourceType and id.
+// Unknown elements are ignored, and no elements returns doc unchanged.
+func FilterElements(doc map[string]interface{}, elements []string) map[string]interface{} {
+	if len(elements) == 0 {
+		return doc
+	}
+
+	filtered := make(map[string]interface{}, len(elements)+len(mandatoryElements))
+	for _, names := range [][]string{elements, mandatoryElements} {
+		for _, element := range names {
+			if value, ok := doc[element]; ok {
+				filtered[element] = value
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,79 @@
+package fhirutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseElements(t *testing.T) {
+	tests := []struct {
+		name     string
+		param    string
+		expected []string
+	}{
+		{name: "Empty", param: "", expected: nil},
+		{name: "Single element", param: "status", expected: []string{"status"}},
+		{name: "Spaces and blanks", param: " id, status ,,subject ", expected: []string{"id", "status", "subject"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseElements(tt.param); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFilterElements(t *testing.T) {
+	doc := map[string]interface{}{
+		"resourceType": "Encounter",
+		"id":           "enc-1",
+		"status":       "finished",
+		"subject":      map[string]interface{}{"reference": "Patient/p1"},
+		"period":       map[string]interface{}{"start": "2025-01-01T10:00:00Z"},
+	}
+
+	tests := []struct {
+		name     string
+		elements []string
+		expected map[string]interface{}
+	}{
+		{
+			name:     "Requested elements plus mandatory ones",
+			elements: []string{"status", "subject"},
+			expected: map[string]interface{}{
+				"resourceType": "Encounter",
+				"id":           "enc-1",
+				"status":       "finished",
+				"subject":      map[string]interface{}{"reference": "Patient/p1"},
+			},
+		},
+		{
+			name:     "Unknown element is ignored",
+			elements: []string{"status", "hospitalization"},
+			expected: map[string]interface{}{
+				"resourceType": "Encounter",
+				"id":           "enc-1",
+				"status":       "finished",
+			},
+		},
+		{
+			name:     "No elements returns the full resource",
+			elements: nil,
+			expected: doc,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FilterElements(doc, tt.elements); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	if len(doc) != 5 {
+		t.Errorf("Expected the source document to be left untouched, got %v", doc)
+	}
+}
@@ -0,0 +1,41 @@
+package fhirutil
+
+import "strings"
+
+// FHIR content types accepted by the API
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeFHIRJSON = "application/fhir+json"
+)
+
+// OperationOutcome issue severities and codes used by the API
+const (
+	IssueSeverityError = "error"
+	IssueCodeNotFound  = "not-found"
+)
+
+// NewOperationOutcome builds a FHIR R4 OperationOutcome with a single issue
+func NewOperationOutcome(severity, code, diagnostics string) map[string]interface{} {
+	return map[string]interface{}{
+		"resourceType": "OperationOutcome",
+		"issue": []interface{}{
+			map[string]interface{}{
+				"severity":    severity,
+				"code":        code,
+				"diagnostics": diagnostics,
+			},
+		},
+	}
+}
+
+// NegotiateContentType returns application/fhir+json when the Accept header asks for it,
+// and application/json otherwise
+func NegotiateContentType(accept string) string {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		if strings.TrimSpace(mediaType) == ContentTypeFHIRJSON {
+			return ContentTypeFHIRJSON
+		}
+	}
+	return ContentTypeJSON
+}
@@ -0,0 +1,62 @@
+package fhirutil
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewOperationOutcome(t *testing.T) {
+	outcome := NewOperationOutcome(IssueSeverityError, IssueCodeNotFound, "Encounter/123 not found")
+
+	// Round-trip through JSON to check the wire structure
+	body, err := json.Marshal(outcome)
+	if err != nil {
+		t.Fatalf("Failed to marshal OperationOutcome: %v", err)
+	}
+
+	var decoded struct {
+		ResourceType string `json:"resourceType"`
+		Issue        []struct {
+			Severity    string `json:"severity"`
+			Code        string `json:"code"`
+			Diagnostics string `json:"diagnostics"`
+		} `json:"issue"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal OperationOutcome: %v", err)
+	}
+
+	if decoded.ResourceType != "OperationOutcome" {
+		t.Errorf("Expected resourceType OperationOutcome, got %q", decoded.ResourceType)
+	}
+	if len(decoded.Issue) != 1 {
+		t.Fatalf("Expected 1 issue, got %d", len(decoded.Issue))
+	}
+	issue := decoded.Issue[0]
+	if issue.Severity != "error" || issue.Code != "not-found" || issue.Diagnostics != "Encounter/123 not found" {
+		t.Errorf("Unexpected issue: %+v", issue)
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   string
+		expected string
+	}{
+		{name: "No Accept header", accept: "", expected: ContentTypeJSON},
+		{name: "JSON", accept: "application/json", expected: ContentTypeJSON},
+		{name: "FHIR JSON", accept: "application/fhir+json", expected: ContentTypeFHIRJSON},
+		{name: "FHIR JSON with parameters", accept: "application/fhir+json; fhirVersion=4.0", expected: ContentTypeFHIRJSON},
+		{name: "FHIR JSON among others", accept: "text/html, application/fhir+json;q=0.9", expected: ContentTypeFHIRJSON},
+		{name: "Wildcard", accept: "*/*", expected: ContentTypeJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NegotiateContentType(tt.accept); got != tt.expected {
+				t.Errorf("NegotiateContentType(%q) = %q, want %q", tt.accept, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package fhirutil
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Reference parse reasons, used as metric labels
+const (
+	ReferenceReasonOK           = "ok"
+	ReferenceReasonTypeMismatch = "type_mismatch"
+	ReferenceReasonURNUUID      = "urn_uuid"
+	ReferenceReasonURNOID       = "urn_oid"
+	ReferenceReasonInvalid      = "invalid"
+)
+
+// ReferenceObserver is notified with the outcome of every parsed reference
+type ReferenceObserver func(resourceType, reason string)
+
+// ExtractPatientRef returns the bare patient ID referenced by an encounter's subject,
+// or an empty string when there is no resolvable patient reference
+func ExtractPatientRef(resource map[string]interface{}, observers ...ReferenceObserver) string {
+	subject, ok := resource["subject"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	reference, ok := subject["reference"].(string)
+	if !ok {
+		return ""
+	}
+
+	return parseObserved(reference, "Patient", observers)
+}
+
+// ExtractEncounterRef returns the bare encounter ID referenced by a resource's encounter field
+// (e.g. an Observation), or an empty string when there is no resolvable encounter reference
+func ExtractEncounterRef(resource map[string]interface{}, observers ...ReferenceObserver) string {
+	encounter, ok := resource["encounter"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	reference, ok := encounter["reference"].(string)
+	if !ok {
+		return ""
+	}
+
+	return parseObserved(reference, "Encounter", observers)
+}
+
+// ExtractPractitionerRefs returns the bare practitioner IDs referenced by an encounter's participants
+func ExtractPractitionerRefs(resource map[string]interface{}, observers ...ReferenceObserver) []string {
+	var refs []string
+
+	participants, ok := resource["participant"].([]interface{})
+	if !ok {
+		return refs
+	}
+
+	for _, participant := range participants {
+		p, ok := participant.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		individual, ok := p["individual"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		reference, ok := individual["reference"].(string)
+		if !ok {
+			continue
+		}
+
+		if ref := parseObserved(reference, "Practitioner", observers); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+
+	return refs
+}
+
+// parseObserved parses a reference and notifies the observers of the outcome
+func parseObserved(reference, resourceType string, observers []ReferenceObserver) string {
+	refID, reason := ParseReference(reference, resourceType)
+	for _, observe := range observers {
+		observe(resourceType, reason)
+	}
+	return refID
+}
+
+// ParseReference extracts the bare ID from a FHIR reference and returns the reason when it can't:
+// "Patient/123" -> "123"
+// "https://hapi.fhir.org/baseR4/Patient/123/" -> "123"
+// "Patient/123/_history/2" -> "123"
+// "urn:uuid:abc-123", "urn:oid:1.2.3" -> "" (not resolvable via FHIR API)
+func ParseReference(reference, resourceType string) (string, string) {
+	reference = strings.TrimSpace(reference)
+
+	if strings.HasPrefix(reference, "urn:uuid:") {
+		// Inline bundle reference: skip external sync
+		return "", ReferenceReasonURNUUID
+	}
+	if strings.HasPrefix(reference, "urn:oid:") {
+		return "", ReferenceReasonURNOID
+	}
+
+	path := reference
+	if strings.Contains(reference, "://") {
+		parsed, err := url.Parse(reference)
+		if err != nil {
+			return "", ReferenceReasonInvalid
+		}
+		path = parsed.Path
+	}
+
+	// Drop version suffix and trailing slashes
+	if idx := strings.Index(path, "/_history"); idx >= 0 {
+		path = path[:idx]
+	}
+	path = strings.Trim(path, "/")
+
+	segments := strings.Split(path, "/")
+	if len(segments) < 2 || segments[len(segments)-1] == "" {
+		return "", ReferenceReasonInvalid
+	}
+	if segments[len(segments)-2] != resourceType {
+		return "", ReferenceReasonTypeMismatch
+	}
+
+	return segments[len(segments)-1], ReferenceReasonOK
+}
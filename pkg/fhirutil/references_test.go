@@ -0,0 +1,231 @@
+package fhirutil
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name           string
+		reference      string
+		resourceType   string
+		expectedID     string
+		expectedReason string
+	}{
+		{
+			name:           "Relative reference",
+			reference:      "Patient/1234",
+			resourceType:   "Patient",
+			expectedID:     "1234",
+			expectedReason: ReferenceReasonOK,
+		},
+		{
+			name:           "Absolute HAPI reference",
+			reference:      "https://hapi.fhir.org/baseR4/Patient/1234",
+			resourceType:   "Patient",
+			expectedID:     "1234",
+			expectedReason: ReferenceReasonOK,
+		},
+		{
+			name:           "Absolute reference with trailing slash",
+			reference:      "https://hapi.fhir.org/baseR4/Practitioner/5678/",
+			resourceType:   "Practitioner",
+			expectedID:     "5678",
+			expectedReason: ReferenceReasonOK,
+		},
+		{
+			name:           "Versioned absolute reference",
+			reference:      "http://hapi.fhir.org/baseR4/Patient/1234/_history/3",
+			resourceType:   "Patient",
+			expectedID:     "1234",
+			expectedReason: ReferenceReasonOK,
+		},
+		{
+			name:           "Versioned relative reference",
+			reference:      "Practitioner/5678/_history/1",
+			resourceType:   "Practitioner",
+			expectedID:     "5678",
+			expectedReason: ReferenceReasonOK,
+		},
+		{
+			name:           "Different resource type",
+			reference:      "Group/456",
+			resourceType:   "Patient",
+			expectedReason: ReferenceReasonTypeMismatch,
+		},
+		{
+			name:           "Absolute reference of different resource type",
+			reference:      "https://hapi.fhir.org/baseR4/Organization/1",
+			resourceType:   "Practitioner",
+			expectedReason: ReferenceReasonTypeMismatch,
+		},
+		{
+			name:           "Inline bundle uuid reference",
+			reference:      "urn:uuid:9f2c6a1e-58c4-4d0b-9e0a-1c2f3b4a5d6e",
+			resourceType:   "Patient",
+			expectedReason: ReferenceReasonURNUUID,
+		},
+		{
+			name:           "OID reference",
+			reference:      "urn:oid:2.16.840.1.113883.4.642",
+			resourceType:   "Patient",
+			expectedReason: ReferenceReasonURNOID,
+		},
+		{
+			name:           "Empty reference",
+			reference:      "",
+			resourceType:   "Patient",
+			expectedReason: ReferenceReasonInvalid,
+		},
+		{
+			name:           "Type without ID",
+			reference:      "Patient/",
+			resourceType:   "Patient",
+			expectedReason: ReferenceReasonInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, reason := ParseReference(tt.reference, tt.resourceType)
+			if id != tt.expectedID {
+				t.Errorf("Expected ID %q, got %q", tt.expectedID, id)
+			}
+			if reason != tt.expectedReason {
+				t.Errorf("Expected reason %q, got %q", tt.expectedReason, reason)
+			}
+		})
+	}
+}
+
+// loadHAPIEncounters reads the sample HAPI FHIR search bundle
+func loadHAPIEncounters(t *testing.T) []map[string]interface{} {
+	t.Helper()
+
+	content, err := os.ReadFile("testdata/hapi_encounters.json")
+	if err != nil {
+		t.Fatalf("Failed to read sample bundle: %v", err)
+	}
+
+	var bundle struct {
+		Entry []struct {
+			Resource map[string]interface{} `json:"resource"`
+		} `json:"entry"`
+	}
+	if err := json.Unmarshal(content, &bundle); err != nil {
+		t.Fatalf("Failed to parse sample bundle: %v", err)
+	}
+
+	var encounters []map[string]interface{}
+	for _, entry := range bundle.Entry {
+		encounters = append(encounters, entry.Resource)
+	}
+	return encounters
+}
+
+func TestExtractRefsFromHAPIEncounters(t *testing.T) {
+	encounters := loadHAPIEncounters(t)
+
+	tests := []struct {
+		name                  string
+		encounter             map[string]interface{}
+		expectedPatient       string
+		expectedPractitioners []string
+	}{
+		{
+			name:                  "Relative references",
+			encounter:             encounters[0],
+			expectedPatient:       "592911",
+			expectedPractitioners: []string{"592908"},
+		},
+		{
+			name:                  "Absolute and versioned references, other participant types skipped",
+			encounter:             encounters[1],
+			expectedPatient:       "1234",
+			expectedPractitioners: []string{"5678", "5679"},
+		},
+		{
+			name:                  "Inline bundle references",
+			encounter:             encounters[2],
+			expectedPatient:       "",
+			expectedPractitioners: nil,
+		},
+		{
+			name:                  "Encounter without subject or participants",
+			encounter:             map[string]interface{}{"resourceType": "Encounter", "id": "1"},
+			expectedPatient:       "",
+			expectedPractitioners: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if patient := ExtractPatientRef(tt.encounter); patient != tt.expectedPatient {
+				t.Errorf("Expected patient %q, got %q", tt.expectedPatient, patient)
+			}
+			if practitioners := ExtractPractitionerRefs(tt.encounter); !reflect.DeepEqual(practitioners, tt.expectedPractitioners) {
+				t.Errorf("Expected practitioners %v, got %v", tt.expectedPractitioners, practitioners)
+			}
+		})
+	}
+}
+
+func TestExtractRefsNotifiesObservers(t *testing.T) {
+	encounters := loadHAPIEncounters(t)
+
+	reasons := make(map[string]int)
+	observe := func(resourceType, reason string) {
+		reasons[resourceType+":"+reason]++
+	}
+
+	ExtractPatientRef(encounters[1], observe)
+	ExtractPractitionerRefs(encounters[1], observe)
+
+	expected := map[string]int{
+		"Patient:" + ReferenceReasonOK:                1,
+		"Practitioner:" + ReferenceReasonOK:           2,
+		"Practitioner:" + ReferenceReasonTypeMismatch: 1,
+	}
+	if !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("Expected observed reasons %v, got %v", expected, reasons)
+	}
+}
+
+func TestExtractEncounterRef(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource map[string]interface{}
+		expected string
+	}{
+		{
+			name: "Relative reference",
+			resource: map[string]interface{}{
+				"encounter": map[string]interface{}{"reference": "Encounter/42"},
+			},
+			expected: "42",
+		},
+		{
+			name: "Type mismatch",
+			resource: map[string]interface{}{
+				"encounter": map[string]interface{}{"reference": "Patient/42"},
+			},
+			expected: "",
+		},
+		{
+			name:     "No encounter",
+			resource: map[string]interface{}{"subject": map[string]interface{}{"reference": "Patient/1"}},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractEncounterRef(tt.resource); got != tt.expected {
+				t.Errorf("Expected encounter %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
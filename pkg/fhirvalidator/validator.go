@@ -0,0 +1,73 @@
+package fhirvalidator
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidResource is wrapped by errors returned for resources that fail validation
+var ErrInvalidResource = errors.New("invalid FHIR resource")
+
+// ValidationError describes a missing or malformed field of a FHIR resource
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+// Error implements the error interface
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidateResource checks that a FHIR R4 resource has the minimal required fields:
+// resourceType and id for every resource, plus type-specific fields
+// (Encounter requires status, Patient requires a name or an identifier, Observation requires status and code)
+func ValidateResource(resourceType string, data map[string]interface{}) []ValidationError {
+	var errs []ValidationError
+
+	if data == nil {
+		return []ValidationError{{Field: "resource", Message: "resource is empty"}}
+	}
+
+	switch value := data["resourceType"].(type) {
+	case string:
+		if value != resourceType {
+			errs = append(errs, ValidationError{
+				Field:   "resourceType",
+				Message: fmt.Sprintf("expected %s, got %s", resourceType, value),
+			})
+		}
+	default:
+		errs = append(errs, ValidationError{Field: "resourceType", Message: "must be a string"})
+	}
+
+	if id, ok := data["id"].(string); !ok || id == "" {
+		errs = append(errs, ValidationError{Field: "id", Message: "must be a non-empty string"})
+	}
+
+	switch resourceType {
+	case "Encounter":
+		if status, ok := data["status"].(string); !ok || status == "" {
+			errs = append(errs, ValidationError{Field: "status", Message: "must be a non-empty string"})
+		}
+	case "Patient":
+		if !hasElements(data["name"]) && !hasElements(data["identifier"]) {
+			errs = append(errs, ValidationError{Field: "name", Message: "at least one name or identifier is required"})
+		}
+	case "Observation":
+		if status, ok := data["status"].(string); !ok || status == "" {
+			errs = append(errs, ValidationError{Field: "status", Message: "must be a non-empty string"})
+		}
+		if _, ok := data["code"].(map[string]interface{}); !ok {
+			errs = append(errs, ValidationError{Field: "code", Message: "must be a CodeableConcept"})
+		}
+	}
+
+	return errs
+}
+
+// hasElements checks if a value is a non-empty array
+func hasElements(value interface{}) bool {
+	elements, ok := value.([]interface{})
+	return ok && len(elements) > 0
+}
@@ -0,0 +1,111 @@
+package fhirvalidator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateResource(t *testing.T) {
+	tests := []struct {
+		name           string
+		resourceType   string
+		data           map[string]interface{}
+		expectedFields []string
+	}{
+		{
+			name:         "Valid encounter",
+			resourceType: "Encounter",
+			data: map[string]interface{}{
+				"resourceType": "Encounter",
+				"id":           "1",
+				"status":       "finished",
+			},
+		},
+		{
+			name:           "Encounter without status",
+			resourceType:   "Encounter",
+			data:           map[string]interface{}{"resourceType": "Encounter", "id": "1"},
+			expectedFields: []string{"status"},
+		},
+		{
+			name:         "Valid patient with name",
+			resourceType: "Patient",
+			data: map[string]interface{}{
+				"resourceType": "Patient",
+				"id":           "1",
+				"name":         []interface{}{map[string]interface{}{"family": "Silva"}},
+			},
+		},
+		{
+			name:         "Valid patient with identifier only",
+			resourceType: "Patient",
+			data: map[string]interface{}{
+				"resourceType": "Patient",
+				"id":           "1",
+				"identifier":   []interface{}{map[string]interface{}{"value": "123"}},
+			},
+		},
+		{
+			name:         "Patient without name or identifier",
+			resourceType: "Patient",
+			data: map[string]interface{}{
+				"resourceType": "Patient",
+				"id":           "1",
+				"name":         []interface{}{},
+			},
+			expectedFields: []string{"name"},
+		},
+		{
+			name:         "Valid practitioner",
+			resourceType: "Practitioner",
+			data:         map[string]interface{}{"resourceType": "Practitioner", "id": "1"},
+		},
+		{
+			name:         "Valid observation",
+			resourceType: "Observation",
+			data: map[string]interface{}{
+				"resourceType": "Observation",
+				"id":           "1",
+				"status":       "final",
+				"code":         map[string]interface{}{"coding": []interface{}{map[string]interface{}{"code": "8867-4"}}},
+			},
+		},
+		{
+			name:           "Observation without status and code",
+			resourceType:   "Observation",
+			data:           map[string]interface{}{"resourceType": "Observation", "id": "1"},
+			expectedFields: []string{"status", "code"},
+		},
+		{
+			name:           "Missing resourceType and empty id",
+			resourceType:   "Practitioner",
+			data:           map[string]interface{}{"id": ""},
+			expectedFields: []string{"resourceType", "id"},
+		},
+		{
+			name:           "Mismatched resourceType",
+			resourceType:   "Encounter",
+			data:           map[string]interface{}{"resourceType": "Patient", "id": "1", "status": "finished"},
+			expectedFields: []string{"resourceType"},
+		},
+		{
+			name:           "Nil resource",
+			resourceType:   "Encounter",
+			data:           nil,
+			expectedFields: []string{"resource"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []string
+			for _, err := range ValidateResource(tt.resourceType, tt.data) {
+				fields = append(fields, err.Field)
+			}
+
+			if !reflect.DeepEqual(fields, tt.expectedFields) {
+				t.Errorf("Expected invalid fields %v, got %v", tt.expectedFields, fields)
+			}
+		})
+	}
+}
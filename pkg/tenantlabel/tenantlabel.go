@@ -0,0 +1,111 @@
+// Package tenantlabel turns tenant IDs into bounded values of the tenant label of Prometheus metrics,
+// so api-rest and fhir-client label their series the same way.
+package tenantlabel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// Length is how many characters of a tenant ID are kept as its label
+	Length = 8
+	// MaxLabels caps the distinct tenant label values; later tenants share Overflow
+	MaxLabels = 50
+	// Overflow is the label of tenants seen after MaxLabels was reached, or missing from METRICS_TENANT_ALLOWLIST
+	Overflow = "other"
+)
+
+// Set tracks the tenant label values handed out so far
+type Set struct {
+	mu     sync.Mutex
+	labels map[string]struct{}
+}
+
+// NewSet returns an empty label set
+func NewSet() *Set {
+	return &Set{labels: make(map[string]struct{})}
+}
+
+// Label returns the bounded tenant label of a tenant ID, or an empty label when tenant labels are
+// disabled or there is no tenant, so series cardinality is unchanged
+func (s *Set) Label(id string) string {
+	if id == "" || !Enabled() {
+		return ""
+	}
+	if !Allowed(id) {
+		return Overflow
+	}
+	return s.bound(Sanitize(id))
+}
+
+// bound returns label if it is known or there is room for it, and Overflow otherwise
+func (s *Set) bound(label string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.labels[label]; ok {
+		return label
+	}
+	if len(s.labels) >= MaxLabels {
+		return Overflow
+	}
+	s.labels[label] = struct{}{}
+	return label
+}
+
+// Enabled reports whether METRICS_INCLUDE_TENANT_LABEL is set to true (default false)
+func Enabled() bool {
+	return strings.EqualFold(os.Getenv("METRICS_INCLUDE_TENANT_LABEL"), "true")
+}
+
+// Allowed reports whether a tenant ID may get its own label: any tenant when METRICS_TENANT_ALLOWLIST
+// is not set, otherwise only the comma-separated tenant IDs it lists
+func Allowed(id string) bool {
+	allowlist := os.Getenv("METRICS_TENANT_ALLOWLIST")
+	if strings.TrimSpace(allowlist) == "" {
+		return true
+	}
+	for _, allowed := range strings.Split(allowlist, ",") {
+		if strings.TrimSpace(allowed) == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Sanitize shortens a tenant ID to a metric label: the first 8 characters of a UUID,
+// or the first 8 hex characters of its SHA-256 for any other ID
+func Sanitize(id string) string {
+	if id == "" {
+		return ""
+	}
+	if isUUID(id) {
+		return strings.ToLower(id[:Length])
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])[:Length]
+}
+
+// isUUID reports whether id has the canonical 8-4-4-4-12 hex UUID layout
+func isUUID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i, c := range id {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+	return true
+}
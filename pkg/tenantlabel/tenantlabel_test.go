@@ -0,0 +1,84 @@
+package tenantlabel
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want string
+	}{
+		{name: "uuid", id: "3f2504e0-4f89-11d3-9a0c-0305e82c3301", want: "3f2504e0"},
+		{name: "uppercase uuid", id: "3F2504E0-4F89-11D3-9A0C-0305E82C3301", want: "3f2504e0"},
+		{name: "empty", id: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.id); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.id, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("non uuid is hashed", func(t *testing.T) {
+		got := Sanitize("tenant-with-a-long-name")
+		if len(got) != Length {
+			t.Errorf("Expected a %d character label, got %q", Length, got)
+		}
+		if got != Sanitize("tenant-with-a-long-name") {
+			t.Error("Expected the same label for the same tenant")
+		}
+		if got == Sanitize("tenant-with-another-name") {
+			t.Error("Expected different labels for different tenants")
+		}
+	})
+}
+
+func TestLabelDisabledByDefault(t *testing.T) {
+	t.Setenv("METRICS_INCLUDE_TENANT_LABEL", "")
+
+	if got := NewSet().Label("3f2504e0-4f89-11d3-9a0c-0305e82c3301"); got != "" {
+		t.Errorf("Expected an empty tenant label when disabled, got %q", got)
+	}
+}
+
+func TestLabelCardinalityBounded(t *testing.T) {
+	t.Setenv("METRICS_INCLUDE_TENANT_LABEL", "true")
+	labels := NewSet()
+
+	distinct := map[string]bool{}
+	for i := 0; i < MaxLabels*3; i++ {
+		distinct[labels.Label(fmt.Sprintf("tenant-%d", i))] = true
+	}
+
+	// One label per tenant up to the cap, plus the shared overflow label
+	if len(distinct) != MaxLabels+1 || !distinct[Overflow] {
+		t.Errorf("Expected %d labels including %q, got %d", MaxLabels+1, Overflow, len(distinct))
+	}
+	if got := labels.Label("tenant-0"); got != Sanitize("tenant-0") {
+		t.Errorf("Expected an already labelled tenant to keep its label, got %q", got)
+	}
+}
+
+func TestLabelAllowlist(t *testing.T) {
+	t.Setenv("METRICS_INCLUDE_TENANT_LABEL", "true")
+	t.Setenv("METRICS_TENANT_ALLOWLIST", "tenant-a, tenant-b")
+	labels := NewSet()
+
+	if got := labels.Label("tenant-a"); got != Sanitize("tenant-a") {
+		t.Errorf("Expected allow-listed tenant to keep its label, got %q", got)
+	}
+	if got := labels.Label("tenant-b"); got != Sanitize("tenant-b") {
+		t.Errorf("Expected allow-listed tenant to keep its label, got %q", got)
+	}
+	if got := labels.Label("tenant-c"); got != Overflow {
+		t.Errorf("Expected unknown tenant to be labelled %q, got %q", Overflow, got)
+	}
+	if got := labels.Label(""); got != "" {
+		t.Errorf("Expected no tenant to keep an empty label, got %q", got)
+	}
+}
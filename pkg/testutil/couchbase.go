@@ -0,0 +1,264 @@
+// Package testutil provides in-memory stand-ins for Couchbase so DAL unit tests run without a database.
+package testutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// UpsertCall records one MockCollection.Upsert
+type UpsertCall struct {
+	ID      string
+	Value   interface{}
+	Options *gocb.UpsertOptions
+}
+
+// MutateInCall records one MockCollection.MutateIn
+type MutateInCall struct {
+	ID      string
+	Specs   []gocb.MutateInSpec
+	Options *gocb.MutateInOptions
+}
+
+// mockDocument is a stored document with its CAS
+type mockDocument struct {
+	data []byte
+	cas  gocb.Cas
+}
+
+// MockCollection is an in-memory collection. Documents are stored as JSON, so reads decode
+// the same way gocb results do (numbers become float64 in maps). Every write gives the
+// document a new CAS.
+type MockCollection struct {
+	mu        sync.Mutex
+	docs      map[string]mockDocument
+	lastCas   gocb.Cas
+	upserts   []UpsertCall
+	mutations []MutateInCall
+	removals  []string
+
+	// UpsertErr is returned by every Upsert when set
+	UpsertErr error
+}
+
+// NewMockCollection returns an empty collection
+func NewMockCollection() *MockCollection {
+	return &MockCollection{docs: make(map[string]mockDocument)}
+}
+
+// AddFixture stores doc under id, replacing any previous document
+func (c *MockCollection) AddFixture(id string, doc interface{}) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture %s: %w", id, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastCas++
+	c.docs[id] = mockDocument{data: data, cas: c.lastCas}
+	return nil
+}
+
+// Get decodes the document stored under id into valuePtr and returns its CAS.
+// It returns gocb.ErrDocumentNotFound for unknown ids.
+func (c *MockCollection) Get(id string, valuePtr interface{}) (gocb.Cas, error) {
+	c.mu.Lock()
+	doc, ok := c.docs[id]
+	c.mu.Unlock()
+
+	if !ok {
+		return 0, fmt.Errorf("document %s: %w", id, gocb.ErrDocumentNotFound)
+	}
+	return doc.cas, json.Unmarshal(doc.data, valuePtr)
+}
+
+// Upsert records the call and stores the value, unless UpsertErr is set
+func (c *MockCollection) Upsert(id string, val interface{}, opts *gocb.UpsertOptions) (*gocb.MutationResult, error) {
+	c.mu.Lock()
+	c.upserts = append(c.upserts, UpsertCall{ID: id, Value: val, Options: opts})
+	upsertErr := c.UpsertErr
+	c.mu.Unlock()
+
+	if upsertErr != nil {
+		return nil, upsertErr
+	}
+	if err := c.AddFixture(id, val); err != nil {
+		return nil, err
+	}
+	return &gocb.MutationResult{}, nil
+}
+
+// MutateIn records the call and gives the document a new CAS. The specs are recorded for
+// assertions but not applied to the stored document. It returns gocb.ErrDocumentNotFound for
+// unknown ids and gocb.ErrCasMismatch when opts.Cas is set and differs from the document CAS.
+func (c *MockCollection) MutateIn(id string, specs []gocb.MutateInSpec, opts *gocb.MutateInOptions) (*gocb.MutateInResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	doc, ok := c.docs[id]
+	if !ok {
+		return nil, fmt.Errorf("document %s: %w", id, gocb.ErrDocumentNotFound)
+	}
+	if opts != nil && opts.Cas != 0 && opts.Cas != doc.cas {
+		return nil, fmt.Errorf("document %s: %w", id, gocb.ErrCasMismatch)
+	}
+
+	c.mutations = append(c.mutations, MutateInCall{ID: id, Specs: specs, Options: opts})
+	c.lastCas++
+	doc.cas = c.lastCas
+	c.docs[id] = doc
+	return &gocb.MutateInResult{}, nil
+}
+
+// Remove deletes the document stored under id and records the call. It returns
+// gocb.ErrDocumentNotFound for unknown ids.
+func (c *MockCollection) Remove(id string, opts *gocb.RemoveOptions) (*gocb.MutationResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.docs[id]; !ok {
+		return nil, fmt.Errorf("document %s: %w", id, gocb.ErrDocumentNotFound)
+	}
+	c.removals = append(c.removals, id)
+	delete(c.docs, id)
+	return &gocb.MutationResult{}, nil
+}
+
+// RemoveCalls returns the ids of the documents removed so far
+func (c *MockCollection) RemoveCalls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.removals...)
+}
+
+// MutateInCalls returns the successful sub-document mutations recorded so far
+func (c *MockCollection) MutateInCalls() []MutateInCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]MutateInCall(nil), c.mutations...)
+}
+
+// UpsertCalls returns the upserts recorded so far
+func (c *MockCollection) UpsertCalls() []UpsertCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]UpsertCall(nil), c.upserts...)
+}
+
+// MockBucket holds MockCollections by scope and collection name
+type MockBucket struct {
+	mu          sync.Mutex
+	name        string
+	collections map[string]*MockCollection
+}
+
+// NewMockBucket returns a bucket without collections
+func NewMockBucket(name string) *MockBucket {
+	return &MockBucket{name: name, collections: make(map[string]*MockCollection)}
+}
+
+// Name returns the bucket name
+func (b *MockBucket) Name() string {
+	return b.name
+}
+
+// Collection returns the collection of a scope, creating it on first use
+func (b *MockBucket) Collection(scope, name string) *MockCollection {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := scope + "." + name
+	collection, ok := b.collections[key]
+	if !ok {
+		collection = NewMockCollection()
+		b.collections[key] = collection
+	}
+	return collection
+}
+
+// MockQueryResult iterates over configured rows like gocb.QueryResult
+type MockQueryResult struct {
+	// Rows are encoded to JSON and decoded into the value passed to Row
+	Rows []interface{}
+	// Error is returned by Err after the rows are read
+	Error error
+
+	pos    int
+	closed bool
+}
+
+// Next moves to the next row and reports whether there is one
+func (r *MockQueryResult) Next() bool {
+	if r.closed || r.pos >= len(r.Rows) {
+		return false
+	}
+	r.pos++
+	return true
+}
+
+// Row decodes the current row into valuePtr
+func (r *MockQueryResult) Row(valuePtr interface{}) error {
+	if r.pos == 0 || r.pos > len(r.Rows) {
+		return errors.New("no row available")
+	}
+	data, err := json.Marshal(r.Rows[r.pos-1])
+	if err != nil {
+		return fmt.Errorf("failed to encode row: %w", err)
+	}
+	return json.Unmarshal(data, valuePtr)
+}
+
+// Err returns the configured error
+func (r *MockQueryResult) Err() error {
+	return r.Error
+}
+
+// Close stops the iteration
+func (r *MockQueryResult) Close() error {
+	r.closed = true
+	return nil
+}
+
+// QueryCall records one MockCluster.Query
+type QueryCall struct {
+	Statement string
+	Options   *gocb.QueryOptions
+}
+
+// MockCluster answers queries with a configurable result
+type MockCluster struct {
+	mu      sync.Mutex
+	queries []QueryCall
+
+	// Result is returned by Query; a nil Result answers with no rows
+	Result *MockQueryResult
+	// QueryErr is returned by Query when set
+	QueryErr error
+}
+
+// Query records the statement and returns the configured result
+func (c *MockCluster) Query(statement string, opts *gocb.QueryOptions) (*MockQueryResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.queries = append(c.queries, QueryCall{Statement: statement, Options: opts})
+	if c.QueryErr != nil {
+		return nil, c.QueryErr
+	}
+	if c.Result == nil {
+		return &MockQueryResult{}, nil
+	}
+	return c.Result, nil
+}
+
+// QueryCalls returns the queries recorded so far
+func (c *MockCluster) QueryCalls() []QueryCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]QueryCall(nil), c.queries...)
+}
@@ -0,0 +1,159 @@
+package testutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+func TestMockCollection(t *testing.T) {
+	collection := NewMockCollection()
+	if err := collection.AddFixture("Patient/1", map[string]interface{}{"id": "1", "active": true}); err != nil {
+		t.Fatalf("AddFixture() error = %v", err)
+	}
+
+	var doc map[string]interface{}
+	cas, err := collection.Get("Patient/1", &doc)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if cas == 0 {
+		t.Error("Expected a CAS for the fixture")
+	}
+	if doc["active"] != true {
+		t.Errorf("Expected fixture content, got %v", doc)
+	}
+
+	if _, err := collection.Get("Patient/2", &doc); !errors.Is(err, gocb.ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+
+	if _, err := collection.Upsert("Patient/2", map[string]interface{}{"id": "2"}, nil); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if _, err := collection.Get("Patient/2", &doc); err != nil {
+		t.Errorf("Expected upserted document to be readable, got %v", err)
+	}
+
+	calls := collection.UpsertCalls()
+	if len(calls) != 1 || calls[0].ID != "Patient/2" {
+		t.Errorf("Expected one upsert of Patient/2, got %+v", calls)
+	}
+}
+
+func TestMockCollectionUpsertErr(t *testing.T) {
+	collection := NewMockCollection()
+	collection.UpsertErr = gocb.ErrTimeout
+
+	if _, err := collection.Upsert("Patient/1", map[string]interface{}{}, nil); !errors.Is(err, gocb.ErrTimeout) {
+		t.Fatalf("Expected ErrTimeout, got %v", err)
+	}
+
+	var doc map[string]interface{}
+	if _, err := collection.Get("Patient/1", &doc); !errors.Is(err, gocb.ErrDocumentNotFound) {
+		t.Errorf("Expected failed upsert not to be stored, got %v", err)
+	}
+	if len(collection.UpsertCalls()) != 1 {
+		t.Errorf("Expected failed upsert to be recorded")
+	}
+}
+
+func TestMockCollectionMutateInCas(t *testing.T) {
+	collection := NewMockCollection()
+	if err := collection.AddFixture("Encounter/1", map[string]interface{}{"id": "1"}); err != nil {
+		t.Fatalf("AddFixture() error = %v", err)
+	}
+
+	var doc map[string]interface{}
+	cas, err := collection.Get("Encounter/1", &doc)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	specs := []gocb.MutateInSpec{gocb.UpsertSpec("reviewed", true, nil)}
+	if _, err := collection.MutateIn("Encounter/1", specs, &gocb.MutateInOptions{Cas: cas}); err != nil {
+		t.Fatalf("MutateIn() with current CAS error = %v", err)
+	}
+	// The first mutation changed the CAS, so a second writer holding the old one conflicts
+	if _, err := collection.MutateIn("Encounter/1", specs, &gocb.MutateInOptions{Cas: cas}); !errors.Is(err, gocb.ErrCasMismatch) {
+		t.Errorf("Expected ErrCasMismatch, got %v", err)
+	}
+	if _, err := collection.MutateIn("Encounter/2", specs, nil); !errors.Is(err, gocb.ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+
+	if calls := collection.MutateInCalls(); len(calls) != 1 {
+		t.Errorf("Expected one recorded mutation, got %d", len(calls))
+	}
+}
+
+func TestMockBucketCollection(t *testing.T) {
+	bucket := NewMockBucket("evtechallenge")
+
+	if bucket.Collection("tenant1", "patients") != bucket.Collection("tenant1", "patients") {
+		t.Error("Expected the same collection for the same scope and name")
+	}
+	if bucket.Collection("tenant1", "patients") == bucket.Collection("tenant2", "patients") {
+		t.Error("Expected different collections for different scopes")
+	}
+}
+
+func TestMockCluster(t *testing.T) {
+	cluster := &MockCluster{Result: &MockQueryResult{Rows: []interface{}{
+		map[string]interface{}{"id": "a"},
+		map[string]interface{}{"id": "b"},
+	}}}
+
+	rows, err := cluster.Query("SELECT 1", nil)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var row struct {
+			ID string `json:"id"`
+		}
+		if err := rows.Row(&row); err != nil {
+			t.Fatalf("Row() error = %v", err)
+		}
+		ids = append(ids, row.ID)
+	}
+	if len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Errorf("Expected rows a and b, got %v", ids)
+	}
+
+	if calls := cluster.QueryCalls(); len(calls) != 1 || calls[0].Statement != "SELECT 1" {
+		t.Errorf("Expected one recorded query, got %+v", calls)
+	}
+
+	cluster.QueryErr = gocb.ErrTimeout
+	if _, err := cluster.Query("SELECT 2", nil); !errors.Is(err, gocb.ErrTimeout) {
+		t.Errorf("Expected ErrTimeout, got %v", err)
+	}
+}
+
+func TestMockCollectionRemove(t *testing.T) {
+	collection := NewMockCollection()
+	if err := collection.AddFixture("summary/1", map[string]interface{}{"id": "1"}); err != nil {
+		t.Fatalf("AddFixture() error = %v", err)
+	}
+
+	if _, err := collection.Remove("summary/1", nil); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	var doc map[string]interface{}
+	if _, err := collection.Get("summary/1", &doc); !errors.Is(err, gocb.ErrDocumentNotFound) {
+		t.Errorf("Expected removed document to be gone, got %v", err)
+	}
+	if _, err := collection.Remove("summary/1", nil); !errors.Is(err, gocb.ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+
+	calls := collection.RemoveCalls()
+	if len(calls) != 1 || calls[0] != "summary/1" {
+		t.Errorf("Expected one removal of summary/1, got %v", calls)
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -50,14 +54,72 @@ func (clw ConsoleLevelWriter) Write(p []byte) (n int, err error) {
 	return clw.Writer.Write(p)
 }
 
-func startupLoggerWithEnv(elasticsearchURL string, subAddress string, logLevel zerolog.Level) {
-	zerolog.SetGlobalLevel(logLevel)
+// elasticsearchDialTimeout bounds the startup connectivity check against Elasticsearch
+var elasticsearchDialTimeout = 3 * time.Second
+
+// elasticsearchHost returns the host:port of an Elasticsearch URL, defaulting the port from the scheme
+func elasticsearchHost(elasticsearchURL string) (string, error) {
+	parsed, err := url.Parse(elasticsearchURL)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Hostname() == "" {
+		return "", fmt.Errorf("missing host in %q", elasticsearchURL)
+	}
+
+	port := parsed.Port()
+	if port == "" {
+		port = "80"
+		if parsed.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(parsed.Hostname(), port), nil
+}
+
+// isElasticsearchReachable checks that a TCP connection to Elasticsearch can be opened within timeout
+func isElasticsearchReachable(elasticsearchURL string, timeout time.Duration) error {
+	host, err := elasticsearchHost(elasticsearchURL)
+	if err != nil {
+		return fmt.Errorf("invalid Elasticsearch URL: %w", err)
+	}
+
+	conn, err := net.DialTimeout("tcp", host, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// fallbackToConsoleEnabled reads ELASTICSEARCH_FALLBACK_TO_CONSOLE (default true)
+func fallbackToConsoleEnabled() bool {
+	value := os.Getenv("ELASTICSEARCH_FALLBACK_TO_CONSOLE")
+	if value == "" {
+		return true
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return true
+	}
+	return enabled
+}
+
+// newLogger builds the application logger writing to console and, when reachable, to Elasticsearch.
+// It also reports whether Elasticsearch is used and the connectivity check error, if any.
+func newLogger(elasticsearchURL string, subAddress string, console io.Writer) (zerolog.Logger, bool, error) {
+	consoleWriter := zerolog.ConsoleWriter{Out: console}
 
 	if elasticsearchURL == "" {
 		// Fallback to console only
-		log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout}).With().Str("app", appPrefix).
-			Timestamp().Logger()
-		return
+		return zerolog.New(consoleWriter).With().Str("app", appPrefix).
+			Timestamp().Logger(), false, nil
+	}
+
+	reachErr := isElasticsearchReachable(elasticsearchURL, elasticsearchDialTimeout)
+	if reachErr != nil && fallbackToConsoleEnabled() {
+		// Elasticsearch is down, keep the service running with console logs only
+		return zerolog.New(consoleWriter).With().Str("app", appPrefix).
+			Timestamp().Logger(), false, reachErr
 	}
 
 	// ECS format for Elasticsearch with semantic endpoint
@@ -65,17 +127,37 @@ func startupLoggerWithEnv(elasticsearchURL string, subAddress string, logLevel z
 		URL: elasticsearchURL + "/" + subAddress,
 	})
 
-	// Pretty console output
-	consoleWriter := zerolog.ConsoleWriter{Out: os.Stdout}
-
 	// MultiLevelWriter: ECS to Elasticsearch + Pretty to Console
 	multi := zerolog.MultiLevelWriter(
 		ecsLogger,
 		consoleWriter,
 	)
 
-	log.Logger = zerolog.New(multi).With().Str("app", appPrefix).
-		Timestamp().Logger()
+	return zerolog.New(multi).With().Str("app", appPrefix).
+		Timestamp().Logger(), true, reachErr
+}
+
+func startupLoggerWithEnv(elasticsearchURL string, subAddress string, logLevel zerolog.Level) {
+	zerolog.SetGlobalLevel(logLevel)
+
+	logger, usingElasticsearch, reachErr := newLogger(elasticsearchURL, subAddress, os.Stdout)
+	log.Logger = logger
+	// log.Ctx falls back to the global logger for contexts that carry none (e.g. background work)
+	zerolog.DefaultContextLogger = &log.Logger
+
+	if reachErr != nil {
+		if usingElasticsearch {
+			log.Warn().
+				Err(reachErr).
+				Str("elasticsearch_url", elasticsearchURL).
+				Msg("Elasticsearch unreachable at startup, console fallback disabled")
+			return
+		}
+		log.Warn().
+			Err(reachErr).
+			Str("elasticsearch_url", elasticsearchURL).
+			Msg("Elasticsearch unreachable at startup, falling back to console-only logging")
+	}
 }
 
 // SetAppPrefix sets the app prefix
@@ -0,0 +1,108 @@
+package zerolog_config
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// closedPortURL returns an http URL pointing at a local port with nothing listening
+func closedPortURL(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve a port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return "http://" + addr
+}
+
+func TestElasticsearchHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+		wantErr  bool
+	}{
+		{name: "Explicit port", url: "http://elasticsearch:9200", expected: "elasticsearch:9200"},
+		{name: "HTTP default port", url: "http://elasticsearch", expected: "elasticsearch:80"},
+		{name: "HTTPS default port", url: "https://logs.example.com", expected: "logs.example.com:443"},
+		{name: "Missing host", url: "elasticsearch:9200", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, err := elasticsearchHost(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("elasticsearchHost(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+			if host != tt.expected {
+				t.Errorf("elasticsearchHost(%q) = %q, want %q", tt.url, host, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewLoggerFallsBackWhenElasticsearchUnreachable(t *testing.T) {
+	origTimeout := elasticsearchDialTimeout
+	elasticsearchDialTimeout = 500 * time.Millisecond
+	t.Cleanup(func() {
+		elasticsearchDialTimeout = origTimeout
+	})
+
+	tests := []struct {
+		name              string
+		fallback          string
+		wantElasticsearch bool
+	}{
+		{name: "Fallback enabled by default", fallback: "", wantElasticsearch: false},
+		{name: "Fallback enabled", fallback: "true", wantElasticsearch: false},
+		{name: "Fallback disabled", fallback: "false", wantElasticsearch: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ELASTICSEARCH_FALLBACK_TO_CONSOLE", tt.fallback)
+
+			var console bytes.Buffer
+			start := time.Now()
+			logger, usingElasticsearch, reachErr := newLogger(closedPortURL(t), "logs", &console)
+
+			if time.Since(start) > 2*time.Second {
+				t.Errorf("Expected startup not to block, took %v", time.Since(start))
+			}
+			if reachErr == nil {
+				t.Fatal("Expected a connectivity error for a closed port")
+			}
+			if usingElasticsearch != tt.wantElasticsearch {
+				t.Errorf("Expected usingElasticsearch %v, got %v", tt.wantElasticsearch, usingElasticsearch)
+			}
+			if usingElasticsearch {
+				return
+			}
+
+			logger.Info().Msg("service started")
+			if !strings.Contains(console.String(), "service started") {
+				t.Errorf("Expected console output, got %q", console.String())
+			}
+		})
+	}
+}
+
+func TestNewLoggerWithoutElasticsearchURL(t *testing.T) {
+	var console bytes.Buffer
+	logger, usingElasticsearch, reachErr := newLogger("", "logs", &console)
+
+	if usingElasticsearch || reachErr != nil {
+		t.Errorf("Expected console-only logger without error, got usingElasticsearch %v err %v", usingElasticsearch, reachErr)
+	}
+
+	logger.Info().Msg("console only")
+	if !strings.Contains(console.String(), "console only") {
+		t.Errorf("Expected console output, got %q", console.String())
+	}
+}
@@ -195,7 +195,39 @@ curl -s -X POST "$KEYCLOAK_URL/admin/realms/$KEYCLOAK_REALM/users" \
 
 echo "User '$TENANT2_USERNAME' created successfully"
 
-echo "Users created successfully - no roles needed for simple tenant-based access"
+# Create the realm roles checked by the API (reader: GET routes, reviewer: also reviews, admin: also warm-up)
+for ROLE in reader reviewer admin; do
+  echo "Creating realm role '$ROLE'..."
+  curl -s -X POST "$KEYCLOAK_URL/admin/realms/$KEYCLOAK_REALM/roles" \
+    -H "Authorization: Bearer $ADMIN_TOKEN" \
+    -H "Content-Type: application/json" \
+    -d '{"name": "'"$ROLE"'"}' > /dev/null
+done
+
+# assign_realm_role USERNAME ROLE grants a realm role to a user
+assign_realm_role() {
+  USER_ID=$(curl -s -X GET "$KEYCLOAK_URL/admin/realms/$KEYCLOAK_REALM/users?username=$1&exact=true" \
+    -H "Authorization: Bearer $ADMIN_TOKEN" | \
+    grep -o '"id":"[^"]*' | \
+    cut -d'"' -f4 | \
+    head -1)
+  ROLE_JSON=$(curl -s -X GET "$KEYCLOAK_URL/admin/realms/$KEYCLOAK_REALM/roles/$2" \
+    -H "Authorization: Bearer $ADMIN_TOKEN")
+
+  if [ -z "$USER_ID" ] || [ -z "$ROLE_JSON" ]; then
+    echo "ERROR: Failed to assign role '$2' to user '$1'"
+    exit 1
+  fi
+  curl -s -X POST "$KEYCLOAK_URL/admin/realms/$KEYCLOAK_REALM/users/$USER_ID/role-mappings/realm" \
+    -H "Authorization: Bearer $ADMIN_TOKEN" \
+    -H "Content-Type: application/json" \
+    -d "[$ROLE_JSON]" > /dev/null
+  echo "Role '$2' assigned to user '$1'"
+}
+
+# Tenant users read and review their tenant data
+assign_realm_role "$TENANT1_USERNAME" reviewer
+assign_realm_role "$TENANT2_USERNAME" reviewer
 
 # Mark as initialized (skip if no write permissions)
 mkdir -p /tmp/keycloak-init-flag 2>/dev/null || true
@@ -206,5 +238,5 @@ echo "Realm: $KEYCLOAK_REALM"
 echo "Client ID: $KEYCLOAK_CLIENT_ID"
 echo "Client Secret: $CLIENT_SECRET"
 echo "Tenant users created: $TENANT1_USERNAME, $TENANT2_USERNAME"
-echo "Note: Simplified setup - no roles, just tenant-based access"
+echo "Realm roles: reader, reviewer, admin (tenant users are reviewers)"
 echo "Access Keycloak admin console at: $KEYCLOAK_URL"
\ No newline at end of file